@@ -0,0 +1,163 @@
+// Package client is the Go client of the kernel node RPC, with the typed
+// bindings of the methods documented in doc/remote-procedure-calls.md, so the
+// services don't need to decode the response maps by hand.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+)
+
+const (
+	DefaultTimeout = 20 * time.Second
+	DefaultRetries = 3
+	DefaultBackoff = 500 * time.Millisecond
+
+	maxBackoff = 10 * time.Second
+)
+
+// Error is the error rendered by the node for a call, the code is set for the
+// validation errors, and errors.Is or common.ErrorCodeOf works on it.
+type Error struct {
+	Method  string
+	Message string
+	Code    common.ErrorCode
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s => %s", e.Method, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	if e.Code == "" {
+		return nil
+	}
+	return common.Errorf(e.Code, "%s", e.Message)
+}
+
+// Client calls the endpoints in turn, a failed call is retried on the next
+// endpoint with an exponential backoff, but the errors rendered by the node
+// are returned at once, except the full queue, because they would fail again.
+type Client struct {
+	endpoints []string
+	http      *http.Client
+	retries   int
+	backoff   time.Duration
+	next      atomic.Uint64
+}
+
+func New(endpoints ...string) (*Client, error) {
+	if len(endpoints) == 0 {
+		return nil, errors.New("no endpoints")
+	}
+	urls := make([]string, len(endpoints))
+	for i, e := range endpoints {
+		if !hasScheme(e) {
+			e = "http://" + e
+		}
+		urls[i] = e
+	}
+	return &Client{
+		endpoints: urls,
+		http:      &http.Client{Timeout: DefaultTimeout},
+		retries:   DefaultRetries,
+		backoff:   DefaultBackoff,
+	}, nil
+}
+
+// WithRetries sets the retries after the first attempt, and the initial
+// backoff which is doubled after each retry.
+func (c *Client) WithRetries(retries int, backoff time.Duration) *Client {
+	c.retries = max(retries, 0)
+	c.backoff = backoff
+	return c
+}
+
+func (c *Client) WithHTTPClient(hc *http.Client) *Client {
+	c.http = hc
+	return c
+}
+
+// Call sends the method with the params, and decodes the data into the result
+// if not nil. A null data is not an error, e.g. for a transaction not found,
+// and the result is left untouched, so the typed methods check it with nil.
+func (c *Client) Call(ctx context.Context, method string, params []any, result any) error {
+	if params == nil {
+		params = []any{}
+	}
+	body, err := json.Marshal(map[string]any{
+		"method": method,
+		"params": params,
+	})
+	if err != nil {
+		return err
+	}
+
+	backoff := c.backoff
+	for i := 0; ; i++ {
+		endpoint := c.endpoints[int(c.next.Load()%uint64(len(c.endpoints)))]
+		retry, err := c.call(ctx, endpoint, method, body, result)
+		if err == nil || !retry || i >= c.retries || ctx.Err() != nil {
+			return err
+		}
+		c.next.Add(1)
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// the transport errors and the bad status are retried, also the full queue,
+// which is the only one of the validation errors that may pass later.
+func (c *Client) call(ctx context.Context, endpoint, method string, body []byte, result any) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		retry := resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests
+		return retry, fmt.Errorf("%s %s => status %d", endpoint, method, resp.StatusCode)
+	}
+
+	var out struct {
+		Data  json.RawMessage  `json:"data"`
+		Error any              `json:"error"`
+		Code  common.ErrorCode `json:"code"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&out)
+	if err != nil {
+		return true, err
+	}
+	if out.Error != nil {
+		err := &Error{Method: method, Message: fmt.Sprint(out.Error), Code: out.Code}
+		return out.Code == common.ErrorQueueFull, err
+	}
+	if result == nil || len(out.Data) == 0 || bytes.Equal(out.Data, []byte("null")) {
+		return false, nil
+	}
+	return false, json.Unmarshal(out.Data, result)
+}
+
+func hasScheme(endpoint string) bool {
+	return strings.HasPrefix(endpoint, "http://") || strings.HasPrefix(endpoint, "https://")
+}
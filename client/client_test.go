@@ -0,0 +1,388 @@
+package client
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+type testCall struct {
+	Method string `json:"method"`
+	Params []any  `json:"params"`
+}
+
+func testServer(handle func(call *testCall) (int, map[string]any)) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var call testCall
+		err := json.NewDecoder(r.Body).Decode(&call)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		status, body := handle(&call)
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(body)
+	}))
+}
+
+func TestClientRetries(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	var calls atomic.Int32
+	srv := testServer(func(call *testCall) (int, map[string]any) {
+		if calls.Add(1) < 3 {
+			return http.StatusServiceUnavailable, nil
+		}
+		return http.StatusOK, map[string]any{"data": map[string]any{"hash": crypto.Blake3Hash([]byte("tx"))}}
+	})
+	defer srv.Close()
+
+	_, err := New()
+	require.ErrorContains(err, "no endpoints")
+	c, err := New(srv.URL)
+	require.Nil(err)
+	c.WithRetries(3, time.Millisecond)
+	hash, err := c.SendRawTransaction(ctx, "77770005")
+	require.Nil(err)
+	require.Equal(crypto.Blake3Hash([]byte("tx")), hash)
+	require.Equal(int32(3), calls.Load())
+
+	calls.Store(0)
+	c, err = New(srv.URL)
+	require.Nil(err)
+	c.WithRetries(1, time.Millisecond)
+	_, err = c.SendRawTransaction(ctx, "77770005")
+	require.ErrorContains(err, "status 503")
+	require.Equal(int32(2), calls.Load())
+
+	var queue atomic.Int32
+	srv = testServer(func(call *testCall) (int, map[string]any) {
+		calls.Add(1)
+		if call.Params[0] == "full" && queue.Add(1) < 2 {
+			return http.StatusOK, map[string]any{"error": "queue is full", "code": common.ErrorQueueFull}
+		} else if call.Params[0] == "full" {
+			return http.StatusOK, map[string]any{"data": map[string]any{"hash": crypto.Blake3Hash([]byte("full"))}}
+		}
+		return http.StatusOK, map[string]any{"error": "invalid transaction format", "code": common.ErrorInvalidFormat}
+	})
+	defer srv.Close()
+
+	calls.Store(0)
+	c, err = New(srv.URL)
+	require.Nil(err)
+	c.WithRetries(3, time.Millisecond)
+	_, err = c.SendRawTransaction(ctx, "invalid")
+	require.Equal(int32(1), calls.Load())
+	require.Equal(common.ErrorInvalidFormat, common.ErrorCodeOf(err))
+	var re *Error
+	require.True(errors.As(err, &re))
+	require.Equal("sendrawtransaction", re.Method)
+	require.Equal("invalid transaction format", re.Message)
+
+	calls.Store(0)
+	hash, err = c.SendRawTransaction(ctx, "full")
+	require.Nil(err)
+	require.Equal(crypto.Blake3Hash([]byte("full")), hash)
+	require.Equal(int32(2), calls.Load())
+
+	srv = testServer(func(call *testCall) (int, map[string]any) {
+		require.Equal([]any{"77770005", "withdrawal-1"}, call.Params)
+		return http.StatusOK, map[string]any{"data": map[string]any{"hash": crypto.Blake3Hash([]byte("tx"))}}
+	})
+	defer srv.Close()
+	c, err = New(srv.URL)
+	require.Nil(err)
+	hash, err = c.SendRawTransactionWithKey(ctx, "77770005", "withdrawal-1")
+	require.Nil(err)
+	require.Equal(crypto.Blake3Hash([]byte("tx")), hash)
+}
+
+func TestClientEndpoints(t *testing.T) {
+	require := require.New(t)
+
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	srv := testServer(func(call *testCall) (int, map[string]any) {
+		return http.StatusOK, map[string]any{"data": map[string]any{"link": 7}}
+	})
+	defer srv.Close()
+
+	c, err := New(down.URL, srv.URL)
+	require.Nil(err)
+	c.WithRetries(1, time.Millisecond)
+	link, err := c.GetRoundLink(context.Background(), crypto.Hash{}, crypto.Hash{})
+	require.Nil(err)
+	require.Equal(uint64(7), link)
+
+	c, err = New(down.URL)
+	require.Nil(err)
+	c.WithRetries(0, time.Millisecond)
+	_, err = c.GetRoundLink(context.Background(), crypto.Hash{}, crypto.Hash{})
+	require.NotNil(err)
+}
+
+func TestClientTypes(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	asset := crypto.Blake3Hash([]byte("asset"))
+	found := crypto.Blake3Hash([]byte("found"))
+	missing := crypto.Blake3Hash([]byte("missing"))
+	key := crypto.NewKeyFromSeed(make([]byte, 64)).Public()
+	tx := map[string]any{
+		"version": common.TxVersionHashSignature,
+		"asset":   asset,
+		"inputs":  []any{map[string]any{"hash": missing, "index": 1}},
+		"outputs": []any{map[string]any{
+			"type":   common.OutputTypeScript,
+			"amount": common.NewIntegerFromString("1.5"),
+			"keys":   []*crypto.Key{&key},
+			"script": common.NewThresholdScript(1),
+			"mask":   key,
+		}},
+		"extra":      "",
+		"hash":       found,
+		"references": []crypto.Hash{},
+		"hex":        "7777",
+		"snapshot":   missing.String(),
+		"found":      true,
+	}
+
+	srv := testServer(func(call *testCall) (int, map[string]any) {
+		switch call.Method {
+		case "gettransaction":
+			if call.Params[0] == found.String() {
+				return http.StatusOK, map[string]any{"data": tx}
+			}
+			return http.StatusOK, map[string]any{"data": nil}
+		case "gettransactions":
+			return http.StatusOK, map[string]any{"data": []any{
+				map[string]any{"hash": missing, "found": false}, tx,
+			}}
+		case "listsnapshots":
+			return http.StatusOK, map[string]any{"data": []any{
+				map[string]any{"hash": missing, "topology": 3, "round": 2, "transactions": []any{found}},
+				map[string]any{"hash": found, "topology": 4, "round": 2, "transactions": []any{tx}},
+			}}
+		}
+		return http.StatusOK, map[string]any{"error": "invalid method " + call.Method}
+	})
+	defer srv.Close()
+	c, err := New(srv.URL)
+	require.Nil(err)
+	c.WithRetries(0, time.Millisecond)
+
+	res, err := c.GetTransaction(ctx, missing)
+	require.Nil(err)
+	require.Nil(res)
+	res, err = c.GetTransaction(ctx, found)
+	require.Nil(err)
+	require.Equal(found, res.Hash)
+	require.Equal(asset, res.Asset)
+	require.Equal(missing, res.Inputs[0].Hash)
+	require.Equal(uint(1), res.Inputs[0].Index)
+	require.Equal("1.50000000", res.Outputs[0].Amount.String())
+	require.Equal(key, *res.Outputs[0].Keys[0])
+	require.Equal(key, res.Outputs[0].Mask)
+	require.Equal(missing.String(), res.Snapshot)
+
+	txs, err := c.GetTransactions(ctx, []crypto.Hash{missing, found})
+	require.Nil(err)
+	require.Len(txs, 2)
+	require.Nil(txs[0])
+	require.Equal(found, txs[1].Hash)
+
+	snapshots, err := c.ListSnapshots(ctx, 3, 2, false, true)
+	require.Nil(err)
+	require.Len(snapshots, 2)
+	require.Equal(found, snapshots[0].Transactions[0].Hash)
+	require.Nil(snapshots[0].Transactions[0].Transaction)
+	require.Equal(found, snapshots[1].Transactions[0].Hash)
+	require.Equal(asset, snapshots[1].Transactions[0].Transaction.Asset)
+
+	_, err = c.GetInfo(ctx)
+	require.ErrorContains(err, "getinfo => invalid method getinfo")
+	require.Equal(common.ErrorCode(""), common.ErrorCodeOf(err))
+}
+
+func TestSubscribeSnapshots(t *testing.T) {
+	require := require.New(t)
+
+	var polls atomic.Int32
+	srv := testServer(func(call *testCall) (int, map[string]any) {
+		n := polls.Add(1)
+		if n == 2 {
+			return http.StatusOK, map[string]any{"error": "store closed"}
+		}
+		offset := uint64(call.Params[0].(float64))
+		count := uint64(call.Params[1].(float64))
+		snapshots := make([]any, 0)
+		for i := offset; i < min(offset+count, 1200); i++ {
+			snapshots = append(snapshots, map[string]any{"topology": i})
+		}
+		return http.StatusOK, map[string]any{"data": snapshots}
+	})
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c, err := New(srv.URL)
+	require.Nil(err)
+	c.WithRetries(0, time.Millisecond)
+	snapshots, errs := c.SubscribeSnapshots(ctx, 100, time.Millisecond, false)
+	for i := uint64(100); i < 1200; i++ {
+		s := <-snapshots
+		require.Equal(i, s.Topology)
+	}
+	require.ErrorContains(<-errs, "store closed")
+	cancel()
+	for range snapshots {
+	}
+	_, ok := <-errs
+	require.False(ok)
+}
+
+func TestSelectWalletInputs(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	asset := crypto.Blake3Hash([]byte("asset"))
+	srv := testServer(func(call *testCall) (int, map[string]any) {
+		require.Equal("listwalletoutputs", call.Method)
+		require.Equal([]any{"XIN", asset.String(), false}, call.Params)
+		outputs := make([]any, 0)
+		for i, a := range []string{"3", "1", "8", "2", "5"} {
+			outputs = append(outputs, map[string]any{
+				"hash":   crypto.Blake3Hash([]byte{byte(i)}),
+				"index":  i,
+				"asset":  asset,
+				"amount": common.NewIntegerFromString(a),
+			})
+		}
+		return http.StatusOK, map[string]any{"data": outputs}
+	})
+	defer srv.Close()
+	c, err := New(srv.URL)
+	require.Nil(err)
+	c.WithRetries(0, time.Millisecond)
+
+	_, err = c.SelectWalletInputs(ctx, "XIN", crypto.Hash{}, common.NewInteger(9), common.CoinSelectionBranchAndBound)
+	require.Equal(common.ErrorInvalidAsset, common.ErrorCodeOf(err))
+	s, err := c.SelectWalletInputs(ctx, "XIN", asset, common.NewInteger(9), common.CoinSelectionBranchAndBound)
+	require.Nil(err)
+	require.Len(s.Inputs, 2)
+	require.Equal(crypto.Blake3Hash([]byte{2}), s.Inputs[0].Hash)
+	require.Equal(uint(1), s.Inputs[1].Index)
+	require.Equal("0.00000000", s.Change.String())
+	s, err = c.SelectWalletInputs(ctx, "XIN", asset, common.NewInteger(9), common.CoinSelectionLargestFirst)
+	require.Nil(err)
+	require.Equal("4.00000000", s.Change.String())
+}
+
+func TestSubscribeViewSnapshots(t *testing.T) {
+	require := require.New(t)
+
+	account := common.NewAddressFromSeed(make([]byte, 64))
+	var polls atomic.Int32
+	srv := testServer(func(call *testCall) (int, map[string]any) {
+		require.Equal("listviewsnapshots", call.Method)
+		require.Equal(account.String(), call.Params[0])
+		require.Equal(account.PrivateViewKey.String(), call.Params[1])
+		require.Equal("", call.Params[2])
+		if polls.Add(1) == 2 {
+			return http.StatusOK, map[string]any{"error": "store closed"}
+		}
+		offset := uint64(call.Params[3].(float64))
+		count := uint64(call.Params[4].(float64))
+		next := min(offset+count, 1200)
+		snapshots := make([]any, 0)
+		for i := offset; i < next; i++ {
+			if i%3 != 0 {
+				continue
+			}
+			snapshots = append(snapshots, map[string]any{
+				"topology": i,
+				"outputs":  []any{map[string]any{"index": 1, "key_index": 0, "amount": "1.00000000"}},
+			})
+		}
+		return http.StatusOK, map[string]any{"data": map[string]any{"snapshots": snapshots, "next": next}}
+	})
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c, err := New(srv.URL)
+	require.Nil(err)
+	c.WithRetries(0, time.Millisecond)
+	snapshots, errs := c.SubscribeViewSnapshots(ctx, &account, crypto.Hash{}, 100, time.Millisecond)
+	for i := uint64(102); i < 1200; i += 3 {
+		s := <-snapshots
+		require.Equal(i, s.Topology)
+		require.Len(s.Outputs, 1)
+		require.Equal(uint(1), s.Outputs[0].Index)
+		require.Equal("1.00000000", s.Outputs[0].Amount.String())
+	}
+	require.ErrorContains(<-errs, "store closed")
+	cancel()
+	for range snapshots {
+	}
+	_, ok := <-errs
+	require.False(ok)
+}
+
+func TestGetAttestation(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	network := crypto.Blake3Hash([]byte("network"))
+	signer := crypto.NewKeyFromSeed(make([]byte, 64))
+	var stale atomic.Bool
+	srv := testServer(func(call *testCall) (int, map[string]any) {
+		require.Equal("getattestation", call.Method)
+		nonce, _ := hex.DecodeString(call.Params[0].(string))
+		if stale.Load() {
+			nonce = []byte("stale")
+		}
+		a := &common.NodeAttestation{
+			NetworkId: network,
+			NodeId:    common.NodeIdForSigner(network, signer.Public()),
+			Signer:    signer.Public(),
+			Round:     7,
+			Timestamp: 1663669260746463409,
+			Nonce:     nonce,
+		}
+		a.Signature = signer.Sign(a.PayloadHash())
+		return http.StatusOK, map[string]any{"data": map[string]any{
+			"network":   a.NetworkId,
+			"node":      a.NodeId,
+			"signer":    a.Signer,
+			"round":     a.Round,
+			"hash":      a.RoundHash,
+			"timestamp": a.Timestamp,
+			"nonce":     hex.EncodeToString(a.Nonce),
+			"signature": a.Signature,
+		}}
+	})
+	defer srv.Close()
+
+	c, err := New(srv.URL)
+	require.Nil(err)
+	c.WithRetries(0, time.Millisecond)
+	a, err := c.GetAttestation(ctx, []byte("fresh"))
+	require.Nil(err)
+	require.Equal(uint64(7), a.Round)
+	require.Equal(common.NodeIdForSigner(network, signer.Public()), a.NodeId)
+
+	stale.Store(true)
+	_, err = c.GetAttestation(ctx, []byte("fresh"))
+	require.ErrorContains(err, "invalid attestation nonce")
+}
@@ -0,0 +1,202 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+// the methods not bound here, e.g. of the custodian and the wallet indexes,
+// are called with Call and the result types of the caller.
+
+func (c *Client) GetInfo(ctx context.Context) (*Info, error) {
+	var info Info
+	err := c.Call(ctx, "getinfo", nil, &info)
+	if err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+func (c *Client) GetSyncStatus(ctx context.Context) (*SyncStatus, error) {
+	var status SyncStatus
+	err := c.Call(ctx, "getsyncstatus", nil, &status)
+	if err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// GetAttestation asks the node to sign a statement with the nonce, and verifies
+// the signature and the nonce, the caller should check the node id, and the
+// timestamp and the round against its own view of the network.
+func (c *Client) GetAttestation(ctx context.Context, nonce []byte) (*common.NodeAttestation, error) {
+	var res Attestation
+	err := c.Call(ctx, "getattestation", []any{hex.EncodeToString(nonce)}, &res)
+	if err != nil {
+		return nil, err
+	}
+	a := &common.NodeAttestation{
+		NetworkId: res.Network,
+		NodeId:    res.Node,
+		Signer:    res.Signer,
+		Round:     res.Round,
+		RoundHash: res.Hash,
+		Timestamp: res.Timestamp,
+		Signature: res.Signature,
+	}
+	a.Nonce, err = hex.DecodeString(res.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(a.Nonce, nonce) {
+		return nil, fmt.Errorf("invalid attestation nonce %s", res.Nonce)
+	}
+	err = a.Verify()
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// ListAllNodes lists the nodes ever existed before the threshold, or now if
+// the threshold is 0, and with only the latest state if state is true.
+func (c *Client) ListAllNodes(ctx context.Context, threshold uint64, state bool) ([]*Node, error) {
+	var nodes []*Node
+	err := c.Call(ctx, "listallnodes", []any{threshold, state}, &nodes)
+	return nodes, err
+}
+
+// SendRawTransaction queues the signed transaction in hex, and returns the
+// transaction hash, the transaction is not finalized yet, see WaitTransaction.
+func (c *Client) SendRawTransaction(ctx context.Context, raw string) (crypto.Hash, error) {
+	return c.sendRawTransaction(ctx, []any{raw})
+}
+
+// SendRawTransactionWithKey is SendRawTransaction with the idempotency key of
+// the caller, e.g. a withdrawal id, the node responds the original result to
+// the later submissions with the same key in a few minutes, and refuses the
+// key with another transaction.
+func (c *Client) SendRawTransactionWithKey(ctx context.Context, raw, key string) (crypto.Hash, error) {
+	return c.sendRawTransaction(ctx, []any{raw, key})
+}
+
+func (c *Client) sendRawTransaction(ctx context.Context, params []any) (crypto.Hash, error) {
+	var out struct {
+		Hash crypto.Hash `json:"hash"`
+	}
+	err := c.Call(ctx, "sendrawtransaction", params, &out)
+	return out.Hash, err
+}
+
+// GetTransaction returns nil without error if the transaction not found.
+func (c *Client) GetTransaction(ctx context.Context, hash crypto.Hash) (*Transaction, error) {
+	var tx *Transaction
+	err := c.Call(ctx, "gettransaction", []any{hash}, &tx)
+	return tx, err
+}
+
+// GetTransactions returns the transactions in the order of the hashes, and
+// the ones not found are nil.
+func (c *Client) GetTransactions(ctx context.Context, hashes []crypto.Hash) ([]*Transaction, error) {
+	params := make([]any, len(hashes))
+	for i, h := range hashes {
+		params[i] = h
+	}
+	var out []*struct {
+		Transaction
+		Found bool `json:"found"`
+	}
+	err := c.Call(ctx, "gettransactions", params, &out)
+	if err != nil {
+		return nil, err
+	}
+	txs := make([]*Transaction, len(out))
+	for i, t := range out {
+		if t.Found {
+			txs[i] = &t.Transaction
+		}
+	}
+	return txs, nil
+}
+
+// GetCacheTransaction returns the transaction in the cache of the node, which
+// is not finalized yet, or nil if not found.
+func (c *Client) GetCacheTransaction(ctx context.Context, hash crypto.Hash) (*Transaction, error) {
+	var tx *Transaction
+	err := c.Call(ctx, "getcachetransaction", []any{hash}, &tx)
+	return tx, err
+}
+
+// GetUTXO returns nil without error if the output not found.
+func (c *Client) GetUTXO(ctx context.Context, hash crypto.Hash, index uint) (*UTXO, error) {
+	var utxo *UTXO
+	err := c.Call(ctx, "getutxo", []any{hash, index}, &utxo)
+	return utxo, err
+}
+
+// GetKey returns the transaction which used the ghost key, or the zero hash
+// if the key is not used yet.
+func (c *Client) GetKey(ctx context.Context, key crypto.Key) (crypto.Hash, error) {
+	var out struct {
+		Transaction *crypto.Hash `json:"transaction"`
+	}
+	err := c.Call(ctx, "getkey", []any{key}, &out)
+	if err != nil || out.Transaction == nil {
+		return crypto.Hash{}, err
+	}
+	return *out.Transaction, nil
+}
+
+func (c *Client) GetAsset(ctx context.Context, id crypto.Hash) (*Asset, error) {
+	var asset *Asset
+	err := c.Call(ctx, "getasset", []any{id}, &asset)
+	return asset, err
+}
+
+// GetSnapshot returns the snapshot with the signature and the transaction, or
+// nil if not found.
+func (c *Client) GetSnapshot(ctx context.Context, hash crypto.Hash) (*Snapshot, error) {
+	var snap *Snapshot
+	err := c.Call(ctx, "getsnapshot", []any{hash}, &snap)
+	return snap, err
+}
+
+// ListSnapshots lists the finalized snapshots from the topology offset, with
+// the signatures if sig, and the full transactions instead of the hashes if tx.
+func (c *Client) ListSnapshots(ctx context.Context, offset, count uint64, sig, tx bool) ([]*Snapshot, error) {
+	var snapshots []*Snapshot
+	err := c.Call(ctx, "listsnapshots", []any{offset, count, sig, tx}, &snapshots)
+	return snapshots, err
+}
+
+func (c *Client) GetRoundByNumber(ctx context.Context, node crypto.Hash, number uint64) (*Round, error) {
+	var round Round
+	err := c.Call(ctx, "getroundbynumber", []any{node, number}, &round)
+	if err != nil {
+		return nil, err
+	}
+	return &round, nil
+}
+
+func (c *Client) GetRoundByHash(ctx context.Context, hash crypto.Hash) (*Round, error) {
+	var round Round
+	err := c.Call(ctx, "getroundbyhash", []any{hash}, &round)
+	if err != nil {
+		return nil, err
+	}
+	return &round, nil
+}
+
+// GetRoundLink returns the latest round of the to node referenced by the from node.
+func (c *Client) GetRoundLink(ctx context.Context, from, to crypto.Hash) (uint64, error) {
+	var out struct {
+		Link uint64 `json:"link"`
+	}
+	err := c.Call(ctx, "getroundlink", []any{from, to}, &out)
+	return out.Link, err
+}
@@ -0,0 +1,91 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+// the node has no push endpoint, so the subscriptions poll the node, and the
+// interval is the latency of them, a full batch is followed by the next poll
+// at once, so a subscription from an old offset catches up fast.
+const (
+	DefaultPollInterval = time.Second
+
+	subscribeBatch = 500
+)
+
+// SubscribeSnapshots sends the finalized snapshots from the topology offset to
+// the channel in order, with the full transactions if tx, until the context is
+// done, then both channels are closed. The errors after the retries of each
+// poll are sent to the errors channel if it's not full, and the poll goes on
+// from the same offset after the interval, so no snapshot is skipped.
+func (c *Client) SubscribeSnapshots(ctx context.Context, offset uint64, interval time.Duration, tx bool) (<-chan *Snapshot, <-chan error) {
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	snapshots := make(chan *Snapshot, subscribeBatch)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(snapshots)
+		defer close(errs)
+
+		for {
+			batch, err := c.ListSnapshots(ctx, offset, subscribeBatch, false, tx)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				select {
+				case errs <- err:
+				default:
+				}
+			}
+			for _, s := range batch {
+				if s.Topology < offset {
+					continue
+				}
+				select {
+				case snapshots <- s:
+				case <-ctx.Done():
+					return
+				}
+				offset = s.Topology + 1
+			}
+			if err == nil && len(batch) == subscribeBatch {
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+		}
+	}()
+	return snapshots, errs
+}
+
+// WaitTransaction polls the transaction until it's finalized, i.e. with the
+// snapshot, or the context is done. The transaction not found yet is not an
+// error, because it may be still in the cache of the other nodes.
+func (c *Client) WaitTransaction(ctx context.Context, hash crypto.Hash, interval time.Duration) (*Transaction, error) {
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	for {
+		tx, err := c.GetTransaction(ctx, hash)
+		if err != nil {
+			return nil, err
+		}
+		if tx != nil && tx.Snapshot != "" {
+			return tx, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
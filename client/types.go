@@ -0,0 +1,210 @@
+package client
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+// the types follow the response maps of rpc/internal/server, the fields not
+// listed are ignored, and Call with a map or json.RawMessage gets all of them.
+
+type RoundLink struct {
+	Self     crypto.Hash `json:"self"`
+	External crypto.Hash `json:"external"`
+}
+
+type Input struct {
+	Hash    crypto.Hash   `json:"hash"`
+	Index   uint          `json:"index"`
+	Genesis string        `json:"genesis"`
+	Deposit *DepositInput `json:"deposit"`
+	Mint    *MintInput    `json:"mint"`
+}
+
+type DepositInput struct {
+	Chain       crypto.Hash    `json:"chain"`
+	AssetKey    string         `json:"asset_key"`
+	Transaction string         `json:"transaction"`
+	Index       uint64         `json:"index"`
+	Amount      common.Integer `json:"amount"`
+}
+
+type MintInput struct {
+	Group  string         `json:"group"`
+	Batch  uint64         `json:"batch"`
+	Amount common.Integer `json:"amount"`
+}
+
+type Output struct {
+	Type       uint8          `json:"type"`
+	Amount     common.Integer `json:"amount"`
+	Keys       []*crypto.Key  `json:"keys"`
+	Script     common.Script  `json:"script"`
+	Mask       crypto.Key     `json:"mask"`
+	Withdrawal *Withdrawal    `json:"withdrawal"`
+}
+
+type Withdrawal struct {
+	Address string `json:"address"`
+	Tag     string `json:"tag"`
+}
+
+// Transaction is the decoded transaction, the Hex is the raw transaction, and
+// the Snapshot is empty if not finalized yet.
+type Transaction struct {
+	Version      uint8         `json:"version"`
+	Asset        crypto.Hash   `json:"asset"`
+	Inputs       []*Input      `json:"inputs"`
+	Outputs      []*Output     `json:"outputs"`
+	Extra        string        `json:"extra"`
+	Hash         crypto.Hash   `json:"hash"`
+	References   []crypto.Hash `json:"references"`
+	Hex          string        `json:"hex"`
+	Snapshot     string        `json:"snapshot"`
+	ExtraSchema  string        `json:"extra_schema"`
+	ExtraDecoded any           `json:"extra_decoded"`
+}
+
+// SnapshotTransaction is either the hash only, or the full transaction when
+// the snapshots are listed with the transactions.
+type SnapshotTransaction struct {
+	Hash        crypto.Hash
+	Transaction *Transaction
+}
+
+func (st *SnapshotTransaction) UnmarshalJSON(b []byte) error {
+	if len(b) > 0 && b[0] == '"' {
+		return st.Hash.UnmarshalJSON(b)
+	}
+	var tx Transaction
+	err := json.Unmarshal(b, &tx)
+	if err != nil {
+		return err
+	}
+	st.Hash, st.Transaction = tx.Hash, &tx
+	return nil
+}
+
+type Witness struct {
+	Signature *crypto.Signature `json:"signature"`
+	Timestamp uint64            `json:"timestamp"`
+}
+
+type Snapshot struct {
+	Version      uint8                  `json:"version"`
+	Node         crypto.Hash            `json:"node"`
+	References   *RoundLink             `json:"references"`
+	Round        uint64                 `json:"round"`
+	Timestamp    uint64                 `json:"timestamp"`
+	Hash         crypto.Hash            `json:"hash"`
+	Hex          string                 `json:"hex"`
+	Topology     uint64                 `json:"topology"`
+	Witness      *Witness               `json:"witness"`
+	Transactions []*SnapshotTransaction `json:"transactions"`
+	Signature    *crypto.CosiSignature  `json:"signature"`
+}
+
+type UTXO struct {
+	Type   uint8          `json:"type"`
+	Hash   crypto.Hash    `json:"hash"`
+	Index  uint           `json:"index"`
+	Asset  crypto.Hash    `json:"asset"`
+	Amount common.Integer `json:"amount"`
+	Keys   []*crypto.Key  `json:"keys"`
+	Script common.Script  `json:"script"`
+	Mask   crypto.Key     `json:"mask"`
+	Lock   crypto.Hash    `json:"lock"`
+}
+
+type Asset struct {
+	Id       crypto.Hash    `json:"id"`
+	Chain    crypto.Hash    `json:"chain"`
+	AssetKey string         `json:"asset_key"`
+	Balance  common.Integer `json:"balance"`
+}
+
+type Round struct {
+	Node       crypto.Hash `json:"node"`
+	Hash       crypto.Hash `json:"hash"`
+	Start      uint64      `json:"start"`
+	End        uint64      `json:"end"`
+	Number     uint64      `json:"number"`
+	References *RoundLink  `json:"references"`
+	Snapshots  []*Snapshot `json:"snapshots"`
+}
+
+type Node struct {
+	Id          crypto.Hash    `json:"id"`
+	Signer      common.Address `json:"signer"`
+	Payee       common.Address `json:"payee"`
+	Transaction crypto.Hash    `json:"transaction"`
+	Timestamp   uint64         `json:"timestamp"`
+	State       string         `json:"state"`
+}
+
+type ConsensusNode struct {
+	Node        crypto.Hash    `json:"node"`
+	Signer      common.Address `json:"signer"`
+	Payee       common.Address `json:"payee"`
+	State       string         `json:"state"`
+	Timestamp   uint64         `json:"timestamp"`
+	Transaction crypto.Hash    `json:"transaction"`
+	Aggregator  uint64         `json:"aggregator"`
+	Works       [2]uint64      `json:"works"`
+	Spaces      *[2]uint64     `json:"spaces"`
+}
+
+type Info struct {
+	Network   crypto.Hash `json:"network"`
+	Node      crypto.Hash `json:"node"`
+	Version   string      `json:"version"`
+	Uptime    string      `json:"uptime"`
+	Relayer   bool        `json:"relayer"`
+	Epoch     time.Time   `json:"epoch"`
+	Timestamp time.Time   `json:"timestamp"`
+	Mint      struct {
+		Pool   common.Integer `json:"pool"`
+		Batch  uint64         `json:"batch"`
+		Pledge common.Integer `json:"pledge"`
+	} `json:"mint"`
+	Graph struct {
+		Consensus []*ConsensusNode `json:"consensus"`
+		Topology  uint64           `json:"topology"`
+		SPS       float64          `json:"sps"`
+		TPS       float64          `json:"tps"`
+	} `json:"graph"`
+}
+
+type ChainSyncStatus struct {
+	Chain  crypto.Hash `json:"chain"`
+	Local  uint64      `json:"local"`
+	Remote uint64      `json:"remote"`
+	Lag    int64       `json:"lag"`
+}
+
+type SyncStatus struct {
+	Timestamp  uint64             `json:"timestamp"`
+	Synced     bool               `json:"synced"`
+	Stalled    bool               `json:"stalled"`
+	Progress   float64            `json:"progress"`
+	Local      uint64             `json:"local"`
+	Remote     uint64             `json:"remote"`
+	Rate       float64            `json:"rate"`
+	ETA        string             `json:"eta"`
+	ProgressAt uint64             `json:"progress_at"`
+	Chains     []*ChainSyncStatus `json:"chains"`
+}
+
+type Attestation struct {
+	Network   crypto.Hash      `json:"network"`
+	Node      crypto.Hash      `json:"node"`
+	Signer    crypto.Key       `json:"signer"`
+	Round     uint64           `json:"round"`
+	Hash      crypto.Hash      `json:"hash"`
+	Timestamp uint64           `json:"timestamp"`
+	Nonce     string           `json:"nonce"`
+	Signature crypto.Signature `json:"signature"`
+}
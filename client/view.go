@@ -0,0 +1,96 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+// ViewOutput is an output to the view key address, the index is the output
+// index in the transaction, and the key index is the index of the key of the
+// address in the output keys.
+type ViewOutput struct {
+	Index    uint           `json:"index"`
+	KeyIndex int            `json:"key_index"`
+	Amount   common.Integer `json:"amount"`
+}
+
+// ViewSnapshot is a snapshot with the full transaction, which has some outputs
+// to the view key address.
+type ViewSnapshot struct {
+	Snapshot
+	Outputs []*ViewOutput `json:"outputs"`
+}
+
+// ListViewSnapshots scans at most count snapshots from the topology offset on
+// the node with the private view key of the account, and returns only the ones
+// with the outputs to the account, of the asset if not zero. The next is the
+// offset of the next call, even when no snapshot is returned. It's an admin
+// method, so the view key is only sent to a node of the account owner.
+func (c *Client) ListViewSnapshots(ctx context.Context, account *common.Address, asset crypto.Hash, offset, count uint64, sig bool) ([]*ViewSnapshot, uint64, error) {
+	var a string
+	if asset.HasValue() {
+		a = asset.String()
+	}
+	var result struct {
+		Snapshots []*ViewSnapshot `json:"snapshots"`
+		Next      uint64          `json:"next"`
+	}
+	params := []any{account.String(), account.PrivateViewKey.String(), a, offset, count, sig}
+	err := c.Call(ctx, "listviewsnapshots", params, &result)
+	return result.Snapshots, result.Next, err
+}
+
+// SubscribeViewSnapshots is SubscribeSnapshots filtered on the node by the
+// view key of the account, so a thin wallet only receives its own snapshots,
+// with the outputs already identified.
+func (c *Client) SubscribeViewSnapshots(ctx context.Context, account *common.Address, asset crypto.Hash, offset uint64, interval time.Duration) (<-chan *ViewSnapshot, <-chan error) {
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	snapshots := make(chan *ViewSnapshot, subscribeBatch)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(snapshots)
+		defer close(errs)
+
+		for {
+			batch, next, err := c.ListViewSnapshots(ctx, account, asset, offset, subscribeBatch, false)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				select {
+				case errs <- err:
+				default:
+				}
+			}
+			for _, s := range batch {
+				if s.Topology < offset {
+					continue
+				}
+				select {
+				case snapshots <- s:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if err == nil && next > offset {
+				full := next-offset >= subscribeBatch
+				offset = next
+				if full {
+					continue
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+		}
+	}()
+	return snapshots, errs
+}
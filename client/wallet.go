@@ -0,0 +1,52 @@
+package client
+
+import (
+	"context"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+// WalletOutput is an output of an account registered to the wallet scanner
+// of the node, with the registerwalletaccount method.
+type WalletOutput struct {
+	Hash      crypto.Hash    `json:"hash"`
+	Index     uint           `json:"index"`
+	KeyIndex  int            `json:"key_index"`
+	Asset     crypto.Hash    `json:"asset"`
+	Amount    common.Integer `json:"amount"`
+	Snapshot  crypto.Hash    `json:"snapshot"`
+	Timestamp uint64         `json:"timestamp"`
+	SpentBy   crypto.Hash    `json:"spent_by"`
+	PaymentId string         `json:"payment_id"`
+}
+
+// ListWalletOutputs lists the outputs of the account, of all assets if the
+// asset is zero, and with the spent ones if spent.
+func (c *Client) ListWalletOutputs(ctx context.Context, account string, asset crypto.Hash, spent bool) ([]*WalletOutput, error) {
+	var a string
+	if asset.HasValue() {
+		a = asset.String()
+	}
+	var outputs []*WalletOutput
+	err := c.Call(ctx, "listwalletoutputs", []any{account, a, spent}, &outputs)
+	return outputs, err
+}
+
+// SelectWalletInputs selects the unspent outputs of the account to pay the
+// amount with the common coin selection strategy, and the inputs could be
+// passed to common.NewBatchTransferWithSelection, or spent directly.
+func (c *Client) SelectWalletInputs(ctx context.Context, account string, asset crypto.Hash, amount common.Integer, strategy string) (*common.CoinSelection, error) {
+	if !asset.HasValue() {
+		return nil, common.Errorf(common.ErrorInvalidAsset, "invalid coin selection asset %s", asset)
+	}
+	outputs, err := c.ListWalletOutputs(ctx, account, asset, false)
+	if err != nil {
+		return nil, err
+	}
+	inputs := make([]*common.TransferInput, len(outputs))
+	for i, o := range outputs {
+		inputs[i] = &common.TransferInput{Hash: o.Hash, Index: o.Index, Amount: o.Amount}
+	}
+	return common.SelectInputs(inputs, amount, strategy)
+}
@@ -1,29 +1,58 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"encoding/base64"
+	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"os"
+	"os/user"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/MixinNetwork/mixin/common"
 	"github.com/MixinNetwork/mixin/config"
 	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/kernel"
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/mixin/p2p"
+	"github.com/MixinNetwork/mixin/remotesigner"
 	"github.com/MixinNetwork/mixin/rpc"
 	"github.com/MixinNetwork/mixin/storage"
 	"github.com/urfave/cli/v2"
 )
 
 func createAddressCmd(c *cli.Context) error {
+	useMnemonic := c.Bool("mnemonic")
+	if useMnemonic && (c.String("view") != "" || c.String("spend") != "") {
+		return errors.New("the mnemonic can not be used with the view or spend key")
+	}
 	for {
 		seed := make([]byte, 64)
 		crypto.ReadRand(seed)
 		addr := common.NewAddressFromSeed(seed)
+		var mnemonic string
+		if useMnemonic {
+			words, err := common.NewMnemonic()
+			if err != nil {
+				return err
+			}
+			addr, err = common.NewAddressFromMnemonic(words, "", c.String("path"))
+			if err != nil {
+				return err
+			}
+			mnemonic = words
+		}
 		if view := c.String("view"); len(view) > 0 {
 			key, err := hex.DecodeString(view)
 			if err != nil {
@@ -51,11 +80,39 @@ func createAddressCmd(c *cli.Context) error {
 			fmt.Printf("address:\t%s\n", addr.String())
 			fmt.Printf("view key:\t%s\n", addr.PrivateViewKey.String())
 			fmt.Printf("spend key:\t%s\n", addr.PrivateSpendKey.String())
+			if mnemonic != "" {
+				fmt.Printf("mnemonic:\t%s\n", mnemonic)
+				fmt.Printf("path:\t\t%s\n", c.String("path"))
+			}
 			return nil
 		}
 	}
 }
 
+func recoverAddressCmd(c *cli.Context) error {
+	mnemonic := c.String("mnemonic")
+	if mnemonic == "" {
+		fmt.Print("mnemonic: ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil {
+			return err
+		}
+		mnemonic = line
+	}
+	addr, err := common.NewAddressFromMnemonic(mnemonic, "", c.String("path"))
+	if err != nil {
+		return err
+	}
+	if c.Bool("public") {
+		addr.PrivateViewKey = addr.PublicSpendKey.DeterministicHashDerive()
+		addr.PublicViewKey = addr.PrivateViewKey.Public()
+	}
+	fmt.Printf("address:\t%s\n", addr.String())
+	fmt.Printf("view key:\t%s\n", addr.PrivateViewKey.String())
+	fmt.Printf("spend key:\t%s\n", addr.PrivateSpendKey.String())
+	return nil
+}
+
 func decodeAddressCmd(c *cli.Context) error {
 	addr, err := common.NewAddressFromString(c.String("address"))
 	if err != nil {
@@ -68,6 +125,131 @@ func decodeAddressCmd(c *cli.Context) error {
 	return nil
 }
 
+func deriveSequenceAddressCmd(c *cli.Context) error {
+	master, err := common.NewAddressFromString(c.String("address"))
+	if err != nil {
+		return err
+	}
+	master.PrivateViewKey, err = crypto.KeyFromString(c.String("view"))
+	if err != nil {
+		return err
+	}
+	if master.PrivateViewKey.Public() != master.PublicViewKey {
+		return fmt.Errorf("invalid view key for address %s", master)
+	}
+	if spend := c.String("spend"); len(spend) > 0 {
+		master.PrivateSpendKey, err = crypto.KeyFromString(spend)
+		if err != nil {
+			return err
+		}
+		if master.PrivateSpendKey.Public() != master.PublicSpendKey {
+			return fmt.Errorf("invalid spend key for address %s", master)
+		}
+	}
+	for i := c.Uint64("start"); i < c.Uint64("start")+c.Uint64("count"); i++ {
+		addr := master.DeriveSequenceAddress(i)
+		if addr.PrivateSpendKey.HasValue() {
+			fmt.Printf("%d\t%s\t%s\n", i, addr.String(), addr.PrivateSpendKey.String())
+		} else {
+			fmt.Printf("%d\t%s\n", i, addr.String())
+		}
+	}
+	return nil
+}
+
+func deriveSubaddressCmd(c *cli.Context) error {
+	master, err := common.NewAddressFromString(c.String("address"))
+	if err != nil {
+		return err
+	}
+	master.PrivateViewKey, err = crypto.KeyFromString(c.String("view"))
+	if err != nil {
+		return err
+	}
+	if master.PrivateViewKey.Public() != master.PublicViewKey {
+		return fmt.Errorf("invalid view key for address %s", master)
+	}
+	if spend := c.String("spend"); len(spend) > 0 {
+		master.PrivateSpendKey, err = crypto.KeyFromString(spend)
+		if err != nil {
+			return err
+		}
+		if master.PrivateSpendKey.Public() != master.PublicSpendKey {
+			return fmt.Errorf("invalid spend key for address %s", master)
+		}
+	}
+	for _, id := range c.StringSlice("payment") {
+		addr, err := master.DeriveSubaddress([]byte(id))
+		if err != nil {
+			return err
+		}
+		if addr.PrivateSpendKey.HasValue() {
+			fmt.Printf("%s\t%s\t%s\n", id, addr.String(), addr.PrivateSpendKey.String())
+		} else {
+			fmt.Printf("%s\t%s\n", id, addr.String())
+		}
+	}
+	return nil
+}
+
+func readMessage(c *cli.Context) ([]byte, error) {
+	if c.Bool("hex") {
+		return hex.DecodeString(c.String("message"))
+	}
+	return []byte(c.String("message")), nil
+}
+
+func signMessageCmd(c *cli.Context) error {
+	view, err := crypto.KeyFromString(c.String("view"))
+	if err != nil {
+		return err
+	}
+	spend, err := crypto.KeyFromString(c.String("spend"))
+	if err != nil {
+		return err
+	}
+	if !view.CheckScalar() || !spend.CheckScalar() {
+		return fmt.Errorf("invalid address private keys")
+	}
+	message, err := readMessage(c)
+	if err != nil {
+		return err
+	}
+	addr := common.Address{
+		PublicViewKey:  view.Public(),
+		PublicSpendKey: spend.Public(),
+	}
+	sig := crypto.SignMessage(spend, addr.PublicViewKey, message)
+	fmt.Printf("address:\t%s\n", addr.String())
+	fmt.Printf("signature:\t%s\n", sig.String())
+	return nil
+}
+
+func verifyMessageCmd(c *cli.Context) error {
+	addr, err := common.NewAddressFromString(c.String("address"))
+	if err != nil {
+		return err
+	}
+	message, err := readMessage(c)
+	if err != nil {
+		return err
+	}
+	b, err := hex.DecodeString(c.String("signature"))
+	if err != nil {
+		return err
+	}
+	var sig crypto.Signature
+	if len(b) != len(sig) {
+		return fmt.Errorf("invalid signature size %d", len(b))
+	}
+	copy(sig[:], b)
+	if !crypto.VerifyMessage(addr.PublicSpendKey, addr.PublicViewKey, message, sig) {
+		return fmt.Errorf("invalid message signature of %s", addr.String())
+	}
+	fmt.Println("valid")
+	return nil
+}
+
 func decodeSignatureCmd(c *cli.Context) error {
 	var s struct{ S crypto.CosiSignature }
 	in := fmt.Sprintf(`{"S":"%s"}`, c.String("signature"))
@@ -133,6 +315,10 @@ func updateHeadReference(c *cli.Context) error {
 		return err
 	}
 	copy(round.References.External[:], external)
+	err = writeMaintenanceAudit(store, fmt.Sprintf("updateheadreference %s %d %s", round.NodeId, round.Number, round.References.External))
+	if err != nil {
+		return err
+	}
 	return store.UpdateEmptyHeadRound(round.NodeId, round.Number, round.References)
 }
 
@@ -146,6 +332,10 @@ func removeGraphEntries(c *cli.Context) error {
 		return err
 	}
 	defer store.Close()
+	err = writeMaintenanceAudit(store, fmt.Sprintf("removegraphentries %s", c.String("prefix")))
+	if err != nil {
+		return err
+	}
 	removed, err := store.RemoveGraphEntries(c.String("prefix"))
 	fmt.Printf("removed %d entries with %v\n", removed, err)
 	return err
@@ -177,6 +367,10 @@ func validateGraphEntries(c *cli.Context) error {
 		return err
 	}
 	defer store.Close()
+	err = writeMaintenanceAudit(store, fmt.Sprintf("validategraphentries %d", c.Uint64("depth")))
+	if err != nil {
+		return err
+	}
 
 	total, invalid, err := store.ValidateGraphEntries(networkId, c.Uint64("depth"))
 	if err != nil {
@@ -186,480 +380,2675 @@ func validateGraphEntries(c *cli.Context) error {
 	return nil
 }
 
-func decodeTransactionCmd(c *cli.Context) error {
-	raw, err := hex.DecodeString(c.String("raw"))
+func replayGraphChainCmd(c *cli.Context) error {
+	custom, err := config.Initialize(c.String("dir") + "/config.toml")
 	if err != nil {
 		return err
 	}
-	ver, err := common.UnmarshalVersionedTransaction(raw)
+	node, err := crypto.HashFromString(c.String("id"))
 	if err != nil {
 		return err
 	}
-	m := transactionToMap(ver)
-	data, err := json.MarshalIndent(m, "", "  ")
+	store, err := storage.NewBadgerStore(custom, c.String("dir"))
 	if err != nil {
 		return err
 	}
-	fmt.Println(string(data))
-	return nil
+	defer store.Close()
+	if c.Bool("repair") {
+		err = writeMaintenanceAudit(store, fmt.Sprintf("replaygraphchain %s repair", node))
+		if err != nil {
+			return err
+		}
+	}
+
+	replay, err := store.ReplayGraphChain(node, c.Bool("repair"))
+	if replay != nil {
+		fmt.Printf("node: %s rounds: %d snapshots: %d invalid: %d repaired: %d\n", replay.NodeId, replay.Rounds, replay.Snapshots, replay.Invalid, replay.Repaired)
+	}
+	return err
 }
 
-func buildRawTransactionCmd(c *cli.Context) error {
-	seed, err := hex.DecodeString(c.String("seed"))
+func exportConsensusEvidenceCmd(c *cli.Context) error {
+	custom, err := config.Initialize(c.String("dir") + "/config.toml")
 	if err != nil {
 		return err
 	}
-	if len(seed) != 64 {
-		seed = make([]byte, 64)
-		crypto.ReadRand(seed)
-	}
-
-	viewKey, err := crypto.KeyFromString(c.String("view"))
+	salt, err := hex.DecodeString(c.String("salt"))
 	if err != nil {
 		return err
 	}
-	spendKey, err := crypto.KeyFromString(c.String("spend"))
+	if len(salt) == 0 {
+		salt = make([]byte, 32)
+		crypto.ReadRand(salt)
+	}
+	out := c.String("out")
+	err = os.MkdirAll(out, 0700)
 	if err != nil {
 		return err
 	}
-	account := common.Address{
-		PrivateViewKey:  viewKey,
-		PrivateSpendKey: spendKey,
-		PublicViewKey:   viewKey.Public(),
-		PublicSpendKey:  spendKey.Public(),
+	var files []*os.File
+	for _, name := range []string{"rounds.csv", "snapshots.csv", "references.csv"} {
+		f, err := os.Create(filepath.Join(out, name))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		files = append(files, f)
 	}
 
-	asset, err := crypto.HashFromString(c.String("asset"))
+	store, err := storage.NewBadgerStore(custom, c.String("dir"))
 	if err != nil {
 		return err
 	}
+	defer store.Close()
+	next, err := kernel.ExportConsensusEvidence(store, salt, c.Uint64("offset"), c.Uint64("count"), files[0], files[1], files[2])
+	fmt.Printf("exported snapshots %d-%d with %v\n", c.Uint64("offset"), next, err)
+	return err
+}
 
-	extra, err := hex.DecodeString(c.String("extra"))
+func exportTransactionsCmd(c *cli.Context) error {
+	custom, err := config.Initialize(c.String("dir") + "/config.toml")
 	if err != nil {
 		return err
 	}
-
-	inputs := make([]map[string]any, 0)
-	for _, in := range strings.Split(c.String("inputs"), ",") {
-		parts := strings.Split(in, ":")
-		if len(parts) != 2 {
-			return fmt.Errorf("invalid input %s", in)
-		}
-		hash, err := crypto.HashFromString(parts[0])
-		if err != nil {
-			return err
-		}
-		index, err := strconv.ParseInt(parts[1], 10, 64)
+	out := c.String("out")
+	err = os.MkdirAll(out, 0700)
+	if err != nil {
+		return err
+	}
+	var files []*os.File
+	for _, name := range []string{"transactions", "outputs"} {
+		f, err := os.Create(filepath.Join(out, name+".csv"))
 		if err != nil {
 			return err
 		}
-		inputs = append(inputs, map[string]any{
-			"hash":  hash,
-			"index": index,
-		})
+		defer f.Close()
+		files = append(files, f)
 	}
 
-	outputs := make([]map[string]any, 0)
-	for _, out := range strings.Split(c.String("outputs"), ",") {
-		parts := strings.Split(out, ":")
-		if len(parts) != 2 {
-			return fmt.Errorf("invalid output %s", out)
-		}
-		addr, err := common.NewAddressFromString(parts[0])
-		if err != nil {
-			return err
-		}
-		amount := common.NewIntegerFromString(parts[1])
-		if amount.Sign() == 0 {
-			return fmt.Errorf("invalid output %s", out)
-		}
-		outputs = append(outputs, map[string]any{
-			"accounts": []*common.Address{&addr},
-			"amount":   amount,
-		})
+	store, err := storage.NewBadgerStore(custom, c.String("dir"))
+	if err != nil {
+		return err
 	}
+	defer store.Close()
+	offset := c.Uint64("since-topology")
+	next, err := kernel.ExportTransactions(store, offset, c.Uint64("count"), files[0], files[1])
+	fmt.Printf("exported snapshots %d-%d with %v\n", offset, next, err)
+	return err
+}
 
-	var raw signerInput
-	raw.Node = c.String("node")
-	isb, _ := json.Marshal(map[string]any{"inputs": inputs})
-	_ = json.Unmarshal(isb, &raw)
+func exportRoundGraphCmd(c *cli.Context) error {
+	if f := c.String("format"); f != "dot" {
+		return fmt.Errorf("invalid graph format %s", f)
+	}
+	nodeId, err := crypto.HashFromString(c.String("node"))
+	if err != nil {
+		return err
+	}
+	parts := strings.Split(c.String("rounds"), "..")
+	if len(parts) != 2 {
+		return fmt.Errorf("invalid rounds range %s", c.String("rounds"))
+	}
+	from, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return err
+	}
+	to, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return err
+	}
 
-	tx := common.NewTransactionV5(asset)
-	for _, in := range inputs {
-		tx.AddInput(in["hash"].(crypto.Hash), uint(in["index"].(int64)))
+	custom, err := config.Initialize(c.String("dir") + "/config.toml")
+	if err != nil {
+		return err
 	}
-	for _, out := range outputs {
-		tx.AddScriptOutput(out["accounts"].([]*common.Address), common.NewThresholdScript(1), out["amount"].(common.Integer), seed)
+	store, err := storage.NewBadgerStore(custom, c.String("dir"))
+	if err != nil {
+		return err
 	}
-	tx.Extra = extra
+	defer store.Close()
+	return kernel.ExportRoundGraph(store, nodeId, from, to, os.Stdout)
+}
 
-	signed := tx.AsVersioned()
-	for i := range tx.Inputs {
-		err = signed.SignInput(raw, i, []*common.Address{&account})
-		if err != nil {
-			return err
+func openDumpStore(c *cli.Context) (*storage.BadgerStore, error) {
+	custom, err := config.Initialize(c.String("dir") + "/config.toml")
+	if err != nil {
+		return nil, err
+	}
+	return storage.NewBadgerStore(custom, c.String("dir"))
+}
+
+func dumpStorePrefixesCmd(c *cli.Context) error {
+	store, err := openDumpStore(c)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	stats, err := store.ListKeyPrefixes()
+	if err != nil {
+		return err
+	}
+	for _, s := range stats {
+		prefix := s.Prefix
+		if prefix == "" {
+			prefix = "(unknown)"
 		}
+		fmt.Printf("%s\t%-24s\tkeys: %d\tsize: %d\n", s.DB, prefix, s.Keys, s.Size)
 	}
-	fmt.Println(hex.EncodeToString(signed.Marshal()))
 	return nil
 }
 
-func signTransactionCmd(c *cli.Context) error {
-	var raw signerInput
-	err := json.Unmarshal([]byte(c.String("raw")), &raw)
+func dumpStoreSnapshotCmd(c *cli.Context) error {
+	hash, err := crypto.HashFromString(c.String("hash"))
 	if err != nil {
 		return err
 	}
-	if raw.Version != common.TxVersionHashSignature {
-		return fmt.Errorf("invalid version number %d", raw.Version)
+	store, err := openDumpStore(c)
+	if err != nil {
+		return err
 	}
-	raw.Node = c.String("node")
-
-	seed, err := hex.DecodeString(c.String("seed"))
+	defer store.Close()
+	s, err := store.ReadSnapshot(hash)
 	if err != nil {
 		return err
 	}
-	if len(seed) != 64 {
-		seed = make([]byte, 64)
-		crypto.ReadRand(seed)
+	if s == nil {
+		return fmt.Errorf("snapshot %s not found", hash)
 	}
+	return printDumpJSON(annotatedSnapshotToMap(s))
+}
 
-	tx := common.NewTransactionV5(raw.Asset)
-	for _, in := range raw.Inputs {
-		if d := in.Deposit; d != nil {
-			tx.AddDepositInput(&common.DepositData{
-				Chain:       d.Chain,
-				AssetKey:    d.AssetKey,
-				Transaction: d.TransactionHash,
-				Index:       d.OutputIndex,
-				Amount:      d.Amount,
-			})
-		} else {
-			tx.AddInput(in.Hash, in.Index)
-		}
+func dumpStoreTransactionCmd(c *cli.Context) error {
+	hash, err := crypto.HashFromString(c.String("hash"))
+	if err != nil {
+		return err
 	}
-
-	for _, out := range raw.Outputs {
-		if out.Mask.HasValue() {
-			tx.Outputs = append(tx.Outputs, &common.Output{
-				Type:   out.Type,
-				Amount: out.Amount,
-				Keys:   out.Keys,
-				Script: out.Script,
-				Mask:   out.Mask,
-			})
-		} else {
-			hash := crypto.Blake3Hash(seed)
-			seed = append(hash[:], hash[:]...)
-			tx.AddOutputWithType(out.Type, out.Accounts, out.Script, out.Amount, seed)
-		}
+	store, err := openDumpStore(c)
+	if err != nil {
+		return err
 	}
-
-	extra, err := hex.DecodeString(raw.Extra)
+	defer store.Close()
+	tx, snap, err := store.ReadTransaction(hash)
 	if err != nil {
 		return err
 	}
-	tx.Extra = extra
-
-	keys := c.StringSlice("key")
-	var accounts []*common.Address
-	for _, s := range keys {
-		key, err := hex.DecodeString(s)
+	if tx == nil {
+		tx, err = store.CacheGetTransaction(hash)
 		if err != nil {
 			return err
 		}
-		if len(key) != 64 {
-			return fmt.Errorf("invalid key length %d", len(key))
-		}
-		var account common.Address
-		copy(account.PrivateViewKey[:], key[:32])
-		copy(account.PrivateSpendKey[:], key[32:])
-		accounts = append(accounts, &account)
 	}
+	if tx == nil {
+		return fmt.Errorf("transaction %s not found", hash)
+	}
+	m := annotatedTransactionToMap(tx)
+	m["snapshot"] = snap
+	return printDumpJSON(m)
+}
 
-	signed := tx.AsVersioned()
-	for i := range signed.Inputs {
-		err := signed.SignInput(raw, i, accounts)
+func dumpStoreHeadsCmd(c *cli.Context) error {
+	gns, err := common.ReadGenesis(c.String("dir") + "/genesis.json")
+	if err != nil {
+		return err
+	}
+	store, err := openDumpStore(c)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	var heads []map[string]any
+	for _, n := range store.ReadAllNodes(uint64(time.Now().UnixNano())*2, false) {
+		id := n.IdForNetwork(gns.NetworkId())
+		head := map[string]any{
+			"node":  id,
+			"state": n.State,
+		}
+		round, err := store.ReadRound(id)
 		if err != nil {
 			return err
 		}
+		if round != nil {
+			head["round"] = round.Number
+			head["timestamp"] = round.Timestamp
+			if r := round.References; r != nil {
+				head["references"] = map[string]any{
+					"self":     r.Self,
+					"external": r.External,
+				}
+			}
+		}
+		heads = append(heads, head)
 	}
-	fmt.Println(hex.EncodeToString(signed.Marshal()))
-	return nil
-}
-
-func sendTransactionCmd(c *cli.Context) error {
-	data, err := callRPC(c.String("node"), "sendrawtransaction", []any{
-		c.String("raw"),
-	}, c.Bool("time"))
-	if err == nil {
-		fmt.Println(string(data))
-	}
-	return err
+	return printDumpJSON(heads)
 }
 
-func custodianDepositCmd(c *cli.Context) error {
-	receiver, err := common.NewAddressFromString(c.String("receiver"))
+func dumpStoreVerifyCmd(c *cli.Context) error {
+	nodeId, err := crypto.HashFromString(c.String("node"))
 	if err != nil {
-		return fmt.Errorf("invalid receiver %s", c.String("receiver"))
-	}
-	kph := c.String("custodian")
-	if len(kph) != 128 {
-		return fmt.Errorf("invalid custodian %s", kph)
+		return err
 	}
-	view, err := crypto.KeyFromString(kph[:64])
-	if err != nil {
-		return fmt.Errorf("invalid custodian %s", kph)
+	parts := strings.Split(c.String("rounds"), "..")
+	if len(parts) != 2 {
+		return fmt.Errorf("invalid rounds range %s", c.String("rounds"))
 	}
-	spend, err := crypto.KeyFromString(kph[64:])
+	from, err := strconv.ParseUint(parts[0], 10, 64)
 	if err != nil {
-		return fmt.Errorf("invalid custodian %s", kph)
-	}
-	custodian := &common.Address{
-		PrivateViewKey:  view,
-		PrivateSpendKey: spend,
-		PublicViewKey:   view.Public(),
-		PublicSpendKey:  spend.Public(),
+		return err
 	}
-
-	asset, err := crypto.HashFromString(c.String("asset"))
+	to, err := strconv.ParseUint(parts[1], 10, 64)
 	if err != nil {
-		return fmt.Errorf("invalid asset %s", c.String("asset"))
+		return err
 	}
-	chain, err := crypto.HashFromString(c.String("chain"))
+	store, err := openDumpStore(c)
 	if err != nil {
-		return fmt.Errorf("invalid chain %s", c.String("chain"))
-	}
-	amount := common.NewIntegerFromString(c.String("amount"))
-	seed := make([]byte, 64)
-	crypto.ReadRand(seed)
-	script := common.NewThresholdScript(1)
-	deposit := &common.DepositData{
-		Chain:       chain,
-		AssetKey:    c.String("asset_key"),
-		Transaction: c.String("transaction"),
-		Index:       c.Uint64("index"),
-		Amount:      amount,
+		return err
 	}
-	tx := common.NewTransactionV5(asset)
-	tx.AddDepositInput(deposit)
-	tx.AddScriptOutput([]*common.Address{&receiver}, script, amount, seed)
-	ver := tx.AsVersioned()
-	err = ver.SignInput(nil, 0, []*common.Address{custodian})
-	if err == nil {
-		fmt.Printf("%x\n", ver.Marshal())
+	defer store.Close()
+	replay, err := store.VerifyGraphChainRange(nodeId, from, to)
+	if replay != nil {
+		fmt.Printf("node: %s rounds: %d snapshots: %d invalid: %d\n", replay.NodeId, replay.Rounds, replay.Snapshots, replay.Invalid)
 	}
 	return err
 }
 
-func pledgeNodeCmd(c *cli.Context) error {
-	seed := make([]byte, 64)
-	crypto.ReadRand(seed)
-	viewKey, err := crypto.KeyFromString(c.String("view"))
+func printDumpJSON(v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
 	if err != nil {
 		return err
 	}
-	spendKey, err := crypto.KeyFromString(c.String("spend"))
-	if err != nil {
-		return err
+	fmt.Println(string(data))
+	return nil
+}
+
+func diffGraphCmd(c *cli.Context) error {
+	if c.NArg() != 2 {
+		return errors.New("usage: diffgraph dirA dirB")
 	}
-	account := common.Address{
-		PrivateViewKey:  viewKey,
-		PrivateSpendKey: spendKey,
-		PublicViewKey:   viewKey.Public(),
-		PublicSpendKey:  spendKey.Public(),
+	var stores []*storage.BadgerStore
+	for _, dir := range c.Args().Slice() {
+		custom, err := config.Initialize(dir + "/config.toml")
+		if err != nil {
+			return err
+		}
+		store, err := storage.NewBadgerStore(custom, dir)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+		stores = append(stores, store)
 	}
 
-	signer, err := common.NewAddressFromString(c.String("signer"))
+	gd, identical, err := kernel.DiffGraph(stores[0], stores[1], c.Uint64("offset"))
 	if err != nil {
 		return err
 	}
-	payee, err := common.NewAddressFromString(c.String("payee"))
-	if err != nil {
-		return err
+	fmt.Printf("identical snapshots: %d\n", identical)
+	if gd == nil {
+		fmt.Println("no divergence")
+		return nil
+	}
+	fmt.Printf("first divergence at topology %d\n", gd.Topology)
+	for i, s := range []*common.SnapshotWithTopologicalOrder{gd.A, gd.B} {
+		dir := c.Args().Get(i)
+		if s == nil {
+			fmt.Printf("%s:\tend of graph\n", dir)
+			continue
+		}
+		fmt.Printf("%s:\ttopology %d node %s round %d hash %s\n",
+			dir, s.TopologicalOrder, s.NodeId, s.RoundNumber, s.PayloadHash())
+	}
+	if gd.Reordered {
+		fmt.Println("the snapshot is finalized at another topology, only a different finalization order")
 	}
+	return nil
+}
 
-	var raw signerInput
-	input, err := crypto.HashFromString(c.String("input"))
+func writeMaintenanceAudit(store storage.Store, detail string) error {
+	actor := "unknown"
+	if u, err := user.Current(); err == nil {
+		actor = u.Username
+	}
+	return store.WriteAuditEntry(&common.AuditEntry{
+		Timestamp: uint64(time.Now().UnixNano()),
+		Actor:     actor,
+		Action:    common.AuditActionMaintenance,
+		Detail:    detail,
+	})
+}
+
+func rebuildTimestampIndex(c *cli.Context) error {
+	custom, err := config.Initialize(c.String("dir") + "/config.toml")
 	if err != nil {
 		return err
 	}
-	err = json.Unmarshal([]byte(fmt.Sprintf(`{"inputs":[{"hash":"%s","index":0}]}`, input.String())), &raw)
+	store, err := storage.NewBadgerStore(custom, c.String("dir"))
 	if err != nil {
 		return err
 	}
-	raw.Node = c.String("node")
-
-	amount := common.NewIntegerFromString(c.String("amount"))
-
-	tx := common.NewTransactionV5(common.XINAssetId)
-	tx.AddInput(input, 0)
-	tx.AddOutputWithType(common.OutputTypeNodePledge, nil, common.Script{}, amount, seed)
-	tx.Extra = append(signer.PublicSpendKey[:], payee.PublicSpendKey[:]...)
-
-	signed := tx.AsVersioned()
-	err = signed.SignInput(raw, 0, []*common.Address{&account})
+	defer store.Close()
+	err = writeMaintenanceAudit(store, "rebuildtimestampindex")
 	if err != nil {
 		return err
 	}
-	fmt.Println(hex.EncodeToString(signed.Marshal()))
-	return nil
+	for offset := uint64(0); ; {
+		next, err := store.RebuildTimestampIndex(offset, 500)
+		if err != nil || next == offset {
+			fmt.Printf("indexed %d snapshots with %v\n", next, err)
+			return err
+		}
+		offset = next
+	}
 }
 
-func cancelNodeCmd(c *cli.Context) error {
-	seed := make([]byte, 64)
-	crypto.ReadRand(seed)
-	viewKey, err := crypto.KeyFromString(c.String("view"))
+func rebuildAssetFlows(c *cli.Context) error {
+	custom, err := config.Initialize(c.String("dir") + "/config.toml")
 	if err != nil {
 		return err
 	}
-	spendKey, err := crypto.KeyFromString(c.String("spend"))
+	store, err := storage.NewBadgerStore(custom, c.String("dir"))
 	if err != nil {
 		return err
 	}
-	receiver, err := common.NewAddressFromString(c.String("receiver"))
+	defer store.Close()
+	err = writeMaintenanceAudit(store, "rebuildassetflows")
 	if err != nil {
 		return err
 	}
-	account := common.Address{
-		PrivateViewKey:  viewKey,
-		PrivateSpendKey: spendKey,
-		PublicViewKey:   viewKey.Public(),
-		PublicSpendKey:  spendKey.Public(),
-	}
-	if account.String() != receiver.String() {
-		return fmt.Errorf("invalid key and receiver %s %s", account, receiver)
+	for offset := uint64(0); ; {
+		next, err := store.RebuildAssetFlows(offset, 500)
+		if err != nil || next == offset {
+			fmt.Printf("indexed %d snapshots with %v\n", next, err)
+			return err
+		}
+		offset = next
 	}
+}
 
-	b, err := hex.DecodeString(c.String("pledge"))
+func rebuildStateIndex(c *cli.Context) error {
+	custom, err := config.Initialize(c.String("dir") + "/config.toml")
 	if err != nil {
 		return err
 	}
-	pledge, err := common.UnmarshalVersionedTransaction(b)
+	store, err := storage.NewBadgerStore(custom, c.String("dir"))
 	if err != nil {
 		return err
 	}
-	if pledge.TransactionType() != common.TransactionTypeNodePledge {
-		return fmt.Errorf("invalid pledge transaction type %d", pledge.TransactionType())
+	defer store.Close()
+	err = writeMaintenanceAudit(store, "rebuildstateindex")
+	if err != nil {
+		return err
+	}
+	for offset := uint64(0); ; {
+		next, err := store.RebuildStateIndex(offset, 500)
+		if err != nil || next == offset {
+			fmt.Printf("indexed %d snapshots with %v\n", next, err)
+			return err
+		}
+		offset = next
 	}
+}
 
-	b, err = hex.DecodeString(c.String("source"))
+func repairStoreCmd(c *cli.Context) error {
+	custom, err := config.Initialize(c.String("dir") + "/config.toml")
 	if err != nil {
 		return err
 	}
-	source, err := common.UnmarshalVersionedTransaction(b)
+	store, err := storage.NewBadgerStore(custom, c.String("dir"))
 	if err != nil {
 		return err
 	}
-	if source.TransactionType() != common.TransactionTypeScript {
-		return fmt.Errorf("invalid source transaction type %d", source.TransactionType())
-	}
+	defer store.Close()
+	return recoverStore(store, "repairstore", c.Bool("dry"))
+}
 
-	if source.PayloadHash() != pledge.Inputs[0].Hash {
-		return fmt.Errorf("invalid source transaction hash %s %s", source.PayloadHash(), pledge.Inputs[0].Hash)
+func recoverStore(store storage.Store, actor string, dry bool) error {
+	issues, err := store.CheckRecovery()
+	if err != nil || len(issues) == 0 {
+		return err
 	}
-	if len(source.Outputs) != 1 || len(source.Outputs[0].Keys) != 1 {
-		return fmt.Errorf("invalid source transaction outputs %d %d", len(source.Outputs), len(source.Outputs[0].Keys))
+	for _, i := range issues {
+		fmt.Printf("recovery issue %s: %s\n", i.Kind, i.Detail)
 	}
-	pig := crypto.ViewGhostOutputKey(source.Outputs[0].Keys[0], &viewKey, &source.Outputs[0].Mask, 0)
-	if pig.String() != receiver.PublicSpendKey.String() {
-		return fmt.Errorf("invalid source and receiver %s %s", pig.String(), receiver.PublicSpendKey)
+	if dry {
+		return fmt.Errorf("found %d storage inconsistencies, run repairstore to repair", len(issues))
 	}
-
-	tx := common.NewTransactionV5(common.XINAssetId)
-	tx.AddInput(pledge.PayloadHash(), 0)
-	tx.AddOutputWithType(common.OutputTypeNodeCancel, nil, common.Script{}, pledge.Outputs[0].Amount.Div(100), seed)
-	tx.AddScriptOutput([]*common.Address{&receiver}, common.NewThresholdScript(1), pledge.Outputs[0].Amount.Sub(tx.Outputs[0].Amount), seed)
-	tx.Extra = append(pledge.Extra, viewKey[:]...)
-	utxo := &common.UTXO{
-		Input: common.Input{
-			Hash:  pledge.PayloadHash(),
-			Index: 0,
-		},
-		Output: common.Output{
-			Type: common.OutputTypeNodePledge,
-			Keys: source.Outputs[0].Keys,
-			Mask: source.Outputs[0].Mask,
-		},
+	err = writeMaintenanceAudit(store, fmt.Sprintf("%s repair %d inconsistencies", actor, len(issues)))
+	if err != nil {
+		return err
 	}
-	signed := tx.AsVersioned()
-	err = signed.SignUTXO(utxo, []*common.Address{&account})
+	err = store.RepairRecovery(issues)
 	if err != nil {
 		return err
 	}
-	fmt.Println(hex.EncodeToString(signed.Marshal()))
+	fmt.Printf("repaired %d storage inconsistencies\n", len(issues))
 	return nil
 }
 
-func decodePledgeNodeCmd(c *cli.Context) error {
-	b, err := hex.DecodeString(c.String("raw"))
+// doctorReport prints a pass or fail line for each check of the doctor, and
+// counts the failures to refuse the kernel start.
+type doctorReport struct {
+	failures int
+}
+
+func (r *doctorReport) check(name string, detail string, err error) bool {
 	if err != nil {
-		return err
+		r.failures += 1
+		fmt.Printf("FAIL %-10s %v\n", name, err)
+		return false
 	}
-	pledge, err := common.UnmarshalVersionedTransaction(b)
-	if err != nil {
-		return err
+	fmt.Printf("PASS %-10s %s\n", name, detail)
+	return true
+}
+
+func doctorCmd(c *cli.Context) error {
+	var report doctorReport
+	timeout := time.Duration(c.Int("timeout")) * time.Second
+
+	path := c.String("dir") + "/config.toml"
+	custom, err := config.InitializeNetwork(path, c.String("network"), c.StringSlice("set")...)
+	if !report.check("config", path, err) {
+		return fmt.Errorf("%d checks failed", report.failures)
+	}
+	gns, err := common.ReadGenesis(custom.Network.Genesis)
+	if !report.check("genesis", custom.Network.Genesis, err) {
+		return fmt.Errorf("%d checks failed", report.failures)
+	}
+	networkId := gns.NetworkId()
+
+	var id crypto.Hash
+	public, err := doctorSigner(custom)
+	if report.check("signer", public.String(), err) {
+		addr := common.Address{PublicSpendKey: public}
+		addr.PrivateViewKey = addr.PublicSpendKey.DeterministicHashDerive()
+		addr.PublicViewKey = addr.PrivateViewKey.Public()
+		id = addr.Hash().ForNetwork(networkId)
+	}
+
+	store, err := storage.NewBadgerStore(custom, custom.Network.Data)
+	if report.check("store", custom.Network.Data, err) {
+		defer store.Close()
+		detail, err := doctorGenesis(store, gns)
+		report.check("network", networkId.String()+" "+detail, err)
+		if id.HasValue() {
+			report.check("node", id.String()+" "+doctorNodeState(store, networkId, id), nil)
+		}
 	}
-	if len(pledge.Extra) != len(crypto.Key{})*2 {
-		return fmt.Errorf("invalid extra %s", hex.EncodeToString(pledge.Extra))
+
+	for _, seed := range custom.P2P.Seeds {
+		if id.HasValue() && strings.HasPrefix(seed, id.String()+"@") {
+			continue
+		}
+		start := time.Now()
+		err := doctorSeed(seed, timeout)
+		report.check("seed", seed+" "+time.Since(start).Round(time.Millisecond).String(), err)
 	}
-	signerPublicSpend, err := crypto.KeyFromString(hex.EncodeToString(pledge.Extra[:32]))
-	if err != nil {
-		return err
+
+	if server := c.String("ntp"); server != "" {
+		offset, err := queryClockOffset(server, timeout)
+		if err == nil && offset.Abs() > time.Duration(config.SnapshotRoundGap) {
+			err = fmt.Errorf("clock offset %s to %s exceeds %s", offset, server, time.Duration(config.SnapshotRoundGap))
+		}
+		report.check("clock", "offset "+offset.String()+" to "+server, err)
+	}
+
+	if report.failures > 0 {
+		return fmt.Errorf("%d checks failed", report.failures)
+	}
+	return nil
+}
+
+// doctorSigner makes a signature with the configured signer the same way as
+// the kernel, which proves the remote signer or the cosigners are reachable
+// and the public key matches the signatures.
+func doctorSigner(custom *config.Custom) (crypto.Key, error) {
+	err := custom.UnlockSigner(promptSignerPassphrase)
+	if err != nil {
+		return crypto.Key{}, err
+	}
+	var signer crypto.Signer
+	if cosigners := custom.Node.SignerCosigners; len(cosigners) > 0 {
+		ts, err := remotesigner.NewThresholdSigner(custom.Node.SignerThreshold, cosigners,
+			custom.Node.SignerRemoteCert, custom.Node.SignerRemoteKey, custom.Node.SignerRemoteCA)
+		if err != nil {
+			return crypto.Key{}, err
+		}
+		signer = ts
+	} else if remote := custom.Node.SignerRemote; remote != "" {
+		client, err := remotesigner.NewClient(remote, custom.Node.SignerRemoteCert,
+			custom.Node.SignerRemoteKey, custom.Node.SignerRemoteCA)
+		if err != nil {
+			return crypto.Key{}, err
+		}
+		signer = client
+	} else if !custom.Node.Signer.CheckScalar() {
+		return crypto.Key{}, errors.New("invalid signer key")
+	} else {
+		signer = crypto.NewKeySigner(custom.Node.Signer)
+	}
+	probe := crypto.Blake3Hash([]byte(fmt.Sprintf("MIXINDOCTOR%d", time.Now().UnixNano())))
+	public := signer.PublicKey()
+	sig, err := signer.Sign(probe)
+	if err != nil {
+		return crypto.Key{}, fmt.Errorf("signer %s %v", public, err)
+	}
+	if !public.Verify(probe, sig) {
+		return crypto.Key{}, fmt.Errorf("signer %s signature mismatch", public)
+	}
+	return public, nil
+}
+
+// doctorGenesis checks the genesis snapshots in the store are the snapshots
+// of the genesis file, so the store belongs to the same network.
+func doctorGenesis(store storage.Store, gns *common.Genesis) (string, error) {
+	_, snapshots, _, err := gns.BuildSnapshots()
+	if err != nil {
+		return "", err
+	}
+	loaded, err := store.CheckGenesisLoad(snapshots)
+	if err != nil {
+		return "", fmt.Errorf("store genesis mismatch %s: %v", gns.NetworkId(), err)
+	}
+	if !loaded {
+		return "empty store", nil
+	}
+	return "genesis loaded", nil
+}
+
+func doctorNodeState(store storage.Store, networkId, id crypto.Hash) string {
+	for _, n := range store.ReadAllNodes(uint64(time.Now().UnixNano()), true) {
+		if n.IdForNetwork(networkId) == id {
+			return n.State
+		}
+	}
+	return "not a consensus node"
+}
+
+func doctorSeed(seed string, timeout time.Duration) error {
+	parts := strings.Split(seed, "@")
+	if len(parts) != 2 {
+		return fmt.Errorf("invalid peer %s", seed)
+	}
+	_, err := crypto.HashFromString(parts[0])
+	if err != nil {
+		return fmt.Errorf("invalid peer id %s", seed)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	client, err := p2p.NewQuicConsumer(ctx, parts[1])
+	if err != nil {
+		return err
+	}
+	return client.Close("doctor")
+}
+
+// queryClockOffset is the SNTP client of RFC 4330, the offset is positive
+// when the local clock is behind the server.
+func queryClockOffset(server string, timeout time.Duration) (time.Duration, error) {
+	conn, err := net.DialTimeout("udp", server, timeout)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	err = conn.SetDeadline(time.Now().Add(timeout))
+	if err != nil {
+		return 0, err
+	}
+
+	req := make([]byte, 48)
+	req[0] = 0x23 // version 4, client mode
+	sent := time.Now()
+	_, err = conn.Write(req)
+	if err != nil {
+		return 0, err
+	}
+	res := make([]byte, 48)
+	n, err := conn.Read(res)
+	if err != nil {
+		return 0, err
+	}
+	received := time.Now()
+	if n < 48 || res[0]&0x07 != 4 || res[1] == 0 {
+		return 0, fmt.Errorf("invalid ntp response from %s", server)
+	}
+
+	ntpTime := func(b []byte) time.Time {
+		sec := int64(binary.BigEndian.Uint32(b[:4])) - 2208988800
+		frac := uint64(binary.BigEndian.Uint32(b[4:8]))
+		return time.Unix(sec, int64(frac*1e9>>32))
+	}
+	receive, transmit := ntpTime(res[32:40]), ntpTime(res[40:48])
+	return (receive.Sub(sent) + transmit.Sub(received)) / 2, nil
+}
+
+func remoteSignerCmd(c *cli.Context) error {
+	custom, err := config.Initialize(c.String("dir") + "/config.toml")
+	if err != nil {
+		return err
+	}
+	err = custom.UnlockSigner(promptSignerPassphrase)
+	if err != nil {
+		return err
+	}
+	if !custom.Node.Signer.HasValue() {
+		return errors.New("signer key not found")
+	}
+	signer := crypto.NewKeySigner(custom.Node.Signer)
+	fmt.Printf("remote signer %s listening on %s\n", signer.PublicKey(), c.String("listen"))
+	server := remotesigner.NewServer(signer)
+	return server.ListenAndServe(c.String("listen"), c.String("cert"), c.String("key"), c.String("ca"))
+}
+
+func encryptSignerKeyCmd(c *cli.Context) error {
+	key, err := crypto.KeyFromString(c.String("key"))
+	if err != nil {
+		return err
+	}
+	passphrase, err := promptSignerPassphrase()
+	if err != nil {
+		return err
+	}
+	passphrase = bytes.TrimSpace(passphrase)
+	if len(passphrase) < 8 {
+		return errors.New("passphrase too short")
+	}
+	encrypted, err := config.EncryptSignerKey(key, passphrase)
+	if err != nil {
+		return err
+	}
+	fmt.Println(encrypted)
+	return nil
+}
+
+func splitSignerKeyCmd(c *cli.Context) error {
+	key, err := crypto.KeyFromString(c.String("key"))
+	if err != nil {
+		return err
+	}
+	shares, err := crypto.SplitKeyShares(key, c.Int("threshold"), c.Int("total"))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("public: %s\n", key.Public())
+	for _, s := range shares {
+		fmt.Printf("share %d: %s %s\n", s.Index, s.Secret, s.Public)
+	}
+	return nil
+}
+
+func promptSignerPassphrase() ([]byte, error) {
+	fmt.Fprint(os.Stderr, "signer key passphrase: ")
+	line, err := bufio.NewReader(os.Stdin).ReadBytes('\n')
+	if err != nil && len(line) == 0 {
+		return nil, err
+	}
+	return line, nil
+}
+
+func decodeTransactionCmd(c *cli.Context) error {
+	raw, err := hex.DecodeString(c.String("raw"))
+	if err != nil {
+		return err
+	}
+	ver, err := common.UnmarshalVersionedTransaction(raw)
+	if err != nil {
+		return err
+	}
+	m := transactionToMap(ver)
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// decodeCmd accepts the hex or base64 encoded transaction or snapshot, and
+// annotates the JSON with the type names, the known asset symbol, the script
+// semantics and the extra decoded by the registered extra schemas. The legacy
+// versions are detected, but the kernel has no decoders for them anymore.
+func decodeCmd(c *cli.Context) error {
+	payload := strings.TrimSpace(c.String("payload"))
+	raw, err := hex.DecodeString(payload)
+	if err != nil {
+		raw, err = base64.StdEncoding.DecodeString(payload)
+	}
+	if err != nil {
+		raw, err = base64.RawURLEncoding.DecodeString(payload)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid hex or base64 payload")
+	}
+	if len(raw) < 4 || raw[0] != 0x77 || raw[1] != 0x77 || raw[2] != 0 {
+		return fmt.Errorf("unknown payload %x, maybe the legacy msgpack encoding", raw[:min(len(raw), 4)])
+	}
+
+	var m map[string]any
+	switch raw[3] {
+	case common.TxVersionHashSignature:
+		ver, err := common.UnmarshalVersionedTransaction(raw)
+		if err != nil {
+			return err
+		}
+		m = annotatedTransactionToMap(ver)
+	case common.SnapshotVersionCommonEncoding:
+		s, err := common.UnmarshalVersionedSnapshot(raw)
+		if err != nil {
+			return err
+		}
+		m = annotatedSnapshotToMap(s)
+	default:
+		if raw[3] < common.TxVersionHashSignature {
+			return fmt.Errorf("legacy encoding version %d is not supported by this kernel", raw[3])
+		}
+		return fmt.Errorf("unknown encoding version %d", raw[3])
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+func annotatedTransactionToMap(ver *common.VersionedTransaction) map[string]any {
+	m := transactionToMap(ver)
+	m["kind"] = "transaction"
+	m["transaction_type"] = transactionTypeName(ver.TransactionType())
+	m["asset_symbol"] = assetSymbol(ver.Asset)
+	outputs, _ := m["outputs"].([]map[string]any)
+	for i, out := range ver.Outputs {
+		outputs[i]["type_name"] = outputTypeName(out.Type)
+		if len(out.Script) > 0 {
+			outputs[i]["script_semantics"] = scriptSemantics(out.Script, len(out.Keys))
+		}
+	}
+	if name, data, err := common.DecodeExtraSchema(ver); name != "" && err == nil {
+		m["extra_schema"] = name
+		m["extra_decoded"] = data
+	} else if len(ver.Extra) > 0 && utf8.Valid(ver.Extra) {
+		m["extra_text"] = string(ver.Extra)
+	}
+	return m
+}
+
+func annotatedSnapshotToMap(s *common.SnapshotWithTopologicalOrder) map[string]any {
+	m := map[string]any{
+		"kind":         "snapshot",
+		"version":      s.Version,
+		"node":         s.NodeId,
+		"round":        s.RoundNumber,
+		"timestamp":    s.Timestamp,
+		"time":         time.Unix(0, int64(s.Timestamp)).UTC().Format(time.RFC3339Nano),
+		"hash":         s.PayloadHash(),
+		"topology":     s.TopologicalOrder,
+		"transactions": s.Transactions,
+	}
+	if r := s.References; r != nil {
+		m["references"] = map[string]any{
+			"self":     r.Self,
+			"external": r.External,
+		}
+	}
+	if sig := s.Signature; sig != nil {
+		m["signature"] = map[string]any{
+			"signature": sig.Signature,
+			"signers":   sig.Keys(),
+			"threshold": len(sig.Keys()),
+		}
+	}
+	return m
+}
+
+func scriptSemantics(s common.Script, keys int) map[string]any {
+	err := s.VerifyFormat()
+	if err != nil {
+		return map[string]any{"error": err.Error()}
+	}
+	if es, _ := s.Escrow(); es != nil {
+		return map[string]any{
+			"kind":               "escrow",
+			"receiver_keys":      es.ReceiverKeys,
+			"receiver_threshold": es.ReceiverThreshold,
+			"sender_keys":        keys - int(es.ReceiverKeys),
+			"sender_threshold":   es.SenderThreshold,
+			"deadline":           es.Deadline,
+			"hash_lock":          es.HashLock,
+		}
+	}
+	sm := map[string]any{
+		"kind":      "threshold",
+		"threshold": s[2],
+		"keys":      keys,
+	}
+	if lock := s.LockTime(); lock > 0 {
+		sm["kind"] = "timelock"
+		sm["lock_time"] = lock
+		sm["lock_until"] = time.Unix(0, int64(lock)).UTC().Format(time.RFC3339Nano)
+	}
+	return sm
+}
+
+func transactionTypeName(t uint8) string {
+	switch t {
+	case common.TransactionTypeScript:
+		return "script"
+	case common.TransactionTypeMint:
+		return "mint"
+	case common.TransactionTypeDeposit:
+		return "deposit"
+	case common.TransactionTypeWithdrawalSubmit:
+		return "withdrawal_submit"
+	case common.TransactionTypeWithdrawalClaim:
+		return "withdrawal_claim"
+	case common.TransactionTypeNodePledge:
+		return "node_pledge"
+	case common.TransactionTypeNodeAccept:
+		return "node_accept"
+	case common.TransactionTypeNodeRemove:
+		return "node_remove"
+	case common.TransactionTypeNodeCancel:
+		return "node_cancel"
+	case common.TransactionTypeNodePayeeChange:
+		return "node_payee_change"
+	case common.TransactionTypeCustodianUpdateNodes:
+		return "custodian_update_nodes"
+	case common.TransactionTypeCustodianSlashNodes:
+		return "custodian_slash_nodes"
+	}
+	return "unknown"
+}
+
+func outputTypeName(t uint8) string {
+	switch t {
+	case common.OutputTypeScript:
+		return "script"
+	case common.OutputTypeWithdrawalSubmit:
+		return "withdrawal_submit"
+	case common.OutputTypeNodePledge:
+		return "node_pledge"
+	case common.OutputTypeNodeAccept:
+		return "node_accept"
+	case common.OutputTypeNodeRemove:
+		return "node_remove"
+	case common.OutputTypeWithdrawalClaim:
+		return "withdrawal_claim"
+	case common.OutputTypeNodeCancel:
+		return "node_cancel"
+	case common.OutputTypeNodePayeeChange:
+		return "node_payee_change"
+	case common.OutputTypeCustodianUpdateNodes:
+		return "custodian_update_nodes"
+	case common.OutputTypeCustodianSlashNodes:
+		return "custodian_slash_nodes"
+	}
+	return "unknown"
+}
+
+func assetSymbol(id crypto.Hash) string {
+	switch id {
+	case common.XINAssetId:
+		return "XIN"
+	case common.BitcoinAssetId:
+		return "BTC"
+	case common.EthereumAssetId:
+		return "ETH"
+	case common.BOXAssetId:
+		return "BOX"
+	case common.MOBAssetId:
+		return "MOB"
+	case common.USDTEthereumAssetId:
+		return "USDT-ERC20"
+	case common.USDTTRONAssetId:
+		return "USDT-TRC20"
+	case common.PandoUSDAssetId:
+		return "pUSD"
+	case common.USDCAssetId:
+		return "USDC"
+	case common.EOSAssetId:
+		return "EOS"
+	case common.SOLAssetId:
+		return "SOL"
+	case common.UNIAssetId:
+		return "UNI"
+	case common.DOGEAssetId:
+		return "DOGE"
+	}
+	return ""
+}
+
+func buildRawTransactionCmd(c *cli.Context) error {
+	if c.Bool("offline") {
+		return buildOfflineRawTransactionCmd(c)
+	}
+
+	seed, err := hex.DecodeString(c.String("seed"))
+	if err != nil {
+		return err
+	}
+	if len(seed) != 64 {
+		seed = make([]byte, 64)
+		crypto.ReadRand(seed)
+	}
+
+	viewKey, err := crypto.KeyFromString(c.String("view"))
+	if err != nil {
+		return err
+	}
+	signer, err := openGhostSigner(c.String("signer"), c.String("spend"))
+	if err != nil {
+		return err
+	}
+
+	asset, err := crypto.HashFromString(c.String("asset"))
+	if err != nil {
+		return err
+	}
+
+	extra, err := hex.DecodeString(c.String("extra"))
+	if err != nil {
+		return err
+	}
+
+	inputs := make([]map[string]any, 0)
+	for _, in := range strings.Split(c.String("inputs"), ",") {
+		parts := strings.Split(in, ":")
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid input %s", in)
+		}
+		hash, err := crypto.HashFromString(parts[0])
+		if err != nil {
+			return err
+		}
+		index, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			return err
+		}
+		inputs = append(inputs, map[string]any{
+			"hash":  hash,
+			"index": index,
+		})
+	}
+
+	outputs := make([]map[string]any, 0)
+	for _, out := range strings.Split(c.String("outputs"), ",") {
+		parts := strings.Split(out, ":")
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid output %s", out)
+		}
+		addr, err := common.NewAddressFromString(parts[0])
+		if err != nil {
+			return err
+		}
+		amount := common.NewIntegerFromString(parts[1])
+		if amount.Sign() == 0 {
+			return fmt.Errorf("invalid output %s", out)
+		}
+		outputs = append(outputs, map[string]any{
+			"accounts": []*common.Address{&addr},
+			"amount":   amount,
+		})
+	}
+
+	var raw signerInput
+	raw.Node = c.String("node")
+	isb, _ := json.Marshal(map[string]any{"inputs": inputs})
+	_ = json.Unmarshal(isb, &raw)
+
+	tx := common.NewTransactionV5(asset)
+	for _, in := range inputs {
+		tx.AddInput(in["hash"].(crypto.Hash), uint(in["index"].(int64)))
+	}
+	for _, out := range outputs {
+		tx.AddScriptOutput(out["accounts"].([]*common.Address), common.NewThresholdScript(1), out["amount"].(common.Integer), seed)
+	}
+	tx.Extra = extra
+
+	signed := tx.AsVersioned()
+	for i := range tx.Inputs {
+		err = signed.SignInputWithSigners(raw, i, []crypto.Key{viewKey}, []crypto.GhostSigner{signer})
+		if err != nil {
+			return err
+		}
+	}
+	fmt.Println(hex.EncodeToString(signed.Marshal()))
+	return nil
+}
+
+func openGhostSigner(kind, spend string) (crypto.GhostSigner, error) {
+	switch kind {
+	case "", "key":
+		key, err := crypto.KeyFromString(spend)
+		if err != nil {
+			return nil, err
+		}
+		return crypto.NewKeySigner(key), nil
+	default:
+		return nil, fmt.Errorf("invalid signer %s", kind)
+	}
+}
+
+// the offline raw transaction is built by a watch-only node with only the
+// private view key, all inputs are verified to belong to the address and the
+// keys are embedded, so the air-gapped signer needs no RPC access at all.
+func buildOfflineRawTransactionCmd(c *cli.Context) error {
+	viewKey, err := crypto.KeyFromString(c.String("view"))
+	if err != nil {
+		return err
+	}
+	account, err := common.NewAddressFromString(c.String("address"))
+	if err != nil {
+		return err
+	}
+	if account.PublicViewKey != viewKey.Public() {
+		return fmt.Errorf("invalid view key for address %s", account)
+	}
+	account.PrivateViewKey = viewKey
+
+	asset, err := crypto.HashFromString(c.String("asset"))
+	if err != nil {
+		return err
+	}
+	_, err = hex.DecodeString(c.String("extra"))
+	if err != nil {
+		return err
+	}
+
+	inputs := make([]map[string]any, 0)
+	for _, in := range strings.Split(c.String("inputs"), ",") {
+		parts := strings.Split(in, ":")
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid input %s", in)
+		}
+		hash, err := crypto.HashFromString(parts[0])
+		if err != nil {
+			return err
+		}
+		index, err := strconv.ParseUint(parts[1], 10, 16)
+		if err != nil {
+			return err
+		}
+		utxo, err := readWatchOnlyUTXO(c.String("node"), hash, uint(index))
+		if err != nil {
+			return err
+		}
+		if utxo.Asset != asset {
+			return fmt.Errorf("invalid input asset %s %s", in, utxo.Asset)
+		}
+		if utxo.Lock.HasValue() {
+			return fmt.Errorf("input %s locked by %s", in, utxo.Lock)
+		}
+		out := &common.Output{Keys: utxo.Keys, Mask: utxo.Mask}
+		if _, found := out.ViewKeyIndex(&account, uint(index)); !found {
+			return fmt.Errorf("input %s not owned by %s", in, account)
+		}
+		inputs = append(inputs, map[string]any{
+			"hash":  hash,
+			"index": index,
+			"keys":  utxo.Keys,
+			"mask":  utxo.Mask,
+		})
+	}
+
+	outputs := make([]map[string]any, 0)
+	for _, out := range strings.Split(c.String("outputs"), ",") {
+		parts := strings.Split(out, ":")
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid output %s", out)
+		}
+		addr, err := common.NewAddressFromString(parts[0])
+		if err != nil {
+			return err
+		}
+		amount := common.NewIntegerFromString(parts[1])
+		if amount.Sign() == 0 {
+			return fmt.Errorf("invalid output %s", out)
+		}
+		outputs = append(outputs, map[string]any{
+			"type":     common.OutputTypeScript,
+			"accounts": []*common.Address{&addr},
+			"script":   common.NewThresholdScript(1),
+			"amount":   amount,
+		})
+	}
+
+	data, err := json.Marshal(map[string]any{
+		"version": common.TxVersionHashSignature,
+		"asset":   asset,
+		"inputs":  inputs,
+		"outputs": outputs,
+		"extra":   c.String("extra"),
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+func scanOutputsCmd(c *cli.Context) error {
+	viewKey, err := crypto.KeyFromString(c.String("view"))
+	if err != nil {
+		return err
+	}
+	account, err := common.NewAddressFromString(c.String("address"))
+	if err != nil {
+		return err
+	}
+	if account.PublicViewKey != viewKey.Public() {
+		return fmt.Errorf("invalid view key for address %s", account)
+	}
+	account.PrivateViewKey = viewKey
+
+	data, err := callRPC(c.String("node"), "listsnapshots", []any{
+		c.Uint64("since"),
+		c.Uint64("count"),
+		false,
+		true,
+	}, c.Bool("time"))
+	if err != nil {
+		return err
+	}
+	var snapshots []struct {
+		Topology     uint64 `json:"topology"`
+		Transactions []struct {
+			Hash    crypto.Hash `json:"hash"`
+			Asset   crypto.Hash `json:"asset"`
+			Outputs []struct {
+				Type   uint8          `json:"type"`
+				Amount common.Integer `json:"amount"`
+				Keys   []*crypto.Key  `json:"keys"`
+				Mask   crypto.Key     `json:"mask"`
+			} `json:"outputs"`
+		} `json:"transactions"`
+	}
+	err = json.Unmarshal(data, &snapshots)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range snapshots {
+		for _, tx := range s.Transactions {
+			for i, o := range tx.Outputs {
+				if o.Type != common.OutputTypeScript {
+					continue
+				}
+				out := &common.Output{Keys: o.Keys, Mask: o.Mask}
+				if _, found := out.ViewKeyIndex(&account, uint(i)); !found {
+					continue
+				}
+				utxo, err := readWatchOnlyUTXO(c.String("node"), tx.Hash, uint(i))
+				if err != nil {
+					return err
+				}
+				if utxo.Lock.HasValue() {
+					continue
+				}
+				fmt.Printf("%d %s:%d %s %s\n", s.Topology, tx.Hash, i, tx.Asset, o.Amount)
+			}
+		}
+	}
+	return nil
+}
+
+type watchOnlyUTXO struct {
+	Hash   crypto.Hash    `json:"hash"`
+	Index  uint           `json:"index"`
+	Asset  crypto.Hash    `json:"asset"`
+	Amount common.Integer `json:"amount"`
+	Keys   []*crypto.Key  `json:"keys"`
+	Mask   crypto.Key     `json:"mask"`
+	Lock   crypto.Hash    `json:"lock"`
+}
+
+func readWatchOnlyUTXO(node string, hash crypto.Hash, index uint) (*watchOnlyUTXO, error) {
+	data, err := callRPC(node, "getutxo", []any{hash.String(), index}, false)
+	if err != nil {
+		return nil, err
+	}
+	var utxo watchOnlyUTXO
+	err = json.Unmarshal(data, &utxo)
+	if err != nil {
+		return nil, err
+	}
+	if utxo.Amount.Sign() == 0 {
+		return nil, fmt.Errorf("invalid input %s#%d", hash.String(), index)
+	}
+	return &utxo, nil
+}
+
+func signTransactionCmd(c *cli.Context) error {
+	var raw signerInput
+	err := json.Unmarshal([]byte(c.String("raw")), &raw)
+	if err != nil {
+		return err
+	}
+	if raw.Version != common.TxVersionHashSignature {
+		return fmt.Errorf("invalid version number %d", raw.Version)
+	}
+	raw.Node = c.String("node")
+	raw.Offline = c.Bool("offline")
+
+	seed, err := hex.DecodeString(c.String("seed"))
+	if err != nil {
+		return err
+	}
+	if len(seed) != 64 {
+		seed = make([]byte, 64)
+		crypto.ReadRand(seed)
+	}
+
+	tx := common.NewTransactionV5(raw.Asset)
+	for _, in := range raw.Inputs {
+		if d := in.Deposit; d != nil {
+			tx.AddDepositInput(&common.DepositData{
+				Chain:       d.Chain,
+				AssetKey:    d.AssetKey,
+				Transaction: d.TransactionHash,
+				Index:       d.OutputIndex,
+				Amount:      d.Amount,
+			})
+		} else {
+			tx.AddInput(in.Hash, in.Index)
+		}
+	}
+
+	for _, out := range raw.Outputs {
+		if out.Mask.HasValue() {
+			tx.Outputs = append(tx.Outputs, &common.Output{
+				Type:   out.Type,
+				Amount: out.Amount,
+				Keys:   out.Keys,
+				Script: out.Script,
+				Mask:   out.Mask,
+			})
+		} else {
+			hash := crypto.Blake3Hash(seed)
+			seed = append(hash[:], hash[:]...)
+			tx.AddOutputWithType(out.Type, out.Accounts, out.Script, out.Amount, seed)
+		}
+	}
+
+	tx.References = raw.References
+	extra, err := hex.DecodeString(raw.Extra)
+	if err != nil {
+		return err
+	}
+	tx.Extra = extra
+
+	keys := c.StringSlice("key")
+	var accounts []*common.Address
+	for _, s := range keys {
+		key, err := hex.DecodeString(s)
+		if err != nil {
+			return err
+		}
+		if len(key) != 64 {
+			return fmt.Errorf("invalid key length %d", len(key))
+		}
+		var account common.Address
+		copy(account.PrivateViewKey[:], key[:32])
+		copy(account.PrivateSpendKey[:], key[32:])
+		accounts = append(accounts, &account)
+	}
+
+	signed := tx.AsVersioned()
+	for i := range signed.Inputs {
+		err := signed.SignInput(raw, i, accounts)
+		if err != nil {
+			return err
+		}
+	}
+	fmt.Println(hex.EncodeToString(signed.Marshal()))
+	return nil
+}
+
+func buildEscrowScriptCmd(c *cli.Context) error {
+	deadline, err := time.Parse(time.RFC3339, c.String("deadline"))
+	if err != nil {
+		return err
+	}
+	secret, err := hex.DecodeString(c.String("secret"))
+	if err != nil {
+		return err
+	}
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		crypto.ReadRand(secret)
+	}
+	receivers := c.Int("receivers")
+	if receivers < 1 || receivers > common.Operator64 {
+		return fmt.Errorf("invalid receivers count %d", receivers)
+	}
+	script := common.NewEscrowScript(uint8(receivers), uint8(c.Int("receiver-threshold")),
+		uint8(c.Int("sender-threshold")), uint64(deadline.UnixNano()), secret)
+	err = script.VerifyFormat()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(map[string]any{
+		"script":    script,
+		"secret":    hex.EncodeToString(secret),
+		"hash_lock": crypto.Blake3Hash(secret),
+		"deadline":  deadline.UnixNano(),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// the recipients file has one "address,amount" per line, and the inputs are
+// "hash:index:amount", the transactions are built until all recipients paid
+func buildBatchTransferCmd(c *cli.Context) error {
+	asset, err := crypto.HashFromString(c.String("asset"))
+	if err != nil {
+		return err
+	}
+	change, err := common.NewAddressFromString(c.String("change"))
+	if err != nil {
+		return err
+	}
+	extra, err := hex.DecodeString(c.String("extra"))
+	if err != nil {
+		return err
+	}
+	seed, err := hex.DecodeString(c.String("seed"))
+	if err != nil {
+		return err
+	}
+	if len(seed) != 64 {
+		seed = make([]byte, 64)
+		crypto.ReadRand(seed)
+	}
+
+	var inputs []*common.TransferInput
+	for _, s := range c.StringSlice("input") {
+		parts := strings.Split(s, ":")
+		if len(parts) != 3 {
+			return fmt.Errorf("invalid input %s", s)
+		}
+		hash, err := crypto.HashFromString(parts[0])
+		if err != nil {
+			return err
+		}
+		index, err := strconv.ParseUint(parts[1], 10, 64)
+		if err != nil {
+			return err
+		}
+		inputs = append(inputs, &common.TransferInput{
+			Hash:   hash,
+			Index:  uint(index),
+			Amount: common.NewIntegerFromString(parts[2]),
+		})
+	}
+	if account := c.String("account"); account != "" {
+		outputs, err := listWalletInputs(c.String("node"), account, asset)
+		if err != nil {
+			return err
+		}
+		inputs = append(inputs, outputs...)
+	}
+	strategy := c.String("strategy")
+	if strategy == "" && c.String("account") != "" {
+		strategy = common.CoinSelectionLargestFirst
+	}
+
+	data, err := os.ReadFile(c.String("recipients"))
+	if err != nil {
+		return err
+	}
+	var recipients []*common.TransferRecipient
+	for _, l := range strings.Split(string(data), "\n") {
+		l = strings.TrimSpace(l)
+		if l == "" {
+			continue
+		}
+		parts := strings.Split(l, ",")
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid recipient %s", l)
+		}
+		addr, err := common.NewAddressFromString(strings.TrimSpace(parts[0]))
+		if err != nil {
+			return err
+		}
+		recipients = append(recipients, &common.TransferRecipient{
+			Address: &addr,
+			Amount:  common.NewIntegerFromString(strings.TrimSpace(parts[1])),
+		})
+	}
+
+	var raws []map[string]any
+	for len(recipients) > 0 {
+		var tx *common.Transaction
+		var rest []*common.TransferRecipient
+		if strategy == "" {
+			tx, rest, err = common.NewBatchTransfer(asset, inputs, recipients, &change, extra, seed)
+		} else {
+			tx, rest, err = common.NewBatchTransferWithSelection(asset, inputs, recipients, &change, extra, seed, strategy)
+		}
+		if err != nil {
+			return err
+		}
+		inputs, recipients = removeSpentTransferInputs(inputs, tx.Inputs), rest
+		seed = append(seed[32:], seed[:32]...)
+		ins := make([]map[string]any, len(tx.Inputs))
+		for i, in := range tx.Inputs {
+			ins[i] = map[string]any{"hash": in.Hash, "index": in.Index}
+		}
+		outs := make([]map[string]any, len(tx.Outputs))
+		for i, out := range tx.Outputs {
+			outs[i] = map[string]any{
+				"type":   out.Type,
+				"amount": out.Amount,
+				"keys":   out.Keys,
+				"mask":   out.Mask,
+				"script": out.Script,
+			}
+		}
+		raws = append(raws, map[string]any{
+			"version": tx.Version,
+			"asset":   tx.Asset,
+			"inputs":  ins,
+			"outputs": outs,
+			"extra":   hex.EncodeToString(tx.Extra),
+		})
+	}
+	data, err = json.MarshalIndent(raws, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// the outputs of the wallet scanner are those of the transactions already
+// finalized, and the ones spent by the transactions in the cache are still
+// listed, so the transactions built may fail with the locked inputs.
+func listWalletInputs(node, account string, asset crypto.Hash) ([]*common.TransferInput, error) {
+	data, err := callRPC(node, "listwalletoutputs", []any{account, asset.String(), false}, false)
+	if err != nil {
+		return nil, err
+	}
+	var outputs []struct {
+		Hash   crypto.Hash    `json:"hash"`
+		Index  uint           `json:"index"`
+		Amount common.Integer `json:"amount"`
+	}
+	err = json.Unmarshal(data, &outputs)
+	if err != nil {
+		return nil, err
+	}
+	inputs := make([]*common.TransferInput, len(outputs))
+	for i, o := range outputs {
+		inputs[i] = &common.TransferInput{Hash: o.Hash, Index: o.Index, Amount: o.Amount}
+	}
+	return inputs, nil
+}
+
+func removeSpentTransferInputs(inputs []*common.TransferInput, spent []*common.Input) []*common.TransferInput {
+	filter := make(map[string]bool)
+	for _, in := range spent {
+		filter[fmt.Sprintf("%s:%d", in.Hash, in.Index)] = true
+	}
+	var rest []*common.TransferInput
+	for _, in := range inputs {
+		if !filter[fmt.Sprintf("%s:%d", in.Hash, in.Index)] {
+			rest = append(rest, in)
+		}
+	}
+	return rest
+}
+
+func sendTransactionCmd(c *cli.Context) error {
+	params := []any{c.String("raw")}
+	if k := c.String("key"); k != "" {
+		params = append(params, k)
+	}
+	data, err := callRPC(c.String("node"), "sendrawtransaction", params, c.Bool("time"))
+	if err == nil {
+		fmt.Println(string(data))
+	}
+	return err
+}
+
+func custodianDepositCmd(c *cli.Context) error {
+	receiver, err := common.NewAddressFromString(c.String("receiver"))
+	if err != nil {
+		return fmt.Errorf("invalid receiver %s", c.String("receiver"))
+	}
+	custodian, err := parseCustodianKey(c.String("custodian"))
+	if err != nil {
+		return err
+	}
+
+	asset, err := crypto.HashFromString(c.String("asset"))
+	if err != nil {
+		return fmt.Errorf("invalid asset %s", c.String("asset"))
+	}
+	chain, err := crypto.HashFromString(c.String("chain"))
+	if err != nil {
+		return fmt.Errorf("invalid chain %s", c.String("chain"))
+	}
+	amount := common.NewIntegerFromString(c.String("amount"))
+	seed := make([]byte, 64)
+	crypto.ReadRand(seed)
+	script := common.NewThresholdScript(1)
+	deposit := &common.DepositData{
+		Chain:       chain,
+		AssetKey:    c.String("asset_key"),
+		Transaction: c.String("transaction"),
+		Index:       c.Uint64("index"),
+		Amount:      amount,
+	}
+	tx := common.NewTransactionV5(asset)
+	tx.AddDepositInput(deposit)
+	tx.AddScriptOutput([]*common.Address{&receiver}, script, amount, seed)
+	ver := tx.AsVersioned()
+	err = ver.SignInput(nil, 0, []*common.Address{custodian})
+	if err == nil {
+		fmt.Printf("%x\n", ver.Marshal())
+	}
+	return err
+}
+
+func parseCustodianKey(kph string) (*common.Address, error) {
+	if len(kph) != 128 {
+		return nil, fmt.Errorf("invalid custodian %s", kph)
+	}
+	view, err := crypto.KeyFromString(kph[:64])
+	if err != nil {
+		return nil, fmt.Errorf("invalid custodian %s", kph)
+	}
+	spend, err := crypto.KeyFromString(kph[64:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid custodian %s", kph)
+	}
+	return &common.Address{
+		PrivateViewKey:  view,
+		PrivateSpendKey: spend,
+		PublicViewKey:   view.Public(),
+		PublicSpendKey:  spend.Public(),
+	}, nil
+}
+
+func pledgeNodeCmd(c *cli.Context) error {
+	signed, err := buildPledgeTransaction(c)
+	if err != nil {
+		return err
+	}
+	fmt.Println(hex.EncodeToString(signed.Marshal()))
+	return nil
+}
+
+func buildPledgeTransaction(c *cli.Context) (*common.VersionedTransaction, error) {
+	seed := make([]byte, 64)
+	crypto.ReadRand(seed)
+	viewKey, err := crypto.KeyFromString(c.String("view"))
+	if err != nil {
+		return nil, err
+	}
+	kind, address := pledgeSignerFlags(c)
+	spendSigner, err := openGhostSigner(kind, c.String("spend"))
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := common.NewAddressFromString(address)
+	if err != nil {
+		return nil, err
+	}
+	payee, err := common.NewAddressFromString(c.String("payee"))
+	if err != nil {
+		return nil, err
+	}
+
+	var raw signerInput
+	input, err := crypto.HashFromString(c.String("input"))
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal([]byte(fmt.Sprintf(`{"inputs":[{"hash":"%s","index":0}]}`, input.String())), &raw)
+	if err != nil {
+		return nil, err
+	}
+	raw.Node = c.String("node")
+
+	amount := common.NewIntegerFromString(c.String("amount"))
+
+	tx := common.NewTransactionV5(common.XINAssetId)
+	tx.AddInput(input, 0)
+	tx.AddOutputWithType(common.OutputTypeNodePledge, nil, common.Script{}, amount, seed)
+	tx.Extra = append(signer.PublicSpendKey[:], payee.PublicSpendKey[:]...)
+
+	signed := tx.AsVersioned()
+	err = signed.SignInputWithSigners(raw, 0, []crypto.Key{viewKey}, []crypto.GhostSigner{spendSigner})
+	if err != nil {
+		return nil, err
+	}
+	return signed, nil
+}
+
+// the pledge commands take the spend key signer kind by --signer, as the
+// other spending commands, and the node signer address by --signer-address,
+// the legacy --signer address is still accepted for the key signer.
+func pledgeSignerFlags(c *cli.Context) (string, string) {
+	kind, address := c.String("signer"), c.String("signer-address")
+	switch kind {
+	case "", "key":
+		return kind, address
+	}
+	if address == "" {
+		return "key", kind
+	}
+	return kind, address
+}
+
+func pledgeNodeSendCmd(c *cli.Context) error {
+	_, address := pledgeSignerFlags(c)
+	status, err := readPledgeStatus(c.String("node"), address)
+	if err != nil {
+		return err
+	}
+	if status.Phase != kernel.PledgePhaseAvailable {
+		return fmt.Errorf("unable to pledge: %s", status.Describe())
+	}
+
+	signed, err := buildPledgeTransaction(c)
+	if err != nil {
+		return err
+	}
+	raw := hex.EncodeToString(signed.Marshal())
+	if !c.Bool("send") {
+		fmt.Println(raw)
+		return nil
+	}
+	_, err = callRPC(c.String("node"), "sendrawtransaction", []any{raw}, c.Bool("time"))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("pledge transaction %s sent\n", signed.PayloadHash())
+	if !c.Bool("watch") {
+		return nil
+	}
+	return watchPledgeStatus(c.String("node"), address, c.Duration("interval"))
+}
+
+func pledgeStatusCmd(c *cli.Context) error {
+	if c.Bool("watch") {
+		return watchPledgeStatus(c.String("node"), c.String("signer"), c.Duration("interval"))
+	}
+	status, err := readPledgeStatus(c.String("node"), c.String("signer"))
+	if err != nil {
+		return err
+	}
+	fmt.Println(status.Describe())
+	return nil
+}
+
+type pledgeStatus struct {
+	Timestamp uint64 `json:"timestamp"`
+	Signer    string `json:"signer"`
+	Phase     string `json:"phase"`
+	NextAt    uint64 `json:"next_at"`
+	Node      *struct {
+		Id          crypto.Hash `json:"id"`
+		Transaction crypto.Hash `json:"transaction"`
+		Timestamp   uint64      `json:"timestamp"`
+	} `json:"node"`
+	Pledging     string `json:"pledging"`
+	AcceptAfter  uint64 `json:"accept_after"`
+	AcceptBefore uint64 `json:"accept_before"`
+	AcceptHourAt uint64 `json:"accept_hour_at"`
+}
+
+func readPledgeStatus(node, signer string) (*pledgeStatus, error) {
+	data, err := callRPC(node, "getpledgestatus", []any{signer, 0}, false)
+	if err != nil {
+		return nil, err
+	}
+	var status pledgeStatus
+	err = json.Unmarshal(data, &status)
+	return &status, err
+}
+
+// watchPledgeStatus prints the status whenever the phase changes, until the
+// node is ready or the pledge could not proceed anymore.
+func watchPledgeStatus(node, signer string, interval time.Duration) error {
+	var phase string
+	for {
+		status, err := readPledgeStatus(node, signer)
+		if err != nil {
+			return err
+		}
+		if status.Phase != phase {
+			fmt.Println(status.Describe())
+			phase = status.Phase
+		}
+		switch phase {
+		case kernel.PledgePhaseReady,
+			kernel.PledgePhaseExpired,
+			kernel.PledgePhaseCancelled,
+			kernel.PledgePhaseRemoved:
+			return nil
+		}
+		time.Sleep(interval)
+	}
+}
+
+func (s *pledgeStatus) Describe() string {
+	at := func(ts uint64) string {
+		t := time.Unix(0, int64(ts)).UTC()
+		in := time.Duration(ts - s.Timestamp).Round(time.Second)
+		if ts < s.Timestamp {
+			in = 0
+		}
+		return fmt.Sprintf("%s (in %s)", t.Format(time.RFC3339), in)
+	}
+	prefix := fmt.Sprintf("%s %s", time.Unix(0, int64(s.Timestamp)).UTC().Format(time.RFC3339), s.Phase)
+	switch s.Phase {
+	case kernel.PledgePhaseAvailable:
+		return prefix + ": the pledging slot is available for a new pledge"
+	case kernel.PledgePhaseWaiting:
+		if s.Pledging != "" {
+			return fmt.Sprintf("%s: the pledging slot is occupied by %s", prefix, s.Pledging)
+		}
+		if s.NextAt > 0 {
+			return fmt.Sprintf("%s: the next pledge is possible at %s", prefix, at(s.NextAt))
+		}
+		return prefix + ": the kernel nodes list is full"
+	case kernel.PledgePhasePledging:
+		return fmt.Sprintf("%s: node %s pledged by %s, the accept window opens at %s",
+			prefix, s.Node.Id, s.Node.Transaction, at(s.AcceptAfter))
+	case kernel.PledgePhaseAccepting:
+		return fmt.Sprintf("%s: node %s should send the accept transaction at %s, before %s",
+			prefix, s.Node.Id, at(s.AcceptHourAt), at(s.AcceptBefore))
+	case kernel.PledgePhaseExpired:
+		return fmt.Sprintf("%s: node %s missed the accept window, the pledge is locked", prefix, s.Node.Id)
+	case kernel.PledgePhaseAccepted:
+		return fmt.Sprintf("%s: node %s accepted, ready for consensus at %s", prefix, s.Node.Id, at(s.NextAt))
+	case kernel.PledgePhaseReady:
+		return fmt.Sprintf("%s: node %s is ready for consensus", prefix, s.Node.Id)
+	default:
+		return fmt.Sprintf("%s: node %s", prefix, s.Node.Id)
+	}
+}
+
+func nodeRemovalCmd(c *cli.Context) error {
+	var last string
+	for {
+		removal, err := readNodeRemoval(c.String("node"), c.String("signer"))
+		if err != nil {
+			return err
+		}
+		state := fmt.Sprintf("%s %t %s", removal.Phase, removal.Refund != nil, removal.Blocked)
+		if state != last {
+			fmt.Println(removal.Describe())
+			if c.Bool("raw") && removal.Transaction != nil {
+				fmt.Println(removal.Transaction.Raw)
+			}
+			last = state
+		}
+		if !c.Bool("watch") {
+			return nil
+		}
+		switch removal.Phase {
+		case kernel.RemovalPhaseUnavailable, kernel.RemovalPhaseRefunded:
+			return nil
+		case kernel.RemovalPhaseRemoved:
+			// the refund output is not available until the removal
+			// transaction finalized in the connected node
+			if removal.Refund != nil {
+				return nil
+			}
+		}
+		time.Sleep(c.Duration("interval"))
+	}
+}
+
+type nodeRemoval struct {
+	Timestamp uint64 `json:"timestamp"`
+	Signer    string `json:"signer"`
+	Phase     string `json:"phase"`
+	Node      *struct {
+		Id          crypto.Hash `json:"id"`
+		Payee       string      `json:"payee"`
+		Transaction crypto.Hash `json:"transaction"`
+	} `json:"node"`
+	Position    int    `json:"position"`
+	RemoveAt    uint64 `json:"remove_at"`
+	Blocked     string `json:"blocked"`
+	Transaction *struct {
+		Hash      crypto.Hash `json:"hash"`
+		Raw       string      `json:"raw"`
+		Finalized bool        `json:"finalized"`
+	} `json:"transaction"`
+	Refund *struct {
+		Hash      crypto.Hash    `json:"hash"`
+		Index     uint           `json:"index"`
+		Amount    common.Integer `json:"amount"`
+		Spendable bool           `json:"spendable"`
+		Lock      crypto.Hash    `json:"lock"`
+	} `json:"refund"`
+}
+
+func readNodeRemoval(node, signer string) (*nodeRemoval, error) {
+	data, err := callRPC(node, "getnoderemoval", []any{signer, 0}, false)
+	if err != nil {
+		return nil, err
+	}
+	var removal nodeRemoval
+	err = json.Unmarshal(data, &removal)
+	return &removal, err
+}
+
+func (r *nodeRemoval) Describe() string {
+	prefix := fmt.Sprintf("%s %s", time.Unix(0, int64(r.Timestamp)).UTC().Format(time.RFC3339), r.Phase)
+	switch r.Phase {
+	case kernel.RemovalPhaseUnavailable:
+		return prefix + ": no accepted node of the signer"
+	case kernel.RemovalPhaseQueued:
+		return fmt.Sprintf("%s: node %s waits for %d accepted nodes to be removed before", prefix, r.Node.Id, r.Position)
+	case kernel.RemovalPhaseNext:
+		at := time.Unix(0, int64(r.RemoveAt)).UTC().Format(time.RFC3339)
+		in := time.Duration(r.RemoveAt - r.Timestamp).Round(time.Second)
+		desc := fmt.Sprintf("%s: node %s is the next to remove by transaction %s at %s (in %s)",
+			prefix, r.Node.Id, r.Transaction.Hash, at, in)
+		if r.Blocked != "" {
+			desc = fmt.Sprintf("%s, blocked now by %s", desc, r.Blocked)
+		}
+		return desc
+	case kernel.RemovalPhaseRemoved:
+		if r.Refund == nil {
+			return fmt.Sprintf("%s: node %s removed by transaction %s, waiting for the refund output", prefix, r.Node.Id, r.Node.Transaction)
+		}
+		return fmt.Sprintf("%s: node %s removed, refund %s XIN spendable by payee %s at %s:%d",
+			prefix, r.Node.Id, r.Refund.Amount, r.Node.Payee, r.Refund.Hash, r.Refund.Index)
+	case kernel.RemovalPhaseRefunded:
+		return fmt.Sprintf("%s: node %s removed, refund %s:%d spent by %s",
+			prefix, r.Node.Id, r.Refund.Hash, r.Refund.Index, r.Refund.Lock)
+	default:
+		return fmt.Sprintf("%s: node %s", prefix, r.Node.Id)
+	}
+}
+
+func listElectionWarningsCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "listelectionwarnings", []any{}, c.Bool("time"))
+	if err == nil {
+		fmt.Println(string(data))
+	}
+	return err
+}
+
+func getSyncStatusCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "getsyncstatus", []any{}, c.Bool("time"))
+	if err == nil {
+		fmt.Println(string(data))
+	}
+	return err
+}
+
+func changeNodePayeeCmd(c *cli.Context) error {
+	seed := make([]byte, 64)
+	crypto.ReadRand(seed)
+	viewKey, err := crypto.KeyFromString(c.String("view"))
+	if err != nil {
+		return err
+	}
+	spendKey, err := crypto.KeyFromString(c.String("spend"))
+	if err != nil {
+		return err
+	}
+	signer, err := common.NewAddressFromString(c.String("signer"))
+	if err != nil {
+		return err
+	}
+	oldPayee, err := crypto.KeyFromString(c.String("old"))
+	if err != nil {
+		return err
+	}
+	newPayee, err := crypto.KeyFromString(c.String("new"))
+	if err != nil {
+		return err
+	}
+
+	var raw signerInput
+	input, err := crypto.HashFromString(c.String("input"))
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal([]byte(fmt.Sprintf(`{"inputs":[{"hash":"%s","index":0}]}`, input.String())), &raw)
+	if err != nil {
+		return err
+	}
+	raw.Node = c.String("node")
+
+	payee := common.Address{PublicSpendKey: newPayee.Public()}
+	payee.PrivateViewKey = payee.PublicSpendKey.DeterministicHashDerive()
+	payee.PublicViewKey = payee.PrivateViewKey.Public()
+	amount := common.NewIntegerFromString(c.String("amount"))
+
+	tx := common.NewTransactionV5(common.XINAssetId)
+	tx.AddInput(input, 0)
+	tx.AddOutputWithType(common.OutputTypeNodePayeeChange, []*common.Address{&payee}, common.NewThresholdScript(1), amount, seed)
+	change := &common.NodePayeeChange{
+		Signer:   signer.PublicSpendKey,
+		OldPayee: oldPayee.Public(),
+		NewPayee: newPayee.Public(),
+	}
+	msg := common.NodePayeeChangeMessage(change.Signer, change.OldPayee, change.NewPayee, tx.Inputs[0])
+	change.OldSignature = oldPayee.Sign(msg)
+	change.NewSignature = newPayee.Sign(msg)
+	tx.Extra = change.Extra()
+
+	signed := tx.AsVersioned()
+	err = signed.SignInput(raw, 0, []*common.Address{{PrivateViewKey: viewKey, PrivateSpendKey: spendKey}})
+	if err != nil {
+		return err
+	}
+	fmt.Println(hex.EncodeToString(signed.Marshal()))
+	return nil
+}
+
+func cancelNodeCmd(c *cli.Context) error {
+	seed := make([]byte, 64)
+	crypto.ReadRand(seed)
+	viewKey, err := crypto.KeyFromString(c.String("view"))
+	if err != nil {
+		return err
+	}
+	spendKey, err := crypto.KeyFromString(c.String("spend"))
+	if err != nil {
+		return err
+	}
+	receiver, err := common.NewAddressFromString(c.String("receiver"))
+	if err != nil {
+		return err
+	}
+	account := common.Address{
+		PrivateViewKey:  viewKey,
+		PrivateSpendKey: spendKey,
+		PublicViewKey:   viewKey.Public(),
+		PublicSpendKey:  spendKey.Public(),
+	}
+	if account.String() != receiver.String() {
+		return fmt.Errorf("invalid key and receiver %s %s", account, receiver)
+	}
+
+	b, err := hex.DecodeString(c.String("pledge"))
+	if err != nil {
+		return err
+	}
+	pledge, err := common.UnmarshalVersionedTransaction(b)
+	if err != nil {
+		return err
+	}
+	if pledge.TransactionType() != common.TransactionTypeNodePledge {
+		return fmt.Errorf("invalid pledge transaction type %d", pledge.TransactionType())
+	}
+
+	b, err = hex.DecodeString(c.String("source"))
+	if err != nil {
+		return err
+	}
+	source, err := common.UnmarshalVersionedTransaction(b)
+	if err != nil {
+		return err
+	}
+	if source.TransactionType() != common.TransactionTypeScript {
+		return fmt.Errorf("invalid source transaction type %d", source.TransactionType())
+	}
+
+	if source.PayloadHash() != pledge.Inputs[0].Hash {
+		return fmt.Errorf("invalid source transaction hash %s %s", source.PayloadHash(), pledge.Inputs[0].Hash)
+	}
+	if len(source.Outputs) != 1 || len(source.Outputs[0].Keys) != 1 {
+		return fmt.Errorf("invalid source transaction outputs %d %d", len(source.Outputs), len(source.Outputs[0].Keys))
+	}
+	pig := crypto.ViewGhostOutputKey(source.Outputs[0].Keys[0], &viewKey, &source.Outputs[0].Mask, 0)
+	if pig.String() != receiver.PublicSpendKey.String() {
+		return fmt.Errorf("invalid source and receiver %s %s", pig.String(), receiver.PublicSpendKey)
+	}
+
+	tx := common.NewTransactionV5(common.XINAssetId)
+	tx.AddInput(pledge.PayloadHash(), 0)
+	tx.AddOutputWithType(common.OutputTypeNodeCancel, nil, common.Script{}, pledge.Outputs[0].Amount.Div(100), seed)
+	tx.AddScriptOutput([]*common.Address{&receiver}, common.NewThresholdScript(1), pledge.Outputs[0].Amount.Sub(tx.Outputs[0].Amount), seed)
+	tx.Extra = append(pledge.Extra, viewKey[:]...)
+	utxo := &common.UTXO{
+		Input: common.Input{
+			Hash:  pledge.PayloadHash(),
+			Index: 0,
+		},
+		Output: common.Output{
+			Type: common.OutputTypeNodePledge,
+			Keys: source.Outputs[0].Keys,
+			Mask: source.Outputs[0].Mask,
+		},
+	}
+	signed := tx.AsVersioned()
+	err = signed.SignUTXO(utxo, []*common.Address{&account})
+	if err != nil {
+		return err
+	}
+	fmt.Println(hex.EncodeToString(signed.Marshal()))
+	return nil
+}
+
+func decodePledgeNodeCmd(c *cli.Context) error {
+	b, err := hex.DecodeString(c.String("raw"))
+	if err != nil {
+		return err
+	}
+	pledge, err := common.UnmarshalVersionedTransaction(b)
+	if err != nil {
+		return err
+	}
+	if len(pledge.Extra) != len(crypto.Key{})*2 {
+		return fmt.Errorf("invalid extra %s", hex.EncodeToString(pledge.Extra))
+	}
+	signerPublicSpend, err := crypto.KeyFromString(hex.EncodeToString(pledge.Extra[:32]))
+	if err != nil {
+		return err
 	}
 	payeePublicSpend, err := crypto.KeyFromString(hex.EncodeToString(pledge.Extra[32:]))
 	if err != nil {
 		return err
 	}
-	signer := common.Address{
-		PublicSpendKey: signerPublicSpend,
-		PublicViewKey:  signerPublicSpend.DeterministicHashDerive().Public(),
+	signer := common.Address{
+		PublicSpendKey: signerPublicSpend,
+		PublicViewKey:  signerPublicSpend.DeterministicHashDerive().Public(),
+	}
+	payee := common.Address{
+		PublicSpendKey: payeePublicSpend,
+		PublicViewKey:  payeePublicSpend.DeterministicHashDerive().Public(),
+	}
+	fmt.Printf("signer: %s\n", signer)
+	fmt.Printf("payee: %s\n", payee)
+	return nil
+}
+
+func encodeCustodianExtraCmd(c *cli.Context) error {
+	signerSpend, err := crypto.KeyFromString(c.String("signer"))
+	if err != nil {
+		return err
+	}
+	payeeSpend, err := crypto.KeyFromString(c.String("payee"))
+	if err != nil {
+		return err
+	}
+	custodianSpend, err := crypto.KeyFromString(c.String("custodian"))
+	if err != nil {
+		return err
+	}
+	networkId, err := crypto.HashFromString(c.String("network"))
+	if err != nil {
+		return err
+	}
+
+	custodian := &common.Address{
+		PublicSpendKey: custodianSpend.Public(),
+		PublicViewKey:  custodianSpend.Public().DeterministicHashDerive().Public(),
+	}
+	payee := &common.Address{
+		PublicSpendKey: payeeSpend.Public(),
+		PublicViewKey:  payeeSpend.Public().DeterministicHashDerive().Public(),
+	}
+	extra := common.EncodeCustodianNode(custodian, payee, &signerSpend, &payeeSpend, &custodianSpend, networkId)
+	fmt.Printf("HEX: %x\n", extra)
+	fmt.Printf("BASE64: %s\n", base64.RawURLEncoding.EncodeToString(extra))
+	return nil
+}
+
+func getTopologyByTimestampCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "gettopologybytimestamp", []any{
+		c.Uint64("timestamp"),
+	}, c.Bool("time"))
+	if err == nil {
+		fmt.Println(string(data))
+	}
+	return err
+}
+
+func getRoundLinkCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "getroundlink", []any{
+		c.String("from"),
+		c.String("to"),
+	}, c.Bool("time"))
+	if err == nil {
+		fmt.Println(string(data))
+	}
+	return err
+}
+
+func getRoundByNumberCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "getroundbynumber", []any{
+		c.String("id"),
+		c.Uint64("number"),
+	}, c.Bool("time"))
+	if err == nil {
+		fmt.Println(string(data))
+	}
+	return err
+}
+
+func getRoundByHashCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "getroundbyhash", []any{
+		c.String("hash"),
+	}, c.Bool("time"))
+	if err == nil {
+		fmt.Println(string(data))
+	}
+	return err
+}
+
+func listSnapshotsCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "listsnapshots", []any{
+		c.Uint64("since"),
+		c.Uint64("count"),
+		c.Bool("sig"),
+		c.Bool("tx"),
+	}, c.Bool("time"))
+	if err == nil {
+		fmt.Println(string(data))
+	}
+	return err
+}
+
+func getSnapshotCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "getsnapshot", []any{
+		c.String("hash"),
+	}, c.Bool("time"))
+	if err == nil {
+		fmt.Println(string(data))
+	}
+	return err
+}
+
+func getFinalityProofCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "getfinalityproof", []any{
+		c.String("hash"),
+	}, c.Bool("time"))
+	if err == nil {
+		fmt.Println(string(data))
+	}
+	return err
+}
+
+func getTransactionCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "gettransaction", []any{
+		c.String("hash"),
+	}, c.Bool("time"))
+	if err == nil {
+		fmt.Println(string(data))
+	}
+	return err
+}
+
+func getTransactionsCmd(c *cli.Context) error {
+	var hashes []any
+	for _, h := range c.StringSlice("hash") {
+		hashes = append(hashes, h)
+	}
+	data, err := callRPC(c.String("node"), "gettransactions", hashes, c.Bool("time"))
+	if err == nil {
+		fmt.Println(string(data))
+	}
+	return err
+}
+
+func getCacheTransactionCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "getcachetransaction", []any{
+		c.String("hash"),
+	}, c.Bool("time"))
+	if err == nil {
+		fmt.Println(string(data))
+	}
+	return err
+}
+
+func purgeCacheTransactionsCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "purgecachetransactions", []any{
+		c.Uint64("age"),
+		c.String("prefix"),
+	}, c.Bool("time"))
+	if err == nil {
+		fmt.Println(string(data))
+	}
+	return err
+}
+
+func getDepositTransactionCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "getdeposittransaction", []any{
+		c.String("chain"),
+		c.String("hash"),
+		c.Int("index"),
+	}, c.Bool("time"))
+	if err == nil {
+		fmt.Println(string(data))
+	}
+	return err
+}
+
+func getWithdrawalClaimCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "getwithdrawalclaim", []any{
+		c.String("hash"),
+	}, c.Bool("time"))
+	if err == nil {
+		fmt.Println(string(data))
+	}
+	return err
+}
+
+func listWithdrawalClaimsCmd(c *cli.Context) error {
+	var params []any
+	if fees := c.StringSlice("fee"); len(fees) > 0 {
+		m := make(map[string]any)
+		for _, f := range fees {
+			parts := strings.Split(f, ":")
+			if len(parts) != 2 {
+				return fmt.Errorf("invalid claim fee %s", f)
+			}
+			m[parts[0]] = parts[1]
+		}
+		params = append(params, m)
+	}
+	data, err := callRPC(c.String("node"), "listwithdrawalclaims", params, c.Bool("time"))
+	if err == nil {
+		fmt.Println(string(data))
+	}
+	return err
+}
+
+func buildWithdrawalClaimsCmd(c *cli.Context) error {
+	var inputs, claims []any
+	for _, in := range c.StringSlice("input") {
+		inputs = append(inputs, in)
+	}
+	for _, cl := range c.StringSlice("claim") {
+		parts := strings.Split(cl, ":")
+		if len(parts) != 3 {
+			return fmt.Errorf("invalid claim %s", cl)
+		}
+		claims = append(claims, map[string]any{
+			"submission": parts[0],
+			"fee":        parts[1],
+			"info":       parts[2],
+		})
+	}
+	data, err := callRPC(c.String("node"), "buildwithdrawalclaims", []any{
+		c.String("receiver"),
+		c.String("change"),
+		inputs,
+		claims,
+	}, c.Bool("time"))
+	if err == nil {
+		fmt.Println(string(data))
+	}
+	return err
+}
+
+func getUTXOCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "getutxo", []any{
+		c.String("hash"),
+		c.Uint64("index"),
+	}, c.Bool("time"))
+	if err == nil {
+		fmt.Println(string(data))
+	}
+	return err
+}
+
+func proveOutputOwnershipCmd(c *cli.Context) error {
+	view, err := crypto.KeyFromString(c.String("view"))
+	if err != nil {
+		return err
+	}
+	spend, err := crypto.KeyFromString(c.String("spend"))
+	if err != nil {
+		return err
 	}
-	payee := common.Address{
-		PublicSpendKey: payeePublicSpend,
-		PublicViewKey:  payeePublicSpend.DeterministicHashDerive().Public(),
+	hash, err := crypto.HashFromString(c.String("hash"))
+	if err != nil {
+		return err
 	}
-	fmt.Printf("signer: %s\n", signer)
-	fmt.Printf("payee: %s\n", payee)
+	challenge, err := hex.DecodeString(c.String("challenge"))
+	if err != nil {
+		return err
+	}
+	addr := &common.Address{
+		PrivateViewKey:  view,
+		PrivateSpendKey: spend,
+		PublicViewKey:   view.Public(),
+		PublicSpendKey:  spend.Public(),
+	}
+
+	index := uint(c.Uint64("index"))
+	utxo, err := readWatchOnlyUTXO(c.String("node"), hash, index)
+	if err != nil {
+		return err
+	}
+	out := &common.Output{Keys: utxo.Keys, Mask: utxo.Mask}
+	o, err := common.NewOutputOwnership(addr, hash, index, out, challenge)
+	if err != nil {
+		return err
+	}
+	fmt.Println(hex.EncodeToString(o.Marshal()))
 	return nil
 }
 
-func encodeCustodianExtraCmd(c *cli.Context) error {
-	signerSpend, err := crypto.KeyFromString(c.String("signer"))
+func verifyOutputOwnershipCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "verifyoutputownership", []any{
+		c.String("proof"),
+	}, c.Bool("time"))
+	if err == nil {
+		fmt.Println(string(data))
+	}
+	return err
+}
+
+func getKeyCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "getkey", []any{
+		c.String("key"),
+	}, c.Bool("time"))
+	if err == nil {
+		fmt.Println(string(data))
+	}
+	return err
+}
+
+func getAssetCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "getasset", []any{
+		c.String("id"),
+	}, c.Bool("time"))
+	if err == nil {
+		fmt.Println(string(data))
+	}
+	return err
+}
+
+func listCustodianUpdatesCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "listcustodianupdates", []any{}, c.Bool("time"))
+	if err == nil {
+		fmt.Println(string(data))
+	}
+	return err
+}
+
+func getCustodianCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "getcustodian", []any{}, c.Bool("time"))
+	if err == nil {
+		fmt.Println(string(data))
+	}
+	return err
+}
+
+func listPendingCustodianUpdatesCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "listpendingcustodianupdates", []any{}, c.Bool("time"))
+	if err == nil {
+		fmt.Println(string(data))
+	}
+	return err
+}
+
+func listAssetFlowsCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "listassetflows", []any{}, c.Bool("time"))
+	if err == nil {
+		fmt.Println(string(data))
+	}
+	return err
+}
+
+func listStateCheckpointsCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "liststatecheckpoints", []any{
+		c.Uint64("limit"),
+	}, c.Bool("time"))
+	if err == nil {
+		fmt.Println(string(data))
+	}
+	return err
+}
+
+func listCustodianBalancesCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "listcustodianbalances", []any{}, c.Bool("time"))
+	if err == nil {
+		fmt.Println(string(data))
+	}
+	return err
+}
+
+func proposeCustodianUpdateCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "proposecustodianupdate", []any{
+		c.String("custodian"),
+		c.String("extra"),
+	}, c.Bool("time"))
+	if err == nil {
+		fmt.Println(string(data))
+	}
+	return err
+}
+
+func listCustodianProposalsCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "listcustodianproposals", []any{}, c.Bool("time"))
+	if err == nil {
+		fmt.Println(string(data))
+	}
+	return err
+}
+
+func signCustodianProposalCmd(c *cli.Context) error {
+	key, err := crypto.KeyFromString(c.String("key"))
 	if err != nil {
 		return err
 	}
-	payeeSpend, err := crypto.KeyFromString(c.String("payee"))
+	extra, err := hex.DecodeString(c.String("extra"))
+	if err != nil {
+		return err
+	}
+	var sig crypto.Signature
+	_, err = common.ParseCustodianUpdateNodesExtra(append(extra, sig[:]...), false)
+	if err != nil {
+		return err
+	}
+	sig = key.Sign(crypto.Blake3Hash(extra))
+	fmt.Println(sig.String())
+	return nil
+}
+
+func buildCustodianUpdateCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "buildcustodianupdate", []any{
+		c.String("custodian"),
+		c.String("approval"),
+		c.String("receiver"),
+		c.String("input"),
+		c.Uint("index"),
+	}, c.Bool("time"))
+	if err == nil {
+		fmt.Println(string(data))
+	}
+	return err
+}
+
+func listMintWorksCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "listmintworks", []any{
+		c.Uint64("since"),
+	}, c.Bool("time"))
+	if err == nil {
+		fmt.Println(string(data))
+	}
+	return err
+}
+
+func getMintForecastCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "getmintforecast", []any{}, c.Bool("time"))
+	if err == nil {
+		fmt.Println(string(data))
+	}
+	return err
+}
+
+func listMintDistributionsCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "listmintdistributions", []any{
+		c.Uint64("since"),
+		c.Uint64("count"),
+		c.Bool("tx"),
+	}, c.Bool("time"))
+	if err == nil {
+		fmt.Println(string(data))
+	}
+	return err
+}
+
+func listAllNodesCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "listallnodes", []any{
+		c.Uint64("threshold"),
+		c.Bool("state"),
+	}, c.Bool("time"))
+	if err == nil {
+		fmt.Println(string(data))
+	}
+	return err
+}
+
+func listConsensusNodesCmd(c *cli.Context) error {
+	var timestamp uint64
+	if at := c.String("at"); at != "" {
+		ts, err := strconv.ParseUint(at, 10, 64)
+		if err != nil {
+			t, err := time.Parse(time.RFC3339, at)
+			if err != nil {
+				return fmt.Errorf("invalid time %s, RFC3339 or Unix nanoseconds", at)
+			}
+			ts = uint64(t.UnixNano())
+		}
+		timestamp = ts
+	}
+	data, err := callRPC(c.String("node"), "listconsensusnodes", []any{
+		timestamp,
+	}, c.Bool("time"))
+	if err == nil {
+		fmt.Println(string(data))
+	}
+	return err
+}
+
+type consensusNodesDump struct {
+	Network   string               `json:"network"`
+	Threshold uint64               `json:"threshold"`
+	Nodes     []*consensusNodeDump `json:"nodes"`
+}
+
+type consensusNodeDump struct {
+	Id          string `json:"id"`
+	Signer      string `json:"signer"`
+	Payee       string `json:"payee"`
+	Transaction string `json:"transaction"`
+	Timestamp   uint64 `json:"timestamp"`
+	State       string `json:"state"`
+}
+
+func readConsensusNodesDump(node string, threshold uint64) (*consensusNodesDump, error) {
+	data, err := callRPC(node, "getinfo", []any{}, false)
+	if err != nil {
+		return nil, err
+	}
+	var info struct {
+		Network string `json:"network"`
+	}
+	err = json.Unmarshal(data, &info)
+	if err != nil {
+		return nil, err
+	}
+	data, err = callRPC(node, "listallnodes", []any{threshold, false}, false)
+	if err != nil {
+		return nil, err
+	}
+	dump := &consensusNodesDump{Network: info.Network, Threshold: threshold}
+	err = json.Unmarshal(data, &dump.Nodes)
+	return dump, err
+}
+
+func exportNodesCmd(c *cli.Context) error {
+	threshold := c.Uint64("threshold")
+	if threshold == 0 {
+		threshold = uint64(time.Now().UnixNano())
+	}
+	dump, err := readConsensusNodesDump(c.String("node"), threshold)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(dump, "", "  ")
+	if err != nil {
+		return err
+	}
+	if out := c.String("out"); out != "" {
+		return os.WriteFile(out, data, 0644)
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+func verifyNodesCmd(c *cli.Context) error {
+	data, err := os.ReadFile(c.String("file"))
 	if err != nil {
 		return err
 	}
-	custodianSpend, err := crypto.KeyFromString(c.String("custodian"))
+	var dump consensusNodesDump
+	err = json.Unmarshal(data, &dump)
 	if err != nil {
 		return err
 	}
-	networkId, err := crypto.HashFromString(c.String("network"))
+	remote, err := readConsensusNodesDump(c.String("node"), dump.Threshold)
 	if err != nil {
 		return err
 	}
-
-	custodian := &common.Address{
-		PublicSpendKey: custodianSpend.Public(),
-		PublicViewKey:  custodianSpend.Public().DeterministicHashDerive().Public(),
+	if remote.Network != dump.Network {
+		return fmt.Errorf("network mismatch %s %s", dump.Network, remote.Network)
+	}
+
+	nodes := make(map[string]*consensusNodeDump)
+	for _, n := range remote.Nodes {
+		nodes[n.Id] = n
+	}
+	var diffs int
+	for _, n := range dump.Nodes {
+		r := nodes[n.Id]
+		delete(nodes, n.Id)
+		switch {
+		case r == nil:
+			fmt.Printf("missing %s %s %d\n", n.Id, n.State, n.Timestamp)
+		case *r != *n:
+			fmt.Printf("mismatch %s %s %d %s => %s %d %s\n", n.Id,
+				n.State, n.Timestamp, n.Transaction, r.State, r.Timestamp, r.Transaction)
+		default:
+			continue
+		}
+		diffs += 1
 	}
-	payee := &common.Address{
-		PublicSpendKey: payeeSpend.Public(),
-		PublicViewKey:  payeeSpend.Public().DeterministicHashDerive().Public(),
+	for _, r := range remote.Nodes {
+		if nodes[r.Id] != nil {
+			fmt.Printf("extra %s %s %d\n", r.Id, r.State, r.Timestamp)
+			diffs += 1
+		}
 	}
-	extra := common.EncodeCustodianNode(custodian, payee, &signerSpend, &payeeSpend, &custodianSpend, networkId)
-	fmt.Printf("HEX: %x\n", extra)
-	fmt.Printf("BASE64: %s\n", base64.RawURLEncoding.EncodeToString(extra))
+	if diffs > 0 {
+		return fmt.Errorf("%d differences of %d nodes at %d", diffs, len(dump.Nodes), dump.Threshold)
+	}
+	fmt.Printf("all %d nodes match at %d\n", len(dump.Nodes), dump.Threshold)
 	return nil
 }
 
-func getRoundLinkCmd(c *cli.Context) error {
-	data, err := callRPC(c.String("node"), "getroundlink", []any{
-		c.String("from"),
-		c.String("to"),
+func buildSweepTransactionCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "buildsweeptransaction", []any{
+		c.String("address"),
+		c.String("view"),
+		c.String("asset"),
+		c.String("destination"),
+		c.Uint64("since"),
+		c.Uint64("count"),
+		c.Uint64("split-count"),
+		c.String("split-size"),
 	}, c.Bool("time"))
 	if err == nil {
 		fmt.Println(string(data))
@@ -667,33 +3056,27 @@ func getRoundLinkCmd(c *cli.Context) error {
 	return err
 }
 
-func getRoundByNumberCmd(c *cli.Context) error {
-	data, err := callRPC(c.String("node"), "getroundbynumber", []any{
-		c.String("id"),
-		c.Uint64("number"),
-	}, c.Bool("time"))
+func writeSupportBundleCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "writesupportbundle", []any{}, c.Bool("time"))
 	if err == nil {
 		fmt.Println(string(data))
 	}
 	return err
 }
 
-func getRoundByHashCmd(c *cli.Context) error {
-	data, err := callRPC(c.String("node"), "getroundbyhash", []any{
-		c.String("hash"),
-	}, c.Bool("time"))
+func listChainStatsCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "listchainstats", []any{}, c.Bool("time"))
 	if err == nil {
 		fmt.Println(string(data))
 	}
 	return err
 }
 
-func listSnapshotsCmd(c *cli.Context) error {
-	data, err := callRPC(c.String("node"), "listsnapshots", []any{
+func listAuditEntriesCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "listauditentries", []any{
 		c.Uint64("since"),
 		c.Uint64("count"),
-		c.Bool("sig"),
-		c.Bool("tx"),
+		c.String("action"),
 	}, c.Bool("time"))
 	if err == nil {
 		fmt.Println(string(data))
@@ -701,9 +3084,10 @@ func listSnapshotsCmd(c *cli.Context) error {
 	return err
 }
 
-func getSnapshotCmd(c *cli.Context) error {
-	data, err := callRPC(c.String("node"), "getsnapshot", []any{
-		c.String("hash"),
+func registerWalletAccountCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "registerwalletaccount", []any{
+		c.String("address"),
+		c.String("view"),
 	}, c.Bool("time"))
 	if err == nil {
 		fmt.Println(string(data))
@@ -711,9 +3095,14 @@ func getSnapshotCmd(c *cli.Context) error {
 	return err
 }
 
-func getTransactionCmd(c *cli.Context) error {
-	data, err := callRPC(c.String("node"), "gettransaction", []any{
-		c.String("hash"),
+func registerWebhookCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "registerwebhook", []any{
+		c.String("url"),
+		c.String("asset"),
+		c.String("address"),
+		c.String("view"),
+		c.String("extra"),
+		c.Uint64("confirmations"),
 	}, c.Bool("time"))
 	if err == nil {
 		fmt.Println(string(data))
@@ -721,21 +3110,27 @@ func getTransactionCmd(c *cli.Context) error {
 	return err
 }
 
-func getCacheTransactionCmd(c *cli.Context) error {
-	data, err := callRPC(c.String("node"), "getcachetransaction", []any{
-		c.String("hash"),
-	}, c.Bool("time"))
+func listWebhooksCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "listwebhooks", []any{}, c.Bool("time"))
 	if err == nil {
 		fmt.Println(string(data))
 	}
 	return err
 }
 
-func getDepositTransactionCmd(c *cli.Context) error {
-	data, err := callRPC(c.String("node"), "getdeposittransaction", []any{
-		c.String("chain"),
-		c.String("hash"),
-		c.Int("index"),
+func removeWebhookCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "removewebhook", []any{c.String("id")}, c.Bool("time"))
+	if err == nil {
+		fmt.Println(string(data))
+	}
+	return err
+}
+
+func listWalletOutputsCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "listwalletoutputs", []any{
+		c.String("address"),
+		c.String("asset"),
+		c.Bool("spent"),
 	}, c.Bool("time"))
 	if err == nil {
 		fmt.Println(string(data))
@@ -743,9 +3138,11 @@ func getDepositTransactionCmd(c *cli.Context) error {
 	return err
 }
 
-func getWithdrawalClaimCmd(c *cli.Context) error {
-	data, err := callRPC(c.String("node"), "getwithdrawalclaim", []any{
-		c.String("hash"),
+func registerWalletSequenceCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "registerwalletsequence", []any{
+		c.String("address"),
+		c.String("view"),
+		c.Uint64("gap"),
 	}, c.Bool("time"))
 	if err == nil {
 		fmt.Println(string(data))
@@ -753,10 +3150,11 @@ func getWithdrawalClaimCmd(c *cli.Context) error {
 	return err
 }
 
-func getUTXOCmd(c *cli.Context) error {
-	data, err := callRPC(c.String("node"), "getutxo", []any{
-		c.String("hash"),
-		c.Uint64("index"),
+func registerWalletSubaddressCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "registerwalletsubaddress", []any{
+		c.String("address"),
+		c.String("view"),
+		c.String("payment"),
 	}, c.Bool("time"))
 	if err == nil {
 		fmt.Println(string(data))
@@ -764,9 +3162,9 @@ func getUTXOCmd(c *cli.Context) error {
 	return err
 }
 
-func getKeyCmd(c *cli.Context) error {
-	data, err := callRPC(c.String("node"), "getkey", []any{
-		c.String("key"),
+func getWalletSequenceCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "getwalletsequence", []any{
+		c.String("address"),
 	}, c.Bool("time"))
 	if err == nil {
 		fmt.Println(string(data))
@@ -774,9 +3172,9 @@ func getKeyCmd(c *cli.Context) error {
 	return err
 }
 
-func getAssetCmd(c *cli.Context) error {
-	data, err := callRPC(c.String("node"), "getasset", []any{
-		c.String("id"),
+func getWalletBalanceCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "getwalletbalance", []any{
+		c.String("address"),
 	}, c.Bool("time"))
 	if err == nil {
 		fmt.Println(string(data))
@@ -784,63 +3182,76 @@ func getAssetCmd(c *cli.Context) error {
 	return err
 }
 
-func listCustodianUpdatesCmd(c *cli.Context) error {
-	data, err := callRPC(c.String("node"), "listcustodianupdates", []any{}, c.Bool("time"))
+func getInfoCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "getinfo", []any{}, c.Bool("time"))
 	if err == nil {
 		fmt.Println(string(data))
 	}
 	return err
 }
 
-func listMintWorksCmd(c *cli.Context) error {
-	data, err := callRPC(c.String("node"), "listmintworks", []any{
-		c.Uint64("since"),
-	}, c.Bool("time"))
+func listPeersCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "listpeers", []any{}, c.Bool("time"))
 	if err == nil {
 		fmt.Println(string(data))
 	}
 	return err
 }
 
-func listMintDistributionsCmd(c *cli.Context) error {
-	data, err := callRPC(c.String("node"), "listmintdistributions", []any{
-		c.Uint64("since"),
-		c.Uint64("count"),
-		c.Bool("tx"),
-	}, c.Bool("time"))
+func setNodeRoleCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "setnoderole", []any{c.String("role")}, c.Bool("time"))
 	if err == nil {
 		fmt.Println(string(data))
 	}
 	return err
 }
 
-func listAllNodesCmd(c *cli.Context) error {
-	data, err := callRPC(c.String("node"), "listallnodes", []any{
-		c.Uint64("threshold"),
-		c.Bool("state"),
-	}, c.Bool("time"))
+func listLogLevelsCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "listloglevels", []any{}, c.Bool("time"))
 	if err == nil {
 		fmt.Println(string(data))
 	}
 	return err
 }
 
-func getInfoCmd(c *cli.Context) error {
-	data, err := callRPC(c.String("node"), "getinfo", []any{}, c.Bool("time"))
+func setLogLevelCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "setloglevel", []any{c.String("module"), c.Int("level")}, c.Bool("time"))
 	if err == nil {
 		fmt.Println(string(data))
 	}
 	return err
 }
 
-func listPeersCmd(c *cli.Context) error {
-	data, err := callRPC(c.String("node"), "listpeers", []any{}, c.Bool("time"))
+func setDiagnosticsCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "setdiagnostics", []any{c.Bool("enable")}, c.Bool("time"))
 	if err == nil {
 		fmt.Println(string(data))
 	}
 	return err
 }
 
+// setLogModules parses the module levels list, e.g. kernel=3,p2p=7
+func setLogModules(modules string) error {
+	if modules == "" {
+		return nil
+	}
+	for _, ml := range strings.Split(modules, ",") {
+		module, l, found := strings.Cut(strings.TrimSpace(ml), "=")
+		if !found {
+			return fmt.Errorf("invalid log module level %s", ml)
+		}
+		level, err := strconv.Atoi(l)
+		if err != nil {
+			return fmt.Errorf("invalid log module level %s", ml)
+		}
+		err = logger.SetModuleLevel(module, level)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func listRelayersCmd(c *cli.Context) error {
 	data, err := callRPC(c.String("node"), "listrelayers", []any{c.String("id")}, c.Bool("time"))
 	if err == nil {
@@ -949,6 +3360,394 @@ object-server = true
 	return nil
 }
 
+const devnetConfigTmpl = `[node]
+signer-key = "%s"
+kernel-operation-period = 3
+memory-cache-size = 64
+cache-ttl = 3600
+[storage]
+value-log-gc = true
+[p2p]
+port = %d
+seeds = [%s]
+relayer = true
+[rpc]
+port = %d
+`
+
+func devnetCmd(c *cli.Context) error {
+	count, minimum := c.Int("nodes"), config.KernelMinimumNodesCount
+	if c.Bool("dev") {
+		minimum = 1
+		if !c.IsSet("nodes") {
+			count = 1
+		}
+	}
+	if count < minimum {
+		return fmt.Errorf("invalid devnet nodes count %d, at least %d", count, minimum)
+	}
+	gap := time.Duration(c.Int64("gap")) * time.Millisecond
+	if gap < 100*time.Millisecond || uint64(gap) > config.SnapshotRoundGapDefault {
+		return fmt.Errorf("invalid devnet round gap %s", gap)
+	}
+
+	err := os.Setenv("QUIC_GO_DISABLE_GSO", "true")
+	if err != nil {
+		return err
+	}
+	logger.SetLevel(c.Int("log"))
+
+	root := c.String("dir")
+	_, err = os.Stat(root + "/genesis.json")
+	if os.IsNotExist(err) {
+		err = setupDevnet(root, count, c.Int("port"), gap, c.Bool("dev"))
+	}
+	if err != nil {
+		return err
+	}
+	gns, err := common.ReadGenesis(root + "/genesis.json")
+	if err != nil {
+		return err
+	}
+	if len(gns.Nodes) != count || gns.Dev != c.Bool("dev") {
+		return fmt.Errorf("devnet at %s has %d nodes with dev %t", root, len(gns.Nodes), gns.Dev)
+	}
+	params, err := gns.ConsensusParams()
+	if err != nil {
+		return err
+	}
+	if c.IsSet("gap") && params.RoundGap != uint64(gap) {
+		return fmt.Errorf("devnet at %s has round gap %s", root, time.Duration(params.RoundGap))
+	}
+	err = kernel.ApplyConsensusParams(gns)
+	if err != nil {
+		return err
+	}
+
+	errs := make(chan error, count)
+	for i := range count {
+		dir := fmt.Sprintf("%s/node-%02d", root, i+1)
+		custom, err := config.Initialize(dir + "/config.toml")
+		if err != nil {
+			return err
+		}
+		cache, err := newCache(custom)
+		if err != nil {
+			return err
+		}
+		store, err := storage.NewBadgerStore(custom, dir)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		node, err := kernel.SetupNode(custom, store, cache, gns)
+		if err != nil {
+			return err
+		}
+		server := rpc.NewServer(custom, store, node, custom.RPC.Port)
+		go server.ListenAndServe()
+		go func() { errs <- node.Loop() }()
+		fmt.Printf("node#%d\t%s\thttp://127.0.0.1:%d\n", i+1, node.IdForNetwork, custom.RPC.Port)
+	}
+	fmt.Printf("network:\t%s\n", gns.NetworkId())
+	fmt.Printf("round gap:\t%s\n", time.Duration(params.RoundGap))
+	return <-errs
+}
+
+// setupDevnet generates the genesis, and the signer keys and configs for all
+// the nodes of the devnet, they listen on the loopback ports from the base.
+// The dev genesis is never valid for the mainnet, and a single node of it
+// finalizes the snapshots alone. The round gap is declared in the genesis
+// params, so it is committed in the network id.
+func setupDevnet(root string, count, port int, gap time.Duration, dev bool) error {
+	accounts := make([]common.Address, count)
+	inputs := make([]map[string]string, count)
+	for i := range accounts {
+		signer := newDevnetAccount()
+		accounts[i] = signer
+		inputs[i] = map[string]string{
+			"signer":    signer.String(),
+			"payee":     newDevnetAccount().String(),
+			"custodian": newDevnetAccount().String(),
+			"balance":   "13439",
+		}
+	}
+	custodian := newDevnetAccount()
+	genesisData, err := json.MarshalIndent(map[string]any{
+		"epoch":     time.Now().Unix(),
+		"nodes":     inputs,
+		"custodian": custodian,
+		"dev":       dev,
+		"params":    map[string]any{"round_gap": gap.String()},
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	var gns common.Genesis
+	err = json.Unmarshal(genesisData, &gns)
+	if err != nil {
+		return err
+	}
+
+	peers := make([]string, count)
+	for i, a := range accounts {
+		id := a.Hash().ForNetwork(gns.NetworkId())
+		peers[i] = fmt.Sprintf(`"%s@127.0.0.1:%d"`, id, port+i+1)
+	}
+	for i, a := range accounts {
+		dir := fmt.Sprintf("%s/node-%02d", root, i+1)
+		err := os.MkdirAll(dir, 0700)
+		if err != nil {
+			return err
+		}
+		configData := fmt.Sprintf(devnetConfigTmpl, a.PrivateSpendKey, port+i+1,
+			strings.Join(peers, ","), port+1000+i+1)
+		err = os.WriteFile(dir+"/config.toml", []byte(configData), 0600)
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("custodian:\t%s\n", custodian.String())
+	fmt.Printf("view key:\t%s\n", custodian.PrivateViewKey.String())
+	fmt.Printf("spend key:\t%s\n", custodian.PrivateSpendKey.String())
+	return os.WriteFile(root+"/genesis.json", genesisData, 0644)
+}
+
+func newDevnetAccount() common.Address {
+	seed := make([]byte, 64)
+	crypto.ReadRand(seed)
+	account := common.NewAddressFromSeed(seed)
+	account.PrivateViewKey = account.PublicSpendKey.DeterministicHashDerive()
+	account.PublicViewKey = account.PrivateViewKey.Public()
+	return account
+}
+
+// benchSendCmd deposits to a new account by the devnet custodian, splits the
+// deposit to the outputs, then transfers each output to the account itself
+// at the rate, and a finalized transfer makes its output available again.
+// The outputs limit the transfers in flight, so the rate is not reached if
+// the latency is longer than the outputs divided by the rate.
+func benchSendCmd(c *cli.Context) error {
+	node := c.String("node")
+	custodian, err := parseCustodianKey(c.String("custodian"))
+	if err != nil {
+		return err
+	}
+	rate, count := c.Int("rate"), c.Int("outputs")
+	if rate < 1 {
+		return fmt.Errorf("invalid bench rate %d", rate)
+	}
+	if count < 1 || count > common.SliceCountLimit {
+		return fmt.Errorf("invalid bench outputs %d", count)
+	}
+	duration, timeout := c.Duration("duration"), c.Duration("timeout")
+
+	account := newDevnetAccount()
+	hash := crypto.Blake3Hash([]byte(account.String()))
+	amount := common.NewInteger(uint64(count))
+	deposit := common.NewTransactionV5(common.XINAssetId)
+	deposit.AddDepositInput(&common.DepositData{
+		Chain:       common.XINAsset.Chain,
+		AssetKey:    common.XINAsset.AssetKey,
+		Transaction: "0x" + hash.String(),
+		Index:       0,
+		Amount:      amount,
+	})
+	seed := make([]byte, 64)
+	crypto.ReadRand(seed)
+	deposit.AddScriptOutput([]*common.Address{&account}, common.NewThresholdScript(1), amount, seed)
+	ver := deposit.AsVersioned()
+	err = ver.SignInput(nil, 0, []*common.Address{custodian})
+	if err != nil {
+		return err
+	}
+	err = sendBenchTransaction(node, ver, timeout)
+	if err != nil {
+		return err
+	}
+	outputs := benchOutputs(ver)
+	ver, err = buildBenchTransfer(&account, outputs[0], count)
+	if err != nil {
+		return err
+	}
+	err = sendBenchTransaction(node, ver, timeout)
+	if err != nil {
+		return err
+	}
+	idle := benchOutputs(ver)
+	fmt.Printf("account:\t%s\n", account.String())
+	fmt.Printf("outputs:\t%d\n", len(idle))
+
+	type transfer struct {
+		output *benchOutput
+		sent   time.Time
+	}
+	pending := make(map[crypto.Hash]*transfer)
+	var latencies []time.Duration
+	var submitted, failed, starved int
+	send := time.NewTicker(time.Second / time.Duration(rate))
+	defer send.Stop()
+	poll := time.NewTicker(100 * time.Millisecond)
+	defer poll.Stop()
+	start := time.Now()
+	end, drain := start.Add(duration), start.Add(duration+timeout)
+	for now := start; now.Before(drain) && (now.Before(end) || len(pending) > 0); now = time.Now() {
+		select {
+		case now := <-send.C:
+			if now.After(end) {
+				continue
+			}
+			// the ticks dropped by the slow calls are all sent at the next tick
+			due := int(now.Sub(start).Seconds()*float64(rate)) - submitted - failed - starved
+			for range due {
+				if len(idle) == 0 {
+					starved += 1
+					continue
+				}
+				in := idle[0]
+				ver, err := buildBenchTransfer(&account, in, 1)
+				if err != nil {
+					return err
+				}
+				raw := hex.EncodeToString(ver.Marshal())
+				_, err = callRPC(node, "sendrawtransaction", []any{raw}, false)
+				if err != nil {
+					failed += 1
+					continue
+				}
+				idle = idle[1:]
+				submitted += 1
+				pending[ver.PayloadHash()] = &transfer{benchOutputs(ver)[0], time.Now()}
+			}
+		case <-poll.C:
+			var hashes []any
+			for h := range pending {
+				if len(hashes) < storage.TransactionsReadBatchLimit {
+					hashes = append(hashes, h.String())
+				}
+			}
+			if len(hashes) == 0 {
+				continue
+			}
+			data, err := callRPC(node, "gettransactions", hashes, false)
+			if err != nil {
+				return err
+			}
+			var txs []struct {
+				Snapshot string `json:"snapshot"`
+			}
+			err = json.Unmarshal(data, &txs)
+			if err != nil || len(txs) != len(hashes) {
+				return fmt.Errorf("invalid gettransactions response %s %v", string(data), err)
+			}
+			for i, tx := range txs {
+				if tx.Snapshot == "" {
+					continue
+				}
+				h, _ := crypto.HashFromString(hashes[i].(string))
+				t := pending[h]
+				delete(pending, h)
+				latencies = append(latencies, time.Since(t.sent))
+				idle = append(idle, t.output)
+			}
+		}
+	}
+
+	elapsed := time.Since(start)
+	fmt.Printf("submitted:\t%d\n", submitted)
+	fmt.Printf("finalized:\t%d\n", len(latencies))
+	fmt.Printf("pending:\t%d\n", len(pending))
+	fmt.Printf("failed:\t%d\n", failed)
+	fmt.Printf("starved:\t%d\n", starved)
+	fmt.Printf("throughput:\t%.2f/s\n", float64(len(latencies))/elapsed.Seconds())
+	// the bench fails with the exit code, so the release scripts could stop
+	if len(latencies) == 0 {
+		return cli.Exit(fmt.Sprintf("no transfer finalized in %s", elapsed.Round(time.Second)), 1)
+	}
+	slices.Sort(latencies)
+	percentile := func(p float64) time.Duration {
+		return latencies[int(p*float64(len(latencies)-1))].Round(time.Millisecond)
+	}
+	fmt.Printf("latency p50:\t%s\n", percentile(0.5))
+	fmt.Printf("latency p90:\t%s\n", percentile(0.9))
+	fmt.Printf("latency p99:\t%s\n", percentile(0.99))
+	fmt.Printf("latency max:\t%s\n", percentile(1))
+	if limit := c.Duration("max-p99"); limit > 0 && percentile(0.99) > limit {
+		return cli.Exit(fmt.Sprintf("latency p99 %s exceeds %s", percentile(0.99), limit), 1)
+	}
+	return nil
+}
+
+// benchOutput is an output owned by the bench account, and reads its own
+// keys to sign the transfer spending it, without reading it from the node.
+type benchOutput struct {
+	hash  crypto.Hash
+	index uint
+	keys  *common.UTXOKeys
+}
+
+func (o *benchOutput) ReadUTXOKeys(hash crypto.Hash, index uint) (*common.UTXOKeys, error) {
+	if hash != o.hash || index != o.index {
+		return nil, fmt.Errorf("unknown bench output %s:%d", hash, index)
+	}
+	return o.keys, nil
+}
+
+func benchOutputs(ver *common.VersionedTransaction) []*benchOutput {
+	outputs := make([]*benchOutput, len(ver.Outputs))
+	for i, out := range ver.Outputs {
+		outputs[i] = &benchOutput{
+			hash:  ver.PayloadHash(),
+			index: uint(i),
+			keys:  &common.UTXOKeys{Mask: out.Mask, Keys: out.Keys},
+		}
+	}
+	return outputs
+}
+
+// buildBenchTransfer spends the output to the count outputs of the account,
+// the input amount must be the count in XIN.
+func buildBenchTransfer(account *common.Address, in *benchOutput, count int) (*common.VersionedTransaction, error) {
+	tx := common.NewTransactionV5(common.XINAssetId)
+	tx.AddInput(in.hash, in.index)
+	for range count {
+		seed := make([]byte, 64)
+		crypto.ReadRand(seed)
+		tx.AddScriptOutput([]*common.Address{account}, common.NewThresholdScript(1), common.NewInteger(1), seed)
+	}
+	signed := tx.AsVersioned()
+	err := signed.SignInput(in, 0, []*common.Address{account})
+	if err != nil {
+		return nil, err
+	}
+	return common.UnmarshalVersionedTransaction(signed.Marshal())
+}
+
+func sendBenchTransaction(node string, ver *common.VersionedTransaction, timeout time.Duration) error {
+	raw := hex.EncodeToString(ver.Marshal())
+	_, err := callRPC(node, "sendrawtransaction", []any{raw}, false)
+	if err != nil {
+		return err
+	}
+	for deadline := time.Now().Add(timeout); time.Now().Before(deadline); {
+		data, err := callRPC(node, "gettransaction", []any{ver.PayloadHash().String()}, false)
+		if err != nil {
+			return err
+		}
+		var tx struct {
+			Snapshot string `json:"snapshot"`
+		}
+		err = json.Unmarshal(data, &tx)
+		if err == nil && tx.Snapshot != "" {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return fmt.Errorf("bench transaction %s not finalized in %s", ver.PayloadHash(), timeout)
+}
+
 func callRPC(node, method string, params []any, _ bool) ([]byte, error) {
 	return rpc.CallMixinRPC(node, method, params)
 }
@@ -976,9 +3775,11 @@ type signerInput struct {
 		Script   common.Script     `json:"script"`
 		Accounts []*common.Address `json:"accounts"`
 	}
-	Asset crypto.Hash `json:"asset"`
-	Extra string      `json:"extra"`
-	Node  string      `json:"-"`
+	Asset      crypto.Hash   `json:"asset"`
+	References []crypto.Hash `json:"references"`
+	Extra      string        `json:"extra"`
+	Node       string        `json:"-"`
+	Offline    bool          `json:"-"`
 }
 
 func (raw signerInput) ReadUTXOKeys(hash crypto.Hash, index uint) (*common.UTXOKeys, error) {
@@ -991,6 +3792,9 @@ func (raw signerInput) ReadUTXOKeys(hash crypto.Hash, index uint) (*common.UTXOK
 			return utxo, nil
 		}
 	}
+	if raw.Offline {
+		return nil, fmt.Errorf("missing keys for offline input %s#%d", hash.String(), index)
+	}
 
 	data, err := callRPC(raw.Node, "getutxo", []any{hash.String(), index}, false)
 	if err != nil {
@@ -3,6 +3,7 @@ package common
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"strconv"
 	"strings"
 
@@ -10,7 +11,10 @@ import (
 	"github.com/MixinNetwork/mixin/util/base58"
 )
 
-const MainAddressPrefix = "XIN"
+const (
+	MainAddressPrefix          = "XIN"
+	SubaddressPaymentIdMaxSize = 64
+)
 
 type Address struct {
 	PrivateSpendKey crypto.Key
@@ -58,6 +62,46 @@ func NewAddressFromString(s string) (Address, error) {
 	return a, nil
 }
 
+// DeriveSequenceAddress derives the deposit address of the sequence from the
+// master address, all the sequence addresses share the master view key, so
+// a scanner with the private view key finds the outputs of all of them. The
+// private spend key is derived only if the master private spend key is known.
+func (a Address) DeriveSequenceAddress(sequence uint64) Address {
+	if !a.PrivateViewKey.HasValue() {
+		panic(a.String())
+	}
+	addr := Address{
+		PrivateViewKey: a.PrivateViewKey,
+		PublicViewKey:  a.PublicViewKey,
+		PublicSpendKey: *crypto.DeriveSequencePublicKey(&a.PublicSpendKey, &a.PrivateViewKey, sequence),
+	}
+	if a.PrivateSpendKey.HasValue() {
+		addr.PrivateSpendKey = *crypto.DeriveSequencePrivateKey(&a.PrivateSpendKey, &a.PrivateViewKey, sequence)
+	}
+	return addr
+}
+
+// DeriveSubaddress derives the deposit address of the payment id from the
+// master address, like the sequence addresses, but the scanner must know the
+// payment id in advance to find the outputs.
+func (a Address) DeriveSubaddress(paymentId []byte) (Address, error) {
+	if !a.PrivateViewKey.HasValue() {
+		panic(a.String())
+	}
+	if len(paymentId) == 0 || len(paymentId) > SubaddressPaymentIdMaxSize {
+		return Address{}, fmt.Errorf("invalid subaddress payment id size %d", len(paymentId))
+	}
+	addr := Address{
+		PrivateViewKey: a.PrivateViewKey,
+		PublicViewKey:  a.PublicViewKey,
+		PublicSpendKey: *crypto.DeriveSubaddressPublicKey(&a.PublicSpendKey, &a.PrivateViewKey, paymentId),
+	}
+	if a.PrivateSpendKey.HasValue() {
+		addr.PrivateSpendKey = *crypto.DeriveSubaddressPrivateKey(&a.PrivateSpendKey, &a.PrivateViewKey, paymentId)
+	}
+	return addr, nil
+}
+
 func (a Address) String() string {
 	data := append([]byte(MainAddressPrefix), a.PublicSpendKey[:]...)
 	data = append(data, a.PublicViewKey[:]...)
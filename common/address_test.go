@@ -3,6 +3,7 @@ package common
 import (
 	"testing"
 
+	"github.com/MixinNetwork/mixin/crypto"
 	"github.com/stretchr/testify/require"
 )
 
@@ -51,3 +52,74 @@ func TestAddress(t *testing.T) {
 	err = a.UnmarshalJSON([]byte("\"\""))
 	require.NotNil(err)
 }
+
+func TestSequenceAddress(t *testing.T) {
+	require := require.New(t)
+
+	master := randomAccount()
+	watch := Address{
+		PrivateViewKey: master.PrivateViewKey,
+		PublicViewKey:  master.PublicViewKey,
+		PublicSpendKey: master.PublicSpendKey,
+	}
+	a0 := master.DeriveSequenceAddress(0)
+	a1 := master.DeriveSequenceAddress(1)
+	require.NotEqual(a0.String(), a1.String())
+	require.NotEqual(master.String(), a0.String())
+	require.Equal(master.PublicViewKey, a1.PublicViewKey)
+	require.Equal(a1.PrivateSpendKey.Public(), a1.PublicSpendKey)
+	w1 := watch.DeriveSequenceAddress(1)
+	require.Equal(a1.String(), w1.String())
+	require.False(w1.PrivateSpendKey.HasValue())
+
+	seed := make([]byte, 64)
+	crypto.ReadRand(seed)
+	tx := NewTransactionV5(XINAssetId)
+	tx.AddScriptOutput([]*Address{&a1}, NewThresholdScript(1), NewInteger(1), seed)
+	out := tx.Outputs[0]
+	_, found := out.ViewKeyIndex(&w1, 0)
+	require.True(found)
+	_, found = out.ViewKeyIndex(&a0, 0)
+	require.False(found)
+	priv := crypto.DeriveGhostPrivateKey(&out.Mask, &a1.PrivateViewKey, &a1.PrivateSpendKey, 0)
+	require.Equal(*out.Keys[0], priv.Public())
+}
+
+func TestSubaddress(t *testing.T) {
+	require := require.New(t)
+
+	master := randomAccount()
+	watch := Address{
+		PrivateViewKey: master.PrivateViewKey,
+		PublicViewKey:  master.PublicViewKey,
+		PublicSpendKey: master.PublicSpendKey,
+	}
+	_, err := master.DeriveSubaddress(nil)
+	require.NotNil(err)
+	_, err = master.DeriveSubaddress(make([]byte, SubaddressPaymentIdMaxSize+1))
+	require.NotNil(err)
+
+	a0, err := master.DeriveSubaddress([]byte("customer-0"))
+	require.Nil(err)
+	a1, err := master.DeriveSubaddress([]byte("customer-1"))
+	require.Nil(err)
+	require.NotEqual(a0.String(), a1.String())
+	require.Equal(master.PublicViewKey, a1.PublicViewKey)
+	require.Equal(a1.PrivateSpendKey.Public(), a1.PublicSpendKey)
+	w1, err := watch.DeriveSubaddress([]byte("customer-1"))
+	require.Nil(err)
+	require.Equal(a1.String(), w1.String())
+	require.False(w1.PrivateSpendKey.HasValue())
+
+	seed := make([]byte, 64)
+	crypto.ReadRand(seed)
+	tx := NewTransactionV5(XINAssetId)
+	tx.AddScriptOutput([]*Address{&a1}, NewThresholdScript(1), NewInteger(1), seed)
+	out := tx.Outputs[0]
+	_, found := out.ViewKeyIndex(&w1, 0)
+	require.True(found)
+	_, found = out.ViewKeyIndex(&a0, 0)
+	require.False(found)
+	priv := crypto.DeriveGhostPrivateKey(&out.Mask, &a1.PrivateViewKey, &a1.PrivateSpendKey, 0)
+	require.Equal(*out.Keys[0], priv.Public())
+}
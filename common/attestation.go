@@ -0,0 +1,71 @@
+package common
+
+import (
+	"fmt"
+
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+const (
+	attestationPrefix   = "MIXIN:NODE:ATTESTATION:"
+	AttestationNonceMax = 64
+)
+
+// NodeAttestation is a statement signed by the signer key of the node, with
+// the current final round of the node chain and the nonce of the verifier, so
+// a stale replica can't answer a fresh nonce with an old statement.
+type NodeAttestation struct {
+	NetworkId crypto.Hash
+	NodeId    crypto.Hash
+	Signer    crypto.Key
+	Round     uint64
+	RoundHash crypto.Hash
+	Timestamp uint64
+	Nonce     []byte
+	Signature crypto.Signature
+}
+
+// NodeIdForSigner is the node id of the signer public key, because the view key
+// of a node is derived from the spend key.
+func NodeIdForSigner(networkId crypto.Hash, signer crypto.Key) crypto.Hash {
+	var addr Address
+	addr.PublicSpendKey = signer
+	addr.PublicViewKey = signer.DeterministicHashDerive().Public()
+	return addr.Hash().ForNetwork(networkId)
+}
+
+func (a *NodeAttestation) PayloadHash() crypto.Hash {
+	if len(a.Nonce) > AttestationNonceMax {
+		panic(len(a.Nonce))
+	}
+	enc := NewMinimumEncoder()
+	enc.Write([]byte(attestationPrefix))
+	enc.Write(a.NetworkId[:])
+	enc.Write(a.NodeId[:])
+	enc.Write(a.Signer[:])
+	enc.WriteUint64(a.Round)
+	enc.Write(a.RoundHash[:])
+	enc.WriteUint64(a.Timestamp)
+	enc.WriteInt(len(a.Nonce))
+	enc.Write(a.Nonce)
+	return crypto.Blake3Hash(enc.Bytes())
+}
+
+// Verify checks the node id of the signer key and the signature, the verifier
+// should also check the nonce is the one sent, and the timestamp is recent.
+func (a *NodeAttestation) Verify() error {
+	if len(a.Nonce) > AttestationNonceMax {
+		return Errorf(ErrorInvalidFormat, "invalid attestation nonce size %d", len(a.Nonce))
+	}
+	if id := NodeIdForSigner(a.NetworkId, a.Signer); id != a.NodeId {
+		return Errorf(ErrorInvalidSignature, "invalid attestation node %s %s", a.NodeId, id)
+	}
+	if !a.Signer.Verify(a.PayloadHash(), a.Signature) {
+		return Errorf(ErrorInvalidSignature, "invalid attestation signature %s", a.Signature)
+	}
+	return nil
+}
+
+func (a *NodeAttestation) String() string {
+	return fmt.Sprintf("%s:%d:%s:%d", a.NodeId, a.Round, a.RoundHash, a.Timestamp)
+}
@@ -0,0 +1,51 @@
+package common
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNodeAttestation(t *testing.T) {
+	require := require.New(t)
+
+	network := crypto.Blake3Hash([]byte("network"))
+	signer := crypto.NewKeyFromSeed(bytes.Repeat([]byte{1}, 64))
+	node := &Node{}
+	node.Signer.PublicSpendKey = signer.Public()
+	node.Signer.PublicViewKey = signer.Public().DeterministicHashDerive().Public()
+	require.Equal(node.IdForNetwork(network), NodeIdForSigner(network, signer.Public()))
+
+	a := &NodeAttestation{
+		NetworkId: network,
+		NodeId:    node.IdForNetwork(network),
+		Signer:    signer.Public(),
+		Round:     123,
+		RoundHash: crypto.Blake3Hash([]byte("round")),
+		Timestamp: 1663669260746463409,
+		Nonce:     []byte("nonce"),
+	}
+	require.Equal("55c2c95f75fd41c82100fc70dff50eee745a1f33d9689d8edaae0e09c6d29e47", a.PayloadHash().String())
+	a.Signature = signer.Sign(a.PayloadHash())
+	require.Nil(a.Verify())
+
+	a.Nonce = []byte("other")
+	require.ErrorContains(a.Verify(), "invalid attestation signature")
+	a.Nonce = bytes.Repeat([]byte{1}, AttestationNonceMax+1)
+	require.ErrorContains(a.Verify(), "invalid attestation nonce size")
+	a.Nonce = []byte("nonce")
+	a.Round = 124
+	require.ErrorContains(a.Verify(), "invalid attestation signature")
+	a.Round = 123
+	require.Nil(a.Verify())
+
+	other := crypto.NewKeyFromSeed(bytes.Repeat([]byte{2}, 64))
+	a.Signer = other.Public()
+	a.Signature = other.Sign(a.PayloadHash())
+	require.ErrorContains(a.Verify(), "invalid attestation node")
+	a.NodeId = NodeIdForSigner(network, other.Public())
+	a.Signature = other.Sign(a.PayloadHash())
+	require.Nil(a.Verify())
+}
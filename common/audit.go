@@ -0,0 +1,85 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	AuditActionMaintenance       = "maintenance"
+	AuditActionKeyUnlock         = "key.unlock"
+	AuditActionPeerAuthFailure   = "p2p.auth.failure"
+	AuditActionRPCAuthFailure    = "rpc.auth.failure"
+	AuditActionRPCAdmin          = "rpc.admin"
+	AuditActionTransactionSubmit = "transaction.submit"
+
+	auditThrottleKeysLimit = 4096
+)
+
+// AuditEntry records an operator action on the node, the entries are
+// append only and ordered by the sequence assigned by the store.
+type AuditEntry struct {
+	Sequence  uint64
+	Timestamp uint64
+	Actor     string
+	Action    string
+	Source    string
+	Detail    string
+}
+
+func (e *AuditEntry) Verify() error {
+	if strings.TrimSpace(e.Actor) == "" || len(e.Actor) > 256 {
+		return fmt.Errorf("invalid audit actor %s", e.Actor)
+	}
+	if strings.TrimSpace(e.Action) == "" || len(e.Action) > 256 {
+		return fmt.Errorf("invalid audit action %s", e.Action)
+	}
+	if len(e.Source) > 256 {
+		return fmt.Errorf("invalid audit source %s", e.Source)
+	}
+	if len(e.Detail) > 4096 {
+		return fmt.Errorf("invalid audit detail size %d", len(e.Detail))
+	}
+	if e.Timestamp == 0 {
+		return fmt.Errorf("invalid audit timestamp %d", e.Timestamp)
+	}
+	return nil
+}
+
+// AuditThrottle allows a key once in the period, to audit the failures from
+// a remote source without letting it flood the audit log.
+type AuditThrottle struct {
+	mutex  sync.Mutex
+	period uint64
+	last   map[string]uint64
+}
+
+func NewAuditThrottle(period time.Duration) *AuditThrottle {
+	return &AuditThrottle{
+		period: uint64(period),
+		last:   make(map[string]uint64),
+	}
+}
+
+func (t *AuditThrottle) Allow(key string, now uint64) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if last, found := t.last[key]; found && last+t.period > now {
+		return false
+	}
+	if len(t.last) >= auditThrottleKeysLimit {
+		for k, last := range t.last {
+			if last+t.period <= now {
+				delete(t.last, k)
+			}
+		}
+	}
+	if len(t.last) >= auditThrottleKeysLimit {
+		return false
+	}
+	t.last[key] = now
+	return true
+}
@@ -0,0 +1,528 @@
+package common
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+// The canonical JSON is a text form of the transactions and snapshots that
+// maps one to one to the binary encodings, so an indexer storing the JSON can
+// rebuild the exact binary and recompute the hashes offline.
+//
+// The objects have all the keys present and sorted, without any whitespace or
+// HTML escaping. The bytes are lower case hex, the amounts are the Integer
+// strings with all the 8 decimals, and the uint64 values are decimal strings
+// to keep the precision in the languages with float numbers only.
+
+type canonicalTransaction struct {
+	Aggregated *canonicalAggregatedSignature `json:"aggregated"`
+	Asset      crypto.Hash                   `json:"asset"`
+	Extra      string                        `json:"extra"`
+	Hash       crypto.Hash                   `json:"hash"`
+	Inputs     []*canonicalInput             `json:"inputs"`
+	Outputs    []*canonicalOutput            `json:"outputs"`
+	References []crypto.Hash                 `json:"references"`
+	Signatures []map[string]crypto.Signature `json:"signatures"`
+	Version    uint8                         `json:"version"`
+}
+
+type canonicalAggregatedSignature struct {
+	Signature crypto.Signature `json:"signature"`
+	Signers   []int            `json:"signers"`
+}
+
+type canonicalInput struct {
+	Deposit *canonicalDeposit `json:"deposit"`
+	Genesis string            `json:"genesis"`
+	Hash    crypto.Hash       `json:"hash"`
+	Index   uint              `json:"index"`
+	Mint    *canonicalMint    `json:"mint"`
+}
+
+type canonicalDeposit struct {
+	Amount      string      `json:"amount"`
+	AssetKey    string      `json:"asset_key"`
+	Chain       crypto.Hash `json:"chain"`
+	Index       string      `json:"index"`
+	Transaction string      `json:"transaction"`
+}
+
+type canonicalMint struct {
+	Amount string `json:"amount"`
+	Batch  string `json:"batch"`
+	Group  string `json:"group"`
+}
+
+type canonicalOutput struct {
+	Amount     string               `json:"amount"`
+	Keys       []*crypto.Key        `json:"keys"`
+	Mask       crypto.Key           `json:"mask"`
+	Script     Script               `json:"script"`
+	Type       uint8                `json:"type"`
+	Withdrawal *canonicalWithdrawal `json:"withdrawal"`
+}
+
+type canonicalWithdrawal struct {
+	Address string `json:"address"`
+	Tag     string `json:"tag"`
+}
+
+type canonicalSnapshot struct {
+	Hash         crypto.Hash           `json:"hash"`
+	NodeId       crypto.Hash           `json:"node"`
+	References   *canonicalReferences  `json:"references"`
+	RoundNumber  string                `json:"round"`
+	Signature    *crypto.CosiSignature `json:"signature"`
+	Timestamp    string                `json:"timestamp"`
+	Topology     string                `json:"topology"`
+	Transactions []crypto.Hash         `json:"transactions"`
+	Version      uint8                 `json:"version"`
+}
+
+type canonicalReferences struct {
+	External crypto.Hash `json:"external"`
+	Self     crypto.Hash `json:"self"`
+}
+
+// CanonicalizeJSON rewrites any JSON document in the canonical layout, which
+// sorts the keys and drops the whitespace, so the documents reordered by a
+// database could be compared byte by byte again.
+func CanonicalizeJSON(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	err := dec.Decode(&v)
+	if err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("trailing data after json value")
+	}
+	return marshalCanonicalJSON(v)
+}
+
+func (tx *VersionedTransaction) CanonicalJSON() ([]byte, error) {
+	ct := &canonicalTransaction{
+		Asset:      tx.Asset,
+		Extra:      hex.EncodeToString(tx.Extra),
+		Hash:       tx.PayloadHash(),
+		Inputs:     make([]*canonicalInput, len(tx.Inputs)),
+		Outputs:    make([]*canonicalOutput, len(tx.Outputs)),
+		References: make([]crypto.Hash, len(tx.References)),
+		Version:    tx.Version,
+	}
+	copy(ct.References, tx.References)
+
+	for i, in := range tx.Inputs {
+		ci := &canonicalInput{
+			Genesis: hex.EncodeToString(in.Genesis),
+			Hash:    in.Hash,
+			Index:   in.Index,
+		}
+		if d := in.Deposit; d != nil {
+			err := checkCanonicalStrings(d.AssetKey, d.Transaction)
+			if err != nil {
+				return nil, err
+			}
+			ci.Deposit = &canonicalDeposit{
+				Amount:      d.Amount.String(),
+				AssetKey:    d.AssetKey,
+				Chain:       d.Chain,
+				Index:       strconv.FormatUint(d.Index, 10),
+				Transaction: d.Transaction,
+			}
+		}
+		if m := in.Mint; m != nil {
+			err := checkCanonicalStrings(m.Group)
+			if err != nil {
+				return nil, err
+			}
+			ci.Mint = &canonicalMint{
+				Amount: m.Amount.String(),
+				Batch:  strconv.FormatUint(m.Batch, 10),
+				Group:  m.Group,
+			}
+		}
+		ct.Inputs[i] = ci
+	}
+
+	for i, out := range tx.Outputs {
+		co := &canonicalOutput{
+			Amount: out.Amount.String(),
+			Keys:   make([]*crypto.Key, len(out.Keys)),
+			Mask:   out.Mask,
+			Script: out.Script,
+			Type:   out.Type,
+		}
+		copy(co.Keys, out.Keys)
+		if w := out.Withdrawal; w != nil {
+			err := checkCanonicalStrings(w.Address, w.Tag)
+			if err != nil {
+				return nil, err
+			}
+			co.Withdrawal = &canonicalWithdrawal{Address: w.Address, Tag: w.Tag}
+		}
+		ct.Outputs[i] = co
+	}
+
+	if as := tx.AggregatedSignature; as != nil {
+		ct.Aggregated = &canonicalAggregatedSignature{
+			Signature: as.Signature,
+			Signers:   make([]int, len(as.Signers)),
+		}
+		copy(ct.Aggregated.Signers, as.Signers)
+	} else {
+		ct.Signatures = make([]map[string]crypto.Signature, len(tx.SignaturesMap))
+		for i, sm := range tx.SignaturesMap {
+			cm := make(map[string]crypto.Signature, len(sm))
+			for j, sig := range sm {
+				cm[strconv.FormatUint(uint64(j), 10)] = *sig
+			}
+			ct.Signatures[i] = cm
+		}
+	}
+
+	return marshalCanonicalJSON(ct)
+}
+
+// UnmarshalCanonicalTransaction rebuilds the transaction from the canonical
+// JSON, the document may have the keys reordered, but any other difference
+// from the canonical form, including a mismatched hash, is an error.
+func UnmarshalCanonicalTransaction(data []byte) (*VersionedTransaction, error) {
+	var ct canonicalTransaction
+	err := unmarshalCanonicalJSON(data, &ct)
+	if err != nil {
+		return nil, Errorf(ErrorInvalidFormat, "invalid transaction json %w", err)
+	}
+	if ct.Version != TxVersionHashSignature {
+		return nil, Errorf(ErrorInvalidFormat, "invalid transaction version %d", ct.Version)
+	}
+	if ct.Aggregated != nil && ct.Signatures != nil {
+		return nil, Errorf(ErrorInvalidFormat, "both aggregated and map signatures present")
+	}
+
+	tx := &SignedTransaction{Transaction: Transaction{
+		Version:    ct.Version,
+		Asset:      ct.Asset,
+		References: ct.References,
+	}}
+	tx.Extra, err = hex.DecodeString(ct.Extra)
+	if err != nil {
+		return nil, Errorf(ErrorInvalidFormat, "invalid transaction extra %w", err)
+	}
+
+	for _, ci := range ct.Inputs {
+		if ci == nil {
+			return nil, Errorf(ErrorInvalidFormat, "null transaction input")
+		}
+		in := &Input{Hash: ci.Hash, Index: ci.Index}
+		in.Genesis, err = hex.DecodeString(ci.Genesis)
+		if err != nil {
+			return nil, Errorf(ErrorInvalidFormat, "invalid input genesis %w", err)
+		}
+		if len(in.Genesis) == 0 {
+			in.Genesis = nil
+		}
+		if d := ci.Deposit; d != nil {
+			in.Deposit = &DepositData{
+				Chain:       d.Chain,
+				AssetKey:    d.AssetKey,
+				Transaction: d.Transaction,
+			}
+			in.Deposit.Index, err = strconv.ParseUint(d.Index, 10, 64)
+			if err != nil {
+				return nil, Errorf(ErrorInvalidFormat, "invalid deposit index %w", err)
+			}
+			in.Deposit.Amount, err = parseCanonicalInteger(d.Amount)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if m := ci.Mint; m != nil {
+			in.Mint = &MintData{Group: m.Group}
+			in.Mint.Batch, err = strconv.ParseUint(m.Batch, 10, 64)
+			if err != nil {
+				return nil, Errorf(ErrorInvalidFormat, "invalid mint batch %w", err)
+			}
+			in.Mint.Amount, err = parseCanonicalInteger(m.Amount)
+			if err != nil {
+				return nil, err
+			}
+		}
+		tx.Inputs = append(tx.Inputs, in)
+	}
+
+	for _, co := range ct.Outputs {
+		if co == nil {
+			return nil, Errorf(ErrorInvalidFormat, "null transaction output")
+		}
+		out := &Output{
+			Type:   co.Type,
+			Keys:   co.Keys,
+			Mask:   co.Mask,
+			Script: co.Script,
+		}
+		for _, k := range out.Keys {
+			if k == nil {
+				return nil, Errorf(ErrorInvalidFormat, "null output key")
+			}
+		}
+		out.Amount, err = parseCanonicalInteger(co.Amount)
+		if err != nil {
+			return nil, err
+		}
+		if w := co.Withdrawal; w != nil {
+			out.Withdrawal = &WithdrawalData{Address: w.Address, Tag: w.Tag}
+		}
+		tx.Outputs = append(tx.Outputs, out)
+	}
+
+	if as := ct.Aggregated; as != nil {
+		tx.AggregatedSignature = &AggregatedSignature{
+			Signature: as.Signature,
+			Signers:   as.Signers,
+		}
+	}
+	for _, cm := range ct.Signatures {
+		sm := make(map[uint16]*crypto.Signature, len(cm))
+		for k, sig := range cm {
+			i, err := strconv.ParseUint(k, 10, 16)
+			if err != nil {
+				return nil, Errorf(ErrorInvalidFormat, "invalid signature index %s", k)
+			}
+			sm[uint16(i)] = &sig
+		}
+		tx.SignaturesMap = append(tx.SignaturesMap, sm)
+	}
+
+	err = checkTransactionEncodingLimits(tx)
+	if err != nil {
+		return nil, err
+	}
+	ver, err := unmarshalVersionedTransaction(tx.AsVersioned().marshal())
+	if err != nil {
+		return nil, err
+	}
+	if ver.PayloadHash() != ct.Hash {
+		return nil, Errorf(ErrorInvalidFormat, "transaction hash mismatch %s %s", ct.Hash, ver.PayloadHash())
+	}
+	err = compareCanonicalJSON(data, ver.CanonicalJSON)
+	if err != nil {
+		return nil, err
+	}
+	return ver, nil
+}
+
+func (s *SnapshotWithTopologicalOrder) CanonicalJSON() ([]byte, error) {
+	cs := &canonicalSnapshot{
+		Hash:         s.PayloadHash(),
+		NodeId:       s.NodeId,
+		RoundNumber:  strconv.FormatUint(s.RoundNumber, 10),
+		Signature:    s.Signature,
+		Timestamp:    strconv.FormatUint(s.Timestamp, 10),
+		Topology:     strconv.FormatUint(s.TopologicalOrder, 10),
+		Transactions: make([]crypto.Hash, len(s.Transactions)),
+		Version:      s.Version,
+	}
+	copy(cs.Transactions, s.Transactions)
+	if r := s.References; r != nil {
+		cs.References = &canonicalReferences{External: r.External, Self: r.Self}
+	}
+	return marshalCanonicalJSON(cs)
+}
+
+// UnmarshalCanonicalSnapshot is the snapshot counterpart of
+// UnmarshalCanonicalTransaction, and the returned snapshot has the Hash set.
+func UnmarshalCanonicalSnapshot(data []byte) (*SnapshotWithTopologicalOrder, error) {
+	var cs canonicalSnapshot
+	err := unmarshalCanonicalJSON(data, &cs)
+	if err != nil {
+		return nil, Errorf(ErrorInvalidFormat, "invalid snapshot json %w", err)
+	}
+	if cs.Version != SnapshotVersionCommonEncoding {
+		return nil, Errorf(ErrorInvalidFormat, "invalid snapshot version %d", cs.Version)
+	}
+	if len(cs.Transactions) != 1 {
+		return nil, Errorf(ErrorInvalidFormat, "invalid snapshot transactions count %d", len(cs.Transactions))
+	}
+	if cs.Signature != nil && cs.Signature.Mask == 0 {
+		return nil, Errorf(ErrorInvalidFormat, "invalid snapshot signature mask")
+	}
+
+	s := &SnapshotWithTopologicalOrder{Snapshot: &Snapshot{
+		Version:      cs.Version,
+		NodeId:       cs.NodeId,
+		Signature:    cs.Signature,
+		Transactions: cs.Transactions,
+	}}
+	if r := cs.References; r != nil {
+		s.References = &RoundLink{Self: r.Self, External: r.External}
+	}
+	for _, f := range []struct {
+		name string
+		src  string
+		dst  *uint64
+	}{
+		{"round", cs.RoundNumber, &s.RoundNumber},
+		{"timestamp", cs.Timestamp, &s.Timestamp},
+		{"topology", cs.Topology, &s.TopologicalOrder},
+	} {
+		*f.dst, err = strconv.ParseUint(f.src, 10, 64)
+		if err != nil {
+			return nil, Errorf(ErrorInvalidFormat, "invalid snapshot %s %w", f.name, err)
+		}
+	}
+
+	s, err = NewDecoder(s.VersionedMarshal()).DecodeSnapshotWithTopo()
+	if err != nil {
+		return nil, Errorf(ErrorInvalidFormat, "%w", err)
+	}
+	s.Hash = s.PayloadHash()
+	if s.Hash != cs.Hash {
+		return nil, Errorf(ErrorInvalidFormat, "snapshot hash mismatch %s %s", cs.Hash, s.Hash)
+	}
+	err = compareCanonicalJSON(data, s.CanonicalJSON)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// checkTransactionEncodingLimits rejects the values the encoder would panic on,
+// they can't come from the binary, but the JSON could have anything.
+func checkTransactionEncodingLimits(tx *SignedTransaction) error {
+	for _, l := range []int{len(tx.Inputs), len(tx.Outputs), len(tx.References)} {
+		if l > MaximumEncodingInt {
+			return Errorf(ErrorExceedsCountLimit, "transaction count %d exceeds limit", l)
+		}
+	}
+	if len(tx.Extra) > ExtraSizeStorageCapacity {
+		return Errorf(ErrorExceedsExtraLimit, "transaction extra %d exceeds limit", len(tx.Extra))
+	}
+	for _, in := range tx.Inputs {
+		if in.Index > 1024 {
+			return Errorf(ErrorInvalidInput, "invalid input index %d", in.Index)
+		}
+		sizes := []int{len(in.Genesis)}
+		if d := in.Deposit; d != nil {
+			sizes = append(sizes, len(d.AssetKey), len(d.Transaction), len(d.Amount.i.Bytes()))
+		}
+		if m := in.Mint; m != nil {
+			sizes = append(sizes, len(m.Group), len(m.Amount.i.Bytes()))
+		}
+		err := checkEncodingSizes(sizes...)
+		if err != nil {
+			return err
+		}
+	}
+	for _, out := range tx.Outputs {
+		sizes := []int{len(out.Keys), len(out.Script), len(out.Amount.i.Bytes())}
+		if w := out.Withdrawal; w != nil {
+			sizes = append(sizes, len(w.Address), len(w.Tag))
+		}
+		err := checkEncodingSizes(sizes...)
+		if err != nil {
+			return err
+		}
+	}
+	if as := tx.AggregatedSignature; as != nil {
+		for i, m := range as.Signers {
+			if m < 0 || m > MaximumEncodingInt || (i > 0 && m <= as.Signers[i-1]) {
+				return Errorf(ErrorInvalidSignature, "invalid aggregated signers %v", as.Signers)
+			}
+		}
+	}
+	if len(tx.SignaturesMap) >= MaximumEncodingInt {
+		return Errorf(ErrorExceedsCountLimit, "signatures count %d exceeds limit", len(tx.SignaturesMap))
+	}
+	for _, sm := range tx.SignaturesMap {
+		err := checkEncodingSizes(len(sm))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkEncodingSizes(sizes ...int) error {
+	for _, l := range sizes {
+		if l > MaximumEncodingInt {
+			return Errorf(ErrorExceedsSizeLimit, "field size %d exceeds limit", l)
+		}
+	}
+	return nil
+}
+
+// checkCanonicalStrings rejects the invalid UTF-8, which the binary accepts but
+// the JSON can't keep without replacing the bytes.
+func checkCanonicalStrings(values ...string) error {
+	for _, v := range values {
+		if !utf8.ValidString(v) {
+			return Errorf(ErrorInvalidFormat, "invalid utf-8 string %x", v)
+		}
+	}
+	return nil
+}
+
+func parseCanonicalInteger(s string) (Integer, error) {
+	var v Integer
+	if len(s) < Precision+2 || s[len(s)-Precision-1] != '.' {
+		return v, Errorf(ErrorInvalidAmount, "invalid amount %s", s)
+	}
+	digits := s[:len(s)-Precision-1] + s[len(s)-Precision:]
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return v, Errorf(ErrorInvalidAmount, "invalid amount %s", s)
+		}
+	}
+	v.i.SetString(digits, 10)
+	if v.String() != s {
+		return v, Errorf(ErrorInvalidAmount, "invalid amount %s", s)
+	}
+	return v, nil
+}
+
+func compareCanonicalJSON(data []byte, canonical func() ([]byte, error)) error {
+	in, err := CanonicalizeJSON(data)
+	if err != nil {
+		return Errorf(ErrorInvalidFormat, "%w", err)
+	}
+	out, err := canonical()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(in, out) {
+		return Errorf(ErrorInvalidFormat, "non-canonical json %s", string(in))
+	}
+	return nil
+}
+
+func unmarshalCanonicalJSON(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(v)
+	if err != nil {
+		return err
+	}
+	if dec.More() {
+		return fmt.Errorf("trailing data after json value")
+	}
+	return nil
+}
+
+func marshalCanonicalJSON(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	err := enc.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte{'\n'}), nil
+}
@@ -0,0 +1,186 @@
+package common
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalTransactionJSON(t *testing.T) {
+	require := require.New(t)
+
+	for _, raw := range []string{
+		"777700052dc0ab2919c77daea5cfc0b37a2beea02142e8fdc4f60409fd40b256bb13ea290007eff98bbf1fd4632380b3f81bec40b54ceaa5d10e181b2c9e141da28b3d13c5460001000000000000a348712f7881be7a7bec9935d46578fd612a96e1cd0ac0f83520e2c0db0e98e2000100000000000030ad61194c5c3c19c0397d3ae98fb25ea4ead720fd49a86f2ab7b6db888ea61b00010000000000005981c0b5df48c066b4b3858ea949990c82cc27e030127d9eda70ff57fe9d9feb0001000000000000ad2fccec444b26794a13fb52f71348308de20f72a6dc4544195cef79f60910660001000000000000c817d2cac077b5ab21af4166f7451d9678a836db3f709b2903a971bf763b7f890001000000000000a4df50c83ed97db449ec856d660f4b0ef1f888bf2824800cf1bcf71418b32e580001000000000000000200a100060417bce6c8000000000000000000000000000000000000000000000000000000000000000000000000007777006b344b45397734746e65417472324257736d6877693645624231436257716779424248326f4367397677676e39346e5a5a4d6379694c7655347a596b6277703277754e4a595651556b77795a46664e3846726238345178556770673174656e574c61647834554552583270480000000000053be744043b00012f4d6a6fd5720be42930533d2efd2f5659f6179ea0e677edad599ef1dc6293b8ae2ebb91eac8ceb937f92c7dd5ffdb577b6506c6fbe0f2c7baf35d399dbc7bab0003fffe01000000000000001581a154c4107e519774e8784192b35a93ef68fff6ee0000",
+		"77770005a99c2e0e2b1da4d648755ef19bd95139acbbe6564cfb06dec7cd34931ca72cdc0001c19d51beba90c20ff538a32ab262ce6e32e59f03b5bfe6d8e6fe2b2544ba43b60000000000000000000100a40005e8d4a510000000000000000000000000000000000000000000000000000000000000000000000000000000000000000040cf0926f381bb17668ef4b4eab6243d4b437ae6d2372623b74f41a5597277495556515cbc346d8b639386c1e22239d032bb6f09f8b6f2ea5a3a19b41fe0bdd1de0000",
+	} {
+		val, _ := hex.DecodeString(raw)
+		ver, err := UnmarshalVersionedTransaction(val)
+		require.Nil(err)
+		testCanonicalTransactionRoundTrip(require, ver)
+	}
+
+	tx := NewTransactionV5(XINAssetId)
+	tx.Inputs = append(tx.Inputs, &Input{Genesis: []byte("genesis<&>")})
+	tx.Inputs = append(tx.Inputs, &Input{Deposit: &DepositData{
+		Chain:       EthereumAssetId,
+		AssetKey:    "0xa974c709cfb4566686553a20790685a47aceaa33",
+		Transaction: "0xc5945a8571fc84cd6850b26b5771d76311ed56957a04e993927de07b83f07c91",
+		Index:       1 << 60,
+		Amount:      NewIntegerFromString("123.45678901"),
+	}})
+	tx.Inputs = append(tx.Inputs, &Input{Mint: &MintData{
+		Group:  mintGroupUniversal,
+		Batch:  1<<53 + 1,
+		Amount: NewInteger(0),
+	}})
+	tx.AddInput(crypto.Blake3Hash([]byte("input")), 3)
+	key := crypto.NewKeyFromSeed(bytes.Repeat([]byte{1}, 64))
+	tx.Outputs = append(tx.Outputs, &Output{
+		Type:   OutputTypeScript,
+		Amount: NewIntegerFromString("0.00000001"),
+		Keys:   []*crypto.Key{&key},
+		Mask:   key,
+		Script: NewThresholdScript(1),
+	})
+	tx.Outputs = append(tx.Outputs, &Output{
+		Type:       OutputTypeWithdrawalSubmit,
+		Amount:     NewInteger(10000),
+		Withdrawal: &WithdrawalData{Address: "bc1<address>&", Tag: "memo \"tag\""},
+	})
+	tx.References = []crypto.Hash{crypto.Blake3Hash([]byte("reference"))}
+	tx.Extra = []byte("extra")
+	ver := tx.AsVersioned()
+	require.Equal("86d6ee0b53f15c0a56fbe4de5323a78ed800045a041227e8741d758ce0681523", ver.PayloadHash().String())
+	testCanonicalTransactionRoundTrip(require, ver)
+
+	sig := key.Sign(ver.PayloadHash())
+	ver.SignaturesMap = []map[uint16]*crypto.Signature{{2: &sig, 10: &sig}, {}}
+	testCanonicalTransactionRoundTrip(require, ver)
+	ver.SignaturesMap = nil
+	ver.AggregatedSignature = &AggregatedSignature{Signers: []int{0, 3, 700}, Signature: sig}
+	testCanonicalTransactionRoundTrip(require, ver)
+
+	data, err := ver.CanonicalJSON()
+	require.Nil(err)
+	require.NotContains(string(data), "\\u003c")
+	var m map[string]any
+	require.Nil(json.Unmarshal(data, &m))
+	m["extra"] = "65787472"
+	bad, _ := json.Marshal(m)
+	_, err = UnmarshalCanonicalTransaction(bad)
+	require.ErrorContains(err, "transaction hash mismatch")
+	m["extra"] = "6578747261"
+	m["signatures"] = []any{}
+	bad, _ = json.Marshal(m)
+	_, err = UnmarshalCanonicalTransaction(bad)
+	require.ErrorContains(err, "both aggregated and map signatures")
+	delete(m, "signatures")
+	m["hash"] = strings.ToUpper(ver.PayloadHash().String())
+	bad, _ = json.Marshal(m)
+	_, err = UnmarshalCanonicalTransaction(bad)
+	require.ErrorContains(err, "non-canonical json")
+	m["hash"] = ver.PayloadHash().String()
+	m["unknown"] = true
+	bad, _ = json.Marshal(m)
+	_, err = UnmarshalCanonicalTransaction(bad)
+	require.ErrorContains(err, "unknown field")
+	delete(m, "unknown")
+	m["outputs"].([]any)[0].(map[string]any)["amount"] = "0.000000010"
+	bad, _ = json.Marshal(m)
+	_, err = UnmarshalCanonicalTransaction(bad)
+	require.ErrorContains(err, "invalid amount")
+	m["outputs"].([]any)[0].(map[string]any)["amount"] = "0.00000001"
+	m["aggregated"].(map[string]any)["signers"] = []any{3, 0}
+	bad, _ = json.Marshal(m)
+	_, err = UnmarshalCanonicalTransaction(bad)
+	require.ErrorContains(err, "invalid aggregated signers")
+
+	ver.Inputs[1].Deposit.AssetKey = "\xff"
+	_, err = ver.CanonicalJSON()
+	require.ErrorContains(err, "invalid utf-8 string")
+}
+
+func testCanonicalTransactionRoundTrip(require *require.Assertions, ver *VersionedTransaction) {
+	ver.resetCache()
+	data, err := ver.CanonicalJSON()
+	require.Nil(err)
+	canonical, err := CanonicalizeJSON(data)
+	require.Nil(err)
+	require.Equal(string(data), string(canonical))
+
+	res, err := UnmarshalCanonicalTransaction(data)
+	require.Nil(err)
+	require.Equal(ver.PayloadHash(), res.PayloadHash())
+	require.Equal(ver.Marshal(), res.Marshal())
+	again, err := res.CanonicalJSON()
+	require.Nil(err)
+	require.Equal(string(data), string(again))
+
+	var m map[string]any
+	require.Nil(json.Unmarshal(data, &m))
+	indented, err := json.MarshalIndent(m, "", "  ")
+	require.Nil(err)
+	res, err = UnmarshalCanonicalTransaction(indented)
+	require.Nil(err)
+	require.Equal(ver.Marshal(), res.Marshal())
+}
+
+func TestCanonicalSnapshotJSON(t *testing.T) {
+	require := require.New(t)
+
+	s := &SnapshotWithTopologicalOrder{Snapshot: &Snapshot{
+		Version:      SnapshotVersionCommonEncoding,
+		NodeId:       crypto.Blake3Hash([]byte("node-test-id")),
+		RoundNumber:  123,
+		Timestamp:    1663669260746463409,
+		Transactions: []crypto.Hash{crypto.Blake3Hash([]byte("tx-test-id"))},
+		References: &RoundLink{
+			Self:     crypto.Blake3Hash([]byte("self-reference")),
+			External: crypto.Blake3Hash([]byte("external-reference")),
+		},
+	}, TopologicalOrder: 345}
+	var sig crypto.CosiSignature
+	sig.Mask ^= (1 << uint64(0))
+	copy(sig.Signature[:], bytes.Repeat([]byte{1, 2, 3, 4}, 16))
+	s.Signature = &sig
+
+	data, err := s.CanonicalJSON()
+	require.Nil(err)
+	require.Equal(`{"hash":"5496376f884328a6f73d2844b9cd646755d08348b7b9efc03d3868f5afd4b134","node":"d4f5a8351419cfc9b0ba10268f623994c6d6a1640efa904fe848ae697556652a","references":{"external":"0552038ee8ce7c8b0efba019a7c36e86f1b70069553bbb187cfd8e3ca5f14fb1","self":"b7342ffb374824d69674054486e71bb8b575a4d961b65ffff647a8e1696f579a"},"round":"123","signature":"010203040102030401020304010203040102030401020304010203040102030401020304010203040102030401020304010203040102030401020304010203040000000000000001","timestamp":"1663669260746463409","topology":"345","transactions":["d694818d674f347b36b0efd75332eadfa73723cd0fb6152da778b91baf9719cc"],"version":2}`, string(data))
+
+	res, err := UnmarshalCanonicalSnapshot(data)
+	require.Nil(err)
+	require.Equal("5496376f884328a6f73d2844b9cd646755d08348b7b9efc03d3868f5afd4b134", res.Hash.String())
+	require.Equal(s.VersionedMarshal(), res.VersionedMarshal())
+
+	s.References = nil
+	s.Signature = nil
+	data, err = s.CanonicalJSON()
+	require.Nil(err)
+	res, err = UnmarshalCanonicalSnapshot(data)
+	require.Nil(err)
+	require.Nil(res.References)
+	require.Nil(res.Signature)
+	require.Equal(s.PayloadHash(), res.Hash)
+	require.Equal(s.VersionedMarshal(), res.VersionedMarshal())
+
+	var m map[string]any
+	require.Nil(json.Unmarshal(data, &m))
+	m["timestamp"] = 1663669260746463409
+	bad, _ := json.Marshal(m)
+	_, err = UnmarshalCanonicalSnapshot(bad)
+	require.ErrorContains(err, "invalid snapshot json")
+	m["timestamp"] = "1663669260746463408"
+	bad, _ = json.Marshal(m)
+	_, err = UnmarshalCanonicalSnapshot(bad)
+	require.ErrorContains(err, "snapshot hash mismatch")
+	m["timestamp"] = "01663669260746463409"
+	bad, _ = json.Marshal(m)
+	_, err = UnmarshalCanonicalSnapshot(bad)
+	require.ErrorContains(err, "non-canonical json")
+}
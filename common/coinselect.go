@@ -0,0 +1,159 @@
+package common
+
+import (
+	"bytes"
+	"slices"
+)
+
+// the coin selection picks the inputs to pay an amount from the unspent outputs
+// of a wallet, e.g. scanned with the view key. there is no fee in the kernel,
+// so the costs are the transaction size and the change output, which becomes
+// one more small output to spend later.
+//
+// the largest first selection spends the fewest inputs, the branch and bound
+// selection searches an exact match of the amount to save the change output,
+// and falls back to the largest first, and the consolidation fills all the
+// remaining input slots with the smallest outputs, so the dust is merged into
+// the change.
+const (
+	CoinSelectionLargestFirst   = "largest-first"
+	CoinSelectionBranchAndBound = "branch-and-bound"
+	CoinSelectionConsolidate    = "consolidate"
+
+	coinSelectionBranchTries = 100000
+)
+
+type CoinSelection struct {
+	Inputs []*TransferInput
+	Total  Integer
+	Change Integer
+}
+
+func SelectInputs(inputs []*TransferInput, amount Integer, strategy string) (*CoinSelection, error) {
+	if amount.Sign() <= 0 {
+		return nil, Errorf(ErrorInvalidAmount, "invalid coin selection amount %s", amount)
+	}
+	sorted := make([]*TransferInput, len(inputs))
+	for i, in := range inputs {
+		if in.Amount.Sign() <= 0 {
+			return nil, Errorf(ErrorInvalidInput, "invalid coin selection input %s:%d", in.Hash, in.Index)
+		}
+		sorted[i] = in
+	}
+	slices.SortFunc(sorted, compareTransferInputs)
+
+	var selected []*TransferInput
+	var err error
+	switch strategy {
+	case CoinSelectionLargestFirst:
+		selected, err = selectLargestFirst(sorted, amount)
+	case CoinSelectionBranchAndBound:
+		selected = selectBranchAndBound(sorted, amount)
+		if selected == nil {
+			selected, err = selectLargestFirst(sorted, amount)
+		}
+	case CoinSelectionConsolidate:
+		selected, err = selectLargestFirst(sorted, amount)
+		if err == nil {
+			selected = consolidateInputs(sorted, selected)
+		}
+	default:
+		return nil, Errorf(ErrorInvalidFormat, "invalid coin selection strategy %s", strategy)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	total := Zero
+	for _, in := range selected {
+		total = total.Add(in.Amount)
+	}
+	return &CoinSelection{
+		Inputs: selected,
+		Total:  total,
+		Change: total.Sub(amount),
+	}, nil
+}
+
+func selectLargestFirst(sorted []*TransferInput, amount Integer) ([]*TransferInput, error) {
+	total := Zero
+	for i, in := range sorted {
+		total = total.Add(in.Amount)
+		if total.Cmp(amount) < 0 {
+			continue
+		}
+		if i >= SliceCountLimit {
+			return nil, Errorf(ErrorExceedsCountLimit, "too many coin selection inputs %d for %s", i+1, amount)
+		}
+		return slices.Clone(sorted[:i+1]), nil
+	}
+	return nil, Errorf(ErrorInsufficientInputs, "insufficient coin selection inputs %s %s", total, amount)
+}
+
+// the search includes or excludes each input from the largest, and prunes the
+// branch over the amount, or unable to reach it with all the remaining inputs,
+// the inputs with the same amount as an excluded one are also excluded, because
+// they would lead to the same sums.
+func selectBranchAndBound(sorted []*TransferInput, amount Integer) []*TransferInput {
+	remaining := make([]Integer, len(sorted)+1)
+	remaining[len(sorted)] = Zero
+	for i := len(sorted) - 1; i >= 0; i-- {
+		remaining[i] = remaining[i+1].Add(sorted[i].Amount)
+	}
+
+	var selected []*TransferInput
+	var tries int
+	var search func(i int, sum Integer) bool
+	search = func(i int, sum Integer) bool {
+		if sum.Cmp(amount) == 0 {
+			return true
+		}
+		if i == len(sorted) || len(selected) >= SliceCountLimit {
+			return false
+		}
+		if tries++; tries > coinSelectionBranchTries {
+			return false
+		}
+		if sum.Add(remaining[i]).Cmp(amount) < 0 {
+			return false
+		}
+		if next := sum.Add(sorted[i].Amount); next.Cmp(amount) <= 0 {
+			selected = append(selected, sorted[i])
+			if search(i+1, next) {
+				return true
+			}
+			selected = selected[:len(selected)-1]
+		}
+		j := i + 1
+		for j < len(sorted) && sorted[j].Amount.Cmp(sorted[i].Amount) == 0 {
+			j++
+		}
+		return search(j, sum)
+	}
+	if search(0, Zero) {
+		return selected
+	}
+	return nil
+}
+
+// the selected inputs are the largest ones, so the smallest ones are appended
+// from the end of the sorted inputs until the input slots are full.
+func consolidateInputs(sorted, selected []*TransferInput) []*TransferInput {
+	largest := len(selected)
+	for i := len(sorted) - 1; i >= largest && len(selected) < SliceCountLimit; i-- {
+		selected = append(selected, sorted[i])
+	}
+	return selected
+}
+
+// the inputs are sorted by the amount from the largest, then by the hash and
+// the index, so the selection is the same for the same outputs.
+func compareTransferInputs(a, b *TransferInput) int {
+	if c := b.Amount.Cmp(a.Amount); c != 0 {
+		return c
+	}
+	if c := bytes.Compare(a.Hash[:], b.Hash[:]); c != 0 {
+		return c
+	}
+	return int(a.Index) - int(b.Index)
+}
@@ -0,0 +1,133 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoinSelection(t *testing.T) {
+	require := require.New(t)
+
+	inputs := make([]*TransferInput, 0)
+	for i, a := range []string{"3", "1", "8", "2", "5"} {
+		inputs = append(inputs, &TransferInput{
+			Hash:   crypto.Blake3Hash([]byte{byte(i)}),
+			Index:  uint(i),
+			Amount: NewIntegerFromString(a),
+		})
+	}
+	amounts := func(s *CoinSelection) []string {
+		var res []string
+		for _, in := range s.Inputs {
+			res = append(res, in.Amount.String())
+		}
+		return res
+	}
+
+	_, err := SelectInputs(inputs, Zero, CoinSelectionLargestFirst)
+	require.Equal(ErrorInvalidAmount, ErrorCodeOf(err))
+	_, err = SelectInputs(inputs, NewInteger(1), "smallest-first")
+	require.Equal(ErrorInvalidFormat, ErrorCodeOf(err))
+	_, err = SelectInputs(append(inputs, &TransferInput{}), NewInteger(1), CoinSelectionLargestFirst)
+	require.Equal(ErrorInvalidInput, ErrorCodeOf(err))
+	for _, s := range []string{CoinSelectionLargestFirst, CoinSelectionBranchAndBound, CoinSelectionConsolidate} {
+		_, err = SelectInputs(inputs, NewInteger(20), s)
+		require.Equal(ErrorInsufficientInputs, ErrorCodeOf(err))
+	}
+
+	s, err := SelectInputs(inputs, NewInteger(9), CoinSelectionLargestFirst)
+	require.Nil(err)
+	require.Equal([]string{"8.00000000", "5.00000000"}, amounts(s))
+	require.Equal("13.00000000", s.Total.String())
+	require.Equal("4.00000000", s.Change.String())
+
+	s, err = SelectInputs(inputs, NewInteger(9), CoinSelectionBranchAndBound)
+	require.Nil(err)
+	require.Equal([]string{"8.00000000", "1.00000000"}, amounts(s))
+	require.Equal("0.00000000", s.Change.String())
+	s, err = SelectInputs(inputs, NewInteger(19), CoinSelectionBranchAndBound)
+	require.Nil(err)
+	require.Len(s.Inputs, 5)
+	require.Equal("0.00000000", s.Change.String())
+	s, err = SelectInputs(inputs, NewIntegerFromString("9.5"), CoinSelectionBranchAndBound)
+	require.Nil(err)
+	require.Equal([]string{"8.00000000", "5.00000000"}, amounts(s))
+	require.Equal("3.50000000", s.Change.String())
+
+	s, err = SelectInputs(inputs, NewInteger(9), CoinSelectionConsolidate)
+	require.Nil(err)
+	require.Equal([]string{"8.00000000", "5.00000000", "1.00000000", "2.00000000", "3.00000000"}, amounts(s))
+	require.Equal("10.00000000", s.Change.String())
+
+	dust := make([]*TransferInput, 0)
+	for i := range 400 {
+		dust = append(dust, &TransferInput{
+			Hash:   crypto.Blake3Hash([]byte{byte(i), byte(i >> 8)}),
+			Index:  uint(i),
+			Amount: NewIntegerFromString("0.001"),
+		})
+	}
+	_, err = SelectInputs(dust, NewIntegerFromString("0.3"), CoinSelectionLargestFirst)
+	require.Equal(ErrorExceedsCountLimit, ErrorCodeOf(err))
+	_, err = SelectInputs(dust, NewIntegerFromString("0.3"), CoinSelectionBranchAndBound)
+	require.Equal(ErrorExceedsCountLimit, ErrorCodeOf(err))
+	s, err = SelectInputs(dust, NewIntegerFromString("0.1"), CoinSelectionBranchAndBound)
+	require.Nil(err)
+	require.Len(s.Inputs, 100)
+	require.Equal("0.00000000", s.Change.String())
+
+	s, err = SelectInputs(append(dust, inputs...), NewInteger(9), CoinSelectionConsolidate)
+	require.Nil(err)
+	require.Len(s.Inputs, SliceCountLimit)
+	require.Equal("8.00000000", s.Inputs[0].Amount.String())
+	require.Equal("5.00000000", s.Inputs[1].Amount.String())
+	require.Equal("0.00100000", s.Inputs[2].Amount.String())
+	require.Equal("4.25400000", s.Change.String())
+
+	again, err := SelectInputs(append(inputs, dust...), NewInteger(9), CoinSelectionConsolidate)
+	require.Nil(err)
+	require.Equal(s.Inputs, again.Inputs)
+}
+
+func TestBatchTransferWithSelection(t *testing.T) {
+	require := require.New(t)
+
+	seed := make([]byte, 64)
+	crypto.ReadRand(seed)
+	change := randomAccount()
+	inputs := make([]*TransferInput, 0)
+	for i, a := range []string{"3", "1", "8", "2", "5"} {
+		inputs = append(inputs, &TransferInput{
+			Hash:   crypto.Blake3Hash([]byte{byte(i)}),
+			Index:  uint(i),
+			Amount: NewIntegerFromString(a),
+		})
+	}
+	a, b := randomAccount(), randomAccount()
+	recipients := []*TransferRecipient{
+		{Address: &a, Amount: NewIntegerFromString("4.5")},
+		{Address: &b, Amount: NewIntegerFromString("4.5")},
+	}
+
+	_, _, err := NewBatchTransferWithSelection(XINAssetId, inputs, recipients, &change, nil, seed, "")
+	require.NotNil(err)
+
+	tx, rest, err := NewBatchTransferWithSelection(XINAssetId, inputs, recipients, nil, nil, seed, CoinSelectionBranchAndBound)
+	require.Nil(err)
+	require.Len(rest, 0)
+	require.Len(tx.Inputs, 2)
+	require.Equal(inputs[2].Hash, tx.Inputs[0].Hash)
+	require.Equal(inputs[1].Hash, tx.Inputs[1].Hash)
+	require.Len(tx.Outputs, 2)
+
+	tx, _, err = NewBatchTransferWithSelection(XINAssetId, inputs, recipients, &change, nil, seed, CoinSelectionLargestFirst)
+	require.Nil(err)
+	require.Len(tx.Inputs, 2)
+	require.Equal(inputs[4].Hash, tx.Inputs[1].Hash)
+	require.Len(tx.Outputs, 3)
+	require.Equal("4.00000000", tx.Outputs[2].Amount.String())
+	_, found := tx.Outputs[2].ViewKeyIndex(&change, 2)
+	require.True(found)
+}
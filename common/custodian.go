@@ -32,7 +32,7 @@ type CustodianNode struct {
 
 func (cn *CustodianNode) validate() error {
 	if cn.Payee.PublicSpendKey == cn.Custodian.PublicSpendKey {
-		return fmt.Errorf("invalid custodian or payee keys %x", cn.Extra)
+		return Errorf(ErrorInvalidCustodian, "invalid custodian or payee keys %x", cn.Extra)
 	}
 
 	eh := crypto.Blake3Hash(cn.Extra[:161])
@@ -40,10 +40,10 @@ func (cn *CustodianNode) validate() error {
 	copy(payeeSig[:], cn.Extra[225:289])
 	copy(custodianSig[:], cn.Extra[289:custodianNodeExtraSize])
 	if !cn.Payee.PublicSpendKey.Verify(eh, payeeSig) {
-		return fmt.Errorf("invalid custodian update payee signature %x", cn.Extra)
+		return Errorf(ErrorInvalidSignature, "invalid custodian update payee signature %x", cn.Extra)
 	}
 	if !cn.Custodian.PublicSpendKey.Verify(eh, custodianSig) {
-		return fmt.Errorf("invalid custodian update custodian signature %x", cn.Extra)
+		return Errorf(ErrorInvalidSignature, "invalid custodian update custodian signature %x", cn.Extra)
 	}
 	return nil
 }
@@ -72,12 +72,16 @@ func EncodeCustodianNode(custodian, payee *Address, signerSpend, payeeSpend, cus
 	return extra
 }
 
+func DecodeCustodianNode(extra []byte) (*CustodianNode, error) {
+	return parseCustodianNode(extra, false)
+}
+
 func parseCustodianNode(extra []byte, genesis bool) (*CustodianNode, error) {
 	if len(extra) != custodianNodeExtraSize {
-		return nil, fmt.Errorf("invalid custodian node data %x", extra)
+		return nil, Errorf(ErrorInvalidCustodian, "invalid custodian node data %x", extra)
 	}
 	if extra[0] != custodianNodeActionUpdate {
-		return nil, fmt.Errorf("invalid custodian update action %x", extra)
+		return nil, Errorf(ErrorInvalidCustodian, "invalid custodian update action %x", extra)
 	}
 	var cn CustodianNode
 	cn.Extra = make([]byte, len(extra))
@@ -93,9 +97,65 @@ func parseCustodianNode(extra []byte, genesis bool) (*CustodianNode, error) {
 	return &cn, nil
 }
 
+// EncodeCustodianUpdateNodesExtra sorts the signed node extras of the new
+// custodian, and the result should be signed by the current custodian as
+// the approval signature appended to the transaction extra.
+func EncodeCustodianUpdateNodesExtra(custodian *Address, nodes [][]byte) ([]byte, error) {
+	if len(nodes) < custodianNodesMinimumCount {
+		return nil, Errorf(ErrorInvalidCustodian, "invalid custodian nodes count %d", len(nodes))
+	}
+	cns := make([]*CustodianNode, len(nodes))
+	for i, n := range nodes {
+		cn, err := parseCustodianNode(n, false)
+		if err != nil {
+			return nil, err
+		}
+		cns[i] = cn
+	}
+	sort.Slice(cns, func(i, j int) bool {
+		return bytes.Compare(cns[i].Custodian.PublicSpendKey[:], cns[j].Custodian.PublicSpendKey[:]) < 0
+	})
+	extra := append(custodian.PublicSpendKey[:], custodian.PublicViewKey[:]...)
+	for _, n := range cns {
+		extra = append(extra, n.Extra...)
+	}
+	var sig crypto.Signature
+	_, err := ParseCustodianUpdateNodesExtra(append(bytes.Clone(extra), sig[:]...), false)
+	if err != nil {
+		return nil, err
+	}
+	return extra, nil
+}
+
+// CustodianUpdateNodesPrice is the minimum output amount to update the nodes,
+// new custodian nodes cost more than the payee updates of the existing ones.
+func CustodianUpdateNodesPrice(prev *CustodianUpdateRequest, nodes []*CustodianNode) Integer {
+	filter := make(map[string]string)
+	for _, n := range prev.Nodes {
+		filter[n.Custodian.String()] = n.Payee.String()
+	}
+	total := Zero
+	newPrice := NewInteger(custodianNodeNewPrice)
+	udpatePrice := NewInteger(custodianNodeUpdatePrice)
+	for _, n := range nodes {
+		old, found := filter[n.Custodian.String()]
+		if !found {
+			total = total.Add(newPrice)
+		} else if old != n.Payee.String() {
+			total = total.Add(udpatePrice)
+		}
+	}
+	return total
+}
+
 func ParseCustodianUpdateNodesExtra(extra []byte, genesis bool) (*CustodianUpdateRequest, error) {
-	if len(extra) < 64+custodianNodeExtraSize*custodianNodesMinimumCount+64 {
-		return nil, fmt.Errorf("invalid custodian update extra %x", extra)
+	// the genesis nodes count is checked by ReadGenesis, and a dev genesis may have a single node
+	minimum := custodianNodesMinimumCount
+	if genesis {
+		minimum = 1
+	}
+	if len(extra) < 64+custodianNodeExtraSize*minimum+64 {
+		return nil, Errorf(ErrorInvalidCustodian, "invalid custodian update extra %x", extra)
 	}
 	var custodian Address
 	copy(custodian.PublicSpendKey[:], extra[:32])
@@ -106,7 +166,7 @@ func ParseCustodianUpdateNodesExtra(extra []byte, genesis bool) (*CustodianUpdat
 	// 1 || custodian (Address) || payee (Address) || node id (Hash) || signerSig || payeeSig || custodianSig
 	nodesExtra := extra[64 : len(extra)-64]
 	if len(nodesExtra)%custodianNodeExtraSize != 0 {
-		return nil, fmt.Errorf("invalid custodian update extra %x", extra)
+		return nil, Errorf(ErrorInvalidCustodian, "invalid custodian update extra %x", extra)
 	}
 	nodes := make([]*CustodianNode, len(nodesExtra)/custodianNodeExtraSize)
 	uniqueKeys := make(map[crypto.Key]bool)
@@ -117,7 +177,7 @@ func ParseCustodianUpdateNodesExtra(extra []byte, genesis bool) (*CustodianUpdat
 			return nil, err
 		}
 		if uniqueKeys[cn.Payee.PublicSpendKey] || uniqueKeys[cn.Custodian.PublicSpendKey] {
-			return nil, fmt.Errorf("duplicate custodian or payee keys %x", cne)
+			return nil, Errorf(ErrorInvalidCustodian, "duplicate custodian or payee keys %x", cne)
 		}
 		uniqueKeys[cn.Payee.PublicSpendKey] = true
 		uniqueKeys[cn.Payee.PublicViewKey] = true
@@ -134,7 +194,7 @@ func ParseCustodianUpdateNodesExtra(extra []byte, genesis bool) (*CustodianUpdat
 		sortedExtra = append(sortedExtra, n.Extra...)
 	}
 	if !bytes.Equal(nodesExtra, sortedExtra) {
-		return nil, fmt.Errorf("invalid custodian nodes extra sort order %x", extra)
+		return nil, Errorf(ErrorInvalidCustodian, "invalid custodian nodes extra sort order %x", extra)
 	}
 
 	return &CustodianUpdateRequest{
@@ -146,20 +206,20 @@ func ParseCustodianUpdateNodesExtra(extra []byte, genesis bool) (*CustodianUpdat
 
 func (tx *Transaction) validateCustodianUpdateNodes(store CustodianReader, now uint64) error {
 	if tx.Version < TxVersionHashSignature {
-		return fmt.Errorf("invalid custodian update version %d", tx.Version)
+		return Errorf(ErrorInvalidCustodian, "invalid custodian update version %d", tx.Version)
 	}
 	if tx.Asset != XINAssetId {
-		return fmt.Errorf("invalid custodian update asset %s", tx.Asset.String())
+		return Errorf(ErrorInvalidAsset, "invalid custodian update asset %s", tx.Asset.String())
 	}
 	if len(tx.Outputs) != 1 {
-		return fmt.Errorf("invalid custodian update outputs count %d", len(tx.Outputs))
+		return Errorf(ErrorInvalidCustodian, "invalid custodian update outputs count %d", len(tx.Outputs))
 	}
 	out := tx.Outputs[0]
 	if out.Type != OutputTypeCustodianUpdateNodes {
-		return fmt.Errorf("invalid custodian update output type %v", out)
+		return Errorf(ErrorInvalidCustodian, "invalid custodian update output type %v", out)
 	}
 	if len(out.Keys) != 1 || out.Script.String() != "fffe40" {
-		return fmt.Errorf("invalid custodian update output receiver %v", out)
+		return Errorf(ErrorInvalidCustodian, "invalid custodian update output receiver %v", out)
 	}
 
 	curs, err := ParseCustodianUpdateNodesExtra(tx.Extra, false)
@@ -167,7 +227,7 @@ func (tx *Transaction) validateCustodianUpdateNodes(store CustodianReader, now u
 		return err
 	}
 	if len(curs.Nodes) < custodianNodesMinimumCount {
-		return fmt.Errorf("invalid custodian nodes count %d", len(curs.Nodes))
+		return Errorf(ErrorInvalidCustodian, "invalid custodian nodes count %d", len(curs.Nodes))
 	}
 
 	prev, err := store.ReadCustodian(now)
@@ -175,11 +235,11 @@ func (tx *Transaction) validateCustodianUpdateNodes(store CustodianReader, now u
 		return err
 	}
 	if prev == nil {
-		return fmt.Errorf("there must be a custodian available %d", now)
+		return Errorf(ErrorInvalidCustodian, "there must be a custodian available %d", now)
 	}
 	eh := crypto.Blake3Hash(tx.Extra[:len(tx.Extra)-64])
 	if !prev.Custodian.PublicSpendKey.Verify(eh, *curs.Signature) {
-		return fmt.Errorf("invalid custodian update approval signature %x", tx.Extra)
+		return Errorf(ErrorInvalidSignature, "invalid custodian update approval signature %x", tx.Extra)
 	}
 
 	filter := make(map[string]string)
@@ -189,27 +249,19 @@ func (tx *Transaction) validateCustodianUpdateNodes(store CustodianReader, now u
 	if len(filter) != len(prev.Nodes) {
 		panic(prev.Custodian.String())
 	}
-	total := Zero
-	newPrice := NewInteger(custodianNodeNewPrice)
-	udpatePrice := NewInteger(custodianNodeUpdatePrice)
 	for _, n := range curs.Nodes {
-		old, found := filter[n.Custodian.String()]
-		if !found {
-			total = total.Add(newPrice)
-		} else if old != n.Payee.String() {
-			total = total.Add(udpatePrice)
-		}
 		delete(filter, n.Custodian.String())
 	}
+	total := CustodianUpdateNodesPrice(prev, curs.Nodes)
 	if out.Amount.Cmp(total) < 0 {
-		return fmt.Errorf("invalid custodian nodes update price %v", out)
+		return Errorf(ErrorInvalidCustodian, "invalid custodian nodes update price %v", out)
 	}
 
 	if curs.Custodian.String() != prev.Custodian.String() {
 		return nil
 	}
 	if len(filter) != 0 || len(prev.Nodes) != len(curs.Nodes) {
-		return fmt.Errorf("custodian account and nodes mismatch %x", tx.Extra)
+		return Errorf(ErrorInvalidCustodian, "custodian account and nodes mismatch %x", tx.Extra)
 	}
 	return nil
 }
@@ -168,3 +168,47 @@ func testBuildAddress(require *require.Assertions) Address {
 	addr.PublicViewKey = addr.PrivateViewKey.Public()
 	return addr
 }
+
+func TestCustodianEncodeUpdateNodes(t *testing.T) {
+	require := require.New(t)
+
+	mainnet, _ := crypto.HashFromString(mainnetId)
+	domain := testBuildAddress(require)
+	custodian := testBuildAddress(require)
+	nodes := make([][]byte, 0)
+	cns := make([]*CustodianNode, 0)
+	for i := 0; i < custodianNodesMinimumCount; i++ {
+		signer := testBuildAddress(require)
+		payee := testBuildAddress(require)
+		custodian := testBuildAddress(require)
+		extra := EncodeCustodianNode(&custodian, &payee, &signer.PrivateSpendKey, &payee.PrivateSpendKey, &custodian.PrivateSpendKey, mainnet)
+		nodes = append(nodes, extra)
+		cns = append(cns, &CustodianNode{custodian, payee, extra})
+	}
+
+	_, err := EncodeCustodianUpdateNodesExtra(&custodian, nodes[1:])
+	require.NotNil(err)
+	_, err = EncodeCustodianUpdateNodesExtra(&custodian, append(nodes[1:], nodes[1]))
+	require.NotNil(err)
+	invalid := bytes.Clone(nodes[0])
+	invalid[300] ^= 1
+	_, err = EncodeCustodianUpdateNodesExtra(&custodian, append(nodes[1:], invalid))
+	require.NotNil(err)
+
+	extra, err := EncodeCustodianUpdateNodesExtra(&custodian, nodes)
+	require.Nil(err)
+	require.Len(extra, 64+custodianNodeExtraSize*custodianNodesMinimumCount)
+	sig := domain.PrivateSpendKey.Sign(crypto.Blake3Hash(extra))
+	cur, err := ParseCustodianUpdateNodesExtra(append(extra, sig[:]...), false)
+	require.Nil(err)
+	require.Equal(custodian.String(), cur.Custodian.String())
+	require.Len(cur.Nodes, custodianNodesMinimumCount)
+
+	prev := &CustodianUpdateRequest{Custodian: &domain}
+	require.Equal("700.00000000", CustodianUpdateNodesPrice(prev, cur.Nodes).String())
+	prev.Nodes = cns
+	require.Equal("0.00000000", CustodianUpdateNodesPrice(prev, cur.Nodes).String())
+	payee := testBuildAddress(require)
+	prev.Nodes = append([]*CustodianNode{{cns[0].Custodian, payee, nil}}, cns[1:]...)
+	require.Equal("1.00000000", CustodianUpdateNodesPrice(prev, cur.Nodes).String())
+}
@@ -38,13 +38,13 @@ func (tx *Transaction) verifyDepositData(store DataStore) error {
 	deposit := tx.Inputs[0].Deposit
 	asset := deposit.Asset()
 	if err := asset.Verify(); err != nil {
-		return fmt.Errorf("invalid asset data %s", err.Error())
+		return Errorf(ErrorInvalidDeposit, "invalid asset data %s", err.Error())
 	}
 	if deposit.Amount.Sign() <= 0 {
-		return fmt.Errorf("invalid amount %s", deposit.Amount.String())
+		return Errorf(ErrorInvalidAmount, "invalid amount %s", deposit.Amount.String())
 	}
 	if strings.TrimSpace(deposit.Transaction) != deposit.Transaction || len(deposit.Transaction) == 0 {
-		return fmt.Errorf("invalid transaction hash %s", deposit.Transaction)
+		return Errorf(ErrorInvalidDeposit, "invalid transaction hash %s", deposit.Transaction)
 	}
 	old, balance, err := store.ReadAssetWithBalance(tx.Asset)
 	if err != nil || old == nil {
@@ -52,26 +52,26 @@ func (tx *Transaction) verifyDepositData(store DataStore) error {
 	}
 	total := balance.Add(deposit.Amount)
 	if total.Cmp(GetAssetCapacity(tx.Asset)) >= 0 {
-		return fmt.Errorf("invalid deposit capacity %s", total.String())
+		return Errorf(ErrorInvalidDeposit, "invalid deposit capacity %s", total.String())
 	}
 	if old.Chain == asset.Chain && old.AssetKey == asset.AssetKey {
 		return nil
 	}
-	return fmt.Errorf("invalid asset info %s %v %v", tx.Asset, *old, *asset)
+	return Errorf(ErrorInvalidAsset, "invalid asset info %s %v %v", tx.Asset, *old, *asset)
 }
 
 func (tx *SignedTransaction) validateDeposit(store DataStore, payloadHash crypto.Hash, sigs []map[uint16]*crypto.Signature, snapTime uint64) error {
 	if len(tx.Inputs) != 1 {
-		return fmt.Errorf("invalid inputs count %d for deposit", len(tx.Inputs))
+		return Errorf(ErrorInvalidDeposit, "invalid inputs count %d for deposit", len(tx.Inputs))
 	}
 	if len(tx.Outputs) != 1 {
-		return fmt.Errorf("invalid outputs count %d for deposit", len(tx.Outputs))
+		return Errorf(ErrorInvalidDeposit, "invalid outputs count %d for deposit", len(tx.Outputs))
 	}
 	if tx.Outputs[0].Type != OutputTypeScript {
-		return fmt.Errorf("invalid deposit output type %d", tx.Outputs[0].Type)
+		return Errorf(ErrorInvalidDeposit, "invalid deposit output type %d", tx.Outputs[0].Type)
 	}
 	if len(sigs) != 1 || len(sigs[0]) != 1 {
-		return fmt.Errorf("invalid signatures count %d for deposit", len(sigs))
+		return Errorf(ErrorInvalidSignature, "invalid signatures count %d for deposit", len(sigs))
 	}
 	err := tx.verifyDepositData(store)
 	if err != nil {
@@ -80,14 +80,14 @@ func (tx *SignedTransaction) validateDeposit(store DataStore, payloadHash crypto
 
 	sig := sigs[0][0]
 	if sig == nil {
-		return fmt.Errorf("invalid custodian signature index for deposit")
+		return Errorf(ErrorInvalidSignature, "invalid custodian signature index for deposit")
 	}
 	custodian, err := store.ReadCustodian(snapTime)
 	if err != nil {
 		return err
 	}
 	if !custodian.Custodian.PublicSpendKey.Verify(payloadHash, *sig) {
-		return fmt.Errorf("invalid custodian signature for deposit")
+		return Errorf(ErrorInvalidSignature, "invalid custodian signature for deposit")
 	}
 
 	locked, err := store.ReadDepositLock(tx.DepositData())
@@ -95,7 +95,7 @@ func (tx *SignedTransaction) validateDeposit(store DataStore, payloadHash crypto
 		return err
 	}
 	if locked.HasValue() && locked != payloadHash {
-		return fmt.Errorf("invalid lock %s %s", locked, payloadHash)
+		return Errorf(ErrorInputLocked, "invalid lock %s %s", locked, payloadHash)
 	}
 	return nil
 }
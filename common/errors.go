@@ -0,0 +1,69 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrorCode names the class of a validation failure, the codes are stable for
+// the clients to tell the failures apart, while the messages may change.
+type ErrorCode string
+
+const (
+	ErrorInvalidFormat        ErrorCode = "invalid_format"
+	ErrorExceedsCountLimit    ErrorCode = "exceeds_count_limit"
+	ErrorExceedsExtraLimit    ErrorCode = "exceeds_extra_limit"
+	ErrorExceedsSizeLimit     ErrorCode = "exceeds_size_limit"
+	ErrorInvalidSignature     ErrorCode = "invalid_signature"
+	ErrorInputNotFound        ErrorCode = "input_not_found"
+	ErrorInputLocked          ErrorCode = "input_locked"
+	ErrorReferenceNotFound    ErrorCode = "reference_not_found"
+	ErrorInvalidInput         ErrorCode = "invalid_input"
+	ErrorInvalidOutput        ErrorCode = "invalid_output"
+	ErrorInvalidAmount        ErrorCode = "invalid_amount"
+	ErrorAmountMismatch       ErrorCode = "amount_mismatch"
+	ErrorInsufficientInputs   ErrorCode = "insufficient_inputs"
+	ErrorInvalidScript        ErrorCode = "invalid_script"
+	ErrorInvalidExtra         ErrorCode = "invalid_extra"
+	ErrorInvalidAsset         ErrorCode = "invalid_asset"
+	ErrorInvalidDeposit       ErrorCode = "invalid_deposit"
+	ErrorInvalidDepositProof  ErrorCode = "invalid_deposit_proof"
+	ErrorInvalidMint          ErrorCode = "invalid_mint"
+	ErrorInvalidWithdrawal    ErrorCode = "invalid_withdrawal"
+	ErrorInvalidNodeOperation ErrorCode = "invalid_node_operation"
+	ErrorInvalidCustodian     ErrorCode = "invalid_custodian"
+	ErrorInvalidTimestamp     ErrorCode = "invalid_timestamp"
+	ErrorInvalidSnapshot      ErrorCode = "invalid_snapshot"
+	ErrorInvalidPeer          ErrorCode = "invalid_peer"
+	ErrorQueueFull            ErrorCode = "queue_full"
+)
+
+// Error is a validation failure with the code, the message is the same as the
+// plain error before the codes, and the wrapped errors are kept for errors.Is.
+type Error struct {
+	Code ErrorCode
+	err  error
+}
+
+// Errorf formats the message as fmt.Errorf, and the %w verb wraps the error.
+func Errorf(code ErrorCode, format string, args ...any) error {
+	return &Error{Code: code, err: fmt.Errorf(format, args...)}
+}
+
+func (e *Error) Error() string {
+	return e.err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return errors.Unwrap(e.err)
+}
+
+// ErrorCodeOf returns the code of the first coded error in the chain, or an
+// empty code for the other errors, e.g. of the store.
+func ErrorCodeOf(err error) ErrorCode {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return ""
+}
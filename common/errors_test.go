@@ -0,0 +1,29 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorCodes(t *testing.T) {
+	require := require.New(t)
+
+	err := Errorf(ErrorInputLocked, "input locked for transaction %s", "abcd")
+	require.Equal("input locked for transaction abcd", err.Error())
+	require.Equal(ErrorInputLocked, ErrorCodeOf(err))
+	require.Equal(ErrorInputLocked, ErrorCodeOf(fmt.Errorf("queue %w", err)))
+	require.Equal(ErrorCode(""), ErrorCodeOf(errors.New("input locked")))
+	require.Equal(ErrorCode(""), ErrorCodeOf(nil))
+
+	err = Errorf(ErrorInvalidFormat, "%w", io.ErrUnexpectedEOF)
+	require.ErrorIs(err, io.ErrUnexpectedEOF)
+	require.Equal(io.ErrUnexpectedEOF.Error(), err.Error())
+
+	_, err = UnmarshalVersionedTransaction([]byte{0x77, 0x77, 0x00})
+	require.NotNil(err)
+	require.Equal(ErrorInvalidFormat, ErrorCodeOf(err))
+}
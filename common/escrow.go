@@ -0,0 +1,109 @@
+package common
+
+import (
+	"encoding/binary"
+
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+const (
+	OperatorEscrow   = 0xfd
+	EscrowScriptSize = 4 + 8 + 32
+)
+
+// EscrowScript is the two-stage script of a refundable escrow output, the
+// first receiver keys of the output are able to spend it before the deadline
+// with the secret as the extra of the spending transaction, and the rest
+// sender keys are able to spend it at or after the deadline without secret.
+//
+// [OperatorEscrow][receiver keys][receiver threshold][sender threshold]
+// [deadline uint64][blake3 hash lock of the secret]
+type EscrowScript struct {
+	ReceiverKeys      uint8
+	ReceiverThreshold uint8
+	SenderThreshold   uint8
+	Deadline          uint64
+	HashLock          crypto.Hash
+}
+
+func NewEscrowScript(receiverKeys, receiverThreshold, senderThreshold uint8, deadline uint64, secret []byte) Script {
+	es := &EscrowScript{
+		ReceiverKeys:      receiverKeys,
+		ReceiverThreshold: receiverThreshold,
+		SenderThreshold:   senderThreshold,
+		Deadline:          deadline,
+		HashLock:          crypto.Blake3Hash(secret),
+	}
+	return es.Script()
+}
+
+func (es *EscrowScript) Script() Script {
+	s := Script{OperatorEscrow, es.ReceiverKeys, es.ReceiverThreshold, es.SenderThreshold}
+	s = binary.BigEndian.AppendUint64(s, es.Deadline)
+	return append(s, es.HashLock[:]...)
+}
+
+func (s Script) IsEscrow() bool {
+	return len(s) > 0 && s[0] == OperatorEscrow
+}
+
+func (s Script) Escrow() (*EscrowScript, error) {
+	if len(s) != EscrowScriptSize || s[0] != OperatorEscrow {
+		return nil, Errorf(ErrorInvalidScript, "invalid escrow script %s", s)
+	}
+	es := &EscrowScript{
+		ReceiverKeys:      s[1],
+		ReceiverThreshold: s[2],
+		SenderThreshold:   s[3],
+		Deadline:          binary.BigEndian.Uint64(s[4:12]),
+	}
+	copy(es.HashLock[:], s[12:])
+	if es.ReceiverThreshold == 0 || es.ReceiverThreshold > es.ReceiverKeys {
+		return nil, Errorf(ErrorInvalidScript, "invalid escrow receiver threshold %d/%d", es.ReceiverThreshold, es.ReceiverKeys)
+	}
+	if es.ReceiverKeys > Operator64 {
+		return nil, Errorf(ErrorInvalidScript, "invalid escrow receiver keys %d", es.ReceiverKeys)
+	}
+	if es.SenderThreshold == 0 || es.SenderThreshold > Operator64 {
+		return nil, Errorf(ErrorInvalidScript, "invalid escrow sender threshold %d", es.SenderThreshold)
+	}
+	if es.Deadline == 0 {
+		return nil, Errorf(ErrorInvalidScript, "invalid escrow deadline %d", es.Deadline)
+	}
+	return es, nil
+}
+
+func (es *EscrowScript) VerifyKeys(count int) error {
+	senders := count - int(es.ReceiverKeys)
+	if senders < int(es.SenderThreshold) {
+		return Errorf(ErrorInvalidScript, "invalid escrow keys %d %d %d", count, es.ReceiverKeys, es.SenderThreshold)
+	}
+	return nil
+}
+
+// Validate checks the signed key indexes of the escrow output, the receiver
+// stage requires the secret before the deadline, and the sender stage starts
+// at the deadline, the signatures of the other stage keys are not counted.
+func (es *EscrowScript) Validate(signers []int, extra []byte, timestamp uint64) error {
+	var receivers, senders int
+	for _, i := range signers {
+		if i < int(es.ReceiverKeys) {
+			receivers += 1
+		} else {
+			senders += 1
+		}
+	}
+	if timestamp >= es.Deadline {
+		if senders < int(es.SenderThreshold) {
+			return Errorf(ErrorInvalidSignature, "invalid escrow sender signatures %d %d", senders, es.SenderThreshold)
+		}
+		return nil
+	}
+	if crypto.Blake3Hash(extra) != es.HashLock {
+		return Errorf(ErrorInvalidSignature, "invalid escrow secret for hash lock %s", es.HashLock)
+	}
+	if receivers < int(es.ReceiverThreshold) {
+		return Errorf(ErrorInvalidSignature, "invalid escrow receiver signatures %d %d", receivers, es.ReceiverThreshold)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEscrowScript(t *testing.T) {
+	require := require.New(t)
+
+	deadline := uint64(time.Now().UnixNano())
+	secret := []byte("escrow secret")
+	script := NewEscrowScript(1, 1, 1, deadline, secret)
+	require.Len(script, EscrowScriptSize)
+	require.True(script.IsEscrow())
+	require.Nil(script.VerifyFormat())
+	require.False(NewThresholdScript(1).IsEscrow())
+	es, err := script.Escrow()
+	require.Nil(err)
+	require.Equal(deadline, es.Deadline)
+	require.Equal(crypto.Blake3Hash(secret), es.HashLock)
+	require.NotNil(es.VerifyKeys(1))
+	require.Nil(es.VerifyKeys(2))
+	require.NotNil(NewEscrowScript(1, 2, 1, deadline, secret).VerifyFormat())
+	require.NotNil(NewEscrowScript(1, 1, 0, deadline, secret).VerifyFormat())
+	require.NotNil(NewEscrowScript(1, 1, 1, 0, secret).VerifyFormat())
+	require.NotNil(script[:20].VerifyFormat())
+
+	accounts := make([]*Address, 0)
+	for i := 0; i < 2; i++ {
+		a := randomAccount()
+		accounts = append(accounts, &a)
+	}
+	seed := make([]byte, 64)
+	crypto.ReadRand(seed)
+	store := escrowStoreImpl{storeImpl{seed: seed, accounts: accounts}, script}
+
+	build := func(extra []byte, signer *Address) *VersionedTransaction {
+		tx := NewTransactionV5(XINAssetId)
+		tx.AddInput(crypto.Blake3Hash([]byte("escrow")), 0)
+		tx.AddRandomScriptOutput([]*Address{signer}, NewThresholdScript(1), NewInteger(10000))
+		tx.Extra = extra
+		ver := tx.AsVersioned()
+		err := ver.SignInput(store, 0, []*Address{signer})
+		require.Nil(err)
+		return ver
+	}
+
+	ver := build(secret, accounts[0])
+	require.Nil(ver.Validate(store, deadline-1, false, activeForks))
+	err = ver.Validate(store, deadline, false, activeForks)
+	require.NotNil(err)
+	require.Contains(err.Error(), "invalid escrow sender signatures")
+	require.Equal(ErrorInvalidSignature, ErrorCodeOf(err))
+	ver = build([]byte("wrong secret"), accounts[0])
+	err = ver.Validate(store, deadline-1, false, activeForks)
+	require.NotNil(err)
+	require.Contains(err.Error(), "invalid escrow secret")
+
+	ver = build(nil, accounts[1])
+	require.Nil(ver.Validate(store, deadline, false, activeForks))
+	err = ver.Validate(store, deadline-1, false, activeForks)
+	require.NotNil(err)
+	require.Contains(err.Error(), "invalid escrow secret")
+	ver = build(secret, accounts[1])
+	err = ver.Validate(store, deadline-1, false, activeForks)
+	require.NotNil(err)
+	require.Contains(err.Error(), "invalid escrow receiver signatures")
+
+	tx := NewTransactionV5(XINAssetId)
+	tx.AddInput(crypto.Blake3Hash([]byte("escrow")), 0)
+	tx.AddRandomScriptOutput(accounts, NewEscrowScript(1, 1, 1, deadline, secret), NewInteger(10000))
+	ver = tx.AsVersioned()
+	err = ver.SignInput(store, 0, accounts[1:])
+	require.Nil(err)
+	require.Nil(ver.Validate(store, deadline, false, activeForks))
+	err = ver.Validate(store, deadline, false, ConsensusForks{})
+	require.NotNil(err)
+	require.Contains(err.Error(), "escrow script not activated")
+	require.Equal(ErrorInvalidScript, ErrorCodeOf(err))
+}
+
+type escrowStoreImpl struct {
+	storeImpl
+	script Script
+}
+
+func (store escrowStoreImpl) ReadUTXOKeys(hash crypto.Hash, index uint) (*UTXOKeys, error) {
+	utxo, err := store.ReadUTXOLock(hash, index)
+	if err != nil {
+		return nil, err
+	}
+	return &UTXOKeys{Mask: utxo.Mask, Keys: utxo.Keys}, nil
+}
+
+func (store escrowStoreImpl) ReadUTXOLock(hash crypto.Hash, index uint) (*UTXOWithLock, error) {
+	r := crypto.NewKeyFromSeed(store.seed)
+	utxo := &UTXOWithLock{
+		UTXO: UTXO{
+			Input: Input{Hash: hash, Index: index},
+			Output: Output{
+				Type:   OutputTypeScript,
+				Amount: NewInteger(10000),
+				Script: store.script,
+				Mask:   r.Public(),
+			},
+			Asset: XINAssetId,
+		},
+	}
+	for _, a := range store.accounts {
+		key := crypto.DeriveGhostPublicKey(&r, &a.PublicViewKey, &a.PublicSpendKey, uint64(index))
+		utxo.Keys = append(utxo.Keys, key)
+	}
+	return utxo, nil
+}
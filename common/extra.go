@@ -0,0 +1,161 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+// ExtraSchema describes a structured transaction extra payload, identified by
+// the transaction type and an optional application prefix of the extra. The
+// prefix is stripped before the payload is passed to Validate or Decode.
+type ExtraSchema struct {
+	Name            string
+	TransactionType uint8
+	Prefix          []byte
+	Validate        func(tx *VersionedTransaction, payload []byte) error
+	Decode          func(payload []byte) (map[string]any, error)
+}
+
+var extraSchemas struct {
+	sync.RWMutex
+	list []*ExtraSchema
+}
+
+func init() {
+	for _, es := range []*ExtraSchema{{
+		Name:            "node-pledge",
+		TransactionType: TransactionTypeNodePledge,
+		Validate:        validateNodePledgeExtra,
+		Decode:          decodeNodePledgeExtra,
+	}, {
+		Name:            "custodian-update-nodes",
+		TransactionType: TransactionTypeCustodianUpdateNodes,
+		Validate:        validateCustodianUpdateNodesExtra,
+		Decode:          decodeCustodianUpdateNodesExtra,
+	}} {
+		err := RegisterExtraSchema(es)
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+// RegisterExtraSchema makes the schema available to ValidateExtraSchema and
+// DecodeExtraSchema, the name and the type with prefix pair must be unique.
+func RegisterExtraSchema(es *ExtraSchema) error {
+	if es.Name == "" {
+		return fmt.Errorf("invalid extra schema name")
+	}
+	if es.Validate == nil && es.Decode == nil {
+		return fmt.Errorf("invalid extra schema %s without validate or decode", es.Name)
+	}
+	if len(es.Prefix) > ExtraSizeGeneralLimit {
+		return fmt.Errorf("invalid extra schema %s prefix size %d", es.Name, len(es.Prefix))
+	}
+
+	extraSchemas.Lock()
+	defer extraSchemas.Unlock()
+
+	for _, o := range extraSchemas.list {
+		if o.Name == es.Name {
+			return fmt.Errorf("duplicate extra schema name %s", es.Name)
+		}
+		if o.TransactionType == es.TransactionType && bytes.Equal(o.Prefix, es.Prefix) {
+			return fmt.Errorf("duplicate extra schema %s prefix %x with %s", es.Name, es.Prefix, o.Name)
+		}
+	}
+	extraSchemas.list = append(extraSchemas.list, es)
+	return nil
+}
+
+// MatchExtraSchema returns the schema with the longest prefix of the extra
+// among the schemas of the transaction type, and the payload after the prefix.
+func MatchExtraSchema(tx *VersionedTransaction) (*ExtraSchema, []byte) {
+	extraSchemas.RLock()
+	defer extraSchemas.RUnlock()
+
+	txType := tx.TransactionType()
+	var match *ExtraSchema
+	for _, es := range extraSchemas.list {
+		if es.TransactionType != txType || !bytes.HasPrefix(tx.Extra, es.Prefix) {
+			continue
+		}
+		if match == nil || len(es.Prefix) > len(match.Prefix) {
+			match = es
+		}
+	}
+	if match == nil {
+		return nil, nil
+	}
+	return match, tx.Extra[len(match.Prefix):]
+}
+
+// ValidateExtraSchema checks the extra well-formedness against the matched
+// schema, and the extra without any matched schema is always valid.
+func ValidateExtraSchema(tx *VersionedTransaction) error {
+	es, payload := MatchExtraSchema(tx)
+	if es == nil || es.Validate == nil {
+		return nil
+	}
+	err := es.Validate(tx, payload)
+	if err != nil {
+		return Errorf(ErrorInvalidExtra, "invalid extra schema %s %v", es.Name, err)
+	}
+	return nil
+}
+
+// DecodeExtraSchema returns the matched schema name and the decoded extra,
+// or an empty name if no schema matches or the schema has no decoder.
+func DecodeExtraSchema(tx *VersionedTransaction) (string, map[string]any, error) {
+	es, payload := MatchExtraSchema(tx)
+	if es == nil || es.Decode == nil {
+		return "", nil, nil
+	}
+	data, err := es.Decode(payload)
+	return es.Name, data, err
+}
+
+func validateNodePledgeExtra(tx *VersionedTransaction, payload []byte) error {
+	_, err := decodeNodePledgeExtra(payload)
+	return err
+}
+
+func decodeNodePledgeExtra(payload []byte) (map[string]any, error) {
+	if len(payload) != 2*len(crypto.Key{}) {
+		return nil, Errorf(ErrorInvalidExtra, "invalid extra length %d for pledge transaction", len(payload))
+	}
+	var signer, payee crypto.Key
+	copy(signer[:], payload[:len(signer)])
+	copy(payee[:], payload[len(signer):])
+	return map[string]any{
+		"signer": signer.String(),
+		"payee":  payee.String(),
+	}, nil
+}
+
+func validateCustodianUpdateNodesExtra(tx *VersionedTransaction, payload []byte) error {
+	_, err := ParseCustodianUpdateNodesExtra(payload, false)
+	return err
+}
+
+func decodeCustodianUpdateNodesExtra(payload []byte) (map[string]any, error) {
+	cur, err := ParseCustodianUpdateNodesExtra(payload, false)
+	if err != nil {
+		return nil, err
+	}
+	nodes := make([]map[string]any, len(cur.Nodes))
+	for i, n := range cur.Nodes {
+		nodes[i] = map[string]any{
+			"custodian": n.Custodian.String(),
+			"payee":     n.Payee.String(),
+		}
+	}
+	return map[string]any{
+		"custodian": cur.Custodian.String(),
+		"nodes":     nodes,
+		"signature": cur.Signature.String(),
+	}, nil
+}
@@ -3,9 +3,11 @@ package common
 import (
 	"bytes"
 	"encoding/hex"
+	"fmt"
 	"testing"
 	"time"
 
+	"github.com/MixinNetwork/mixin/config"
 	"github.com/MixinNetwork/mixin/crypto"
 	"github.com/stretchr/testify/require"
 )
@@ -56,9 +58,10 @@ func TestTransactionExtraLimit(t *testing.T) {
 	err = ver.AggregateSign(store, aas, seed)
 	require.Nil(err)
 	require.Len(ver.AggregatedSignature.Signers, 3)
-	err = ver.Validate(store, uint64(time.Now().UnixNano()), false)
+	err = ver.Validate(store, uint64(time.Now().UnixNano()), false, activeForks)
 	require.NotNil(err)
 	require.Contains(err.Error(), "invalid extra size 257")
+	require.Equal(ErrorExceedsExtraLimit, ErrorCodeOf(err))
 
 	ver.Outputs[0].Script = NewThresholdScript(64)
 	ver.Outputs[0].Amount = NewIntegerFromString("0.00015")
@@ -67,6 +70,11 @@ func TestTransactionExtraLimit(t *testing.T) {
 	require.Equal(ExtraSizeStorageStep*15, ver.GetExtraLimit())
 	ver.Outputs[0].Amount = NewIntegerFromString("0.01555")
 	require.Equal(ExtraSizeStorageStep*155, ver.GetExtraLimit())
+	config.TransactionExtraSizeMaximum = ExtraSizeStorageStep * 16
+	require.Equal(ExtraSizeStorageStep*155, ver.GetExtraLimit())
+	ver.Outputs[0].Amount = NewIntegerFromString("0.00015")
+	require.Equal(ExtraSizeStorageStep*16, ver.GetExtraLimit())
+	config.TransactionExtraSizeMaximum = config.TransactionExtraSizeMaximumDefault
 	ver.Outputs[0].Amount = NewIntegerFromString("0.40959")
 	require.Equal(ExtraSizeStorageStep*4095, ver.GetExtraLimit())
 	ver.Outputs[0].Amount = NewIntegerFromString("0.40969")
@@ -81,6 +89,73 @@ func TestTransactionExtraLimit(t *testing.T) {
 	err = ver.AggregateSign(store, aas, seed)
 	require.Nil(err)
 	require.Len(ver.AggregatedSignature.Signers, 3)
-	err = ver.Validate(store, uint64(time.Now().UnixNano()), false)
+	err = ver.Validate(store, uint64(time.Now().UnixNano()), false, activeForks)
 	require.Nil(err)
 }
+
+func TestExtraSchema(t *testing.T) {
+	require := require.New(t)
+
+	tx := NewTransactionV5(XINAssetId)
+	tx.Outputs = append(tx.Outputs, &Output{Type: OutputTypeNodePledge, Amount: NewInteger(1)})
+	tx.Extra = make([]byte, 63)
+	ver := tx.AsVersioned()
+	es, _ := MatchExtraSchema(ver)
+	require.Equal("node-pledge", es.Name)
+	err := ValidateExtraSchema(ver)
+	require.NotNil(err)
+	require.Contains(err.Error(), "node-pledge")
+	ver.Extra = make([]byte, 64)
+	require.Nil(ValidateExtraSchema(ver))
+	name, data, err := DecodeExtraSchema(ver)
+	require.Nil(err)
+	require.Equal("node-pledge", name)
+	require.Equal(crypto.Key{}.String(), data["signer"])
+
+	decode := func(payload []byte) (map[string]any, error) {
+		return map[string]any{"payload": string(payload)}, nil
+	}
+	validate := func(tx *VersionedTransaction, payload []byte) error {
+		if len(payload) == 0 {
+			return fmt.Errorf("empty payload")
+		}
+		return nil
+	}
+	err = RegisterExtraSchema(&ExtraSchema{Name: "app"})
+	require.NotNil(err)
+	err = RegisterExtraSchema(&ExtraSchema{Name: "app", Prefix: []byte("app:"), Decode: decode})
+	require.Nil(err)
+	err = RegisterExtraSchema(&ExtraSchema{Name: "app", Prefix: []byte("app:v2:"), Decode: decode})
+	require.NotNil(err)
+	err = RegisterExtraSchema(&ExtraSchema{Name: "app-v1", Prefix: []byte("app:"), Decode: decode})
+	require.NotNil(err)
+	err = RegisterExtraSchema(&ExtraSchema{Name: "app-v2", Prefix: []byte("app:v2:"), Validate: validate, Decode: decode})
+	require.Nil(err)
+
+	tx = NewTransactionV5(XINAssetId)
+	tx.Outputs = append(tx.Outputs, &Output{Type: OutputTypeScript, Amount: NewInteger(1)})
+	tx.Extra = []byte("hello")
+	ver = tx.AsVersioned()
+	es, _ = MatchExtraSchema(ver)
+	require.Nil(es)
+	require.Nil(ValidateExtraSchema(ver))
+	name, data, err = DecodeExtraSchema(ver)
+	require.Nil(err)
+	require.Equal("", name)
+	require.Nil(data)
+
+	ver.Extra = []byte("app:hello")
+	name, data, err = DecodeExtraSchema(ver)
+	require.Nil(err)
+	require.Equal("app", name)
+	require.Equal("hello", data["payload"])
+	require.Nil(ValidateExtraSchema(ver))
+
+	ver.Extra = []byte("app:v2:")
+	es, payload := MatchExtraSchema(ver)
+	require.Equal("app-v2", es.Name)
+	require.Len(payload, 0)
+	require.NotNil(ValidateExtraSchema(ver))
+	ver.Extra = []byte("app:v2:hello")
+	require.Nil(ValidateExtraSchema(ver))
+}
@@ -0,0 +1,72 @@
+//go:build fuzz
+
+package common
+
+import (
+	"bytes"
+	"testing"
+)
+
+// the seeds are the transactions and snapshots of the mainnet genesis, run
+// with go test -tags fuzz -fuzz FuzzUnmarshalVersionedTransaction ./common
+func fuzzGenesisSeeds(f *testing.F) ([]*SnapshotWithTopologicalOrder, []*VersionedTransaction) {
+	gns, err := ReadGenesis("../config/genesis.json")
+	if err != nil {
+		f.Fatal(err)
+	}
+	_, snapshots, transactions, err := gns.BuildSnapshots()
+	if err != nil {
+		f.Fatal(err)
+	}
+	return snapshots, transactions
+}
+
+func FuzzUnmarshalVersionedTransaction(f *testing.F) {
+	_, transactions := fuzzGenesisSeeds(f)
+	for _, ver := range transactions {
+		f.Add(ver.Marshal())
+		f.Add(ver.PayloadMarshal())
+	}
+	f.Add([]byte{})
+	f.Add(append(magic, 0, TxVersionHashSignature))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		ver, err := UnmarshalVersionedTransaction(data)
+		if err != nil {
+			return
+		}
+		ver.TransactionType()
+		hash := ver.PayloadHash()
+		ret, err := UnmarshalVersionedTransaction(ver.Marshal())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ret.PayloadHash() != hash {
+			t.Fatalf("malformed transaction %x", data)
+		}
+	})
+}
+
+func FuzzUnmarshalVersionedSnapshot(f *testing.F) {
+	snapshots, _ := fuzzGenesisSeeds(f)
+	for _, s := range snapshots {
+		f.Add(s.VersionedMarshal())
+	}
+	f.Add([]byte{})
+	f.Add(append(magic, 0, SnapshotVersionCommonEncoding))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		s, err := UnmarshalVersionedSnapshot(data)
+		if err != nil {
+			return
+		}
+		hash := s.PayloadHash()
+		ret, err := UnmarshalVersionedSnapshot(s.VersionedMarshal())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ret.PayloadHash() != hash || !bytes.Equal(ret.VersionedMarshal(), s.VersionedMarshal()) {
+			t.Fatalf("malformed snapshot %x", data)
+		}
+	})
+}
@@ -25,12 +25,72 @@ type Genesis struct {
 		Balance   Integer  `json:"balance"`
 	} `json:"nodes"`
 	Custodian *Address `json:"custodian"`
+
+	// Dev genesis allows a single node to finalize snapshots alone, it is
+	// committed in the network id, so never valid for the mainnet.
+	Dev bool `json:"dev,omitempty"`
+
+	// Params tunes the consensus parameters of a private network, they are
+	// committed in the network id, so never valid for the mainnet either.
+	Params *GenesisParams `json:"params,omitempty"`
+}
+
+// GenesisParams are the Go durations, e.g. 500ms or 1h, and the omitted
+// ones are the mainnet defaults. The transaction size and extra size are in
+// bytes, the extra size is the limit without the storage output.
+type GenesisParams struct {
+	RoundGap            string `json:"round_gap,omitempty"`
+	MinimumNodes        int    `json:"minimum_nodes,omitempty"`
+	PledgePeriodMinimum string `json:"pledge_period_minimum,omitempty"`
+	AcceptPeriodMinimum string `json:"accept_period_minimum,omitempty"`
+	AcceptPeriodMaximum string `json:"accept_period_maximum,omitempty"`
+	TransactionSize     int    `json:"transaction_size,omitempty"`
+	OutputsCount        int    `json:"outputs_count,omitempty"`
+	ExtraSize           int    `json:"extra_size,omitempty"`
 }
 
 func (gns *Genesis) EpochTimestamp() uint64 {
 	return uint64(time.Unix(gns.Epoch, 0).UnixNano())
 }
 
+// ConsensusParams returns the validated consensus parameters of the genesis,
+// which are the mainnet defaults without the genesis params.
+func (gns *Genesis) ConsensusParams() (config.ConsensusParams, error) {
+	var params config.ConsensusParams
+	if p := gns.Params; p != nil {
+		var gap time.Duration
+		for _, d := range []struct {
+			name  string
+			value string
+			dst   *time.Duration
+		}{
+			{"round_gap", p.RoundGap, &gap},
+			{"pledge_period_minimum", p.PledgePeriodMinimum, &params.PledgePeriodMinimum},
+			{"accept_period_minimum", p.AcceptPeriodMinimum, &params.AcceptPeriodMinimum},
+			{"accept_period_maximum", p.AcceptPeriodMaximum, &params.AcceptPeriodMaximum},
+		} {
+			if d.value == "" {
+				continue
+			}
+			v, err := time.ParseDuration(d.value)
+			if err != nil || v <= 0 {
+				return params, fmt.Errorf("invalid genesis params %s %s", d.name, d.value)
+			}
+			*d.dst = v
+		}
+		params.RoundGap = uint64(gap)
+		params.MinimumNodes = p.MinimumNodes
+		params.TransactionSize = p.TransactionSize
+		params.OutputsCount = p.OutputsCount
+		params.ExtraSize = p.ExtraSize
+	}
+	err := params.Validate(gns.Dev)
+	if err != nil {
+		return params, fmt.Errorf("invalid genesis params: %v", err)
+	}
+	return params, nil
+}
+
 func (gns *Genesis) NetworkId() crypto.Hash {
 	data, err := json.Marshal(gns)
 	if err != nil {
@@ -178,8 +238,19 @@ func ReadGenesis(path string) (*Genesis, error) {
 	if gns.Custodian == nil {
 		return nil, fmt.Errorf("invalid genesis custodian %v", gns)
 	}
-	if len(gns.Nodes) < config.KernelMinimumNodesCount {
-		return nil, fmt.Errorf("invalid genesis inputs number %d/%d", len(gns.Nodes), config.KernelMinimumNodesCount)
+	params, err := gns.ConsensusParams()
+	if err != nil {
+		return nil, err
+	}
+	minimum := params.MinimumNodes
+	if gns.Dev {
+		minimum = 1
+	}
+	if len(gns.Nodes) < minimum {
+		return nil, fmt.Errorf("invalid genesis inputs number %d/%d", len(gns.Nodes), minimum)
+	}
+	if gns.Params != nil && gns.NetworkId().String() == config.KernelNetworkId {
+		return nil, fmt.Errorf("invalid params genesis for the mainnet")
 	}
 
 	inputsFilter := make(map[string]bool)
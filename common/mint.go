@@ -30,22 +30,22 @@ func (m *MintData) Distribute(tx crypto.Hash) *MintDistribution {
 
 func (tx *VersionedTransaction) validateMint(store DataStore) error {
 	if len(tx.Inputs) != 1 {
-		return fmt.Errorf("invalid inputs count %d for mint", len(tx.Inputs))
+		return Errorf(ErrorInvalidMint, "invalid inputs count %d for mint", len(tx.Inputs))
 	}
 	for _, out := range tx.Outputs {
 		if out.Type != OutputTypeScript {
-			return fmt.Errorf("invalid mint output type %d", out.Type)
+			return Errorf(ErrorInvalidMint, "invalid mint output type %d", out.Type)
 		}
 	}
 	if tx.Asset != XINAssetId {
-		return fmt.Errorf("invalid mint asset %s", tx.Asset.String())
+		return Errorf(ErrorInvalidMint, "invalid mint asset %s", tx.Asset.String())
 	}
 
 	mint := tx.Inputs[0].Mint
 	switch mint.Group {
 	case mintGroupUniversal:
 	default:
-		return fmt.Errorf("invalid mint group %s", mint.Group)
+		return Errorf(ErrorInvalidMint, "invalid mint group %s", mint.Group)
 	}
 
 	dist, err := store.ReadLastMintDistribution(^uint64(0))
@@ -53,13 +53,13 @@ func (tx *VersionedTransaction) validateMint(store DataStore) error {
 		return err
 	}
 	if mint.Batch < dist.Batch {
-		return fmt.Errorf("backward mint batch %d %d", dist.Batch, mint.Batch)
+		return Errorf(ErrorInvalidMint, "backward mint batch %d %d", dist.Batch, mint.Batch)
 	}
 	if mint.Batch > dist.Batch {
 		return nil
 	}
 	if dist.Transaction != tx.PayloadHash() || dist.Amount.Cmp(mint.Amount) != 0 {
-		return fmt.Errorf("invalid mint lock %s %s", dist.Transaction.String(), tx.PayloadHash().String())
+		return Errorf(ErrorInvalidMint, "invalid mint lock %s %s", dist.Transaction.String(), tx.PayloadHash().String())
 	}
 	return nil
 }
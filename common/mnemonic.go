@@ -0,0 +1,81 @@
+package common
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/tyler-smith/go-bip39"
+)
+
+const (
+	MnemonicWordsCount  = 24
+	MnemonicPathDefault = "m/0'"
+)
+
+// NewMnemonic generates a random BIP39 mnemonic of 24 words.
+func NewMnemonic() (string, error) {
+	entropy := make([]byte, MnemonicWordsCount/3*4)
+	crypto.ReadRand(entropy)
+	return bip39.NewMnemonic(entropy)
+}
+
+// NewAddressFromMnemonic derives the address from the BIP39 mnemonic seed
+// and the path, the path is derived in the SLIP-0010 ed25519 way, so all
+// the indexes must be hardened. The derived key and chain code are used
+// as the seed of NewAddressFromSeed.
+func NewAddressFromMnemonic(mnemonic, passphrase, path string) (Address, error) {
+	words := strings.Fields(mnemonic)
+	if len(words) != MnemonicWordsCount {
+		return Address{}, fmt.Errorf("invalid mnemonic words count %d", len(words))
+	}
+	seed, err := bip39.NewSeedWithErrorChecking(strings.Join(words, " "), passphrase)
+	if err != nil {
+		return Address{}, fmt.Errorf("invalid mnemonic %v", err)
+	}
+	indexes, err := parseMnemonicPath(path)
+	if err != nil {
+		return Address{}, err
+	}
+	return NewAddressFromSeed(deriveMnemonicSeed(seed, indexes)), nil
+}
+
+// the result is the 32 bytes key and the 32 bytes chain code
+func deriveMnemonicSeed(seed []byte, indexes []uint32) []byte {
+	mac := hmac.New(sha512.New, []byte("ed25519 seed"))
+	mac.Write(seed)
+	node := mac.Sum(nil)
+	for _, i := range indexes {
+		data := make([]byte, 37)
+		copy(data[1:], node[:32])
+		binary.BigEndian.PutUint32(data[33:], i)
+		mac := hmac.New(sha512.New, node[32:])
+		mac.Write(data)
+		node = mac.Sum(nil)
+	}
+	return node
+}
+
+func parseMnemonicPath(path string) ([]uint32, error) {
+	parts := strings.Split(path, "/")
+	if parts[0] != "m" {
+		return nil, fmt.Errorf("invalid mnemonic path %s", path)
+	}
+	indexes := make([]uint32, len(parts)-1)
+	for i, p := range parts[1:] {
+		n, ok := strings.CutSuffix(p, "'")
+		if !ok {
+			return nil, fmt.Errorf("invalid mnemonic path %s without hardened index", path)
+		}
+		index, err := strconv.ParseUint(n, 10, 31)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mnemonic path %s %v", path, err)
+		}
+		indexes[i] = uint32(index) | 0x80000000
+	}
+	return indexes, nil
+}
@@ -0,0 +1,55 @@
+package common
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMnemonic(t *testing.T) {
+	require := require.New(t)
+
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	node := deriveMnemonicSeed(seed, nil)
+	require.Equal("2b4be7f19ee27bbf30c667b642d5f4aa69fd169872f8fc3059c08ebae2eb19e7", hex.EncodeToString(node[:32]))
+	require.Equal("90046a93de5380a72b5e45010748567d5ea02bbf6522f979e05c0d8d8ca9fffb", hex.EncodeToString(node[32:]))
+	indexes, err := parseMnemonicPath("m/0'")
+	require.Nil(err)
+	node = deriveMnemonicSeed(seed, indexes)
+	require.Equal("68e0fe46dfb67e368c75379acec591dad19df3cde26e63b93a8e704f1dade7a3", hex.EncodeToString(node[:32]))
+	require.Equal("8b59aa11380b624e81507a27fedda59fea6d0b779a778918a2fd3590e16e9c69", hex.EncodeToString(node[32:]))
+
+	_, err = parseMnemonicPath("m/0")
+	require.NotNil(err)
+	_, err = parseMnemonicPath("0'/1'")
+	require.NotNil(err)
+	_, err = parseMnemonicPath("m/2147483648'")
+	require.NotNil(err)
+
+	mnemonic := strings.Repeat("abandon ", 23) + "art"
+	a, err := NewAddressFromMnemonic(mnemonic, "", MnemonicPathDefault)
+	require.Nil(err)
+	b, err := NewAddressFromMnemonic("  "+strings.ReplaceAll(mnemonic, " ", "\n")+"\n", "", "m/0'")
+	require.Nil(err)
+	require.Equal(a.String(), b.String())
+	require.Equal(a.PrivateSpendKey, b.PrivateSpendKey)
+	b, err = NewAddressFromMnemonic(mnemonic, "", "m/1'")
+	require.Nil(err)
+	require.NotEqual(a.String(), b.String())
+	b, err = NewAddressFromMnemonic(mnemonic, "TREZOR", MnemonicPathDefault)
+	require.Nil(err)
+	require.NotEqual(a.String(), b.String())
+
+	_, err = NewAddressFromMnemonic(strings.Repeat("abandon ", 24), "", MnemonicPathDefault)
+	require.NotNil(err)
+	_, err = NewAddressFromMnemonic(strings.Repeat("abandon ", 11)+"about", "", MnemonicPathDefault)
+	require.NotNil(err)
+
+	mnemonic, err = NewMnemonic()
+	require.Nil(err)
+	require.Len(strings.Fields(mnemonic), MnemonicWordsCount)
+	_, err = NewAddressFromMnemonic(mnemonic, "", MnemonicPathDefault)
+	require.Nil(err)
+}
@@ -32,6 +32,7 @@ func (tx *Transaction) NodeTransactionExtraAsSigner() *Address {
 	case TransactionTypeNodePledge:
 	case TransactionTypeNodeAccept:
 	case TransactionTypeNodeRemove:
+	case TransactionTypeNodePayeeChange:
 	default:
 		panic(tx.AsVersioned().PayloadHash())
 	}
@@ -45,20 +46,22 @@ func (tx *Transaction) NodeTransactionExtraAsSigner() *Address {
 
 func (tx *Transaction) validateNodePledge(store DataStore, inputs map[string]*UTXO, snapTime uint64) error {
 	if tx.Asset != XINAssetId {
-		return fmt.Errorf("invalid node asset %s", tx.Asset.String())
+		return Errorf(ErrorInvalidAsset, "invalid node asset %s", tx.Asset.String())
 	}
 	if len(tx.Outputs) != 1 {
-		return fmt.Errorf("invalid outputs count %d for pledge transaction", len(tx.Outputs))
+		return Errorf(ErrorInvalidNodeOperation, "invalid outputs count %d for pledge transaction", len(tx.Outputs))
 	}
 	if len(tx.Inputs) != 1 || len(inputs) != len(tx.Inputs) {
-		return fmt.Errorf("invalid inputs count %d for pledge transaction", len(tx.Outputs))
+		return Errorf(ErrorInvalidNodeOperation, "invalid inputs count %d for pledge transaction", len(tx.Outputs))
 	}
 	fk := fmt.Sprintf("%s:%d", tx.Inputs[0].Hash.String(), tx.Inputs[0].Index)
-	if inputs[fk].Type != OutputTypeScript && inputs[fk].Type != OutputTypeNodeRemove {
-		return fmt.Errorf("invalid utxo type %d", inputs[fk].Type)
+	switch inputs[fk].Type {
+	case OutputTypeScript, OutputTypeNodeRemove, OutputTypeNodePayeeChange:
+	default:
+		return Errorf(ErrorInvalidInput, "invalid utxo type %d", inputs[fk].Type)
 	}
 	if len(tx.Extra) != 2*len(crypto.Key{}) {
-		return fmt.Errorf("invalid extra length %d for pledge transaction", len(tx.Extra))
+		return Errorf(ErrorInvalidExtra, "invalid extra length %d for pledge transaction", len(tx.Extra))
 	}
 
 	var signerSpend crypto.Key
@@ -66,13 +69,13 @@ func (tx *Transaction) validateNodePledge(store DataStore, inputs map[string]*UT
 	nodes := store.ReadAllNodes(snapTime, false)
 	for _, n := range nodes {
 		if n.State != NodeStateAccepted && n.State != NodeStateCancelled && n.State != NodeStateRemoved {
-			return fmt.Errorf("invalid node pending state %s %s", n.Signer.String(), n.State)
+			return Errorf(ErrorInvalidNodeOperation, "invalid node pending state %s %s", n.Signer.String(), n.State)
 		}
 		if n.Signer.PublicSpendKey.String() == signerSpend.String() {
-			return fmt.Errorf("invalid node signer key %s %s", hex.EncodeToString(tx.Extra), n.Signer)
+			return Errorf(ErrorInvalidNodeOperation, "invalid node signer key %s %s", hex.EncodeToString(tx.Extra), n.Signer)
 		}
 		if n.Payee.PublicSpendKey.String() == signerSpend.String() {
-			return fmt.Errorf("invalid node signer key %s %s", hex.EncodeToString(tx.Extra), n.Payee)
+			return Errorf(ErrorInvalidNodeOperation, "invalid node signer key %s %s", hex.EncodeToString(tx.Extra), n.Payee)
 		}
 	}
 
@@ -81,29 +84,29 @@ func (tx *Transaction) validateNodePledge(store DataStore, inputs map[string]*UT
 
 func (tx *Transaction) validateNodeCancel(store DataStore, payloadHash crypto.Hash, sigs []map[uint16]*crypto.Signature, snapTime uint64) error {
 	if tx.Asset != XINAssetId {
-		return fmt.Errorf("invalid node asset %s", tx.Asset.String())
+		return Errorf(ErrorInvalidAsset, "invalid node asset %s", tx.Asset.String())
 	}
 	if len(tx.Outputs) != 2 {
-		return fmt.Errorf("invalid outputs count %d for cancel transaction", len(tx.Outputs))
+		return Errorf(ErrorInvalidNodeOperation, "invalid outputs count %d for cancel transaction", len(tx.Outputs))
 	}
 	if len(tx.Inputs) != 1 {
-		return fmt.Errorf("invalid inputs count %d for cancel transaction", len(tx.Inputs))
+		return Errorf(ErrorInvalidNodeOperation, "invalid inputs count %d for cancel transaction", len(tx.Inputs))
 	}
 	if len(sigs) != 1 || len(sigs[0]) != 1 || sigs[0][0] == nil {
-		return fmt.Errorf("invalid signatures %v for cancel transaction", sigs)
+		return Errorf(ErrorInvalidSignature, "invalid signatures %v for cancel transaction", sigs)
 	}
 	if len(tx.Extra) != len(crypto.Key{})*3 {
-		return fmt.Errorf("invalid extra %s for cancel transaction", hex.EncodeToString(tx.Extra))
+		return Errorf(ErrorInvalidExtra, "invalid extra %s for cancel transaction", hex.EncodeToString(tx.Extra))
 	}
 	cancel, script := tx.Outputs[0], tx.Outputs[1]
 	if cancel.Type != OutputTypeNodeCancel || script.Type != OutputTypeScript {
-		return fmt.Errorf("invalid outputs type %d %d for cancel transaction", cancel.Type, script.Type)
+		return Errorf(ErrorInvalidNodeOperation, "invalid outputs type %d %d for cancel transaction", cancel.Type, script.Type)
 	}
 	if len(script.Keys) != 1 {
-		return fmt.Errorf("invalid script output keys %d for cancel transaction", len(script.Keys))
+		return Errorf(ErrorInvalidNodeOperation, "invalid script output keys %d for cancel transaction", len(script.Keys))
 	}
 	if script.Script.String() != NewThresholdScript(1).String() {
-		return fmt.Errorf("invalid script output script %s for cancel transaction", script.Script)
+		return Errorf(ErrorInvalidNodeOperation, "invalid script output script %s for cancel transaction", script.Script)
 	}
 
 	var pledging *Node
@@ -117,14 +120,14 @@ func (tx *Transaction) validateNodeCancel(store DataStore, payloadHash crypto.Ha
 		if n.State == NodeStatePledging && pledging == nil {
 			pledging = n
 		} else {
-			return fmt.Errorf("invalid pledging nodes %s %s", pledging.Signer.String(), n.Signer.String())
+			return Errorf(ErrorInvalidNodeOperation, "invalid pledging nodes %s %s", pledging.Signer.String(), n.Signer.String())
 		}
 	}
 	if pledging == nil {
-		return fmt.Errorf("no pledging node needs to get cancelled")
+		return Errorf(ErrorInvalidNodeOperation, "no pledging node needs to get cancelled")
 	}
 	if pledging.Transaction != tx.Inputs[0].Hash {
-		return fmt.Errorf("invalid plede utxo source %s %s", pledging.Transaction, tx.Inputs[0].Hash)
+		return Errorf(ErrorInvalidNodeOperation, "invalid plede utxo source %s %s", pledging.Transaction, tx.Inputs[0].Hash)
 	}
 
 	lastPledge, _, err := store.ReadTransaction(tx.Inputs[0].Hash)
@@ -132,18 +135,18 @@ func (tx *Transaction) validateNodeCancel(store DataStore, payloadHash crypto.Ha
 		return err
 	}
 	if len(lastPledge.Outputs) != 1 {
-		return fmt.Errorf("invalid pledge utxo count %d", len(lastPledge.Outputs))
+		return Errorf(ErrorInvalidNodeOperation, "invalid pledge utxo count %d", len(lastPledge.Outputs))
 	}
 	po := lastPledge.Outputs[0]
 	if po.Type != OutputTypeNodePledge {
-		return fmt.Errorf("invalid pledge utxo type %d", po.Type)
+		return Errorf(ErrorInvalidNodeOperation, "invalid pledge utxo type %d", po.Type)
 	}
 	if cancel.Amount.Cmp(po.Amount.Div(100)) != 0 {
-		return fmt.Errorf("invalid script output amount %s for cancel transaction", cancel.Amount)
+		return Errorf(ErrorInvalidAmount, "invalid script output amount %s for cancel transaction", cancel.Amount)
 	}
 	acc := lastPledge.NodeTransactionExtraAsSigner()
 	if filter[acc.String()] != NodeStatePledging {
-		return fmt.Errorf("invalid pledge utxo source %s", filter[acc.String()])
+		return Errorf(ErrorInvalidNodeOperation, "invalid pledge utxo source %s", filter[acc.String()])
 	}
 
 	pit, _, err := store.ReadTransaction(lastPledge.Inputs[0].Hash)
@@ -151,37 +154,37 @@ func (tx *Transaction) validateNodeCancel(store DataStore, payloadHash crypto.Ha
 		return err
 	}
 	if pit == nil {
-		return fmt.Errorf("invalid pledge input source %s:%d", lastPledge.Inputs[0].Hash, lastPledge.Inputs[0].Index)
+		return Errorf(ErrorInvalidNodeOperation, "invalid pledge input source %s:%d", lastPledge.Inputs[0].Hash, lastPledge.Inputs[0].Index)
 	}
 	pi := pit.Outputs[lastPledge.Inputs[0].Index]
 	if len(pi.Keys) != 1 {
-		return fmt.Errorf("invalid pledge input source keys %d", len(pi.Keys))
+		return Errorf(ErrorInvalidNodeOperation, "invalid pledge input source keys %d", len(pi.Keys))
 	}
 	var a crypto.Key
 	copy(a[:], tx.Extra[len(crypto.Key{})*2:])
 	pledgeSpend := crypto.ViewGhostOutputKey(pi.Keys[0], &a, &pi.Mask, uint64(lastPledge.Inputs[0].Index))
 	targetSpend := crypto.ViewGhostOutputKey(script.Keys[0], &a, &script.Mask, 1)
 	if !bytes.Equal(lastPledge.Extra, tx.Extra[:len(crypto.Key{})*2]) {
-		return fmt.Errorf("invalid pledge and cancel key %s %s", hex.EncodeToString(lastPledge.Extra), hex.EncodeToString(tx.Extra))
+		return Errorf(ErrorInvalidNodeOperation, "invalid pledge and cancel key %s %s", hex.EncodeToString(lastPledge.Extra), hex.EncodeToString(tx.Extra))
 	}
 	if !bytes.Equal(pledgeSpend[:], targetSpend[:]) {
-		return fmt.Errorf("invalid pledge and cancel target %s %s", pledgeSpend, targetSpend)
+		return Errorf(ErrorInvalidNodeOperation, "invalid pledge and cancel target %s %s", pledgeSpend, targetSpend)
 	}
 	if !pi.Keys[0].Verify(payloadHash, *sigs[0][0]) {
-		return fmt.Errorf("invalid cancel signature %s", sigs[0][0])
+		return Errorf(ErrorInvalidSignature, "invalid cancel signature %s", sigs[0][0])
 	}
 	return nil
 }
 
 func (tx *Transaction) validateNodeAccept(store DataStore, snapTime uint64) error {
 	if tx.Asset != XINAssetId {
-		return fmt.Errorf("invalid node asset %s", tx.Asset.String())
+		return Errorf(ErrorInvalidAsset, "invalid node asset %s", tx.Asset.String())
 	}
 	if len(tx.Outputs) != 1 {
-		return fmt.Errorf("invalid outputs count %d for accept transaction", len(tx.Outputs))
+		return Errorf(ErrorInvalidNodeOperation, "invalid outputs count %d for accept transaction", len(tx.Outputs))
 	}
 	if len(tx.Inputs) != 1 {
-		return fmt.Errorf("invalid inputs count %d for accept transaction", len(tx.Inputs))
+		return Errorf(ErrorInvalidNodeOperation, "invalid inputs count %d for accept transaction", len(tx.Inputs))
 	}
 	var pledging *Node
 	filter := make(map[string]string)
@@ -194,14 +197,14 @@ func (tx *Transaction) validateNodeAccept(store DataStore, snapTime uint64) erro
 		if n.State == NodeStatePledging && pledging == nil {
 			pledging = n
 		} else {
-			return fmt.Errorf("invalid pledging nodes %s %s", pledging.Signer.String(), n.Signer.String())
+			return Errorf(ErrorInvalidNodeOperation, "invalid pledging nodes %s %s", pledging.Signer.String(), n.Signer.String())
 		}
 	}
 	if pledging == nil {
-		return fmt.Errorf("no pledging node needs to get accepted")
+		return Errorf(ErrorInvalidNodeOperation, "no pledging node needs to get accepted")
 	}
 	if pledging.Transaction != tx.Inputs[0].Hash {
-		return fmt.Errorf("invalid plede utxo source %s %s", pledging.Transaction, tx.Inputs[0].Hash)
+		return Errorf(ErrorInvalidNodeOperation, "invalid plede utxo source %s %s", pledging.Transaction, tx.Inputs[0].Hash)
 	}
 
 	lastPledge, _, err := store.ReadTransaction(tx.Inputs[0].Hash)
@@ -209,31 +212,31 @@ func (tx *Transaction) validateNodeAccept(store DataStore, snapTime uint64) erro
 		return err
 	}
 	if len(lastPledge.Outputs) != 1 {
-		return fmt.Errorf("invalid pledge utxo count %d", len(lastPledge.Outputs))
+		return Errorf(ErrorInvalidNodeOperation, "invalid pledge utxo count %d", len(lastPledge.Outputs))
 	}
 	po := lastPledge.Outputs[0]
 	if po.Type != OutputTypeNodePledge {
-		return fmt.Errorf("invalid pledge utxo type %d", po.Type)
+		return Errorf(ErrorInvalidNodeOperation, "invalid pledge utxo type %d", po.Type)
 	}
 	acc := lastPledge.NodeTransactionExtraAsSigner()
 	if filter[acc.String()] != NodeStatePledging {
-		return fmt.Errorf("invalid pledge utxo source %s", filter[acc.String()])
+		return Errorf(ErrorInvalidNodeOperation, "invalid pledge utxo source %s", filter[acc.String()])
 	}
 	if !bytes.Equal(lastPledge.Extra, tx.Extra) {
-		return fmt.Errorf("invalid pledge and accept key %s %s", hex.EncodeToString(lastPledge.Extra), hex.EncodeToString(tx.Extra))
+		return Errorf(ErrorInvalidNodeOperation, "invalid pledge and accept key %s %s", hex.EncodeToString(lastPledge.Extra), hex.EncodeToString(tx.Extra))
 	}
 	return nil
 }
 
 func (tx *Transaction) validateNodeRemove(store DataStore) error {
 	if tx.Asset != XINAssetId {
-		return fmt.Errorf("invalid node asset %s", tx.Asset.String())
+		return Errorf(ErrorInvalidAsset, "invalid node asset %s", tx.Asset.String())
 	}
 	if len(tx.Outputs) != 1 {
-		return fmt.Errorf("invalid outputs count %d for remove transaction", len(tx.Outputs))
+		return Errorf(ErrorInvalidNodeOperation, "invalid outputs count %d for remove transaction", len(tx.Outputs))
 	}
 	if len(tx.Inputs) != 1 {
-		return fmt.Errorf("invalid inputs count %d for remove transaction", len(tx.Inputs))
+		return Errorf(ErrorInvalidNodeOperation, "invalid inputs count %d for remove transaction", len(tx.Inputs))
 	}
 
 	accept, _, err := store.ReadTransaction(tx.Inputs[0].Hash)
@@ -241,17 +244,17 @@ func (tx *Transaction) validateNodeRemove(store DataStore) error {
 		return err
 	}
 	if accept.PayloadHash() != tx.Inputs[0].Hash {
-		return fmt.Errorf("accept transaction malformed %s %s", tx.Inputs[0].Hash, accept.PayloadHash())
+		return Errorf(ErrorInvalidNodeOperation, "accept transaction malformed %s %s", tx.Inputs[0].Hash, accept.PayloadHash())
 	}
 	if len(accept.Outputs) != 1 {
-		return fmt.Errorf("invalid accept utxo count %d", len(accept.Outputs))
+		return Errorf(ErrorInvalidNodeOperation, "invalid accept utxo count %d", len(accept.Outputs))
 	}
 	ao := accept.Outputs[0]
 	if ao.Type != OutputTypeNodeAccept {
-		return fmt.Errorf("invalid accept utxo type %d", ao.Type)
+		return Errorf(ErrorInvalidNodeOperation, "invalid accept utxo type %d", ao.Type)
 	}
 	if !bytes.Equal(accept.Extra, tx.Extra) {
-		return fmt.Errorf("invalid accept and remove key %s %s", hex.EncodeToString(accept.Extra), hex.EncodeToString(tx.Extra))
+		return Errorf(ErrorInvalidNodeOperation, "invalid accept and remove key %s %s", hex.EncodeToString(accept.Extra), hex.EncodeToString(tx.Extra))
 	}
 	return nil
 }
@@ -0,0 +1,152 @@
+package common
+
+import (
+	"fmt"
+
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+const (
+	ownershipPrefix       = "MIXIN:OUTPUT:OWNERSHIP:"
+	OwnershipChallengeMax = 64
+)
+
+// OutputOwnership is a statement that the address controls the output with
+// the ghost key, and the challenge of the verifier, e.g. the withdrawal
+// request id of an exchange. The ghost private key signs the statement to
+// prove the output is spendable by the prover, and the spend key of the
+// address signs the same statement over the ghost key, so the verifier knows
+// both keys are held by the same owner without the view key of the address.
+type OutputOwnership struct {
+	Address        Address
+	Transaction    crypto.Hash
+	Index          uint
+	Key            crypto.Key
+	Challenge      []byte
+	SpendSignature crypto.Signature
+	GhostSignature crypto.Signature
+}
+
+// NewOutputOwnership signs the ownership statement of the output at the index
+// of the transaction, with the private view and spend keys of the address.
+func NewOutputOwnership(addr *Address, hash crypto.Hash, index uint, out *Output, challenge []byte) (*OutputOwnership, error) {
+	if len(challenge) > OwnershipChallengeMax {
+		return nil, Errorf(ErrorInvalidFormat, "invalid ownership challenge size %d", len(challenge))
+	}
+	i, ok := out.ViewKeyIndex(addr, index)
+	if !ok {
+		return nil, Errorf(ErrorInvalidOutput, "output %s:%d not owned by %s", hash, index, addr)
+	}
+	ghost := crypto.DeriveGhostPrivateKey(&out.Mask, &addr.PrivateViewKey, &addr.PrivateSpendKey, uint64(index))
+	if ghost.Public() != *out.Keys[i] {
+		return nil, Errorf(ErrorInvalidOutput, "output %s:%d not spendable by %s", hash, index, addr)
+	}
+	o := &OutputOwnership{
+		Address: Address{
+			PublicSpendKey: addr.PublicSpendKey,
+			PublicViewKey:  addr.PublicViewKey,
+		},
+		Transaction: hash,
+		Index:       index,
+		Key:         *out.Keys[i],
+		Challenge:   challenge,
+	}
+	msg := o.PayloadHash()
+	o.SpendSignature = addr.PrivateSpendKey.Sign(msg)
+	o.GhostSignature = ghost.Sign(msg)
+	return o, nil
+}
+
+func (o *OutputOwnership) PayloadHash() crypto.Hash {
+	if len(o.Challenge) > OwnershipChallengeMax {
+		panic(len(o.Challenge))
+	}
+	enc := NewMinimumEncoder()
+	enc.Write([]byte(ownershipPrefix))
+	enc.Write(o.Address.PublicSpendKey[:])
+	enc.Write(o.Address.PublicViewKey[:])
+	enc.Write(o.Transaction[:])
+	enc.WriteUint64(uint64(o.Index))
+	enc.Write(o.Key[:])
+	enc.WriteInt(len(o.Challenge))
+	enc.Write(o.Challenge)
+	return crypto.Blake3Hash(enc.Bytes())
+}
+
+// Verify checks the output of the statement has the ghost key, and both the
+// signatures, the verifier should also check the challenge is the one sent,
+// and the output is not spent yet if the funds are proved.
+func (o *OutputOwnership) Verify(out *Output) error {
+	if len(o.Challenge) > OwnershipChallengeMax {
+		return Errorf(ErrorInvalidFormat, "invalid ownership challenge size %d", len(o.Challenge))
+	}
+	var found bool
+	for _, k := range out.Keys {
+		found = found || *k == o.Key
+	}
+	if !found {
+		return Errorf(ErrorInvalidOutput, "invalid ownership key %s of %s:%d", o.Key, o.Transaction, o.Index)
+	}
+	msg := o.PayloadHash()
+	if !o.Key.Verify(msg, o.GhostSignature) {
+		return Errorf(ErrorInvalidSignature, "invalid ownership ghost signature %s", o.GhostSignature)
+	}
+	if !o.Address.PublicSpendKey.Verify(msg, o.SpendSignature) {
+		return Errorf(ErrorInvalidSignature, "invalid ownership spend signature %s", o.SpendSignature)
+	}
+	return nil
+}
+
+func (o *OutputOwnership) Marshal() []byte {
+	enc := NewMinimumEncoder()
+	enc.Write(o.Address.PublicSpendKey[:])
+	enc.Write(o.Address.PublicViewKey[:])
+	enc.Write(o.Transaction[:])
+	enc.WriteInt(int(o.Index))
+	enc.Write(o.Key[:])
+	enc.WriteInt(len(o.Challenge))
+	enc.Write(o.Challenge)
+	enc.Write(o.SpendSignature[:])
+	enc.Write(o.GhostSignature[:])
+	return enc.Bytes()
+}
+
+func UnmarshalOutputOwnership(b []byte) (*OutputOwnership, error) {
+	dec, err := NewMinimumDecoder(b)
+	if err != nil {
+		return nil, err
+	}
+	var o OutputOwnership
+	for _, f := range [][]byte{o.Address.PublicSpendKey[:], o.Address.PublicViewKey[:], o.Transaction[:]} {
+		err = dec.Read(f)
+		if err != nil {
+			return nil, err
+		}
+	}
+	index, err := dec.ReadInt()
+	if err != nil {
+		return nil, err
+	}
+	o.Index = uint(index)
+	err = dec.Read(o.Key[:])
+	if err != nil {
+		return nil, err
+	}
+	o.Challenge, err = dec.ReadBytes()
+	if err != nil {
+		return nil, err
+	}
+	if len(o.Challenge) > OwnershipChallengeMax {
+		return nil, fmt.Errorf("invalid ownership challenge size %d", len(o.Challenge))
+	}
+	for _, f := range [][]byte{o.SpendSignature[:], o.GhostSignature[:]} {
+		err = dec.Read(f)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if dec.buf.Len() != 0 {
+		return nil, fmt.Errorf("invalid ownership size %d", len(b))
+	}
+	return &o, nil
+}
@@ -0,0 +1,61 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutputOwnership(t *testing.T) {
+	require := require.New(t)
+
+	accounts := make([]*Address, 0)
+	for i := 0; i < 3; i++ {
+		a := randomAccount()
+		accounts = append(accounts, &a)
+	}
+	tx := NewTransactionV5(XINAssetId).AsVersioned()
+	tx.AddInput(crypto.Hash{}, 0)
+	tx.AddRandomScriptOutput(accounts[:1], NewThresholdScript(1), NewInteger(100))
+	tx.AddRandomScriptOutput(accounts[1:], NewThresholdScript(1), NewInteger(200))
+	hash := tx.PayloadHash()
+
+	_, err := NewOutputOwnership(accounts[1], hash, 0, tx.Outputs[0], nil)
+	require.ErrorContains(err, "not owned by")
+	_, err = NewOutputOwnership(accounts[2], hash, 1, tx.Outputs[1], make([]byte, OwnershipChallengeMax+1))
+	require.ErrorContains(err, "invalid ownership challenge size")
+	watch := *accounts[2]
+	watch.PrivateSpendKey = accounts[0].PrivateSpendKey
+	_, err = NewOutputOwnership(&watch, hash, 1, tx.Outputs[1], nil)
+	require.ErrorContains(err, "not spendable by")
+
+	o, err := NewOutputOwnership(accounts[2], hash, 1, tx.Outputs[1], []byte("withdrawal"))
+	require.Nil(err)
+	require.Equal(accounts[2].String(), o.Address.String())
+	require.Equal(*tx.Outputs[1].Keys[1], o.Key)
+	require.False(o.Address.PrivateSpendKey.HasValue())
+	require.Nil(o.Verify(tx.Outputs[1]))
+	require.ErrorContains(o.Verify(tx.Outputs[0]), "invalid ownership key")
+
+	b := o.Marshal()
+	decoded, err := UnmarshalOutputOwnership(b)
+	require.Nil(err)
+	require.Equal(o, decoded)
+	require.Nil(decoded.Verify(tx.Outputs[1]))
+	_, err = UnmarshalOutputOwnership(append(b, 0))
+	require.ErrorContains(err, "invalid ownership size")
+	_, err = UnmarshalOutputOwnership(b[:len(b)-1])
+	require.NotNil(err)
+
+	decoded.Challenge = []byte("other")
+	require.ErrorContains(decoded.Verify(tx.Outputs[1]), "invalid ownership ghost signature")
+	decoded.Challenge = o.Challenge
+	decoded.Address = *accounts[1]
+	msg := decoded.PayloadHash()
+	ghost := crypto.DeriveGhostPrivateKey(&tx.Outputs[1].Mask, &accounts[2].PrivateViewKey, &accounts[2].PrivateSpendKey, 1)
+	decoded.GhostSignature = ghost.Sign(msg)
+	require.ErrorContains(decoded.Verify(tx.Outputs[1]), "invalid ownership spend signature")
+	decoded.SpendSignature = accounts[1].PrivateSpendKey.Sign(msg)
+	require.Nil(decoded.Verify(tx.Outputs[1]))
+}
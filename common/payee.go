@@ -0,0 +1,121 @@
+package common
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+const nodePayeeChangeExtraSize = 32*3 + 64*2
+
+// NodePayeeChange rotates the payee of an accepted node, which receives the
+// mint works and the removal refund. The change is signed by both the old and
+// new payee keys, to prove the ownership of the new key and to authorize the
+// change by the old one, and the signed message commits to the input of the
+// transaction, so the change could never be replayed.
+type NodePayeeChange struct {
+	Signer       crypto.Key
+	OldPayee     crypto.Key
+	NewPayee     crypto.Key
+	OldSignature crypto.Signature
+	NewSignature crypto.Signature
+}
+
+// NodePayeeUpdate is the record of a finalized payee change, the payee
+// applies to the node since the timestamp.
+type NodePayeeUpdate struct {
+	Signer      Address
+	Payee       Address
+	Transaction crypto.Hash
+	Timestamp   uint64
+}
+
+func NodePayeeChangeMessage(signer, oldPayee, newPayee crypto.Key, input *Input) crypto.Hash {
+	msg := append(signer[:], oldPayee[:]...)
+	msg = append(msg, newPayee[:]...)
+	msg = append(msg, input.Hash[:]...)
+	msg = binary.BigEndian.AppendUint64(msg, uint64(input.Index))
+	return crypto.Blake3Hash(msg)
+}
+
+func (c *NodePayeeChange) Extra() []byte {
+	extra := append(c.Signer[:], c.OldPayee[:]...)
+	extra = append(extra, c.NewPayee[:]...)
+	extra = append(extra, c.OldSignature[:]...)
+	return append(extra, c.NewSignature[:]...)
+}
+
+func ParseNodePayeeChange(extra []byte) (*NodePayeeChange, error) {
+	if len(extra) != nodePayeeChangeExtraSize {
+		return nil, Errorf(ErrorInvalidExtra, "invalid extra length %d for payee change transaction", len(extra))
+	}
+	c := &NodePayeeChange{}
+	copy(c.Signer[:], extra[:32])
+	copy(c.OldPayee[:], extra[32:64])
+	copy(c.NewPayee[:], extra[64:96])
+	copy(c.OldSignature[:], extra[96:160])
+	copy(c.NewSignature[:], extra[160:])
+	return c, nil
+}
+
+func (tx *Transaction) validateNodePayeeChange(store DataStore, inputs map[string]*UTXO, snapTime uint64) error {
+	if tx.Asset != XINAssetId {
+		return Errorf(ErrorInvalidAsset, "invalid node asset %s", tx.Asset.String())
+	}
+	if len(tx.Outputs) != 1 {
+		return Errorf(ErrorInvalidNodeOperation, "invalid outputs count %d for payee change transaction", len(tx.Outputs))
+	}
+	if len(tx.Inputs) != 1 || len(inputs) != len(tx.Inputs) {
+		return Errorf(ErrorInvalidNodeOperation, "invalid inputs count %d for payee change transaction", len(tx.Inputs))
+	}
+	fk := fmt.Sprintf("%s:%d", tx.Inputs[0].Hash.String(), tx.Inputs[0].Index)
+	switch inputs[fk].Type {
+	case OutputTypeScript, OutputTypeNodeRemove, OutputTypeNodePayeeChange:
+	default:
+		return Errorf(ErrorInvalidInput, "invalid utxo type %d", inputs[fk].Type)
+	}
+	out := tx.Outputs[0]
+	if len(out.Keys) != 1 {
+		return Errorf(ErrorInvalidNodeOperation, "invalid output keys count %d for payee change transaction", len(out.Keys))
+	}
+	if out.Script.String() != NewThresholdScript(1).String() {
+		return Errorf(ErrorInvalidNodeOperation, "invalid output script %s for payee change transaction", out.Script)
+	}
+
+	change, err := ParseNodePayeeChange(tx.Extra)
+	if err != nil {
+		return err
+	}
+	if change.NewPayee == change.OldPayee || change.NewPayee == change.Signer {
+		return Errorf(ErrorInvalidNodeOperation, "invalid new payee %s for payee change transaction", change.NewPayee)
+	}
+	if !change.NewPayee.CheckKey() {
+		return Errorf(ErrorInvalidNodeOperation, "invalid new payee key format %s", change.NewPayee)
+	}
+
+	var node *Node
+	for _, n := range store.ReadAllNodes(snapTime, false) {
+		if n.Signer.PublicSpendKey == change.Signer {
+			node = n
+		}
+		if n.Signer.PublicSpendKey == change.NewPayee || n.Payee.PublicSpendKey == change.NewPayee {
+			return Errorf(ErrorInvalidNodeOperation, "invalid new payee %s used by node %s", change.NewPayee, n.Signer)
+		}
+	}
+	if node == nil || node.State != NodeStateAccepted {
+		return Errorf(ErrorInvalidNodeOperation, "invalid node %s for payee change transaction", change.Signer)
+	}
+	if node.Payee.PublicSpendKey != change.OldPayee {
+		return Errorf(ErrorInvalidNodeOperation, "invalid old payee %s %s", change.OldPayee, node.Payee.PublicSpendKey)
+	}
+
+	msg := NodePayeeChangeMessage(change.Signer, change.OldPayee, change.NewPayee, tx.Inputs[0])
+	if !change.OldPayee.Verify(msg, change.OldSignature) {
+		return Errorf(ErrorInvalidSignature, "invalid old payee signature for payee change transaction")
+	}
+	if !change.NewPayee.Verify(msg, change.NewSignature) {
+		return Errorf(ErrorInvalidSignature, "invalid new payee signature for payee change transaction")
+	}
+	return nil
+}
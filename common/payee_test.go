@@ -0,0 +1,94 @@
+package common
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNodePayeeChange(t *testing.T) {
+	require := require.New(t)
+
+	signer := testBuildAddress(require)
+	oldPayee := testBuildAddress(require)
+	newPayee := testBuildAddress(require)
+	store := &testPayeeStore{nodes: []*Node{{
+		Signer: signer,
+		Payee:  oldPayee,
+		State:  NodeStateAccepted,
+	}}}
+	snapTime := uint64(time.Now().UnixNano())
+
+	tx := NewTransactionV5(XINAssetId)
+	tx.AddInput(crypto.Blake3Hash([]byte("payee-change-input")), 0)
+	tx.AddOutputWithType(OutputTypeNodePayeeChange, []*Address{&newPayee}, NewThresholdScript(1), NewInteger(1), make([]byte, 64))
+	fk := fmt.Sprintf("%s:%d", tx.Inputs[0].Hash.String(), tx.Inputs[0].Index)
+	inputs := map[string]*UTXO{fk: {Output: Output{Type: OutputTypeScript}}}
+
+	change := &NodePayeeChange{
+		Signer:   signer.PublicSpendKey,
+		OldPayee: oldPayee.PublicSpendKey,
+		NewPayee: newPayee.PublicSpendKey,
+	}
+	msg := NodePayeeChangeMessage(change.Signer, change.OldPayee, change.NewPayee, tx.Inputs[0])
+	change.OldSignature = oldPayee.PrivateSpendKey.Sign(msg)
+	change.NewSignature = newPayee.PrivateSpendKey.Sign(msg)
+	tx.Extra = change.Extra()
+
+	parsed, err := ParseNodePayeeChange(tx.Extra)
+	require.Nil(err)
+	require.Equal(change, parsed)
+	_, err = ParseNodePayeeChange(tx.Extra[1:])
+	require.ErrorContains(err, "invalid extra length 223")
+	err = tx.AsVersioned().Validate(nil, snapTime, false, ConsensusForks{EscrowScript: true, LockTimeScript: true})
+	require.ErrorContains(err, "node payee change not activated")
+
+	require.Nil(tx.validateNodePayeeChange(store, inputs, snapTime))
+	require.Equal(uint8(TransactionTypeNodePayeeChange), tx.AsVersioned().TransactionType())
+
+	inputs[fk].Type = OutputTypeNodePledge
+	err = tx.validateNodePayeeChange(store, inputs, snapTime)
+	require.ErrorContains(err, "invalid utxo type")
+	require.Equal(ErrorInvalidInput, ErrorCodeOf(err))
+	inputs[fk].Type = OutputTypeNodeRemove
+	require.Nil(tx.validateNodePayeeChange(store, inputs, snapTime))
+
+	store.nodes[0].State = NodeStatePledging
+	err = tx.validateNodePayeeChange(store, inputs, snapTime)
+	require.ErrorContains(err, "invalid node")
+	require.Equal(ErrorInvalidNodeOperation, ErrorCodeOf(err))
+	store.nodes[0].State = NodeStateAccepted
+
+	store.nodes[0].Payee = newPayee
+	err = tx.validateNodePayeeChange(store, inputs, snapTime)
+	require.ErrorContains(err, "used by node")
+	require.Equal(ErrorInvalidNodeOperation, ErrorCodeOf(err))
+	store.nodes[0].Payee = oldPayee
+
+	replay := NewTransactionV5(XINAssetId)
+	replay.AddInput(crypto.Blake3Hash([]byte("payee-change-replay")), 0)
+	replay.Outputs = tx.Outputs
+	replay.Extra = tx.Extra
+	fk = fmt.Sprintf("%s:%d", replay.Inputs[0].Hash.String(), replay.Inputs[0].Index)
+	err = replay.validateNodePayeeChange(store, map[string]*UTXO{fk: {Output: Output{Type: OutputTypeScript}}}, snapTime)
+	require.ErrorContains(err, "invalid old payee signature")
+	require.Equal(ErrorInvalidSignature, ErrorCodeOf(err))
+
+	change.NewSignature = oldPayee.PrivateSpendKey.Sign(msg)
+	tx.Extra = change.Extra()
+	err = tx.validateNodePayeeChange(store, inputs, snapTime)
+	require.ErrorContains(err, "invalid new payee signature")
+	require.Equal(ErrorInvalidSignature, ErrorCodeOf(err))
+}
+
+type testPayeeStore struct {
+	storeImpl
+	nodes []*Node
+}
+
+func (s *testPayeeStore) ReadAllNodes(_ uint64, _ bool) []*Node {
+	return s.nodes
+}
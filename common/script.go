@@ -1,16 +1,19 @@
 package common
 
 import (
+	"encoding/binary"
 	"encoding/hex"
-	"fmt"
 	"strconv"
 )
 
 const (
-	Operator0   = 0x00
-	Operator64  = 0x40
-	OperatorSum = 0xfe
-	OperatorCmp = 0xff
+	Operator0        = 0x00
+	Operator64       = 0x40
+	OperatorLockTime = 0xfc
+	OperatorSum      = 0xfe
+	OperatorCmp      = 0xff
+
+	TimeLockScriptSize = 3 + 1 + 8
 )
 
 type Script []uint8
@@ -19,15 +22,51 @@ func NewThresholdScript(threshold uint8) Script {
 	return Script{OperatorCmp, OperatorSum, threshold}
 }
 
+// NewTimeLockScript appends the lock time to the threshold script, the output
+// is unspendable by the snapshots with timestamp before the lock time.
+//
+// [OperatorCmp][OperatorSum][threshold][OperatorLockTime][lock time uint64]
+func NewTimeLockScript(threshold uint8, lockTime uint64) Script {
+	s := Script{OperatorCmp, OperatorSum, threshold, OperatorLockTime}
+	return binary.BigEndian.AppendUint64(s, lockTime)
+}
+
 func (s Script) VerifyFormat() error {
-	if len(s) != 3 {
-		return fmt.Errorf("invalid script length %d", len(s))
+	if s.IsEscrow() {
+		_, err := s.Escrow()
+		return err
+	}
+	if len(s) != 3 && len(s) != TimeLockScriptSize {
+		return Errorf(ErrorInvalidScript, "invalid script length %d", len(s))
 	}
 	if s[0] != OperatorCmp || s[1] != OperatorSum {
-		return fmt.Errorf("invalid script operators %d %d", s[0], s[1])
+		return Errorf(ErrorInvalidScript, "invalid script operators %d %d", s[0], s[1])
 	}
 	if s[2] > Operator64 {
-		return fmt.Errorf("invalid script threshold %d", s[2])
+		return Errorf(ErrorInvalidScript, "invalid script threshold %d", s[2])
+	}
+	if len(s) == 3 {
+		return nil
+	}
+	if s[3] != OperatorLockTime {
+		return Errorf(ErrorInvalidScript, "invalid script lock time operator %d", s[3])
+	}
+	if s.LockTime() == 0 {
+		return Errorf(ErrorInvalidScript, "invalid script lock time %d", s.LockTime())
+	}
+	return nil
+}
+
+func (s Script) LockTime() uint64 {
+	if len(s) != TimeLockScriptSize || s[3] != OperatorLockTime {
+		return 0
+	}
+	return binary.BigEndian.Uint64(s[4:])
+}
+
+func (s Script) ValidateLockTime(timestamp uint64) error {
+	if lock := s.LockTime(); timestamp < lock {
+		return Errorf(ErrorInvalidScript, "invalid script lock time %d %d", lock, timestamp)
 	}
 	return nil
 }
@@ -38,7 +77,7 @@ func (s Script) Validate(sum int) error {
 		return err
 	}
 	if sum < int(s[2]) {
-		return fmt.Errorf("invalid signature keys %d %d", sum, s[2])
+		return Errorf(ErrorInvalidSignature, "invalid signature keys %d %d", sum, s[2])
 	}
 	return nil
 }
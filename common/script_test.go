@@ -2,7 +2,9 @@ package common
 
 import (
 	"testing"
+	"time"
 
+	"github.com/MixinNetwork/mixin/crypto"
 	"github.com/stretchr/testify/require"
 )
 
@@ -45,4 +47,48 @@ func TestScript(t *testing.T) {
 	err = s.Validate(1)
 	require.Nil(err)
 	require.Equal("fffe01", s.String())
+
+	require.Equal(uint64(0), s.LockTime())
+	require.Nil(s.ValidateLockTime(0))
+
+	s = NewTimeLockScript(2, 1700000000000000000)
+	require.Len(s, TimeLockScriptSize)
+	require.Equal("fffe02fc17979cfe362a0000", s.String())
+	require.Equal(uint64(1700000000000000000), s.LockTime())
+	require.Nil(s.VerifyFormat())
+	require.NotNil(s.Validate(1))
+	require.Nil(s.Validate(2))
+	require.NotNil(s.ValidateLockTime(1699999999999999999))
+	require.Nil(s.ValidateLockTime(1700000000000000000))
+	require.NotNil(NewTimeLockScript(2, 0).VerifyFormat())
+	require.NotNil(NewTimeLockScript(65, 1).VerifyFormat())
+	s[3] = OperatorSum
+	require.NotNil(s.VerifyFormat())
+	require.Equal(uint64(0), s.LockTime())
+	require.NotNil(s[:8].VerifyFormat())
+}
+
+func TestTimeLockScript(t *testing.T) {
+	require := require.New(t)
+
+	lock := uint64(time.Now().UnixNano())
+	account := randomAccount()
+	seed := make([]byte, 64)
+	crypto.ReadRand(seed)
+	store := escrowStoreImpl{storeImpl{seed: seed, accounts: []*Address{&account}}, NewTimeLockScript(1, lock)}
+
+	tx := NewTransactionV5(XINAssetId)
+	tx.AddInput(crypto.Blake3Hash([]byte("timelock")), 0)
+	tx.AddRandomScriptOutput([]*Address{&account}, NewTimeLockScript(1, lock+1), NewInteger(10000))
+	ver := tx.AsVersioned()
+	err := ver.SignInput(store, 0, []*Address{&account})
+	require.Nil(err)
+
+	err = ver.Validate(store, lock-1, false, activeForks)
+	require.NotNil(err)
+	require.Contains(err.Error(), "invalid script lock time")
+	require.Nil(ver.Validate(store, lock, false, activeForks))
+	err = ver.Validate(store, lock, false, ConsensusForks{EscrowScript: true})
+	require.NotNil(err)
+	require.Contains(err.Error(), "lock time script not activated")
 }
@@ -1,7 +1,6 @@
 package common
 
 import (
-	"encoding/hex"
 	"fmt"
 
 	"github.com/MixinNetwork/mixin/crypto"
@@ -55,7 +54,7 @@ func (s *Snapshot) AddSoleTransaction(tx crypto.Hash) {
 
 func UnmarshalVersionedSnapshot(b []byte) (*SnapshotWithTopologicalOrder, error) {
 	if checkSnapVersion(b) < SnapshotVersionCommonEncoding {
-		panic(hex.EncodeToString(b))
+		return nil, fmt.Errorf("invalid snapshot version %x", b[:min(len(b), 4)])
 	}
 	return NewDecoder(b).DecodeSnapshotWithTopo()
 }
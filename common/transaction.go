@@ -27,6 +27,7 @@ const (
 	OutputTypeNodeRemove           = 0xa6
 	OutputTypeWithdrawalClaim      = 0xa9
 	OutputTypeNodeCancel           = 0xaa
+	OutputTypeNodePayeeChange      = 0xab
 	OutputTypeCustodianUpdateNodes = 0xb1
 	OutputTypeCustodianSlashNodes  = 0xb2
 
@@ -42,6 +43,7 @@ const (
 	TransactionTypeNodeCancel           = 0x12
 	TransactionTypeCustodianUpdateNodes = 0x13
 	TransactionTypeCustodianSlashNodes  = 0x14
+	TransactionTypeNodePayeeChange      = 0x15
 	TransactionTypeUnknown              = 0xff
 )
 
@@ -131,6 +133,8 @@ func (tx *SignedTransaction) TransactionType() uint8 {
 			return TransactionTypeNodeAccept
 		case OutputTypeNodeRemove:
 			return TransactionTypeNodeRemove
+		case OutputTypeNodePayeeChange:
+			return TransactionTypeNodePayeeChange
 		case OutputTypeCustodianUpdateNodes:
 			return TransactionTypeCustodianUpdateNodes
 		case OutputTypeCustodianSlashNodes:
@@ -183,6 +187,32 @@ func (signed *SignedTransaction) SignInput(reader UTXOKeysReader, index int, acc
 		return signed.SignRaw(accounts[0].PrivateSpendKey)
 	}
 
+	views := make([]crypto.Key, len(accounts))
+	signers := make([]crypto.GhostSigner, len(accounts))
+	for i, acc := range accounts {
+		views[i] = acc.PrivateViewKey
+		signers[i] = crypto.NewKeySigner(acc.PrivateSpendKey)
+	}
+	return signed.SignInputWithSigners(reader, index, views, signers)
+}
+
+// SignInputWithSigners signs the input with the ghost signers, the spend keys
+// are kept by the signers, so only the private view keys are required here.
+func (signed *SignedTransaction) SignInputWithSigners(reader UTXOKeysReader, index int, views []crypto.Key, signers []crypto.GhostSigner) error {
+	if len(signers) == 0 {
+		return nil
+	}
+	if len(views) != len(signers) {
+		return fmt.Errorf("invalid view keys count %d/%d", len(views), len(signers))
+	}
+	if index >= len(signed.Inputs) {
+		return fmt.Errorf("invalid input index %d/%d", index, len(signed.Inputs))
+	}
+	in := signed.Inputs[index]
+	if in.Deposit != nil || in.Mint != nil {
+		return fmt.Errorf("invalid input type for ghost signers %d", index)
+	}
+
 	utxo, err := reader.ReadUTXOKeys(in.Hash, in.Index)
 	if err != nil {
 		return err
@@ -198,14 +228,16 @@ func (signed *SignedTransaction) SignInput(reader UTXOKeysReader, index int, acc
 
 	sigs := make(map[uint16]*crypto.Signature)
 	msg := signed.AsVersioned().PayloadHash()
-	for _, acc := range accounts {
-		priv := crypto.DeriveGhostPrivateKey(&utxo.Mask, &acc.PrivateViewKey, &acc.PrivateSpendKey, uint64(in.Index))
-		i, found := keysFilter[priv.Public().String()]
+	for i, signer := range signers {
+		pub, sig, err := signer.SignGhost(&utxo.Mask, &views[i], uint64(in.Index), msg)
+		if err != nil {
+			return err
+		}
+		i, found := keysFilter[pub.String()]
 		if !found {
-			return fmt.Errorf("invalid key for the input %s", acc.String())
+			return fmt.Errorf("invalid key for the input %s", signer.PublicSpendKey())
 		}
-		sig := priv.Sign(msg)
-		sigs[i] = &sig
+		sigs[i] = sig
 	}
 	signed.SignaturesMap = append(signed.SignaturesMap, sigs)
 	return nil
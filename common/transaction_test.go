@@ -58,7 +58,7 @@ func TestTransactionReferences(t *testing.T) {
 		require.NotNil(err)
 		require.Contains(err.Error(), "invalid key for the input")
 	}
-	err = ver.Validate(store, uint64(time.Now().UnixNano()), false)
+	err = ver.Validate(store, uint64(time.Now().UnixNano()), false, activeForks)
 	require.NotNil(err)
 	require.Contains(err.Error(), "invalid tx signature number")
 
@@ -66,14 +66,14 @@ func TestTransactionReferences(t *testing.T) {
 	for i := range ver.Inputs {
 		err := ver.SignInput(store, i, accounts[0:i+1])
 		require.Nil(err)
-		err = ver.Validate(store, uint64(time.Now().UnixNano()), false)
+		err = ver.Validate(store, uint64(time.Now().UnixNano()), false, activeForks)
 		if i < len(ver.Inputs)-1 {
 			require.NotNil(err)
 		} else {
 			require.Nil(err)
 		}
 	}
-	err = ver.Validate(store, uint64(time.Now().UnixNano()), false)
+	err = ver.Validate(store, uint64(time.Now().UnixNano()), false, activeForks)
 	require.Nil(err)
 
 	pm = ver.Marshal()
@@ -99,7 +99,7 @@ func TestTransactionReferences(t *testing.T) {
 		}
 	}
 	ver.SignaturesMap = sm
-	err = ver.Validate(store, uint64(time.Now().UnixNano()), false)
+	err = ver.Validate(store, uint64(time.Now().UnixNano()), false, activeForks)
 	require.NotNil(err)
 	require.Equal("batch verification failure 3 3", err.Error())
 	sm = make([]map[uint16]*crypto.Signature, 2)
@@ -112,7 +112,7 @@ func TestTransactionReferences(t *testing.T) {
 		}
 	}
 	ver.SignaturesMap = sm
-	err = ver.Validate(store, uint64(time.Now().UnixNano()), false)
+	err = ver.Validate(store, uint64(time.Now().UnixNano()), false, activeForks)
 	require.NotNil(err)
 	require.Equal("invalid signature map index 2 2", err.Error())
 	sm = make([]map[uint16]*crypto.Signature, 2)
@@ -126,7 +126,7 @@ func TestTransactionReferences(t *testing.T) {
 	}
 	sm[0][1] = sm[0][0]
 	ver.SignaturesMap = sm
-	err = ver.Validate(store, uint64(time.Now().UnixNano()), false)
+	err = ver.Validate(store, uint64(time.Now().UnixNano()), false, activeForks)
 	require.NotNil(err)
 	require.Equal("batch verification failure 4 4", err.Error())
 	sm = make([]map[uint16]*crypto.Signature, 2)
@@ -140,7 +140,7 @@ func TestTransactionReferences(t *testing.T) {
 	}
 	sm[1][0] = sm[0][0]
 	ver.SignaturesMap = sm
-	err = ver.Validate(store, uint64(time.Now().UnixNano()), false)
+	err = ver.Validate(store, uint64(time.Now().UnixNano()), false, activeForks)
 	require.NotNil(err)
 	require.Equal("batch verification failure 3 3", err.Error())
 
@@ -153,11 +153,11 @@ func TestTransactionReferences(t *testing.T) {
 	require.NotEqual(outputs[1].Keys[1].String(), accounts[1].PublicViewKey.String())
 
 	ver.AggregatedSignature = &AggregatedSignature{}
-	err = ver.Validate(store, uint64(time.Now().UnixNano()), false)
+	err = ver.Validate(store, uint64(time.Now().UnixNano()), false, activeForks)
 	require.NotNil(err)
 	require.Contains(err.Error(), "invalid signatures map 2")
 	ver.SignaturesMap = nil
-	err = ver.Validate(store, uint64(time.Now().UnixNano()), false)
+	err = ver.Validate(store, uint64(time.Now().UnixNano()), false, activeForks)
 	require.NotNil(err)
 	require.Contains(err.Error(), "invalid signature keys 0 1")
 
@@ -169,7 +169,7 @@ func TestTransactionReferences(t *testing.T) {
 	err = ver.AggregateSign(store, aas, seed)
 	require.Nil(err)
 	require.Len(ver.AggregatedSignature.Signers, 1)
-	err = ver.Validate(store, uint64(time.Now().UnixNano()), false)
+	err = ver.Validate(store, uint64(time.Now().UnixNano()), false, activeForks)
 	require.NotNil(err)
 
 	aas = make([][]*Address, len(ver.Inputs))
@@ -183,7 +183,7 @@ func TestTransactionReferences(t *testing.T) {
 	require.NotNil(err)
 	require.Nil(ver.AggregatedSignature)
 	require.NotNil(ver.Marshal())
-	err = ver.Validate(store, uint64(time.Now().UnixNano()), false)
+	err = ver.Validate(store, uint64(time.Now().UnixNano()), false, activeForks)
 	require.NotNil(err)
 
 	aas = make([][]*Address, len(ver.Inputs))
@@ -194,7 +194,7 @@ func TestTransactionReferences(t *testing.T) {
 	err = ver.AggregateSign(store, aas, seed)
 	require.Nil(err)
 	require.Len(ver.AggregatedSignature.Signers, 3)
-	err = ver.Validate(store, uint64(time.Now().UnixNano()), false)
+	err = ver.Validate(store, uint64(time.Now().UnixNano()), false, activeForks)
 	require.Nil(err)
 
 	pm = ver.Marshal()
@@ -204,7 +204,7 @@ func TestTransactionReferences(t *testing.T) {
 	require.NotNil(ver.AggregatedSignature)
 	require.Nil(ver.SignaturesMap)
 	require.Equal(pm, ver.Marshal())
-	err = ver.Validate(store, uint64(time.Now().UnixNano()), false)
+	err = ver.Validate(store, uint64(time.Now().UnixNano()), false, activeForks)
 	require.Nil(err)
 
 	require.Len(ver.References, 0)
@@ -213,13 +213,13 @@ func TestTransactionReferences(t *testing.T) {
 	ver.References = []crypto.Hash{ver.Inputs[0].Hash}
 	require.Len(ver.PayloadMarshal(), 772)
 	require.Len(ver.AggregatedSignature.Signers, 3)
-	err = ver.Validate(store, uint64(time.Now().UnixNano()), false)
+	err = ver.Validate(store, uint64(time.Now().UnixNano()), false, activeForks)
 	require.NotNil(err)
 	ver.AggregatedSignature = nil
 	err = ver.AggregateSign(store, aas, seed)
 	require.Nil(err)
 	require.Len(ver.AggregatedSignature.Signers, 3)
-	err = ver.Validate(store, uint64(time.Now().UnixNano()), false)
+	err = ver.Validate(store, uint64(time.Now().UnixNano()), false, activeForks)
 	require.Nil(err)
 	pm = ver.Marshal()
 	require.Len(pm, 842)
@@ -228,6 +228,8 @@ func TestTransactionReferences(t *testing.T) {
 	require.Equal(ver.Inputs[0].Hash, ver.References[0])
 }
 
+var activeForks = ConsensusForks{EscrowScript: true, LockTimeScript: true, NodePayeeChange: true}
+
 type storeImpl struct {
 	custodian *Address
 	seed      []byte
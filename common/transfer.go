@@ -0,0 +1,120 @@
+package common
+
+import (
+	"fmt"
+
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+const BatchTransferRecipientsLimit = SliceCountLimit - 1
+
+type TransferInput struct {
+	Hash   crypto.Hash
+	Index  uint
+	Amount Integer
+}
+
+type TransferRecipient struct {
+	Address *Address
+	Amount  Integer
+}
+
+// NewBatchTransfer builds a single transaction paying the recipients in order,
+// the inputs are spent in order until all the recipients in the transaction
+// are covered, and the remaining amount goes to the change address as the
+// last output. The outputs are limited by the slice count limit with one
+// reserved for the change, so the recipients not included are returned to
+// be paid in the next transaction with the unused inputs.
+func NewBatchTransfer(asset crypto.Hash, inputs []*TransferInput, recipients []*TransferRecipient, change *Address, extra []byte, seed []byte) (*Transaction, []*TransferRecipient, error) {
+	return newBatchTransfer(asset, inputs, recipients, change, extra, seed, selectInputsInOrder)
+}
+
+// NewBatchTransferWithSelection is the same as NewBatchTransfer, but the inputs
+// are picked by the coin selection strategy instead of in order, so the unused
+// inputs are not a prefix of the inputs, and should be told by the transaction
+// inputs.
+func NewBatchTransferWithSelection(asset crypto.Hash, inputs []*TransferInput, recipients []*TransferRecipient, change *Address, extra []byte, seed []byte, strategy string) (*Transaction, []*TransferRecipient, error) {
+	return newBatchTransfer(asset, inputs, recipients, change, extra, seed, func(inputs []*TransferInput, total Integer) ([]*TransferInput, error) {
+		selection, err := SelectInputs(inputs, total, strategy)
+		if err != nil {
+			return nil, err
+		}
+		return selection.Inputs, nil
+	})
+}
+
+func newBatchTransfer(asset crypto.Hash, inputs []*TransferInput, recipients []*TransferRecipient, change *Address, extra []byte, seed []byte, selectInputs func([]*TransferInput, Integer) ([]*TransferInput, error)) (*Transaction, []*TransferRecipient, error) {
+	if len(recipients) == 0 {
+		return nil, nil, fmt.Errorf("invalid batch transfer recipients count %d", len(recipients))
+	}
+	if len(seed) != 64 {
+		return nil, nil, fmt.Errorf("invalid batch transfer seed length %d", len(seed))
+	}
+	if len(extra) > ExtraSizeGeneralLimit {
+		return nil, nil, fmt.Errorf("invalid batch transfer extra size %d", len(extra))
+	}
+
+	var rest []*TransferRecipient
+	if len(recipients) > BatchTransferRecipientsLimit {
+		rest = recipients[BatchTransferRecipientsLimit:]
+		recipients = recipients[:BatchTransferRecipientsLimit]
+	}
+	total := Zero
+	for _, r := range recipients {
+		if r.Address == nil || r.Amount.Sign() <= 0 {
+			return nil, nil, fmt.Errorf("invalid batch transfer recipient %v", r)
+		}
+		total = total.Add(r.Amount)
+	}
+
+	selected, err := selectInputs(inputs, total)
+	if err != nil {
+		return nil, nil, err
+	}
+	tx := NewTransactionV5(asset)
+	tx.Extra = extra
+	spent := Zero
+	for _, in := range selected {
+		tx.AddInput(in.Hash, in.Index)
+		spent = spent.Add(in.Amount)
+	}
+
+	script := NewThresholdScript(1)
+	for _, r := range recipients {
+		seed = nextBatchTransferSeed(seed)
+		tx.AddScriptOutput([]*Address{r.Address}, script, r.Amount, seed)
+	}
+	if spent.Cmp(total) > 0 {
+		if change == nil {
+			return nil, nil, fmt.Errorf("missing batch transfer change address for %s", spent.Sub(total))
+		}
+		seed = nextBatchTransferSeed(seed)
+		tx.AddScriptOutput([]*Address{change}, script, spent.Sub(total), seed)
+	}
+	return tx, rest, nil
+}
+
+func selectInputsInOrder(inputs []*TransferInput, total Integer) ([]*TransferInput, error) {
+	spent := Zero
+	for i, in := range inputs {
+		if spent.Cmp(total) >= 0 {
+			return inputs[:i], nil
+		}
+		if i >= SliceCountLimit {
+			return nil, fmt.Errorf("too many batch transfer inputs for %s", total)
+		}
+		if in.Amount.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid batch transfer input %s:%d", in.Hash, in.Index)
+		}
+		spent = spent.Add(in.Amount)
+	}
+	if spent.Cmp(total) < 0 {
+		return nil, fmt.Errorf("insufficient batch transfer inputs %s %s", spent, total)
+	}
+	return inputs, nil
+}
+
+func nextBatchTransferSeed(seed []byte) []byte {
+	hash := crypto.Blake3Hash(seed)
+	return append(hash[:], hash[:]...)
+}
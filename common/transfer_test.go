@@ -0,0 +1,70 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchTransfer(t *testing.T) {
+	require := require.New(t)
+
+	seed := make([]byte, 64)
+	crypto.ReadRand(seed)
+	change := randomAccount()
+	inputs := make([]*TransferInput, 0)
+	for i := 0; i < 3; i++ {
+		inputs = append(inputs, &TransferInput{
+			Hash:   crypto.Blake3Hash([]byte{byte(i)}),
+			Index:  uint(i),
+			Amount: NewInteger(100),
+		})
+	}
+	recipients := make([]*TransferRecipient, 0)
+	for i := 0; i < 300; i++ {
+		a := randomAccount()
+		recipients = append(recipients, &TransferRecipient{
+			Address: &a,
+			Amount:  NewIntegerFromString("0.5"),
+		})
+	}
+
+	_, _, err := NewBatchTransfer(XINAssetId, inputs, recipients, &change, nil, seed[:32])
+	require.NotNil(err)
+	_, _, err = NewBatchTransfer(XINAssetId, inputs[:1], recipients, &change, nil, seed)
+	require.NotNil(err)
+	_, _, err = NewBatchTransfer(XINAssetId, inputs, recipients, nil, nil, seed)
+	require.NotNil(err)
+
+	tx, rest, err := NewBatchTransfer(XINAssetId, inputs, recipients, &change, []byte("batch"), seed)
+	require.Nil(err)
+	require.Len(rest, 300-BatchTransferRecipientsLimit)
+	require.Len(tx.Inputs, 2)
+	require.Len(tx.Outputs, SliceCountLimit)
+	require.Equal("72.50000000", tx.Outputs[SliceCountLimit-1].Amount.String())
+	require.Equal([]byte("batch"), tx.Extra)
+	for i, o := range tx.Outputs {
+		require.Len(o.Keys, 1)
+		require.Equal("fffe01", o.Script.String())
+		a := recipients[i].Address
+		if i == SliceCountLimit-1 {
+			a = &change
+		}
+		_, found := o.ViewKeyIndex(a, uint(i))
+		require.True(found)
+	}
+
+	tx, rest, err = NewBatchTransfer(XINAssetId, inputs[2:], rest, &change, nil, seed)
+	require.Nil(err)
+	require.Len(rest, 0)
+	require.Len(tx.Inputs, 1)
+	require.Len(tx.Outputs, 300-BatchTransferRecipientsLimit+1)
+	require.Equal("77.50000000", tx.Outputs[len(tx.Outputs)-1].Amount.String())
+
+	exact := []*TransferRecipient{{Address: &change, Amount: NewInteger(100)}}
+	tx, _, err = NewBatchTransfer(XINAssetId, inputs, exact, nil, nil, seed)
+	require.Nil(err)
+	require.Len(tx.Inputs, 1)
+	require.Len(tx.Outputs, 1)
+}
@@ -32,6 +32,7 @@ func (tx *VersionedTransaction) UnspentOutputs() []*UTXOWithLock {
 			OutputTypeNodeCancel,
 			OutputTypeNodeAccept,
 			OutputTypeNodeRemove,
+			OutputTypeNodePayeeChange,
 			OutputTypeWithdrawalClaim,
 			OutputTypeCustodianUpdateNodes:
 		case OutputTypeWithdrawalSubmit,
@@ -60,6 +61,22 @@ func (tx *VersionedTransaction) UnspentOutputs() []*UTXOWithLock {
 	return utxos
 }
 
+// ViewKeyIndex checks whether the output belongs to the address, it only
+// requires the private view key and the public spend key of the address,
+// so a watch-only wallet is able to scan outputs without the spend key.
+func (out *Output) ViewKeyIndex(addr *Address, index uint) (int, bool) {
+	if !out.Mask.HasValue() {
+		return -1, false
+	}
+	for i, k := range out.Keys {
+		spend := crypto.ViewGhostOutputKey(k, &addr.PrivateViewKey, &out.Mask, uint64(index))
+		if *spend == addr.PublicSpendKey {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
 func (out *UTXOWithLock) Marshal() []byte {
 	enc := NewMinimumEncoder()
 	enc.Write(out.Asset[:])
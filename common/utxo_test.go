@@ -34,3 +34,37 @@ func TestUTXO(t *testing.T) {
 	require.Len(utxo.Output.Keys, 3)
 	require.Equal(XINAssetId, utxo.Asset)
 }
+
+func TestUTXOViewKeyIndex(t *testing.T) {
+	require := require.New(t)
+
+	genesisHash := crypto.Hash{}
+	accounts := make([]*Address, 0)
+	for i := 0; i < 3; i++ {
+		a := randomAccount()
+		accounts = append(accounts, &a)
+	}
+
+	tx := NewTransactionV5(XINAssetId).AsVersioned()
+	tx.AddInput(genesisHash, 0)
+	tx.AddRandomScriptOutput(accounts[:1], NewThresholdScript(1), NewInteger(100))
+	tx.AddRandomScriptOutput(accounts[1:], NewThresholdScript(2), NewInteger(200))
+
+	watch := &Address{
+		PrivateViewKey: accounts[2].PrivateViewKey,
+		PublicViewKey:  accounts[2].PublicViewKey,
+		PublicSpendKey: accounts[2].PublicSpendKey,
+	}
+	i, found := tx.Outputs[0].ViewKeyIndex(watch, 0)
+	require.False(found)
+	require.Equal(-1, i)
+	i, found = tx.Outputs[1].ViewKeyIndex(watch, 0)
+	require.False(found)
+	require.Equal(-1, i)
+	i, found = tx.Outputs[1].ViewKeyIndex(watch, 1)
+	require.True(found)
+	require.Equal(1, i)
+	i, found = tx.Outputs[0].ViewKeyIndex(accounts[0], 0)
+	require.True(found)
+	require.Equal(0, i)
+}
@@ -7,43 +7,55 @@ import (
 	"github.com/MixinNetwork/mixin/crypto"
 )
 
-func (ver *VersionedTransaction) Validate(store DataStore, snapTime uint64, fork bool) error {
+// ConsensusForks are the consensus changes activated by the kernel at the
+// snapshot timestamp, the new formats are invalid before their activation,
+// otherwise the nodes not upgraded yet would reject them and split the graph.
+type ConsensusForks struct {
+	EscrowScript    bool
+	LockTimeScript  bool
+	NodePayeeChange bool
+}
+
+func (ver *VersionedTransaction) Validate(store DataStore, snapTime uint64, fork bool, active ConsensusForks) error {
 	tx := &ver.SignedTransaction
 	txType := tx.TransactionType()
 
 	switch ver.Version {
 	case TxVersionHashSignature:
 	default:
-		return fmt.Errorf("invalid tx version %d", ver.Version)
+		return Errorf(ErrorInvalidFormat, "invalid tx version %d", ver.Version)
 	}
 
 	if txType == TransactionTypeUnknown {
-		return fmt.Errorf("invalid tx type %d", txType)
+		return Errorf(ErrorInvalidFormat, "invalid tx type %d", txType)
+	}
+	if txType == TransactionTypeNodePayeeChange && !active.NodePayeeChange {
+		return Errorf(ErrorInvalidFormat, "node payee change not activated %d", txType)
 	}
 	if len(tx.Inputs) < 1 || len(tx.Outputs) < 1 {
-		return fmt.Errorf("invalid tx inputs or outputs %d %d",
+		return Errorf(ErrorInvalidFormat, "invalid tx inputs or outputs %d %d",
 			len(tx.Inputs), len(tx.Outputs))
 	}
-	if len(tx.Inputs) > SliceCountLimit || len(tx.Outputs) > SliceCountLimit ||
+	if len(tx.Inputs) > SliceCountLimit || len(tx.Outputs) > config.TransactionOutputsMaximum ||
 		len(tx.References) > SliceCountLimit {
-		return fmt.Errorf("invalid tx inputs or outputs %d %d %d",
+		return Errorf(ErrorExceedsCountLimit, "invalid tx inputs or outputs %d %d %d",
 			len(tx.Inputs), len(tx.Outputs), len(tx.References))
 	}
 	if len(tx.Extra) > tx.GetExtraLimit() {
-		return fmt.Errorf("invalid extra size %d", len(tx.Extra))
+		return Errorf(ErrorExceedsExtraLimit, "invalid extra size %d", len(tx.Extra))
 	}
 	if len(ver.PayloadMarshal()) > config.TransactionMaximumSize {
-		return fmt.Errorf("invalid transaction size %d", len(ver.PayloadMarshal()))
+		return Errorf(ErrorExceedsSizeLimit, "invalid transaction size %d", len(ver.PayloadMarshal()))
 	}
 
 	if tx.AggregatedSignature != nil {
 		if tx.SignaturesMap != nil {
-			return fmt.Errorf("invalid signatures map %d", len(tx.SignaturesMap))
+			return Errorf(ErrorInvalidSignature, "invalid signatures map %d", len(tx.SignaturesMap))
 		}
 	} else {
 		if len(tx.Inputs) != len(tx.SignaturesMap) && txType != TransactionTypeNodeAccept &&
 			txType != TransactionTypeNodeRemove {
-			return fmt.Errorf("invalid tx signature number %d %d %d",
+			return Errorf(ErrorInvalidSignature, "invalid tx signature number %d %d %d",
 				len(tx.Inputs), len(tx.SignaturesMap), txType)
 		}
 	}
@@ -52,14 +64,14 @@ func (ver *VersionedTransaction) Validate(store DataStore, snapTime uint64, fork
 	if err != nil {
 		return err
 	}
-	inputsFilter, inputAmount, err := tx.validateInputs(store, ver.PayloadHash(), txType, fork)
+	inputsFilter, inputAmount, err := tx.validateInputs(store, ver.PayloadHash(), txType, snapTime, fork)
 	if err != nil {
 		return err
 	}
 	if inputAmount.Sign() <= 0 {
-		return fmt.Errorf("invalid input amount %s", inputAmount)
+		return Errorf(ErrorInvalidAmount, "invalid input amount %s", inputAmount)
 	}
-	err = tx.validateOutputs(store, ver.PayloadHash(), inputAmount, fork)
+	err = tx.validateOutputs(store, ver.PayloadHash(), inputAmount, fork, active)
 	if err != nil {
 		return err
 	}
@@ -83,42 +95,45 @@ func (ver *VersionedTransaction) Validate(store DataStore, snapTime uint64, fork
 		return tx.validateNodeAccept(store, snapTime)
 	case TransactionTypeNodeRemove:
 		return tx.validateNodeRemove(store)
+	case TransactionTypeNodePayeeChange:
+		return tx.validateNodePayeeChange(store, inputsFilter, snapTime)
 	case TransactionTypeCustodianUpdateNodes:
 		return tx.validateCustodianUpdateNodes(store, snapTime)
 	case TransactionTypeCustodianSlashNodes:
 		return tx.validateCustodianSlashNodes(store)
 	}
-	return fmt.Errorf("invalid transaction type %d", txType)
+	return Errorf(ErrorInvalidFormat, "invalid transaction type %d", txType)
 }
 
 func (tx *SignedTransaction) GetExtraLimit() int {
 	if tx.Version < TxVersionHashSignature {
 		panic(tx.Version)
 	}
+	general := config.TransactionExtraSizeMaximum
 	if tx.Asset != XINAssetId {
-		return ExtraSizeGeneralLimit
+		return general
 	}
 	out := tx.findStorageOutput()
 	if out == nil {
-		return ExtraSizeGeneralLimit
+		return general
 	}
 	switch out.Type {
 	case OutputTypeScript:
 	case OutputTypeCustodianUpdateNodes:
 		return ExtraSizeStorageCapacity
 	default:
-		return ExtraSizeGeneralLimit
+		return general
 	}
 	step := NewIntegerFromString(ExtraStoragePriceStep)
 	if out.Amount.Cmp(step) < 0 {
-		return ExtraSizeGeneralLimit
+		return general
 	}
 	cells := out.Amount.Count(step)
 	limit := cells * ExtraSizeStorageStep
 	if limit > ExtraSizeStorageCapacity {
-		return ExtraSizeStorageCapacity
+		limit = ExtraSizeStorageCapacity
 	}
-	return int(limit)
+	return max(general, int(limit))
 }
 
 func (tx *SignedTransaction) findStorageOutput() *Output {
@@ -142,8 +157,10 @@ func (tx *SignedTransaction) findStorageOutput() *Output {
 
 func validateScriptTransaction(inputs map[string]*UTXO) error {
 	for _, in := range inputs {
-		if in.Type != OutputTypeScript && in.Type != OutputTypeNodeRemove {
-			return fmt.Errorf("invalid utxo type %d", in.Type)
+		switch in.Type {
+		case OutputTypeScript, OutputTypeNodeRemove, OutputTypeNodePayeeChange:
+		default:
+			return Errorf(ErrorInvalidInput, "invalid utxo type %d", in.Type)
 		}
 	}
 	return nil
@@ -151,7 +168,7 @@ func validateScriptTransaction(inputs map[string]*UTXO) error {
 
 func validateReferences(store TransactionReader, tx *SignedTransaction) error {
 	if len(tx.References) > ReferencesCountLimit {
-		return fmt.Errorf("too many references %d", len(tx.References))
+		return Errorf(ErrorExceedsCountLimit, "too many references %d", len(tx.References))
 	}
 
 	for _, r := range tx.References {
@@ -160,14 +177,14 @@ func validateReferences(store TransactionReader, tx *SignedTransaction) error {
 			return err
 		}
 		if rtx == nil || snap == "" {
-			return fmt.Errorf("reference not found %s", r)
+			return Errorf(ErrorReferenceNotFound, "reference not found %s", r)
 		}
 	}
 
 	return nil
 }
 
-func (tx *SignedTransaction) validateInputs(store UTXOLockReader, hash crypto.Hash, txType uint8, fork bool) (map[string]*UTXO, Integer, error) {
+func (tx *SignedTransaction) validateInputs(store UTXOLockReader, hash crypto.Hash, txType uint8, snapTime uint64, fork bool) (map[string]*UTXO, Integer, error) {
 	inputAmount := NewInteger(0)
 	inputsFilter := make(map[string]*UTXO)
 	allKeys := make([]*crypto.Key, 0)
@@ -175,7 +192,7 @@ func (tx *SignedTransaction) validateInputs(store UTXOLockReader, hash crypto.Ha
 
 	for i, in := range tx.Inputs {
 		if len(in.Genesis) > 0 {
-			return inputsFilter, inputAmount, fmt.Errorf("invalid genesis %v", in)
+			return inputsFilter, inputAmount, Errorf(ErrorInvalidInput, "invalid genesis %v", in)
 		}
 		if in.Mint != nil {
 			return inputsFilter, in.Mint.Amount, nil
@@ -186,7 +203,7 @@ func (tx *SignedTransaction) validateInputs(store UTXOLockReader, hash crypto.Ha
 
 		fk := fmt.Sprintf("%s:%d", in.Hash.String(), in.Index)
 		if inputsFilter[fk] != nil {
-			return inputsFilter, inputAmount, fmt.Errorf("invalid input %s", fk)
+			return inputsFilter, inputAmount, Errorf(ErrorInvalidInput, "invalid input %s", fk)
 		}
 
 		utxo, err := store.ReadUTXOLock(in.Hash, in.Index)
@@ -194,21 +211,21 @@ func (tx *SignedTransaction) validateInputs(store UTXOLockReader, hash crypto.Ha
 			return inputsFilter, inputAmount, err
 		}
 		if utxo == nil {
-			err := fmt.Errorf("input not found %s:%d", in.Hash.String(), in.Index)
+			err := Errorf(ErrorInputNotFound, "input not found %s:%d", in.Hash.String(), in.Index)
 			return inputsFilter, inputAmount, err
 		}
 		if utxo.Asset != tx.Asset {
-			err := fmt.Errorf("invalid input asset %s %s", utxo.Asset.String(), tx.Asset.String())
+			err := Errorf(ErrorInvalidAsset, "invalid input asset %s %s", utxo.Asset.String(), tx.Asset.String())
 			return inputsFilter, inputAmount, err
 		}
 		if utxo.LockHash.HasValue() && utxo.LockHash != hash {
 			if !fork {
-				err := fmt.Errorf("input locked for transaction %s", utxo.LockHash)
+				err := Errorf(ErrorInputLocked, "input locked for transaction %s", utxo.LockHash)
 				return inputsFilter, inputAmount, err
 			}
 		}
 
-		err = validateUTXO(i, &utxo.UTXO, tx.SignaturesMap, tx.AggregatedSignature, txType, keySigs, len(allKeys))
+		err = tx.validateUTXO(i, &utxo.UTXO, txType, keySigs, len(allKeys), snapTime)
 		if err != nil {
 			return inputsFilter, inputAmount, err
 		}
@@ -221,13 +238,13 @@ func (tx *SignedTransaction) validateInputs(store UTXOLockReader, hash crypto.Ha
 		return inputsFilter, inputAmount, nil
 	}
 	if len(keySigs) < len(tx.Inputs) {
-		err := fmt.Errorf("batch verification not ready %d %d", len(tx.Inputs), len(keySigs))
+		err := Errorf(ErrorInvalidSignature, "batch verification not ready %d %d", len(tx.Inputs), len(keySigs))
 		return inputsFilter, inputAmount, err
 	}
 	if as := tx.AggregatedSignature; as != nil {
 		err := crypto.AggregateVerify(&as.Signature, allKeys, as.Signers, hash)
 		if err != nil {
-			err := fmt.Errorf("aggregate verification failure %s", err)
+			err := Errorf(ErrorInvalidSignature, "aggregate verification failure %s", err)
 			return inputsFilter, inputAmount, err
 		}
 	} else {
@@ -238,23 +255,23 @@ func (tx *SignedTransaction) validateInputs(store UTXOLockReader, hash crypto.Ha
 			sigs = append(sigs, s)
 		}
 		if !crypto.BatchVerify(hash, keys, sigs) {
-			err := fmt.Errorf("batch verification failure %d %d", len(keys), len(sigs))
+			err := Errorf(ErrorInvalidSignature, "batch verification failure %d %d", len(keys), len(sigs))
 			return inputsFilter, inputAmount, err
 		}
 	}
 	return inputsFilter, inputAmount, nil
 }
 
-func (tx *Transaction) validateOutputs(store GhostLocker, hash crypto.Hash, inputAmount Integer, fork bool) error {
+func (tx *Transaction) validateOutputs(store GhostLocker, hash crypto.Hash, inputAmount Integer, fork bool, active ConsensusForks) error {
 	outputAmount := NewInteger(0)
 	ghostKeysFilter := make(map[crypto.Key]bool)
 	ghostKeys := make([]*crypto.Key, 0)
 	for _, o := range tx.Outputs {
 		if len(o.Keys) > SliceCountLimit {
-			return fmt.Errorf("invalid output keys count %d", len(o.Keys))
+			return Errorf(ErrorInvalidOutput, "invalid output keys count %d", len(o.Keys))
 		}
 		if o.Amount.Sign() <= 0 {
-			return fmt.Errorf("invalid output amount %s", o.Amount.String())
+			return Errorf(ErrorInvalidAmount, "invalid output amount %s", o.Amount.String())
 		}
 
 		if o.Withdrawal != nil {
@@ -264,11 +281,11 @@ func (tx *Transaction) validateOutputs(store GhostLocker, hash crypto.Hash, inpu
 
 		for _, k := range o.Keys {
 			if ghostKeysFilter[*k] {
-				return fmt.Errorf("invalid output key %s", k.String())
+				return Errorf(ErrorInvalidOutput, "invalid output key %s", k.String())
 			}
 			ghostKeysFilter[*k] = true
 			if !k.CheckKey() {
-				return fmt.Errorf("invalid output key format %s", k.String())
+				return Errorf(ErrorInvalidOutput, "invalid output key format %s", k.String())
 
 			}
 			ghostKeys = append(ghostKeys, k)
@@ -281,31 +298,47 @@ func (tx *Transaction) validateOutputs(store GhostLocker, hash crypto.Hash, inpu
 			OutputTypeNodeCancel,
 			OutputTypeNodeAccept:
 			if len(o.Keys) != 0 {
-				return fmt.Errorf("invalid output keys count %d for kernel multisig transaction", len(o.Keys))
+				return Errorf(ErrorInvalidOutput, "invalid output keys count %d for kernel multisig transaction", len(o.Keys))
 			}
 			if len(o.Script) != 0 {
-				return fmt.Errorf("invalid output script %s for kernel multisig transaction", o.Script)
+				return Errorf(ErrorInvalidOutput, "invalid output script %s for kernel multisig transaction", o.Script)
 			}
 			if o.Mask.HasValue() {
-				return fmt.Errorf("invalid output empty mask %s for kernel multisig transaction", o.Mask)
+				return Errorf(ErrorInvalidOutput, "invalid output empty mask %s for kernel multisig transaction", o.Mask)
 			}
 		default:
 			err := o.Script.VerifyFormat()
 			if err != nil {
 				return err
 			}
+			if o.Script.LockTime() > 0 && !active.LockTimeScript {
+				return Errorf(ErrorInvalidScript, "lock time script not activated %s", o.Script)
+			}
+			if o.Script.IsEscrow() {
+				if !active.EscrowScript {
+					return Errorf(ErrorInvalidScript, "escrow script not activated %s", o.Script)
+				}
+				es, _ := o.Script.Escrow()
+				if o.Type != OutputTypeScript {
+					return Errorf(ErrorInvalidOutput, "invalid escrow output type %d", o.Type)
+				}
+				err = es.VerifyKeys(len(o.Keys))
+				if err != nil {
+					return err
+				}
+			}
 			if !o.Mask.HasValue() {
-				return fmt.Errorf("invalid script output empty mask %s", o.Mask)
+				return Errorf(ErrorInvalidOutput, "invalid script output empty mask %s", o.Mask)
 			}
 			if o.Withdrawal != nil {
-				return fmt.Errorf("invalid script output with withdrawal %s", o.Withdrawal.Address)
+				return Errorf(ErrorInvalidOutput, "invalid script output with withdrawal %s", o.Withdrawal.Address)
 			}
 		}
 		outputAmount = outputAmount.Add(o.Amount)
 	}
 
 	if inputAmount.Cmp(outputAmount) != 0 {
-		return fmt.Errorf("invalid input output amount %s %s", inputAmount, outputAmount)
+		return Errorf(ErrorAmountMismatch, "invalid input output amount %s %s", inputAmount, outputAmount)
 	}
 	err := store.LockGhostKeys(ghostKeys, hash, fork)
 	if err != nil {
@@ -314,11 +347,12 @@ func (tx *Transaction) validateOutputs(store GhostLocker, hash crypto.Hash, inpu
 	return nil
 }
 
-func validateUTXO(index int, utxo *UTXO, sigs []map[uint16]*crypto.Signature, as *AggregatedSignature, txType uint8, keySigs map[*crypto.Key]*crypto.Signature, offset int) error {
+func (tx *SignedTransaction) validateUTXO(index int, utxo *UTXO, txType uint8, keySigs map[*crypto.Key]*crypto.Signature, offset int, snapTime uint64) error {
 	switch utxo.Type {
-	case OutputTypeScript, OutputTypeNodeRemove:
-		if as != nil {
-			signers, limit := 0, offset+len(utxo.Keys)
+	case OutputTypeScript, OutputTypeNodeRemove, OutputTypeNodePayeeChange:
+		var signers []int
+		if as := tx.AggregatedSignature; as != nil {
+			limit := offset + len(utxo.Keys)
 			for _, m := range as.Signers {
 				if m >= limit {
 					break
@@ -326,31 +360,42 @@ func validateUTXO(index int, utxo *UTXO, sigs []map[uint16]*crypto.Signature, as
 					continue
 				}
 				keySigs[utxo.Keys[m-offset]] = nil
-				signers += 1
+				signers = append(signers, m-offset)
 			}
-			return utxo.Script.Validate(signers)
 		} else {
-			for i, sig := range sigs[index] {
+			for i, sig := range tx.SignaturesMap[index] {
 				if int(i) >= len(utxo.Keys) {
-					return fmt.Errorf("invalid signature map index %d %d", i, len(utxo.Keys))
+					return Errorf(ErrorInvalidSignature, "invalid signature map index %d %d", i, len(utxo.Keys))
 				}
 				keySigs[utxo.Keys[i]] = sig
+				signers = append(signers, int(i))
+			}
+		}
+		if utxo.Script.IsEscrow() {
+			es, err := utxo.Script.Escrow()
+			if err != nil {
+				return err
 			}
-			return utxo.Script.Validate(len(sigs[index]))
+			return es.Validate(signers, tx.Extra, snapTime)
+		}
+		err := utxo.Script.ValidateLockTime(snapTime)
+		if err != nil {
+			return err
 		}
+		return utxo.Script.Validate(len(signers))
 	case OutputTypeNodePledge:
 		if txType == TransactionTypeNodeAccept || txType == TransactionTypeNodeCancel {
 			return nil
 		}
-		return fmt.Errorf("pledge input used for invalid transaction type %d", txType)
+		return Errorf(ErrorInvalidInput, "pledge input used for invalid transaction type %d", txType)
 	case OutputTypeNodeAccept:
 		if txType == TransactionTypeNodeRemove {
 			return nil
 		}
-		return fmt.Errorf("accept input used for invalid transaction type %d", txType)
+		return Errorf(ErrorInvalidInput, "accept input used for invalid transaction type %d", txType)
 	case OutputTypeNodeCancel:
-		return fmt.Errorf("should do more validation on those %d UTXOs", utxo.Type)
+		return Errorf(ErrorInvalidInput, "should do more validation on those %d UTXOs", utxo.Type)
 	default:
-		return fmt.Errorf("invalid input type %d", utxo.Type)
+		return Errorf(ErrorInvalidInput, "invalid input type %d", utxo.Type)
 	}
 }
@@ -111,12 +111,12 @@ func checkSnapVersion(val []byte) uint8 {
 
 func unmarshalVersionedTransaction(val []byte) (*VersionedTransaction, error) {
 	if len(val) > config.TransactionMaximumSize {
-		return nil, fmt.Errorf("transaction too large %d", len(val))
+		return nil, Errorf(ErrorExceedsSizeLimit, "transaction too large %d", len(val))
 	}
 
 	signed, err := NewDecoder(val).DecodeTransaction()
 	if err != nil {
-		return nil, err
+		return nil, Errorf(ErrorInvalidFormat, "%w", err)
 	}
 	ver := &VersionedTransaction{SignedTransaction: *signed}
 	return ver, nil
@@ -1,7 +1,7 @@
 package common
 
 import (
-	"fmt"
+	"sort"
 
 	"github.com/MixinNetwork/mixin/config"
 	"github.com/MixinNetwork/mixin/crypto"
@@ -12,34 +12,155 @@ type WithdrawalData struct {
 	Tag     string
 }
 
+type WithdrawalSubmission struct {
+	Transaction *VersionedTransaction
+	Chain       crypto.Hash
+}
+
+type WithdrawalClaim struct {
+	Submission crypto.Hash
+	Fee        Integer
+	Info       []byte
+}
+
+type WithdrawalClaimBatch struct {
+	Chain  crypto.Hash
+	Claims []*WithdrawalClaim
+	Total  Integer
+}
+
+// the estimator returns the claim fee in XIN for the withdrawal submission
+// to the destination chain, and the fee should cover the cost to withdraw
+type WithdrawalClaimFeeEstimator func(chain crypto.Hash, submit *VersionedTransaction) (Integer, error)
+
+func EstimateMinimumWithdrawalClaimFee(chain crypto.Hash, submit *VersionedTransaction) (Integer, error) {
+	return NewIntegerFromString(config.WithdrawalClaimFee), nil
+}
+
+// GroupWithdrawalClaims groups the withdrawal submissions by the destination
+// chain, and estimates the claim fee for each submission, the batches are
+// sorted by the chain and the claims keep the submissions order.
+func GroupWithdrawalClaims(submissions []*WithdrawalSubmission, estimate WithdrawalClaimFeeEstimator) ([]*WithdrawalClaimBatch, error) {
+	minimum := NewIntegerFromString(config.WithdrawalClaimFee)
+	batches := make(map[crypto.Hash]*WithdrawalClaimBatch)
+	for _, s := range submissions {
+		ver := s.Transaction
+		if ver.TransactionType() != TransactionTypeWithdrawalSubmit {
+			return nil, Errorf(ErrorInvalidWithdrawal, "invalid withdrawal submission %s", ver.PayloadHash())
+		}
+		fee, err := estimate(s.Chain, ver)
+		if err != nil {
+			return nil, err
+		}
+		if fee.Cmp(minimum) < 0 {
+			return nil, Errorf(ErrorInvalidWithdrawal, "invalid withdrawal claim fee %s for %s", fee, ver.PayloadHash())
+		}
+		b := batches[s.Chain]
+		if b == nil {
+			b = &WithdrawalClaimBatch{Chain: s.Chain, Total: Zero}
+			batches[s.Chain] = b
+		}
+		b.Claims = append(b.Claims, &WithdrawalClaim{
+			Submission: ver.PayloadHash(),
+			Fee:        fee,
+		})
+		b.Total = b.Total.Add(fee)
+	}
+
+	result := make([]*WithdrawalClaimBatch, 0, len(batches))
+	for _, b := range batches {
+		result = append(result, b)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Chain.String() < result[j].Chain.String()
+	})
+	return result, nil
+}
+
+// NewWithdrawalClaims builds one claim transaction for each claim, the XIN
+// inputs are spent in order to pay the fee of each claim, and the remaining
+// amount goes to the change address. The extra starts with an empty custodian
+// signature to be replaced by the signature of the claim info hash, and the
+// unused inputs are returned for the next batch.
+func NewWithdrawalClaims(claims []*WithdrawalClaim, inputs []*TransferInput, receiver, change *Address, seed []byte) ([]*Transaction, []*TransferInput, error) {
+	if len(seed) != 64 {
+		return nil, nil, Errorf(ErrorInvalidWithdrawal, "invalid withdrawal claim seed length %d", len(seed))
+	}
+	if receiver == nil {
+		return nil, nil, Errorf(ErrorInvalidWithdrawal, "invalid withdrawal claim receiver")
+	}
+
+	minimum := NewIntegerFromString(config.WithdrawalClaimFee)
+	script := NewThresholdScript(1)
+	var txs []*Transaction
+	for _, c := range claims {
+		var sig crypto.Signature
+		if c.Fee.Cmp(minimum) < 0 {
+			return nil, nil, Errorf(ErrorInvalidWithdrawal, "invalid withdrawal claim fee %s for %s", c.Fee, c.Submission)
+		}
+		if len(sig)+len(c.Info) > ExtraSizeGeneralLimit {
+			return nil, nil, Errorf(ErrorInvalidWithdrawal, "invalid withdrawal claim info size %d", len(c.Info))
+		}
+
+		tx := NewTransactionV5(XINAssetId)
+		tx.References = []crypto.Hash{c.Submission}
+		tx.Extra = append(sig[:], c.Info...)
+		spent := Zero
+		for spent.Cmp(c.Fee) < 0 && len(inputs) > 0 && len(tx.Inputs) < SliceCountLimit {
+			in := inputs[0]
+			if in.Amount.Sign() <= 0 {
+				return nil, nil, Errorf(ErrorInvalidWithdrawal, "invalid withdrawal claim input %s:%d", in.Hash, in.Index)
+			}
+			tx.AddInput(in.Hash, in.Index)
+			spent = spent.Add(in.Amount)
+			inputs = inputs[1:]
+		}
+		if spent.Cmp(c.Fee) < 0 {
+			return nil, nil, Errorf(ErrorInsufficientInputs, "insufficient withdrawal claim inputs %s %s", spent, c.Fee)
+		}
+
+		seed = nextBatchTransferSeed(seed)
+		tx.AddOutputWithType(OutputTypeWithdrawalClaim, []*Address{receiver}, script, c.Fee, seed)
+		if spent.Cmp(c.Fee) > 0 {
+			if change == nil {
+				return nil, nil, Errorf(ErrorInvalidWithdrawal, "missing withdrawal claim change address for %s", spent.Sub(c.Fee))
+			}
+			seed = nextBatchTransferSeed(seed)
+			tx.AddScriptOutput([]*Address{change}, script, spent.Sub(c.Fee), seed)
+		}
+		txs = append(txs, tx)
+	}
+	return txs, inputs, nil
+}
+
 func (tx *Transaction) validateWithdrawalSubmit(inputs map[string]*UTXO) error {
 	for _, in := range inputs {
 		if in.Type != OutputTypeScript {
-			return fmt.Errorf("invalid utxo type %d", in.Type)
+			return Errorf(ErrorInvalidInput, "invalid utxo type %d", in.Type)
 		}
 	}
 	for _, o := range tx.Outputs[1:] {
 		if o.Type != OutputTypeScript {
-			return fmt.Errorf("invalid change type %d for withdrawal submit transaction", tx.Outputs[1].Type)
+			return Errorf(ErrorInvalidWithdrawal, "invalid change type %d for withdrawal submit transaction", tx.Outputs[1].Type)
 		}
 	}
 
 	submit := tx.Outputs[0]
 	if submit.Type != OutputTypeWithdrawalSubmit {
-		return fmt.Errorf("invalid output type %d for withdrawal submit transaction", submit.Type)
+		return Errorf(ErrorInvalidWithdrawal, "invalid output type %d for withdrawal submit transaction", submit.Type)
 	}
 	if submit.Withdrawal == nil {
-		return fmt.Errorf("invalid withdrawal submit data")
+		return Errorf(ErrorInvalidWithdrawal, "invalid withdrawal submit data")
 	}
 
 	if len(submit.Keys) != 0 {
-		return fmt.Errorf("invalid withdrawal submit keys %d", len(submit.Keys))
+		return Errorf(ErrorInvalidWithdrawal, "invalid withdrawal submit keys %d", len(submit.Keys))
 	}
 	if len(submit.Script) != 0 {
-		return fmt.Errorf("invalid withdrawal submit script %s", submit.Script)
+		return Errorf(ErrorInvalidWithdrawal, "invalid withdrawal submit script %s", submit.Script)
 	}
 	if submit.Mask.HasValue() {
-		return fmt.Errorf("invalid withdrawal submit mask %s", submit.Mask)
+		return Errorf(ErrorInvalidWithdrawal, "invalid withdrawal submit mask %s", submit.Mask)
 	}
 
 	return nil
@@ -48,28 +169,28 @@ func (tx *Transaction) validateWithdrawalSubmit(inputs map[string]*UTXO) error {
 func (tx *Transaction) validateWithdrawalClaim(store DataStore, inputs map[string]*UTXO, snapTime uint64) error {
 	for _, in := range inputs {
 		if in.Type != OutputTypeScript {
-			return fmt.Errorf("invalid utxo type %d", in.Type)
+			return Errorf(ErrorInvalidInput, "invalid utxo type %d", in.Type)
 		}
 	}
 
 	if tx.Asset != XINAssetId {
-		return fmt.Errorf("invalid asset %s for withdrawal claim transaction", tx.Asset)
+		return Errorf(ErrorInvalidAsset, "invalid asset %s for withdrawal claim transaction", tx.Asset)
 	}
 	for _, o := range tx.Outputs[1:] {
 		if o.Type != OutputTypeScript {
-			return fmt.Errorf("invalid change type %d for withdrawal claim transaction", tx.Outputs[1].Type)
+			return Errorf(ErrorInvalidWithdrawal, "invalid change type %d for withdrawal claim transaction", tx.Outputs[1].Type)
 		}
 	}
 	if len(tx.References) != 1 {
-		return fmt.Errorf("invalid references count %d for withdrawal claim transaction", len(tx.References))
+		return Errorf(ErrorInvalidWithdrawal, "invalid references count %d for withdrawal claim transaction", len(tx.References))
 	}
 
 	claim := tx.Outputs[0]
 	if claim.Type != OutputTypeWithdrawalClaim {
-		return fmt.Errorf("invalid output type %d for withdrawal claim transaction", claim.Type)
+		return Errorf(ErrorInvalidWithdrawal, "invalid output type %d for withdrawal claim transaction", claim.Type)
 	}
 	if claim.Amount.Cmp(NewIntegerFromString(config.WithdrawalClaimFee)) < 0 {
-		return fmt.Errorf("invalid output amount %s for withdrawal claim transaction", claim.Amount)
+		return Errorf(ErrorInvalidAmount, "invalid output amount %s for withdrawal claim transaction", claim.Amount)
 	}
 
 	submit, _, err := store.ReadTransaction(tx.References[0])
@@ -77,16 +198,16 @@ func (tx *Transaction) validateWithdrawalClaim(store DataStore, inputs map[strin
 		return err
 	}
 	if submit == nil {
-		return fmt.Errorf("invalid withdrawal submit data")
+		return Errorf(ErrorInvalidWithdrawal, "invalid withdrawal submit data")
 	}
 	withdrawal := submit.Outputs[0].Withdrawal
 	if withdrawal == nil || submit.Outputs[0].Type != OutputTypeWithdrawalSubmit {
-		return fmt.Errorf("invalid withdrawal submit data")
+		return Errorf(ErrorInvalidWithdrawal, "invalid withdrawal submit data")
 	}
 
 	var sig crypto.Signature
 	if len(tx.Extra) < len(sig) {
-		return fmt.Errorf("invalid withdrawal claim information")
+		return Errorf(ErrorInvalidWithdrawal, "invalid withdrawal claim information")
 	}
 	copy(sig[:], tx.Extra[:len(sig)])
 	eh := crypto.Blake3Hash(tx.Extra[len(sig):])
@@ -95,7 +216,7 @@ func (tx *Transaction) validateWithdrawalClaim(store DataStore, inputs map[strin
 		return err
 	}
 	if !cur.Custodian.PublicSpendKey.Verify(eh, sig) {
-		return fmt.Errorf("invalid custodian signature for withdrawal claim")
+		return Errorf(ErrorInvalidSignature, "invalid custodian signature for withdrawal claim")
 	}
 	return nil
 }
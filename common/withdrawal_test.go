@@ -0,0 +1,103 @@
+package common
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithdrawalClaims(t *testing.T) {
+	require := require.New(t)
+
+	submit := func(asset crypto.Hash, i int) *VersionedTransaction {
+		tx := NewTransactionV5(asset)
+		tx.AddInput(crypto.Blake3Hash([]byte{byte(i)}), 0)
+		tx.Outputs = []*Output{{
+			Type:       OutputTypeWithdrawalSubmit,
+			Amount:     NewInteger(1),
+			Withdrawal: &WithdrawalData{Address: "0xMIXINTODAMOON"},
+		}}
+		return tx.AsVersioned()
+	}
+	submissions := []*WithdrawalSubmission{
+		{Transaction: submit(EthereumAssetId, 0), Chain: EthereumAssetId},
+		{Transaction: submit(BitcoinAssetId, 1), Chain: BitcoinAssetId},
+		{Transaction: submit(USDTEthereumAssetId, 2), Chain: EthereumAssetId},
+	}
+
+	_, err := GroupWithdrawalClaims(submissions, func(chain crypto.Hash, ver *VersionedTransaction) (Integer, error) {
+		return NewIntegerFromString("0.00001"), nil
+	})
+	require.NotNil(err)
+	_, err = GroupWithdrawalClaims(submissions, func(chain crypto.Hash, ver *VersionedTransaction) (Integer, error) {
+		return Zero, errors.New("estimate")
+	})
+	require.NotNil(err)
+	transfer := NewTransactionV5(XINAssetId).AsVersioned()
+	transfer.AddInput(crypto.Blake3Hash([]byte("transfer")), 0)
+	transfer.AddRandomScriptOutput([]*Address{}, NewThresholdScript(1), NewInteger(1))
+	_, err = GroupWithdrawalClaims([]*WithdrawalSubmission{{Transaction: transfer}}, EstimateMinimumWithdrawalClaimFee)
+	require.NotNil(err)
+
+	batches, err := GroupWithdrawalClaims(submissions, func(chain crypto.Hash, ver *VersionedTransaction) (Integer, error) {
+		if chain == BitcoinAssetId {
+			return NewIntegerFromString("0.01"), nil
+		}
+		return EstimateMinimumWithdrawalClaimFee(chain, ver)
+	})
+	require.Nil(err)
+	require.Len(batches, 2)
+	eth := batches[0]
+	if eth.Chain != EthereumAssetId {
+		eth = batches[1]
+	}
+	require.Len(eth.Claims, 2)
+	require.Equal(submissions[0].Transaction.PayloadHash(), eth.Claims[0].Submission)
+	require.Equal(submissions[2].Transaction.PayloadHash(), eth.Claims[1].Submission)
+	require.Equal("0.00020000", eth.Total.String())
+
+	seed := make([]byte, 64)
+	crypto.ReadRand(seed)
+	receiver := randomAccount()
+	change := randomAccount()
+	inputs := []*TransferInput{
+		{Hash: crypto.Blake3Hash([]byte("a")), Index: 0, Amount: NewIntegerFromString("0.00005")},
+		{Hash: crypto.Blake3Hash([]byte("b")), Index: 1, Amount: NewIntegerFromString("0.00005")},
+		{Hash: crypto.Blake3Hash([]byte("c")), Index: 2, Amount: NewInteger(1)},
+		{Hash: crypto.Blake3Hash([]byte("d")), Index: 3, Amount: NewInteger(1)},
+	}
+	_, _, err = NewWithdrawalClaims(eth.Claims, inputs, &receiver, &change, seed[:32])
+	require.NotNil(err)
+	_, _, err = NewWithdrawalClaims(eth.Claims, inputs[:2], &receiver, &change, seed)
+	require.NotNil(err)
+	_, _, err = NewWithdrawalClaims(eth.Claims, inputs, &receiver, nil, seed)
+	require.NotNil(err)
+
+	eth.Claims[0].Info = []byte("0xETHEREUMTRANSACTION")
+	txs, rest, err := NewWithdrawalClaims(eth.Claims, inputs, &receiver, &change, seed)
+	require.Nil(err)
+	require.Len(txs, 2)
+	require.Len(rest, 1)
+	require.Equal(inputs[3], rest[0])
+
+	tx := txs[0]
+	require.Equal(XINAssetId, tx.Asset)
+	require.Len(tx.Inputs, 2)
+	require.Len(tx.Outputs, 1)
+	require.Equal(uint8(OutputTypeWithdrawalClaim), tx.Outputs[0].Type)
+	require.Equal("0.00010000", tx.Outputs[0].Amount.String())
+	require.Equal([]crypto.Hash{eth.Claims[0].Submission}, tx.References)
+	require.Equal(append(make([]byte, 64), []byte("0xETHEREUMTRANSACTION")...), tx.Extra)
+	_, found := tx.Outputs[0].ViewKeyIndex(&receiver, 0)
+	require.True(found)
+
+	tx = txs[1]
+	require.Len(tx.Inputs, 1)
+	require.Len(tx.Outputs, 2)
+	require.Equal("0.99990000", tx.Outputs[1].Amount.String())
+	require.Len(tx.Extra, 64)
+	_, found = tx.Outputs[1].ViewKeyIndex(&change, 1)
+	require.True(found)
+}
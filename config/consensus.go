@@ -0,0 +1,122 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	SnapshotRoundGapMinimum = uint64(100 * time.Millisecond)
+	SnapshotRoundGapMaximum = uint64(10 * time.Second)
+
+	kernelMinimumNodesCountMinimum = 4
+	kernelNodePeriodMinimum        = time.Hour
+
+	// the outputs and extra limits are never lower than the mainnet, which
+	// the mint and node transactions rely on, and the output index is at most
+	// 1024 to be spent, the extra at most the storage capacity to be encoded.
+	transactionMaximumSizeMinimum      = 64 * 1024
+	transactionMaximumSizeMaximum      = 16 * 1024 * 1024
+	transactionOutputsMaximumMaximum   = 1024
+	transactionExtraSizeMaximumMaximum = 4 * 1024 * 1024
+)
+
+// ConsensusParams are the consensus parameters declared by the genesis, the
+// zero values are the mainnet defaults.
+type ConsensusParams struct {
+	RoundGap            uint64
+	MinimumNodes        int
+	PledgePeriodMinimum time.Duration
+	AcceptPeriodMinimum time.Duration
+	AcceptPeriodMaximum time.Duration
+	TransactionSize     int
+	OutputsCount        int
+	ExtraSize           int
+}
+
+// Validate fills the defaults and checks the bounds the kernel relies on,
+// e.g. the pledging node is counted in the consensus base at least an hour
+// after its pledge, and the election skips the first and the last nodes.
+func (p *ConsensusParams) Validate(dev bool) error {
+	if p.RoundGap == 0 {
+		p.RoundGap = SnapshotRoundGapDefault
+	}
+	if p.MinimumNodes == 0 {
+		p.MinimumNodes = KernelMinimumNodesCountDefault
+	}
+	if p.PledgePeriodMinimum == 0 {
+		p.PledgePeriodMinimum = KernelNodePledgePeriodMinimumDefault
+	}
+	if p.AcceptPeriodMinimum == 0 {
+		p.AcceptPeriodMinimum = KernelNodeAcceptPeriodMinimumDefault
+	}
+	if p.AcceptPeriodMaximum == 0 {
+		p.AcceptPeriodMaximum = KernelNodeAcceptPeriodMaximumDefault
+	}
+	if p.TransactionSize == 0 {
+		p.TransactionSize = TransactionMaximumSizeDefault
+	}
+	if p.OutputsCount == 0 {
+		p.OutputsCount = TransactionOutputsMaximumDefault
+	}
+	if p.ExtraSize == 0 {
+		p.ExtraSize = TransactionExtraSizeMaximumDefault
+	}
+
+	if p.RoundGap < SnapshotRoundGapMinimum || p.RoundGap > SnapshotRoundGapMaximum {
+		return fmt.Errorf("invalid round gap %s", time.Duration(p.RoundGap))
+	}
+	if !dev && p.MinimumNodes < kernelMinimumNodesCountMinimum {
+		return fmt.Errorf("invalid minimum nodes %d", p.MinimumNodes)
+	}
+	if p.PledgePeriodMinimum < kernelNodePeriodMinimum {
+		return fmt.Errorf("invalid pledge period minimum %s", p.PledgePeriodMinimum)
+	}
+	if p.AcceptPeriodMinimum < kernelNodePeriodMinimum {
+		return fmt.Errorf("invalid accept period minimum %s", p.AcceptPeriodMinimum)
+	}
+	if p.AcceptPeriodMaximum <= p.AcceptPeriodMinimum {
+		return fmt.Errorf("invalid accept period maximum %s", p.AcceptPeriodMaximum)
+	}
+	if p.TransactionSize < transactionMaximumSizeMinimum || p.TransactionSize > transactionMaximumSizeMaximum {
+		return fmt.Errorf("invalid transaction size %d", p.TransactionSize)
+	}
+	if p.OutputsCount < TransactionOutputsMaximumDefault || p.OutputsCount > transactionOutputsMaximumMaximum {
+		return fmt.Errorf("invalid outputs count %d", p.OutputsCount)
+	}
+	if p.ExtraSize < TransactionExtraSizeMaximumDefault || p.ExtraSize > transactionExtraSizeMaximumMaximum {
+		return fmt.Errorf("invalid extra size %d", p.ExtraSize)
+	}
+	if p.ExtraSize >= p.TransactionSize {
+		return fmt.Errorf("invalid extra size %d for transaction size %d", p.ExtraSize, p.TransactionSize)
+	}
+	return nil
+}
+
+// SetConsensusParams applies the validated params to the whole process, they
+// are read by the kernel and the transaction validation without lock, so it
+// must be called once before any node starts.
+func SetConsensusParams(p ConsensusParams) {
+	SnapshotRoundGap = p.RoundGap
+	KernelMinimumNodesCount = p.MinimumNodes
+	KernelNodePledgePeriodMinimum = p.PledgePeriodMinimum
+	KernelNodeAcceptPeriodMinimum = p.AcceptPeriodMinimum
+	KernelNodeAcceptPeriodMaximum = p.AcceptPeriodMaximum
+	TransactionMaximumSize = p.TransactionSize
+	TransactionOutputsMaximum = p.OutputsCount
+	TransactionExtraSizeMaximum = p.ExtraSize
+}
+
+// CurrentConsensusParams returns the params applied to the process.
+func CurrentConsensusParams() ConsensusParams {
+	return ConsensusParams{
+		RoundGap:            SnapshotRoundGap,
+		MinimumNodes:        KernelMinimumNodesCount,
+		PledgePeriodMinimum: KernelNodePledgePeriodMinimum,
+		AcceptPeriodMinimum: KernelNodeAcceptPeriodMinimum,
+		AcceptPeriodMaximum: KernelNodeAcceptPeriodMaximum,
+		TransactionSize:     TransactionMaximumSize,
+		OutputsCount:        TransactionOutputsMaximum,
+		ExtraSize:           TransactionExtraSizeMaximum,
+	}
+}
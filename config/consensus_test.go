@@ -0,0 +1,47 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsensusParams(t *testing.T) {
+	require := require.New(t)
+
+	var params ConsensusParams
+	err := params.Validate(false)
+	require.Nil(err)
+	require.Equal(SnapshotRoundGapDefault, params.RoundGap)
+	require.Equal(KernelMinimumNodesCountDefault, params.MinimumNodes)
+	require.Equal(KernelNodePledgePeriodMinimumDefault, params.PledgePeriodMinimum)
+	require.Equal(KernelNodeAcceptPeriodMinimumDefault, params.AcceptPeriodMinimum)
+	require.Equal(KernelNodeAcceptPeriodMaximumDefault, params.AcceptPeriodMaximum)
+	require.Equal(TransactionMaximumSizeDefault, params.TransactionSize)
+	require.Equal(TransactionOutputsMaximumDefault, params.OutputsCount)
+	require.Equal(TransactionExtraSizeMaximumDefault, params.ExtraSize)
+	require.Equal(params, CurrentConsensusParams())
+
+	params = ConsensusParams{MinimumNodes: 1}
+	require.ErrorContains(params.Validate(false), "invalid minimum nodes 1")
+	require.Nil(params.Validate(true))
+	params = ConsensusParams{RoundGap: uint64(time.Minute)}
+	require.ErrorContains(params.Validate(false), "invalid round gap 1m0s")
+	params = ConsensusParams{PledgePeriodMinimum: time.Minute}
+	require.ErrorContains(params.Validate(false), "invalid pledge period minimum 1m0s")
+	params = ConsensusParams{AcceptPeriodMinimum: 8 * 24 * time.Hour}
+	require.ErrorContains(params.Validate(false), "invalid accept period maximum 168h0m0s")
+	params = ConsensusParams{TransactionSize: 1024}
+	require.ErrorContains(params.Validate(false), "invalid transaction size 1024")
+	params = ConsensusParams{OutputsCount: 128}
+	require.ErrorContains(params.Validate(false), "invalid outputs count 128")
+	params = ConsensusParams{OutputsCount: 2048}
+	require.ErrorContains(params.Validate(false), "invalid outputs count 2048")
+	params = ConsensusParams{ExtraSize: 8 * 1024 * 1024}
+	require.ErrorContains(params.Validate(false), "invalid extra size 8388608")
+	params = ConsensusParams{TransactionSize: 64 * 1024, ExtraSize: 64 * 1024}
+	require.ErrorContains(params.Validate(false), "invalid extra size 65536 for transaction size 65536")
+	params = ConsensusParams{TransactionSize: 64 * 1024, OutputsCount: 1024, ExtraSize: 4096}
+	require.Nil(params.Validate(false))
+}
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"strconv"
+)
+
+const listenerTokenMinimumLength = 32
+
+// ListenAddress is the address to listen on the port of the interface, an
+// empty listen is all interfaces.
+func ListenAddress(listen string, port int) string {
+	return net.JoinHostPort(listen, strconv.Itoa(port))
+}
+
+// the TLS files are relative to the config file directory
+func (c *Custom) resolveListeners(dir string) {
+	for _, path := range []*string{
+		&c.RPC.TLSCert, &c.RPC.TLSKey,
+		&c.Admin.TLSCert, &c.Admin.TLSKey,
+		&c.Dev.TLSCert, &c.Dev.TLSKey,
+		&c.Replica.CA,
+	} {
+		if *path != "" && !filepath.IsAbs(*path) {
+			*path = filepath.Join(dir, *path)
+		}
+	}
+}
+
+func (c *Custom) applyListenerDefaults() {
+	// the admin and diagnostics listeners without a token are only
+	// protected by the loopback interface
+	if c.Admin.Listen == "" && c.Admin.Token == "" {
+		c.Admin.Listen = "127.0.0.1"
+	}
+	if c.Dev.Listen == "" && c.Dev.Token == "" {
+		c.Dev.Listen = "127.0.0.1"
+	}
+}
+
+// validateListeners checks the listeners of all the roles, so the consensus,
+// the public RPC, the admin RPC and the diagnostics never share an address.
+func (c *Custom) validateListeners() error {
+	listeners := []struct {
+		section string
+		listen  string
+		port    int
+		cert    string
+		key     string
+		token   string
+		private bool
+	}{
+		{"p2p", c.P2P.Listen, c.P2P.Port, "", "", "", false},
+		{"rpc", c.RPC.Listen, c.RPC.Port, c.RPC.TLSCert, c.RPC.TLSKey, c.RPC.Token, false},
+		{"admin", c.Admin.Listen, c.Admin.Port, c.Admin.TLSCert, c.Admin.TLSKey, c.Admin.Token, true},
+		{"dev", c.Dev.Listen, c.Dev.Port, c.Dev.TLSCert, c.Dev.TLSKey, c.Dev.Token, true},
+	}
+	for i, l := range listeners {
+		err := checkPort(l.port, l.section != "p2p")
+		if err != nil {
+			return invalidError(l.section+".port", err.Error())
+		}
+		if l.listen != "" && net.ParseIP(l.listen) == nil {
+			return invalidError(l.section+".listen", fmt.Sprintf("%s not an IP address", l.listen))
+		}
+		if (l.cert == "") != (l.key == "") {
+			return invalidError(l.section+".tls-key", "requires both tls-cert and tls-key")
+		}
+		if t := l.token; t != "" && len(t) < listenerTokenMinimumLength {
+			return invalidError(l.section+".token", fmt.Sprintf("length %d less than %d", len(t), listenerTokenMinimumLength))
+		}
+		if l.private && l.token == "" && !isLoopback(l.listen) {
+			return invalidError(l.section+".listen", fmt.Sprintf("%s requires %s.token", l.listen, l.section))
+		}
+		for _, o := range listeners[:i] {
+			// the p2p port is UDP, and the others are TCP
+			if l.port == 0 || o.port != l.port || o.section == "p2p" {
+				continue
+			}
+			if l.listen == "" || o.listen == "" || l.listen == o.listen {
+				return invalidError(l.section+".port", fmt.Sprintf("%d conflicts with %s.port", l.port, o.section))
+			}
+		}
+	}
+	return nil
+}
+
+func isLoopback(listen string) bool {
+	ip := net.ParseIP(listen)
+	return ip != nil && ip.IsLoopback()
+}
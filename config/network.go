@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/pelletier/go-toml"
+)
+
+const networksSection = "networks"
+
+// the profile keys besides the config keys, the paths are relative to the
+// directory of the config file
+var networkKeys = map[string]bool{
+	"genesis": true,
+	"data":    true,
+}
+
+// applyNetwork overrides the values in the tree with the selected network
+// profile in the networks section, e.g. the [networks.testnet.p2p] port
+// overrides the [p2p] port, and the profile genesis and data paths default
+// to the genesis.json and the data directory under the network name.
+func applyNetwork(tree *toml.Tree, network string, config *Custom) error {
+	config.Network.Name = network
+	config.Network.Genesis = "genesis.json"
+	config.Network.Data = "."
+	if network == "" {
+		return nil
+	}
+	profile, ok := tree.GetPath([]string{networksSection, network}).(*toml.Tree)
+	if !ok {
+		return fmt.Errorf("invalid config network %s: not found", network)
+	}
+	config.Network.Genesis = filepath.Join(network, "genesis.json")
+	config.Network.Data = network
+
+	fields := configFields()
+	prefix := networksSection + "." + network + "."
+	for _, key := range flattenKeys(profile, "") {
+		val := profile.GetPath(strings.Split(key, "."))
+		if networkKeys[key] {
+			s, ok := val.(string)
+			if !ok || s == "" {
+				return fmt.Errorf("invalid config %s%s: not a path", prefix, key)
+			}
+			if key == "genesis" {
+				config.Network.Genesis = s
+			} else {
+				config.Network.Data = s
+			}
+			continue
+		}
+		if fields[key] == nil {
+			return fmt.Errorf("invalid config %s%s: unknown key", prefix, key)
+		}
+		tree.SetPath(strings.Split(key, "."), val)
+	}
+	return nil
+}
+
+// the network profile paths are relative to the config file directory
+func (c *Custom) resolveNetwork(dir string) {
+	if !filepath.IsAbs(c.Network.Genesis) {
+		c.Network.Genesis = filepath.Join(dir, c.Network.Genesis)
+	}
+	if !filepath.IsAbs(c.Network.Data) {
+		c.Network.Data = filepath.Join(dir, c.Network.Data)
+	}
+}
+
+// networkKey returns the key in the profile for the networks.name.key
+func networkKey(key string) (string, bool) {
+	parts := strings.SplitN(key, ".", 3)
+	if len(parts) != 3 || parts[0] != networksSection {
+		return "", false
+	}
+	return parts[2], true
+}
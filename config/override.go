@@ -0,0 +1,91 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml"
+)
+
+const EnvPrefix = "MIXIN_"
+
+// EnvName returns the environment variable to override the key, e.g.
+// MIXIN_NODE_SIGNER_KEY for node.signer-key.
+func EnvName(key string) string {
+	r := strings.NewReplacer(".", "_", "-", "_")
+	return EnvPrefix + strings.ToUpper(r.Replace(key))
+}
+
+// applyOverrides sets the values in the tree, the environment variables
+// override the file, and the key=value overrides, usually from the command
+// line flags, override both of them. The lists are comma separated.
+func applyOverrides(tree *toml.Tree, lookup func(string) (string, bool), sets []string) error {
+	fields := configFields()
+	if lookup != nil {
+		for key, typ := range fields {
+			val, ok := lookup(EnvName(key))
+			if !ok {
+				continue
+			}
+			err := setValue(tree, key, typ, val)
+			if err != nil {
+				return fmt.Errorf("invalid config override %s: %v", EnvName(key), err)
+			}
+		}
+	}
+	for _, s := range sets {
+		key, val, ok := strings.Cut(s, "=")
+		if !ok {
+			return fmt.Errorf("invalid config override %s: not key=value", s)
+		}
+		key = strings.TrimSpace(key)
+		typ := fields[key]
+		if typ == nil {
+			return fmt.Errorf("invalid config override %s: unknown key", s)
+		}
+		err := setValue(tree, key, typ, val)
+		if err != nil {
+			return fmt.Errorf("invalid config override %s: %v", key, err)
+		}
+	}
+	return nil
+}
+
+func setValue(tree *toml.Tree, key string, typ reflect.Type, val string) error {
+	var v any
+	var err error
+	switch typ.Kind() {
+	case reflect.String:
+		v = val
+	case reflect.Bool:
+		v, err = strconv.ParseBool(val)
+	case reflect.Int:
+		v, err = strconv.ParseInt(val, 10, 64)
+	case reflect.Uint64:
+		var u uint64
+		u, err = strconv.ParseUint(val, 10, 64)
+		v = int64(u)
+		if u > 1<<63-1 {
+			err = fmt.Errorf("%d out of range", u)
+		}
+	case reflect.Float64:
+		v, err = strconv.ParseFloat(val, 64)
+	case reflect.Slice:
+		list := []any{}
+		for _, s := range strings.Split(val, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				list = append(list, s)
+			}
+		}
+		v = list
+	default:
+		err = fmt.Errorf("unsupported type %s", typ)
+	}
+	if err != nil {
+		return err
+	}
+	tree.SetPath(strings.Split(key, "."), v)
+	return nil
+}
@@ -2,9 +2,11 @@ package config
 
 import (
 	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
 	"github.com/pelletier/go-toml"
 )
 
@@ -13,81 +15,198 @@ const (
 	BuildVersion    = "v0.18.19-BUILD_VERSION"
 	KernelNetworkId = "74c6cdb7d51af57037faa1f5544f8331ced001df5964331911ca51385993b375"
 
-	SnapshotRoundGap           = uint64(3 * time.Second)
+	SnapshotRoundGapDefault    = uint64(3 * time.Second)
 	SnapshotReferenceThreshold = 10
 	SnapshotSyncRoundThreshold = 100
 	SnapshotRoundSize          = 200
 
 	CheckpointDuration        = 10 * time.Minute
 	CheckpointPunishmentGrade = 7
+	StateCheckpointInterval   = time.Hour
 
-	TransactionMaximumSize = 1024 * 1024 * 4
-	WithdrawalClaimFee     = "0.0001"
-	GossipSize             = 3
+	TransactionMaximumSizeDefault      = 1024 * 1024 * 4
+	TransactionOutputsMaximumDefault   = 256
+	TransactionExtraSizeMaximumDefault = 256
+	WithdrawalClaimFee                 = "0.0001"
+	GossipSize                         = 3
 
-	KernelMinimumNodesCount = 7
+	KernelMinimumNodesCountDefault = 7
 
 	KernelMintTimeBegin = 7
 	KernelMintTimeEnd   = 9
 
-	KernelNodeAcceptTimeBegin     = 13
-	KernelNodeAcceptTimeEnd       = 19
-	KernelNodePledgePeriodMinimum = 12 * time.Hour
-	KernelNodeAcceptPeriodMinimum = 12 * time.Hour
-	KernelNodeAcceptPeriodMaximum = 7 * 24 * time.Hour
+	KernelNodeAcceptTimeBegin            = 13
+	KernelNodeAcceptTimeEnd              = 19
+	KernelNodePledgePeriodMinimumDefault = 12 * time.Hour
+	KernelNodeAcceptPeriodMinimumDefault = 12 * time.Hour
+	KernelNodeAcceptPeriodMaximumDefault = 7 * 24 * time.Hour
+)
+
+// The consensus parameters are only changed by the params of a private
+// network genesis, whose nodes may run in the same process as the devnet,
+// so they are never changed on the mainnet.
+var (
+	SnapshotRoundGap              = SnapshotRoundGapDefault
+	KernelMinimumNodesCount       = KernelMinimumNodesCountDefault
+	KernelNodePledgePeriodMinimum = KernelNodePledgePeriodMinimumDefault
+	KernelNodeAcceptPeriodMinimum = KernelNodeAcceptPeriodMinimumDefault
+	KernelNodeAcceptPeriodMaximum = KernelNodeAcceptPeriodMaximumDefault
+	TransactionMaximumSize        = TransactionMaximumSizeDefault
+	TransactionOutputsMaximum     = TransactionOutputsMaximumDefault
+	TransactionExtraSizeMaximum   = TransactionExtraSizeMaximumDefault
 )
 
 type Custom struct {
 	Node struct {
 		Signer               crypto.Key `toml:"-"`
 		SignerStr            string     `toml:"signer-key"`
+		SignerEncrypted      string     `toml:"signer-key-encrypted"`
+		SignerUnlock         string     `toml:"signer-key-unlock"`
+		SignerKeyFile        string     `toml:"signer-key-file"`
+		SignerKeystore       string     `toml:"signer-keystore"`
+		SignerKeystoreDir    string     `toml:"signer-keystore-dir"`
+		SignerRemote         string     `toml:"signer-remote"`
+		SignerRemoteCert     string     `toml:"signer-remote-cert"`
+		SignerRemoteKey      string     `toml:"signer-remote-key"`
+		SignerRemoteCA       string     `toml:"signer-remote-ca"`
+		SignerThreshold      int        `toml:"signer-threshold"`
+		SignerCosigners      []string   `toml:"signer-cosigners"`
 		KernelOprationPeriod int        `toml:"kernel-operation-period"`
 		MemoryCacheSize      int        `toml:"memory-cache-size"`
+		MemoryLimit          int        `toml:"memory-limit"`
 		CacheTTL             int        `toml:"cache-ttl"`
+		CacheTransactionTTL  int        `toml:"cache-transaction-ttl"`
+		ExtraSchemaCheck     bool       `toml:"extra-schema-check"`
+		InvariantCheck       bool       `toml:"invariant-check"`
 	} `toml:"node"`
 	Storage struct {
-		ValueLogGC          bool `toml:"value-log-gc"`
-		MaxCompactionLevels int  `toml:"max-compaction-levels"`
+		ValueLogGC          bool   `toml:"value-log-gc"`
+		MaxCompactionLevels int    `toml:"max-compaction-levels"`
+		RecoveryCheck       bool   `toml:"recovery-check"`
+		AuditRetention      uint64 `toml:"audit-retention"`
 	} `toml:"storage"`
 	P2P struct {
+		Listen  string   `toml:"listen"`
 		Port    int      `toml:"port"`
 		Seeds   []string `toml:"seeds"`
 		Relayer bool     `toml:"relayer"`
 		Metric  bool     `toml:"metric"`
 	} `toml:"p2p"`
 	RPC struct {
-		Port         int  `toml:"port"`
-		Runtime      bool `toml:"runtime"`
-		ObjectServer bool `toml:"object-server"`
+		Listen       string `toml:"listen"`
+		Port         int    `toml:"port"`
+		TLSCert      string `toml:"tls-cert"`
+		TLSKey       string `toml:"tls-key"`
+		Token        string `toml:"token"`
+		Runtime      bool   `toml:"runtime"`
+		ObjectServer bool   `toml:"object-server"`
+		Metrics      bool   `toml:"metrics"`
 	} `toml:"rpc"`
+	Admin struct {
+		Listen  string `toml:"listen"`
+		Port    int    `toml:"port"`
+		TLSCert string `toml:"tls-cert"`
+		TLSKey  string `toml:"tls-key"`
+		Token   string `toml:"token"`
+	} `toml:"admin"`
 	Dev struct {
-		Port int `toml:"port"`
+		Listen  string `toml:"listen"`
+		Port    int    `toml:"port"`
+		TLSCert string `toml:"tls-cert"`
+		TLSKey  string `toml:"tls-key"`
+		Token   string `toml:"token"`
 	} `toml:"dev"`
+	Wallet struct {
+		Scanner bool `toml:"scanner"`
+	} `toml:"wallet"`
+	Webhook struct {
+		Enabled bool `toml:"enabled"`
+	} `toml:"webhook"`
+	EventSink struct {
+		Kind    string `toml:"kind"`
+		Address string `toml:"address"`
+		Topic   string `toml:"topic"`
+	} `toml:"eventsink"`
+	Tracing struct {
+		Endpoint string  `toml:"endpoint"`
+		Ratio    float64 `toml:"ratio"`
+	} `toml:"tracing"`
+	Deposit struct {
+		ProofProvider        string `toml:"proof-provider"`
+		BitcoinConfirmations int    `toml:"bitcoin-confirmations"`
+		EthereumRPC          string `toml:"ethereum-rpc"`
+	} `toml:"deposit"`
+	LogShip struct {
+		Collector string `toml:"collector"`
+		Key       string `toml:"key"`
+		Period    int    `toml:"period"`
+	} `toml:"logship"`
+	Replica struct {
+		Primary  string `toml:"primary"`
+		Token    string `toml:"token"`
+		CA       string `toml:"tls-ca"`
+		Interval int    `toml:"interval"`
+	} `toml:"replica"`
+	Network struct {
+		Name    string `toml:"-"`
+		Genesis string `toml:"-"`
+		Data    string `toml:"-"`
+	} `toml:"-"`
 }
 
-func Initialize(file string) (*Custom, error) {
+// Initialize loads the config file with the MIXIN_ environment variables and
+// the key=value overrides, the deprecated and unknown keys are logged as
+// warnings, and any invalid value fails the loading.
+func Initialize(file string, overrides ...string) (*Custom, error) {
+	return InitializeNetwork(file, "", overrides...)
+}
+
+// InitializeNetwork loads the config file with the network profile, whose
+// values override the file, and are overridden by the environment variables
+// and the key=value overrides.
+func InitializeNetwork(file, network string, overrides ...string) (*Custom, error) {
 	f, err := os.ReadFile(file)
 	if err != nil {
 		return nil, err
 	}
-	var config Custom
-	err = toml.Unmarshal(f, &config)
+	config, warnings, err := load(f, filepath.Dir(file), network, os.LookupEnv, overrides)
 	if err != nil {
 		return nil, err
 	}
-	key, err := crypto.KeyFromString(config.Node.SignerStr)
+	for _, w := range warnings {
+		logger.Printf("config.Initialize(%s) %s\n", file, w)
+	}
+	return config, nil
+}
+
+func load(data []byte, dir, network string, lookup func(string) (string, bool), overrides []string) (*Custom, []string, error) {
+	tree, err := toml.LoadBytes(data)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	config.Node.Signer = key
-	if config.Node.KernelOprationPeriod == 0 {
-		config.Node.KernelOprationPeriod = 700
+	var config Custom
+	err = applyNetwork(tree, network, &config)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = applyOverrides(tree, lookup, overrides)
+	if err != nil {
+		return nil, nil, err
 	}
-	if config.Node.MemoryCacheSize == 0 {
-		config.Node.MemoryCacheSize = 1024 * 4
+	err = tree.Unmarshal(&config)
+	if err != nil {
+		return nil, nil, err
+	}
+	config.resolveNetwork(dir)
+	config.resolveListeners(dir)
+	config.applyDefaults()
+	err = config.readSignerFile(dir)
+	if err != nil {
+		return nil, nil, err
 	}
-	if config.Node.CacheTTL == 0 {
-		config.Node.CacheTTL = 3600 * 2
+	err = config.validate()
+	if err != nil {
+		return nil, nil, err
 	}
-	return &config, nil
+	return &config, checkKeys(tree), nil
 }
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"net/url"
+
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+const redacted = "REDACTED"
+
+// Redacted returns a copy of the config without the secrets, to be shared in
+// the support bundles, the passwords in the URLs are replaced by xxxxx.
+func (c *Custom) Redacted() *Custom {
+	r := *c
+	r.Node.Signer = crypto.Key{}
+	r.Node.SignerStr = redactSecret(r.Node.SignerStr)
+	r.Node.SignerEncrypted = redactSecret(r.Node.SignerEncrypted)
+	r.Node.SignerUnlock = redactSecret(r.Node.SignerUnlock)
+	r.RPC.Token = redactSecret(r.RPC.Token)
+	r.Admin.Token = redactSecret(r.Admin.Token)
+	r.Dev.Token = redactSecret(r.Dev.Token)
+	r.LogShip.Key = redactSecret(r.LogShip.Key)
+	r.LogShip.Collector = redactURL(r.LogShip.Collector)
+	r.EventSink.Address = redactURL(r.EventSink.Address)
+	r.Tracing.Endpoint = redactURL(r.Tracing.Endpoint)
+	r.Deposit.ProofProvider = redactURL(r.Deposit.ProofProvider)
+	r.Deposit.EthereumRPC = redactURL(r.Deposit.EthereumRPC)
+	r.Replica.Token = redactSecret(r.Replica.Token)
+	r.Replica.Primary = redactURL(r.Replica.Primary)
+	return &r
+}
+
+func redactSecret(s string) string {
+	if s == "" {
+		return ""
+	}
+	return redacted
+}
+
+func redactURL(s string) string {
+	uri, err := url.Parse(s)
+	if err != nil {
+		return redactSecret(s)
+	}
+	return uri.Redacted()
+}
@@ -0,0 +1,185 @@
+package config
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	SignerKeyEncryptionPrefix = "scrypt:"
+
+	signerKeyScryptN = 1 << 17
+	signerKeyScryptR = 8
+	signerKeyScryptP = 1
+)
+
+// EncryptSignerKey encrypts the signer key with the passphrase derived by
+// scrypt and sealed by chacha20poly1305, the result is the hex encoded salt,
+// nonce and ciphertext with the scrypt prefix.
+func EncryptSignerKey(key crypto.Key, passphrase []byte) (string, error) {
+	salt := make([]byte, 16)
+	crypto.ReadRand(salt)
+	aead, err := signerKeyCipher(passphrase, salt)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	crypto.ReadRand(nonce)
+	data := append(salt, nonce...)
+	data = aead.Seal(data, nonce, key[:], []byte(SignerKeyEncryptionPrefix))
+	return SignerKeyEncryptionPrefix + hex.EncodeToString(data), nil
+}
+
+func DecryptSignerKey(encrypted string, passphrase []byte) (crypto.Key, error) {
+	var key crypto.Key
+	if !strings.HasPrefix(encrypted, SignerKeyEncryptionPrefix) {
+		return key, fmt.Errorf("invalid encrypted signer key format")
+	}
+	data, err := hex.DecodeString(strings.TrimPrefix(encrypted, SignerKeyEncryptionPrefix))
+	if err != nil {
+		return key, err
+	}
+	if len(data) != 16+chacha20poly1305.NonceSize+len(key)+chacha20poly1305.Overhead {
+		return key, fmt.Errorf("invalid encrypted signer key size %d", len(data))
+	}
+	aead, err := signerKeyCipher(passphrase, data[:16])
+	if err != nil {
+		return key, err
+	}
+	nonce := data[16 : 16+aead.NonceSize()]
+	plain, err := aead.Open(nil, nonce, data[16+aead.NonceSize():], []byte(SignerKeyEncryptionPrefix))
+	if err != nil {
+		return key, errors.New("invalid signer key passphrase")
+	}
+	copy(key[:], plain)
+	return key, nil
+}
+
+// UnlockSigner decrypts the encrypted signer key with the secret from the
+// unlock method, env:NAME reads the environment variable, command:CMD uses
+// the output of the shell command, e.g. a cloud KMS decrypt command, and
+// the prompt is used if no unlock method configured.
+func (c *Custom) UnlockSigner(prompt func() ([]byte, error)) error {
+	if c.Node.SignerEncrypted == "" {
+		return nil
+	}
+	if c.Node.Signer.HasValue() {
+		return errors.New("both signer key and encrypted signer key configured")
+	}
+
+	var secret []byte
+	var err error
+	unlock := c.Node.SignerUnlock
+	switch {
+	case strings.HasPrefix(unlock, "env:"):
+		secret = []byte(os.Getenv(strings.TrimPrefix(unlock, "env:")))
+	case strings.HasPrefix(unlock, "command:"):
+		cmd := exec.Command("sh", "-c", strings.TrimPrefix(unlock, "command:"))
+		cmd.Stderr = os.Stderr
+		secret, err = cmd.Output()
+	case unlock == "":
+		secret, err = prompt()
+	default:
+		return fmt.Errorf("invalid signer key unlock method %s", unlock)
+	}
+	if err != nil {
+		return err
+	}
+	secret = bytes.TrimSpace(secret)
+	if len(secret) == 0 {
+		return errors.New("empty signer key passphrase")
+	}
+
+	key, err := DecryptSignerKey(c.Node.SignerEncrypted, secret)
+	if err != nil {
+		return err
+	}
+	c.Node.Signer = key
+	return nil
+}
+
+func signerKeyCipher(passphrase, salt []byte) (cipher.AEAD, error) {
+	secret, err := scrypt.Key(passphrase, salt, signerKeyScryptN, signerKeyScryptR, signerKeyScryptP, chacha20poly1305.KeySize)
+	if err != nil {
+		return nil, err
+	}
+	return chacha20poly1305.New(secret)
+}
+
+var signerKeystoreName = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+// readSignerFile reads the signer key from the key file or the keystore
+// entry, relative to the config directory, to keep the key out of the
+// config.toml. The file holds the hex key or the encrypted key, and must
+// not be accessible by the group or the others.
+func (c *Custom) readSignerFile(dir string) error {
+	var sources int
+	for _, s := range []string{c.Node.SignerStr, c.Node.SignerEncrypted, c.Node.SignerKeyFile, c.Node.SignerKeystore} {
+		if s != "" {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return invalidError("node.signer-key", "multiple signer key sources configured")
+	}
+
+	var key, path string
+	switch name := c.Node.SignerKeystore; {
+	case c.Node.SignerKeyFile != "":
+		key, path = "node.signer-key-file", c.Node.SignerKeyFile
+	case name != "":
+		if !signerKeystoreName.MatchString(name) || strings.Trim(name, ".") == "" {
+			return invalidError("node.signer-keystore", name)
+		}
+		key, path = "node.signer-keystore", filepath.Join(c.Node.SignerKeystoreDir, name)
+	default:
+		return nil
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	secret, err := readSecretFile(path)
+	if err != nil {
+		return invalidError(key, err.Error())
+	}
+	if strings.HasPrefix(secret, SignerKeyEncryptionPrefix) {
+		c.Node.SignerEncrypted = secret
+	} else {
+		c.Node.SignerStr = secret
+	}
+	return nil
+}
+
+func readSecretFile(path string) (string, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if !fi.Mode().IsRegular() {
+		return "", fmt.Errorf("%s not a regular file", path)
+	}
+	if perm := fi.Mode().Perm(); perm&0077 != 0 {
+		return "", fmt.Errorf("%s permissions %#o accessible by the group or the others", path, perm)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	secret := strings.TrimSpace(string(data))
+	if secret == "" {
+		return "", fmt.Errorf("%s empty", path)
+	}
+	return secret, nil
+}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignerKeyEncryption(t *testing.T) {
+	require := require.New(t)
+
+	seed := make([]byte, 64)
+	crypto.ReadRand(seed)
+	key := crypto.NewKeyFromSeed(seed)
+
+	encrypted, err := EncryptSignerKey(key, []byte("mixin passphrase"))
+	require.Nil(err)
+	require.Contains(encrypted, SignerKeyEncryptionPrefix)
+
+	_, err = DecryptSignerKey(encrypted, []byte("wrong passphrase"))
+	require.NotNil(err)
+	decrypted, err := DecryptSignerKey(encrypted, []byte("mixin passphrase"))
+	require.Nil(err)
+	require.Equal(key, decrypted)
+
+	var custom Custom
+	custom.Node.SignerEncrypted = encrypted
+	custom.Node.SignerUnlock = "env:MIXIN_TEST_SIGNER_PASSPHRASE"
+	t.Setenv("MIXIN_TEST_SIGNER_PASSPHRASE", "mixin passphrase\n")
+	err = custom.UnlockSigner(nil)
+	require.Nil(err)
+	require.Equal(key, custom.Node.Signer)
+
+	custom.Node.Signer = crypto.Key{}
+	custom.Node.SignerUnlock = "command:echo mixin passphrase"
+	err = custom.UnlockSigner(nil)
+	require.Nil(err)
+	require.Equal(key, custom.Node.Signer)
+
+	custom.Node.Signer = crypto.Key{}
+	custom.Node.SignerUnlock = ""
+	err = custom.UnlockSigner(func() ([]byte, error) { return []byte("wrong"), nil })
+	require.NotNil(err)
+	require.False(custom.Node.Signer.HasValue())
+}
+
+func TestSignerKeyFile(t *testing.T) {
+	require := require.New(t)
+
+	seed := make([]byte, 64)
+	crypto.ReadRand(seed)
+	key := crypto.NewKeyFromSeed(seed)
+	dir := t.TempDir()
+	file := filepath.Join(dir, "signer.key")
+	err := os.WriteFile(file, []byte(key.String()+"\n"), 0600)
+	require.Nil(err)
+
+	custom, _, err := load([]byte(`[node]
+signer-key-file = "signer.key"`), dir, "", nil, nil)
+	require.Nil(err)
+	require.Equal(key, custom.Node.Signer)
+	require.Equal("REDACTED", custom.Redacted().Node.SignerStr)
+
+	err = os.Chmod(file, 0640)
+	require.Nil(err)
+	_, _, err = load([]byte(`[node]
+signer-key-file = "signer.key"`), dir, "", nil, nil)
+	require.ErrorContains(err, "invalid config node.signer-key-file: "+file+" permissions 0640 accessible by the group or the others")
+
+	_, _, err = load([]byte(`[node]
+signer-key = "8bcfad3959892e8334fa287a3c9755fed017cd7a9e8c68d7540dc9e69fa4a00d"
+signer-key-file = "signer.key"`), dir, "", nil, nil)
+	require.ErrorContains(err, "invalid config node.signer-key: multiple signer key sources configured")
+
+	encrypted, err := EncryptSignerKey(key, []byte("mixin passphrase"))
+	require.Nil(err)
+	err = os.MkdirAll(filepath.Join(dir, "keystore"), 0700)
+	require.Nil(err)
+	err = os.WriteFile(filepath.Join(dir, "keystore", "mainnet"), []byte(encrypted), 0400)
+	require.Nil(err)
+	custom, _, err = load([]byte(`[node]
+signer-keystore = "mainnet"`), dir, "", nil, nil)
+	require.Nil(err)
+	require.False(custom.Node.Signer.HasValue())
+	require.Equal(encrypted, custom.Node.SignerEncrypted)
+	err = custom.UnlockSigner(func() ([]byte, error) { return []byte("mixin passphrase"), nil })
+	require.Nil(err)
+	require.Equal(key, custom.Node.Signer)
+
+	_, _, err = load([]byte(`[node]
+signer-keystore = "../signer.key"`), dir, "", nil, nil)
+	require.ErrorContains(err, "invalid config node.signer-keystore: ../signer.key")
+	_, _, err = load([]byte(`[node]
+signer-keystore = "testnet"`), dir, "", nil, nil)
+	require.ErrorContains(err, "invalid config node.signer-keystore: ")
+}
@@ -0,0 +1,357 @@
+package config
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/pelletier/go-toml"
+)
+
+const (
+	P2PPortDefault             = 5850
+	MaxCompactionLevelsDefault = 7
+	EventSinkTopicDefault      = "mixin.snapshots"
+
+	logShipKeySize = 32
+)
+
+// the keys of the old config files, they are ignored with a warning, and the
+// replacement is suggested if any
+var deprecatedKeys = map[string]string{
+	"node.consensus-only":      "p2p.relayer",
+	"node.ring-cache-size":     "",
+	"node.ring-final-size":     "",
+	"network.listener":         "p2p.port",
+	"network.peers":            "p2p.seeds",
+	"network.gossip-neighbors": "",
+	"network.metric":           "p2p.metric",
+}
+
+// configFields returns the types of all the keys, which are the toml tags of
+// the Custom struct in the section.key format.
+func configFields() map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	ct := reflect.TypeOf(Custom{})
+	for i := 0; i < ct.NumField(); i++ {
+		sf := ct.Field(i)
+		section := sf.Tag.Get("toml")
+		if section == "-" {
+			continue
+		}
+		for j := 0; j < sf.Type.NumField(); j++ {
+			f := sf.Type.Field(j)
+			if name := f.Tag.Get("toml"); name != "-" {
+				fields[section+"."+name] = f.Type
+			}
+		}
+	}
+	return fields
+}
+
+// checkKeys returns the warnings of the deprecated and unknown keys in the
+// tree.
+func checkKeys(tree *toml.Tree) []string {
+	fields := configFields()
+	var warnings []string
+	for _, key := range flattenKeys(tree, "") {
+		if name, ok := networkKey(key); ok {
+			if !networkKeys[name] && fields[name] == nil {
+				warnings = append(warnings, fmt.Sprintf("unknown key %s is ignored", key))
+			}
+			continue
+		}
+		replacement, deprecated := deprecatedKeys[key]
+		switch {
+		case deprecated && replacement != "":
+			warnings = append(warnings, fmt.Sprintf("deprecated key %s, use %s instead", key, replacement))
+		case deprecated:
+			warnings = append(warnings, fmt.Sprintf("deprecated key %s is ignored", key))
+		case fields[key] == nil:
+			warnings = append(warnings, fmt.Sprintf("unknown key %s is ignored", key))
+		}
+	}
+	return warnings
+}
+
+func flattenKeys(tree *toml.Tree, prefix string) []string {
+	var keys []string
+	for _, k := range tree.Keys() {
+		key := prefix + k
+		if sub, ok := tree.Get(k).(*toml.Tree); ok {
+			keys = append(keys, flattenKeys(sub, key+".")...)
+		} else {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (c *Custom) applyDefaults() {
+	if c.Node.SignerKeystoreDir == "" {
+		c.Node.SignerKeystoreDir = "keystore"
+	}
+	if c.Node.KernelOprationPeriod == 0 {
+		c.Node.KernelOprationPeriod = 700
+	}
+	if c.Node.MemoryCacheSize == 0 {
+		c.Node.MemoryCacheSize = 1024 * 4
+	}
+	if c.Node.CacheTTL == 0 {
+		c.Node.CacheTTL = 3600 * 2
+	}
+	if c.Node.CacheTransactionTTL == 0 {
+		c.Node.CacheTransactionTTL = c.Node.CacheTTL
+	}
+	if c.Storage.MaxCompactionLevels == 0 {
+		c.Storage.MaxCompactionLevels = MaxCompactionLevelsDefault
+	}
+	if c.Storage.AuditRetention == 0 {
+		c.Storage.AuditRetention = 1000000
+	}
+	if c.P2P.Port == 0 {
+		c.P2P.Port = P2PPortDefault
+	}
+	if c.EventSink.Topic == "" {
+		c.EventSink.Topic = EventSinkTopicDefault
+	}
+	if c.Deposit.BitcoinConfirmations == 0 {
+		c.Deposit.BitcoinConfirmations = 6
+	}
+	if c.LogShip.Period == 0 {
+		c.LogShip.Period = 60
+	}
+	if c.Tracing.Ratio == 0 {
+		c.Tracing.Ratio = 0.1
+	}
+	if c.Replica.Interval == 0 {
+		c.Replica.Interval = 1
+	}
+	c.applyListenerDefaults()
+}
+
+func (c *Custom) validate() error {
+	external := c.Node.SignerRemote != "" || len(c.Node.SignerCosigners) > 0
+	if c.Node.SignerStr != "" || (!external && c.Node.SignerEncrypted == "") {
+		key, err := crypto.KeyFromString(c.Node.SignerStr)
+		if err != nil {
+			return invalidError("node.signer-key", err.Error())
+		}
+		c.Node.Signer = key
+	}
+	if e := c.Node.SignerEncrypted; e != "" && !strings.HasPrefix(e, SignerKeyEncryptionPrefix) {
+		return invalidError("node.signer-key-encrypted", "missing prefix "+SignerKeyEncryptionPrefix)
+	}
+	if u := c.Node.SignerUnlock; u != "" && !strings.HasPrefix(u, "env:") && !strings.HasPrefix(u, "command:") {
+		return invalidError("node.signer-key-unlock", "not env:NAME or command:CMD")
+	}
+	if r := c.Node.SignerRemote; r != "" {
+		err := checkURL(r, "http", "https")
+		if err != nil {
+			return invalidError("node.signer-remote", err.Error())
+		}
+	}
+	if cosigners := c.Node.SignerCosigners; len(cosigners) > 0 {
+		if c.Node.SignerRemote != "" {
+			return invalidError("node.signer-cosigners", "conflicts with node.signer-remote")
+		}
+		if t := c.Node.SignerThreshold; t < 1 || t > len(cosigners) {
+			return invalidError("node.signer-threshold", fmt.Sprintf("%d not in [1, %d]", t, len(cosigners)))
+		}
+		err := checkCosigners(cosigners)
+		if err != nil {
+			return invalidError("node.signer-cosigners", err.Error())
+		}
+	}
+	if c.Node.KernelOprationPeriod < 0 {
+		return invalidError("node.kernel-operation-period", strconv.Itoa(c.Node.KernelOprationPeriod))
+	}
+	if c.Node.MemoryCacheSize < 0 {
+		return invalidError("node.memory-cache-size", strconv.Itoa(c.Node.MemoryCacheSize))
+	}
+	if l := c.Node.MemoryLimit; l < 0 {
+		return invalidError("node.memory-limit", strconv.Itoa(l))
+	} else if l > 0 && l <= c.Node.MemoryCacheSize {
+		return invalidError("node.memory-limit", fmt.Sprintf("%d not more than node.memory-cache-size %d", l, c.Node.MemoryCacheSize))
+	}
+	if c.Node.CacheTTL < 0 {
+		return invalidError("node.cache-ttl", strconv.Itoa(c.Node.CacheTTL))
+	}
+	if c.Node.CacheTransactionTTL < 0 {
+		return invalidError("node.cache-transaction-ttl", strconv.Itoa(c.Node.CacheTransactionTTL))
+	}
+
+	if l := c.Storage.MaxCompactionLevels; l < MaxCompactionLevelsDefault {
+		return invalidError("storage.max-compaction-levels", fmt.Sprintf("%d less than %d", l, MaxCompactionLevelsDefault))
+	}
+
+	err := c.validateListeners()
+	if err != nil {
+		return err
+	}
+	for _, s := range c.P2P.Seeds {
+		err := checkSeed(s)
+		if err != nil {
+			return invalidError("p2p.seeds", err.Error())
+		}
+	}
+
+	switch c.EventSink.Kind {
+	case "":
+	case "kafka":
+		err = checkURL(c.EventSink.Address, "http", "https")
+	case "nats":
+		err = checkURL(c.EventSink.Address, "nats")
+	default:
+		return invalidError("eventsink.kind", c.EventSink.Kind)
+	}
+	if err != nil {
+		return invalidError("eventsink.address", err.Error())
+	}
+
+	if e := c.Tracing.Endpoint; e != "" {
+		err := checkURL(e, "http", "https")
+		if err != nil {
+			return invalidError("tracing.endpoint", err.Error())
+		}
+	}
+	if r := c.Tracing.Ratio; r <= 0 || r > 1 {
+		return invalidError("tracing.ratio", fmt.Sprintf("%f not in (0, 1]", r))
+	}
+
+	if p := c.Deposit.ProofProvider; p != "" {
+		err := checkURL(p, "http", "https")
+		if err != nil {
+			return invalidError("deposit.proof-provider", err.Error())
+		}
+	}
+	if c.Deposit.BitcoinConfirmations < 1 {
+		return invalidError("deposit.bitcoin-confirmations", strconv.Itoa(c.Deposit.BitcoinConfirmations))
+	}
+	if r := c.Deposit.EthereumRPC; r != "" {
+		if c.Deposit.ProofProvider == "" {
+			return invalidError("deposit.ethereum-rpc", "requires deposit.proof-provider")
+		}
+		err := checkURL(r, "http", "https")
+		if err != nil {
+			return invalidError("deposit.ethereum-rpc", err.Error())
+		}
+	}
+
+	if col := c.LogShip.Collector; col != "" {
+		err := checkURL(col, "http", "https")
+		if err != nil {
+			return invalidError("logship.collector", err.Error())
+		}
+		key, err := hex.DecodeString(c.LogShip.Key)
+		if err != nil || len(key) != logShipKeySize {
+			return invalidError("logship.key", fmt.Sprintf("not %d bytes hex", logShipKeySize))
+		}
+	}
+	if c.LogShip.Period < 1 {
+		return invalidError("logship.period", strconv.Itoa(c.LogShip.Period))
+	}
+
+	if p := c.Replica.Primary; p != "" {
+		err := checkURL(p, "http", "https")
+		if err != nil {
+			return invalidError("replica.primary", err.Error())
+		}
+		// the deliveries and the offsets are replicated from the primary,
+		// which already sends them
+		if c.Webhook.Enabled {
+			return invalidError("webhook.enabled", "conflicts with replica.primary")
+		}
+		if c.EventSink.Kind != "" {
+			return invalidError("eventsink.kind", "conflicts with replica.primary")
+		}
+	}
+	if c.Replica.Interval < 1 {
+		return invalidError("replica.interval", strconv.Itoa(c.Replica.Interval))
+	}
+	return nil
+}
+
+func invalidError(key, reason string) error {
+	return fmt.Errorf("invalid config %s: %s", key, reason)
+}
+
+func checkPort(port int, optional bool) error {
+	if optional && port == 0 {
+		return nil
+	}
+	if port < 1 || port > 65535 {
+		return fmt.Errorf("port %d out of range", port)
+	}
+	return nil
+}
+
+func checkURL(s string, schemes ...string) error {
+	uri, err := url.Parse(s)
+	if err != nil {
+		return err
+	}
+	if uri.Host == "" {
+		return fmt.Errorf("missing host in %s", uri.Redacted())
+	}
+	for _, scheme := range schemes {
+		if uri.Scheme == scheme {
+			return nil
+		}
+	}
+	return fmt.Errorf("scheme %s not in %v", uri.Scheme, schemes)
+}
+
+func checkSeed(s string) error {
+	parts := strings.Split(s, "@")
+	if len(parts) != 2 {
+		return fmt.Errorf("%s not id@host:port", s)
+	}
+	_, err := crypto.HashFromString(parts[0])
+	if err != nil {
+		return fmt.Errorf("%s: %v", s, err)
+	}
+	_, port, err := net.SplitHostPort(parts[1])
+	if err != nil {
+		return fmt.Errorf("%s: %v", s, err)
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		return fmt.Errorf("%s: %v", s, err)
+	}
+	err = checkPort(p, false)
+	if err != nil {
+		return fmt.Errorf("%s: %v", s, err)
+	}
+	return nil
+}
+
+func checkCosigners(cosigners []string) error {
+	indexes := make(map[uint64]bool)
+	for _, cs := range cosigners {
+		parts := strings.SplitN(cs, "@", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("%s not index@endpoint", cs)
+		}
+		index, err := strconv.ParseUint(parts[0], 10, 16)
+		if err != nil || index == 0 {
+			return fmt.Errorf("%s index not in [1, 65535]", cs)
+		}
+		if indexes[index] {
+			return fmt.Errorf("%s index duplicated", cs)
+		}
+		indexes[index] = true
+		err = checkURL(parts[1], "http", "https")
+		if err != nil {
+			return fmt.Errorf("%s: %v", cs, err)
+		}
+	}
+	return nil
+}
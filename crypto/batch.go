@@ -43,7 +43,17 @@ func NewBatchVerifier() BatchVerifier {
 	}
 }
 
-// Add adds a (public key, message, sig) triple to the current batch.
+// Add adds a (public key, message, sig) triple to the current batch, the
+// messages of the entries could be different.
+func (v *BatchVerifier) Add(publicKey *Key, message Hash, sig *Signature) {
+	v.add(publicKey, message[:], sig[:])
+}
+
+// Len returns the number of entries in the current batch.
+func (v *BatchVerifier) Len() int {
+	return len(v.entries)
+}
+
 func (v *BatchVerifier) add(publicKey *Key, message, sig []byte) {
 	// Compute the challenge scalar for this entry upfront, so that we don't
 	// introduce a dependency on the lifetime of the message array. This doesn't
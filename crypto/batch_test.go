@@ -1,10 +1,147 @@
 package crypto
 
 import (
+	"crypto/sha512"
+	"encoding/hex"
 	"fmt"
 	"testing"
+
+	"filippo.io/edwards25519"
+	"github.com/stretchr/testify/require"
 )
 
+func TestBatchVerifier(t *testing.T) {
+	require := require.New(t)
+
+	verifier := NewBatchVerifier()
+	require.False(verifier.Verify())
+	var msgs []Hash
+	var pubs []*Key
+	var sigs []*Signature
+	for i := 0; i < 16; i++ {
+		priv := randomKey()
+		pub := priv.Public()
+		msg := Blake3Hash([]byte(fmt.Sprintf("TestBatchVerifier%d", i)))
+		sig := priv.Sign(msg)
+		verifier.Add(&pub, msg, &sig)
+		msgs = append(msgs, msg)
+		pubs = append(pubs, &pub)
+		sigs = append(sigs, &sig)
+	}
+	require.Equal(16, verifier.Len())
+	require.True(verifier.Verify())
+
+	verifier = NewBatchVerifier()
+	for i := range msgs {
+		verifier.Add(pubs[i], msgs[(i+1)%len(msgs)], sigs[i])
+	}
+	require.False(verifier.Verify())
+
+	var publics []*Key
+	var privates []Key
+	for i := 0; i < 4; i++ {
+		priv := randomKey()
+		pub := priv.Public()
+		privates = append(privates, priv)
+		publics = append(publics, &pub)
+	}
+	verifier = NewBatchVerifier()
+	for n := 0; n < 8; n++ {
+		msg := Blake3Hash([]byte(fmt.Sprintf("TestBatchVerifierCosi%d", n)))
+		randoms := make(map[int]*Key)
+		for i := 0; i < 3; i++ {
+			r := CosiCommit(RandReader())
+			randoms[i] = r
+		}
+		commitments := make(map[int]*Key)
+		for i, r := range randoms {
+			R := r.Public()
+			commitments[i] = &R
+		}
+		cosi, err := CosiAggregateCommitment(commitments)
+		require.Nil(err)
+		responses := make(map[int]*[32]byte)
+		for i, r := range randoms {
+			s, err := cosi.Response(&privates[i], r, publics, msg)
+			require.Nil(err)
+			responses[i] = s
+		}
+		err = cosi.AggregateResponse(publics, responses, msg, true)
+		require.Nil(err)
+		require.Nil(cosi.FullVerify(publics, 3, msg))
+		require.NotNil(cosi.BatchAdd(&verifier, publics, 4, msg))
+		require.Nil(cosi.BatchAdd(&verifier, publics, 3, msg))
+	}
+	require.Equal(8, verifier.Len())
+	require.True(verifier.Verify())
+}
+
+func TestBatchTorsion(t *testing.T) {
+	require := require.New(t)
+
+	require.NotNil(scalarOrderMinusOne)
+	var T Key
+	torsion, _ := hex.DecodeString("26e8958fc2b227b045c3f489f2ef98f0d5dfac05d3c63339b13802886d53fc05")
+	copy(T[:], torsion)
+	tp, err := edwards25519.NewIdentityPoint().SetBytes(T[:])
+	require.Nil(err)
+	require.Equal(0, tp.Equal(edwards25519.NewIdentityPoint()))
+	require.Equal(1, edwards25519.NewIdentityPoint().MultByCofactor(tp).Equal(edwards25519.NewIdentityPoint()))
+	require.False(checkTorsionFree(T[:]))
+
+	var publics []*Key
+	a := edwards25519.NewScalar()
+	for i := 0; i < 4; i++ {
+		priv := randomKey()
+		pub := priv.Public()
+		require.True(checkTorsionFree(pub[:]))
+		publics = append(publics, &pub)
+		x, err := edwards25519.NewScalar().SetCanonicalBytes(priv[:])
+		require.Nil(err)
+		a.Add(a, x)
+	}
+	A, err := aggregatePublicKey(publics, []int{0, 1, 2, 3})
+	require.Nil(err)
+
+	// the signature with a torsioned R is valid only by the cofactored equation
+	msg := Blake3Hash([]byte("TestBatchTorsion"))
+	r := randomKey()
+	rs, err := edwards25519.NewScalar().SetCanonicalBytes(r[:])
+	require.Nil(err)
+	R := edwards25519.NewIdentityPoint().ScalarBaseMult(rs)
+	R.Add(R, tp)
+	h := sha512.Sum512(append(append(R.Bytes(), A[:]...), msg[:]...))
+	k, err := edwards25519.NewScalar().SetUniformBytes(h[:])
+	require.Nil(err)
+	cosi := &CosiSignature{Mask: 15}
+	copy(cosi.Signature[:32], R.Bytes())
+	copy(cosi.Signature[32:], edwards25519.NewScalar().MultiplyAdd(k, a, rs).Bytes())
+
+	valid := randomKey()
+	vpub := valid.Public()
+	vsig := valid.Sign(msg)
+	verifier := NewBatchVerifier()
+	verifier.Add(A, msg, &cosi.Signature)
+	verifier.Add(&vpub, msg, &vsig)
+	require.True(verifier.Verify())
+
+	require.NotNil(cosi.FullVerify(publics, 4, msg))
+	verifier = NewBatchVerifier()
+	err = cosi.BatchAdd(&verifier, publics, 4, msg)
+	require.NotNil(err)
+	require.Contains(err.Error(), "not torsion free")
+	require.Equal(0, verifier.Len())
+
+	// the aggregated key with a torsion component is refused as well
+	tpub := edwards25519.NewIdentityPoint().Add(tp, edwards25519.NewIdentityPoint().ScalarBaseMult(a))
+	var tk Key
+	copy(tk[:], tpub.Bytes())
+	cosi.Mask = 1
+	err = cosi.BatchAdd(&verifier, []*Key{&tk}, 1, msg)
+	require.NotNil(err)
+	require.Contains(err.Error(), "aggregated key")
+}
+
 func BenchmarkVerifyBatch(b *testing.B) {
 	for _, n := range []int{1, 2, 4, 8, 64, 256} {
 		b.Run(fmt.Sprint(n), func(b *testing.B) {
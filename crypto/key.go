@@ -40,6 +40,13 @@ func (k Key) CheckKey() bool {
 	return err == nil
 }
 
+// CheckScalar is true for a canonical private key, which is required by
+// Public and Sign.
+func (k Key) CheckScalar() bool {
+	_, err := edwards25519.NewScalar().SetCanonicalBytes(k[:])
+	return err == nil
+}
+
 func (k Key) Public() Key {
 	x, err := edwards25519.NewScalar().SetCanonicalBytes(k[:])
 	if err != nil {
@@ -142,6 +149,73 @@ func ViewGhostOutputKey(P, a, R *Key, outputIndex uint64) *Key {
 	return &key
 }
 
+// the sequence keys share the view key a with the master spend key B, the
+// public spend key of sequence n is B + Hs(a, n)*G, so the view key holder
+// derives all the public keys, and only the owner of b can spend them.
+func DeriveSequencePublicKey(B, a *Key, sequence uint64) *Key {
+	return derivePublicKey(B, sequenceScalar(a, sequence))
+}
+
+func DeriveSequencePrivateKey(b, a *Key, sequence uint64) *Key {
+	return derivePrivateKey(b, sequenceScalar(a, sequence))
+}
+
+// the subaddress keys are derived the same way as the sequence keys, but
+// from an arbitrary payment id, e.g. the customer id of an exchange, so the
+// deposits of each customer go to a distinct address instead of a memo.
+func DeriveSubaddressPublicKey(B, a *Key, paymentId []byte) *Key {
+	return derivePublicKey(B, subaddressScalar(a, paymentId))
+}
+
+func DeriveSubaddressPrivateKey(b, a *Key, paymentId []byte) *Key {
+	return derivePrivateKey(b, subaddressScalar(a, paymentId))
+}
+
+func derivePublicKey(B *Key, s *edwards25519.Scalar) *Key {
+	p1, err := edwards25519.NewIdentityPoint().SetBytes(B[:])
+	if err != nil {
+		panic(B.String())
+	}
+	p2 := edwards25519.NewIdentityPoint().ScalarBaseMult(s)
+	p4 := edwards25519.NewIdentityPoint().Add(p1, p2)
+	var key Key
+	copy(key[:], p4.Bytes())
+	return &key
+}
+
+func derivePrivateKey(b *Key, s *edwards25519.Scalar) *Key {
+	y, err := edwards25519.NewScalar().SetCanonicalBytes(b[:])
+	if err != nil {
+		panic(b.String())
+	}
+	t := edwards25519.NewScalar().Add(s, y)
+	var key Key
+	copy(key[:], t.Bytes())
+	return &key
+}
+
+func sequenceScalar(a *Key, sequence uint64) *edwards25519.Scalar {
+	buf := append([]byte("SEQUENCE"), a[:]...)
+	buf = binary.BigEndian.AppendUint64(buf, sequence)
+	return derivationScalar(buf)
+}
+
+func subaddressScalar(a *Key, paymentId []byte) *edwards25519.Scalar {
+	buf := append([]byte("SUBADDRESS"), a[:]...)
+	buf = append(buf, paymentId...)
+	return derivationScalar(buf)
+}
+
+func derivationScalar(buf []byte) *edwards25519.Scalar {
+	h1 := Blake3Hash(buf)
+	h2 := Blake3Hash(h1[:])
+	s, err := edwards25519.NewScalar().SetUniformBytes(append(h1[:], h2[:]...))
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
 func (k Key) String() string {
 	return hex.EncodeToString(k[:])
 }
@@ -15,6 +15,12 @@ func TestKey(t *testing.T) {
 	key := NewKeyFromSeed(seed)
 	require.Equal("c91e0907d114fd83c1edc396490bb2dafa43c19815b0354e70dc80c317c3cb0a", key.String())
 	require.Equal("36bb0e309e7e9a82f1527df2c6b0e48181589097fe90c1282c558207ea27ce66", key.Public().String())
+	require.True(key.CheckScalar())
+	var invalid Key
+	for i := range invalid {
+		invalid[i] = 0xff
+	}
+	require.False(invalid.CheckScalar())
 
 	j, err := key.MarshalJSON()
 	require.Nil(err)
@@ -62,3 +68,41 @@ func randomKey() Key {
 	ReadRand(seed)
 	return NewKeyFromSeed(seed)
 }
+
+func TestSequenceKey(t *testing.T) {
+	require := require.New(t)
+	a := randomKey()
+	b := randomKey()
+	B := b.Public()
+
+	P0 := DeriveSequencePublicKey(&B, &a, 0)
+	p0 := DeriveSequencePrivateKey(&b, &a, 0)
+	require.Equal(*P0, p0.Public())
+	P1 := DeriveSequencePublicKey(&B, &a, 1)
+	p1 := DeriveSequencePrivateKey(&b, &a, 1)
+	require.Equal(*P1, p1.Public())
+	require.NotEqual(*P0, *P1)
+	require.NotEqual(B, *P0)
+
+	c := randomKey()
+	P0 = DeriveSequencePublicKey(&B, &c, 0)
+	require.NotEqual(p0.Public(), *P0)
+}
+
+func TestSubaddressKey(t *testing.T) {
+	require := require.New(t)
+	a := randomKey()
+	b := randomKey()
+	B := b.Public()
+
+	P0 := DeriveSubaddressPublicKey(&B, &a, []byte("customer-0"))
+	p0 := DeriveSubaddressPrivateKey(&b, &a, []byte("customer-0"))
+	require.Equal(*P0, p0.Public())
+	P1 := DeriveSubaddressPublicKey(&B, &a, []byte("customer-1"))
+	require.NotEqual(*P0, *P1)
+	require.NotEqual(B, *P0)
+
+	var seq [8]byte
+	S0 := DeriveSequencePublicKey(&B, &a, 0)
+	require.NotEqual(*S0, *DeriveSubaddressPublicKey(&B, &a, seq[:]))
+}
@@ -0,0 +1,77 @@
+package crypto
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/zeebo/blake3"
+)
+
+const LatticeHashSize = 2048
+
+// LatticeHash is the lattice based homomorphic hash of a multiset, each
+// element is expanded to 1024 uint16 words by the blake3 xof and summed
+// word by word, so the hash is independent of the order of the elements,
+// and an element is removed by the subtraction.
+type LatticeHash [LatticeHashSize / 2]uint16
+
+func NewLatticeHash(b []byte) (*LatticeHash, error) {
+	if len(b) != LatticeHashSize {
+		return nil, fmt.Errorf("invalid lattice hash size %d", len(b))
+	}
+	var lh LatticeHash
+	for i := range lh {
+		lh[i] = binary.LittleEndian.Uint16(b[i*2:])
+	}
+	return &lh, nil
+}
+
+func (lh *LatticeHash) Add(element []byte) {
+	lh.Merge(latticeHashElement(element))
+}
+
+func (lh *LatticeHash) Remove(element []byte) {
+	lh.Subtract(latticeHashElement(element))
+}
+
+func (lh *LatticeHash) Merge(other *LatticeHash) {
+	for i := range lh {
+		lh[i] += other[i]
+	}
+}
+
+func (lh *LatticeHash) Subtract(other *LatticeHash) {
+	for i := range lh {
+		lh[i] -= other[i]
+	}
+}
+
+func (lh *LatticeHash) Bytes() []byte {
+	b := make([]byte, 0, LatticeHashSize)
+	for _, w := range lh {
+		b = binary.LittleEndian.AppendUint16(b, w)
+	}
+	return b
+}
+
+func (lh *LatticeHash) Checksum() Hash {
+	return Blake3Hash(lh.Bytes())
+}
+
+func latticeHashElement(element []byte) *LatticeHash {
+	h := blake3.New()
+	_, err := h.Write(element)
+	if err != nil {
+		panic(err)
+	}
+	b := make([]byte, LatticeHashSize)
+	_, err = h.Digest().Read(b)
+	if err != nil {
+		panic(err)
+	}
+	lh, err := NewLatticeHash(b)
+	if err != nil {
+		panic(err)
+	}
+	return lh
+}
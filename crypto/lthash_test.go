@@ -0,0 +1,44 @@
+package crypto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatticeHash(t *testing.T) {
+	require := require.New(t)
+
+	var empty, a, b LatticeHash
+	a.Add([]byte("one"))
+	a.Add([]byte("two"))
+	a.Add([]byte("three"))
+	b.Add([]byte("three"))
+	b.Add([]byte("one"))
+	b.Add([]byte("two"))
+	require.Equal(a.Checksum(), b.Checksum())
+	require.NotEqual(empty.Checksum(), a.Checksum())
+
+	b.Remove([]byte("two"))
+	require.NotEqual(a.Checksum(), b.Checksum())
+	b.Add([]byte("two"))
+	require.Equal(a.Checksum(), b.Checksum())
+
+	var c LatticeHash
+	c.Add([]byte("four"))
+	a.Merge(&c)
+	b.Add([]byte("four"))
+	require.Equal(a.Checksum(), b.Checksum())
+	a.Subtract(&c)
+	b.Remove([]byte("four"))
+	require.Equal(a.Checksum(), b.Checksum())
+	a.Subtract(&b)
+	require.Equal(empty.Checksum(), a.Checksum())
+
+	lh, err := NewLatticeHash(b.Bytes())
+	require.Nil(err)
+	require.Equal(b.Checksum(), lh.Checksum())
+	require.Len(b.Bytes(), LatticeHashSize)
+	_, err = NewLatticeHash(b.Bytes()[1:])
+	require.ErrorContains(err, "invalid lattice hash size 2047")
+}
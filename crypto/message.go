@@ -0,0 +1,25 @@
+package crypto
+
+const messagePrefix = "MIXIN:SIGNED:MESSAGE:"
+
+// MessageHash is the hash of an arbitrary message signed by the address with
+// the public view key. The prefix separates it from the transaction and
+// snapshot hashes, and the view key separates the messages of the addresses
+// sharing the same spend key, e.g. the public address of a private one.
+func MessageHash(view Key, message []byte) Hash {
+	data := make([]byte, 0, len(messagePrefix)+len(view)+len(message))
+	data = append(data, messagePrefix...)
+	data = append(data, view[:]...)
+	data = append(data, message...)
+	return Blake3Hash(data)
+}
+
+// SignMessage signs the message with the private spend key of the address,
+// the view is the public view key of the same address.
+func SignMessage(spend Key, view Key, message []byte) Signature {
+	return spend.Sign(MessageHash(view, message))
+}
+
+func VerifyMessage(spend Key, view Key, message []byte, sig Signature) bool {
+	return spend.Verify(MessageHash(view, message), sig)
+}
@@ -0,0 +1,27 @@
+package crypto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessage(t *testing.T) {
+	require := require.New(t)
+
+	spend := randomKey()
+	view := randomKey().Public()
+	message := []byte("login:2b5b0e4f")
+	sig := SignMessage(spend, view, message)
+	require.True(VerifyMessage(spend.Public(), view, message, sig))
+	require.False(VerifyMessage(spend.Public(), view, []byte("login:2b5b0e4e"), sig))
+	require.False(VerifyMessage(spend.Public(), randomKey().Public(), message, sig))
+	require.False(VerifyMessage(randomKey().Public(), view, message, sig))
+
+	hash := MessageHash(view, message)
+	require.NotEqual(Blake3Hash(message), hash)
+	pub := spend.Public()
+	require.False(pub.Verify(Blake3Hash(message), sig))
+	require.True(pub.Verify(hash, sig))
+	require.Equal(MessageHash(view, nil), MessageHash(view, []byte{}))
+}
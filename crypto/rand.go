@@ -1,7 +1,6 @@
 package crypto
 
 import (
-	"crypto/rand"
 	"fmt"
 )
 
@@ -20,7 +19,7 @@ func ReadRand(buf []byte) {
 	if len(buf) == 0 {
 		panic(buf)
 	}
-	n, err := rand.Read(buf)
+	n, err := readRand(buf)
 	if err != nil || len(buf) != n {
 		panic(err)
 	}
@@ -0,0 +1,38 @@
+//go:build simulation
+
+package crypto
+
+import (
+	"crypto/rand"
+	mrand "math/rand/v2"
+	"sync"
+)
+
+var seeded struct {
+	sync.Mutex
+	rand *mrand.ChaCha8
+}
+
+// SeedRand replaces the system random with the deterministic one of the seed,
+// so the keys and nonces of a simulation are reproducible, or restores the
+// system random with a nil seed. The seeded random is never secure, so it is
+// only built with the simulation tag.
+func SeedRand(seed *[32]byte) {
+	seeded.Lock()
+	defer seeded.Unlock()
+	if seed == nil {
+		seeded.rand = nil
+	} else {
+		seeded.rand = mrand.NewChaCha8(*seed)
+	}
+}
+
+func readRand(buf []byte) (int, error) {
+	seeded.Lock()
+	if seeded.rand == nil {
+		seeded.Unlock()
+		return rand.Read(buf)
+	}
+	defer seeded.Unlock()
+	return seeded.rand.Read(buf)
+}
@@ -0,0 +1,9 @@
+//go:build !simulation
+
+package crypto
+
+import "crypto/rand"
+
+func readRand(buf []byte) (int, error) {
+	return rand.Read(buf)
+}
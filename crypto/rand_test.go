@@ -0,0 +1,34 @@
+//go:build simulation
+
+package crypto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeedRand(t *testing.T) {
+	require := require.New(t)
+	defer SeedRand(nil)
+
+	seed := [32]byte{1}
+	a, b := make([]byte, 64), make([]byte, 64)
+	SeedRand(&seed)
+	ReadRand(a)
+	SeedRand(&seed)
+	ReadRand(b)
+	require.Equal(a, b)
+	ReadRand(b)
+	require.NotEqual(a, b)
+
+	seed[0] = 2
+	SeedRand(&seed)
+	ReadRand(b)
+	require.NotEqual(a, b)
+
+	SeedRand(nil)
+	ReadRand(a)
+	ReadRand(b)
+	require.NotEqual(a, b)
+}
@@ -0,0 +1,82 @@
+package crypto
+
+import (
+	"fmt"
+
+	"filippo.io/edwards25519"
+)
+
+// GhostSigner signs the transaction inputs with the ghost private keys
+// derived from a spend key, the spend key may never leave the signer,
+// so only the public spend key is exposed.
+type GhostSigner interface {
+	PublicSpendKey() Key
+	SignGhost(mask, view *Key, outputIndex uint64, msg Hash) (*Key, *Signature, error)
+}
+
+// Signer signs the consensus messages and cosi responses with the node
+// signer key, which may be kept by a remote service or HSM.
+type Signer interface {
+	PublicKey() Key
+	Sign(message Hash) (Signature, error)
+	CosiCommit(count int) ([]*CosiNonce, error)
+	CosiRespond(nonce *CosiNonce, challenge [32]byte) (*[32]byte, error)
+}
+
+// CosiNonce is the cosi commitment of a signer, the secret random is only
+// available to the local key signer, a remote signer keeps it by itself.
+type CosiNonce struct {
+	Commitment Key
+	random     *Key
+}
+
+func NewRemoteCosiNonce(commitment Key) *CosiNonce {
+	return &CosiNonce{Commitment: commitment}
+}
+
+type KeySigner struct {
+	spend Key
+}
+
+func NewKeySigner(spend Key) *KeySigner {
+	return &KeySigner{spend: spend}
+}
+
+func (ks *KeySigner) PublicSpendKey() Key {
+	return ks.spend.Public()
+}
+
+func (ks *KeySigner) SignGhost(mask, view *Key, outputIndex uint64, msg Hash) (*Key, *Signature, error) {
+	priv := DeriveGhostPrivateKey(mask, view, &ks.spend, outputIndex)
+	pub := priv.Public()
+	sig := priv.Sign(msg)
+	return &pub, &sig, nil
+}
+
+func (ks *KeySigner) PublicKey() Key {
+	return ks.spend.Public()
+}
+
+func (ks *KeySigner) Sign(message Hash) (Signature, error) {
+	return ks.spend.Sign(message), nil
+}
+
+func (ks *KeySigner) CosiCommit(count int) ([]*CosiNonce, error) {
+	nonces := make([]*CosiNonce, count)
+	for i := range nonces {
+		r := CosiCommit(RandReader())
+		nonces[i] = &CosiNonce{Commitment: r.Public(), random: r}
+	}
+	return nonces, nil
+}
+
+func (ks *KeySigner) CosiRespond(nonce *CosiNonce, challenge [32]byte) (*[32]byte, error) {
+	if nonce.random == nil {
+		return nil, fmt.Errorf("cosi nonce %s not from the key signer", nonce.Commitment)
+	}
+	x, err := edwards25519.NewScalar().SetCanonicalBytes(challenge[:])
+	if err != nil {
+		return nil, err
+	}
+	return cosiResponse(x, &ks.spend, nonce.random), nil
+}
@@ -0,0 +1,386 @@
+package crypto
+
+import (
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"sync"
+
+	"filippo.io/edwards25519"
+)
+
+const (
+	ThresholdMaximumShares = 64
+	ThresholdNoncesCached  = 1024 * 64
+)
+
+type KeyShare struct {
+	Index  uint16 `json:"index"`
+	Secret Key    `json:"secret"`
+	Public Key    `json:"public"`
+}
+
+// SplitKeyShares splits the key into total Shamir shares, any threshold
+// of them are able to produce the signature of the key together.
+func SplitKeyShares(key Key, threshold, total int) ([]*KeyShare, error) {
+	if threshold < 1 || threshold > total || total > ThresholdMaximumShares {
+		return nil, fmt.Errorf("invalid key shares threshold %d/%d", threshold, total)
+	}
+	secret, err := edwards25519.NewScalar().SetCanonicalBytes(key[:])
+	if err != nil {
+		return nil, err
+	}
+	coefficients := []*edwards25519.Scalar{secret}
+	for i := 1; i < threshold; i++ {
+		seed := make([]byte, 64)
+		ReadRand(seed)
+		c := NewKeyFromSeed(seed)
+		s, err := edwards25519.NewScalar().SetCanonicalBytes(c[:])
+		if err != nil {
+			return nil, err
+		}
+		coefficients = append(coefficients, s)
+	}
+
+	shares := make([]*KeyShare, total)
+	for i := range shares {
+		x := thresholdIndexScalar(uint16(i + 1))
+		y := edwards25519.NewScalar()
+		for j := len(coefficients) - 1; j >= 0; j-- {
+			y.MultiplyAdd(y, x, coefficients[j])
+		}
+		share := &KeyShare{Index: uint16(i + 1)}
+		copy(share.Secret[:], y.Bytes())
+		share.Public = share.Secret.Public()
+		shares[i] = share
+	}
+	return shares, nil
+}
+
+// ThresholdPublicKey interpolates the public key from the share public keys,
+// all the extra shares beyond the threshold must be on the same polynomial.
+func ThresholdPublicKey(shares map[uint16]Key, threshold int) (Key, error) {
+	var public Key
+	indexes := make([]uint16, 0, len(shares))
+	for i := range shares {
+		indexes = append(indexes, i)
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+	if threshold < 1 || len(indexes) < threshold || indexes[0] == 0 {
+		return public, fmt.Errorf("invalid threshold shares %d/%d", threshold, len(indexes))
+	}
+
+	signers := indexes[:threshold]
+	for _, x := range append([]uint16{0}, indexes[threshold:]...) {
+		p := edwards25519.NewIdentityPoint()
+		for _, j := range signers {
+			y := shares[j]
+			Y, err := edwards25519.NewIdentityPoint().SetBytes(y[:])
+			if err != nil {
+				return public, err
+			}
+			l := lagrangeCoefficientAt(x, j, signers)
+			p.Add(p, edwards25519.NewIdentityPoint().ScalarMult(l, Y))
+		}
+		if x == 0 {
+			copy(public[:], p.Bytes())
+		} else if y := shares[x]; string(y[:]) != string(p.Bytes()) {
+			return public, fmt.Errorf("invalid threshold share %d %s", x, y)
+		}
+	}
+	return public, nil
+}
+
+func LagrangeCoefficient(index uint16, signers []uint16) *edwards25519.Scalar {
+	return lagrangeCoefficientAt(0, index, signers)
+}
+
+func lagrangeCoefficientAt(x, index uint16, signers []uint16) *edwards25519.Scalar {
+	num := thresholdIndexScalar(1)
+	den := thresholdIndexScalar(1)
+	xs := thresholdIndexScalar(x)
+	xi := thresholdIndexScalar(index)
+	for _, j := range signers {
+		if j == index {
+			continue
+		}
+		xj := thresholdIndexScalar(j)
+		num.Multiply(num, edwards25519.NewScalar().Subtract(xs, xj))
+		den.Multiply(den, edwards25519.NewScalar().Subtract(xi, xj))
+	}
+	return num.Multiply(num, edwards25519.NewScalar().Invert(den))
+}
+
+func thresholdIndexScalar(i uint16) *edwards25519.Scalar {
+	var b [32]byte
+	binary.LittleEndian.PutUint16(b[:], i)
+	s, err := edwards25519.NewScalar().SetCanonicalBytes(b[:])
+	if err != nil {
+		panic(i)
+	}
+	return s
+}
+
+type thresholdSession struct {
+	signers  []uint16
+	hiding   map[uint16]*CosiNonce
+	binding  map[uint16]*CosiNonce
+	bindings map[uint16]*edwards25519.Scalar
+}
+
+// ThresholdSigner orchestrates the co-signers holding the key shares with the
+// FROST binding factors. Each co-signer commits a hiding and a binding nonce,
+// and the session commitment is the sum of the hiding commitments and the
+// binding commitments weighted by the binding factors. The response is the sum
+// of the partial responses weighted by the Lagrange coefficients, so the key is
+// never assembled.
+//
+// The orchestrator is trusted. It computes the binding factors and the partial
+// challenges itself, and the co-signers respond to whatever challenges it
+// sends without recomputing them from the commitments and the message, which
+// they never see. So the binding factors don't protect the co-signers against
+// a malicious orchestrator choosing the challenges, e.g. with the ROS attack,
+// and it must run on the node host with the co-signers only reachable by it.
+// A co-signer nonce is still never asked to respond twice.
+type ThresholdSigner struct {
+	public    Key
+	threshold int
+	cosigners map[uint16]Signer
+	publics   map[uint16]Key
+
+	mutex    sync.Mutex
+	sessions map[Key]*thresholdSession
+	queue    []Key
+}
+
+func NewThresholdSigner(threshold int, cosigners map[uint16]Signer) (*ThresholdSigner, error) {
+	publics := make(map[uint16]Key)
+	signers := make(map[uint16]Signer)
+	for i, s := range cosigners {
+		publics[i] = s.PublicKey()
+		signers[i] = s
+	}
+	public, err := ThresholdPublicKey(publics, threshold)
+	if err != nil {
+		return nil, err
+	}
+	return &ThresholdSigner{
+		public:    public,
+		threshold: threshold,
+		cosigners: signers,
+		publics:   publics,
+		sessions:  make(map[Key]*thresholdSession),
+	}, nil
+}
+
+func (ts *ThresholdSigner) PublicKey() Key {
+	return ts.public
+}
+
+func (ts *ThresholdSigner) Sign(message Hash) (Signature, error) {
+	var sig Signature
+	nonces, err := ts.commit(1, message[:])
+	if err != nil {
+		return sig, err
+	}
+	var hramDigest [64]byte
+	h := sha512.New()
+	h.Write(nonces[0].Commitment[:])
+	h.Write(ts.public[:])
+	h.Write(message[:])
+	h.Sum(hramDigest[:0])
+	x, err := edwards25519.NewScalar().SetUniformBytes(hramDigest[:])
+	if err != nil {
+		return sig, err
+	}
+	var challenge [32]byte
+	copy(challenge[:], x.Bytes())
+	s, err := ts.CosiRespond(nonces[0], challenge)
+	if err != nil {
+		return sig, err
+	}
+	copy(sig[:32], nonces[0].Commitment[:])
+	copy(sig[32:], s[:])
+	return sig, nil
+}
+
+func (ts *ThresholdSigner) CosiCommit(count int) ([]*CosiNonce, error) {
+	return ts.commit(count, nil)
+}
+
+func (ts *ThresholdSigner) commit(count int, message []byte) ([]*CosiNonce, error) {
+	indexes := make([]uint16, 0, len(ts.cosigners))
+	for i := range ts.cosigners {
+		indexes = append(indexes, i)
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+
+	var signers []uint16
+	hiding := make(map[uint16][]*CosiNonce)
+	binding := make(map[uint16][]*CosiNonce)
+	for _, i := range indexes {
+		hn, err := ts.cosigners[i].CosiCommit(count)
+		if err != nil || len(hn) != count {
+			continue
+		}
+		bn, err := ts.cosigners[i].CosiCommit(count)
+		if err != nil || len(bn) != count {
+			continue
+		}
+		signers = append(signers, i)
+		hiding[i], binding[i] = hn, bn
+		if len(signers) == ts.threshold {
+			break
+		}
+	}
+	if len(signers) < ts.threshold {
+		return nil, fmt.Errorf("not enough co-signers %d/%d", len(signers), ts.threshold)
+	}
+
+	nonces := make([]*CosiNonce, count)
+	sessions := make([]*thresholdSession, count)
+	for k := range nonces {
+		session := &thresholdSession{
+			signers: signers,
+			hiding:  make(map[uint16]*CosiNonce),
+			binding: make(map[uint16]*CosiNonce),
+		}
+		for _, i := range signers {
+			session.hiding[i] = hiding[i][k]
+			session.binding[i] = binding[i][k]
+		}
+		session.bindings = thresholdBindingFactors(ts.public, message, signers, session.hiding, session.binding)
+		R, err := thresholdGroupCommitment(session)
+		if err != nil {
+			return nil, err
+		}
+		nonces[k] = NewRemoteCosiNonce(R)
+		sessions[k] = session
+	}
+
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+	for k, n := range nonces {
+		ts.sessions[n.Commitment] = sessions[k]
+		ts.queue = append(ts.queue, n.Commitment)
+	}
+	for len(ts.queue) > ThresholdNoncesCached {
+		delete(ts.sessions, ts.queue[0])
+		ts.queue = ts.queue[1:]
+	}
+	return nonces, nil
+}
+
+// the binding factors follow FROST, they are derived from the public key, the
+// message and the encoded list of the co-signers indexes with their hiding and
+// binding commitments, so any change of them changes all the binding factors.
+// they are only derived by the orchestrator, the co-signers never check them.
+func thresholdBindingFactors(public Key, message []byte, signers []uint16, hiding, binding map[uint16]*CosiNonce) map[uint16]*edwards25519.Scalar {
+	var encoded []byte
+	for _, i := range signers {
+		encoded = binary.LittleEndian.AppendUint16(encoded, i)
+		encoded = append(encoded, hiding[i].Commitment[:]...)
+		encoded = append(encoded, binding[i].Commitment[:]...)
+	}
+	mh := Blake3Hash(message)
+	eh := Blake3Hash(encoded)
+
+	factors := make(map[uint16]*edwards25519.Scalar)
+	for _, i := range signers {
+		var digest [64]byte
+		h := sha512.New()
+		h.Write([]byte("MIXIN:THRESHOLD:BINDING"))
+		h.Write(public[:])
+		h.Write(mh[:])
+		h.Write(eh[:])
+		h.Write(binary.LittleEndian.AppendUint16(nil, i))
+		h.Sum(digest[:0])
+		rho, err := edwards25519.NewScalar().SetUniformBytes(digest[:])
+		if err != nil {
+			panic(err)
+		}
+		factors[i] = rho
+	}
+	return factors
+}
+
+func thresholdGroupCommitment(session *thresholdSession) (Key, error) {
+	var commitment Key
+	R := edwards25519.NewIdentityPoint()
+	for _, i := range session.signers {
+		D, err := edwards25519.NewIdentityPoint().SetBytes(session.hiding[i].Commitment[:])
+		if err != nil {
+			return commitment, err
+		}
+		E, err := edwards25519.NewIdentityPoint().SetBytes(session.binding[i].Commitment[:])
+		if err != nil {
+			return commitment, err
+		}
+		R.Add(R, D)
+		R.Add(R, edwards25519.NewIdentityPoint().ScalarMult(session.bindings[i], E))
+	}
+	copy(commitment[:], R.Bytes())
+	return commitment, nil
+}
+
+// CosiRespond asks each co-signer to respond with both its nonces, for the
+// challenge c weighted by the Lagrange coefficient, the hiding nonce d responds
+// to c·(1-ρ) and the binding nonce e responds to c, so the co-signer response
+// is the FROST one d + ρ·e + c·s with the key share s, and neither nonce is
+// revealed alone.
+func (ts *ThresholdSigner) CosiRespond(nonce *CosiNonce, challenge [32]byte) (*[32]byte, error) {
+	ts.mutex.Lock()
+	session := ts.sessions[nonce.Commitment]
+	delete(ts.sessions, nonce.Commitment)
+	ts.mutex.Unlock()
+	if session == nil {
+		return nil, fmt.Errorf("threshold session %s not found", nonce.Commitment)
+	}
+
+	x, err := edwards25519.NewScalar().SetCanonicalBytes(challenge[:])
+	if err != nil {
+		return nil, err
+	}
+	one := thresholdIndexScalar(1)
+	s := edwards25519.NewScalar()
+	for _, i := range session.signers {
+		rho := session.bindings[i]
+		c := edwards25519.NewScalar().Multiply(x, LagrangeCoefficient(i, session.signers))
+		hc := edwards25519.NewScalar().Multiply(c, edwards25519.NewScalar().Subtract(one, rho))
+		zd, err := ts.respond(i, session.hiding[i], hc)
+		if err != nil {
+			return nil, err
+		}
+		ze, err := ts.respond(i, session.binding[i], c)
+		if err != nil {
+			return nil, err
+		}
+		s.Add(s, zd)
+		s.Add(s, edwards25519.NewScalar().Multiply(rho, ze))
+	}
+	var response [32]byte
+	copy(response[:], s.Bytes())
+	return &response, nil
+}
+
+func (ts *ThresholdSigner) respond(i uint16, n *CosiNonce, c *edwards25519.Scalar) (*edwards25519.Scalar, error) {
+	var partial [32]byte
+	copy(partial[:], c.Bytes())
+	r, err := ts.cosigners[i].CosiRespond(n, partial)
+	if err != nil {
+		return nil, fmt.Errorf("co-signer %d response %v", i, err)
+	}
+	z, err := edwards25519.NewScalar().SetCanonicalBytes(r[:])
+	if err != nil {
+		return nil, err
+	}
+	var sig Signature
+	copy(sig[:32], n.Commitment[:])
+	copy(sig[32:], r[:])
+	pub := ts.publics[i]
+	if !pub.VerifyWithChallenge(sig, c) {
+		return nil, fmt.Errorf("invalid co-signer %d response", i)
+	}
+	return z, nil
+}
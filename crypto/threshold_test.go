@@ -0,0 +1,101 @@
+package crypto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestThresholdSigner(t *testing.T) {
+	require := require.New(t)
+
+	key := randomKey()
+	_, err := SplitKeyShares(key, 4, 3)
+	require.NotNil(err)
+	shares, err := SplitKeyShares(key, 3, 5)
+	require.Nil(err)
+	require.Len(shares, 5)
+
+	publics := make(map[uint16]Key)
+	cosigners := make(map[uint16]Signer)
+	for _, s := range shares {
+		require.Equal(s.Secret.Public(), s.Public)
+		publics[s.Index] = s.Public
+		cosigners[s.Index] = NewKeySigner(s.Secret)
+	}
+	public, err := ThresholdPublicKey(publics, 3)
+	require.Nil(err)
+	require.Equal(key.Public(), public)
+	_, err = ThresholdPublicKey(publics, 6)
+	require.NotNil(err)
+	publics[4] = randomKey().Public()
+	_, err = ThresholdPublicKey(publics, 3)
+	require.NotNil(err)
+
+	delete(cosigners, 1)
+	delete(cosigners, 3)
+	signer, err := NewThresholdSigner(3, cosigners)
+	require.Nil(err)
+	require.Equal(key.Public(), signer.PublicKey())
+
+	msg := Blake3Hash([]byte("threshold"))
+	sig, err := signer.Sign(msg)
+	require.Nil(err)
+	require.True(public.Verify(msg, sig))
+
+	nonces, err := signer.CosiCommit(2)
+	require.Nil(err)
+	require.Len(nonces, 2)
+	other := randomKey()
+	ks := NewKeySigner(other)
+	otherNonces, err := ks.CosiCommit(1)
+	require.Nil(err)
+
+	pk := other.Public()
+	publicKeys := []*Key{&public, &pk}
+	randoms := map[int]*Key{0: &nonces[0].Commitment, 1: &otherNonces[0].Commitment}
+	cosi, err := CosiAggregateCommitment(randoms)
+	require.Nil(err)
+	r0, err := cosi.SignerResponse(signer, nonces[0], publicKeys, msg)
+	require.Nil(err)
+	r1, err := cosi.SignerResponse(ks, otherNonces[0], publicKeys, msg)
+	require.Nil(err)
+	require.Nil(cosi.VerifyResponse(publicKeys, 0, r0, msg))
+	require.Nil(cosi.VerifyResponse(publicKeys, 1, r1, msg))
+	err = cosi.AggregateResponse(publicKeys, map[int]*[32]byte{0: r0, 1: r1}, msg, true)
+	require.Nil(err)
+	require.Nil(cosi.FullVerify(publicKeys, 2, msg))
+
+	_, err = cosi.SignerResponse(signer, nonces[0], publicKeys, msg)
+	require.NotNil(err)
+
+	delete(cosigners, 5)
+	_, err = NewThresholdSigner(3, cosigners)
+	require.NotNil(err)
+}
+
+func TestThresholdBindingFactors(t *testing.T) {
+	require := require.New(t)
+
+	public := randomKey().Public()
+	signers := []uint16{1, 2, 3}
+	hiding := make(map[uint16]*CosiNonce)
+	binding := make(map[uint16]*CosiNonce)
+	for _, i := range signers {
+		hiding[i] = NewRemoteCosiNonce(randomKey().Public())
+		binding[i] = NewRemoteCosiNonce(randomKey().Public())
+	}
+	factors := thresholdBindingFactors(public, nil, signers, hiding, binding)
+	require.Len(factors, 3)
+	require.NotEqual(factors[1].Bytes(), factors[2].Bytes())
+	require.Equal(factors, thresholdBindingFactors(public, nil, signers, hiding, binding))
+
+	msg := Blake3Hash([]byte("threshold"))
+	bound := thresholdBindingFactors(public, msg[:], signers, hiding, binding)
+	binding[3] = NewRemoteCosiNonce(randomKey().Public())
+	changed := thresholdBindingFactors(public, nil, signers, hiding, binding)
+	for _, i := range signers {
+		require.NotEqual(factors[i].Bytes(), bound[i].Bytes())
+		require.NotEqual(factors[i].Bytes(), changed[i].Bytes())
+	}
+}
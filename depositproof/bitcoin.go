@@ -0,0 +1,268 @@
+package depositproof
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"slices"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/shopspring/decimal"
+)
+
+// the bitcoin proof is the raw transaction, the merkle branch of it in the
+// block, and the headers from the block to the tip. the headers must have the
+// proof of work not easier than the target floor, so a fabricated chain of
+// headers costs a significant part of the network hash rate.
+const (
+	BitcoinMaxTargetBits = 0x17100000
+
+	bitcoinHeaderSize = 80
+	bitcoinDecimals   = 8
+)
+
+type BitcoinProof struct {
+	Transaction string   `json:"transaction"`
+	Branch      []string `json:"branch"`
+	Position    uint32   `json:"position"`
+	Headers     []string `json:"headers"`
+}
+
+type BitcoinVerifier struct {
+	confirmations int
+	maxTarget     *big.Int
+}
+
+func NewBitcoinVerifier(confirmations int, maxTargetBits uint32) (*BitcoinVerifier, error) {
+	if confirmations < 1 {
+		return nil, fmt.Errorf("invalid bitcoin confirmations %d", confirmations)
+	}
+	target, err := bitcoinTarget(maxTargetBits)
+	if err != nil {
+		return nil, err
+	}
+	return &BitcoinVerifier{confirmations: confirmations, maxTarget: target}, nil
+}
+
+func (v *BitcoinVerifier) Verify(d *common.DepositData, data []byte) error {
+	var proof BitcoinProof
+	err := json.Unmarshal(data, &proof)
+	if err != nil {
+		return err
+	}
+	raw, err := hex.DecodeString(proof.Transaction)
+	if err != nil {
+		return err
+	}
+	txid, values, err := parseBitcoinTransaction(raw)
+	if err != nil {
+		return err
+	}
+	if hex.EncodeToString(reversed(txid)) != d.Transaction {
+		return fmt.Errorf("bitcoin transaction hash %x", reversed(txid))
+	}
+	if d.Index >= uint64(len(values)) {
+		return fmt.Errorf("bitcoin output index %d out of %d", d.Index, len(values))
+	}
+	amount := decimal.NewFromBigInt(new(big.Int).SetUint64(values[d.Index]), -bitcoinDecimals)
+	if common.NewIntegerFromString(amount.String()).Cmp(d.Amount) != 0 {
+		return fmt.Errorf("bitcoin output amount %s %s", amount, d.Amount)
+	}
+
+	if len(proof.Headers) < v.confirmations {
+		return fmt.Errorf("bitcoin confirmations %d less than %d", len(proof.Headers), v.confirmations)
+	}
+	var prev []byte
+	for i, h := range proof.Headers {
+		header, err := hex.DecodeString(h)
+		if err != nil {
+			return err
+		}
+		hash, err := v.verifyHeader(header)
+		if err != nil {
+			return err
+		}
+		if i == 0 {
+			root := bitcoinMerkleRoot(txid, proof.Branch, proof.Position)
+			if root == nil || !bytes.Equal(root, header[36:68]) {
+				return fmt.Errorf("bitcoin merkle root %x %x", root, header[36:68])
+			}
+		} else if !bytes.Equal(header[4:36], prev) {
+			return fmt.Errorf("bitcoin header %d not linked", i)
+		}
+		prev = hash
+	}
+	return nil
+}
+
+func (v *BitcoinVerifier) verifyHeader(header []byte) ([]byte, error) {
+	if len(header) != bitcoinHeaderSize {
+		return nil, fmt.Errorf("bitcoin header size %d", len(header))
+	}
+	target, err := bitcoinTarget(binary.LittleEndian.Uint32(header[72:76]))
+	if err != nil {
+		return nil, err
+	}
+	if target.Cmp(v.maxTarget) > 0 {
+		return nil, fmt.Errorf("bitcoin header target %x above %x", target, v.maxTarget)
+	}
+	hash := doubleSha256(header)
+	if new(big.Int).SetBytes(reversed(hash)).Cmp(target) > 0 {
+		return nil, fmt.Errorf("bitcoin header hash %x above target %x", reversed(hash), target)
+	}
+	return hash, nil
+}
+
+func bitcoinTarget(bits uint32) (*big.Int, error) {
+	exponent, mantissa := uint(bits>>24), int64(bits&0x007fffff)
+	if bits&0x00800000 != 0 || mantissa == 0 {
+		return nil, fmt.Errorf("invalid bitcoin target bits %x", bits)
+	}
+	if exponent <= 3 {
+		return big.NewInt(mantissa >> (8 * (3 - exponent))), nil
+	}
+	return new(big.Int).Lsh(big.NewInt(mantissa), 8*(exponent-3)), nil
+}
+
+// bitcoinMerkleRoot returns nil if the position is not in the branch.
+func bitcoinMerkleRoot(txid []byte, branch []string, position uint32) []byte {
+	if len(branch) < 32 && position>>len(branch) != 0 {
+		return nil
+	}
+	root := txid
+	for _, h := range branch {
+		sibling, err := hex.DecodeString(h)
+		if err != nil || len(sibling) != 32 {
+			return nil
+		}
+		if position&1 == 1 {
+			root = doubleSha256(append(slices.Clone(sibling), root...))
+		} else {
+			root = doubleSha256(append(slices.Clone(root), sibling...))
+		}
+		position = position >> 1
+	}
+	return root
+}
+
+// parseBitcoinTransaction returns the txid in the internal byte order and the
+// values of the outputs. a transaction of 64 bytes without the witness is
+// rejected, because it could be an inner node of the merkle tree.
+func parseBitcoinTransaction(raw []byte) ([]byte, []uint64, error) {
+	r := &bitcoinReader{data: raw}
+	r.read(4)
+	witness := len(raw) > 6 && raw[4] == 0 && raw[5] == 1
+	if witness {
+		r.read(2)
+	}
+	start := r.offset
+	inputs := r.count()
+	if inputs == 0 {
+		return nil, nil, fmt.Errorf("bitcoin transaction without inputs")
+	}
+	for range inputs {
+		r.read(36)
+		r.read(r.varint())
+		r.read(4)
+	}
+	var values []uint64
+	outputs := r.count()
+	for range outputs {
+		value := r.read(8)
+		r.read(r.varint())
+		if r.err == nil {
+			values = append(values, binary.LittleEndian.Uint64(value))
+		}
+	}
+	end := r.offset
+	if witness {
+		for range inputs {
+			items := r.count()
+			for range items {
+				r.read(r.varint())
+			}
+		}
+	}
+	r.read(4)
+	if r.err != nil {
+		return nil, nil, r.err
+	}
+	if r.offset != len(raw) {
+		return nil, nil, fmt.Errorf("bitcoin transaction trailing bytes %d", len(raw)-r.offset)
+	}
+
+	stripped := slices.Concat(raw[:4], raw[start:end], raw[len(raw)-4:])
+	if len(stripped) == 64 {
+		return nil, nil, fmt.Errorf("bitcoin transaction size 64")
+	}
+	return doubleSha256(stripped), values, nil
+}
+
+type bitcoinReader struct {
+	data   []byte
+	offset int
+	err    error
+}
+
+func (r *bitcoinReader) read(n uint64) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if uint64(len(r.data)-r.offset) < n {
+		r.err = fmt.Errorf("bitcoin transaction truncated at %d", r.offset)
+		return nil
+	}
+	b := r.data[r.offset : r.offset+int(n)]
+	r.offset += int(n)
+	return b
+}
+
+// count reads the number of the items, each item has at least one byte.
+func (r *bitcoinReader) count() uint64 {
+	n := r.varint()
+	if r.err == nil && n > uint64(len(r.data)-r.offset) {
+		r.err = fmt.Errorf("bitcoin transaction count %d at %d", n, r.offset)
+		return 0
+	}
+	return n
+}
+
+func (r *bitcoinReader) varint() uint64 {
+	b := r.read(1)
+	if b == nil {
+		return 0
+	}
+	switch b[0] {
+	case 0xfd:
+		if v := r.read(2); v != nil {
+			return uint64(binary.LittleEndian.Uint16(v))
+		}
+	case 0xfe:
+		if v := r.read(4); v != nil {
+			return uint64(binary.LittleEndian.Uint32(v))
+		}
+	case 0xff:
+		if v := r.read(8); v != nil {
+			return binary.LittleEndian.Uint64(v)
+		}
+	default:
+		return uint64(b[0])
+	}
+	return 0
+}
+
+func doubleSha256(b []byte) []byte {
+	first := sha256.Sum256(b)
+	second := sha256.Sum256(first[:])
+	return second[:]
+}
+
+func reversed(b []byte) []byte {
+	r := slices.Clone(b)
+	slices.Reverse(r)
+	return r
+}
@@ -0,0 +1,143 @@
+package depositproof
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"slices"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+const bitcoinTestTargetBits = 0x207fffff
+
+func TestBitcoinVerifier(t *testing.T) {
+	require := require.New(t)
+
+	_, err := NewBitcoinVerifier(0, BitcoinMaxTargetBits)
+	require.NotNil(err)
+	_, err = NewBitcoinVerifier(2, 0x04923456)
+	require.NotNil(err)
+
+	raw := bitcoinTestTransaction(false)
+	txid, values, err := parseBitcoinTransaction(raw)
+	require.Nil(err)
+	require.Equal([]uint64{150000000, 2100000000000000}, values)
+	witness := bitcoinTestTransaction(true)
+	wtxid, values, err := parseBitcoinTransaction(witness)
+	require.Nil(err)
+	require.Equal(txid, wtxid)
+	require.Len(values, 2)
+	_, _, err = parseBitcoinTransaction(raw[:len(raw)-1])
+	require.NotNil(err)
+	_, _, err = parseBitcoinTransaction(append(slices.Clone(raw), 0))
+	require.NotNil(err)
+
+	sibling := crypto.Blake3Hash([]byte("sibling"))
+	root := doubleSha256(append(sibling[:], txid...))
+	block := bitcoinTestHeader(make([]byte, 32), root)
+	tip := bitcoinTestHeader(doubleSha256(block), make([]byte, 32))
+	proof := &BitcoinProof{
+		Transaction: hex.EncodeToString(witness),
+		Branch:      []string{hex.EncodeToString(sibling[:])},
+		Position:    1,
+		Headers:     []string{hex.EncodeToString(block), hex.EncodeToString(tip)},
+	}
+	d := &common.DepositData{
+		Chain:       common.BitcoinAssetId,
+		AssetKey:    "c6d0c728-2624-429b-8e0d-d9d19b6592fa",
+		Transaction: hex.EncodeToString(reversed(txid)),
+		Index:       0,
+		Amount:      common.NewIntegerFromString("1.5"),
+	}
+
+	v, err := NewBitcoinVerifier(2, bitcoinTestTargetBits)
+	require.Nil(err)
+	require.Nil(v.Verify(d, bitcoinTestProof(proof)))
+
+	d.Amount = common.NewIntegerFromString("1.50000001")
+	require.ErrorContains(v.Verify(d, bitcoinTestProof(proof)), "bitcoin output amount")
+	d.Amount, d.Index = common.NewIntegerFromString("1.5"), 2
+	require.ErrorContains(v.Verify(d, bitcoinTestProof(proof)), "bitcoin output index 2 out of 2")
+	d.Index, d.Transaction = 0, hex.EncodeToString(txid)
+	require.ErrorContains(v.Verify(d, bitcoinTestProof(proof)), "bitcoin transaction hash")
+	d.Transaction = hex.EncodeToString(reversed(txid))
+
+	proof.Position = 0
+	require.ErrorContains(v.Verify(d, bitcoinTestProof(proof)), "bitcoin merkle root")
+	proof.Position = 3
+	require.ErrorContains(v.Verify(d, bitcoinTestProof(proof)), "bitcoin merkle root")
+	proof.Position = 1
+	proof.Headers = []string{hex.EncodeToString(block), hex.EncodeToString(block)}
+	require.ErrorContains(v.Verify(d, bitcoinTestProof(proof)), "bitcoin header 1 not linked")
+	proof.Headers = []string{hex.EncodeToString(block)}
+	require.ErrorContains(v.Verify(d, bitcoinTestProof(proof)), "bitcoin confirmations 1 less than 2")
+	proof.Headers = []string{hex.EncodeToString(block), hex.EncodeToString(tip)}
+	require.Nil(v.Verify(d, bitcoinTestProof(proof)))
+
+	v, err = NewBitcoinVerifier(2, BitcoinMaxTargetBits)
+	require.Nil(err)
+	require.ErrorContains(v.Verify(d, bitcoinTestProof(proof)), "bitcoin header target")
+}
+
+func TestBitcoinTarget(t *testing.T) {
+	require := require.New(t)
+
+	target, err := bitcoinTarget(0x1d00ffff)
+	require.Nil(err)
+	require.Equal("ffff0000000000000000000000000000000000000000000000000000", target.Text(16))
+	target, err = bitcoinTarget(0x03123456)
+	require.Nil(err)
+	require.Equal(big.NewInt(0x123456), target)
+	target, err = bitcoinTarget(0x02123456)
+	require.Nil(err)
+	require.Equal(big.NewInt(0x1234), target)
+	_, err = bitcoinTarget(0x1d800000)
+	require.NotNil(err)
+	_, err = bitcoinTarget(0x1d000000)
+	require.NotNil(err)
+}
+
+func bitcoinTestTransaction(witness bool) []byte {
+	raw := binary.LittleEndian.AppendUint32(nil, 2)
+	if witness {
+		raw = append(raw, 0, 1)
+	}
+	raw = append(raw, 1)
+	raw = append(raw, make([]byte, 36)...)
+	raw = append(raw, 0)
+	raw = binary.LittleEndian.AppendUint32(raw, 0xffffffff)
+	raw = append(raw, 2)
+	raw = binary.LittleEndian.AppendUint64(raw, 150000000)
+	raw = append(raw, 1, 0x51)
+	raw = binary.LittleEndian.AppendUint64(raw, 2100000000000000)
+	raw = append(raw, 1, 0x51)
+	if witness {
+		raw = append(raw, 1, 1, 0x51)
+	}
+	return binary.LittleEndian.AppendUint32(raw, 0)
+}
+
+func bitcoinTestHeader(prev, root []byte) []byte {
+	target, _ := bitcoinTarget(bitcoinTestTargetBits)
+	header := binary.LittleEndian.AppendUint32(nil, 0x20000000)
+	header = append(header, prev...)
+	header = append(header, root...)
+	header = binary.LittleEndian.AppendUint32(header, 1700000000)
+	header = binary.LittleEndian.AppendUint32(header, bitcoinTestTargetBits)
+	for nonce := uint32(0); ; nonce++ {
+		h := binary.LittleEndian.AppendUint32(slices.Clone(header), nonce)
+		if new(big.Int).SetBytes(reversed(doubleSha256(h))).Cmp(target) <= 0 {
+			return h
+		}
+	}
+}
+
+func bitcoinTestProof(proof *BitcoinProof) []byte {
+	data, _ := json.Marshal(proof)
+	return data
+}
@@ -0,0 +1,162 @@
+package depositproof
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+const (
+	ProofSizeLimit = 4 * 1024 * 1024
+
+	checkerCacheTTL   = time.Hour
+	checkerFailureTTL = time.Minute
+	checkerCacheLimit = 16384
+)
+
+var ErrProofNotFound = errors.New("deposit proof not found")
+
+// the custodian signs the deposits, so a compromised custodian is able to
+// mint any asset by a fabricated deposit. the node checks each deposit of a
+// supported chain against the proof from the provider, e.g. the SPV proof of
+// a bitcoin transaction or the receipt proof of an ethereum transaction, and
+// refuses to sign the snapshot if the proof is missing or invalid. the check
+// is not part of the consensus, the finalized deposits are always accepted.
+type Verifier interface {
+	Verify(d *common.DepositData, proof []byte) error
+}
+
+type Provider interface {
+	ReadProof(d *common.DepositData) ([]byte, error)
+}
+
+type Checker struct {
+	provider  Provider
+	verifiers map[crypto.Hash]Verifier
+
+	mutex   sync.Mutex
+	results map[crypto.Hash]*checkResult
+	pending map[crypto.Hash]bool
+}
+
+type checkResult struct {
+	at  time.Time
+	err error
+}
+
+func NewChecker(provider Provider) *Checker {
+	return &Checker{
+		provider:  provider,
+		verifiers: make(map[crypto.Hash]Verifier),
+		results:   make(map[crypto.Hash]*checkResult),
+		pending:   make(map[crypto.Hash]bool),
+	}
+}
+
+// Register the verifier for the deposits of the chain, the deposits of the
+// chains without any verifier are not checked.
+func (c *Checker) Register(chain crypto.Hash, v Verifier) {
+	c.verifiers[chain] = v
+}
+
+func (c *Checker) Supports(chain crypto.Hash) bool {
+	return c.verifiers[chain] != nil
+}
+
+// Check verifies the proof of the deposit, both the valid and invalid results
+// are cached, the failures only shortly in case the provider is down, so the
+// provider is not requested again for each cosi message.
+func (c *Checker) Check(d *common.DepositData) error {
+	v := c.verifiers[d.Chain]
+	if v == nil {
+		return nil
+	}
+	key := d.UniqueKey()
+	if found, err := c.cached(key, time.Now()); found {
+		return err
+	}
+	err := c.verify(v, d)
+	c.cache(key, err, time.Now())
+	return err
+}
+
+// Cached returns the cached result of the deposit without any network request,
+// and false if the deposit is not checked yet or the result expired.
+func (c *Checker) Cached(d *common.DepositData) (bool, error) {
+	if c.verifiers[d.Chain] == nil {
+		return true, nil
+	}
+	return c.cached(d.UniqueKey(), time.Now())
+}
+
+// Prefetch checks the deposit in the background if it's not cached yet, so
+// a later Cached of the deposit has the result.
+func (c *Checker) Prefetch(d *common.DepositData) {
+	if found, _ := c.Cached(d); found {
+		return
+	}
+	key := d.UniqueKey()
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.pending[key] {
+		return
+	}
+	c.pending[key] = true
+	go func() {
+		c.Check(d)
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		delete(c.pending, key)
+	}()
+}
+
+func (c *Checker) verify(v Verifier, d *common.DepositData) error {
+	proof, err := c.provider.ReadProof(d)
+	if err != nil {
+		return fmt.Errorf("deposit proof unavailable %s:%d %v", d.Transaction, d.Index, err)
+	}
+	err = v.Verify(d, proof)
+	if err != nil {
+		return fmt.Errorf("invalid deposit proof %s:%d %v", d.Transaction, d.Index, err)
+	}
+	return nil
+}
+
+func (c *Checker) cached(key crypto.Hash, now time.Time) (bool, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	r := c.results[key]
+	if r == nil || r.expired(now) {
+		return false, nil
+	}
+	return true, r.err
+}
+
+func (c *Checker) cache(key crypto.Hash, err error, now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if len(c.results) >= checkerCacheLimit {
+		for k, r := range c.results {
+			if r.expired(now) {
+				delete(c.results, k)
+			}
+		}
+	}
+	if len(c.results) >= checkerCacheLimit {
+		c.results = make(map[crypto.Hash]*checkResult)
+	}
+	c.results[key] = &checkResult{at: now, err: err}
+}
+
+func (r *checkResult) expired(now time.Time) bool {
+	if r.err != nil {
+		return now.Sub(r.at) >= checkerFailureTTL
+	}
+	return now.Sub(r.at) >= checkerCacheTTL
+}
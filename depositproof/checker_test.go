@@ -0,0 +1,100 @@
+package depositproof
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+type testVerifier struct {
+	proofs [][]byte
+	err    error
+}
+
+func (v *testVerifier) Verify(d *common.DepositData, proof []byte) error {
+	v.proofs = append(v.proofs, proof)
+	return v.err
+}
+
+func TestChecker(t *testing.T) {
+	require := require.New(t)
+
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path)
+		if r.URL.Path != "/proofs/"+common.BitcoinAssetId.String()+"/abcd/1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"headers":[]}`))
+	}))
+	defer server.Close()
+
+	checker := NewChecker(NewHTTPProvider(server.URL + "/proofs/"))
+	v := &testVerifier{}
+	checker.Register(common.BitcoinAssetId, v)
+	require.True(checker.Supports(common.BitcoinAssetId))
+	require.False(checker.Supports(common.EthereumAssetId))
+
+	d := &common.DepositData{
+		Chain:       common.EthereumAssetId,
+		AssetKey:    EthereumNativeAssetKey,
+		Transaction: "abcd",
+		Index:       1,
+		Amount:      common.NewInteger(1),
+	}
+	require.Nil(checker.Check(d))
+	require.Len(requests, 0)
+
+	d.Chain = common.BitcoinAssetId
+	require.Nil(checker.Check(d))
+	require.Nil(checker.Check(d))
+	require.Len(requests, 1)
+	require.Equal([][]byte{[]byte(`{"headers":[]}`)}, v.proofs)
+
+	d.Index = 2
+	err := checker.Check(d)
+	require.ErrorContains(err, "deposit proof unavailable abcd:2")
+	require.ErrorContains(err, ErrProofNotFound.Error())
+	require.Len(requests, 2)
+
+	d.Index = 1
+	checker = NewChecker(NewHTTPProvider(server.URL + "/proofs"))
+	checker.Register(common.BitcoinAssetId, &testVerifier{err: errors.New("fabricated")})
+	found, err := checker.Cached(d)
+	require.False(found)
+	require.Nil(err)
+	require.ErrorContains(checker.Check(d), "invalid deposit proof abcd:1 fabricated")
+	require.ErrorContains(checker.Check(d), "fabricated")
+	require.Len(requests, 3)
+	found, err = checker.Cached(d)
+	require.True(found)
+	require.ErrorContains(err, "fabricated")
+	key := d.UniqueKey()
+	checker.results[key].at = time.Now().Add(-checkerFailureTTL)
+	found, _ = checker.Cached(d)
+	require.False(found)
+
+	checker = NewChecker(NewHTTPProvider(server.URL + "/proofs"))
+	checker.Register(common.BitcoinAssetId, &testVerifier{})
+	checker.Prefetch(d)
+	require.Eventually(func() bool {
+		found, err := checker.Cached(d)
+		return found && err == nil
+	}, time.Second, time.Millisecond*10)
+	require.Len(requests, 4)
+	checker.Prefetch(d)
+	require.Len(requests, 4)
+
+	checker = NewChecker(NewHTTPProvider(server.URL + "/proofs"))
+	for i := range checkerCacheLimit + 1 {
+		checker.cache(crypto.Blake3Hash([]byte{byte(i), byte(i >> 8)}), nil, time.Now())
+	}
+	require.Len(checker.results, 1)
+}
@@ -0,0 +1,227 @@
+package depositproof
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/shopspring/decimal"
+)
+
+// the ethereum proof is the block header, and the merkle patricia proofs of
+// the transaction and its receipt in the block. the block must be finalized
+// and canonical in the oracle, which is the light client or the full node of
+// the operator, so the proof itself could be from any untrusted provider.
+// the deposit index of the native ether must be 0, and the deposit index of
+// a token is the position of the transfer log in the receipt.
+const (
+	EthereumNativeAssetKey = "0x0000000000000000000000000000000000000000"
+
+	ethereumDecimals = 18
+)
+
+var ethereumTransferTopic = keccak256([]byte("Transfer(address,address,uint256)"))
+
+type EthereumProof struct {
+	Header      string   `json:"header"`
+	Index       uint64   `json:"index"`
+	Transaction []string `json:"transaction"`
+	Receipt     []string `json:"receipt"`
+}
+
+type EthereumOracle interface {
+	BlockHash(number uint64) ([]byte, error)
+	FinalizedNumber() (uint64, error)
+}
+
+type EthereumVerifier struct {
+	oracle EthereumOracle
+	tokens map[string]int
+}
+
+func NewEthereumVerifier(oracle EthereumOracle) *EthereumVerifier {
+	v := &EthereumVerifier{oracle: oracle, tokens: make(map[string]int)}
+	v.AddToken(common.XINAsset.AssetKey, 18)
+	v.AddToken("0xdac17f958d2ee523a2206206994597c13d831ec7", 6)
+	v.AddToken("0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48", 6)
+	return v
+}
+
+// AddToken with the decimals of the token contract, the deposits of the
+// tokens not added are rejected, because the amount can't be verified.
+func (v *EthereumVerifier) AddToken(key string, decimals int) {
+	v.tokens[strings.ToLower(key)] = decimals
+}
+
+func (v *EthereumVerifier) Verify(d *common.DepositData, data []byte) error {
+	var proof EthereumProof
+	err := json.Unmarshal(data, &proof)
+	if err != nil {
+		return err
+	}
+	header, err := hex.DecodeString(strings.TrimPrefix(proof.Header, "0x"))
+	if err != nil {
+		return err
+	}
+	fields, err := rlpList(header)
+	if err != nil {
+		return err
+	}
+	if len(fields) < 15 || len(fields[4].data) != 32 || len(fields[5].data) != 32 || len(fields[8].data) > 8 {
+		return fmt.Errorf("ethereum invalid header")
+	}
+	number := new(big.Int).SetBytes(fields[8].data).Uint64()
+	finalized, err := v.oracle.FinalizedNumber()
+	if err != nil {
+		return err
+	}
+	if number > finalized {
+		return fmt.Errorf("ethereum block %d not finalized %d", number, finalized)
+	}
+	canonical, err := v.oracle.BlockHash(number)
+	if err != nil {
+		return err
+	}
+	if hash := keccak256(header); !bytes.Equal(hash, canonical) {
+		return fmt.Errorf("ethereum block %d hash %x not canonical %x", number, hash, canonical)
+	}
+
+	key := rlpUint(proof.Index)
+	nodes, err := decodeHexList(proof.Transaction)
+	if err != nil {
+		return err
+	}
+	tx, err := verifyTrieProof(fields[4].data, key, nodes)
+	if err != nil {
+		return err
+	}
+	hash := strings.TrimPrefix(strings.ToLower(d.Transaction), "0x")
+	if hex.EncodeToString(keccak256(tx)) != hash {
+		return fmt.Errorf("ethereum transaction hash %x", keccak256(tx))
+	}
+	nodes, err = decodeHexList(proof.Receipt)
+	if err != nil {
+		return err
+	}
+	receipt, err := verifyTrieProof(fields[5].data, key, nodes)
+	if err != nil {
+		return err
+	}
+	logs, err := ethereumReceiptLogs(receipt)
+	if err != nil {
+		return err
+	}
+
+	var value *big.Int
+	var decimals int
+	if d.AssetKey == EthereumNativeAssetKey {
+		if d.Index != 0 {
+			return fmt.Errorf("ethereum native deposit index %d", d.Index)
+		}
+		value, err = ethereumTransactionValue(tx)
+		decimals = ethereumDecimals
+	} else {
+		dec, found := v.tokens[strings.ToLower(d.AssetKey)]
+		if !found {
+			return fmt.Errorf("ethereum token %s not supported", d.AssetKey)
+		}
+		if d.Index >= uint64(len(logs)) {
+			return fmt.Errorf("ethereum log index %d out of %d", d.Index, len(logs))
+		}
+		value, err = ethereumTransferValue(logs[d.Index], d.AssetKey)
+		decimals = dec
+	}
+	if err != nil {
+		return err
+	}
+	amount := decimal.NewFromBigInt(value, -int32(decimals))
+	if common.NewIntegerFromString(amount.String()).Cmp(d.Amount) != 0 {
+		return fmt.Errorf("ethereum amount %s %s", amount, d.Amount)
+	}
+	return nil
+}
+
+// ethereumReceiptLogs returns the logs of the successful receipt.
+func ethereumReceiptLogs(receipt []byte) ([]*rlpItem, error) {
+	if len(receipt) > 0 && receipt[0] < 0xc0 {
+		receipt = receipt[1:]
+	}
+	fields, err := rlpList(receipt)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) != 4 || !fields[3].list {
+		return nil, fmt.Errorf("ethereum invalid receipt")
+	}
+	if !bytes.Equal(fields[0].data, []byte{1}) {
+		return nil, fmt.Errorf("ethereum receipt status %x", fields[0].data)
+	}
+	return rlpList(fields[3].raw)
+}
+
+func ethereumTransactionValue(tx []byte) (*big.Int, error) {
+	if len(tx) == 0 {
+		return nil, fmt.Errorf("ethereum empty transaction")
+	}
+	index := 4
+	if tx[0] < 0xc0 {
+		switch tx[0] {
+		case 1:
+			index = 5
+		case 2, 3, 4:
+			index = 6
+		default:
+			return nil, fmt.Errorf("ethereum transaction type %d", tx[0])
+		}
+		tx = tx[1:]
+	}
+	fields, err := rlpList(tx)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) <= index || fields[index].list || len(fields[index].data) > 32 {
+		return nil, fmt.Errorf("ethereum invalid transaction")
+	}
+	return new(big.Int).SetBytes(fields[index].data), nil
+}
+
+func ethereumTransferValue(log *rlpItem, contract string) (*big.Int, error) {
+	fields, err := rlpList(log.raw)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) != 3 || fields[0].list || !fields[1].list || fields[2].list {
+		return nil, fmt.Errorf("ethereum invalid log")
+	}
+	address := "0x" + hex.EncodeToString(fields[0].data)
+	if address != strings.ToLower(contract) {
+		return nil, fmt.Errorf("ethereum log address %s", address)
+	}
+	topics, err := rlpList(fields[1].raw)
+	if err != nil {
+		return nil, err
+	}
+	if len(topics) != 3 || !bytes.Equal(topics[0].data, ethereumTransferTopic) {
+		return nil, fmt.Errorf("ethereum log not a transfer")
+	}
+	if len(fields[2].data) != 32 {
+		return nil, fmt.Errorf("ethereum transfer data size %d", len(fields[2].data))
+	}
+	return new(big.Int).SetBytes(fields[2].data), nil
+}
+
+func decodeHexList(list []string) ([][]byte, error) {
+	var nodes [][]byte
+	for _, s := range list {
+		b, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, b)
+	}
+	return nodes, nil
+}
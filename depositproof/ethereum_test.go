@@ -0,0 +1,266 @@
+package depositproof
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"slices"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/stretchr/testify/require"
+)
+
+type ethereumTestOracle struct {
+	hashes    map[uint64][]byte
+	finalized uint64
+}
+
+func (o *ethereumTestOracle) BlockHash(number uint64) ([]byte, error) {
+	if h := o.hashes[number]; h != nil {
+		return h, nil
+	}
+	return nil, fmt.Errorf("block %d not found", number)
+}
+
+func (o *ethereumTestOracle) FinalizedNumber() (uint64, error) {
+	return o.finalized, nil
+}
+
+func TestEthereumVerifier(t *testing.T) {
+	require := require.New(t)
+
+	usdt := "0xdAC17F958D2ee523a2206206994597C13D831ec7"
+	token, _ := hex.DecodeString(usdt[2:])
+	transfer := rlpTestList(rlpTestBytes(token), rlpTestList(
+		rlpTestBytes(ethereumTransferTopic), rlpTestBytes(make([]byte, 32)), rlpTestBytes(make([]byte, 32)),
+	), rlpTestBytes(rlpTestWord(big.NewInt(2500000))))
+
+	wei, _ := new(big.Int).SetString("1500000000000000000", 10)
+	txs := [][]byte{ethereumTestTransaction(big.NewInt(0), 1), ethereumTestTransaction(wei, 2)}
+	receipts := [][]byte{ethereumTestReceipt(1, transfer), ethereumTestReceipt(1)}
+	txRoot, txProof := ethereumTestTrie(txs)
+	receiptRoot, receiptProof := ethereumTestTrie(receipts)
+
+	fields := make([][]byte, 15)
+	for i := range fields {
+		fields[i] = rlpTestBytes(nil)
+	}
+	fields[4], fields[5], fields[8] = rlpTestBytes(txRoot), rlpTestBytes(receiptRoot), rlpTestBytes([]byte{0x01, 0x00})
+	header := rlpTestList(fields...)
+	oracle := &ethereumTestOracle{hashes: map[uint64][]byte{256: keccak256(header)}, finalized: 256}
+	v := NewEthereumVerifier(oracle)
+
+	proof := &EthereumProof{
+		Header:      "0x" + hex.EncodeToString(header),
+		Index:       1,
+		Transaction: txProof,
+		Receipt:     receiptProof,
+	}
+	d := &common.DepositData{
+		Chain:       common.EthereumAssetId,
+		AssetKey:    EthereumNativeAssetKey,
+		Transaction: "0x" + hex.EncodeToString(keccak256(txs[1])),
+		Index:       0,
+		Amount:      common.NewIntegerFromString("1.5"),
+	}
+	require.Nil(v.Verify(d, ethereumTestProof(proof)))
+
+	d.Amount = common.NewIntegerFromString("1.4")
+	require.ErrorContains(v.Verify(d, ethereumTestProof(proof)), "ethereum amount")
+	d.Amount, d.Index = common.NewIntegerFromString("1.5"), 1
+	require.ErrorContains(v.Verify(d, ethereumTestProof(proof)), "ethereum native deposit index 1")
+	d.Index, proof.Index = 0, 0
+	require.ErrorContains(v.Verify(d, ethereumTestProof(proof)), "ethereum transaction hash")
+	proof.Index = 2
+	require.ErrorContains(v.Verify(d, ethereumTestProof(proof)), "trie key 02 not found")
+	proof.Index = 1
+
+	oracle.finalized = 255
+	require.ErrorContains(v.Verify(d, ethereumTestProof(proof)), "ethereum block 256 not finalized 255")
+	oracle.finalized = 256
+	oracle.hashes[256] = make([]byte, 32)
+	require.ErrorContains(v.Verify(d, ethereumTestProof(proof)), "not canonical")
+	oracle.hashes[256] = keccak256(header)
+
+	proof.Index = 0
+	d.AssetKey = usdt
+	d.Transaction = hex.EncodeToString(keccak256(txs[0]))
+	d.Amount = common.NewIntegerFromString("2.5")
+	require.Nil(v.Verify(d, ethereumTestProof(proof)))
+	d.Index = 1
+	require.ErrorContains(v.Verify(d, ethereumTestProof(proof)), "ethereum log index 1 out of 1")
+	d.Index, d.AssetKey = 0, common.XINAsset.AssetKey
+	require.ErrorContains(v.Verify(d, ethereumTestProof(proof)), "ethereum log address")
+	d.AssetKey = "0x0000000000000000000000000000000000000001"
+	require.ErrorContains(v.Verify(d, ethereumTestProof(proof)), "not supported")
+
+	receipts[0] = ethereumTestReceipt(0, transfer)
+	receiptRoot, proof.Receipt = ethereumTestTrie(receipts)
+	fields[5] = rlpTestBytes(receiptRoot)
+	header = rlpTestList(fields...)
+	oracle.hashes[256] = keccak256(header)
+	proof.Header = hex.EncodeToString(header)
+	d.AssetKey = usdt
+	require.ErrorContains(v.Verify(d, ethereumTestProof(proof)), "ethereum receipt status")
+}
+
+func TestTrieProof(t *testing.T) {
+	require := require.New(t)
+
+	var values [][]byte
+	for i := range 130 {
+		values = append(values, []byte{byte(i), 0xaa})
+	}
+	root, proof := ethereumTestTrie(values)
+	nodes, err := decodeHexList(proof)
+	require.Nil(err)
+	for i, val := range values {
+		v, err := verifyTrieProof(root, rlpUint(uint64(i)), nodes)
+		require.Nil(err)
+		require.Equal(val, v)
+	}
+	_, err = verifyTrieProof(root, rlpUint(130), nodes)
+	require.NotNil(err)
+	_, err = verifyTrieProof(root, rlpUint(0), nodes[1:])
+	require.NotNil(err)
+}
+
+func ethereumTestTransaction(value *big.Int, nonce byte) []byte {
+	fields := [][]byte{rlpTestBytes([]byte{1}), rlpTestBytes([]byte{nonce})}
+	for range 3 {
+		fields = append(fields, rlpTestBytes([]byte{0x10}))
+	}
+	fields = append(fields, rlpTestBytes(make([]byte, 20)), rlpTestBytes(value.Bytes()), rlpTestBytes(nil), rlpTestList())
+	fields = append(fields, rlpTestBytes(nil), rlpTestBytes(make([]byte, 32)), rlpTestBytes(make([]byte, 32)))
+	return append([]byte{2}, rlpTestList(fields...)...)
+}
+
+func ethereumTestReceipt(status byte, logs ...[]byte) []byte {
+	var st []byte
+	if status > 0 {
+		st = []byte{status}
+	}
+	receipt := rlpTestList(rlpTestBytes(st), rlpTestBytes([]byte{0x52, 0x08}), rlpTestBytes(make([]byte, 256)), rlpTestList(logs...))
+	return append([]byte{2}, receipt...)
+}
+
+func ethereumTestProof(proof *EthereumProof) []byte {
+	data, _ := json.Marshal(proof)
+	return data
+}
+
+// ethereumTestTrie builds the trie of the values keyed by the rlp encoded
+// indexes, and returns the root and all the nodes hashed.
+func ethereumTestTrie(values [][]byte) ([]byte, []string) {
+	paths := make(map[string][]byte)
+	for i, v := range values {
+		var path []byte
+		for _, b := range rlpUint(uint64(i)) {
+			path = append(path, b>>4, b&0x0f)
+		}
+		paths[string(path)] = v
+	}
+	var nodes []string
+	node := ethereumTestNode(paths, &nodes)
+	nodes = append([]string{hex.EncodeToString(node)}, nodes...)
+	return keccak256(node), nodes
+}
+
+func ethereumTestNode(paths map[string][]byte, nodes *[]string) []byte {
+	if len(paths) == 1 {
+		for p, v := range paths {
+			return rlpTestList(rlpTestBytes(compactTestPath([]byte(p), true)), rlpTestBytes(v))
+		}
+	}
+	var keys []string
+	for p := range paths {
+		keys = append(keys, p)
+	}
+	prefix := []byte(keys[0])
+	for _, k := range keys[1:] {
+		n := 0
+		for n < len(prefix) && n < len(k) && prefix[n] == k[n] {
+			n++
+		}
+		prefix = prefix[:n]
+	}
+	if len(prefix) > 0 {
+		sub := make(map[string][]byte)
+		for p, v := range paths {
+			sub[p[len(prefix):]] = v
+		}
+		child := ethereumTestRef(ethereumTestNode(sub, nodes), nodes)
+		return rlpTestList(rlpTestBytes(compactTestPath(prefix, false)), child)
+	}
+
+	items := make([][]byte, 17)
+	for i := range items {
+		items[i] = rlpTestBytes(nil)
+	}
+	for n := range 16 {
+		sub := make(map[string][]byte)
+		for p, v := range paths {
+			if len(p) > 0 && p[0] == byte(n) {
+				sub[p[1:]] = v
+			}
+		}
+		if len(sub) > 0 {
+			items[n] = ethereumTestRef(ethereumTestNode(sub, nodes), nodes)
+		}
+	}
+	if v, found := paths[""]; found {
+		items[16] = rlpTestBytes(v)
+	}
+	return rlpTestList(items...)
+}
+
+func ethereumTestRef(node []byte, nodes *[]string) []byte {
+	if len(node) < 32 {
+		return node
+	}
+	*nodes = append(*nodes, hex.EncodeToString(node))
+	return rlpTestBytes(keccak256(node))
+}
+
+func compactTestPath(path []byte, leaf bool) []byte {
+	var flag byte
+	if leaf {
+		flag = 2
+	}
+	if len(path)%2 == 1 {
+		path = append([]byte{flag + 1}, path...)
+	} else {
+		path = append([]byte{flag, 0}, path...)
+	}
+	var compact []byte
+	for i := 0; i < len(path); i += 2 {
+		compact = append(compact, path[i]<<4|path[i+1])
+	}
+	return compact
+}
+
+func rlpTestBytes(b []byte) []byte {
+	if len(b) == 1 && b[0] < 0x80 {
+		return slices.Clone(b)
+	}
+	return append(rlpTestPrefix(0x80, len(b)), b...)
+}
+
+func rlpTestList(items ...[]byte) []byte {
+	payload := slices.Concat(items...)
+	return append(rlpTestPrefix(0xc0, len(payload)), payload...)
+}
+
+func rlpTestPrefix(base byte, size int) []byte {
+	if size < 56 {
+		return []byte{base + byte(size)}
+	}
+	b := big.NewInt(int64(size)).Bytes()
+	return append([]byte{base + 55 + byte(len(b))}, b...)
+}
+
+func rlpTestWord(v *big.Int) []byte {
+	return v.FillBytes(make([]byte, 32))
+}
@@ -0,0 +1,129 @@
+package depositproof
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+)
+
+const providerTimeout = 5 * time.Second
+
+// the HTTP provider reads the proof of the deposit from the endpoint at the
+// path /chain/transaction/index, which responds the proof JSON of the chain,
+// or the status 404 if the proof is not available yet.
+type HTTPProvider struct {
+	endpoint string
+	client   *http.Client
+}
+
+func NewHTTPProvider(endpoint string) *HTTPProvider {
+	return &HTTPProvider{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		client:   &http.Client{Timeout: providerTimeout},
+	}
+}
+
+func (p *HTTPProvider) ReadProof(d *common.DepositData) ([]byte, error) {
+	path := fmt.Sprintf("%s/%s/%s/%d", p.endpoint, d.Chain, url.PathEscape(d.Transaction), d.Index)
+	resp, err := p.client.Get(path)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, ErrProofNotFound
+	default:
+		return nil, fmt.Errorf("deposit proof provider status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, ProofSizeLimit+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > ProofSizeLimit {
+		return nil, fmt.Errorf("deposit proof size exceeds %d", ProofSizeLimit)
+	}
+	return data, nil
+}
+
+// the ethereum JSON-RPC oracle reads the canonical block hashes and the
+// finalized block number from the node of the operator.
+type EthereumRPC struct {
+	endpoint string
+	client   *http.Client
+}
+
+func NewEthereumRPC(endpoint string) *EthereumRPC {
+	return &EthereumRPC{
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: providerTimeout},
+	}
+}
+
+func (r *EthereumRPC) BlockHash(number uint64) ([]byte, error) {
+	block, err := r.readBlock("0x" + strconv.FormatUint(number, 16))
+	if err != nil {
+		return nil, err
+	}
+	return hex.DecodeString(strings.TrimPrefix(block.Hash, "0x"))
+}
+
+func (r *EthereumRPC) FinalizedNumber() (uint64, error) {
+	block, err := r.readBlock("finalized")
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimPrefix(block.Number, "0x"), 16, 64)
+}
+
+type ethereumBlock struct {
+	Hash   string `json:"hash"`
+	Number string `json:"number"`
+}
+
+func (r *EthereumRPC) readBlock(tag string) (*ethereumBlock, error) {
+	body, err := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "eth_getBlockByNumber",
+		"params":  []any{tag, false},
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.client.Post(r.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Result *ethereumBlock `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	err = json.NewDecoder(io.LimitReader(resp.Body, 1024*1024)).Decode(&result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Error != nil {
+		return nil, fmt.Errorf("ethereum rpc error %d %s", result.Error.Code, result.Error.Message)
+	}
+	if result.Result == nil {
+		return nil, fmt.Errorf("ethereum block %s not found", tag)
+	}
+	return result.Result, nil
+}
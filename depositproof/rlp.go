@@ -0,0 +1,161 @@
+package depositproof
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+
+	"golang.org/x/crypto/sha3"
+)
+
+type rlpItem struct {
+	list bool
+	data []byte
+	raw  []byte
+}
+
+func rlpSplit(b []byte) (*rlpItem, []byte, error) {
+	if len(b) == 0 {
+		return nil, nil, fmt.Errorf("rlp empty input")
+	}
+	prefix := int(b[0])
+	var list bool
+	var offset, size int
+	switch {
+	case prefix < 0x80:
+		offset, size = 0, 1
+	case prefix <= 0xb7:
+		offset, size = 1, prefix-0x80
+	case prefix < 0xc0:
+		list, offset = false, 1+prefix-0xb7
+	case prefix <= 0xf7:
+		list, offset, size = true, 1, prefix-0xc0
+	default:
+		list, offset = true, 1+prefix-0xf7
+	}
+	if offset > 1 {
+		if len(b) < offset || b[1] == 0 {
+			return nil, nil, fmt.Errorf("rlp invalid size prefix")
+		}
+		sz := new(big.Int).SetBytes(b[1:offset])
+		if !sz.IsInt64() || sz.Int64() > int64(len(b)) || sz.Int64() < 56 {
+			return nil, nil, fmt.Errorf("rlp invalid size %s", sz)
+		}
+		size = int(sz.Int64())
+	}
+	if len(b)-offset < size {
+		return nil, nil, fmt.Errorf("rlp truncated %d %d", len(b), offset+size)
+	}
+	item := &rlpItem{list: list, data: b[offset : offset+size], raw: b[:offset+size]}
+	return item, b[offset+size:], nil
+}
+
+// rlpList decodes the items of the list, which must be the whole input.
+func rlpList(b []byte) ([]*rlpItem, error) {
+	item, rest, err := rlpSplit(b)
+	if err != nil {
+		return nil, err
+	}
+	if !item.list || len(rest) > 0 {
+		return nil, fmt.Errorf("rlp not a list")
+	}
+	var items []*rlpItem
+	for data := item.data; len(data) > 0; {
+		var it *rlpItem
+		it, data, err = rlpSplit(data)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	return items, nil
+}
+
+func rlpUint(n uint64) []byte {
+	if n == 0 {
+		return []byte{0x80}
+	}
+	b := new(big.Int).SetUint64(n).Bytes()
+	if len(b) == 1 && b[0] < 0x80 {
+		return b
+	}
+	return append([]byte{0x80 + byte(len(b))}, b...)
+}
+
+// verifyTrieProof returns the value of the key in the merkle patricia trie
+// of the root, the proof has all the nodes from the root to the value, the
+// nodes smaller than 32 bytes are embedded in the parent.
+func verifyTrieProof(root, key []byte, proof [][]byte) ([]byte, error) {
+	nodes := make(map[string][]byte)
+	for _, n := range proof {
+		nodes[string(keccak256(n))] = n
+	}
+	var path []byte
+	for _, b := range key {
+		path = append(path, b>>4, b&0x0f)
+	}
+
+	ref := &rlpItem{data: root}
+	for {
+		var node []byte
+		switch {
+		case ref.list:
+			node = ref.raw
+		case len(ref.data) == 32:
+			node = nodes[string(ref.data)]
+			if node == nil {
+				return nil, fmt.Errorf("trie node %x missing", ref.data)
+			}
+		default:
+			return nil, fmt.Errorf("trie key %x not found", key)
+		}
+		items, err := rlpList(node)
+		if err != nil {
+			return nil, err
+		}
+		switch len(items) {
+		case 17:
+			if len(path) == 0 {
+				return items[16].data, nil
+			}
+			ref, path = items[path[0]], path[1:]
+		case 2:
+			if items[0].list || len(items[0].data) == 0 {
+				return nil, fmt.Errorf("trie invalid node path")
+			}
+			flag, encoded := items[0].data[0]>>4, items[0].data
+			if flag > 3 {
+				return nil, fmt.Errorf("trie invalid node flag %d", flag)
+			}
+			var partial []byte
+			if flag&1 == 1 {
+				partial = append(partial, encoded[0]&0x0f)
+			}
+			for _, b := range encoded[1:] {
+				partial = append(partial, b>>4, b&0x0f)
+			}
+			if len(partial) == 0 && flag&2 == 0 {
+				return nil, fmt.Errorf("trie invalid extension node")
+			}
+			if !bytes.HasPrefix(path, partial) {
+				return nil, fmt.Errorf("trie key %x not found", key)
+			}
+			path = path[len(partial):]
+			if flag&2 == 2 {
+				if len(path) > 0 {
+					return nil, fmt.Errorf("trie key %x not found", key)
+				}
+				return items[1].data, nil
+			}
+			ref = items[1]
+		default:
+			return nil, fmt.Errorf("trie invalid node size %d", len(items))
+		}
+	}
+}
+
+func keccak256(b []byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	h.Write(b)
+	return h.Sum(nil)
+}
@@ -0,0 +1,242 @@
+package diagnostics
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	runtimepprof "runtime/pprof"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	LogRingSize = 4 * 1024 * 1024
+
+	bundlePrefix = "support-"
+	bundleKeep   = 10
+	crashFile    = "crash.log"
+)
+
+// LogRing keeps the latest log output in memory for the support bundle, it
+// should be one of the writers of the standard logger.
+type LogRing struct {
+	mutex  sync.Mutex
+	size   int
+	buffer []byte
+}
+
+func NewLogRing(size int) *LogRing {
+	return &LogRing{size: size}
+}
+
+func (r *LogRing) Write(p []byte) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.buffer = append(r.buffer, p...)
+	if over := len(r.buffer) - r.size; over > 0 {
+		r.buffer = slices.Clone(r.buffer[over:])
+	}
+	return len(p), nil
+}
+
+func (r *LogRing) Bytes() []byte {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return slices.Clone(r.buffer)
+}
+
+// the bundler writes the support bundles to the directory, each section is
+// encoded as a JSON file in the zip, and a failed section is recorded as an
+// error instead of failing the whole bundle, because the node may be broken.
+type bundler struct {
+	mutex    sync.Mutex
+	dir      string
+	logs     *LogRing
+	sections map[string]func() (any, error)
+}
+
+// FIXME GLOBAL VARIABLES
+var bundles struct {
+	sync.Mutex
+	b *bundler
+}
+
+// SetupBundle configures the support bundles of the node, the logs ring is
+// optional, and the sections are usually the config with the secrets
+// redacted, the chain states, the sync points and the store stats.
+func SetupBundle(dir string, logs *LogRing, sections map[string]func() (any, error)) error {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return err
+	}
+	bundles.Lock()
+	defer bundles.Unlock()
+	bundles.b = &bundler{dir: dir, logs: logs, sections: sections}
+	return nil
+}
+
+// WriteBundle writes a support bundle for the reason and returns the path.
+func WriteBundle(reason string) (string, error) {
+	bundles.Lock()
+	b := bundles.b
+	bundles.Unlock()
+	if b == nil {
+		return "", fmt.Errorf("support bundle not configured")
+	}
+	return b.write(reason, nil)
+}
+
+// CaptureCrash sends the fatal errors output with all the goroutines to the
+// crash file in the bundle directory. If the file is not empty, the previous
+// run crashed, then a bundle with the crash output is written before the
+// file is truncated for this run.
+func CaptureCrash() (string, error) {
+	bundles.Lock()
+	b := bundles.b
+	bundles.Unlock()
+	if b == nil {
+		return "", fmt.Errorf("support bundle not configured")
+	}
+
+	var path string
+	name := filepath.Join(b.dir, crashFile)
+	crash, err := os.ReadFile(name)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	if len(crash) > 0 {
+		path, err = b.write("crash", crash)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return path, err
+	}
+	defer f.Close()
+	debug.SetTraceback("all")
+	return path, debug.SetCrashOutput(f, debug.CrashOptions{})
+}
+
+func (b *bundler) write(reason string, crash []byte) (string, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := time.Now()
+	name := fmt.Sprintf("%s%d.zip", bundlePrefix, now.UnixNano())
+	path := filepath.Join(b.dir, name)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0600)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	info := fmt.Sprintf("reason: %s\ntime: %s\n", reason, now.UTC().Format(time.RFC3339Nano))
+	err = writeZipFile(zw, "reason.txt", []byte(info), now)
+	if err != nil {
+		return "", err
+	}
+	if len(crash) > 0 {
+		err = writeZipFile(zw, crashFile, crash, now)
+		if err != nil {
+			return "", err
+		}
+	}
+	if b.logs != nil {
+		err = writeZipFile(zw, "logs.txt", b.logs.Bytes(), now)
+		if err != nil {
+			return "", err
+		}
+	}
+	w, err := zw.CreateHeader(zipHeader("goroutines.txt", now))
+	if err != nil {
+		return "", err
+	}
+	err = runtimepprof.Lookup("goroutine").WriteTo(w, 2)
+	if err != nil {
+		return "", err
+	}
+
+	names := make([]string, 0, len(b.sections))
+	for n := range b.sections {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		data, err := encodeSection(b.sections[n])
+		if err != nil {
+			return "", err
+		}
+		err = writeZipFile(zw, n+".json", data, now)
+		if err != nil {
+			return "", err
+		}
+	}
+	err = zw.Close()
+	if err != nil {
+		return "", err
+	}
+	b.prune()
+	return path, f.Sync()
+}
+
+func encodeSection(section func() (any, error)) ([]byte, error) {
+	return json.MarshalIndent(readSection(section), "", "  ")
+}
+
+func readSection(section func() (any, error)) (v any) {
+	defer func() {
+		if r := recover(); r != nil {
+			v = map[string]string{"panic": fmt.Sprint(r)}
+		}
+	}()
+	v, err := section()
+	if err != nil {
+		return map[string]string{"error": err.Error()}
+	}
+	return v
+}
+
+func zipHeader(name string, modified time.Time) *zip.FileHeader {
+	return &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified}
+}
+
+func writeZipFile(zw *zip.Writer, name string, data []byte, modified time.Time) error {
+	w, err := zw.CreateHeader(zipHeader(name, modified))
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// the bundle names have the same length in a long time, so the sorted
+// names are also in the time order
+func (b *bundler) prune() {
+	entries, err := os.ReadDir(b.dir)
+	if err != nil {
+		return
+	}
+	var names []string
+	for _, e := range entries {
+		n := e.Name()
+		if strings.HasPrefix(n, bundlePrefix) && strings.HasSuffix(n, ".zip") {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+	for len(names) > bundleKeep {
+		os.Remove(filepath.Join(b.dir, names[0]))
+		names = names[1:]
+	}
+}
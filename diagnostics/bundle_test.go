@@ -0,0 +1,90 @@
+package diagnostics
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogRing(t *testing.T) {
+	require := require.New(t)
+
+	r := NewLogRing(8)
+	fmt.Fprint(r, "hello")
+	require.Equal("hello", string(r.Bytes()))
+	fmt.Fprint(r, " world")
+	require.Equal("lo world", string(r.Bytes()))
+}
+
+func TestBundle(t *testing.T) {
+	require := require.New(t)
+
+	_, err := WriteBundle("rpc")
+	require.NotNil(err)
+
+	root, err := os.MkdirTemp("", "mixin-bundle-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	logs := NewLogRing(LogRingSize)
+	fmt.Fprintln(logs, "round finalized")
+	err = SetupBundle(root, logs, map[string]func() (any, error){
+		"config": func() (any, error) { return map[string]string{"token": "REDACTED"}, nil },
+		"store":  func() (any, error) { return nil, errors.New("store closed") },
+		"state":  func() (any, error) { panic("chain hangs") },
+	})
+	require.Nil(err)
+
+	err = os.WriteFile(filepath.Join(root, crashFile), []byte("panic: boom"), 0600)
+	require.Nil(err)
+	path, err := CaptureCrash()
+	require.Nil(err)
+	files := readBundle(t, path)
+	require.Contains(files["reason.txt"], "reason: crash")
+	require.Equal("panic: boom", files[crashFile])
+	crash, err := os.ReadFile(filepath.Join(root, crashFile))
+	require.Nil(err)
+	require.Len(crash, 0)
+
+	path, err = WriteBundle("rpc")
+	require.Nil(err)
+	files = readBundle(t, path)
+	require.Contains(files["reason.txt"], "reason: rpc")
+	require.NotContains(files, crashFile)
+	require.Equal("round finalized\n", files["logs.txt"])
+	require.Contains(files["goroutines.txt"], "TestBundle")
+	require.Contains(files["config.json"], `"token": "REDACTED"`)
+	require.Contains(files["store.json"], `"error": "store closed"`)
+	require.Contains(files["state.json"], `"panic": "chain hangs"`)
+
+	for range bundleKeep + 2 {
+		_, err = WriteBundle("rpc")
+		require.Nil(err)
+	}
+	matches, err := filepath.Glob(filepath.Join(root, bundlePrefix+"*.zip"))
+	require.Nil(err)
+	require.Len(matches, bundleKeep)
+}
+
+func readBundle(t *testing.T, path string) map[string]string {
+	zr, err := zip.OpenReader(path)
+	require.Nil(t, err)
+	defer zr.Close()
+
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		r, err := f.Open()
+		require.Nil(t, err)
+		data, err := io.ReadAll(r)
+		require.Nil(t, err)
+		r.Close()
+		files[f.Name] = string(data)
+	}
+	return files
+}
@@ -0,0 +1,103 @@
+package diagnostics
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	runtimepprof "runtime/pprof"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const TokenMinimumLength = 32
+
+// FIXME GLOBAL VARIABLES
+var enabled atomic.Bool
+
+// SetEnabled toggles the diagnostics endpoints at runtime, the listener is
+// kept open and responds unavailable to all requests when disabled.
+func SetEnabled(on bool) {
+	enabled.Store(on)
+}
+
+func Enabled() bool {
+	return enabled.Load()
+}
+
+type handler struct {
+	token string
+	state func() any
+	mux   *http.ServeMux
+}
+
+// NewServer serves the pprof profiles, the goroutine dumps and the state
+// returned by the state function. Without a token the server only listens
+// on the loopback interface, otherwise it listens on the listen interface,
+// or all interfaces if empty, and all requests must have the header
+// Authorization: Bearer token.
+func NewServer(listen string, port int, token string, state func() any) (*http.Server, error) {
+	if token != "" && len(token) < TokenMinimumLength {
+		return nil, fmt.Errorf("invalid diagnostics token length %d", len(token))
+	}
+	if token == "" {
+		listen = "127.0.0.1"
+	}
+	addr := net.JoinHostPort(listen, strconv.Itoa(port))
+
+	h := &handler{token: token, state: state, mux: http.NewServeMux()}
+	h.mux.HandleFunc("/debug/pprof/", pprof.Index)
+	h.mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	h.mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	h.mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	h.mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	h.mux.HandleFunc("/debug/goroutines", h.goroutines)
+	h.mux.HandleFunc("/debug/state", h.dumpState)
+
+	// no write timeout because the cpu profile and trace take seconds
+	return &http.Server{
+		Addr:              addr,
+		Handler:           h,
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       120 * time.Second,
+	}, nil
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !Enabled() {
+		http.Error(w, "diagnostics disabled", http.StatusServiceUnavailable)
+		return
+	}
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *handler) authorized(r *http.Request) bool {
+	if h.token == "" {
+		return true
+	}
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) == 1
+}
+
+func (h *handler) goroutines(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	runtimepprof.Lookup("goroutine").WriteTo(w, 2)
+}
+
+func (h *handler) dumpState(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(h.state())
+}
@@ -0,0 +1,71 @@
+package diagnostics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiagnostics(t *testing.T) {
+	require := require.New(t)
+
+	_, err := NewServer("", 7870, "short", nil)
+	require.NotNil(err)
+
+	server, err := NewServer("", 7870, "", nil)
+	require.Nil(err)
+	require.Equal("127.0.0.1:7870", server.Addr)
+	server, err = NewServer("10.0.0.1", 7870, "", nil)
+	require.Nil(err)
+	require.Equal("127.0.0.1:7870", server.Addr)
+
+	token := strings.Repeat("a", TokenMinimumLength)
+	server, err = NewServer("10.0.0.1", 7870, token, nil)
+	require.Nil(err)
+	require.Equal("10.0.0.1:7870", server.Addr)
+	server, err = NewServer("", 7870, token, func() any {
+		return map[string]int{"cache_pool": 3}
+	})
+	require.Nil(err)
+	require.Equal(":7870", server.Addr)
+	ts := httptest.NewServer(server.Handler)
+	defer ts.Close()
+
+	get := func(path, token string) (int, string) {
+		req, err := http.NewRequest("GET", ts.URL+path, nil)
+		require.Nil(err)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.Nil(err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.Nil(err)
+		return resp.StatusCode, string(body)
+	}
+
+	SetEnabled(true)
+	code, _ := get("/debug/state", "")
+	require.Equal(http.StatusUnauthorized, code)
+	code, _ = get("/debug/state", strings.Repeat("b", TokenMinimumLength))
+	require.Equal(http.StatusUnauthorized, code)
+	code, body := get("/debug/state", token)
+	require.Equal(http.StatusOK, code)
+	require.Contains(body, `"cache_pool": 3`)
+	code, body = get("/debug/goroutines", token)
+	require.Equal(http.StatusOK, code)
+	require.Contains(body, "goroutine")
+	code, _ = get("/debug/pprof/heap", token)
+	require.Equal(http.StatusOK, code)
+
+	SetEnabled(false)
+	code, _ = get("/debug/state", token)
+	require.Equal(http.StatusServiceUnavailable, code)
+	code, _ = get("/debug/state", "")
+	require.Equal(http.StatusUnauthorized, code)
+}
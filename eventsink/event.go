@@ -0,0 +1,151 @@
+package eventsink
+
+import (
+	"encoding/hex"
+	"strconv"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+type Record struct {
+	Key   string
+	Value []byte
+}
+
+// the event is the finalized snapshot with its decoded transaction, in the
+// same field names as the RPC snapshots with the transactions
+type Event struct {
+	Topology     uint64         `json:"topology"`
+	Version      uint8          `json:"version"`
+	Node         crypto.Hash    `json:"node"`
+	References   *RoundLink     `json:"references"`
+	Round        uint64         `json:"round"`
+	Timestamp    uint64         `json:"timestamp"`
+	Hash         crypto.Hash    `json:"hash"`
+	Transactions []*Transaction `json:"transactions"`
+}
+
+type RoundLink struct {
+	Self     crypto.Hash `json:"self"`
+	External crypto.Hash `json:"external"`
+}
+
+type Transaction struct {
+	Version      uint8          `json:"version"`
+	Hash         crypto.Hash    `json:"hash"`
+	Asset        crypto.Hash    `json:"asset"`
+	Inputs       []*Input       `json:"inputs"`
+	Outputs      []*Output      `json:"outputs"`
+	References   []crypto.Hash  `json:"references"`
+	Extra        string         `json:"extra"`
+	ExtraSchema  string         `json:"extra_schema,omitempty"`
+	ExtraDecoded map[string]any `json:"extra_decoded,omitempty"`
+}
+
+type Input struct {
+	Hash    *crypto.Hash `json:"hash,omitempty"`
+	Index   *uint        `json:"index,omitempty"`
+	Genesis string       `json:"genesis,omitempty"`
+	Deposit *Deposit     `json:"deposit,omitempty"`
+	Mint    *Mint        `json:"mint,omitempty"`
+}
+
+type Deposit struct {
+	Chain       crypto.Hash    `json:"chain"`
+	AssetKey    string         `json:"asset_key"`
+	Transaction string         `json:"transaction"`
+	Index       uint64         `json:"index"`
+	Amount      common.Integer `json:"amount"`
+}
+
+type Mint struct {
+	Group  string         `json:"group"`
+	Batch  uint64         `json:"batch"`
+	Amount common.Integer `json:"amount"`
+}
+
+type Output struct {
+	Type       uint8          `json:"type"`
+	Amount     common.Integer `json:"amount"`
+	Keys       []*crypto.Key  `json:"keys,omitempty"`
+	Mask       *crypto.Key    `json:"mask,omitempty"`
+	Script     common.Script  `json:"script,omitempty"`
+	Withdrawal *Withdrawal    `json:"withdrawal,omitempty"`
+}
+
+type Withdrawal struct {
+	Address string `json:"address"`
+	Tag     string `json:"tag"`
+}
+
+// the topology is the record key, so the Kafka partitions and the NATS
+// message ids are decided by the topology
+func NewEvent(s *common.SnapshotWithTopologicalOrder, tx *common.VersionedTransaction) *Event {
+	e := &Event{
+		Topology:     s.TopologicalOrder,
+		Version:      s.Version,
+		Node:         s.NodeId,
+		Round:        s.RoundNumber,
+		Timestamp:    s.Timestamp,
+		Hash:         s.PayloadHash(),
+		Transactions: []*Transaction{newTransaction(tx)},
+	}
+	if r := s.References; r != nil {
+		e.References = &RoundLink{Self: r.Self, External: r.External}
+	}
+	return e
+}
+
+func (e *Event) Key() string {
+	return strconv.FormatUint(e.Topology, 10)
+}
+
+func newTransaction(tx *common.VersionedTransaction) *Transaction {
+	t := &Transaction{
+		Version:    tx.Version,
+		Hash:       tx.PayloadHash(),
+		Asset:      tx.Asset,
+		References: tx.References,
+		Extra:      hex.EncodeToString(tx.Extra),
+	}
+	for _, in := range tx.Inputs {
+		input := &Input{}
+		if in.Hash.HasValue() {
+			input.Hash, input.Index = &in.Hash, &in.Index
+		} else if len(in.Genesis) > 0 {
+			input.Genesis = hex.EncodeToString(in.Genesis)
+		} else if d := in.Deposit; d != nil {
+			input.Deposit = &Deposit{
+				Chain:       d.Chain,
+				AssetKey:    d.AssetKey,
+				Transaction: d.Transaction,
+				Index:       d.Index,
+				Amount:      d.Amount,
+			}
+		} else if m := in.Mint; m != nil {
+			input.Mint = &Mint{Group: m.Group, Batch: m.Batch, Amount: m.Amount}
+		}
+		t.Inputs = append(t.Inputs, input)
+	}
+	for _, out := range tx.Outputs {
+		output := &Output{
+			Type:   out.Type,
+			Amount: out.Amount,
+			Keys:   out.Keys,
+			Script: out.Script,
+		}
+		if out.Mask.HasValue() {
+			output.Mask = &out.Mask
+		}
+		if w := out.Withdrawal; w != nil {
+			output.Withdrawal = &Withdrawal{Address: w.Address, Tag: w.Tag}
+		}
+		t.Outputs = append(t.Outputs, output)
+	}
+	name, data, err := common.DecodeExtraSchema(tx)
+	if name != "" && err == nil {
+		t.ExtraSchema, t.ExtraDecoded = name, data
+	}
+	return t
+}
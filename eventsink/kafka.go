@@ -0,0 +1,98 @@
+package eventsink
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const kafkaContentType = "application/vnd.kafka.json.v2+json"
+
+// the Kafka publisher produces the records through the REST proxy, which
+// hashes the record key to decide the partition, so the records with the
+// same topology always go to the same partition.
+type kafkaPublisher struct {
+	endpoint string
+	client   *http.Client
+}
+
+type kafkaRecord struct {
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+type kafkaResponse struct {
+	Offsets []struct {
+		Partition int     `json:"partition"`
+		Offset    int64   `json:"offset"`
+		ErrorCode *int    `json:"error_code"`
+		Error     *string `json:"error"`
+	} `json:"offsets"`
+}
+
+// http://127.0.0.1:8082 is the REST proxy, and the records are posted to
+// http://127.0.0.1:8082/topics/mixin-snapshots for the topic mixin-snapshots
+func newKafkaPublisher(uri *url.URL, topic string) (*kafkaPublisher, error) {
+	if topic == "" || strings.ContainsAny(topic, "/ ") {
+		return nil, fmt.Errorf("invalid Kafka topic %s", topic)
+	}
+	return &kafkaPublisher{
+		endpoint: strings.TrimSuffix(uri.String(), "/") + "/topics/" + topic,
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+func (p *kafkaPublisher) Publish(records []*Record) error {
+	body := struct {
+		Records []*kafkaRecord `json:"records"`
+	}{}
+	for _, r := range records {
+		body.Records = append(body.Records, &kafkaRecord{Key: r.Key, Value: r.Value})
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", p.endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", kafkaContentType)
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	rb, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Kafka REST proxy status %d %s", resp.StatusCode, rb)
+	}
+	var kr kafkaResponse
+	err = json.Unmarshal(rb, &kr)
+	if err != nil {
+		return err
+	}
+	if len(kr.Offsets) != len(records) {
+		return fmt.Errorf("Kafka REST proxy offsets count %d %d", len(kr.Offsets), len(records))
+	}
+	for i, o := range kr.Offsets {
+		if o.ErrorCode != nil || o.Error != nil {
+			return fmt.Errorf("Kafka REST proxy record %s error %s", records[i].Key, rb)
+		}
+	}
+	return nil
+}
+
+func (p *kafkaPublisher) Close() error {
+	p.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,108 @@
+package eventsink
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/mixin/storage"
+)
+
+const (
+	KindKafka = "kafka"
+	KindNATS  = "nats"
+
+	streamBatchSize  = 100
+	streamInterval   = time.Second
+	streamRetryDelay = 5 * time.Second
+)
+
+type Publisher interface {
+	Publish(records []*Record) error
+	Close() error
+}
+
+// NewPublisher with the kind kafka posts to the REST proxy address, e.g.
+// http://127.0.0.1:8082, and with the kind nats connects to the server
+// address, e.g. nats://127.0.0.1:4222. The topic is the Kafka topic or the
+// NATS subject.
+func NewPublisher(kind, address, topic string) (Publisher, error) {
+	uri, err := url.Parse(address)
+	if err != nil {
+		return nil, err
+	}
+	switch kind {
+	case KindKafka:
+		if uri.Scheme != "http" && uri.Scheme != "https" || uri.Host == "" {
+			return nil, fmt.Errorf("invalid Kafka REST proxy address %s", address)
+		}
+		return newKafkaPublisher(uri, topic)
+	case KindNATS:
+		if uri.Scheme != "nats" || uri.Host == "" {
+			return nil, fmt.Errorf("invalid NATS address %s", address)
+		}
+		return newNATSPublisher(uri, topic)
+	default:
+		return nil, fmt.Errorf("invalid event sink kind %s", kind)
+	}
+}
+
+// the streamer publishes the finalized snapshots in the topological order,
+// and the offset is saved after each batch published, so all snapshots are
+// published at least once, even after a restart or a publisher failure.
+type Streamer struct {
+	store     storage.Store
+	publisher Publisher
+}
+
+func NewStreamer(store storage.Store, publisher Publisher) *Streamer {
+	return &Streamer{store: store, publisher: publisher}
+}
+
+// Loop waits while paused, e.g. under the memory pressure of the node, and the
+// snapshots are published later from the saved offset.
+func (s *Streamer) Loop(paused func() bool) {
+	for {
+		if paused() {
+			time.Sleep(streamInterval)
+			continue
+		}
+		n, err := s.Stream()
+		if err != nil {
+			logger.Printf("eventsink.Stream() => %v\n", err)
+			time.Sleep(streamRetryDelay)
+		} else if n < streamBatchSize {
+			time.Sleep(streamInterval)
+		}
+	}
+}
+
+// Stream publishes a batch of the snapshots after the saved offset, and
+// returns the number of snapshots published.
+func (s *Streamer) Stream() (int, error) {
+	offset, err := s.store.ReadEventSinkOffset()
+	if err != nil {
+		return 0, err
+	}
+	snapshots, transactions, err := s.store.ReadSnapshotWithTransactionsSinceTopology(offset, streamBatchSize)
+	if err != nil || len(snapshots) == 0 {
+		return 0, err
+	}
+	records := make([]*Record, len(snapshots))
+	for i, snap := range snapshots {
+		e := NewEvent(snap, transactions[i])
+		val, err := json.Marshal(e)
+		if err != nil {
+			panic(err)
+		}
+		records[i] = &Record{Key: e.Key(), Value: val}
+	}
+	err = s.publisher.Publish(records)
+	if err != nil {
+		return 0, err
+	}
+	last := snapshots[len(snapshots)-1].TopologicalOrder
+	return len(snapshots), s.store.WriteEventSinkOffset(last + 1)
+}
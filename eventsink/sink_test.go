@@ -0,0 +1,214 @@
+package eventsink
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/storage"
+	"github.com/stretchr/testify/require"
+)
+
+type testStore struct {
+	storage.Store
+	offset    uint64
+	snapshots []*common.SnapshotWithTopologicalOrder
+	txs       []*common.VersionedTransaction
+}
+
+func (s *testStore) ReadEventSinkOffset() (uint64, error) {
+	return s.offset, nil
+}
+
+func (s *testStore) WriteEventSinkOffset(offset uint64) error {
+	s.offset = offset
+	return nil
+}
+
+func (s *testStore) ReadSnapshotWithTransactionsSinceTopology(offset, count uint64) ([]*common.SnapshotWithTopologicalOrder, []*common.VersionedTransaction, error) {
+	if offset >= uint64(len(s.snapshots)) {
+		return nil, nil, nil
+	}
+	end := min(offset+count, uint64(len(s.snapshots)))
+	return s.snapshots[offset:end], s.txs[offset:end], nil
+}
+
+type testPublisher struct {
+	records []*Record
+	err     error
+}
+
+func (p *testPublisher) Publish(records []*Record) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.records = append(p.records, records...)
+	return nil
+}
+
+func (p *testPublisher) Close() error {
+	return nil
+}
+
+func TestStreamer(t *testing.T) {
+	require := require.New(t)
+
+	store := &testStore{}
+	receiver := common.NewAddressFromSeed(make([]byte, 64))
+	for i := uint64(0); i < streamBatchSize+5; i++ {
+		tx := common.NewTransactionV5(common.XINAssetId).AsVersioned()
+		tx.AddInput(crypto.Blake3Hash([]byte("genesis")), uint(i))
+		tx.AddRandomScriptOutput([]*common.Address{&receiver}, common.NewThresholdScript(1), common.NewInteger(100))
+		tx.Extra = []byte("mixin")
+		store.txs = append(store.txs, tx)
+		store.snapshots = append(store.snapshots, &common.SnapshotWithTopologicalOrder{
+			Snapshot: &common.Snapshot{
+				Version:      common.SnapshotVersionCommonEncoding,
+				NodeId:       crypto.Blake3Hash([]byte("node")),
+				References:   &common.RoundLink{},
+				RoundNumber:  i,
+				Timestamp:    i + 1,
+				Transactions: []crypto.Hash{tx.PayloadHash()},
+			},
+			TopologicalOrder: i,
+		})
+	}
+
+	publisher := &testPublisher{err: fmt.Errorf("unavailable")}
+	streamer := NewStreamer(store, publisher)
+	n, err := streamer.Stream()
+	require.NotNil(err)
+	require.Equal(0, n)
+	require.Equal(uint64(0), store.offset)
+
+	publisher.err = nil
+	n, err = streamer.Stream()
+	require.Nil(err)
+	require.Equal(streamBatchSize, n)
+	require.Equal(uint64(streamBatchSize), store.offset)
+	n, err = streamer.Stream()
+	require.Nil(err)
+	require.Equal(5, n)
+	n, err = streamer.Stream()
+	require.Nil(err)
+	require.Equal(0, n)
+	require.Len(publisher.records, streamBatchSize+5)
+
+	r := publisher.records[7]
+	require.Equal("7", r.Key)
+	var e map[string]any
+	require.Nil(json.Unmarshal(r.Value, &e))
+	require.Equal(float64(7), e["topology"])
+	require.Equal(store.snapshots[7].PayloadHash().String(), e["hash"])
+	tx := e["transactions"].([]any)[0].(map[string]any)
+	require.Equal(store.txs[7].PayloadHash().String(), tx["hash"])
+	require.Equal("6d6978696e", tx["extra"])
+	in := tx["inputs"].([]any)[0].(map[string]any)
+	require.Equal(float64(7), in["index"])
+	out := tx["outputs"].([]any)[0].(map[string]any)
+	require.Equal("100.00000000", out["amount"])
+	require.Equal("fffe01", out["script"])
+}
+
+func TestKafkaPublisher(t *testing.T) {
+	require := require.New(t)
+
+	_, err := NewPublisher(KindKafka, "nats://127.0.0.1:4222", "mixin")
+	require.NotNil(err)
+	_, err = NewPublisher("redis", "http://127.0.0.1:8082", "mixin")
+	require.NotNil(err)
+
+	fail := false
+	var body map[string][]map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal("/topics/mixin-snapshots", r.URL.Path)
+		require.Equal(kafkaContentType, r.Header.Get("Content-Type"))
+		require.Nil(json.NewDecoder(r.Body).Decode(&body))
+		if fail {
+			fmt.Fprint(w, `{"offsets":[{"partition":null,"offset":null,"error_code":50003,"error":"timeout"}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"offsets":[{"partition":1,"offset":9}]}`)
+	}))
+	defer server.Close()
+
+	publisher, err := NewPublisher(KindKafka, server.URL+"/", "mixin-snapshots")
+	require.Nil(err)
+	err = publisher.Publish([]*Record{{Key: "9", Value: []byte(`{"topology":9}`)}})
+	require.Nil(err)
+	require.Equal("9", body["records"][0]["key"])
+	require.Equal(map[string]any{"topology": float64(9)}, body["records"][0]["value"])
+
+	fail = true
+	err = publisher.Publish([]*Record{{Key: "9", Value: []byte(`{"topology":9}`)}})
+	require.NotNil(err)
+	require.Contains(err.Error(), "timeout")
+}
+
+func TestNATSPublisher(t *testing.T) {
+	require := require.New(t)
+
+	_, err := NewPublisher(KindNATS, "http://127.0.0.1:4222", "mixin")
+	require.NotNil(err)
+	_, err = NewPublisher(KindNATS, "nats://127.0.0.1:4222", "mixin snapshots")
+	require.NotNil(err)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(err)
+	defer l.Close()
+	lines := make(chan string, 16)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		fmt.Fprint(conn, "INFO {\"server_id\":\"test\",\"headers\":true}\r\n")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimSpace(line)
+			switch {
+			case line == "PING":
+				fmt.Fprint(conn, "PONG\r\n")
+			case strings.HasPrefix(line, "HPUB "):
+				var subject string
+				var hl, tl int
+				fmt.Sscanf(line, "HPUB %s %d %d", &subject, &hl, &tl)
+				payload := make([]byte, tl+2)
+				io.ReadFull(r, payload)
+				line = fmt.Sprintf("%s %q", subject, payload[:tl])
+			}
+			lines <- line
+		}
+	}()
+
+	publisher, err := NewPublisher(KindNATS, "nats://user:pass@"+l.Addr().String(), "mixin.snapshots")
+	require.Nil(err)
+	defer publisher.Close()
+	err = publisher.Publish([]*Record{
+		{Key: "1", Value: []byte(`{"topology":1}`)},
+		{Key: "2", Value: []byte(`{"topology":2}`)},
+	})
+	require.Nil(err)
+
+	connect := <-lines
+	require.True(strings.HasPrefix(connect, "CONNECT "))
+	require.Contains(connect, `"user":"user"`)
+	require.Contains(connect, `"headers":true`)
+	require.Equal("PING", <-lines)
+	require.Equal(`mixin.snapshots "NATS/1.0\r\nNats-Msg-Id: 1\r\n\r\n{\"topology\":1}"`, <-lines)
+	require.Equal(`mixin.snapshots "NATS/1.0\r\nNats-Msg-Id: 2\r\n\r\n{\"topology\":2}"`, <-lines)
+	require.Equal("PING", <-lines)
+}
@@ -0,0 +1,169 @@
+package finality
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"golang.org/x/crypto/sha3"
+)
+
+const (
+	// the keys array is the only dynamic field of the proof, so its offset
+	// is always right after the static head
+	proofHeadWords = 12
+	proofWordSize  = 32
+	proofKeysLimit = 64
+)
+
+// Proof is the finality of a snapshot in a layout for the EVM contracts, it
+// is abi.encode of the tuple
+//
+//	(bytes32 snapshot, uint8 version, bytes32 node, uint64 round,
+//	 bytes32 self, bytes32 external, bytes32 transaction, uint64 timestamp,
+//	 uint64 mask, bytes32 r, bytes32 s, bytes32[] keys)
+//
+// the keys are all the consensus keys in the order of the signature mask, and
+// the contract checks them against its keccak256(abi.encodePacked(keys))
+// commitment instead of storing the keys.
+type Proof struct {
+	Snapshot    crypto.Hash
+	Version     uint8
+	NodeId      crypto.Hash
+	RoundNumber uint64
+	References  *common.RoundLink
+	Transaction crypto.Hash
+	Timestamp   uint64
+	Signature   *crypto.CosiSignature
+	Keys        []*crypto.Key
+}
+
+func NewProof(s *common.Snapshot, keys []*crypto.Key) (*Proof, error) {
+	if s.Version != common.SnapshotVersionCommonEncoding {
+		return nil, fmt.Errorf("invalid snapshot version %d", s.Version)
+	}
+	if s.Signature == nil || s.References == nil {
+		return nil, fmt.Errorf("snapshot %s not finalized by cosi", s.Hash)
+	}
+	if len(s.Transactions) != 1 {
+		return nil, fmt.Errorf("invalid snapshot transactions count %d", len(s.Transactions))
+	}
+	if len(keys) == 0 || len(keys) > proofKeysLimit {
+		return nil, fmt.Errorf("invalid finality keys count %d", len(keys))
+	}
+	return &Proof{
+		Snapshot:    s.PayloadHash(),
+		Version:     s.Version,
+		NodeId:      s.NodeId,
+		RoundNumber: s.RoundNumber,
+		References:  s.References,
+		Transaction: s.Transactions[0],
+		Timestamp:   s.Timestamp,
+		Signature:   s.Signature,
+		Keys:        keys,
+	}, nil
+}
+
+func (p *Proof) Encode() []byte {
+	b := make([]byte, 0, (proofHeadWords+1+len(p.Keys))*proofWordSize)
+	b = append(b, p.Snapshot[:]...)
+	b = appendUint64Word(b, uint64(p.Version))
+	b = append(b, p.NodeId[:]...)
+	b = appendUint64Word(b, p.RoundNumber)
+	b = append(b, p.References.Self[:]...)
+	b = append(b, p.References.External[:]...)
+	b = append(b, p.Transaction[:]...)
+	b = appendUint64Word(b, p.Timestamp)
+	b = appendUint64Word(b, p.Signature.Mask)
+	b = append(b, p.Signature.Signature[:]...)
+	b = appendUint64Word(b, proofHeadWords*proofWordSize)
+	b = appendUint64Word(b, uint64(len(p.Keys)))
+	for _, k := range p.Keys {
+		b = append(b, k[:]...)
+	}
+	return b
+}
+
+// DecodeProof is strict as the contract should be, all the words must be
+// canonical and there must be no trailing bytes.
+func DecodeProof(b []byte) (*Proof, error) {
+	if len(b) < (proofHeadWords+1)*proofWordSize || len(b)%proofWordSize != 0 {
+		return nil, fmt.Errorf("invalid finality proof size %d", len(b))
+	}
+	words := make([][]byte, len(b)/proofWordSize)
+	for i := range words {
+		words[i] = b[i*proofWordSize : (i+1)*proofWordSize]
+	}
+
+	var err error
+	p := &Proof{References: &common.RoundLink{}, Signature: &crypto.CosiSignature{}}
+	copy(p.Snapshot[:], words[0])
+	version, err := readUint64Word(words[1])
+	if err != nil || version > 0xff {
+		return nil, fmt.Errorf("invalid finality proof version %x", words[1])
+	}
+	p.Version = uint8(version)
+	copy(p.NodeId[:], words[2])
+	p.RoundNumber, err = readUint64Word(words[3])
+	if err != nil {
+		return nil, err
+	}
+	copy(p.References.Self[:], words[4])
+	copy(p.References.External[:], words[5])
+	copy(p.Transaction[:], words[6])
+	p.Timestamp, err = readUint64Word(words[7])
+	if err != nil {
+		return nil, err
+	}
+	p.Signature.Mask, err = readUint64Word(words[8])
+	if err != nil {
+		return nil, err
+	}
+	copy(p.Signature.Signature[:32], words[9])
+	copy(p.Signature.Signature[32:], words[10])
+
+	offset, err := readUint64Word(words[11])
+	if err != nil || offset != proofHeadWords*proofWordSize {
+		return nil, fmt.Errorf("invalid finality proof keys offset %x", words[11])
+	}
+	count, err := readUint64Word(words[proofHeadWords])
+	if err != nil || count == 0 || count > proofKeysLimit {
+		return nil, fmt.Errorf("invalid finality proof keys count %x", words[proofHeadWords])
+	}
+	if int(count) != len(words)-proofHeadWords-1 {
+		return nil, fmt.Errorf("invalid finality proof keys count %d/%d", count, len(words)-proofHeadWords-1)
+	}
+	for _, w := range words[proofHeadWords+1:] {
+		var k crypto.Key
+		copy(k[:], w)
+		p.Keys = append(p.Keys, &k)
+	}
+	return p, nil
+}
+
+// KeysCommitment is keccak256(abi.encodePacked(keys)), which the contract
+// stores for each set of consensus keys.
+func KeysCommitment(keys []*crypto.Key) crypto.Hash {
+	h := sha3.NewLegacyKeccak256()
+	for _, k := range keys {
+		h.Write(k[:])
+	}
+	var commitment crypto.Hash
+	copy(commitment[:], h.Sum(nil))
+	return commitment
+}
+
+func appendUint64Word(b []byte, v uint64) []byte {
+	b = append(b, make([]byte, proofWordSize-8)...)
+	return binary.BigEndian.AppendUint64(b, v)
+}
+
+func readUint64Word(w []byte) (uint64, error) {
+	for _, c := range w[:proofWordSize-8] {
+		if c != 0 {
+			return 0, fmt.Errorf("invalid finality proof uint64 word %x", w)
+		}
+	}
+	return binary.BigEndian.Uint64(w[proofWordSize-8:]), nil
+}
@@ -0,0 +1,101 @@
+package finality
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProof(t *testing.T) {
+	require := require.New(t)
+
+	keys := make([]*crypto.Key, 7)
+	publics := make([]*crypto.Key, len(keys))
+	for i := range keys {
+		seed := crypto.Blake3Hash([]byte(fmt.Sprintf("finality%d", i)))
+		priv := crypto.NewKeyFromSeed(append(seed[:], seed[:]...))
+		pub := priv.Public()
+		keys[i] = &priv
+		publics[i] = &pub
+	}
+
+	s := &common.Snapshot{
+		Version:     common.SnapshotVersionCommonEncoding,
+		NodeId:      crypto.Blake3Hash([]byte("node")),
+		RoundNumber: 123,
+		References: &common.RoundLink{
+			Self:     crypto.Blake3Hash([]byte("self")),
+			External: crypto.Blake3Hash([]byte("external")),
+		},
+		Timestamp: 1700000000000000000,
+	}
+	s.AddSoleTransaction(crypto.Blake3Hash([]byte("transaction")))
+	s.Hash = s.PayloadHash()
+
+	_, err := NewProof(s, publics)
+	require.ErrorContains(err, "not finalized by cosi")
+
+	signers := []int{0, 2, 3, 5, 6}
+	randoms := make(map[int]*crypto.Key)
+	nonces := make(map[int]*crypto.Key)
+	for _, i := range signers {
+		r := crypto.CosiCommit(crypto.RandReader())
+		R := r.Public()
+		nonces[i] = r
+		randoms[i] = &R
+	}
+	cosi, err := crypto.CosiAggregateCommitment(randoms)
+	require.Nil(err)
+	responses := make(map[int]*[32]byte)
+	for _, i := range signers {
+		r, err := cosi.Response(keys[i], nonces[i], publics, s.Hash)
+		require.Nil(err)
+		responses[i] = r
+	}
+	require.Nil(cosi.AggregateResponse(publics, responses, s.Hash, true))
+	s.Signature = cosi
+
+	p, err := NewProof(s, publics)
+	require.Nil(err)
+	b := p.Encode()
+	require.Len(b, (proofHeadWords+1+len(publics))*proofWordSize)
+	require.Equal(s.Hash[:], b[:32])
+	require.Equal(uint8(0x6d), b[8*32+31])
+
+	commitment := KeysCommitment(publics)
+	v, err := Verify(b, commitment, 5)
+	require.Nil(err)
+	require.Equal(s.Hash, v.Snapshot)
+	require.Equal(s.Transactions[0], v.Transaction)
+	require.Equal(s.Timestamp, v.Timestamp)
+	require.Equal(p.Encode(), v.Encode())
+
+	_, err = Verify(b, commitment, 6)
+	require.ErrorContains(err, "invalid finality proof signers 5/6")
+	_, err = Verify(b, KeysCommitment(publics[1:]), 5)
+	require.ErrorContains(err, "invalid finality proof keys commitment")
+	_, err = Verify(append(b, make([]byte, 32)...), commitment, 5)
+	require.ErrorContains(err, "invalid finality proof keys count 7/8")
+	_, err = Verify(b[:len(b)-32], commitment, 5)
+	require.ErrorContains(err, "invalid finality proof keys count 7/6")
+
+	forged := append([]byte{}, b...)
+	forged[7*32+31] ^= 1
+	_, err = Verify(forged, commitment, 5)
+	require.ErrorContains(err, "invalid finality proof snapshot")
+	forged = append([]byte{}, b...)
+	forged[7*32] = 1
+	_, err = Verify(forged, commitment, 5)
+	require.ErrorContains(err, "invalid finality proof uint64 word")
+	forged = append([]byte{}, b...)
+	forged[8*32+31] = 0xff
+	_, err = Verify(forged, commitment, 5)
+	require.ErrorContains(err, "invalid finality proof mask 00000000000000ff/7")
+	forged = append([]byte{}, b...)
+	forged[8*32+31] ^= 0x02
+	_, err = Verify(forged, commitment, 5)
+	require.ErrorContains(err, "invalid finality proof signature")
+}
@@ -0,0 +1,56 @@
+package finality
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+// Verify mirrors the contract step by step, so the contract could be tested
+// against the same proofs. the commitment and threshold are the state of the
+// contract for the consensus keys when the snapshot is finalized.
+func Verify(b []byte, commitment crypto.Hash, threshold int) (*Proof, error) {
+	p, err := DecodeProof(b)
+	if err != nil {
+		return nil, err
+	}
+	if KeysCommitment(p.Keys) != commitment {
+		return nil, fmt.Errorf("invalid finality proof keys commitment %s", commitment)
+	}
+
+	mask := p.Signature.Mask
+	if mask == 0 || mask>>len(p.Keys) != 0 {
+		return nil, fmt.Errorf("invalid finality proof mask %016x/%d", mask, len(p.Keys))
+	}
+	if threshold <= 0 || bits.OnesCount64(mask) < threshold {
+		return nil, fmt.Errorf("invalid finality proof signers %d/%d", bits.OnesCount64(mask), threshold)
+	}
+
+	if crypto.Blake3Hash(proofPayload(p)) != p.Snapshot {
+		return nil, fmt.Errorf("invalid finality proof snapshot %s", p.Snapshot)
+	}
+	err = crypto.AggregateVerify(&p.Signature.Signature, p.Keys, p.Signature.Keys(), p.Snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("invalid finality proof signature %v", err)
+	}
+	return p, nil
+}
+
+// proofPayload rebuilds the snapshot payload from the fixed size fields, the
+// layout is the common encoding of a signed snapshot with one transaction
+// and the signature excluded.
+func proofPayload(p *Proof) []byte {
+	b := make([]byte, 0, 160)
+	b = append(b, 0x77, 0x77, 0x00, p.Version)
+	b = append(b, p.NodeId[:]...)
+	b = binary.BigEndian.AppendUint64(b, p.RoundNumber)
+	b = binary.BigEndian.AppendUint16(b, 2)
+	b = append(b, p.References.Self[:]...)
+	b = append(b, p.References.External[:]...)
+	b = binary.BigEndian.AppendUint16(b, 1)
+	b = append(b, p.Transaction[:]...)
+	b = binary.BigEndian.AppendUint64(b, p.Timestamp)
+	return binary.BigEndian.AppendUint64(b, 0)
+}
@@ -0,0 +1,199 @@
+package kernel
+
+import (
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/kernel/internal/clock"
+	"github.com/MixinNetwork/mixin/logger"
+)
+
+const (
+	ElectionAnomalyAcceptMissed = 1
+	ElectionAnomalyRemoveOrder  = 2
+
+	electionWarningPayloadSize = 1 + 32 + 32 + 8 + 32
+	electionWarningSize        = electionWarningPayloadSize + 64
+	electionWarningsLimit      = 1024
+)
+
+// ElectionWarning is a signed report of an election event deviating from the
+// deterministic rules, i.e. the pledging node not accepted in the accept
+// window, or the removed node not the earliest accepted one, which are
+// possible only with bugs or forks, and required manual forensics before.
+type ElectionWarning struct {
+	Kind uint8
+	// the pledging node and the pledge timestamp, or the removed node and
+	// the removal timestamp, with the node expected to be removed
+	Node      crypto.Hash
+	Expected  crypto.Hash
+	Timestamp uint64
+	Reporter  crypto.Hash
+	Signature crypto.Signature
+}
+
+type electionWarnings struct {
+	sync.RWMutex
+	list []*ElectionWarning
+}
+
+func (w *ElectionWarning) KindName() string {
+	switch w.Kind {
+	case ElectionAnomalyAcceptMissed:
+		return "accept-missed"
+	case ElectionAnomalyRemoveOrder:
+		return "remove-order"
+	}
+	return fmt.Sprintf("unknown-%d", w.Kind)
+}
+
+func (w *ElectionWarning) payload() []byte {
+	data := []byte{w.Kind}
+	data = append(data, w.Node[:]...)
+	data = append(data, w.Expected[:]...)
+	data = binary.BigEndian.AppendUint64(data, w.Timestamp)
+	return append(data, w.Reporter[:]...)
+}
+
+func (w *ElectionWarning) Marshal() []byte {
+	return append(w.payload(), w.Signature[:]...)
+}
+
+func UnmarshalElectionWarning(data []byte) (*ElectionWarning, error) {
+	if len(data) != electionWarningSize {
+		return nil, fmt.Errorf("invalid election warning size %d", len(data))
+	}
+	w := &ElectionWarning{Kind: data[0]}
+	copy(w.Node[:], data[1:33])
+	copy(w.Expected[:], data[33:65])
+	w.Timestamp = binary.BigEndian.Uint64(data[65:73])
+	copy(w.Reporter[:], data[73:105])
+	copy(w.Signature[:], data[105:])
+	return w, nil
+}
+
+// the same event reported by different nodes share the same event id
+func (w *ElectionWarning) event() crypto.Hash {
+	return crypto.Blake3Hash(w.payload()[:73])
+}
+
+func (node *Node) ElectionWarnings() []*ElectionWarning {
+	node.warnings.RLock()
+	defer node.warnings.RUnlock()
+	return slices.Clone(node.warnings.list)
+}
+
+func (node *Node) hasElectionWarning(w *ElectionWarning) bool {
+	node.warnings.RLock()
+	defer node.warnings.RUnlock()
+	return slices.ContainsFunc(node.warnings.list, func(o *ElectionWarning) bool {
+		return o.Reporter == w.Reporter && o.event() == w.event()
+	})
+}
+
+func (node *Node) addElectionWarning(w *ElectionWarning) bool {
+	node.warnings.Lock()
+	defer node.warnings.Unlock()
+	for _, o := range node.warnings.list {
+		if o.Reporter == w.Reporter && o.event() == w.event() {
+			return false
+		}
+	}
+	node.warnings.list = append(node.warnings.list, w)
+	if len(node.warnings.list) > electionWarningsLimit {
+		node.warnings.list = node.warnings.list[1:]
+	}
+	return true
+}
+
+// detectElectionAnomalies checks the pledging node and the removals in the
+// accept period maximum before the timestamp, the older removals are ignored
+// because the removal rules were different in the history.
+func (node *Node) detectElectionAnomalies(timestamp uint64) []*ElectionWarning {
+	var warnings []*ElectionWarning
+	period := uint64(config.KernelNodeAcceptPeriodMaximum)
+	if p := node.PledgingNode(timestamp); p != nil && timestamp > p.Timestamp+period {
+		warnings = append(warnings, &ElectionWarning{
+			Kind:      ElectionAnomalyAcceptMissed,
+			Node:      p.IdForNetwork,
+			Timestamp: p.Timestamp,
+		})
+	}
+
+	for _, cn := range node.allNodesSortedWithState {
+		if cn.State != common.NodeStateRemoved || cn.Timestamp+period < timestamp || cn.Timestamp > timestamp {
+			continue
+		}
+		var expected crypto.Hash
+		for _, n := range node.NodesListWithoutState(cn.Timestamp, false) {
+			if n.State == common.NodeStateAccepted {
+				expected = n.IdForNetwork
+				break
+			}
+		}
+		if expected == cn.IdForNetwork {
+			continue
+		}
+		warnings = append(warnings, &ElectionWarning{
+			Kind:      ElectionAnomalyRemoveOrder,
+			Node:      cn.IdForNetwork,
+			Expected:  expected,
+			Timestamp: cn.Timestamp,
+		})
+	}
+	return warnings
+}
+
+func (node *Node) reportElectionAnomalies() {
+	now := uint64(clock.Now().UnixNano())
+	for _, w := range node.detectElectionAnomalies(now) {
+		w.Reporter = node.IdForNetwork
+		if node.hasElectionWarning(w) {
+			continue
+		}
+		sig, err := node.SignData(w.payload())
+		if err != nil {
+			logger.Printf("reportElectionAnomalies(%s) => %v\n", w.Node, err)
+			continue
+		}
+		w.Signature = sig
+		node.addElectionWarning(w)
+		logger.Printw("Election anomaly detected", "alert", "election", "kind", w.KindName(),
+			"node", w.Node.String(), "expected", w.Expected.String(), "timestamp", w.Timestamp)
+		data := w.Marshal()
+		for _, cn := range node.NodesListWithoutState(now, true) {
+			err := node.Peer.SendElectionWarningMessage(cn.IdForNetwork, data)
+			if err != nil {
+				logger.Verbosef("SendElectionWarningMessage(%s) => %v\n", cn.IdForNetwork, err)
+			}
+		}
+	}
+}
+
+func (node *Node) ReceiveElectionWarning(peerId crypto.Hash, data []byte) error {
+	w, err := UnmarshalElectionWarning(data)
+	if err != nil {
+		return err
+	}
+	if w.Reporter != peerId {
+		return fmt.Errorf("invalid election warning reporter %s %s", w.Reporter, peerId)
+	}
+	reporter := node.GetAcceptedOrPledgingNode(w.Reporter)
+	if reporter == nil {
+		return fmt.Errorf("unknown election warning reporter %s", w.Reporter)
+	}
+	if !reporter.Signer.PublicSpendKey.Verify(crypto.Blake3Hash(w.payload()), w.Signature) {
+		return fmt.Errorf("invalid election warning signature %s", w.Reporter)
+	}
+	if node.addElectionWarning(w) {
+		logger.Printw("Election anomaly reported", "alert", "election", "kind", w.KindName(),
+			"node", w.Node.String(), "expected", w.Expected.String(), "timestamp", w.Timestamp,
+			"reporter", w.Reporter.String())
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package kernel
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestElectionAnomalies(t *testing.T) {
+	require := require.New(t)
+
+	epoch := uint64(time.Now().Add(-time.Hour * 24 * 30).UnixNano())
+	node := &Node{Epoch: epoch, genesisNodesMap: make(map[crypto.Hash]bool)}
+	var cnodes []*CNode
+	for i := range 7 {
+		signer := testPledgeSigner(fmt.Sprintf("genesis-%d", i))
+		id := signer.Hash().ForNetwork(node.networkId)
+		node.genesisNodesMap[id] = true
+		cnodes = append(cnodes, &CNode{IdForNetwork: id, Signer: signer, Timestamp: epoch + uint64(i), State: common.NodeStateAccepted})
+	}
+	setNodes := func(cnodes []*CNode) {
+		node.allNodesSortedWithState = cnodes
+		node.nodeStateSequences = node.buildNodeStateSequences(cnodes, false)
+		node.acceptedNodeStateSequences = node.buildNodeStateSequences(cnodes, true)
+	}
+
+	removed := epoch + uint64(time.Hour*24)
+	inorder := &CNode{IdForNetwork: cnodes[0].IdForNetwork, Timestamp: removed, State: common.NodeStateRemoved}
+	setNodes(append(cnodes, inorder))
+	require.Len(node.detectElectionAnomalies(removed+1), 0)
+
+	outorder := &CNode{IdForNetwork: cnodes[3].IdForNetwork, Timestamp: removed, State: common.NodeStateRemoved}
+	setNodes(append(cnodes, outorder))
+	warnings := node.detectElectionAnomalies(removed + 1)
+	require.Len(warnings, 1)
+	require.Equal(uint8(ElectionAnomalyRemoveOrder), warnings[0].Kind)
+	require.Equal("remove-order", warnings[0].KindName())
+	require.Equal(cnodes[3].IdForNetwork, warnings[0].Node)
+	require.Equal(cnodes[0].IdForNetwork, warnings[0].Expected)
+	require.Equal(removed, warnings[0].Timestamp)
+	require.Len(node.detectElectionAnomalies(removed+uint64(config.KernelNodeAcceptPeriodMaximum)+1), 0)
+
+	pledged := removed + uint64(time.Hour*24)
+	pledging := &CNode{IdForNetwork: crypto.Blake3Hash([]byte("pledging")), Timestamp: pledged, State: common.NodeStatePledging}
+	setNodes(append(cnodes, pledging))
+	require.Len(node.detectElectionAnomalies(pledged+uint64(config.KernelNodeAcceptPeriodMaximum)), 0)
+	warnings = node.detectElectionAnomalies(pledged + uint64(config.KernelNodeAcceptPeriodMaximum) + 1)
+	require.Len(warnings, 1)
+	require.Equal(uint8(ElectionAnomalyAcceptMissed), warnings[0].Kind)
+	require.Equal(pledging.IdForNetwork, warnings[0].Node)
+	require.Equal(pledged, warnings[0].Timestamp)
+
+	reporter := cnodes[1]
+	w := warnings[0]
+	w.Reporter = reporter.IdForNetwork
+	w.Signature = reporter.Signer.PrivateSpendKey.Sign(crypto.Blake3Hash(w.payload()))
+	data := w.Marshal()
+	require.Len(data, electionWarningSize)
+	dw, err := UnmarshalElectionWarning(data)
+	require.Nil(err)
+	require.Equal(w, dw)
+
+	err = node.ReceiveElectionWarning(cnodes[2].IdForNetwork, data)
+	require.ErrorContains(err, "invalid election warning reporter")
+	err = node.ReceiveElectionWarning(reporter.IdForNetwork, data)
+	require.Nil(err)
+	err = node.ReceiveElectionWarning(reporter.IdForNetwork, data)
+	require.Nil(err)
+	require.Len(node.ElectionWarnings(), 1)
+
+	data[len(data)-1] ^= 0xff
+	err = node.ReceiveElectionWarning(reporter.IdForNetwork, data)
+	require.ErrorContains(err, "invalid election warning signature")
+	_, err = UnmarshalElectionWarning(data[1:])
+	require.ErrorContains(err, "invalid election warning size")
+}
@@ -0,0 +1,35 @@
+package kernel
+
+import (
+	"fmt"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/kernel/internal/clock"
+)
+
+// Attest signs a fresh statement of the node with the final round of its own
+// chain, the round is zero before the chain has any final round. The nonce
+// from the verifier makes the statement impossible to prepare in advance.
+func (node *Node) Attest(nonce []byte) (*common.NodeAttestation, error) {
+	if len(nonce) > common.AttestationNonceMax {
+		return nil, fmt.Errorf("invalid attestation nonce size %d", len(nonce))
+	}
+	a := &common.NodeAttestation{
+		NetworkId: node.networkId,
+		NodeId:    node.IdForNetwork,
+		Signer:    node.Signer.PublicSpendKey,
+		Timestamp: uint64(clock.Now().UnixNano()),
+		Nonce:     nonce,
+	}
+	for _, p := range node.BuildGraph() {
+		if p.NodeId == node.IdForNetwork {
+			a.Round, a.RoundHash = p.Number, p.Hash
+		}
+	}
+	sig, err := node.signer.Sign(a.PayloadHash())
+	if err != nil {
+		return nil, err
+	}
+	a.Signature = sig
+	return a, nil
+}
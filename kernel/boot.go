@@ -3,6 +3,7 @@ package kernel
 import (
 	"time"
 
+	"github.com/MixinNetwork/mixin/kernel/internal"
 	"github.com/MixinNetwork/mixin/kernel/internal/clock"
 )
 
@@ -15,7 +16,13 @@ func (node *Node) Loop() error {
 	go node.listenConsumers()
 	go node.sendGraphToConcensusNodesAndPeers()
 	go node.loopCacheQueue()
+	go node.loopPrevalidateSnapshots()
+	node.resumeSyncFrontiers()
 	go node.MintLoop()
+	go node.PartitionLoop()
+	go node.SyncStatusLoop()
+	go node.MemoryLoop()
+	go node.InvariantLoop()
 	node.ElectionLoop()
 	return nil
 }
@@ -23,8 +30,13 @@ func (node *Node) Loop() error {
 func (node *Node) Teardown() {
 	close(node.done)
 	<-node.cqc
+	<-node.pvc
 	<-node.mlc
 	<-node.elc
+	<-node.plc
+	<-node.ssc
+	<-node.mwc
+	<-node.ivc
 	node.chains.RLock()
 	for _, c := range node.chains.m {
 		c.Teardown()
@@ -37,6 +49,14 @@ func (node *Node) Teardown() {
 
 func TestMockReset() {
 	clock.Reset()
+	internal.ResetRand()
+}
+
+// TestMockRand makes the cosi nonces and the ghost keys of all the nodes in
+// the process follow the seed, until the mock reset. It is only effective in
+// the builds with the simulation tag.
+func TestMockRand(seed int64) {
+	internal.SeedRand(seed)
 }
 
 func TestMockDiff(at time.Duration) {
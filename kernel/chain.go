@@ -52,8 +52,8 @@ type Chain struct {
 
 	State *ChainState
 
-	CosiRandoms        map[crypto.Key]*crypto.Key
-	UsedRandoms        map[crypto.Hash]*crypto.Key
+	CosiRandoms        map[crypto.Key]*crypto.CosiNonce
+	UsedRandoms        map[crypto.Hash]*crypto.CosiNonce
 	CosiCommitments    map[crypto.Hash][]*crypto.Key
 	UsedCommitments    map[crypto.Key]bool
 	ComitmentsSentTime time.Time
@@ -68,6 +68,8 @@ type Chain struct {
 
 	persistStore     storage.Store
 	finalActionsRing ActionBuffer
+	reorder          *reorderBuffer
+	counters         *chainCounters
 	plc              chan struct{}
 	clc              chan struct{}
 	wlc              chan struct{}
@@ -81,8 +83,8 @@ func (node *Node) buildChain(chainId crypto.Hash) *Chain {
 	chain := &Chain{
 		node:               node,
 		ChainId:            chainId,
-		CosiRandoms:        make(map[crypto.Key]*crypto.Key),
-		UsedRandoms:        make(map[crypto.Hash]*crypto.Key),
+		CosiRandoms:        make(map[crypto.Key]*crypto.CosiNonce),
+		UsedRandoms:        make(map[crypto.Hash]*crypto.CosiNonce),
 		CosiCommitments:    make(map[crypto.Hash][]*crypto.Key),
 		UsedCommitments:    make(map[crypto.Key]bool),
 		CosiCommunicatedAt: make(map[crypto.Hash]time.Time),
@@ -91,6 +93,8 @@ func (node *Node) buildChain(chainId crypto.Hash) *Chain {
 		CachePool:          make(chan *CosiAction, CachePoolSnapshotsLimit),
 		persistStore:       node.persistStore,
 		finalActionsRing:   make(chan *CosiAction, FinalPoolSlotsLimit),
+		reorder:            newReorderBuffer(),
+		counters:           newChainCounters(),
 		plc:                make(chan struct{}),
 		clc:                make(chan struct{}),
 		wlc:                make(chan struct{}),
@@ -127,6 +131,7 @@ func (chain *Chain) bootLoops() {
 		return
 	}
 
+	chain.restoreQueue()
 	go chain.AggregateMintWork()
 	go chain.AggregateRoundSpace()
 	go chain.QueuePollSnapshots()
@@ -149,7 +154,7 @@ func (ab ActionBuffer) Offer(m *CosiAction) error {
 	case ab <- m:
 		return nil
 	default:
-		return fmt.Errorf("full")
+		return common.Errorf(common.ErrorQueueFull, "full")
 	}
 }
 
@@ -184,6 +189,7 @@ func (chain *Chain) Teardown() {
 	<-chain.plc
 	<-chain.wlc
 	<-chain.slc
+	chain.persistQueue()
 }
 
 func (chain *Chain) IsPledging() bool {
@@ -203,17 +209,26 @@ func (chain *Chain) loadState() error {
 	}
 
 	chain.ConsensusInfo = chain.loadIdentity()
+	state, err := chain.readState()
+	if err != nil || state == nil {
+		return err
+	}
+	chain.State = state
+	return nil
+}
+
+func (chain *Chain) readState() (*ChainState, error) {
 	state := &ChainState{RoundLinks: make(map[crypto.Hash]uint64)}
 
 	cache, err := loadHeadRoundForNode(chain.persistStore, chain.ChainId)
 	if err != nil || cache == nil {
-		return err
+		return nil, err
 	}
 	state.CacheRound = cache
 
 	final, err := loadFinalRoundForNode(chain.persistStore, chain.ChainId, cache.Number-1)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	state.FinalRound = final
 	state.RoundHistory = loadRoundHistoryForNode(chain.persistStore, final)
@@ -226,13 +241,11 @@ func (chain *Chain) loadState() error {
 		}
 		link, err := chain.persistStore.ReadLink(chain.ChainId, cn.IdForNetwork)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		state.RoundLinks[cn.IdForNetwork] = link
 	}
-
-	chain.State = state
-	return nil
+	return state, nil
 }
 
 func (chain *Chain) QueuePollSnapshots() {
@@ -335,7 +348,12 @@ func (chain *Chain) ConsumeFinalActions() {
 	logger.Printf("ConsumeFinalActions(%s)\n", chain.ChainId)
 	defer close(chain.clc)
 
+	var releasedAt time.Time
 	for chain.running {
+		if time.Since(releasedAt) >= 100*time.Millisecond {
+			chain.releaseReorderBuffer()
+			releasedAt = time.Now()
+		}
 		ps := chain.finalActionsRing.Poll()
 		if ps == nil {
 			time.Sleep(100 * time.Millisecond)
@@ -376,6 +394,7 @@ func (chain *Chain) appendFinalSnapshot(peerId crypto.Hash, s *common.Snapshot)
 	if offset >= FinalPoolSlotsLimit {
 		logger.Verbosef("appendFinalSnapshot(%s, %s) pool slots full %d %d %d %d\n",
 			peerId, s.Hash, start, s.RoundNumber, chain.FinalIndex, fi)
+		chain.holdFinalSnapshot(peerId, s)
 		return false, nil
 	}
 	offset = (offset + fi) % FinalPoolSlotsLimit
@@ -428,7 +447,8 @@ func (chain *Chain) AppendFinalSnapshot(peerId crypto.Hash, s *common.Snapshot)
 	ps := &CosiAction{PeerId: peerId, Snapshot: s}
 	err := chain.finalActionsRing.Offer(ps)
 	if err != nil {
-		return fmt.Errorf("AppendFinalSnapshot(%s, %s) final actions ring full %d %d",
+		chain.markRejected(RejectQueueFull)
+		return common.Errorf(common.ErrorQueueFull, "AppendFinalSnapshot(%s, %s) final actions ring full %d %d",
 			peerId, s.Hash, s.RoundNumber, chain.FinalIndex)
 	}
 	return nil
@@ -464,6 +484,7 @@ func (chain *Chain) AppendCosiAction(m *CosiAction) error {
 
 	err := chain.CachePool.Offer(m)
 	if err != nil {
+		chain.markRejected(RejectQueueFull)
 		logger.Verbosef("AppendCosiAction(%s) %v FULL\n", chain.ChainId, m)
 	}
 	return nil
@@ -0,0 +1,69 @@
+package kernel
+
+import (
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/mixin/storage"
+)
+
+// persistQueue keeps the final snapshots of the chain not finalized yet when
+// the loops stopped, those in the final actions ring, in the final pool and
+// held by the reorder buffer, so they are queued again after the restart.
+// the pending transactions are already kept in the cache store.
+func (chain *Chain) persistQueue() {
+	var start uint64
+	if chain.State != nil {
+		start = chain.State.CacheRound.Number
+	}
+	var queued []*storage.QueuedSnapshot
+	filter := make(map[crypto.Hash]bool)
+	queue := func(peerId crypto.Hash, ps *PeerSnapshot) {
+		s := ps.Snapshot
+		if ps.finalized || s.RoundNumber < start || filter[s.Hash] {
+			return
+		}
+		filter[s.Hash] = true
+		queued = append(queued, &storage.QueuedSnapshot{PeerId: peerId, Snapshot: s})
+	}
+
+	for m := chain.finalActionsRing.Poll(); m != nil; m = chain.finalActionsRing.Poll() {
+		queue(m.PeerId, &PeerSnapshot{Snapshot: m.Snapshot})
+	}
+	for _, round := range chain.FinalPool {
+		if round == nil {
+			continue
+		}
+		for j := 0; j < round.Size; j++ {
+			ps := round.Snapshots[j]
+			queue(ps.peers[0], ps)
+		}
+	}
+	for _, m := range chain.reorder.all() {
+		queue(m.PeerId, &PeerSnapshot{Snapshot: m.Snapshot})
+	}
+
+	err := chain.persistStore.CacheWriteChainQueue(chain.ChainId, queued)
+	logger.Printf("persistQueue(%s) %d => %v\n", chain.ChainId, len(queued), err)
+}
+
+// restoreQueue is called before the loops of the chain, so the snapshots are
+// appended to the final pool directly, and the ones too far ahead are held.
+func (chain *Chain) restoreQueue() {
+	queued, err := chain.persistStore.CacheReadChainQueue(chain.ChainId)
+	if err != nil || len(queued) == 0 {
+		logger.Verbosef("restoreQueue(%s) %d => %v\n", chain.ChainId, len(queued), err)
+		return
+	}
+	for _, qs := range queued {
+		s := qs.Snapshot
+		if cs := chain.State; cs != nil && cs.CacheRound.Number > s.RoundNumber {
+			continue
+		}
+		retry, err := chain.appendFinalSnapshot(qs.PeerId, s)
+		if err != nil || retry {
+			chain.holdFinalSnapshot(qs.PeerId, s)
+		}
+	}
+	err = chain.persistStore.CacheWriteChainQueue(chain.ChainId, nil)
+	logger.Printf("restoreQueue(%s) %d => %v\n", chain.ChainId, len(queued), err)
+}
@@ -0,0 +1,76 @@
+package kernel
+
+import (
+	"os"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChainQueuePersistence(t *testing.T) {
+	require := require.New(t)
+
+	root, err := os.MkdirTemp("", "mixin-chainqueue-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	node := setupTestNode(require, root)
+	id := node.genesisNodes[0]
+	chain := node.getOrCreateChain(id)
+	start := chain.State.CacheRound.Number
+
+	snapshot := func(number uint64, i int) *common.Snapshot {
+		s := &common.Snapshot{
+			Version:     common.SnapshotVersionCommonEncoding,
+			NodeId:      id,
+			RoundNumber: number,
+			Timestamp:   uint64(i),
+		}
+		s.AddSoleTransaction(crypto.Blake3Hash([]byte{byte(i)}))
+		s.Hash = s.PayloadHash()
+		return s
+	}
+	peer := crypto.Blake3Hash([]byte("peer"))
+	require.Nil(chain.AppendFinalSnapshot(peer, snapshot(start, 0)))
+	require.Nil(chain.AppendFinalSnapshot(peer, snapshot(start+1, 1)))
+	require.Nil(chain.AppendFinalSnapshot(peer, snapshot(start-1, 2)))
+	retry, err := chain.appendFinalSnapshot(peer, snapshot(start+2, 3))
+	require.Nil(err)
+	require.False(retry)
+	retry, err = chain.appendFinalSnapshot(peer, snapshot(start+2, 3))
+	require.Nil(err)
+	require.False(retry)
+	chain.holdFinalSnapshot(peer, snapshot(start+FinalPoolSlotsLimit+1, 4))
+	chain.persistQueue()
+
+	expected := []*common.Snapshot{
+		snapshot(start, 0),
+		snapshot(start+1, 1),
+		snapshot(start+2, 3),
+		snapshot(start+FinalPoolSlotsLimit+1, 4),
+	}
+	queued, err := node.persistStore.CacheReadChainQueue(id)
+	require.Nil(err)
+	require.Len(queued, len(expected))
+	for i, qs := range queued {
+		require.Equal(peer, qs.PeerId)
+		require.Equal(expected[i].Hash, qs.Snapshot.Hash)
+		require.Equal(expected[i].RoundNumber, qs.Snapshot.RoundNumber)
+	}
+
+	restored := node.buildChain(id)
+	restored.restoreQueue()
+	require.Equal(1, restored.reorder.Len())
+	for i, s := range expected[:3] {
+		round := restored.FinalPool[(restored.FinalIndex+i)%FinalPoolSlotsLimit]
+		require.NotNil(round)
+		require.Equal(s.RoundNumber, round.Number)
+		require.Equal(1, round.Size)
+		require.Equal(s.Hash, round.Snapshots[0].Snapshot.Hash)
+	}
+	queued, err = node.persistStore.CacheReadChainQueue(id)
+	require.Nil(err)
+	require.Len(queued, 0)
+}
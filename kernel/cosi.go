@@ -9,6 +9,7 @@ import (
 	"github.com/MixinNetwork/mixin/crypto"
 	"github.com/MixinNetwork/mixin/kernel/internal/clock"
 	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/mixin/p2p"
 )
 
 const (
@@ -35,7 +36,7 @@ type CosiAction struct {
 	WantTx       bool
 	Commitments  []*crypto.Key
 	Challenge    *crypto.Key
-	random       *crypto.Key
+	random       *crypto.CosiNonce
 	finalized    bool
 	data         *CosiChainData
 }
@@ -54,12 +55,15 @@ type CosiAggregator struct {
 	FullChallenges map[crypto.Hash]bool
 	Commitments    map[int]*crypto.Key
 	Responses      map[int]*[32]byte
+
+	announcedAt time.Time
+	committedAt time.Time
 }
 
 type CosiVerifier struct {
 	Snapshot     *common.Snapshot
 	Announcement *crypto.Key
-	random       *crypto.Key
+	random       *crypto.CosiNonce
 }
 
 func (node *Node) cosiAcceptedNodesListShuffle(ts uint64) []*CNode {
@@ -77,7 +81,10 @@ func (chain *Chain) cosiHook(m *CosiAction) (bool, error) {
 	if !chain.running {
 		return false, nil
 	}
+	chain.markActive()
+	span := traceCosiAction(m)
 	err := chain.cosiHandleAction(m)
+	traceEnd(span, err)
 	if err != nil {
 		return false, err
 	}
@@ -101,6 +108,7 @@ func (chain *Chain) cosiHandleAction(m *CosiAction) error {
 		return chain.cosiAddCommitments(m)
 	}
 	if err := chain.checkActionSanity(m); err != nil {
+		chain.markRejected(RejectSanity)
 		logger.Debugf("cosiHandleAction checkActionSanity %v ERROR %s\n", m, err)
 		return nil
 	}
@@ -160,7 +168,7 @@ func (chain *Chain) checkActionSanity(m *CosiAction) error {
 		ov := chain.CosiVerifiers[s.SoleTransaction()]
 		if ov != nil && s.RoundNumber > 0 && ov.Snapshot.RoundNumber == s.RoundNumber &&
 			s.Timestamp < ov.Snapshot.Timestamp+config.SnapshotRoundGap {
-			return fmt.Errorf("a transaction %s only in one round %d of one chain %s",
+			return common.Errorf(common.ErrorInvalidSnapshot, "a transaction %s only in one round %d of one chain %s",
 				s.SoleTransaction(), s.RoundNumber, chain.ChainId)
 		}
 	case CosiActionExternalFullChallenge:
@@ -181,7 +189,7 @@ func (chain *Chain) checkActionSanity(m *CosiAction) error {
 		ov := chain.CosiVerifiers[s.SoleTransaction()]
 		if ov != nil && s.RoundNumber > 0 && ov.Snapshot.RoundNumber == s.RoundNumber &&
 			s.Timestamp < ov.Snapshot.Timestamp+config.SnapshotRoundGap {
-			return fmt.Errorf("a transaction %s only in one round %d of one chain %s",
+			return common.Errorf(common.ErrorInvalidSnapshot, "a transaction %s only in one round %d of one chain %s",
 				s.SoleTransaction(), s.RoundNumber, chain.ChainId)
 		}
 	case CosiActionExternalChallenge:
@@ -197,13 +205,13 @@ func (chain *Chain) checkActionSanity(m *CosiAction) error {
 	}
 
 	if s == nil {
-		return fmt.Errorf("no snapshot in cosi")
+		return common.Errorf(common.ErrorInvalidSnapshot, "no snapshot in cosi")
 	}
 	if s.Version != common.SnapshotVersionCommonEncoding {
-		return fmt.Errorf("invalid snapshot version %d", s.Version)
+		return common.Errorf(common.ErrorInvalidSnapshot, "invalid snapshot version %d", s.Version)
 	}
 	if s.NodeId != chain.ChainId {
-		return fmt.Errorf("invalid snapshot node id %s %s", s.NodeId, chain.ChainId)
+		return common.Errorf(common.ErrorInvalidSnapshot, "invalid snapshot node id %s %s", s.NodeId, chain.ChainId)
 	}
 
 	if m.Transaction != nil {
@@ -224,23 +232,23 @@ func (chain *Chain) checkActionSanity(m *CosiAction) error {
 		}
 		cache, final := chain.StateCopy()
 		if s.RoundNumber < cache.Number {
-			return fmt.Errorf("round stale %d %d", s.RoundNumber, cache.Number)
+			return common.Errorf(common.ErrorInvalidSnapshot, "round stale %d %d", s.RoundNumber, cache.Number)
 		}
 		if s.RoundNumber > cache.Number+1 {
-			return fmt.Errorf("round future %d %d", s.RoundNumber, cache.Number)
+			return common.Errorf(common.ErrorInvalidSnapshot, "round future %d %d", s.RoundNumber, cache.Number)
 		}
 		if s.Timestamp <= final.Start+config.SnapshotRoundGap {
-			return fmt.Errorf("round timestamp invalid %d %d", s.Timestamp, final.Start+config.SnapshotRoundGap)
+			return common.Errorf(common.ErrorInvalidTimestamp, "round timestamp invalid %d %d", s.Timestamp, final.Start+config.SnapshotRoundGap)
 		}
 		if m.SnapshotHash != s.Hash {
-			return fmt.Errorf("invalid snapshot hash %s %s", m.SnapshotHash, s.Hash)
+			return common.Errorf(common.ErrorInvalidSnapshot, "invalid snapshot hash %s %s", m.SnapshotHash, s.Hash)
 		}
 		threshold := config.SnapshotRoundGap * config.SnapshotReferenceThreshold
 		if s.Timestamp > uint64(clock.Now().UnixNano())+threshold {
-			return fmt.Errorf("future snapshot timestamp %d", s.Timestamp)
+			return common.Errorf(common.ErrorInvalidTimestamp, "future snapshot timestamp %d", s.Timestamp)
 		}
 		if s.Timestamp+threshold*2 < chain.node.GraphTimestamp {
-			return fmt.Errorf("past snapshot timestamp %d", s.Timestamp)
+			return common.Errorf(common.ErrorInvalidTimestamp, "past snapshot timestamp %d", s.Timestamp)
 		}
 	}
 
@@ -250,22 +258,22 @@ func (chain *Chain) checkActionSanity(m *CosiAction) error {
 
 	rn := chain.node.GetRemovingOrSlashingNode(m.PeerId)
 	if rn != nil {
-		return fmt.Errorf("peer node %s is removing or slashing", m.PeerId)
+		return common.Errorf(common.ErrorInvalidPeer, "peer node %s is removing or slashing", m.PeerId)
 	}
 
 	cn := chain.node.GetAcceptedOrPledgingNode(chain.ChainId)
 	if cn == nil {
-		return fmt.Errorf("chain node %s not found", chain.ChainId)
+		return common.Errorf(common.ErrorInvalidPeer, "chain node %s not found", chain.ChainId)
 	}
 	pn := chain.node.GetAcceptedOrPledgingNode(m.PeerId)
 	if pn == nil {
-		return fmt.Errorf("peer node %s not found", m.PeerId)
+		return common.Errorf(common.ErrorInvalidPeer, "peer node %s not found", m.PeerId)
 	}
 	if s.RoundNumber != 0 && !chain.node.ConsensusReady(cn, s.Timestamp) {
-		return fmt.Errorf("chain node %s not accepted", cn.IdForNetwork)
+		return common.Errorf(common.ErrorInvalidPeer, "chain node %s not accepted", cn.IdForNetwork)
 	}
 	if s.RoundNumber != 0 && !chain.node.ConsensusReady(pn, s.Timestamp) {
-		return fmt.Errorf("peer node %s not accepted", pn.IdForNetwork)
+		return common.Errorf(common.ErrorInvalidPeer, "peer node %s not accepted", pn.IdForNetwork)
 	}
 
 	tx, finalized, err := chain.node.validateSnapshotTransaction(s, false)
@@ -273,7 +281,7 @@ func (chain *Chain) checkActionSanity(m *CosiAction) error {
 		return fmt.Errorf("cosi snapshot transaction error %v or finalized %v", err, finalized)
 	}
 	if m.Action != CosiActionExternalAnnouncement && tx == nil {
-		return fmt.Errorf("no transaction found")
+		return common.Errorf(common.ErrorInvalidSnapshot, "no transaction found")
 	}
 
 	m.data = &CosiChainData{PN: pn, CN: cn, TX: tx, F: finalized}
@@ -317,15 +325,18 @@ func (chain *Chain) prepareAnnouncement(m *CosiAction) (bool, error) {
 			return false, chain.clearAndQueueSnapshotOrPanic(s)
 		}
 	} else if start, _ := cache.Gap(); s.Timestamp >= start+config.SnapshotRoundGap {
+		references := &common.RoundLink{Self: cache.asFinal().Hash, External: cache.References.External}
 		best := chain.determineBestRound(s.Timestamp)
-		if best == nil {
+		if best == nil && !chain.node.devMode {
 			logger.Verbosef("cosiSendAnnouncement no best available\n")
 			return false, chain.clearAndQueueSnapshotOrPanic(s)
 		}
-		if best.NodeId == final.NodeId {
+		if best != nil && best.NodeId == final.NodeId {
 			panic("should never be here")
 		}
-		references := &common.RoundLink{Self: cache.asFinal().Hash, External: best.Hash}
+		if best != nil {
+			references.External = best.Hash
+		}
 		nc, nf, _, err := chain.startNewRoundAndPersist(cache, references, s.Timestamp, false)
 		if err != nil || nf == nil {
 			logger.Verbosef("cosiSendAnnouncement %s %v startNewRoundAndPersist %v %v\n",
@@ -366,13 +377,15 @@ func (chain *Chain) cosiSendAnnouncement(m *CosiAction) error {
 	ov := chain.CosiVerifiers[s.SoleTransaction()]
 	if ov != nil && s.RoundNumber > 0 && ov.Snapshot.RoundNumber == s.RoundNumber &&
 		s.Timestamp < ov.Snapshot.Timestamp+config.SnapshotRoundGap {
-		err := fmt.Errorf("a transaction %s only in one round %d of one chain %s",
+		err := common.Errorf(common.ErrorInvalidSnapshot, "a transaction %s only in one round %d of one chain %s",
 			s.SoleTransaction(), s.RoundNumber, chain.ChainId)
 		logger.Verbosef("cosiSendAnnouncement ERROR %s\n", err)
 		return nil
 	}
 
 	s.Hash = s.PayloadHash()
+	span := traceSnapshot("cosi.announcement", s.Hash, s, chain.ChainId)
+	defer span.End()
 	agg := &CosiAggregator{
 		Snapshot:       s,
 		Transaction:    cd.TX,
@@ -380,14 +393,23 @@ func (chain *Chain) cosiSendAnnouncement(m *CosiAction) error {
 		FullChallenges: make(map[crypto.Hash]bool),
 		Commitments:    make(map[int]*crypto.Key),
 		Responses:      make(map[int]*[32]byte),
+		announcedAt:    clock.Now(),
 	}
 
-	v := &CosiVerifier{Snapshot: s, random: crypto.CosiCommit(crypto.RandReader())}
-	R := v.random.Public()
+	nonces, err := chain.node.signer.CosiCommit(1)
+	if err != nil {
+		logger.Verbosef("cosiSendAnnouncement CosiCommit ERROR %s\n", err)
+		return nil
+	}
+	v := &CosiVerifier{Snapshot: s, random: nonces[0]}
+	R := v.random.Commitment
 	chain.CosiVerifiers[s.Hash] = v
 	chain.CosiVerifiers[s.SoleTransaction()] = v
 	agg.Commitments[cd.CN.ConsensusIndex] = &R
 	chain.CosiAggregators[s.Hash] = agg
+	if chain.node.devMode && len(agg.Commitments) >= chain.node.ConsensusThreshold(s.Timestamp, false) {
+		return chain.cosiFinalizeSelf(agg, cd)
+	}
 	nodes := chain.node.cosiAcceptedNodesListShuffle(s.Timestamp)
 	for _, cn := range nodes {
 		peerId := cn.IdForNetwork
@@ -396,7 +418,7 @@ func (chain *Chain) cosiSendAnnouncement(m *CosiAction) error {
 		}
 		commitment := chain.cosiPopCommitment(peerId)
 		if commitment == nil || chain.CosiCommunicatedAt[peerId].Before(clock.Now().Add(-time.Duration(config.SnapshotRoundGap)*10)) {
-			err := chain.node.Peer.SendSnapshotAnnouncementMessage(peerId, m.Snapshot, R, chain.node.Signer.PrivateSpendKey)
+			err := chain.node.Peer.SendSnapshotAnnouncementMessage(peerId, m.Snapshot, R)
 			if err != nil {
 				logger.Verbosef("cosiSendAnnouncement SendSnapshotAnnouncementMessage(%s, %s) ERROR %v\n",
 					peerId, s.Hash, err)
@@ -428,11 +450,15 @@ func (chain *Chain) cosiHandleAnnouncement(m *CosiAction) error {
 	chain.CosiCommunicatedAt[m.PeerId] = clock.Now()
 
 	s, cd := m.Snapshot, m.data
-	r := crypto.CosiCommit(crypto.RandReader())
+	nonces, err := chain.node.signer.CosiCommit(1)
+	if err != nil {
+		return err
+	}
+	r := nonces[0]
 	v := &CosiVerifier{Snapshot: s, Announcement: m.Commitment, random: r}
 	chain.CosiVerifiers[s.Hash] = v
 	chain.CosiVerifiers[s.SoleTransaction()] = v
-	err = chain.node.Peer.SendSnapshotCommitmentMessage(s.NodeId, s.Hash, r.Public(), cd.TX == nil)
+	err = chain.node.Peer.SendSnapshotCommitmentMessage(s.NodeId, s.Hash, r.Commitment, cd.TX == nil)
 	if err != nil {
 		logger.Verbosef("cosiHandleAnnouncement SendSnapshotCommitmentMessage(%s, %s) ERROR %v\n",
 			s.NodeId, s.Hash, err)
@@ -466,6 +492,8 @@ func (chain *Chain) cosiHandleCommitment(m *CosiAction) error {
 		return nil
 	}
 	logger.Verbosef("cosiHandleCommitment %v ENOUGH\n", m)
+	ann.committedAt = clock.Now()
+	observeCosiPhase(cosiPhaseCommitment, ann.announcedAt, ann.committedAt)
 
 	cosi, err := crypto.CosiAggregateCommitment(ann.Commitments)
 	if err != nil {
@@ -473,9 +501,8 @@ func (chain *Chain) cosiHandleCommitment(m *CosiAction) error {
 	}
 	s.Signature = cosi
 	v := chain.CosiVerifiers[m.SnapshotHash]
-	priv := chain.node.Signer.PrivateSpendKey
 	_, publics := chain.ConsensusKeys(s.RoundNumber, s.Timestamp)
-	response, err := cosi.Response(&priv, v.random, publics, m.SnapshotHash)
+	response, err := cosi.SignerResponse(chain.node.signer, v.random, publics, m.SnapshotHash)
 	if err != nil {
 		return err
 	}
@@ -616,8 +643,7 @@ func (chain *Chain) cosiHandleChallenge(m *CosiAction) error {
 	}
 	chain.CosiCommunicatedAt[m.PeerId] = clock.Now()
 
-	priv := chain.node.Signer.PrivateSpendKey
-	response, err := m.Signature.Response(&priv, v.random, publics, m.SnapshotHash)
+	response, err := m.Signature.SignerResponse(chain.node.signer, v.random, publics, m.SnapshotHash)
 	if err != nil {
 		logger.Verbosef("cosiHandleChallenge %v Response ERROR %s\n", m, err)
 		return err
@@ -658,17 +684,47 @@ func (chain *Chain) cosiHandleResponse(m *CosiAction) error {
 		return nil
 	}
 	logger.Verbosef("cosiHandleResponse %v ENOUGH\n", m)
+	return chain.cosiAggregateResponses(agg, cd, cids, publics, base)
+}
 
-	err = s.Signature.AggregateResponse(publics, agg.Responses, m.SnapshotHash, false)
+// cosiFinalizeSelf signs the snapshot with the commitment of the node alone,
+// only possible for the single node of a dev genesis with threshold 1.
+func (chain *Chain) cosiFinalizeSelf(agg *CosiAggregator, cd *CosiChainData) error {
+	s := agg.Snapshot
+	logger.Verbosef("cosiFinalizeSelf %s\n", s.Hash)
+	agg.committedAt = clock.Now()
+	cosi, err := crypto.CosiAggregateCommitment(agg.Commitments)
+	if err != nil {
+		return err
+	}
+	s.Signature = cosi
+	v := chain.CosiVerifiers[s.Hash]
+	cids, publics := chain.ConsensusKeys(s.RoundNumber, s.Timestamp)
+	response, err := cosi.SignerResponse(chain.node.signer, v.random, publics, s.Hash)
+	if err != nil {
+		return err
+	}
+	agg.Responses[cd.CN.ConsensusIndex] = response
+	copy(cosi.Signature[32:], response[:])
+	base := chain.node.ConsensusThreshold(s.Timestamp, false)
+	return chain.cosiAggregateResponses(agg, cd, cids, publics, base)
+}
+
+func (chain *Chain) cosiAggregateResponses(agg *CosiAggregator, cd *CosiChainData, cids []crypto.Hash, publics []*crypto.Key, base int) error {
+	s := agg.Snapshot
+	err := s.Signature.AggregateResponse(publics, agg.Responses, s.Hash, false)
 	if err != nil {
 		panic(err)
 	}
-	signers, finalized := chain.node.cacheVerifyCosi(m.SnapshotHash, s.Signature, cids, publics, base)
+	signers, finalized := chain.node.cacheVerifyCosi(s.Hash, s.Signature, cids, publics, base)
 	if !finalized {
-		logger.Verbosef("cosiHandleResponse %v AGGREGATE ERROR\n", m)
+		logger.Verbosef("cosiAggregateResponses %s AGGREGATE ERROR\n", s.Hash)
 		return nil
 	}
-	logger.Verbosef("node.cacheVerifyCosi(%s, %s) FINAL\n", chain.node.Peer.Address, m.SnapshotHash)
+	logger.Verbosef("node.cacheVerifyCosi(%s, %s) FINAL\n", chain.node.Peer.Address, s.Hash)
+	now := clock.Now()
+	observeCosiPhase(cosiPhaseResponse, agg.committedAt, now)
+	observeCosiPhase(cosiPhaseFinalization, agg.announcedAt, now)
 
 	if chain.IsPledging() && s.RoundNumber == 0 && cd.TX.TransactionType() == common.TransactionTypeNodeAccept {
 		err := chain.node.finalizeNodeAcceptSnapshot(s, signers)
@@ -681,17 +737,17 @@ func (chain *Chain) cosiHandleResponse(m *CosiAction) error {
 			panic(fmt.Sprintf("should never be here %d %d", cache.Number, s.RoundNumber))
 		}
 		if s.RoundNumber < cache.Number {
-			logger.Verbosef("cosiHandleResponse %v EXPIRE %d %d\n",
-				m, s.RoundNumber, cache.Number)
+			logger.Verbosef("cosiAggregateResponses %s EXPIRE %d %d\n",
+				s.Hash, s.RoundNumber, cache.Number)
 			return nil
 		}
 		if !s.References.Equal(cache.References) {
-			logger.Verbosef("cosiHandleResponse %v REFERENCES %v %v\n",
-				m, s.References, cache.References)
+			logger.Verbosef("cosiAggregateResponses %s REFERENCES %v %v\n",
+				s.Hash, s.References, cache.References)
 			return nil
 		}
 		if err := cache.ValidateSnapshot(s); err != nil {
-			logger.Verbosef("cosiHandleResponse %v ValidateSnapshot %s\n", m, err)
+			logger.Verbosef("cosiAggregateResponses %s ValidateSnapshot %s\n", s.Hash, err)
 			return nil
 		}
 
@@ -707,14 +763,14 @@ func (chain *Chain) cosiHandleResponse(m *CosiAction) error {
 		if agg.Responses[cn.ConsensusIndex] == nil {
 			err := chain.node.SendTransactionToPeer(id, s.SoleTransaction())
 			if err != nil {
-				logger.Verbosef("cosiHandleResponse SendTransactionToPeer(%s, %s) ERROR %v\n",
-					id, m.SnapshotHash, err)
+				logger.Verbosef("cosiAggregateResponses SendTransactionToPeer(%s, %s) ERROR %v\n",
+					id, s.Hash, err)
 			}
 		}
 		err := chain.node.Peer.SendSnapshotFinalizationMessage(id, s)
 		if err != nil {
-			logger.Verbosef("cosiHandleResponse SendSnapshotFinalizationMessage(%s, %s) ERROR %v\n",
-				id, m.SnapshotHash, err)
+			logger.Verbosef("cosiAggregateResponses SendSnapshotFinalizationMessage(%s, %s) ERROR %v\n",
+				id, s.Hash, err)
 		}
 	}
 	return chain.node.reloadConsensusState(s, cd.TX)
@@ -731,11 +787,13 @@ func (chain *Chain) prepareFinalization(m *CosiAction) (bool, error) {
 	}
 	cache := chain.State.CacheRound
 	if s.RoundNumber < cache.Number {
+		chain.markRejected(RejectExpired)
 		logger.Debugf("ERROR cosiHandleFinalization expired round %s %s %d %d\n",
 			m.PeerId, s.Hash, s.RoundNumber, cache.Number)
 		return false, nil
 	}
 	if s.RoundNumber > cache.Number+1 {
+		chain.markRejected(RejectFuture)
 		logger.Debugf("ERROR cosiHandleFinalization in future %s %s %d %d\n",
 			m.PeerId, s.Hash, s.RoundNumber, cache.Number)
 		return false, nil
@@ -743,11 +801,16 @@ func (chain *Chain) prepareFinalization(m *CosiAction) (bool, error) {
 	if s.RoundNumber == cache.Number+1 {
 		_, nf, dummy, err := chain.startNewRoundAndPersist(cache, s.References, s.Timestamp, true)
 		if err != nil || nf == nil {
+			chain.markRejected(RejectNewRound)
 			logger.Verbosef("ERROR cosiHandleFinalization startNewRound %s %v %v %v\n",
 				m.PeerId, s, err, nf)
+			if s.References != nil {
+				chain.requestMissingReferences(m.PeerId, s.References.External)
+			}
 			return false, nil
 		}
 		if dummy {
+			chain.markRejected(RejectNewRound)
 			logger.Verbosef("ERROR cosiHandleFinalization startNewRound DUMMY %s %s %d\n",
 				m.PeerId, s.Hash, chain.node.ConsensusThreshold(s.Timestamp, true))
 			return false, nil
@@ -767,6 +830,7 @@ func (chain *Chain) cosiHandleFinalization(m *CosiAction) error {
 	m.WantTx = false
 	signers, finalized := chain.verifyFinalization(s)
 	if !finalized {
+		chain.markRejected(RejectSignature)
 		logger.Verbosef("ERROR cosiHandleFinalization verifyFinalization %s %v %d\n",
 			m.PeerId, s, chain.node.ConsensusThreshold(s.Timestamp, true))
 		return nil
@@ -774,6 +838,7 @@ func (chain *Chain) cosiHandleFinalization(m *CosiAction) error {
 
 	tx, _, err := chain.node.validateSnapshotTransaction(s, true)
 	if err != nil {
+		chain.markRejected(RejectTransaction)
 		logger.Verbosef("ERROR handleFinalization validateSnapshotTransaction %s %s %d %v\n",
 			m.PeerId, s.Hash, chain.node.ConsensusThreshold(s.Timestamp, true), err)
 		return nil
@@ -798,13 +863,16 @@ func (chain *Chain) cosiHandleFinalization(m *CosiAction) error {
 	if !s.References.Equal(cache.References) {
 		err := chain.updateEmptyHeadRoundAndPersist(final, cache, s.References, s.Timestamp, false)
 		if err != nil {
+			chain.markRejected(RejectReferences)
 			logger.Debugf("ERROR cosiHandleFinalization updateEmptyHeadRoundAndPersist failed %s %s %v\n",
 				m.PeerId, s.Hash, err)
+			chain.requestMissingReferences(m.PeerId, s.References.External)
 		}
 		return nil
 	}
 
 	if err := cache.ValidateSnapshot(s); err != nil {
+		chain.markRejected(RejectValidation)
 		logger.Verbosef("ERROR cosiHandleFinalization ValidateSnapshot %s %v %v\n", m.PeerId, s, err)
 		return nil
 	}
@@ -856,7 +924,7 @@ func (chain *Chain) cosiAddCommitments(m *CosiAction) error {
 	return nil
 }
 
-func (chain *Chain) cosiRetrieveRandom(snap crypto.Hash, peerId crypto.Hash, challenge *crypto.Key) *crypto.Key {
+func (chain *Chain) cosiRetrieveRandom(snap crypto.Hash, peerId crypto.Hash, challenge *crypto.Key) *crypto.CosiNonce {
 	if chain.ChainId == chain.node.IdForNetwork {
 		panic(chain.ChainId)
 	}
@@ -864,7 +932,7 @@ func (chain *Chain) cosiRetrieveRandom(snap crypto.Hash, peerId crypto.Hash, cha
 		panic(peerId)
 	}
 	r := chain.UsedRandoms[snap]
-	if r != nil && r.Public() == *challenge {
+	if r != nil && r.Commitment == *challenge {
 		return r
 	}
 	cm := chain.CosiRandoms
@@ -895,20 +963,20 @@ func (chain *Chain) cosiPrepareRandomsAndSendCommitments(peerId crypto.Hash) err
 	}
 
 	// FIXME always generate new randoms, may bloat the memory
+	nonces, err := chain.node.signer.CosiCommit(maximum)
+	if err != nil {
+		return err
+	}
 	commitments := make([]*crypto.Key, maximum)
-	cm := make(map[crypto.Key]*crypto.Key, maximum)
-	for i := 0; i < maximum; i++ {
-		r := crypto.CosiCommit(crypto.RandReader())
-		k := r.Public()
-		commitments[i] = &k
-		cm[k] = r
+	for i, r := range nonces {
+		commitments[i] = &r.Commitment
 	}
 
 	if chain.CosiRandoms == nil {
-		chain.CosiRandoms = make(map[crypto.Key]*crypto.Key)
+		chain.CosiRandoms = make(map[crypto.Key]*crypto.CosiNonce)
 	}
-	for _, r := range cm {
-		chain.CosiRandoms[r.Public()] = r
+	for _, r := range nonces {
+		chain.CosiRandoms[r.Commitment] = r
 	}
 	chain.ComitmentsSentTime = clock.Now()
 	return chain.node.Peer.SendCommitmentsMessage(peerId, commitments)
@@ -954,6 +1022,7 @@ func (node *Node) CosiQueueExternalAnnouncement(peerId crypto.Hash, s *common.Sn
 	chain := node.getOrCreateChain(s.NodeId)
 
 	s.Hash = s.PayloadHash()
+	defer traceSnapshot("p2p.announcement", s.Hash, s, peerId).End()
 	m := &CosiAction{
 		PeerId:       peerId,
 		Action:       CosiActionExternalAnnouncement,
@@ -970,6 +1039,7 @@ func (node *Node) CosiQueueExternalAnnouncement(peerId crypto.Hash, s *common.Sn
 
 func (node *Node) CosiAggregateSelfCommitments(peerId crypto.Hash, snap crypto.Hash, commitment *crypto.Key, wantTx bool, data []byte, sig *crypto.Signature) error {
 	logger.Debugf("CosiAggregateSelfCommitments(%s, %s)\n", peerId, snap)
+	defer traceSnapshot("p2p.commitment", snap, nil, peerId).End()
 	peer := node.GetAcceptedOrPledgingNode(peerId)
 	if peer == nil {
 		logger.Verbosef("CosiAggregateSelfCommitments(%s, %s) from malicious node\n", peerId, snap)
@@ -996,6 +1066,7 @@ func (node *Node) CosiAggregateSelfCommitments(peerId crypto.Hash, snap crypto.H
 
 func (node *Node) CosiQueueExternalChallenge(peerId crypto.Hash, snap crypto.Hash, cosi *crypto.CosiSignature, ver *common.VersionedTransaction) error {
 	logger.Debugf("CosiQueueExternalChallenge(%s, %s)\n", peerId, snap)
+	defer traceSnapshot("p2p.challenge", snap, nil, peerId).End()
 	if node.GetAcceptedOrPledgingNode(peerId) == nil {
 		logger.Verbosef("CosiQueueExternalChallenge(%s, %s) from malicious node\n", peerId, snap)
 		return nil
@@ -1007,7 +1078,7 @@ func (node *Node) CosiQueueExternalChallenge(peerId crypto.Hash, snap crypto.Has
 		Action:       CosiActionExternalChallenge,
 		SnapshotHash: snap,
 		Signature:    cosi,
-		Transaction:  ver,
+		Transaction:  node.interner.intern(ver),
 	}
 	err := chain.AppendCosiAction(m)
 	if err != nil {
@@ -1025,6 +1096,7 @@ func (node *Node) CosiQueueExternalFullChallenge(peerId crypto.Hash, s *common.S
 	chain := node.getOrCreateChain(peerId)
 
 	s.Hash = s.PayloadHash()
+	defer traceSnapshot("p2p.full_challenge", s.Hash, s, peerId).End()
 	m := &CosiAction{
 		PeerId:       peerId,
 		Action:       CosiActionExternalFullChallenge,
@@ -1033,7 +1105,7 @@ func (node *Node) CosiQueueExternalFullChallenge(peerId crypto.Hash, s *common.S
 		Commitment:   commitment,
 		Challenge:    challenge,
 		Signature:    cosi,
-		Transaction:  ver,
+		Transaction:  node.interner.intern(ver),
 	}
 	err := chain.AppendCosiAction(m)
 	if err != nil {
@@ -1044,6 +1116,7 @@ func (node *Node) CosiQueueExternalFullChallenge(peerId crypto.Hash, s *common.S
 
 func (node *Node) CosiAggregateSelfResponses(peerId crypto.Hash, snap crypto.Hash, response *[32]byte) error {
 	logger.Debugf("CosiAggregateSelfResponses(%s, %s)\n", peerId, snap)
+	defer traceSnapshot("p2p.response", snap, nil, peerId).End()
 	if node.GetAcceptedOrPledgingNode(peerId) == nil {
 		logger.Verbosef("CosiAggregateSelfResponses(%s, %s) from malicious node\n", peerId, snap)
 		return nil
@@ -1065,6 +1138,7 @@ func (node *Node) CosiAggregateSelfResponses(peerId crypto.Hash, snap crypto.Has
 func (node *Node) VerifyAndQueueAppendSnapshotFinalization(peerId crypto.Hash, s *common.Snapshot) error {
 	s.Hash = s.PayloadHash()
 	logger.Debugf("VerifyAndQueueAppendSnapshotFinalization(%s, %s)\n", peerId, s.Hash)
+	defer traceSnapshot("p2p.finalization", s.Hash, s, peerId).End()
 
 	node.Peer.ConfirmSnapshotForPeer(peerId, s.Hash)
 	err := node.Peer.SendSnapshotConfirmMessage(peerId, s.Hash)
@@ -1074,31 +1148,35 @@ func (node *Node) VerifyAndQueueAppendSnapshotFinalization(peerId crypto.Hash, s
 		return nil
 	}
 
-	tx, finalized, err := node.checkTxInStorage(s.SoleTransaction())
-	if err != nil {
-		logger.Verbosef("VerifyAndQueueAppendSnapshotFinalization(%s, %s) check tx error %s\n", peerId, s.Hash, err)
-	} else if tx == nil {
-		err = node.Peer.SendTransactionRequestMessage(peerId, s.SoleTransaction())
-		logger.Verbosef("VerifyAndQueueAppendSnapshotFinalization(%s, %s) SendTransactionRequestMessage %s %v\n",
-			peerId, s.Hash, s.SoleTransaction(), err)
-	} else if finalized == s.Hash.String() {
-		return nil
-	}
+	node.queueSnapshotPrevalidation(peerId, s)
+	return nil
+}
 
-	chain := node.getOrCreateChain(s.NodeId)
-	if cs := chain.State; cs != nil && cs.CacheRound.index[s.Hash] {
-		return nil
+// QueueAppendSnapshotBatch queues the snapshots pulled in batch without the
+// confirm message of each snapshot, and the transactions are cached before
+// the prevalidation, so they are not requested one by one. the snapshots are
+// persisted with the sync frontier to resume after restart.
+func (node *Node) QueueAppendSnapshotBatch(peerId crypto.Hash, b *p2p.SnapshotBatch) error {
+	logger.Debugf("QueueAppendSnapshotBatch(%s, %s, %d, %d)\n", peerId, b.NodeId, b.From, b.To)
+	for _, tx := range b.Transactions {
+		if tx == nil {
+			continue
+		}
+		err := node.persistStore.CachePutTransaction(tx)
+		if err != nil {
+			return err
+		}
 	}
-	if _, finalized := chain.verifyFinalization(s); !finalized {
-		logger.Verbosef("ERROR VerifyAndQueueAppendSnapshotFinalization %s %v %d %t %v %v\n",
-			peerId, s, node.ConsensusThreshold(s.Timestamp, true), chain.IsPledging(), chain.State, chain.ConsensusInfo)
-		return nil
+	for _, s := range b.Snapshots {
+		s.Hash = s.PayloadHash()
 	}
-
-	err = chain.AppendFinalSnapshot(s.NodeId, s)
+	err := node.writeSyncBatch(b)
 	if err != nil {
-		logger.Verbosef("VerifyAndQueueAppendSnapshotFinalization(%s, %s) chain error %s\n",
-			peerId, s.Hash, err)
+		return err
+	}
+	for _, s := range b.Snapshots {
+		node.Peer.ConfirmSnapshotForPeer(peerId, s.Hash)
+		node.queueSnapshotPrevalidation(peerId, s)
 	}
 	return nil
 }
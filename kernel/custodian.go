@@ -1,8 +1,6 @@
 package kernel
 
 import (
-	"fmt"
-
 	"github.com/MixinNetwork/mixin/common"
 	"github.com/MixinNetwork/mixin/config"
 	"github.com/MixinNetwork/mixin/crypto"
@@ -14,24 +12,27 @@ func (node *Node) validateCustodianUpdateNodes(s *common.Snapshot, tx *common.Ve
 	if s.Timestamp == 0 && s.NodeId == node.IdForNetwork {
 		timestamp = uint64(clock.Now().UnixNano())
 	}
-	eid := node.electSnapshotNode(common.TransactionTypeCustodianUpdateNodes, timestamp)
+	eid, err := node.electSnapshotNode(common.TransactionTypeCustodianUpdateNodes, timestamp)
+	if err != nil {
+		return common.Errorf(common.ErrorInvalidCustodian, "%w", err)
+	}
 	if eid != s.NodeId {
-		return fmt.Errorf("custodian updates operation at %d only by %s not %s", timestamp, eid, s.NodeId)
+		return common.Errorf(common.ErrorInvalidCustodian, "custodian updates operation at %d only by %s not %s", timestamp, eid, s.NodeId)
 	}
 
 	if timestamp < node.Epoch {
-		return fmt.Errorf("invalid snapshot timestamp %d %d", node.Epoch, timestamp)
+		return common.Errorf(common.ErrorInvalidTimestamp, "invalid snapshot timestamp %d %d", node.Epoch, timestamp)
 	}
 	since := timestamp - node.Epoch
 	hours := int(since / 3600000000000)
 	kmb, kme := config.KernelMintTimeBegin, config.KernelMintTimeEnd
 	if hours%24+1 >= kmb && hours%24 <= kme+1 {
-		return fmt.Errorf("invalid custodian update hour %d", hours%24)
+		return common.Errorf(common.ErrorInvalidTimestamp, "invalid custodian update hour %d", hours%24)
 	}
 
 	threshold := config.SnapshotRoundGap * config.SnapshotReferenceThreshold
 	if !finalized && timestamp+threshold*2 < node.GraphTimestamp {
-		return fmt.Errorf("invalid custodian update snapshot timestamp %d %d", node.GraphTimestamp, timestamp)
+		return common.Errorf(common.ErrorInvalidTimestamp, "invalid custodian update snapshot timestamp %d %d", node.GraphTimestamp, timestamp)
 	}
 
 	curs, err := common.ParseCustodianUpdateNodesExtra(tx.Extra, false)
@@ -39,7 +40,7 @@ func (node *Node) validateCustodianUpdateNodes(s *common.Snapshot, tx *common.Ve
 		return err
 	}
 	if len(curs.Nodes) < 7 {
-		return fmt.Errorf("invalid custodian nodes count %d", len(curs.Nodes))
+		return common.Errorf(common.ErrorInvalidCustodian, "invalid custodian nodes count %d", len(curs.Nodes))
 	}
 
 	prev, err := node.persistStore.ReadCustodian(timestamp)
@@ -48,7 +49,7 @@ func (node *Node) validateCustodianUpdateNodes(s *common.Snapshot, tx *common.Ve
 	}
 	eh := crypto.Blake3Hash(tx.Extra[:len(tx.Extra)-64])
 	if !prev.Custodian.PublicSpendKey.Verify(eh, *curs.Signature) {
-		return fmt.Errorf("invalid custodian update approval signature %x", tx.Extra)
+		return common.Errorf(common.ErrorInvalidSignature, "invalid custodian update approval signature %x", tx.Extra)
 	}
 
 	all := node.persistStore.ReadAllNodes(timestamp, false)
@@ -61,16 +62,16 @@ func (node *Node) validateCustodianUpdateNodes(s *common.Snapshot, tx *common.Ve
 		copy(id[:], n.Extra[129:161])
 		cn := filter[id]
 		if cn == nil {
-			return fmt.Errorf("invalid custodian node id %x", n.Extra)
+			return common.Errorf(common.ErrorInvalidCustodian, "invalid custodian node id %x", n.Extra)
 		}
 		if cn.Payee.String() != n.Payee.String() {
-			return fmt.Errorf("invalid custodian node payee %x", n.Extra)
+			return common.Errorf(common.ErrorInvalidCustodian, "invalid custodian node payee %x", n.Extra)
 		}
 		var sig crypto.Signature
 		copy(sig[:], n.Extra[161:225])
 		eh := crypto.Blake3Hash(n.Extra[:161])
 		if !cn.Signer.PublicSpendKey.Verify(eh, sig) {
-			return fmt.Errorf("invalid custodian update signer signature %x", n.Extra)
+			return common.Errorf(common.ErrorInvalidSignature, "invalid custodian update signer signature %x", n.Extra)
 		}
 	}
 	return nil
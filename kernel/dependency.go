@@ -0,0 +1,112 @@
+package kernel
+
+import (
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+// the ancestors of a cache transaction are only listed to this depth, a
+// longer chain is already too slow to finalize in time
+const cacheDependencyDepth = 32
+
+type CacheDependency struct {
+	Hash    crypto.Hash
+	Parents []crypto.Hash
+}
+
+// pendingParents returns the cache transactions with the outputs spent by
+// the transaction, these parents are not finalized yet, so the transaction
+// is held in the cache until all of them are finalized.
+func (node *Node) pendingParents(tx *common.VersionedTransaction) ([]*common.VersionedTransaction, error) {
+	var parents []*common.VersionedTransaction
+	filter := make(map[crypto.Hash]bool)
+	for _, in := range tx.Inputs {
+		if !in.Hash.HasValue() || filter[in.Hash] {
+			continue
+		}
+		filter[in.Hash] = true
+		utxo, err := node.persistStore.ReadUTXOLock(in.Hash, in.Index)
+		if err != nil {
+			return nil, err
+		}
+		if utxo != nil {
+			continue
+		}
+		_, finalized, err := node.persistStore.ReadTransaction(in.Hash)
+		if err != nil {
+			return nil, err
+		}
+		if len(finalized) > 0 {
+			continue
+		}
+		parent, err := node.persistStore.CacheGetTransaction(in.Hash)
+		if err != nil {
+			return nil, err
+		}
+		if parent != nil {
+			parents = append(parents, parent)
+		}
+	}
+	return parents, nil
+}
+
+// CacheDependencies lists the cache transaction and its pending ancestors,
+// the nearest first, each with its own pending parents, so the whole chain
+// holding the transaction could be rebuilt. The transaction is held if the
+// first entry has any parents.
+func (node *Node) CacheDependencies(tx *common.VersionedTransaction) ([]*CacheDependency, error) {
+	var deps []*CacheDependency
+	filter := map[crypto.Hash]bool{tx.PayloadHash(): true}
+	queue := []*common.VersionedTransaction{tx}
+	for len(queue) > 0 && len(deps) < cacheDependencyDepth {
+		parents, err := node.pendingParents(queue[0])
+		if err != nil {
+			return nil, err
+		}
+		dep := &CacheDependency{Hash: queue[0].PayloadHash()}
+		for _, p := range parents {
+			hash := p.PayloadHash()
+			dep.Parents = append(dep.Parents, hash)
+			if !filter[hash] {
+				filter[hash] = true
+				queue = append(queue, p)
+			}
+		}
+		deps = append(deps, dep)
+		queue = queue[1:]
+	}
+	return deps, nil
+}
+
+// pendingStore validates the transaction as if its pending parents were
+// finalized, so a child with valid signatures is accepted to the cache,
+// and it is validated again against the store when the parents finalize.
+type pendingStore struct {
+	common.DataStore
+	outputs map[crypto.Hash]map[uint]*common.UTXOWithLock
+}
+
+func newPendingStore(store common.DataStore, parents []*common.VersionedTransaction) common.DataStore {
+	if len(parents) == 0 {
+		return store
+	}
+	ps := &pendingStore{
+		DataStore: store,
+		outputs:   make(map[crypto.Hash]map[uint]*common.UTXOWithLock),
+	}
+	for _, p := range parents {
+		hash := p.PayloadHash()
+		ps.outputs[hash] = make(map[uint]*common.UTXOWithLock)
+		for _, utxo := range p.UnspentOutputs() {
+			ps.outputs[hash][utxo.Index] = utxo
+		}
+	}
+	return ps
+}
+
+func (ps *pendingStore) ReadUTXOLock(hash crypto.Hash, index uint) (*common.UTXOWithLock, error) {
+	if utxo := ps.outputs[hash][index]; utxo != nil {
+		return utxo, nil
+	}
+	return ps.DataStore.ReadUTXOLock(hash, index)
+}
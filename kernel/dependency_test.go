@@ -0,0 +1,79 @@
+package kernel
+
+import (
+	"os"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheDependencies(t *testing.T) {
+	require := require.New(t)
+
+	custom, err := config.Initialize("../config/config.example.toml")
+	require.Nil(err)
+	root, err := os.MkdirTemp("", "mixin-cache-dependencies-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+	store, err := storage.NewBadgerStore(custom, root)
+	require.Nil(err)
+	defer store.Close()
+	node := &Node{persistStore: store}
+
+	seed := make([]byte, 64)
+	account := common.NewAddressFromSeed(seed)
+	build := func(inputs ...*common.Input) *common.VersionedTransaction {
+		tx := common.NewTransactionV5(common.XINAssetId)
+		for _, in := range inputs {
+			tx.AddInput(in.Hash, in.Index)
+		}
+		tx.AddScriptOutput([]*common.Address{&account}, common.NewThresholdScript(1), common.NewInteger(1), seed)
+		tx.AddScriptOutput([]*common.Address{&account}, common.NewThresholdScript(1), common.NewInteger(2), seed)
+		return tx.AsVersioned()
+	}
+	parent := build(&common.Input{Hash: crypto.Blake3Hash([]byte("genesis"))})
+	child := build(&common.Input{Hash: parent.PayloadHash(), Index: 0})
+	grandchild := build(
+		&common.Input{Hash: child.PayloadHash(), Index: 0},
+		&common.Input{Hash: parent.PayloadHash(), Index: 1},
+	)
+
+	parents, err := node.pendingParents(child)
+	require.Nil(err)
+	require.Len(parents, 0)
+	require.Nil(store.CachePutTransaction(parent))
+	parents, err = node.pendingParents(child)
+	require.Nil(err)
+	require.Len(parents, 1)
+	require.Equal(parent.PayloadHash(), parents[0].PayloadHash())
+
+	require.Nil(store.CachePutTransaction(child))
+	deps, err := node.CacheDependencies(grandchild)
+	require.Nil(err)
+	require.Len(deps, 3)
+	require.Equal(grandchild.PayloadHash(), deps[0].Hash)
+	require.Equal([]crypto.Hash{child.PayloadHash(), parent.PayloadHash()}, deps[0].Parents)
+	require.Equal(child.PayloadHash(), deps[1].Hash)
+	require.Equal([]crypto.Hash{parent.PayloadHash()}, deps[1].Parents)
+	require.Equal(parent.PayloadHash(), deps[2].Hash)
+	require.Len(deps[2].Parents, 0)
+
+	deps, err = node.CacheDependencies(parent)
+	require.Nil(err)
+	require.Len(deps, 1)
+	require.Len(deps[0].Parents, 0)
+
+	ps := newPendingStore(store, []*common.VersionedTransaction{parent})
+	utxo, err := ps.ReadUTXOLock(parent.PayloadHash(), 1)
+	require.Nil(err)
+	require.Equal(common.NewInteger(2), utxo.Amount)
+	require.False(utxo.LockHash.HasValue())
+	utxo, err = ps.ReadUTXOLock(parent.PayloadHash(), 2)
+	require.Nil(err)
+	require.Nil(utxo)
+	require.Equal(common.DataStore(store), newPendingStore(store, nil))
+}
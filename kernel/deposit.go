@@ -0,0 +1,67 @@
+package kernel
+
+import (
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/depositproof"
+)
+
+func (node *Node) loadDepositProofs() error {
+	provider := node.custom.Deposit.ProofProvider
+	if provider == "" {
+		return nil
+	}
+	checker := depositproof.NewChecker(depositproof.NewHTTPProvider(provider))
+	btc, err := depositproof.NewBitcoinVerifier(node.custom.Deposit.BitcoinConfirmations, depositproof.BitcoinMaxTargetBits)
+	if err != nil {
+		return err
+	}
+	checker.Register(common.BitcoinAssetId, btc)
+	if rpc := node.custom.Deposit.EthereumRPC; rpc != "" {
+		eth := depositproof.NewEthereumVerifier(depositproof.NewEthereumRPC(rpc))
+		checker.Register(common.EthereumAssetId, eth)
+	}
+	node.depositProofs = checker
+	return nil
+}
+
+// validateDepositProof is only for the deposits not finalized yet, the node
+// refuses to queue or sign the deposit without a valid proof, but it's not a
+// consensus rule, so the deposits finalized by the other nodes are accepted.
+// The proof is requested from the provider when the deposit is queued, and
+// both the valid and invalid results are cached for the snapshot validation.
+func (node *Node) validateDepositProof(tx *common.VersionedTransaction) error {
+	if node.depositProofs == nil || tx.TransactionType() != common.TransactionTypeDeposit {
+		return nil
+	}
+	err := node.depositProofs.Check(tx.DepositData())
+	if err != nil {
+		return common.Errorf(common.ErrorInvalidDepositProof, "%w", err)
+	}
+	return nil
+}
+
+// readDepositProof never requests the provider, because it's called by the
+// chain to sign the snapshots, a deposit not checked yet, e.g. queued by the
+// other nodes, is refused until the proof is checked in the background.
+func (node *Node) readDepositProof(tx *common.VersionedTransaction) error {
+	if node.depositProofs == nil || tx.TransactionType() != common.TransactionTypeDeposit {
+		return nil
+	}
+	d := tx.DepositData()
+	found, err := node.depositProofs.Cached(d)
+	if !found {
+		node.depositProofs.Prefetch(d)
+		return common.Errorf(common.ErrorInvalidDepositProof, "deposit proof not checked yet %s:%d", d.Transaction, d.Index)
+	}
+	if err != nil {
+		return common.Errorf(common.ErrorInvalidDepositProof, "%w", err)
+	}
+	return nil
+}
+
+func (node *Node) prefetchDepositProof(tx *common.VersionedTransaction) {
+	if node.depositProofs == nil || tx.TransactionType() != common.TransactionTypeDeposit {
+		return
+	}
+	node.depositProofs.Prefetch(tx.DepositData())
+}
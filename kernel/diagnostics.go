@@ -0,0 +1,60 @@
+package kernel
+
+import (
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/p2p"
+)
+
+type ChainDiagnostic struct {
+	ChainId      crypto.Hash `json:"chain"`
+	Running      bool        `json:"running"`
+	CacheRound   uint64      `json:"cache_round"`
+	CacheSize    int         `json:"cache_size"`
+	FinalRound   uint64      `json:"final_round"`
+	FinalHash    crypto.Hash `json:"final_hash"`
+	CachePool    int         `json:"cache_pool"`
+	FinalActions int         `json:"final_actions"`
+	FinalIndex   int         `json:"final_index"`
+	FinalCount   int         `json:"final_count"`
+}
+
+type NodeDiagnostic struct {
+	Node       crypto.Hash                    `json:"node"`
+	Timestamp  uint64                         `json:"timestamp"`
+	Chains     []*ChainDiagnostic             `json:"chains"`
+	SyncPoints map[crypto.Hash]*p2p.SyncPoint `json:"sync_points"`
+	SyncMap    map[crypto.Hash]*p2p.SyncPoint `json:"sync_map"`
+}
+
+// Diagnostic is a best effort snapshot of the chain queues and the peer sync
+// points, it never takes the chain locks so it works even if a chain hangs.
+func (node *Node) Diagnostic() *NodeDiagnostic {
+	nd := &NodeDiagnostic{
+		Node:       node.IdForNetwork,
+		Timestamp:  node.GraphTimestamp,
+		SyncPoints: node.SyncPoints.Map(),
+		SyncMap:    node.SyncPointsMap,
+	}
+
+	node.chains.RLock()
+	defer node.chains.RUnlock()
+
+	for _, chain := range node.chains.m {
+		cd := &ChainDiagnostic{
+			ChainId:      chain.ChainId,
+			Running:      chain.running,
+			CachePool:    len(chain.CachePool),
+			FinalActions: len(chain.finalActionsRing),
+			FinalIndex:   chain.FinalIndex,
+			FinalCount:   chain.FinalCount,
+		}
+		if state := chain.State; state != nil {
+			cd.CacheRound = state.CacheRound.Number
+			cd.CacheSize = len(state.CacheRound.Snapshots)
+			cd.FinalRound = state.FinalRound.Number
+			cd.FinalHash = state.FinalRound.Hash
+		}
+		nd.Chains = append(nd.Chains, cd)
+	}
+	return nd
+}
@@ -45,12 +45,14 @@ func (node *Node) ElectionLoop() {
 			if err != nil {
 				logger.Println("tryToSendRemoveTransaction", err)
 			}
+			node.reportElectionAnomalies()
+			node.checkpointState()
 		}
 	}
 }
 
 // TODO slashing rule for failure of this elected node
-func (node *Node) electSnapshotNode(operation byte, now uint64) crypto.Hash {
+func (node *Node) electSnapshotNode(operation byte, now uint64) (crypto.Hash, error) {
 	switch operation {
 	case common.TransactionTypeMint:
 	case common.TransactionTypeNodeRemove:
@@ -58,16 +60,22 @@ func (node *Node) electSnapshotNode(operation byte, now uint64) crypto.Hash {
 	case common.TransactionTypeCustodianUpdateNodes:
 	case common.TransactionTypeCustodianSlashNodes:
 	default:
-		return crypto.Hash{}
+		return crypto.Hash{}, nil
 	}
 	accepted := node.NodesListWithoutState(now, true)
+	if node.devMode && len(accepted) < config.KernelMinimumNodesCount {
+		if len(accepted) == 0 {
+			return crypto.Hash{}, fmt.Errorf("no accepted node to elect at %d", now)
+		}
+		return accepted[0].IdForNetwork, nil
+	}
 	if len(accepted) < config.KernelMinimumNodesCount {
 		panic(len(accepted))
 	}
 	accepted = accepted[1 : len(accepted)-1]
 	day := int((now - node.Epoch) / (uint64(time.Hour) * 24))
 	idx := (day + int(operation)) % len(accepted)
-	return accepted[idx].IdForNetwork
+	return accepted[idx].IdForNetwork, nil
 }
 
 func (node *Node) checkRemovePossibility(nodeId crypto.Hash, now uint64, old *common.VersionedTransaction) (*CNode, error) {
@@ -127,7 +135,10 @@ func (node *Node) buildNodeRemoveTransaction(nodeId crypto.Hash, timestamp uint6
 	if old != nil && candi.Transaction == old.PayloadHash() {
 		return old, nil
 	}
+	return node.buildNodeRemoveTransactionFor(candi)
+}
 
+func (node *Node) buildNodeRemoveTransactionFor(candi *CNode) (*common.VersionedTransaction, error) {
 	accept, _, err := node.persistStore.ReadTransaction(candi.Transaction)
 	if err != nil {
 		return nil, err
@@ -139,13 +150,16 @@ func (node *Node) buildNodeRemoveTransaction(nodeId crypto.Hash, timestamp uint6
 		return nil, fmt.Errorf("accept transaction malformed %s %s", candi.Transaction, accept.PayloadHash())
 	}
 	signer := candi.Signer.PublicSpendKey
-	payee := candi.Payee.PublicSpendKey[:]
+	payee := candi.Payee.PublicSpendKey
 	if len(accept.Extra) != len(signer)*2 {
 		return nil, fmt.Errorf("invalid accept transaction extra %s", hex.EncodeToString(accept.Extra))
 	}
-	if !bytes.Equal(append(signer[:], payee...), accept.Extra) {
+	// the payee may be changed after accepted, and the refund goes to the
+	// current payee, while the extra is always the same as the accept one
+	if !bytes.Equal(append(signer[:], payee[:]...), accept.Extra) &&
+		(!bytes.Equal(signer[:], accept.Extra[:len(signer)]) || !node.payeeChangedAfter(candi)) {
 		return nil, fmt.Errorf("invalid accept transaction extra %s %s %s",
-			hex.EncodeToString(accept.Extra), signer, hex.EncodeToString(payee))
+			hex.EncodeToString(accept.Extra), signer, payee)
 	}
 
 	tx := node.NewTransaction(common.XINAssetId)
@@ -161,7 +175,10 @@ func (node *Node) buildNodeRemoveTransaction(nodeId crypto.Hash, timestamp uint6
 }
 
 func (node *Node) tryToSendRemoveTransaction() error {
-	eid := node.electSnapshotNode(common.TransactionTypeNodeRemove, node.GraphTimestamp)
+	eid, err := node.electSnapshotNode(common.TransactionTypeNodeRemove, node.GraphTimestamp)
+	if err != nil {
+		return err
+	}
 	if eid != node.IdForNetwork {
 		return fmt.Errorf("node remove operation at %d only by %s not me", node.GraphTimestamp, eid)
 	}
@@ -171,7 +188,7 @@ func (node *Node) tryToSendRemoveTransaction() error {
 	}
 	logger.Verbosef("tryToSendRemoveTransaction %s\n", tx.PayloadHash())
 
-	err = tx.Validate(node.persistStore, node.GraphTimestamp, false)
+	err = tx.Validate(node.persistStore, node.GraphTimestamp, false, node.consensusForks(node.GraphTimestamp))
 	if err != nil {
 		return err
 	}
@@ -193,9 +210,12 @@ func (node *Node) validateNodeRemoveSnapshot(s *common.Snapshot, tx *common.Vers
 	if s.Timestamp == 0 && s.NodeId == node.IdForNetwork {
 		timestamp = uint64(clock.Now().UnixNano())
 	}
-	eid := node.electSnapshotNode(common.TransactionTypeNodeRemove, timestamp)
+	eid, err := node.electSnapshotNode(common.TransactionTypeNodeRemove, timestamp)
+	if err != nil {
+		return common.Errorf(common.ErrorInvalidNodeOperation, "%w", err)
+	}
 	if eid != s.NodeId {
-		return fmt.Errorf("node remove operation at %d only by %s not %s", timestamp, eid, s.NodeId)
+		return common.Errorf(common.ErrorInvalidNodeOperation, "node remove operation at %d only by %s not %s", timestamp, eid, s.NodeId)
 	}
 
 	signer := tx.NodeTransactionExtraAsSigner()
@@ -216,7 +236,7 @@ func (node *Node) validateNodeRemoveSnapshot(s *common.Snapshot, tx *common.Vers
 		return err
 	}
 	if cantx.PayloadHash() != tx.PayloadHash() {
-		return fmt.Errorf("invalid node remove transaction %s %s", cantx.PayloadHash(), tx.PayloadHash())
+		return common.Errorf(common.ErrorInvalidNodeOperation, "invalid node remove transaction %s %s", cantx.PayloadHash(), tx.PayloadHash())
 	}
 	return nil
 }
@@ -224,40 +244,40 @@ func (node *Node) validateNodeRemoveSnapshot(s *common.Snapshot, tx *common.Vers
 func (chain *Chain) checkNodeAcceptPossibility(timestamp uint64, finalized bool) error {
 	ci, epoch := chain.ConsensusInfo, chain.node.Epoch
 	if chain.State != nil {
-		return fmt.Errorf("invalid graph round %s %d", chain.ChainId, chain.State.CacheRound.Number)
+		return common.Errorf(common.ErrorInvalidNodeOperation, "invalid graph round %s %d", chain.ChainId, chain.State.CacheRound.Number)
 	}
 
 	pledging := chain.node.PledgingNode(timestamp)
 	if pledging == nil {
-		return fmt.Errorf("no consensus pledging node %t", pledging == nil)
+		return common.Errorf(common.ErrorInvalidNodeOperation, "no consensus pledging node %t", pledging == nil)
 	}
 	if pledging.Signer.String() != ci.Signer.String() {
-		return fmt.Errorf("invalid consensus pledging node %s %s", pledging.Signer, ci.Signer)
+		return common.Errorf(common.ErrorInvalidNodeOperation, "invalid consensus pledging node %s %s", pledging.Signer, ci.Signer)
 	}
 
 	if timestamp < epoch {
-		return fmt.Errorf("invalid snapshot timestamp %d %d", epoch, timestamp)
+		return common.Errorf(common.ErrorInvalidTimestamp, "invalid snapshot timestamp %d %d", epoch, timestamp)
 	}
 
 	if !chain.node.checkConsensusAcceptHour(timestamp) {
 		hour := (timestamp - epoch) / uint64(time.Hour) % 24
-		return fmt.Errorf("invalid node accept hour %d", hour)
+		return common.Errorf(common.ErrorInvalidTimestamp, "invalid node accept hour %d", hour)
 	}
 
 	threshold := config.SnapshotRoundGap * config.SnapshotReferenceThreshold
 	if !finalized && timestamp+threshold*2 < chain.node.GraphTimestamp {
-		return fmt.Errorf("invalid snapshot timestamp %d %d", chain.node.GraphTimestamp, timestamp)
+		return common.Errorf(common.ErrorInvalidTimestamp, "invalid snapshot timestamp %d %d", chain.node.GraphTimestamp, timestamp)
 	}
 
 	if timestamp < pledging.Timestamp {
-		return fmt.Errorf("invalid snapshot timestamp %d %d", pledging.Timestamp, timestamp)
+		return common.Errorf(common.ErrorInvalidTimestamp, "invalid snapshot timestamp %d %d", pledging.Timestamp, timestamp)
 	}
 	elapse := time.Duration(timestamp - pledging.Timestamp)
 	if elapse < config.KernelNodeAcceptPeriodMinimum {
-		return fmt.Errorf("invalid accept period %d %d", config.KernelNodeAcceptPeriodMinimum, elapse)
+		return common.Errorf(common.ErrorInvalidTimestamp, "invalid accept period %d %d", config.KernelNodeAcceptPeriodMinimum, elapse)
 	}
 	if elapse > config.KernelNodeAcceptPeriodMaximum {
-		return fmt.Errorf("invalid accept period %d %d", config.KernelNodeAcceptPeriodMaximum, elapse)
+		return common.Errorf(common.ErrorInvalidTimestamp, "invalid accept period %d %d", config.KernelNodeAcceptPeriodMaximum, elapse)
 	}
 
 	return nil
@@ -306,7 +326,7 @@ func (chain *Chain) tryToSendAcceptTransaction() error {
 	}
 	logger.Verbosef("tryToSendAcceptTransaction %s\n", ver.PayloadHash())
 
-	err = ver.Validate(chain.node.persistStore, now, false)
+	err = ver.Validate(chain.node.persistStore, now, false, chain.node.consensusForks(now))
 	if err != nil {
 		return err
 	}
@@ -330,7 +350,7 @@ func (node *Node) validateNodeAcceptSnapshot(s *common.Snapshot, tx *common.Vers
 		timestamp = uint64(clock.Now().UnixNano())
 	}
 	if s.RoundNumber != 0 {
-		return fmt.Errorf("invalid snapshot round %d", s.RoundNumber)
+		return common.Errorf(common.ErrorInvalidSnapshot, "invalid snapshot round %d", s.RoundNumber)
 	}
 
 	chain := node.getOrCreateChain(s.NodeId)
@@ -339,7 +359,7 @@ func (node *Node) validateNodeAcceptSnapshot(s *common.Snapshot, tx *common.Vers
 		return err
 	}
 	if ver.PayloadHash() != tx.PayloadHash() {
-		return fmt.Errorf("invalid node accept transaction %s %s", ver.PayloadHash(), tx.PayloadHash())
+		return common.Errorf(common.ErrorInvalidNodeOperation, "invalid node accept transaction %s %s", ver.PayloadHash(), tx.PayloadHash())
 	}
 
 	return nil
@@ -358,7 +378,8 @@ func (node *Node) reloadConsensusState(s *common.Snapshot, tx *common.VersionedT
 	case common.TransactionTypeNodePledge,
 		common.TransactionTypeNodeCancel,
 		common.TransactionTypeNodeAccept,
-		common.TransactionTypeNodeRemove:
+		common.TransactionTypeNodeRemove,
+		common.TransactionTypeNodePayeeChange:
 	default:
 		return nil
 	}
@@ -469,16 +490,19 @@ func (node *Node) validateNodePledgeSnapshot(s *common.Snapshot, tx *common.Vers
 	if s.Timestamp == 0 && s.NodeId == node.IdForNetwork {
 		timestamp = uint64(clock.Now().UnixNano())
 	}
-	eid := node.electSnapshotNode(common.TransactionTypeNodePledge, timestamp)
+	eid, err := node.electSnapshotNode(common.TransactionTypeNodePledge, timestamp)
+	if err != nil {
+		return common.Errorf(common.ErrorInvalidNodeOperation, "%w", err)
+	}
 	if eid != s.NodeId {
-		return fmt.Errorf("node pledge operation at %d only by %s not %s", timestamp, eid, s.NodeId)
+		return common.Errorf(common.ErrorInvalidNodeOperation, "node pledge operation at %d only by %s not %s", timestamp, eid, s.NodeId)
 	}
 
 	if timestamp < node.Epoch {
-		return fmt.Errorf("invalid snapshot timestamp %d %d", node.Epoch, timestamp)
+		return common.Errorf(common.ErrorInvalidTimestamp, "invalid snapshot timestamp %d %d", node.Epoch, timestamp)
 	}
 	if tx.Outputs[0].Amount.Cmp(common.KernelNodePledgeAmount) != 0 {
-		return fmt.Errorf("invalid pledge amount %s", tx.Outputs[0].Amount.String())
+		return common.Errorf(common.ErrorInvalidAmount, "invalid pledge amount %s", tx.Outputs[0].Amount.String())
 	}
 
 	var signerSpend crypto.Key
@@ -489,17 +513,17 @@ func (node *Node) validateNodePledgeSnapshot(s *common.Snapshot, tx *common.Vers
 			return nil
 		}
 		if cn.Timestamp > timestamp {
-			return fmt.Errorf("invalid snapshot timestamp %d %d", cn.Timestamp, timestamp)
+			return common.Errorf(common.ErrorInvalidTimestamp, "invalid snapshot timestamp %d %d", cn.Timestamp, timestamp)
 		}
 		elapse := time.Duration(timestamp - cn.Timestamp)
 		if elapse < config.KernelNodePledgePeriodMinimum {
-			return fmt.Errorf("invalid pledge period %d %d", config.KernelNodePledgePeriodMinimum, elapse)
+			return common.Errorf(common.ErrorInvalidTimestamp, "invalid pledge period %d %d", config.KernelNodePledgePeriodMinimum, elapse)
 		}
 		if cn.Signer.PublicSpendKey.String() == signerSpend.String() {
-			return fmt.Errorf("invalid node signer key %s %s", hex.EncodeToString(tx.Extra), cn.Signer)
+			return common.Errorf(common.ErrorInvalidNodeOperation, "invalid node signer key %s %s", hex.EncodeToString(tx.Extra), cn.Signer)
 		}
 		if cn.Payee.PublicSpendKey.String() == signerSpend.String() {
-			return fmt.Errorf("invalid node signer key %s %s", hex.EncodeToString(tx.Extra), cn.Payee)
+			return common.Errorf(common.ErrorInvalidNodeOperation, "invalid node signer key %s %s", hex.EncodeToString(tx.Extra), cn.Payee)
 		}
 		switch cn.State {
 		case common.NodeStateAccepted:
@@ -507,12 +531,12 @@ func (node *Node) validateNodePledgeSnapshot(s *common.Snapshot, tx *common.Vers
 		case common.NodeStateRemoved:
 		case common.NodeStateCancelled:
 		default:
-			return fmt.Errorf("invalid node pending state %s %s", cn.Signer, cn.State)
+			return common.Errorf(common.ErrorInvalidNodeOperation, "invalid node pending state %s %s", cn.Signer, cn.State)
 		}
 	}
 
 	if totalNodes >= MaxKernelNodesCount {
-		return fmt.Errorf("maximum kernel nodes count reached because cosi signauture mask limit %s", tx.PayloadHash())
+		return common.Errorf(common.ErrorInvalidNodeOperation, "maximum kernel nodes count reached because cosi signauture mask limit %s", tx.PayloadHash())
 	}
 	// FIXME the node operation lock threshold should be optimized on pledging period
 	return node.persistStore.AddNodeOperation(tx, timestamp, uint64(config.KernelNodePledgePeriodMinimum)*2)
@@ -524,33 +548,33 @@ func (node *Node) validateNodeCancelSnapshot(s *common.Snapshot, tx *common.Vers
 		timestamp = uint64(clock.Now().UnixNano())
 	}
 	if timestamp < node.Epoch {
-		return fmt.Errorf("invalid snapshot timestamp %d %d", node.Epoch, timestamp)
+		return common.Errorf(common.ErrorInvalidTimestamp, "invalid snapshot timestamp %d %d", node.Epoch, timestamp)
 	}
 
 	pledging := node.PledgingNode(timestamp)
 	if pledging == nil {
-		return fmt.Errorf("invalid consensus status")
+		return common.Errorf(common.ErrorInvalidNodeOperation, "invalid consensus status")
 	}
 
 	if !node.checkConsensusAcceptHour(timestamp) {
 		hour := (timestamp - node.Epoch) / uint64(time.Hour) % 24
-		return fmt.Errorf("invalid node cancel hour %d", hour)
+		return common.Errorf(common.ErrorInvalidTimestamp, "invalid node cancel hour %d", hour)
 	}
 
 	threshold := config.SnapshotRoundGap * config.SnapshotReferenceThreshold
 	if !finalized && timestamp+threshold*2 < node.GraphTimestamp {
-		return fmt.Errorf("invalid snapshot timestamp %d %d", node.GraphTimestamp, timestamp)
+		return common.Errorf(common.ErrorInvalidTimestamp, "invalid snapshot timestamp %d %d", node.GraphTimestamp, timestamp)
 	}
 
 	if timestamp < pledging.Timestamp {
-		return fmt.Errorf("invalid snapshot timestamp %d %d", pledging.Timestamp, timestamp)
+		return common.Errorf(common.ErrorInvalidTimestamp, "invalid snapshot timestamp %d %d", pledging.Timestamp, timestamp)
 	}
 	elapse := time.Duration(timestamp - pledging.Timestamp)
 	if elapse < config.KernelNodeAcceptPeriodMinimum {
-		return fmt.Errorf("invalid cancel period %d %d", config.KernelNodeAcceptPeriodMinimum, elapse)
+		return common.Errorf(common.ErrorInvalidTimestamp, "invalid cancel period %d %d", config.KernelNodeAcceptPeriodMinimum, elapse)
 	}
 	if elapse > config.KernelNodeAcceptPeriodMaximum {
-		return fmt.Errorf("invalid cancel period %d %d", config.KernelNodeAcceptPeriodMaximum, elapse)
+		return common.Errorf(common.ErrorInvalidTimestamp, "invalid cancel period %d %d", config.KernelNodeAcceptPeriodMaximum, elapse)
 	}
 
 	// FIXME the node operation lock threshold should be optimized on pledging period
@@ -1,6 +1,7 @@
 package kernel
 
 import (
+	"math"
 	"os"
 	"testing"
 	"time"
@@ -26,14 +27,24 @@ func TestNodeElection(t *testing.T) {
 	require.NotNil(node)
 
 	now := node.Epoch + uint64(time.Hour*24*123)
-	eid := node.electSnapshotNode(common.TransactionTypeMint, now)
+	eid, err := node.electSnapshotNode(common.TransactionTypeMint, now)
+	require.Nil(err)
 	require.Equal("fb2793d548a889fbc41612ca336bb6112121962c0fac94be76174882e2042da6", eid.String())
-	eid = node.electSnapshotNode(common.TransactionTypeNodePledge, now)
+	eid, err = node.electSnapshotNode(common.TransactionTypeNodePledge, now)
+	require.Nil(err)
 	require.Equal("333508c8fdd5245ca9a3e47807aec3761f0609ace487484c0d0c6617aa109575", eid.String())
-	eid = node.electSnapshotNode(common.TransactionTypeNodeRemove, now)
+	eid, err = node.electSnapshotNode(common.TransactionTypeNodeRemove, now)
+	require.Nil(err)
 	require.Equal("588e521a7e7fd0998ed65ca09443206421f86cf18b167e2367a25af705204d8a", eid.String())
-	eid = node.electSnapshotNode(common.TransactionTypeCustodianUpdateNodes, now)
+	eid, err = node.electSnapshotNode(common.TransactionTypeCustodianUpdateNodes, now)
+	require.Nil(err)
 	require.Equal("d6fc1a38c5fb8c2a4a63eb276613643f09d9f67270129256284b7fc37aa56b82", eid.String())
+
+	require.Equal(common.ConsensusForks{}, node.consensusForks(now))
+	require.Equal(common.ConsensusForks{}, node.consensusForks(math.MaxUint64))
+	require.True(mainnetForkActive(now, now))
+	require.False(mainnetForkActive(now, now-1))
+	require.False(mainnetForkActive(mainnetConsensusForkUnscheduled, math.MaxUint64))
 }
 
 func TestNodeRemovePossibility(t *testing.T) {
@@ -75,7 +86,7 @@ func TestNodeRemovePossibility(t *testing.T) {
 	err = tx.SignInput(node.persistStore, 0, []*common.Address{&node.Signer})
 	require.NotNil(err)
 	require.Contains(err.Error(), "invalid key for the input")
-	err = tx.Validate(node.persistStore, uint64(time.Now().UnixNano()), false)
+	err = tx.Validate(node.persistStore, uint64(time.Now().UnixNano()), false, node.consensusForks(uint64(time.Now().UnixNano())))
 	require.Nil(err)
 
 	payee, err := common.NewAddressFromString("XIN4GLKJRtaquYDE49MraHWeKKyoWVmS58qvXQY845pxLECzm86RmkVZEwWMHo8ZRMd2Q8MziDvre5RrC8Lkty4kFeuZ2aYg")
@@ -117,7 +128,7 @@ func setupTestNode(require *require.Assertions, dir string) *Node {
 	})
 	require.Nil(err)
 
-	store, err := storage.NewBadgerStore(custom, dir)
+	store, err := storage.NewMemoryStore(custom)
 	require.Nil(err)
 	require.NotNil(store)
 	node, err := SetupNode(custom, store, cache, gns)
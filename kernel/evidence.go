@@ -0,0 +1,185 @@
+package kernel
+
+import (
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+const EvidenceExportBatchSize = 500
+
+var (
+	EvidenceRoundsHeader     = []string{"chain", "round", "start", "end", "duration", "snapshots"}
+	EvidenceSnapshotsHeader  = []string{"topology", "chain", "round", "timestamp", "signers", "positions"}
+	EvidenceReferencesHeader = []string{"chain", "round", "external_chain", "external_round", "delay"}
+)
+
+type EvidenceStore interface {
+	ReadSnapshotsSinceTopology(offset, count uint64) ([]*common.SnapshotWithTopologicalOrder, error)
+	ReadRound(hash crypto.Hash) (*common.Round, error)
+}
+
+type evidenceRound struct {
+	chain     string
+	number    uint64
+	start     uint64
+	end       uint64
+	snapshots int
+}
+
+type evidenceExporter struct {
+	store      EvidenceStore
+	salt       []byte
+	rounds     *csv.Writer
+	snapshots  *csv.Writer
+	references *csv.Writer
+	heads      map[crypto.Hash]*evidenceRound
+}
+
+// ExportConsensusEvidence writes the round timing, signer participation and
+// reference delay datasets of the snapshots since the topology offset, as
+// documented in doc/consensus-evidence-export.md. The node ids are replaced
+// by the salted pseudonyms, and no amounts, keys or hashes are exported.
+func ExportConsensusEvidence(store EvidenceStore, salt []byte, offset, count uint64, rounds, snapshots, references io.Writer) (uint64, error) {
+	if len(salt) == 0 {
+		return offset, fmt.Errorf("invalid evidence pseudonym salt")
+	}
+	ee := &evidenceExporter{
+		store:      store,
+		salt:       salt,
+		rounds:     csv.NewWriter(rounds),
+		snapshots:  csv.NewWriter(snapshots),
+		references: csv.NewWriter(references),
+		heads:      make(map[crypto.Hash]*evidenceRound),
+	}
+	for _, w := range []struct {
+		writer *csv.Writer
+		header []string
+	}{
+		{ee.rounds, EvidenceRoundsHeader},
+		{ee.snapshots, EvidenceSnapshotsHeader},
+		{ee.references, EvidenceReferencesHeader},
+	} {
+		err := w.writer.Write(w.header)
+		if err != nil {
+			return offset, err
+		}
+	}
+
+	end := offset + count
+	for offset < end {
+		batch := min(end-offset, EvidenceExportBatchSize)
+		snaps, err := store.ReadSnapshotsSinceTopology(offset, batch)
+		if err != nil {
+			return offset, err
+		}
+		for _, s := range snaps {
+			err = ee.export(s)
+			if err != nil {
+				return offset, err
+			}
+			offset = s.TopologicalOrder + 1
+		}
+		if uint64(len(snaps)) < batch {
+			break
+		}
+	}
+	heads := make([]*evidenceRound, 0, len(ee.heads))
+	for _, r := range ee.heads {
+		heads = append(heads, r)
+	}
+	sort.Slice(heads, func(i, j int) bool {
+		return heads[i].chain < heads[j].chain
+	})
+	for _, r := range heads {
+		err := ee.writeRound(r)
+		if err != nil {
+			return offset, err
+		}
+	}
+	for _, w := range []*csv.Writer{ee.rounds, ee.snapshots, ee.references} {
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return offset, err
+		}
+	}
+	return offset, nil
+}
+
+func (ee *evidenceExporter) export(s *common.SnapshotWithTopologicalOrder) error {
+	chain := ee.pseudonym(s.NodeId)
+	r := ee.heads[s.NodeId]
+	if r == nil || r.number != s.RoundNumber {
+		if r != nil {
+			err := ee.writeRound(r)
+			if err != nil {
+				return err
+			}
+		}
+		r = &evidenceRound{chain: chain, number: s.RoundNumber, start: s.Timestamp, end: s.Timestamp}
+		ee.heads[s.NodeId] = r
+		err := ee.writeReference(s, chain)
+		if err != nil {
+			return err
+		}
+	}
+	r.start = min(r.start, s.Timestamp)
+	r.end = max(r.end, s.Timestamp)
+	r.snapshots += 1
+
+	var positions []string
+	if s.Signature != nil {
+		for _, k := range s.Signature.Keys() {
+			positions = append(positions, strconv.Itoa(k))
+		}
+	}
+	return ee.snapshots.Write([]string{
+		strconv.FormatUint(s.TopologicalOrder, 10),
+		chain,
+		strconv.FormatUint(s.RoundNumber, 10),
+		strconv.FormatUint(s.Timestamp, 10),
+		strconv.Itoa(len(positions)),
+		strings.Join(positions, ";"),
+	})
+}
+
+func (ee *evidenceExporter) writeReference(s *common.SnapshotWithTopologicalOrder, chain string) error {
+	if s.References == nil {
+		return nil
+	}
+	external, err := ee.store.ReadRound(s.References.External)
+	if err != nil || external == nil {
+		return err
+	}
+	return ee.references.Write([]string{
+		chain,
+		strconv.FormatUint(s.RoundNumber, 10),
+		ee.pseudonym(external.NodeId),
+		strconv.FormatUint(external.Number, 10),
+		strconv.FormatInt(int64(s.Timestamp)-int64(external.Timestamp), 10),
+	})
+}
+
+func (ee *evidenceExporter) writeRound(r *evidenceRound) error {
+	return ee.rounds.Write([]string{
+		r.chain,
+		strconv.FormatUint(r.number, 10),
+		strconv.FormatUint(r.start, 10),
+		strconv.FormatUint(r.end, 10),
+		strconv.FormatUint(r.end-r.start, 10),
+		strconv.Itoa(r.snapshots),
+	})
+}
+
+func (ee *evidenceExporter) pseudonym(id crypto.Hash) string {
+	buf := append([]byte{}, ee.salt...)
+	h := crypto.Blake3Hash(append(buf, id[:]...))
+	return hex.EncodeToString(h[:8])
+}
@@ -0,0 +1,116 @@
+package kernel
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+type evidenceStoreImpl struct {
+	snapshots []*common.SnapshotWithTopologicalOrder
+	rounds    map[crypto.Hash]*common.Round
+}
+
+func (s *evidenceStoreImpl) ReadSnapshotsSinceTopology(offset, count uint64) ([]*common.SnapshotWithTopologicalOrder, error) {
+	var snapshots []*common.SnapshotWithTopologicalOrder
+	for _, snap := range s.snapshots {
+		if snap.TopologicalOrder >= offset && uint64(len(snapshots)) < count {
+			snapshots = append(snapshots, snap)
+		}
+	}
+	return snapshots, nil
+}
+
+func (s *evidenceStoreImpl) ReadRound(hash crypto.Hash) (*common.Round, error) {
+	return s.rounds[hash], nil
+}
+
+func TestExportConsensusEvidence(t *testing.T) {
+	require := require.New(t)
+
+	a, b := crypto.Blake3Hash([]byte("a")), crypto.Blake3Hash([]byte("b"))
+	external := crypto.Blake3Hash([]byte("external"))
+	store := &evidenceStoreImpl{rounds: map[crypto.Hash]*common.Round{
+		external: {Hash: external, NodeId: b, Number: 0, Timestamp: 1000},
+	}}
+	snap := func(node crypto.Hash, round, ts uint64, mask uint64, references *common.RoundLink) {
+		s := &common.SnapshotWithTopologicalOrder{
+			Snapshot: &common.Snapshot{
+				NodeId:      node,
+				RoundNumber: round,
+				Timestamp:   ts,
+				References:  references,
+			},
+			TopologicalOrder: uint64(len(store.snapshots)),
+		}
+		if mask > 0 {
+			s.Signature = &crypto.CosiSignature{Mask: mask}
+		}
+		store.snapshots = append(store.snapshots, s)
+	}
+	snap(a, 0, 900, 0, nil)
+	snap(b, 0, 1000, 0, nil)
+	snap(a, 0, 1100, 0, nil)
+	references := &common.RoundLink{External: external}
+	snap(a, 1, 1500, 0b1011, references)
+	snap(b, 1, 1600, 0b0111, nil)
+	snap(a, 1, 1700, 0b1101, references)
+
+	var rounds, snapshots, refs bytes.Buffer
+	_, err := ExportConsensusEvidence(store, nil, 0, 100, &rounds, &snapshots, &refs)
+	require.NotNil(err)
+
+	next, err := ExportConsensusEvidence(store, []byte("salt"), 0, 100, &rounds, &snapshots, &refs)
+	require.Nil(err)
+	require.Equal(uint64(6), next)
+
+	read := func(buf *bytes.Buffer) [][]string {
+		records, err := csv.NewReader(buf).ReadAll()
+		require.Nil(err)
+		return records
+	}
+	records := read(&rounds)
+	require.Len(records, 5)
+	require.Equal(EvidenceRoundsHeader, records[0])
+	pa, pb := records[1][0], records[2][0]
+	require.NotEqual(pa, pb)
+	require.Len(pa, 16)
+	require.Equal([]string{pa, "0", "900", "1100", "200", "2"}, records[1])
+	require.Equal([]string{pb, "0", "1000", "1000", "0", "1"}, records[2])
+	tail := records[3:]
+	if tail[0][0] != pa {
+		tail[0], tail[1] = tail[1], tail[0]
+	}
+	require.Equal([]string{pa, "1", "1500", "1700", "200", "2"}, tail[0])
+	require.Equal([]string{pb, "1", "1600", "1600", "0", "1"}, tail[1])
+
+	records = read(&snapshots)
+	require.Len(records, 7)
+	require.Equal(EvidenceSnapshotsHeader, records[0])
+	require.Equal([]string{"0", pa, "0", "900", "0", ""}, records[1])
+	require.Equal([]string{"3", pa, "1", "1500", "3", "0;1;3"}, records[4])
+
+	records = read(&refs)
+	require.Len(records, 2)
+	require.Equal(EvidenceReferencesHeader, records[0])
+	require.Equal([]string{pa, "1", pb, "0", "500"}, records[1])
+
+	rounds.Reset()
+	snapshots.Reset()
+	refs.Reset()
+	next, err = ExportConsensusEvidence(store, []byte("salt"), 4, 1, &rounds, &snapshots, &refs)
+	require.Nil(err)
+	require.Equal(uint64(5), next)
+	records = read(&rounds)
+	require.Len(records, 2)
+	require.Equal([]string{pb, "1", "1600", "1600", "0", "1"}, records[1])
+	next, err = ExportConsensusEvidence(store, []byte("other"), 0, 100, &rounds, &snapshots, &refs)
+	require.Nil(err)
+	require.Equal(uint64(6), next)
+	records = read(&rounds)
+	require.NotEqual(pa, records[1][0])
+}
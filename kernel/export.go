@@ -0,0 +1,201 @@
+package kernel
+
+import (
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+const TransactionsExportBatchSize = 500
+
+var (
+	ExportTransactionsColumns = []string{
+		"topology",
+		"snapshot",
+		"timestamp",
+		"chain",
+		"round",
+		"hash",
+		"version",
+		"type",
+		"asset",
+		"inputs",
+		"outputs",
+		"references",
+		"extra",
+	}
+	ExportOutputsColumns = []string{
+		"topology",
+		"transaction",
+		"index",
+		"type",
+		"asset",
+		"amount",
+		"keys",
+		"mask",
+		"script",
+		"withdrawal_address",
+		"withdrawal_tag",
+	}
+)
+
+type TransactionsExportStore interface {
+	ReadSnapshotsSinceTopology(offset, count uint64) ([]*common.SnapshotWithTopologicalOrder, error)
+	ReadTransaction(hash crypto.Hash) (*common.VersionedTransaction, string, error)
+}
+
+type csvExportTable struct {
+	writer *csv.Writer
+}
+
+func (t *csvExportTable) Write(row []any) error {
+	record := make([]string, len(row))
+	for i, v := range row {
+		switch v := v.(type) {
+		case uint64:
+			record[i] = strconv.FormatUint(v, 10)
+		case string:
+			record[i] = v
+		default:
+			return fmt.Errorf("invalid export value %v", v)
+		}
+	}
+	return t.writer.Write(record)
+}
+
+func (t *csvExportTable) Close() error {
+	t.writer.Flush()
+	return t.writer.Error()
+}
+
+func newExportTable(w io.Writer, columns []string) (*csvExportTable, error) {
+	t := &csvExportTable{writer: csv.NewWriter(w)}
+	return t, t.writer.Write(columns)
+}
+
+// ExportTransactions writes the finalized transactions of the snapshots since
+// the topology offset, and all their outputs, as the csv tables documented
+// in doc/transactions-export.md, at most count snapshots are read
+// and the next topology to continue is returned.
+func ExportTransactions(store TransactionsExportStore, offset, count uint64, transactions, outputs io.Writer) (uint64, error) {
+	txs, err := newExportTable(transactions, ExportTransactionsColumns)
+	if err != nil {
+		return offset, err
+	}
+	outs, err := newExportTable(outputs, ExportOutputsColumns)
+	if err != nil {
+		return offset, err
+	}
+
+	end := offset + count
+	for offset < end {
+		batch := min(end-offset, TransactionsExportBatchSize)
+		snaps, err := store.ReadSnapshotsSinceTopology(offset, batch)
+		if err != nil {
+			return offset, err
+		}
+		for _, s := range snaps {
+			for _, h := range s.Transactions {
+				tx, _, err := store.ReadTransaction(h)
+				if err != nil {
+					return offset, err
+				}
+				if tx == nil {
+					return offset, fmt.Errorf("finalized transaction %s not found", h)
+				}
+				err = exportTransaction(txs, outs, s, tx)
+				if err != nil {
+					return offset, err
+				}
+			}
+			offset = s.TopologicalOrder + 1
+		}
+		if uint64(len(snaps)) < batch {
+			break
+		}
+	}
+
+	for _, t := range []*csvExportTable{txs, outs} {
+		err := t.Close()
+		if err != nil {
+			return offset, err
+		}
+	}
+	return offset, nil
+}
+
+func exportTransaction(txs, outs *csvExportTable, s *common.SnapshotWithTopologicalOrder, tx *common.VersionedTransaction) error {
+	hash := tx.PayloadHash()
+	var inputs []string
+	for _, in := range tx.Inputs {
+		switch {
+		case in.Hash.HasValue():
+			inputs = append(inputs, fmt.Sprintf("%s:%d", in.Hash, in.Index))
+		case len(in.Genesis) > 0:
+			inputs = append(inputs, "genesis")
+		case in.Deposit != nil:
+			inputs = append(inputs, "deposit")
+		case in.Mint != nil:
+			inputs = append(inputs, "mint")
+		}
+	}
+	references := make([]string, len(tx.References))
+	for i, r := range tx.References {
+		references[i] = r.String()
+	}
+	err := txs.Write([]any{
+		s.TopologicalOrder,
+		s.PayloadHash().String(),
+		s.Timestamp,
+		s.NodeId.String(),
+		s.RoundNumber,
+		hash.String(),
+		uint64(tx.Version),
+		uint64(tx.TransactionType()),
+		tx.Asset.String(),
+		strings.Join(inputs, ";"),
+		uint64(len(tx.Outputs)),
+		strings.Join(references, ";"),
+		hex.EncodeToString(tx.Extra),
+	})
+	if err != nil {
+		return err
+	}
+
+	for i, out := range tx.Outputs {
+		keys := make([]string, len(out.Keys))
+		for j, k := range out.Keys {
+			keys[j] = k.String()
+		}
+		var mask, address, tag string
+		if out.Mask.HasValue() {
+			mask = out.Mask.String()
+		}
+		if w := out.Withdrawal; w != nil {
+			address, tag = w.Address, w.Tag
+		}
+		err := outs.Write([]any{
+			s.TopologicalOrder,
+			hash.String(),
+			uint64(i),
+			uint64(out.Type),
+			tx.Asset.String(),
+			out.Amount.String(),
+			strings.Join(keys, ";"),
+			mask,
+			out.Script.String(),
+			address,
+			tag,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
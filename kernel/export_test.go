@@ -0,0 +1,96 @@
+package kernel
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+type transactionsExportStoreImpl struct {
+	evidenceStoreImpl
+	transactions map[crypto.Hash]*common.VersionedTransaction
+}
+
+func (s *transactionsExportStoreImpl) ReadTransaction(hash crypto.Hash) (*common.VersionedTransaction, string, error) {
+	tx := s.transactions[hash]
+	if tx == nil {
+		return nil, "", nil
+	}
+	return tx, "snapshot", nil
+}
+
+func TestExportTransactions(t *testing.T) {
+	require := require.New(t)
+
+	store := &transactionsExportStoreImpl{transactions: make(map[crypto.Hash]*common.VersionedTransaction)}
+	node := crypto.Blake3Hash([]byte("node"))
+	seed := make([]byte, 64)
+	accounts := []*common.Address{}
+	for i := range 2 {
+		seed[0] = byte(i)
+		addr := common.NewAddressFromSeed(seed)
+		accounts = append(accounts, &addr)
+	}
+	for i := range 3 {
+		tx := common.NewTransactionV5(common.XINAssetId)
+		tx.AddInput(crypto.Blake3Hash([]byte("export")), uint(i))
+		tx.AddScriptOutput(accounts, common.NewThresholdScript(1), common.NewIntegerFromString("1.5"), seed)
+		if i == 2 {
+			tx.AddOutputWithType(common.OutputTypeScript, nil, common.NewThresholdScript(64), common.NewIntegerFromString("0.5"), seed)
+			tx.Extra = []byte("extra")
+		}
+		ver := tx.AsVersioned()
+		store.transactions[ver.PayloadHash()] = ver
+		store.snapshots = append(store.snapshots, &common.SnapshotWithTopologicalOrder{
+			Snapshot: &common.Snapshot{
+				Version:      common.SnapshotVersionCommonEncoding,
+				NodeId:       node,
+				RoundNumber:  uint64(i),
+				Timestamp:    uint64(1000 + i),
+				Transactions: []crypto.Hash{ver.PayloadHash()},
+			},
+			TopologicalOrder: uint64(i),
+		})
+	}
+
+	var transactions, outputs bytes.Buffer
+	next, err := ExportTransactions(store, 1, 10, &transactions, &outputs)
+	require.Nil(err)
+	require.Equal(uint64(3), next)
+
+	read := func(buf *bytes.Buffer) [][]string {
+		records, err := csv.NewReader(buf).ReadAll()
+		require.Nil(err)
+		return records
+	}
+	records := read(&transactions)
+	require.Len(records, 3)
+	require.Equal("topology", records[0][0])
+	last := store.snapshots[2]
+	tx := store.transactions[last.Transactions[0]]
+	require.Equal([]string{
+		"2", last.PayloadHash().String(), "1002", node.String(), "2",
+		tx.PayloadHash().String(), "5", "0", common.XINAssetId.String(),
+		crypto.Blake3Hash([]byte("export")).String() + ":2", "2", "", "6578747261",
+	}, records[2])
+
+	records = read(&outputs)
+	require.Len(records, 4)
+	require.Equal("transaction", records[0][1])
+	require.Equal(tx.PayloadHash().String(), records[3][1])
+	require.Equal("1", records[3][2])
+	require.Equal("0.50000000", records[3][5])
+	require.Equal("", records[3][6])
+	require.Equal("fffe40", records[3][8])
+	require.Len(records[1][6], 64*2+1)
+	require.NotEqual("", records[1][7])
+
+	delete(store.transactions, tx.PayloadHash())
+	next, err = ExportTransactions(store, 0, 10, &transactions, &outputs)
+	require.NotNil(err)
+	require.Equal(uint64(2), next)
+}
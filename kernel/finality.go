@@ -0,0 +1,42 @@
+package kernel
+
+import (
+	"fmt"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+// FinalityKeys returns the consensus keys and threshold which the signature
+// of the finalized snapshot is verified with, in the order of the mask, so
+// the finality proof could be verified without the graph.
+func (node *Node) FinalityKeys(s *common.Snapshot) ([]*crypto.Key, int, error) {
+	if s.Signature == nil {
+		return nil, 0, fmt.Errorf("snapshot %s not finalized by cosi", s.Hash)
+	}
+	chain := node.getChain(s.NodeId)
+	if chain == nil {
+		return nil, 0, fmt.Errorf("chain %s not found", s.NodeId)
+	}
+
+	timestamp, err := chain.finalizationTimestamp(s)
+	if err != nil {
+		return nil, 0, err
+	}
+	_, publics := chain.ConsensusKeys(s.RoundNumber, timestamp)
+	base := node.ConsensusThreshold(timestamp, true)
+	if s.Signature.FullVerify(publics, base, s.Hash) == nil {
+		return publics, base, nil
+	}
+
+	timestamp, fork := chain.nodeRemovalForkTimestamp(timestamp)
+	if !fork {
+		return nil, 0, fmt.Errorf("snapshot %s finality keys not found", s.Hash)
+	}
+	_, publics = chain.ConsensusKeys(s.RoundNumber, timestamp)
+	base = node.ConsensusThreshold(timestamp, true)
+	if s.Signature.FullVerify(publics, base, s.Hash) == nil {
+		return publics, base, nil
+	}
+	return nil, 0, fmt.Errorf("snapshot %s finality keys not found", s.Hash)
+}
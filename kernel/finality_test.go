@@ -0,0 +1,35 @@
+package kernel
+
+import (
+	"os"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFinalityKeysBeforeEpoch(t *testing.T) {
+	require := require.New(t)
+
+	root, err := os.MkdirTemp("", "mixin-finality-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+
+	s := &common.Snapshot{
+		Version:   common.SnapshotVersionCommonEncoding,
+		NodeId:    node.genesisNodes[0],
+		Timestamp: node.Epoch - 1,
+		Signature: &crypto.CosiSignature{},
+	}
+	s.AddSoleTransaction(crypto.Blake3Hash([]byte("finality")))
+	s.Hash = s.PayloadHash()
+	_, _, err = node.FinalityKeys(s)
+	require.ErrorContains(err, "before epoch")
+	signers, finalized := node.getChain(s.NodeId).verifyFinalization(s)
+	require.Nil(signers)
+	require.False(finalized)
+}
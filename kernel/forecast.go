@@ -0,0 +1,92 @@
+package kernel
+
+import (
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+type MintForecastNode struct {
+	Id    crypto.Hash
+	Payee common.Address
+	Works [2]uint64
+	Share common.Integer
+}
+
+// MintForecast projects the next universal mint batch, the node shares are
+// estimated by the works accumulated so far, which are final only after the
+// day before the mint ends, and Error tells why the shares are not available.
+type MintForecast struct {
+	Batch     uint64
+	Timestamp uint64
+	Pool      common.Integer
+	Amount    common.Integer
+	Kernel    common.Integer
+	Custodian common.Integer
+	Light     common.Integer
+	Nodes     []*MintForecastNode
+	Error     string
+}
+
+func (node *Node) MintForecast(now uint64) (*MintForecast, error) {
+	dist := node.lastMintDistribution()
+	var batch uint64
+	if now > node.Epoch {
+		batch = (now - node.Epoch) / OneDay
+	}
+	if batch <= dist.Batch {
+		batch = dist.Batch + 1
+	}
+
+	amount := mintMultiBatchesSize(dist.Batch, batch)
+	kernel := amount.Div(10).Mul(5)
+	custodian := amount.Div(10).Mul(4)
+	timestamp := node.Epoch + batch*OneDay + uint64(config.KernelMintTimeBegin)*uint64(time.Hour)
+	f := &MintForecast{
+		Batch:     batch,
+		Timestamp: timestamp,
+		Pool:      poolSizeUniversal(int(dist.Batch)),
+		Amount:    amount,
+		Kernel:    kernel,
+		Custodian: custodian,
+		Light:     amount.Sub(kernel).Sub(custodian),
+	}
+
+	accepted := node.NodesListWithoutState(timestamp, true)
+	mints := make([]*CNodeWork, len(accepted))
+	cids := make([]crypto.Hash, len(accepted))
+	for i, n := range accepted {
+		cids[i] = n.IdForNetwork
+		mints[i] = &CNodeWork{CNode: *n}
+	}
+
+	// the mint takes the works of the day before, which is still accumulating
+	// or in the future, then the works of today are the best estimation
+	day := timestamp/OneDay - 1
+	if today := now / OneDay; day > today {
+		day = today
+	}
+	works, err := node.persistStore.ListNodeWorks(cids, uint32(day))
+	if err != nil {
+		return nil, err
+	}
+	thr := node.ConsensusThreshold(timestamp, false)
+	err = distributeMintWorks(mints, works, kernel, thr, day)
+	if err != nil {
+		f.Error = err.Error()
+	}
+	for _, m := range mints {
+		n := &MintForecastNode{
+			Id:    m.IdForNetwork,
+			Payee: m.Payee,
+			Works: works[m.IdForNetwork],
+		}
+		if err == nil {
+			n.Share = m.Work
+		}
+		f.Nodes = append(f.Nodes, n)
+	}
+	return f, nil
+}
@@ -0,0 +1,90 @@
+package kernel
+
+import (
+	"sync"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/mixin/p2p"
+)
+
+// the sync frontier of a chain is the last round requested and the last round
+// verified in the batches pulled from the peers. the verified snapshots are
+// persisted with the frontier, so the node restarted during the initial sync
+// queues them again and continues to pull after them, instead of downloading
+// them again.
+type syncFrontier struct {
+	requested uint64
+	verified  uint64
+}
+
+type syncFrontierMap struct {
+	sync.RWMutex
+	m map[crypto.Hash]syncFrontier
+}
+
+func newSyncFrontierMap() *syncFrontierMap {
+	return &syncFrontierMap{m: make(map[crypto.Hash]syncFrontier)}
+}
+
+func (fm *syncFrontierMap) Get(nodeId crypto.Hash) syncFrontier {
+	fm.RLock()
+	defer fm.RUnlock()
+	return fm.m[nodeId]
+}
+
+func (fm *syncFrontierMap) Set(nodeId crypto.Hash, f syncFrontier) {
+	fm.Lock()
+	defer fm.Unlock()
+	fm.m[nodeId] = f
+}
+
+func (node *Node) UpdateSyncFrontier(nodeId crypto.Hash, requested uint64) error {
+	f := node.frontiers.Get(nodeId)
+	f.requested = requested
+	node.frontiers.Set(nodeId, f)
+	return node.persistStore.CacheWriteSyncFrontier(nodeId, f.requested, f.verified, nil)
+}
+
+// the verified round moves back if the pulling restarts from the local final
+// round, because the rounds after it may be dropped by the prevalidation.
+func (node *Node) writeSyncBatch(b *p2p.SnapshotBatch) error {
+	f := node.frontiers.Get(b.NodeId)
+	f.requested, f.verified = max(f.requested, b.To), b.To
+	node.frontiers.Set(b.NodeId, f)
+	return node.persistStore.CacheWriteSyncFrontier(b.NodeId, f.requested, f.verified, b.Snapshots)
+}
+
+// resumeSyncFrontiers queues the persisted snapshots after the local final
+// round of each chain, until the first round missing in the cache, which
+// may expire already, then the pulling continues after that round.
+func (node *Node) resumeSyncFrontiers() {
+	for _, p := range node.BuildGraph() {
+		requested, verified, err := node.persistStore.CacheReadSyncFrontier(p.NodeId)
+		if err != nil {
+			logger.Printf("resumeSyncFrontiers(%s) => %v\n", p.NodeId, err)
+			continue
+		}
+		if verified <= p.Number {
+			continue
+		}
+		last := p.Number
+		for n := p.Number + 1; n <= verified; n++ {
+			ss, err := node.persistStore.CacheReadSyncSnapshots(p.NodeId, n)
+			if err != nil || len(ss) == 0 {
+				break
+			}
+			for _, s := range ss {
+				select {
+				case node.prevalidations <- &prevalidationJob{PeerId: node.IdForNetwork, Snapshot: s}:
+				case <-node.done:
+					return
+				}
+			}
+			last = n
+		}
+		node.frontiers.Set(p.NodeId, syncFrontier{requested: requested, verified: last})
+		node.Peer.ResumeSnapshotBatches(p.NodeId, p.Number, last)
+		logger.Printf("resumeSyncFrontiers(%s) %d %d %d %d\n", p.NodeId, p.Number, last, verified, requested)
+	}
+}
@@ -5,6 +5,10 @@ import "github.com/MixinNetwork/mixin/common"
 func (node *Node) LoadGenesis(gns *common.Genesis) error {
 	node.Epoch = gns.EpochTimestamp()
 	node.networkId = gns.NetworkId()
+	node.devMode = gns.Dev
+	if node.devMode {
+		node.persistStore.EnableDevGenesis()
+	}
 	node.IdForNetwork = node.Signer.Hash().ForNetwork(node.networkId)
 	for _, in := range gns.Nodes {
 		id := in.Signer.Hash().ForNetwork(node.networkId)
@@ -7,7 +7,10 @@ import (
 	"time"
 
 	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
 	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/storage"
+	"github.com/dgraph-io/ristretto/v2"
 	"github.com/stretchr/testify/require"
 )
 
@@ -64,6 +67,178 @@ func TestGenesis(t *testing.T) {
 	require.Equal(common.NewInteger(13439).Mul(27).Add(common.NewInteger(2700)), balance)
 }
 
+func TestDevGenesis(t *testing.T) {
+	require := require.New(t)
+
+	root, err := os.MkdirTemp("", "mixin-dev-genesis-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	data, err := os.ReadFile("../config/genesis.json")
+	require.Nil(err)
+	var inputs map[string]any
+	err = json.Unmarshal(data, &inputs)
+	require.Nil(err)
+	inputs["nodes"] = inputs["nodes"].([]any)[:1]
+	data, err = json.Marshal(inputs)
+	require.Nil(err)
+	err = os.WriteFile(root+"/genesis.json", data, 0644)
+	require.Nil(err)
+	_, err = common.ReadGenesis(root + "/genesis.json")
+	require.NotNil(err)
+
+	inputs["dev"] = true
+	data, err = json.Marshal(inputs)
+	require.Nil(err)
+	err = os.WriteFile(root+"/genesis.json", data, 0644)
+	require.Nil(err)
+	gns, err := common.ReadGenesis(root + "/genesis.json")
+	require.Nil(err)
+	require.True(gns.Dev)
+	require.NotEqual(mainnetId, gns.NetworkId().String())
+
+	err = os.WriteFile(root+"/config.toml", configData, 0644)
+	require.Nil(err)
+	custom, err := config.Initialize(root + "/config.toml")
+	require.Nil(err)
+	cache, err := ristretto.NewCache(&ristretto.Config[[]byte, any]{
+		NumCounters: 1e7,
+		MaxCost:     1 << 30,
+		BufferItems: 64,
+	})
+	require.Nil(err)
+	store, err := storage.NewBadgerStore(custom, root)
+	require.Nil(err)
+	defer store.Close()
+	node, err := SetupNode(custom, store, cache, gns)
+	require.Nil(err)
+	require.True(node.devMode)
+	require.Equal(gns.NetworkId(), node.networkId)
+
+	now := node.Epoch + 1
+	nodes := node.NodesListWithoutState(now, true)
+	require.Len(nodes, 1)
+	require.Equal(1, node.ConsensusThreshold(now, false))
+	require.Equal(1, node.ConsensusThreshold(now, true))
+	eid, err := node.electSnapshotNode(common.TransactionTypeMint, now)
+	require.Nil(err)
+	require.Equal(nodes[0].IdForNetwork, eid)
+	require.Len(node.NodesListWithoutState(node.Epoch-1, true), 0)
+	require.Equal(common.ConsensusForks{EscrowScript: true, LockTimeScript: true, NodePayeeChange: true}, node.consensusForks(now))
+	_, err = node.electSnapshotNode(common.TransactionTypeMint, node.Epoch-1)
+	require.ErrorContains(err, "no accepted node to elect")
+
+	snapshots, err := node.persistStore.ReadSnapshotsSinceTopology(0, 100)
+	require.Nil(err)
+	require.Len(snapshots, 2)
+	round, err := node.persistStore.ReadRound(nodes[0].IdForNetwork)
+	require.Nil(err)
+	require.Equal(uint64(1), round.Number)
+	external, err := node.persistStore.ReadRound(round.References.External)
+	require.Nil(err)
+	require.Equal(nodes[0].IdForNetwork, external.NodeId)
+	require.Equal(uint64(0), external.Number)
+}
+
+func TestParamsGenesis(t *testing.T) {
+	require := require.New(t)
+	defer config.SetConsensusParams(config.ConsensusParams{
+		RoundGap:            config.SnapshotRoundGapDefault,
+		MinimumNodes:        config.KernelMinimumNodesCountDefault,
+		PledgePeriodMinimum: config.KernelNodePledgePeriodMinimumDefault,
+		AcceptPeriodMinimum: config.KernelNodeAcceptPeriodMinimumDefault,
+		AcceptPeriodMaximum: config.KernelNodeAcceptPeriodMaximumDefault,
+		TransactionSize:     config.TransactionMaximumSizeDefault,
+		OutputsCount:        config.TransactionOutputsMaximumDefault,
+		ExtraSize:           config.TransactionExtraSizeMaximumDefault,
+	})
+
+	root, err := os.MkdirTemp("", "mixin-params-genesis-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	data, err := os.ReadFile("../config/genesis.json")
+	require.Nil(err)
+	var inputs map[string]any
+	err = json.Unmarshal(data, &inputs)
+	require.Nil(err)
+	inputs["nodes"] = inputs["nodes"].([]any)[:4]
+	params := map[string]any{"pledge_period_minimum": "2h"}
+	inputs["params"] = params
+	for _, c := range []struct {
+		key   string
+		value any
+		err   string
+	}{
+		{"round_gap", "50ms", "invalid genesis params: invalid round gap 50ms"},
+		{"round_gap", "1 second", "invalid genesis params round_gap 1 second"},
+		{"minimum_nodes", 5, "invalid genesis inputs number 4/5"},
+		{"accept_period_minimum", "30m", "invalid genesis params: invalid accept period minimum 30m0s"},
+		{"accept_period_maximum", "1h", "invalid genesis params: invalid accept period maximum 1h0m0s"},
+		{"transaction_size", 1024, "invalid genesis params: invalid transaction size 1024"},
+		{"outputs_count", 2048, "invalid genesis params: invalid outputs count 2048"},
+		{"extra_size", 128, "invalid genesis params: invalid extra size 128"},
+		{"minimum_nodes", 4, ""},
+	} {
+		params["round_gap"] = "1s"
+		params["minimum_nodes"] = 4
+		params["accept_period_minimum"] = "2h"
+		params["accept_period_maximum"] = "24h"
+		params["transaction_size"] = 1024 * 1024
+		params["outputs_count"] = 1024
+		params["extra_size"] = 4096
+		params[c.key] = c.value
+		data, err = json.Marshal(inputs)
+		require.Nil(err)
+		err = os.WriteFile(root+"/genesis.json", data, 0644)
+		require.Nil(err)
+		_, err = common.ReadGenesis(root + "/genesis.json")
+		if c.err != "" {
+			require.ErrorContains(err, c.err)
+		} else {
+			require.Nil(err)
+		}
+	}
+	gns, err := common.ReadGenesis(root + "/genesis.json")
+	require.Nil(err)
+	require.False(gns.Dev)
+	require.NotEqual(config.KernelNetworkId, gns.NetworkId().String())
+
+	err = os.WriteFile(root+"/config.toml", configData, 0644)
+	require.Nil(err)
+	custom, err := config.Initialize(root + "/config.toml")
+	require.Nil(err)
+	cache, err := ristretto.NewCache(&ristretto.Config[[]byte, any]{
+		NumCounters: 1e7,
+		MaxCost:     1 << 30,
+		BufferItems: 64,
+	})
+	require.Nil(err)
+	store, err := storage.NewBadgerStore(custom, root)
+	require.Nil(err)
+	_, err = SetupNode(custom, store, cache, gns)
+	require.NotNil(err)
+	require.Contains(err.Error(), "not applied")
+	err = ApplyConsensusParams(gns)
+	require.Nil(err)
+	node, err := SetupNode(custom, store, cache, gns)
+	require.Nil(err)
+	require.False(node.devMode)
+	require.Equal(uint64(time.Second), config.SnapshotRoundGap)
+	require.Equal(4, config.KernelMinimumNodesCount)
+	require.Equal(4, node.minimumNodesCount())
+	require.Equal(2*time.Hour, config.KernelNodePledgePeriodMinimum)
+	require.Equal(2*time.Hour, config.KernelNodeAcceptPeriodMinimum)
+	require.Equal(24*time.Hour, config.KernelNodeAcceptPeriodMaximum)
+	require.Equal(1024*1024, config.TransactionMaximumSize)
+	require.Equal(1024, config.TransactionOutputsMaximum)
+	require.Equal(4096, config.TransactionExtraSizeMaximum)
+
+	now := node.Epoch + 1
+	require.Len(node.NodesListWithoutState(now, true), 4)
+	require.Equal(3, node.ConsensusThreshold(now, false))
+}
+
 type SnapshotJSON struct {
 	Version      uint8         `json:"version"`
 	NodeId       crypto.Hash   `json:"node"`
@@ -20,6 +20,7 @@ func (chain *Chain) startNewRoundAndPersist(cache *CacheRound, references *commo
 	} else if final == nil {
 		return nil, nil, false, nil
 	}
+	chain.node.interner.release(cacheTransactions(cache))
 	cache = &CacheRound{
 		NodeId:     chain.ChainId,
 		Number:     final.Number + 1,
@@ -102,7 +103,7 @@ func (chain *Chain) updateEmptyHeadRoundAndPersist(final *FinalRound, cache *Cac
 }
 
 func (chain *Chain) updateExternal(final *FinalRound, external *common.Round, roundTime uint64, strict bool) error {
-	if final.NodeId == external.NodeId {
+	if final.NodeId == external.NodeId && (!chain.node.devMode || external.Number != 0) {
 		return fmt.Errorf("external reference self %s", final.NodeId)
 	}
 	if external.Number < chain.State.RoundLinks[external.NodeId] {
@@ -165,6 +166,7 @@ func (chain *Chain) assignNewGraphRound(final *FinalRound, cache *CacheRound) {
 	}
 
 	chain.StepForward()
+	observeRoundDuration(final)
 	rounds = append(rounds, final.Copy())
 	chain.State.RoundHistory = reduceHistory(rounds)
 }
@@ -277,13 +279,7 @@ func historySinceRound(history []*FinalRound, link uint64) []*FinalRound {
 // Solution: Evil and slash.
 
 func (node *Node) cacheVerifyCosi(snap crypto.Hash, sig *crypto.CosiSignature, cids []crypto.Hash, publics []*crypto.Key, threshold int) ([]crypto.Hash, bool) {
-	key := sig.Signature[:]
-	key = append(snap[:], key...)
-	for _, pub := range publics {
-		key = append(key, pub[:]...)
-	}
-	key = binary.BigEndian.AppendUint64(key, uint64(threshold))
-	key = binary.BigEndian.AppendUint64(key, sig.Mask)
+	key := cosiVerificationCacheKey(snap, sig, publics, threshold)
 	value, found := node.cacheStore.Get(key)
 	if found {
 		signers := convertBytesToSigners(sig, value.([]byte))
@@ -296,14 +292,27 @@ func (node *Node) cacheVerifyCosi(snap crypto.Hash, sig *crypto.CosiSignature, c
 		node.cacheStore.Set(key, []byte{0}, 1)
 		return nil, false
 	}
+	return node.cacheCosiSigners(key, sig, cids), true
+}
 
+func (node *Node) cacheCosiSigners(key []byte, sig *crypto.CosiSignature, cids []crypto.Hash) []crypto.Hash {
 	signers := make([]crypto.Hash, len(sig.Keys()))
 	for i, k := range sig.Keys() {
 		signers[i] = cids[k]
 	}
 	vb := convertSignersToBytes(signers)
 	node.cacheStore.Set(key, vb, int64(len(vb)))
-	return signers, true
+	return signers
+}
+
+func cosiVerificationCacheKey(snap crypto.Hash, sig *crypto.CosiSignature, publics []*crypto.Key, threshold int) []byte {
+	key := sig.Signature[:]
+	key = append(snap[:], key...)
+	for _, pub := range publics {
+		key = append(key, pub[:]...)
+	}
+	key = binary.BigEndian.AppendUint64(key, uint64(threshold))
+	return binary.BigEndian.AppendUint64(key, sig.Mask)
 }
 
 func convertBytesToSigners(sig *crypto.CosiSignature, b []byte) []crypto.Hash {
@@ -344,6 +353,29 @@ func (chain *Chain) ConsensusKeys(round, timestamp uint64) ([]crypto.Hash, []*cr
 	return signers, publics
 }
 
+func (chain *Chain) finalizationTimestamp(s *common.Snapshot) (uint64, error) {
+	timestamp := s.Timestamp
+	if s.Hash.String() == mainnetNodeRemovalHackSnapshotHash {
+		timestamp = timestamp - uint64(time.Minute)
+	}
+	if timestamp < chain.node.Epoch {
+		return 0, fmt.Errorf("snapshot %s timestamp %d before epoch %d", s.Hash, timestamp, chain.node.Epoch)
+	}
+	return timestamp, nil
+}
+
+// nodeRemovalForkTimestamp returns the start of the node accept period if the
+// timestamp is in it, the snapshots finalized then may be signed by the keys
+// before the node removal.
+func (chain *Chain) nodeRemovalForkTimestamp(timestamp uint64) (uint64, bool) {
+	hour := (timestamp - chain.node.Epoch) / uint64(time.Hour) % 24
+	if hour < config.KernelNodeAcceptTimeBegin || hour > config.KernelNodeAcceptTimeEnd {
+		return timestamp, false
+	}
+	elapsed := hour + 1 - config.KernelNodeAcceptTimeBegin
+	return timestamp - elapsed*uint64(time.Hour), true
+}
+
 func (chain *Chain) verifyFinalization(s *common.Snapshot) ([]crypto.Hash, bool) {
 	switch s.Version {
 	case common.SnapshotVersionCommonEncoding:
@@ -355,14 +387,11 @@ func (chain *Chain) verifyFinalization(s *common.Snapshot) ([]crypto.Hash, bool)
 		return nil, false
 	}
 
-	timestamp := s.Timestamp
-	if s.Hash.String() == mainnetNodeRemovalHackSnapshotHash {
-		timestamp = timestamp - uint64(time.Minute)
-	}
-	if timestamp < chain.node.Epoch {
-		panic(timestamp)
+	timestamp, err := chain.finalizationTimestamp(s)
+	if err != nil {
+		logger.Verbosef("verifyFinalization(%v) => %v\n", s, err)
+		return nil, false
 	}
-
 	cids, publics := chain.ConsensusKeys(s.RoundNumber, timestamp)
 	base := chain.node.ConsensusThreshold(timestamp, true)
 	signers, finalized := chain.node.cacheVerifyCosi(s.Hash, s.Signature, cids, publics, base)
@@ -374,12 +403,10 @@ func (chain *Chain) verifyFinalization(s *common.Snapshot) ([]crypto.Hash, bool)
 	}
 
 	logger.Printf("verifyFinalization(%v) node removal time fork check", s)
-	hour := (timestamp - chain.node.Epoch) / uint64(time.Hour) % 24
-	if hour < config.KernelNodeAcceptTimeBegin || hour > config.KernelNodeAcceptTimeEnd {
+	timestamp, fork := chain.nodeRemovalForkTimestamp(timestamp)
+	if !fork {
 		return signers, finalized
 	}
-	elapsed := hour + 1 - config.KernelNodeAcceptTimeBegin
-	timestamp = timestamp - elapsed*uint64(time.Hour)
 	acids, apublics := chain.ConsensusKeys(s.RoundNumber, timestamp)
 	if len(apublics) <= len(publics) {
 		return signers, finalized
@@ -0,0 +1,83 @@
+package kernel
+
+import (
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+const GraphDiffBatchSize = 500
+
+type GraphDiffStore interface {
+	ReadSnapshotsSinceTopology(offset, count uint64) ([]*common.SnapshotWithTopologicalOrder, error)
+	ReadSnapshot(hash crypto.Hash) (*common.SnapshotWithTopologicalOrder, error)
+}
+
+// GraphDivergence is the first topology with different snapshots, either of
+// them is nil if the graph ends there. The snapshot of A may be finalized by
+// B at another topology, which is only a different finalization order and
+// common among different nodes, otherwise B has no such snapshot at all.
+type GraphDivergence struct {
+	Topology  uint64
+	A         *common.SnapshotWithTopologicalOrder
+	B         *common.SnapshotWithTopologicalOrder
+	Reordered bool
+}
+
+// DiffGraph compares the finalized snapshots of the two graphs by topology
+// since the offset, and returns the first divergence with the number of the
+// identical snapshots before it, or nil if both graphs are identical.
+func DiffGraph(a, b GraphDiffStore, offset uint64) (*GraphDivergence, uint64, error) {
+	var identical uint64
+	for {
+		sa, err := a.ReadSnapshotsSinceTopology(offset, GraphDiffBatchSize)
+		if err != nil {
+			return nil, identical, err
+		}
+		sb, err := b.ReadSnapshotsSinceTopology(offset, GraphDiffBatchSize)
+		if err != nil {
+			return nil, identical, err
+		}
+		if len(sa) == 0 && len(sb) == 0 {
+			return nil, identical, nil
+		}
+
+		for i := 0; i < max(len(sa), len(sb)); i++ {
+			var x, y *common.SnapshotWithTopologicalOrder
+			if i < len(sa) {
+				x = sa[i]
+			}
+			if i < len(sb) {
+				y = sb[i]
+			}
+			if x != nil && y != nil && x.TopologicalOrder == y.TopologicalOrder &&
+				x.PayloadHash() == y.PayloadHash() {
+				identical += 1
+				offset = x.TopologicalOrder + 1
+				continue
+			}
+			gd, err := diffGraphDivergence(b, x, y)
+			return gd, identical, err
+		}
+	}
+}
+
+func diffGraphDivergence(b GraphDiffStore, x, y *common.SnapshotWithTopologicalOrder) (*GraphDivergence, error) {
+	gd := &GraphDivergence{A: x, B: y}
+	switch {
+	case x == nil:
+		gd.Topology = y.TopologicalOrder
+	case y == nil || x.TopologicalOrder <= y.TopologicalOrder:
+		gd.Topology = x.TopologicalOrder
+	default:
+		gd.Topology = y.TopologicalOrder
+	}
+	if x == nil {
+		return gd, nil
+	}
+	s, err := b.ReadSnapshot(x.PayloadHash())
+	if err != nil {
+		return nil, err
+	}
+	gd.Reordered = s != nil
+	return gd, nil
+}
@@ -0,0 +1,89 @@
+package kernel
+
+import (
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+type graphDiffStoreImpl struct {
+	snapshots []*common.SnapshotWithTopologicalOrder
+}
+
+func (s *graphDiffStoreImpl) ReadSnapshotsSinceTopology(offset, count uint64) ([]*common.SnapshotWithTopologicalOrder, error) {
+	var list []*common.SnapshotWithTopologicalOrder
+	for _, snap := range s.snapshots {
+		if snap.TopologicalOrder >= offset && uint64(len(list)) < count {
+			list = append(list, snap)
+		}
+	}
+	return list, nil
+}
+
+func (s *graphDiffStoreImpl) ReadSnapshot(hash crypto.Hash) (*common.SnapshotWithTopologicalOrder, error) {
+	for _, snap := range s.snapshots {
+		if snap.PayloadHash() == hash {
+			return snap, nil
+		}
+	}
+	return nil, nil
+}
+
+func TestDiffGraph(t *testing.T) {
+	require := require.New(t)
+
+	node := crypto.Blake3Hash([]byte("node"))
+	build := func(topology, round uint64, tx string) *common.SnapshotWithTopologicalOrder {
+		s := &common.SnapshotWithTopologicalOrder{Snapshot: &common.Snapshot{
+			Version:     common.SnapshotVersionCommonEncoding,
+			NodeId:      node,
+			RoundNumber: round,
+			Timestamp:   1000 + round,
+		}, TopologicalOrder: topology}
+		s.AddSoleTransaction(crypto.Blake3Hash([]byte(tx)))
+		return s
+	}
+
+	var list []*common.SnapshotWithTopologicalOrder
+	for i := range uint64(GraphDiffBatchSize + 10) {
+		list = append(list, build(i, i/10, string(rune(i))))
+	}
+	a := &graphDiffStoreImpl{snapshots: list}
+	b := &graphDiffStoreImpl{snapshots: append([]*common.SnapshotWithTopologicalOrder{}, list...)}
+	gd, identical, err := DiffGraph(a, b, 0)
+	require.Nil(err)
+	require.Nil(gd)
+	require.Equal(uint64(len(list)), identical)
+	gd, identical, err = DiffGraph(a, b, 100)
+	require.Nil(err)
+	require.Nil(gd)
+	require.Equal(uint64(len(list)-100), identical)
+
+	b.snapshots = b.snapshots[:len(list)-1]
+	gd, identical, err = DiffGraph(a, b, 0)
+	require.Nil(err)
+	require.Equal(uint64(len(list)-1), identical)
+	require.Equal(uint64(len(list)-1), gd.Topology)
+	require.NotNil(gd.A)
+	require.Nil(gd.B)
+	require.False(gd.Reordered)
+
+	b.snapshots = append([]*common.SnapshotWithTopologicalOrder{}, list...)
+	b.snapshots[7] = build(7, 0, "fork")
+	gd, identical, err = DiffGraph(a, b, 0)
+	require.Nil(err)
+	require.Equal(uint64(7), identical)
+	require.Equal(uint64(7), gd.Topology)
+	require.Equal(list[7].PayloadHash(), gd.A.PayloadHash())
+	require.Equal(b.snapshots[7].PayloadHash(), gd.B.PayloadHash())
+	require.False(gd.Reordered)
+
+	b.snapshots = append([]*common.SnapshotWithTopologicalOrder{}, list...)
+	b.snapshots[8], b.snapshots[9] = build(8, 0, string(rune(9))), build(9, 0, string(rune(8)))
+	gd, identical, err = DiffGraph(a, b, 0)
+	require.Nil(err)
+	require.Equal(uint64(8), identical)
+	require.True(gd.Reordered)
+}
@@ -0,0 +1,101 @@
+package kernel
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+const RoundGraphExportLimit = 10000
+
+type RoundGraphStore interface {
+	ReadSnapshotsForNodeRound(nodeIdWithNetwork crypto.Hash, round uint64) ([]*common.SnapshotWithTopologicalOrder, error)
+	ReadRound(hash crypto.Hash) (*common.Round, error)
+}
+
+// ExportRoundGraph writes the rounds from to to of the node chain in the DOT
+// language of GraphViz. Each round links to its previous round by the self
+// reference, and to the round of another chain by the external reference.
+func ExportRoundGraph(store RoundGraphStore, nodeId crypto.Hash, from, to uint64, w io.Writer) error {
+	if to < from || to-from >= RoundGraphExportLimit {
+		return fmt.Errorf("invalid round graph range %d..%d", from, to)
+	}
+	_, err := fmt.Fprintf(w, "digraph \"%s\" {\n\trankdir=RL;\n\tnode [shape=box, fontname=monospace];\n", nodeId)
+	if err != nil {
+		return err
+	}
+
+	externals := make(map[crypto.Hash]bool)
+	for number := from; number <= to; number++ {
+		snapshots, err := store.ReadSnapshotsForNodeRound(nodeId, number)
+		if err != nil {
+			return err
+		}
+		if len(snapshots) == 0 {
+			break
+		}
+		ss := make([]*common.Snapshot, len(snapshots))
+		for i, s := range snapshots {
+			ss[i] = s.Snapshot
+		}
+		start, end, hash := common.ComputeRoundHash(nodeId, number, ss)
+		_, err = fmt.Fprintf(w, "\t\"%s\" [label=\"%s #%d\\n%s\\nsnapshots %d\\n%d..%d\"];\n",
+			hash, shortGraphId(nodeId), number, shortGraphId(hash), len(snapshots), start, end)
+		if err != nil {
+			return err
+		}
+
+		references := ss[0].References
+		if references == nil {
+			continue
+		}
+		if number > 0 {
+			_, err = fmt.Fprintf(w, "\t\"%s\" -> \"%s\" [label=\"self\"];\n", hash, references.Self)
+			if err != nil {
+				return err
+			}
+		}
+		if number > 0 && number == from {
+			err = writeOutsideGraphRound(store, references.Self, w)
+		}
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "\t\"%s\" -> \"%s\" [label=\"external\", style=dashed];\n", hash, references.External)
+		if err != nil {
+			return err
+		}
+		if externals[references.External] {
+			continue
+		}
+		externals[references.External] = true
+		err = writeOutsideGraphRound(store, references.External, w)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = fmt.Fprintln(w, "}")
+	return err
+}
+
+// the rounds outside of the exported range are drawn dotted
+func writeOutsideGraphRound(store RoundGraphStore, hash crypto.Hash, w io.Writer) error {
+	round, err := store.ReadRound(hash)
+	if err != nil {
+		return err
+	}
+	if round == nil {
+		_, err = fmt.Fprintf(w, "\t\"%s\" [label=\"unknown\\n%s\", style=dotted];\n", hash, shortGraphId(hash))
+		return err
+	}
+	_, err = fmt.Fprintf(w, "\t\"%s\" [label=\"%s #%d\\n%s\", style=dotted];\n",
+		hash, shortGraphId(round.NodeId), round.Number, shortGraphId(hash))
+	return err
+}
+
+func shortGraphId(h crypto.Hash) string {
+	return h.String()[:8]
+}
@@ -0,0 +1,72 @@
+package kernel
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+type roundGraphStoreImpl struct {
+	snapshots map[uint64][]*common.SnapshotWithTopologicalOrder
+	rounds    map[crypto.Hash]*common.Round
+}
+
+func (s *roundGraphStoreImpl) ReadSnapshotsForNodeRound(nodeIdWithNetwork crypto.Hash, round uint64) ([]*common.SnapshotWithTopologicalOrder, error) {
+	return s.snapshots[round], nil
+}
+
+func (s *roundGraphStoreImpl) ReadRound(hash crypto.Hash) (*common.Round, error) {
+	return s.rounds[hash], nil
+}
+
+func TestExportRoundGraph(t *testing.T) {
+	require := require.New(t)
+
+	a, b := crypto.Blake3Hash([]byte("a")), crypto.Blake3Hash([]byte("b"))
+	external := crypto.Blake3Hash([]byte("external"))
+	store := &roundGraphStoreImpl{
+		snapshots: make(map[uint64][]*common.SnapshotWithTopologicalOrder),
+		rounds: map[crypto.Hash]*common.Round{
+			external: {Hash: external, NodeId: b, Number: 7, Timestamp: 1000},
+		},
+	}
+	var prev crypto.Hash
+	var hashes []crypto.Hash
+	for i := uint64(0); i < 3; i++ {
+		s := &common.SnapshotWithTopologicalOrder{Snapshot: &common.Snapshot{
+			Version:     common.SnapshotVersionCommonEncoding,
+			NodeId:      a,
+			RoundNumber: i,
+			Timestamp:   1000 + i*1000,
+			References:  &common.RoundLink{Self: prev, External: external},
+		}}
+		s.AddSoleTransaction(crypto.Blake3Hash([]byte{byte(i)}))
+		s.Hash = s.PayloadHash()
+		store.snapshots[i] = []*common.SnapshotWithTopologicalOrder{s}
+		_, _, prev = common.ComputeRoundHash(a, i, []*common.Snapshot{s.Snapshot})
+		store.rounds[prev] = &common.Round{Hash: prev, NodeId: a, Number: i}
+		hashes = append(hashes, prev)
+	}
+
+	var buf bytes.Buffer
+	require.NotNil(ExportRoundGraph(store, a, 2, 1, &buf))
+	require.NotNil(ExportRoundGraph(store, a, 0, RoundGraphExportLimit, &buf))
+
+	buf.Reset()
+	err := ExportRoundGraph(store, a, 1, 5, &buf)
+	require.Nil(err)
+	dot := buf.String()
+	require.True(strings.HasPrefix(dot, "digraph"))
+	require.True(strings.HasSuffix(dot, "}\n"))
+	require.Contains(dot, hashes[1].String()+"\" [label=\""+a.String()[:8]+" #1")
+	require.Contains(dot, hashes[2].String()+"\" -> \""+hashes[1].String()+"\" [label=\"self\"]")
+	require.Contains(dot, hashes[1].String()+"\" -> \""+hashes[0].String()+"\" [label=\"self\"]")
+	require.Contains(dot, hashes[0].String()+"\" [label=\""+a.String()[:8]+" #0\\n"+hashes[0].String()[:8]+"\", style=dotted]")
+	require.Contains(dot, external.String()+"\" [label=\""+b.String()[:8]+" #7")
+	require.Equal(1, strings.Count(dot, external.String()+"\" [label=\""+b.String()[:8]))
+	require.Equal(2, strings.Count(dot, "[label=\"external\""))
+}
@@ -1,8 +1,39 @@
 package kernel
 
+import (
+	"math"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+)
+
 const (
 	mainnetConsensusOperationElectionForkAt = uint64(1701388800000000000)
 	mainnetConsensusNodeRemovalTimeForkAt   = uint64(1706400000000000000)
+	mainnetConsensusEscrowScriptForkAt      = mainnetConsensusForkUnscheduled
+	mainnetConsensusLockTimeScriptForkAt    = mainnetConsensusForkUnscheduled
+	mainnetConsensusNodePayeeChangeForkAt   = mainnetConsensusForkUnscheduled
 	mainnetMintDayGapSkipForkBatch          = uint64(1800)
 	mainnetNodeRemovalHackSnapshotHash      = "b5a9ab66e3b5d24328f8f87bc38e90f0c426dc38413200bb8ecf7f5b8607a5f9"
+
+	// the mainnet forks are never active until the activation timestamps
+	// are coordinated with all the nodes
+	mainnetConsensusForkUnscheduled = uint64(math.MaxUint64)
 )
+
+// consensusForks are active since the genesis of the networks other than
+// the mainnet, which activates them at the snapshot timestamps once scheduled.
+func (node *Node) consensusForks(timestamp uint64) common.ConsensusForks {
+	if node.networkId.String() != config.KernelNetworkId {
+		return common.ConsensusForks{EscrowScript: true, LockTimeScript: true, NodePayeeChange: true}
+	}
+	return common.ConsensusForks{
+		EscrowScript:    mainnetForkActive(mainnetConsensusEscrowScriptForkAt, timestamp),
+		LockTimeScript:  mainnetForkActive(mainnetConsensusLockTimeScriptForkAt, timestamp),
+		NodePayeeChange: mainnetForkActive(mainnetConsensusNodePayeeChangeForkAt, timestamp),
+	}
+}
+
+func mainnetForkActive(at, timestamp uint64) bool {
+	return at != mainnetConsensusForkUnscheduled && timestamp >= at
+}
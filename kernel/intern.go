@@ -0,0 +1,121 @@
+package kernel
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/kernel/internal/clock"
+)
+
+const (
+	TransactionInternLimit    = 16384
+	TransactionInternMaxBytes = 64 * 1024 * 1024
+	TransactionInternTTL      = time.Minute
+)
+
+type internedTransaction struct {
+	hash crypto.Hash
+	tx   *common.VersionedTransaction
+	size int
+	seen time.Time
+}
+
+// transactionInterner shares the same decoded transaction among all the
+// chains and rounds referencing it, instead of a decoded copy for each
+// snapshot. The interned transactions must be treated as read only.
+//
+// The entries are kept in LRU order and bounded by both the count and the
+// total payload bytes, the expired ones are dropped on every access, and
+// the transactions are released once the rounds referencing them finalize.
+type transactionInterner struct {
+	sync.Mutex
+	entries map[crypto.Hash]*list.Element
+	lru     *list.List
+	bytes   int
+	hits    uint64
+}
+
+func newTransactionInterner() *transactionInterner {
+	return &transactionInterner{
+		entries: make(map[crypto.Hash]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+func (ti *transactionInterner) intern(tx *common.VersionedTransaction) *common.VersionedTransaction {
+	if tx == nil {
+		return nil
+	}
+	// the payload bytes and hash are cached lazily, so compute them before
+	// the transaction is shared with other goroutines
+	hash := tx.PayloadHash()
+	size := len(tx.PayloadMarshal())
+	now := clock.Now()
+
+	ti.Lock()
+	defer ti.Unlock()
+
+	ti.expire(now)
+	if e := ti.entries[hash]; e != nil {
+		it := e.Value.(*internedTransaction)
+		it.seen = now
+		ti.lru.MoveToFront(e)
+		ti.hits += 1
+		return it.tx
+	}
+	if size > TransactionInternMaxBytes {
+		return tx
+	}
+	for ti.lru.Len() >= TransactionInternLimit || ti.bytes+size > TransactionInternMaxBytes {
+		ti.remove(ti.lru.Back())
+	}
+	it := &internedTransaction{hash: hash, tx: tx, size: size, seen: now}
+	ti.entries[hash] = ti.lru.PushFront(it)
+	ti.bytes += size
+	return tx
+}
+
+func (ti *transactionInterner) release(hashes []crypto.Hash) {
+	ti.Lock()
+	defer ti.Unlock()
+
+	for _, h := range hashes {
+		if e := ti.entries[h]; e != nil {
+			ti.remove(e)
+		}
+	}
+	ti.expire(clock.Now())
+}
+
+func (ti *transactionInterner) expire(now time.Time) {
+	for e := ti.lru.Back(); e != nil; e = ti.lru.Back() {
+		if now.Sub(e.Value.(*internedTransaction).seen) <= TransactionInternTTL {
+			return
+		}
+		ti.remove(e)
+	}
+}
+
+func (ti *transactionInterner) remove(e *list.Element) {
+	it := ti.lru.Remove(e).(*internedTransaction)
+	delete(ti.entries, it.hash)
+	ti.bytes -= it.size
+}
+
+func cacheTransactions(cache *CacheRound) []crypto.Hash {
+	hashes := make([]crypto.Hash, len(cache.Snapshots))
+	for i, s := range cache.Snapshots {
+		hashes[i] = s.SoleTransaction()
+	}
+	return hashes
+}
+
+func (node *Node) InternState() (int, int, uint64) {
+	ti := node.interner
+	ti.Lock()
+	defer ti.Unlock()
+	return ti.lru.Len(), ti.bytes, ti.hits
+}
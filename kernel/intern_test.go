@@ -0,0 +1,93 @@
+package kernel
+
+import (
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/kernel/internal/clock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransactionInterner(t *testing.T) {
+	require := require.New(t)
+	defer clock.Reset()
+
+	node := &Node{interner: newTransactionInterner()}
+	ti := node.interner
+	require.Nil(ti.intern(nil))
+
+	tx := common.NewTransactionV5(common.XINAssetId)
+	tx.AddOutputWithType(common.OutputTypeScript, nil, common.NewThresholdScript(1), common.NewInteger(1), make([]byte, 64))
+	val := tx.AsVersioned().Marshal()
+	first, err := common.UnmarshalVersionedTransaction(val)
+	require.Nil(err)
+	second, err := common.UnmarshalVersionedTransaction(val)
+	require.Nil(err)
+	require.False(first == second)
+
+	require.True(first == ti.intern(first))
+	require.True(first == ti.intern(second))
+	entries, size, hits := node.InternState()
+	require.Equal(1, entries)
+	require.Equal(len(first.PayloadMarshal()), size)
+	require.Equal(uint64(1), hits)
+
+	for i := 1; i < TransactionInternLimit; i++ {
+		tx.Extra = []byte{byte(i), byte(i >> 8)}
+		ti.intern(tx.AsVersioned())
+	}
+	entries, _, _ = node.InternState()
+	require.Equal(TransactionInternLimit, entries)
+	tx.Extra = []byte("full")
+	extra := tx.AsVersioned()
+	require.True(extra == ti.intern(extra))
+	entries, _, _ = node.InternState()
+	require.Equal(TransactionInternLimit, entries)
+	third, err := common.UnmarshalVersionedTransaction(val)
+	require.Nil(err)
+	require.True(third == ti.intern(third))
+	require.True(extra == ti.intern(extra.AsVersioned()))
+
+	clock.MockDiff(TransactionInternTTL * 2)
+	require.True(extra == ti.intern(extra))
+	entries, size, _ = node.InternState()
+	require.Equal(1, entries)
+	require.Equal(len(extra.PayloadMarshal()), size)
+
+	ti.release([]crypto.Hash{extra.PayloadHash()})
+	entries, size, _ = node.InternState()
+	require.Equal(0, entries)
+	require.Equal(0, size)
+}
+
+func TestTransactionInternerBytesBound(t *testing.T) {
+	require := require.New(t)
+	defer clock.Reset()
+
+	node := &Node{interner: newTransactionInterner()}
+	ti := node.interner
+
+	var large []*common.VersionedTransaction
+	for i := 0; i < 48; i++ {
+		tx := common.NewTransactionV5(common.XINAssetId)
+		tx.AddOutputWithType(common.OutputTypeScript, nil, common.NewThresholdScript(1), common.NewInteger(1), make([]byte, 64))
+		tx.Extra = make([]byte, common.ExtraSizeStorageCapacity/2)
+		tx.Extra[0], tx.Extra[1] = byte(i), byte(i>>8)
+		ver := tx.AsVersioned()
+		require.True(ver == ti.intern(ver))
+		large = append(large, ver)
+
+		entries, size, _ := node.InternState()
+		require.LessOrEqual(size, TransactionInternMaxBytes)
+		require.Equal(entries*len(ver.PayloadMarshal()), size)
+	}
+
+	entries, size, _ := node.InternState()
+	require.Less(entries, len(large))
+	require.Greater(size, TransactionInternMaxBytes-len(large[0].PayloadMarshal()))
+	last := large[len(large)-1]
+	require.True(last == ti.intern(last.AsVersioned()))
+	oldest := large[0].AsVersioned()
+	require.True(oldest == ti.intern(oldest))
+}
@@ -0,0 +1,24 @@
+//go:build simulation
+
+package internal
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+func SeedRand(seed int64) {
+	if !inTest {
+		panic(fmt.Errorf("seeded random not allowed in build version %s", config.BuildVersion))
+	}
+	var s [32]byte
+	binary.BigEndian.PutUint64(s[:], uint64(seed))
+	crypto.SeedRand(&s)
+}
+
+func ResetRand() {
+	crypto.SeedRand(nil)
+}
@@ -0,0 +1,8 @@
+//go:build !simulation
+
+package internal
+
+// the system random is never seeded without the simulation tag
+func SeedRand(seed int64) {}
+
+func ResetRand() {}
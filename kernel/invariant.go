@@ -0,0 +1,269 @@
+package kernel
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/mixin/storage"
+)
+
+// the invariant checker of the canary nodes follows the finalized snapshots
+// in the topological order, and asserts the properties the kernel relies on,
+// which must never be violated without a bug or a reorg of the finalized
+// graph. the spent outputs of the recent window are checked again at each
+// round, so a lock changed later is found too. the node halts once any is
+// violated, so the graph is kept as is for forensics.
+const (
+	InvariantCheckInterval = 10 * time.Second
+
+	invariantBatchSize    = 500
+	invariantBatchesLimit = 20
+	invariantSpendsWindow = 4096
+)
+
+const (
+	InvariantTopologySequence = "topology-sequence"
+	InvariantTopologyOrder    = "topology-order"
+	InvariantReorg            = "reorg"
+	InvariantRoundOrder       = "round-order"
+	InvariantRoundConflict    = "round-conflict"
+	InvariantSpendLock        = "spend-lock"
+	InvariantMintBatch        = "mint-batch"
+	InvariantMintDistribution = "mint-distribution"
+)
+
+type InvariantViolation struct {
+	Kind        string      `json:"kind"`
+	Detail      string      `json:"detail"`
+	Topology    uint64      `json:"topology"`
+	Snapshot    crypto.Hash `json:"snapshot"`
+	Node        crypto.Hash `json:"node"`
+	Round       uint64      `json:"round"`
+	Transaction crypto.Hash `json:"transaction"`
+	Checked     uint64      `json:"checked"`
+}
+
+type invariantRound struct {
+	number     uint64
+	references *common.RoundLink
+	snapshot   crypto.Hash
+}
+
+type invariantSpend struct {
+	hash  crypto.Hash
+	index uint
+	lock  crypto.Hash
+}
+
+type invariantChecker struct {
+	store    storage.Store
+	topology uint64
+	last     crypto.Hash
+	sequence uint64
+	rounds   map[crypto.Hash]*invariantRound
+	spends   []*invariantSpend
+	next     int
+	minted   bool
+	mint     uint64
+	mintTx   crypto.Hash
+	dist     *common.MintDistribution
+}
+
+func newInvariantChecker(store storage.Store) *invariantChecker {
+	return &invariantChecker{
+		store:  store,
+		rounds: make(map[crypto.Hash]*invariantRound),
+		spends: make([]*invariantSpend, invariantSpendsWindow),
+	}
+}
+
+func (node *Node) InvariantLoop() {
+	defer close(node.ivc)
+
+	if !node.custom.Node.InvariantCheck {
+		return
+	}
+	checker := newInvariantChecker(node.persistStore)
+	for {
+		v, more, err := checker.check()
+		if err != nil {
+			logger.Printf("InvariantLoop() => %v\n", err)
+		}
+		if v != nil {
+			node.haltInvariant(v)
+		}
+		wait := InvariantCheckInterval
+		if more {
+			wait = 0
+		}
+		select {
+		case <-node.done:
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// haltInvariant panics with the report, which is kept in the crash output of
+// the support bundle, the node must not be restarted before the forensics.
+func (node *Node) haltInvariant(v *InvariantViolation) {
+	report, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	logger.Printw("Invariant violated", "alert", "invariant", "kind", v.Kind,
+		"topology", v.Topology, "snapshot", v.Snapshot, "detail", v.Detail)
+	panic(fmt.Errorf("invariant %s violated\n%s", v.Kind, report))
+}
+
+// check continues from the last checked snapshot, and returns more if there
+// may be more snapshots to check right now.
+func (c *invariantChecker) check() (*InvariantViolation, bool, error) {
+	seq := c.store.TopologySequence()
+	if seq < c.sequence {
+		return c.violation(InvariantTopologySequence, nil, nil,
+			"topology sequence %d went back from %d", seq, c.sequence), false, nil
+	}
+	c.sequence = seq
+
+	if c.topology > 0 {
+		snapshots, err := c.store.ReadSnapshotsSinceTopology(c.topology-1, 1)
+		if err != nil {
+			return nil, false, err
+		}
+		if len(snapshots) == 0 || snapshots[0].Hash != c.last {
+			return c.violation(InvariantReorg, nil, nil,
+				"snapshot %s at topology %d replaced", c.last, c.topology-1), false, nil
+		}
+	}
+
+	for _, sp := range c.spends {
+		if sp == nil {
+			continue
+		}
+		utxo, err := c.store.ReadUTXOLock(sp.hash, sp.index)
+		if err != nil {
+			return nil, false, err
+		}
+		if utxo == nil || utxo.LockHash != sp.lock {
+			return c.violation(InvariantSpendLock, nil, nil,
+				"output %s:%d spent by %s unlocked later", sp.hash, sp.index, sp.lock), false, nil
+		}
+	}
+
+	for range invariantBatchesLimit {
+		snapshots, transactions, err := c.store.ReadSnapshotWithTransactionsSinceTopology(c.topology, invariantBatchSize)
+		if err != nil {
+			return nil, false, err
+		}
+		for i, s := range snapshots {
+			v, err := c.checkSnapshot(s, transactions[i])
+			if err != nil || v != nil {
+				return v, false, err
+			}
+		}
+		if len(snapshots) < invariantBatchSize {
+			v, err := c.checkMintDistribution()
+			return v, false, err
+		}
+	}
+	return nil, true, nil
+}
+
+func (c *invariantChecker) checkSnapshot(s *common.SnapshotWithTopologicalOrder, tx *common.VersionedTransaction) (*InvariantViolation, error) {
+	if s.TopologicalOrder < c.topology {
+		return c.violation(InvariantTopologyOrder, s, nil,
+			"snapshot topology %d before %d", s.TopologicalOrder, c.topology), nil
+	}
+
+	r := c.rounds[s.NodeId]
+	switch {
+	case r == nil || s.RoundNumber > r.number:
+		c.rounds[s.NodeId] = &invariantRound{
+			number:     s.RoundNumber,
+			references: s.References,
+			snapshot:   s.Hash,
+		}
+	case s.RoundNumber < r.number:
+		return c.violation(InvariantRoundOrder, s, nil,
+			"round %d finalized after round %d", s.RoundNumber, r.number), nil
+	case !equalRoundLinks(s.References, r.references):
+		return c.violation(InvariantRoundConflict, s, nil,
+			"references %v conflict with %v of snapshot %s", s.References, r.references, r.snapshot), nil
+	}
+
+	hash := tx.PayloadHash()
+	for _, in := range tx.Inputs {
+		if in.Hash.HasValue() {
+			utxo, err := c.store.ReadUTXOLock(in.Hash, in.Index)
+			if err != nil {
+				return nil, err
+			}
+			if utxo == nil || utxo.LockHash != hash {
+				return c.violation(InvariantSpendLock, s, tx,
+					"output %s:%d not locked by the spending transaction", in.Hash, in.Index), nil
+			}
+			c.spends[c.next] = &invariantSpend{hash: in.Hash, index: in.Index, lock: hash}
+			c.next = (c.next + 1) % len(c.spends)
+		}
+		if m := in.Mint; m != nil {
+			if c.minted && m.Batch <= c.mint && hash != c.mintTx {
+				return c.violation(InvariantMintBatch, s, tx,
+					"mint batch %d after batch %d of %s", m.Batch, c.mint, c.mintTx), nil
+			}
+			c.minted, c.mint, c.mintTx = true, m.Batch, hash
+		}
+	}
+
+	c.topology = s.TopologicalOrder + 1
+	c.last = s.Hash
+	return nil, nil
+}
+
+func (c *invariantChecker) checkMintDistribution() (*InvariantViolation, error) {
+	dist, err := c.store.ReadLastMintDistribution(^uint64(0))
+	if err != nil || dist == nil {
+		return nil, err
+	}
+	old := c.dist
+	c.dist = dist
+	if old == nil || dist.Batch > old.Batch {
+		return nil, nil
+	}
+	if dist.Batch < old.Batch || dist.Transaction != old.Transaction {
+		return c.violation(InvariantMintDistribution, nil, nil,
+			"mint distribution %d of %s replaced by %d of %s", old.Batch, old.Transaction, dist.Batch, dist.Transaction), nil
+	}
+	return nil, nil
+}
+
+func (c *invariantChecker) violation(kind string, s *common.SnapshotWithTopologicalOrder, tx *common.VersionedTransaction, format string, args ...any) *InvariantViolation {
+	v := &InvariantViolation{
+		Kind:     kind,
+		Detail:   fmt.Sprintf(format, args...),
+		Topology: c.topology,
+		Snapshot: c.last,
+		Checked:  c.topology,
+	}
+	if s != nil {
+		v.Topology = s.TopologicalOrder
+		v.Snapshot = s.Hash
+		v.Node = s.NodeId
+		v.Round = s.RoundNumber
+	}
+	if tx != nil {
+		v.Transaction = tx.PayloadHash()
+	}
+	return v
+}
+
+func equalRoundLinks(a, b *common.RoundLink) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(b)
+}
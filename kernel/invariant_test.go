@@ -0,0 +1,162 @@
+package kernel
+
+import (
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/storage"
+	"github.com/stretchr/testify/require"
+)
+
+type invariantTestStore struct {
+	storage.Store
+	sequence  uint64
+	snapshots []*common.SnapshotWithTopologicalOrder
+	txs       []*common.VersionedTransaction
+	locks     map[crypto.Hash]crypto.Hash
+	dist      *common.MintDistribution
+}
+
+func (s *invariantTestStore) TopologySequence() uint64 {
+	return s.sequence
+}
+
+func (s *invariantTestStore) ReadSnapshotsSinceTopology(offset, count uint64) ([]*common.SnapshotWithTopologicalOrder, error) {
+	snapshots, _, err := s.ReadSnapshotWithTransactionsSinceTopology(offset, count)
+	return snapshots, err
+}
+
+func (s *invariantTestStore) ReadSnapshotWithTransactionsSinceTopology(offset, count uint64) ([]*common.SnapshotWithTopologicalOrder, []*common.VersionedTransaction, error) {
+	var snapshots []*common.SnapshotWithTopologicalOrder
+	var txs []*common.VersionedTransaction
+	for i, snap := range s.snapshots {
+		if snap.TopologicalOrder >= offset && uint64(len(snapshots)) < count {
+			snapshots = append(snapshots, snap)
+			txs = append(txs, s.txs[i])
+		}
+	}
+	return snapshots, txs, nil
+}
+
+func (s *invariantTestStore) ReadUTXOLock(hash crypto.Hash, index uint) (*common.UTXOWithLock, error) {
+	lock, found := s.locks[hash]
+	if !found {
+		return nil, nil
+	}
+	return &common.UTXOWithLock{LockHash: lock}, nil
+}
+
+func (s *invariantTestStore) ReadLastMintDistribution(batch uint64) (*common.MintDistribution, error) {
+	return s.dist, nil
+}
+
+func TestInvariantChecker(t *testing.T) {
+	require := require.New(t)
+
+	store := &invariantTestStore{locks: make(map[crypto.Hash]crypto.Hash)}
+	node := crypto.Blake3Hash([]byte("node"))
+	write := func(round uint64, external string, build func(tx *common.Transaction)) *common.SnapshotWithTopologicalOrder {
+		tx := common.NewTransactionV5(common.XINAssetId)
+		build(tx)
+		ver := tx.AsVersioned()
+		snap := &common.SnapshotWithTopologicalOrder{
+			Snapshot: &common.Snapshot{
+				NodeId:       node,
+				RoundNumber:  round,
+				References:   &common.RoundLink{External: crypto.Blake3Hash([]byte(external))},
+				Transactions: []crypto.Hash{ver.PayloadHash()},
+			},
+			TopologicalOrder: store.sequence,
+		}
+		snap.Hash = crypto.Blake3Hash([]byte{byte(store.sequence)})
+		store.sequence += 1
+		store.snapshots = append(store.snapshots, snap)
+		store.txs = append(store.txs, ver)
+		return snap
+	}
+	spend := func(input crypto.Hash) func(tx *common.Transaction) {
+		return func(tx *common.Transaction) {
+			tx.AddInput(input, 0)
+			store.locks[input] = tx.AsVersioned().PayloadHash()
+		}
+	}
+	mint := func(batch uint64) func(tx *common.Transaction) {
+		return func(tx *common.Transaction) {
+			tx.AddUniversalMintInput(batch, common.NewInteger(100))
+		}
+	}
+
+	checker := newInvariantChecker(store)
+	v, more, err := checker.check()
+	require.Nil(err)
+	require.Nil(v)
+	require.False(more)
+
+	genesis := crypto.Blake3Hash([]byte("genesis"))
+	write(0, "a", spend(genesis))
+	write(0, "a", mint(10))
+	write(1, "b", mint(11))
+	v, _, err = checker.check()
+	require.Nil(err)
+	require.Nil(v)
+	require.Equal(uint64(3), checker.topology)
+
+	store.dist = &common.MintDistribution{MintData: common.MintData{Batch: 11}}
+	v, _, err = checker.check()
+	require.Nil(err)
+	require.Nil(v)
+	store.dist = &common.MintDistribution{MintData: common.MintData{Batch: 10}}
+	v, _, err = checker.check()
+	require.Nil(err)
+	require.Equal(InvariantMintDistribution, v.Kind)
+	store.dist = &common.MintDistribution{MintData: common.MintData{Batch: 11}}
+
+	store.locks[genesis] = crypto.Blake3Hash([]byte("double"))
+	v, _, err = checker.check()
+	require.Nil(err)
+	require.Equal(InvariantSpendLock, v.Kind)
+	require.Contains(v.Detail, "unlocked later")
+	store.locks[genesis] = store.txs[0].PayloadHash()
+
+	store.sequence = 2
+	v, _, err = checker.check()
+	require.Nil(err)
+	require.Equal(InvariantTopologySequence, v.Kind)
+	store.sequence = 3
+
+	last := store.snapshots[2].Hash
+	store.snapshots[2].Hash = crypto.Blake3Hash([]byte("reorg"))
+	v, _, err = checker.check()
+	require.Nil(err)
+	require.Equal(InvariantReorg, v.Kind)
+	require.Equal(last, v.Snapshot)
+	store.snapshots[2].Hash = last
+
+	snap := write(1, "c", mint(12))
+	v, _, err = checker.check()
+	require.Nil(err)
+	require.Equal(InvariantRoundConflict, v.Kind)
+	require.Equal(snap.Hash, v.Snapshot)
+	require.Equal(uint64(1), v.Round)
+
+	for _, kind := range []string{InvariantRoundOrder, InvariantMintBatch, InvariantSpendLock} {
+		checker = newInvariantChecker(store)
+		store.snapshots, store.txs, store.sequence = store.snapshots[:3], store.txs[:3], 3
+		switch kind {
+		case InvariantRoundOrder:
+			write(0, "a", mint(12))
+		case InvariantMintBatch:
+			write(1, "b", mint(9))
+		case InvariantSpendLock:
+			write(1, "b", func(tx *common.Transaction) {
+				tx.AddInput(genesis, 0)
+				tx.Extra = []byte("double")
+			})
+		}
+		v, _, err = checker.check()
+		require.Nil(err)
+		require.Equal(kind, v.Kind)
+		require.Equal(uint64(3), v.Topology)
+	}
+}
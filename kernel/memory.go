@@ -0,0 +1,123 @@
+package kernel
+
+import (
+	"runtime/debug"
+	rtmetrics "runtime/metrics"
+	"time"
+
+	"github.com/MixinNetwork/mixin/logger"
+)
+
+// the watchdog compares the heap in use with the memory limit of the config,
+// and reacts before the node is killed by the OOM of the host. above the high
+// mark the cache is shrunk by half at each check, and the optional indexing
+// loops pause, above the critical mark the cache is cleared, the reorder
+// buffers of the chains are shed, and the freed memory is returned to the OS.
+// the cache grows back by double at each check below the low mark, and the
+// pressure level is kept between the marks, so it doesn't flap.
+const (
+	MemoryCheckInterval = 5 * time.Second
+
+	memoryLowRatio       = 0.7
+	memoryHighRatio      = 0.8
+	memoryCriticalRatio  = 0.95
+	memoryCacheMinFactor = 16
+)
+
+const (
+	MemoryPressureNone = iota
+	MemoryPressureHigh
+	MemoryPressureCritical
+)
+
+var memoryPressureNames = []string{"none", "high", "critical"}
+
+func (node *Node) MemoryPressure() int {
+	return int(node.memoryPressure.Load())
+}
+
+// IndexingPaused is true under any memory pressure, then the loops not needed
+// by the consensus, e.g. the event sink and the webhooks, should wait.
+func (node *Node) IndexingPaused() bool {
+	return node.MemoryPressure() > MemoryPressureNone
+}
+
+func (node *Node) MemoryLoop() {
+	defer close(node.mwc)
+
+	limit := uint64(node.custom.Node.MemoryLimit) * 1024 * 1024
+	if limit == 0 {
+		return
+	}
+	// the go runtime collects more often close to the limit, which is also
+	// process wide, so it's set only when the watchdog runs
+	debug.SetMemoryLimit(int64(limit))
+
+	ticker := time.NewTicker(MemoryCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-node.done:
+			return
+		case <-ticker.C:
+			node.checkMemory(limit, readHeapInUse())
+		}
+	}
+}
+
+func (node *Node) checkMemory(limit, heap uint64) int {
+	ratio := float64(heap) / float64(limit)
+	full := int64(node.custom.Node.MemoryCacheSize) * 1024 * 1024
+	cost := node.cacheStore.MaxCost()
+	old := node.MemoryPressure()
+
+	pressure, shed := old, 0
+	switch {
+	case ratio >= memoryCriticalRatio:
+		pressure = MemoryPressureCritical
+		cost = full / memoryCacheMinFactor
+		node.cacheStore.Clear()
+		node.chains.RLock()
+		for _, chain := range node.chains.m {
+			shed += chain.reorder.shed()
+		}
+		node.chains.RUnlock()
+		debug.FreeOSMemory()
+	case ratio >= memoryHighRatio:
+		pressure = max(old, MemoryPressureHigh)
+		cost = max(cost/2, full/memoryCacheMinFactor)
+	case ratio < memoryLowRatio:
+		pressure = MemoryPressureNone
+		cost = min(cost*2, full)
+	case old == MemoryPressureCritical:
+		pressure = MemoryPressureHigh
+	}
+	node.cacheStore.UpdateMaxCost(cost)
+	node.memoryPressure.Store(int32(pressure))
+	observeMemory(heap, cost, pressure)
+
+	if pressure != old {
+		logger.Printw("Memory pressure", "alert", "memory", "level", memoryPressureNames[pressure],
+			"heap", heap, "limit", limit, "cache", cost, "shed", shed)
+	}
+	return pressure
+}
+
+// the heap in use includes the free spans not returned to the OS yet, which
+// are still counted by the OOM killer.
+func readHeapInUse() uint64 {
+	samples := []rtmetrics.Sample{
+		{Name: "/memory/classes/heap/objects:bytes"},
+		{Name: "/memory/classes/heap/unused:bytes"},
+		{Name: "/memory/classes/heap/free:bytes"},
+	}
+	rtmetrics.Read(samples)
+	var heap uint64
+	for _, s := range samples {
+		if s.Value.Kind() == rtmetrics.KindUint64 {
+			heap += s.Value.Uint64()
+		}
+	}
+	return heap
+}
@@ -0,0 +1,61 @@
+package kernel
+
+import (
+	"os"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryWatchdog(t *testing.T) {
+	require := require.New(t)
+
+	root, err := os.MkdirTemp("", "mixin-memory-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	node := setupTestNode(require, root)
+	full := int64(node.custom.Node.MemoryCacheSize) * 1024 * 1024
+	node.cacheStore.UpdateMaxCost(full)
+	require.Equal(MemoryPressureNone, node.MemoryPressure())
+	require.False(node.IndexingPaused())
+
+	chain := node.getOrCreateChain(node.genesisNodes[0])
+	require.Equal(MemoryPressureNone, node.checkMemory(100, 50))
+	require.Equal(full, node.cacheStore.MaxCost())
+
+	require.Equal(MemoryPressureHigh, node.checkMemory(100, 85))
+	require.Equal(full/2, node.cacheStore.MaxCost())
+	require.True(node.IndexingPaused())
+	require.Equal(MemoryPressureHigh, node.checkMemory(100, 90))
+	require.Equal(full/4, node.cacheStore.MaxCost())
+	require.Equal(MemoryPressureHigh, node.checkMemory(100, 75))
+	require.Equal(full/4, node.cacheStore.MaxCost())
+
+	for i := range 3 {
+		s := &common.Snapshot{RoundNumber: 1<<40 + uint64(i)}
+		s.Hash = crypto.Blake3Hash([]byte{byte(i)})
+		require.True(chain.reorder.hold(&CosiAction{Snapshot: s}))
+	}
+	node.cacheStore.Set([]byte("key"), "value", 1)
+	node.cacheStore.Wait()
+	require.Equal(MemoryPressureCritical, node.checkMemory(100, 96))
+	require.Equal(full/memoryCacheMinFactor, node.cacheStore.MaxCost())
+	require.Equal(1, chain.reorder.Len())
+	_, found := node.cacheStore.Get([]byte("key"))
+	require.False(found)
+	require.Equal(MemoryPressureCritical, node.checkMemory(100, 85))
+	require.Equal(full/memoryCacheMinFactor, node.cacheStore.MaxCost())
+	require.Equal(MemoryPressureHigh, node.checkMemory(100, 75))
+
+	require.Equal(MemoryPressureNone, node.checkMemory(100, 60))
+	require.False(node.IndexingPaused())
+	require.Equal(full/memoryCacheMinFactor*2, node.cacheStore.MaxCost())
+	for range 8 {
+		node.checkMemory(100, 60)
+	}
+	require.Equal(full, node.cacheStore.MaxCost())
+	require.Greater(readHeapInUse(), uint64(0))
+}
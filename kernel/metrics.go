@@ -0,0 +1,94 @@
+package kernel
+
+import (
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/metrics"
+)
+
+var (
+	roundDurationHistogram = metrics.NewHistogram("mixin_kernel_round_duration_seconds",
+		"The duration between the first and the last snapshot of each final round.",
+		"chain", []float64{0.1, 0.25, 0.5, 1, 2, 3, 5, 10, 30, 60})
+
+	cosiPhaseHistogram = metrics.NewHistogram("mixin_kernel_cosi_phase_duration_seconds",
+		"The duration of each cosi phase of the snapshots announced by this node.",
+		"phase", []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10})
+
+	syncPointLagHistogram = metrics.NewHistogram("mixin_kernel_sync_point_lag_rounds",
+		"The local final round number minus the number reported by each peer.",
+		"peer", []float64{0, 1, 2, 3, 5, 10, 20, 50, 100, 1000})
+
+	syncProgressGauge = metrics.NewGauge("mixin_kernel_sync_progress_percent",
+		"The local final rounds of all chains in percent of the highest ones reported by the peers.", "")
+
+	syncRemainingGauge = metrics.NewGauge("mixin_kernel_sync_remaining_rounds",
+		"The final rounds of all chains reported by the peers but not finalized locally.", "")
+
+	syncETAGauge = metrics.NewGauge("mixin_kernel_sync_eta_seconds",
+		"The estimated time to finish the sync by the recent rate, or 0 if unknown.", "")
+
+	syncChainLagGauge = metrics.NewGauge("mixin_kernel_sync_chain_lag_rounds",
+		"The highest final round number reported by the peers minus the local one of each chain.", "chain")
+
+	memoryHeapGauge = metrics.NewGauge("mixin_kernel_memory_heap_bytes",
+		"The heap in use checked by the memory watchdog.", "")
+
+	memoryCacheGauge = metrics.NewGauge("mixin_kernel_memory_cache_bytes",
+		"The maximum cost of the memory cache, shrunk under the memory pressure.", "")
+
+	memoryPressureGauge = metrics.NewGauge("mixin_kernel_memory_pressure",
+		"The memory pressure level, 0 for none, 1 for high and 2 for critical.", "")
+
+	cacheTransactionsGauge = metrics.NewGauge("mixin_kernel_cache_transactions",
+		"The cache transactions not finalized and not expired.", "")
+
+	cacheTransactionBytesGauge = metrics.NewGauge("mixin_kernel_cache_transaction_bytes",
+		"The payload size of the cache transactions not finalized and not expired.", "")
+
+	cacheTransactionsRemovedCounter = metrics.NewCounter("mixin_kernel_cache_transactions_removed_total",
+		"The cache transactions removed because finalized, or purged by the admin.", "reason")
+)
+
+const (
+	cosiPhaseCommitment   = "commitment"
+	cosiPhaseResponse     = "response"
+	cosiPhaseFinalization = "finalization"
+)
+
+func observeRoundDuration(final *FinalRound) {
+	if final.End < final.Start {
+		return
+	}
+	d := time.Duration(final.End - final.Start)
+	roundDurationHistogram.Observe(final.NodeId.String(), d.Seconds())
+}
+
+func observeCosiPhase(phase string, since time.Time, now time.Time) {
+	if since.IsZero() {
+		return
+	}
+	cosiPhaseHistogram.Observe(phase, now.Sub(since).Seconds())
+}
+
+// the lag could be negative when the peer is ahead of the local final round,
+// e.g. the local node is just catching up from a restart.
+func observeSyncPointLag(peerId crypto.Hash, final, remote uint64) {
+	syncPointLagHistogram.Observe(peerId.String(), float64(final)-float64(remote))
+}
+
+func observeSyncStatus(status *SyncStatus) {
+	syncProgressGauge.Set("", status.Progress)
+	syncRemainingGauge.Set("", float64(status.Remote-status.Local))
+	syncETAGauge.Set("", time.Duration(status.ETA).Seconds())
+	for _, c := range status.Chains {
+		syncChainLagGauge.Set(c.ChainId.String(), float64(c.Remote)-float64(c.Local))
+	}
+}
+
+func observeMemory(heap uint64, cache int64, pressure int) {
+	memoryHeapGauge.Set("", float64(heap))
+	memoryCacheGauge.Set("", float64(cache))
+	memoryPressureGauge.Set("", float64(pressure))
+}
@@ -142,7 +142,10 @@ func (node *Node) MintLoop() {
 }
 
 func (node *Node) tryToMintUniversal(custodianRequest *common.CustodianUpdateRequest) error {
-	eid := node.electSnapshotNode(common.TransactionTypeMint, node.GraphTimestamp)
+	eid, err := node.electSnapshotNode(common.TransactionTypeMint, node.GraphTimestamp)
+	if err != nil {
+		return err
+	}
 	if eid != node.IdForNetwork {
 		return fmt.Errorf("universal mint operation at %d only by %s not me", node.GraphTimestamp, eid)
 	}
@@ -151,11 +154,11 @@ func (node *Node) tryToMintUniversal(custodianRequest *common.CustodianUpdateReq
 		return nil
 	}
 
-	err := signed.SignInput(node.persistStore, 0, []*common.Address{&node.Signer})
+	err = signed.SignInput(node.persistStore, 0, []*common.Address{&node.Signer})
 	if err != nil {
 		return err
 	}
-	err = signed.Validate(node.persistStore, node.GraphTimestamp, false)
+	err = signed.Validate(node.persistStore, node.GraphTimestamp, false, node.consensusForks(node.GraphTimestamp))
 	if err != nil {
 		return err
 	}
@@ -296,9 +299,12 @@ func (node *Node) validateMintSnapshot(snap *common.Snapshot, tx *common.Version
 	if snap.Timestamp == 0 && snap.NodeId == node.IdForNetwork {
 		timestamp = uint64(clock.Now().UnixNano())
 	}
-	eid := node.electSnapshotNode(common.TransactionTypeMint, timestamp)
+	eid, err := node.electSnapshotNode(common.TransactionTypeMint, timestamp)
+	if err != nil {
+		return common.Errorf(common.ErrorInvalidMint, "%w", err)
+	}
 	if eid != snap.NodeId {
-		return fmt.Errorf("univernal mint operation at %d only by %s not %s", timestamp, eid, snap.NodeId)
+		return common.Errorf(common.ErrorInvalidMint, "univernal mint operation at %d only by %s not %s", timestamp, eid, snap.NodeId)
 	}
 
 	var signed *common.VersionedTransaction
@@ -308,13 +314,13 @@ func (node *Node) validateMintSnapshot(snap *common.Snapshot, tx *common.Version
 	}
 	signed = node.buildUniversalMintTransaction(cur, timestamp, true)
 	if signed == nil {
-		return fmt.Errorf("no universal mint available at %d", timestamp)
+		return common.Errorf(common.ErrorInvalidMint, "no universal mint available at %d", timestamp)
 	}
 
 	if tx.PayloadHash() != signed.PayloadHash() {
 		th := hex.EncodeToString(tx.PayloadMarshal())
 		sh := hex.EncodeToString(signed.PayloadMarshal())
-		return fmt.Errorf("malformed mint transaction at %d %s %s", timestamp, th, sh)
+		return common.Errorf(common.ErrorInvalidMint, "malformed mint transaction at %d %s %s", timestamp, th, sh)
 	}
 	return nil
 }
@@ -383,11 +389,6 @@ func (node *Node) ListRoundSpaces(cids []crypto.Hash, day uint64) (map[crypto.Ha
 	return spaces, nil
 }
 
-// a = average work
-// for x > 7a, y = 2a
-// for 7a > x > a, y = 1/6x + 5/6a
-// for a > x > 1/7a, y = x
-// for x < 1/7a, y = 1/7a
 func (node *Node) distributeKernelMintByWorks(accepted []*CNode, base common.Integer, timestamp uint64) ([]*CNodeWork, error) {
 	mints := make([]*CNodeWork, len(accepted))
 	cids := make([]crypto.Hash, len(accepted))
@@ -423,8 +424,6 @@ func (node *Node) distributeKernelMintByWorks(accepted []*CNode, base common.Int
 		return nil, err
 	}
 
-	var valid int
-	var minW, maxW, totalW common.Integer
 	for _, m := range mints {
 		ns := spaces[m.IdForNetwork]
 		if len(ns) > 0 {
@@ -433,7 +432,23 @@ func (node *Node) distributeKernelMintByWorks(accepted []*CNode, base common.Int
 			// otherwise this will not work in low transaction conditions
 			logger.Verbosef("node spaces %s %d %d\n", m.IdForNetwork, ns[0].Batch, len(ns))
 		}
+	}
+	err = distributeMintWorks(mints, works, base, thr, day)
+	if err != nil {
+		return nil, err
+	}
+	return mints, nil
+}
 
+// a = average work
+// for x > 7a, y = 2a
+// for 7a > x > a, y = 1/6x + 5/6a
+// for a > x > 1/7a, y = x
+// for x < 1/7a, y = 1/7a
+func distributeMintWorks(mints []*CNodeWork, works map[crypto.Hash][2]uint64, base common.Integer, thr int, day uint64) error {
+	var valid int
+	var minW, maxW, totalW common.Integer
+	for _, m := range mints {
 		w := works[m.IdForNetwork]
 		m.Work = common.NewInteger(w[0]).Mul(120).Div(100)
 		sign := common.NewInteger(w[1])
@@ -455,14 +470,14 @@ func (node *Node) distributeKernelMintByWorks(accepted []*CNode, base common.Int
 		totalW = totalW.Add(m.Work)
 	}
 	if valid < thr {
-		return nil, fmt.Errorf("distributeKernelMintByWorks not valid %d %d %d %d",
+		return common.Errorf(common.ErrorInvalidMint, "distributeKernelMintByWorks not valid %d %d %d %d",
 			day, len(mints), thr, valid)
 	}
 
 	totalW = totalW.Sub(minW).Sub(maxW)
 	avg := totalW.Div(valid - 2)
 	if avg.Sign() == 0 {
-		return nil, fmt.Errorf("distributeKernelMintByWorks not valid %d %d %d %d",
+		return common.Errorf(common.ErrorInvalidMint, "distributeKernelMintByWorks not valid %d %d %d %d",
 			day, len(mints), thr, valid)
 	}
 
@@ -483,7 +498,7 @@ func (node *Node) distributeKernelMintByWorks(accepted []*CNode, base common.Int
 		rat := m.Work.Ration(totalW)
 		m.Work = rat.Product(base)
 	}
-	return mints, nil
+	return nil
 }
 
 func (node *Node) validateWorksAndSpacesAggregator(cids []crypto.Hash, thr int, day uint64) error {
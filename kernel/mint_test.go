@@ -340,6 +340,58 @@ func TestMintWorks(t *testing.T) {
 	require.Equal(common.NewInteger(10000).Sub(total).String(), "0.00000016")
 }
 
+func TestMintForecast(t *testing.T) {
+	require := require.New(t)
+
+	root, err := os.MkdirTemp("", "mixin-mint-forecast-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	internal.ToggleMockRunAggregators(true)
+
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+
+	now := uint64(clock.Now().UnixNano())
+	batch := (now - node.Epoch) / OneDay
+	f, err := node.MintForecast(now)
+	require.Nil(err)
+	require.Equal(batch, f.Batch)
+	require.Equal(node.Epoch+batch*OneDay+7*uint64(time.Hour), f.Timestamp)
+	require.Equal(poolSizeUniversal(KernelNetworkLegacyEnding), f.Pool)
+	require.Equal(mintMultiBatchesSize(KernelNetworkLegacyEnding, batch), f.Amount)
+	require.Equal(f.Amount, f.Kernel.Add(f.Custodian).Add(f.Light))
+	require.Equal(f.Amount.Div(10).Mul(5), f.Kernel)
+	require.Len(f.Nodes, len(node.genesisNodes))
+	require.Contains(f.Error, "distributeKernelMintByWorks not valid")
+	for _, n := range f.Nodes {
+		require.Equal(common.Zero, n.Share)
+	}
+
+	workers := node.genesisNodes[:20]
+	snapshots := testBuildMintSnapshots(workers, 0, now-OneDay)
+	for _, id := range workers {
+		err = node.persistStore.WriteRoundWork(id, 0, snapshots, true)
+		require.Nil(err)
+	}
+
+	f, err = node.MintForecast(now)
+	require.Nil(err)
+	require.Equal("", f.Error)
+	require.Len(f.Nodes, len(node.genesisNodes))
+	total := common.NewInteger(0)
+	shares := make(map[crypto.Hash]common.Integer)
+	for _, n := range f.Nodes {
+		shares[n.Id] = n.Share
+		total = total.Add(n.Share)
+	}
+	require.True(f.Kernel.Sub(total).Cmp(common.NewIntegerFromString("0.00001")) < 0)
+	require.Equal(uint64(100), f.Nodes[0].Works[0])
+	work, idle := shares[workers[0]], shares[node.genesisNodes[26]]
+	require.Equal(work, shares[workers[19]])
+	require.Equal(work.Div(7).String(), idle.String())
+}
+
 func testBuildMintSnapshots(signers []crypto.Hash, round, timestamp uint64) []*common.SnapshotWork {
 	snapshots := make([]*common.SnapshotWork, 100)
 	for i := range snapshots {
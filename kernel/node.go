@@ -5,17 +5,22 @@ import (
 	"encoding/binary"
 	"fmt"
 	"math"
+	"net"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/MixinNetwork/mixin/common"
 	"github.com/MixinNetwork/mixin/config"
 	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/depositproof"
 	"github.com/MixinNetwork/mixin/kernel/internal/clock"
 	"github.com/MixinNetwork/mixin/logger"
 	"github.com/MixinNetwork/mixin/p2p"
+	"github.com/MixinNetwork/mixin/remotesigner"
 	"github.com/MixinNetwork/mixin/storage"
 	"github.com/dgraph-io/ristretto/v2"
 )
@@ -24,8 +29,10 @@ type Node struct {
 	IdForNetwork crypto.Hash
 	Signer       common.Address
 	isRelayer    bool
+	signer       crypto.Signer
 
 	Peer          *p2p.Peer
+	network       p2p.Network
 	TopoCounter   *TopologicalSequence
 	SyncPoints    *syncMap
 	SyncPointsMap map[crypto.Hash]*p2p.SyncPoint
@@ -36,22 +43,40 @@ type Node struct {
 
 	chains                     *chainsMap
 	allNodesSortedWithState    []*CNode
+	payeeUpdates               []*common.NodePayeeUpdate
 	nodeStateSequences         []*NodeStateSequence
 	acceptedNodeStateSequences []*NodeStateSequence
 	chain                      *Chain
 
 	genesisNodesMap map[crypto.Hash]bool
 	genesisNodes    []crypto.Hash
+	devMode         bool
 	startAt         time.Time
 	networkId       crypto.Hash
 	persistStore    storage.Store
 	cacheStore      *ristretto.Cache[[]byte, any]
 	custom          *config.Custom
+	prevalidations  chan *prevalidationJob
+	interner        *transactionInterner
+	authAudits      *common.AuditThrottle
+	partition       atomic.Pointer[PartitionState]
+	syncStatus      atomic.Pointer[SyncStatus]
+	replicaSync     *syncTracker
+	frontiers       *syncFrontierMap
+	warnings        electionWarnings
+	commitments     stateCommitments
+	depositProofs   *depositproof.Checker
+	memoryPressure  atomic.Int32
 
 	done chan struct{}
 	elc  chan struct{}
 	mlc  chan struct{}
 	cqc  chan struct{}
+	pvc  chan struct{}
+	plc  chan struct{}
+	ssc  chan struct{}
+	mwc  chan struct{}
+	ivc  chan struct{}
 }
 
 type NodeStateSequence struct {
@@ -69,27 +94,67 @@ type CNode struct {
 	ConsensusIndex int
 }
 
+// ApplyConsensusParams applies the consensus params of the genesis to the
+// process, it must be called before any node setup, all the nodes of the
+// process share the same params.
+func ApplyConsensusParams(gns *common.Genesis) error {
+	params, err := gns.ConsensusParams()
+	if err != nil {
+		return err
+	}
+	config.SetConsensusParams(params)
+	return nil
+}
+
 func SetupNode(custom *config.Custom, store storage.Store, cache *ristretto.Cache[[]byte, any], gns *common.Genesis) (*Node, error) {
+	params, err := gns.ConsensusParams()
+	if err != nil {
+		return nil, err
+	}
+	if params != config.CurrentConsensusParams() {
+		return nil, fmt.Errorf("consensus params %v not applied before the node setup", params)
+	}
+
 	node := &Node{
-		SyncPoints:      &syncMap{mutex: new(sync.RWMutex), m: make(map[crypto.Hash]*p2p.SyncPoint)},
+		SyncPoints:      newSyncMap(),
+		frontiers:       newSyncFrontierMap(),
 		chains:          &chainsMap{m: make(map[crypto.Hash]*Chain)},
 		genesisNodesMap: make(map[crypto.Hash]bool),
 		persistStore:    store,
 		cacheStore:      cache,
 		custom:          custom,
 		startAt:         clock.Now(),
+		prevalidations:  make(chan *prevalidationJob, PrevalidationQueueSize),
+		interner:        newTransactionInterner(),
+		authAudits:      common.NewAuditThrottle(time.Minute),
 		done:            make(chan struct{}),
 		elc:             make(chan struct{}),
 		mlc:             make(chan struct{}),
 		cqc:             make(chan struct{}),
+		pvc:             make(chan struct{}),
+		plc:             make(chan struct{}),
+		ssc:             make(chan struct{}),
+		mwc:             make(chan struct{}),
+		ivc:             make(chan struct{}),
 	}
 
-	node.loadNodeConfig()
+	err = node.loadNodeConfig()
+	if err != nil {
+		return nil, fmt.Errorf("loadNodeConfig() => %v", err)
+	}
+	err = node.loadDepositProofs()
+	if err != nil {
+		return nil, fmt.Errorf("loadDepositProofs() => %v", err)
+	}
 
 	mint := node.lastMintDistribution()
 	node.LastMint = mint.Batch
 
-	err := node.LoadGenesis(gns)
+	if config.SnapshotRoundGap != config.SnapshotRoundGapDefault &&
+		gns.NetworkId().String() == config.KernelNetworkId {
+		return nil, fmt.Errorf("invalid round gap %d for the mainnet", config.SnapshotRoundGap)
+	}
+	err = node.LoadGenesis(gns)
 	if err != nil {
 		return nil, fmt.Errorf("LoadGenesis(%v) => %v", gns, err)
 	}
@@ -103,7 +168,7 @@ func SetupNode(custom *config.Custom, store storage.Store, cache *ristretto.Cach
 	} else if invalid > 0 {
 		return nil, fmt.Errorf("validate graph with %d/%d invalid entries", invalid, total)
 	}
-	logger.Printf("Validate graph with %d total entries in %s\n", total, clock.Now().Sub(start).String())
+	logger.Printw("Validate graph entries", "total", total, "elapse", clock.Now().Sub(start).String())
 
 	err = node.LoadConsensusNodes()
 	if err != nil {
@@ -114,31 +179,65 @@ func SetupNode(custom *config.Custom, store storage.Store, cache *ristretto.Cach
 	if err != nil {
 		return nil, fmt.Errorf("LoadAllChainsAndGraphTimestamp() => %v", err)
 	}
-	node.chain = node.BootChain(node.IdForNetwork)
+	if node.replica() {
+		node.chain = node.getOrCreateChain(node.IdForNetwork)
+	} else {
+		node.chain = node.BootChain(node.IdForNetwork)
+	}
 
-	logger.Printf("Signer:\t%s\n", node.Signer.String())
-	logger.Printf("Network:\t%s\n", node.networkId.String())
-	logger.Printf("Node Id:\t%s\n", node.IdForNetwork.String())
-	logger.Printf("Topology:\t%d\n", node.TopoCounter.seq)
+	logger.Printw("Setup node", "signer", node.Signer.String(), "network", node.networkId.String(),
+		"node", node.IdForNetwork.String(), "topology", node.TopoCounter.seq)
 	return node, nil
 }
 
-func (node *Node) loadNodeConfig() {
+func (node *Node) loadNodeConfig() error {
 	var addr common.Address
-	addr.PrivateSpendKey = node.custom.Node.Signer
-	addr.PublicSpendKey = addr.PrivateSpendKey.Public()
+	if cosigners := node.custom.Node.SignerCosigners; len(cosigners) > 0 {
+		signer, err := remotesigner.NewThresholdSigner(node.custom.Node.SignerThreshold, cosigners,
+			node.custom.Node.SignerRemoteCert, node.custom.Node.SignerRemoteKey, node.custom.Node.SignerRemoteCA)
+		if err != nil {
+			return err
+		}
+		node.signer = signer
+		addr.PublicSpendKey = signer.PublicKey()
+	} else if remote := node.custom.Node.SignerRemote; remote != "" {
+		signer, err := remotesigner.NewClient(remote, node.custom.Node.SignerRemoteCert,
+			node.custom.Node.SignerRemoteKey, node.custom.Node.SignerRemoteCA)
+		if err != nil {
+			return err
+		}
+		node.signer = signer
+		addr.PublicSpendKey = signer.PublicKey()
+	} else {
+		node.signer = crypto.NewKeySigner(node.custom.Node.Signer)
+		addr.PrivateSpendKey = node.custom.Node.Signer
+		addr.PublicSpendKey = addr.PrivateSpendKey.Public()
+	}
 	addr.PrivateViewKey = addr.PublicSpendKey.DeterministicHashDerive()
 	addr.PublicViewKey = addr.PrivateViewKey.Public()
 	node.Signer = addr
 	node.isRelayer = node.custom.P2P.Relayer
+	return nil
 }
 
+// buildNodeStateSequences builds a sequence for each node state change and
+// each payee change, so the payee is correct for the mint and removal at
+// any timestamp.
 func (node *Node) buildNodeStateSequences(allNodesSortedWithState []*CNode, acceptedOnly bool) []*NodeStateSequence {
-	nodeStateSequences := make([]*NodeStateSequence, len(allNodesSortedWithState))
-	for i, n := range allNodesSortedWithState {
-		nodes := node.nodeSequenceWithoutState(n.Timestamp+1, acceptedOnly)
+	timestamps := make([]uint64, 0, len(allNodesSortedWithState)+len(node.payeeUpdates))
+	for _, n := range allNodesSortedWithState {
+		timestamps = append(timestamps, n.Timestamp)
+	}
+	for _, u := range node.payeeUpdates {
+		timestamps = append(timestamps, u.Timestamp)
+	}
+	slices.Sort(timestamps)
+
+	nodeStateSequences := make([]*NodeStateSequence, len(timestamps))
+	for i, ts := range timestamps {
+		nodes := node.nodeSequenceWithoutState(ts+1, acceptedOnly)
 		seq := &NodeStateSequence{
-			Timestamp:         n.Timestamp,
+			Timestamp:         ts,
 			NodesWithoutState: nodes,
 		}
 		nodeStateSequences[i] = seq
@@ -160,21 +259,58 @@ func (node *Node) NodesListWithoutState(threshold uint64, acceptedOnly bool) []*
 	return nil
 }
 
+// payeeChangedAfter is true if the payee of the node is from an activated
+// payee update after the node state, e.g. accepted.
+func (node *Node) payeeChangedAfter(cn *CNode) bool {
+	for _, u := range node.payeeUpdates {
+		if u.Timestamp <= cn.Timestamp || !node.consensusForks(u.Timestamp).NodePayeeChange {
+			continue
+		}
+		if u.Signer.PublicSpendKey == cn.Signer.PublicSpendKey &&
+			u.Payee.PublicSpendKey == cn.Payee.PublicSpendKey {
+			return true
+		}
+	}
+	return false
+}
+
 func (node *Node) nodeSequenceWithoutState(threshold uint64, acceptedOnly bool) []*CNode {
 	filter := make(map[crypto.Hash]*CNode)
+	pledges := make(map[crypto.Hash]uint64)
 	for _, n := range node.allNodesSortedWithState {
 		if n.Timestamp >= threshold {
 			break
 		}
 		filter[n.IdForNetwork] = n
+		if n.State == common.NodeStatePledging {
+			pledges[n.IdForNetwork] = n.Timestamp
+		}
+	}
+	// a payee update applies until the signer pledges again
+	payees := make(map[crypto.Hash]common.Address)
+	for _, u := range node.payeeUpdates {
+		if u.Timestamp >= threshold {
+			break
+		}
+		if !node.consensusForks(u.Timestamp).NodePayeeChange {
+			continue
+		}
+		id := u.Signer.Hash().ForNetwork(node.networkId)
+		if filter[id] != nil && u.Timestamp > pledges[id] {
+			payees[id] = u.Payee
+		}
 	}
 	nodes := make([]*CNode, 0)
 	for _, n := range filter {
 		if !acceptedOnly || n.State == common.NodeStateAccepted {
+			payee, changed := payees[n.IdForNetwork]
+			if !changed {
+				payee = n.Payee
+			}
 			nodes = append(nodes, &CNode{
 				IdForNetwork: n.IdForNetwork,
 				Signer:       n.Signer,
-				Payee:        n.Payee,
+				Payee:        payee,
 				Transaction:  n.Transaction,
 				Timestamp:    n.Timestamp,
 				State:        n.State,
@@ -266,34 +402,53 @@ func (node *Node) ConsensusThreshold(timestamp uint64, final bool) int {
 	consensusBase := 0
 	nodes := node.NodesListWithoutState(timestamp, false)
 	for _, cn := range nodes {
-		threshold := config.SnapshotReferenceThreshold * config.SnapshotRoundGap
-		if threshold > uint64(3*time.Minute) {
-			panic("should never be here")
-		}
-		switch cn.State {
-		case common.NodeStatePledging:
-			// FIXME the pledge transaction may be broadcasted very late
-			// at this situation, the node should be treated as evil
-			if config.KernelNodeAcceptPeriodMinimum < time.Hour {
-				panic("should never be here")
-			}
-			t := uint64(config.KernelNodeAcceptPeriodMinimum) - threshold*3
-			if !final && cn.Timestamp+t < timestamp {
-				consensusBase++
-			}
-		case common.NodeStateAccepted:
-			if node.genesisNodesMap[cn.IdForNetwork] || cn.Timestamp+threshold < timestamp {
-				consensusBase++
-			}
+		if node.consensusBaseAt(cn, final) < timestamp {
+			consensusBase++
 		}
 	}
-	if consensusBase < config.KernelMinimumNodesCount {
-		logger.Debugf("invalid consensus base %d %d %d\n", timestamp, consensusBase, config.KernelMinimumNodesCount)
+	if consensusBase < node.minimumNodesCount() {
+		logger.Debugf("invalid consensus base %d %d %d\n", timestamp, consensusBase, node.minimumNodesCount())
 		return 1000
 	}
 	return consensusBase*2/3 + 1
 }
 
+// minimumNodesCount is a single node for the dev genesis, then the threshold
+// is 1 and the node finalizes its snapshots alone.
+func (node *Node) minimumNodesCount() int {
+	if node.devMode {
+		return 1
+	}
+	return config.KernelMinimumNodesCount
+}
+
+// consensusBaseAt returns the timestamp after which the node is counted in
+// the consensus base of ConsensusThreshold, or math.MaxUint64 if never.
+func (node *Node) consensusBaseAt(cn *CNode, final bool) uint64 {
+	threshold := config.SnapshotReferenceThreshold * config.SnapshotRoundGap
+	if threshold > uint64(3*time.Minute) {
+		panic("should never be here")
+	}
+	switch cn.State {
+	case common.NodeStatePledging:
+		// FIXME the pledge transaction may be broadcasted very late
+		// at this situation, the node should be treated as evil
+		if config.KernelNodeAcceptPeriodMinimum < time.Hour {
+			panic("should never be here")
+		}
+		t := uint64(config.KernelNodeAcceptPeriodMinimum) - threshold*3
+		if !final {
+			return cn.Timestamp + t
+		}
+	case common.NodeStateAccepted:
+		if node.genesisNodesMap[cn.IdForNetwork] {
+			return 0
+		}
+		return cn.Timestamp + threshold
+	}
+	return math.MaxUint64
+}
+
 func (node *Node) LoadConsensusNodes() error {
 	threshold := uint64(clock.Now().UnixNano()) * 2
 	nodes := node.persistStore.ReadAllNodes(threshold, true)
@@ -321,6 +476,7 @@ func (node *Node) LoadConsensusNodes() error {
 		logger.Printf("LoadConsensusNode %v\n", cnodes[i])
 	}
 	node.allNodesSortedWithState = cnodes
+	node.payeeUpdates = node.persistStore.ReadNodePayeeUpdates(threshold)
 	node.nodeStateSequences = node.buildNodeStateSequences(cnodes, false)
 	node.acceptedNodeStateSequences = node.buildNodeStateSequences(cnodes, true)
 	return nil
@@ -336,8 +492,11 @@ func (node *Node) NewTransaction(assetId crypto.Hash) *common.Transaction {
 }
 
 func (node *Node) addRelayersFromConfig() error {
-	addr := fmt.Sprintf(":%d", node.custom.P2P.Port)
+	addr := config.ListenAddress(node.custom.P2P.Listen, node.custom.P2P.Port)
 	node.Peer = p2p.NewPeer(node, node.IdForNetwork, addr, node.isRelayer)
+	if node.network != nil {
+		node.Peer.SetNetwork(node.network)
+	}
 
 	for _, s := range node.custom.P2P.Seeds {
 		parts := strings.Split(s, "@")
@@ -366,20 +525,34 @@ func (node *Node) listenConsumers() {
 	}
 }
 
-func (node *Node) BuildAuthenticationMessage(relayerId crypto.Hash) []byte {
+// SetNetwork replaces the quic network of the peer, e.g. with the memory
+// network of the simulations, it must be called before the node loop.
+func (node *Node) SetNetwork(network p2p.Network) {
+	node.network = network
+}
+
+// SetRelayer switches the running node between the relayer and consumer
+// roles, the config relayer option is only the role at startup.
+func (node *Node) SetRelayer(relayer bool) error {
+	return node.Peer.SetRelayer(relayer)
+}
+
+func (node *Node) BuildAuthenticationMessage(relayerId crypto.Hash) ([]byte, error) {
 	data := make([]byte, 8)
 	binary.BigEndian.PutUint64(data, uint64(clock.Now().Unix()))
 	data = append(data, relayerId[:]...)
 	data = append(data, node.Signer.PublicSpendKey[:]...)
-	if node.isRelayer {
+	if node.Peer.IsRelayer() {
 		data = append(data, 1)
 	} else {
 		data = append(data, 0)
 	}
 	dh := crypto.Blake3Hash(data)
-	sig := node.Signer.PrivateSpendKey.Sign(dh)
-	data = append(data, sig[:]...)
-	return data
+	sig, err := node.signer.Sign(dh)
+	if err != nil {
+		return nil, err
+	}
+	return append(data, sig[:]...), nil
 }
 
 func (node *Node) AuthenticateAs(recipientId crypto.Hash, msg []byte, timeoutSec int64) (*p2p.AuthToken, error) {
@@ -420,6 +593,33 @@ func (node *Node) AuthenticateAs(recipientId crypto.Hash, msg []byte, timeoutSec
 	return token, nil
 }
 
+// RecordAuthenticationFailure audits the peer authentication failures, at
+// most once a minute for each remote host to not flood the audit log.
+func (node *Node) RecordAuthenticationFailure(address string, err error) {
+	host, _, serr := net.SplitHostPort(address)
+	if serr != nil {
+		host = address
+	}
+	now := uint64(clock.Now().UnixNano())
+	if !node.authAudits.Allow(host, now) {
+		return
+	}
+	detail := err.Error()
+	if len(detail) > 1024 {
+		detail = detail[:1024]
+	}
+	werr := node.persistStore.WriteAuditEntry(&common.AuditEntry{
+		Timestamp: now,
+		Actor:     "p2p",
+		Action:    common.AuditActionPeerAuthFailure,
+		Source:    host,
+		Detail:    detail,
+	})
+	if werr != nil {
+		logger.Printf("RecordAuthenticationFailure(%s) => %v\n", address, werr)
+	}
+}
+
 func (node *Node) NetworkId() crypto.Hash {
 	return node.networkId
 }
@@ -432,9 +632,9 @@ func (node *Node) GetCacheStore() *ristretto.Cache[[]byte, any] {
 	return node.cacheStore
 }
 
-func (node *Node) SignData(data []byte) crypto.Signature {
+func (node *Node) SignData(data []byte) (crypto.Signature, error) {
 	dh := crypto.Blake3Hash(data)
-	return node.Signer.PrivateSpendKey.Sign(dh)
+	return node.signer.Sign(dh)
 }
 
 func (node *Node) BuildGraph() []*p2p.SyncPoint {
@@ -469,6 +669,7 @@ func (node *Node) SendTransactionToPeer(peerId, hash crypto.Hash) error {
 }
 
 func (node *Node) CachePutTransaction(peerId crypto.Hash, tx *common.VersionedTransaction) error {
+	node.prefetchDepositProof(tx)
 	return node.persistStore.CachePutTransaction(tx)
 }
 
@@ -481,8 +682,13 @@ func (node *Node) ReadAllNodesWithoutState() []crypto.Hash {
 	return all
 }
 
-func (node *Node) ReadSnapshotsSinceTopology(offset, count uint64) ([]*common.SnapshotWithTopologicalOrder, error) {
-	return node.persistStore.ReadSnapshotsSinceTopology(offset, count)
+func (node *Node) ReadRound(hash crypto.Hash) (*common.Round, error) {
+	return node.persistStore.ReadRound(hash)
+}
+
+func (node *Node) ReadTransaction(hash crypto.Hash) (*common.VersionedTransaction, error) {
+	tx, _, err := node.checkTxInStorage(hash)
+	return tx, err
 }
 
 func (node *Node) ReadSnapshotsForNodeRound(nodeIdWithNetwork crypto.Hash, round uint64) ([]*common.SnapshotWithTopologicalOrder, error) {
@@ -515,9 +721,15 @@ func (node *Node) UpdateSyncPoint(peerId crypto.Hash, points []*p2p.SyncPoint, d
 	if peer != nil && !peer.Signer.PublicSpendKey.Verify(crypto.Blake3Hash(data), *sig) {
 		return fmt.Errorf("invalid graph signature %s", peerId)
 	}
+	if peer != nil {
+		node.SyncPoints.SetGraph(peerId, points)
+	}
 	for _, p := range points {
 		if p.NodeId == node.IdForNetwork {
 			node.SyncPoints.Set(peerId, p)
+			if node.chain.State != nil {
+				observeSyncPointLag(peerId, node.chain.State.FinalRound.Number, p.Number)
+			}
 		}
 	}
 	node.SyncPointsMap = node.SyncPoints.Map()
@@ -526,7 +738,7 @@ func (node *Node) UpdateSyncPoint(peerId crypto.Hash, points []*p2p.SyncPoint, d
 
 func (node *Node) CheckBroadcastedToPeers() bool {
 	spm := node.SyncPointsMap
-	if len(spm) == 0 || node.chain.State == nil {
+	if len(spm) == 0 && !node.devMode || node.chain.State == nil {
 		return false
 	}
 
@@ -547,7 +759,7 @@ func (node *Node) CheckBroadcastedToPeers() bool {
 
 func (node *Node) CheckCatchUpWithPeers() bool {
 	spm := node.SyncPointsMap
-	if len(spm) == 0 || node.chain.State == nil {
+	if len(spm) == 0 && !node.devMode || node.chain.State == nil {
 		return false
 	}
 
@@ -609,14 +821,64 @@ func (node *Node) waitOrDone(wait time.Duration) bool {
 }
 
 type syncMap struct {
-	mutex *sync.RWMutex
-	m     map[crypto.Hash]*p2p.SyncPoint
+	mutex  *sync.RWMutex
+	m      map[crypto.Hash]*p2p.SyncPoint
+	times  map[crypto.Hash]time.Time
+	graphs map[crypto.Hash]map[crypto.Hash]uint64
+}
+
+func newSyncMap() *syncMap {
+	return &syncMap{
+		mutex:  new(sync.RWMutex),
+		m:      make(map[crypto.Hash]*p2p.SyncPoint),
+		times:  make(map[crypto.Hash]time.Time),
+		graphs: make(map[crypto.Hash]map[crypto.Hash]uint64),
+	}
 }
 
 func (s *syncMap) Set(k crypto.Hash, p *p2p.SyncPoint) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 	s.m[k] = p
+	s.times[k] = clock.Now()
+}
+
+// SetGraph keeps the final round numbers of all chains reported by the peer.
+func (s *syncMap) SetGraph(k crypto.Hash, points []*p2p.SyncPoint) {
+	graph := make(map[crypto.Hash]uint64)
+	for _, p := range points {
+		graph[p.NodeId] = p.Number
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.graphs[k] = graph
+}
+
+// RemoteFinals returns the highest final round number of each chain reported
+// by the peers.
+func (s *syncMap) RemoteFinals() map[crypto.Hash]uint64 {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	m := make(map[crypto.Hash]uint64)
+	for _, graph := range s.graphs {
+		for id, n := range graph {
+			m[id] = max(m[id], n)
+		}
+	}
+	return m
+}
+
+// Times returns when the sync point of each peer is updated the last time.
+func (s *syncMap) Times() map[crypto.Hash]time.Time {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	m := make(map[crypto.Hash]time.Time)
+	for k, t := range s.times {
+		m[k] = t
+	}
+	return m
 }
 
 func (s *syncMap) Map() map[crypto.Hash]*p2p.SyncPoint {
@@ -0,0 +1,84 @@
+package kernel
+
+import (
+	"math"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+)
+
+type ConsensusNodeState struct {
+	*CNode
+	Genesis bool
+	Ready   bool
+	ReadyAt uint64
+	Counted bool
+	// the timestamps after which the node is counted in the consensus base
+	// of the cache and final thresholds, math.MaxUint64 if never
+	CountedAt      uint64
+	FinalCountedAt uint64
+}
+
+type PledgingSlot struct {
+	Node         *CNode
+	AcceptAfter  uint64
+	AcceptBefore uint64
+	AcceptHour   bool
+	NextPledgeAt uint64
+	Available    bool
+}
+
+type ConsensusNodesState struct {
+	Timestamp      uint64
+	Threshold      int
+	FinalThreshold int
+	Nodes          []*ConsensusNodeState
+	Pledging       *PledgingSlot
+}
+
+// ConsensusNodesAt returns the consensus nodes list exactly as seen by the
+// ConsensusThreshold at the timestamp, with the maturity of each node, and
+// the occupancy of the single pledging slot. A new pledge is only possible
+// when the slot is empty and the pledge period passed since the last node
+// state change, while the pledging node is only accepted or cancelled in the
+// accept window, and also in the accept hours of the day since the epoch.
+func (node *Node) ConsensusNodesAt(timestamp uint64) *ConsensusNodesState {
+	state := &ConsensusNodesState{
+		Timestamp:      timestamp,
+		Threshold:      node.ConsensusThreshold(timestamp, false),
+		FinalThreshold: node.ConsensusThreshold(timestamp, true),
+		Pledging:       &PledgingSlot{AcceptHour: node.checkConsensusAcceptHour(timestamp)},
+	}
+
+	var accepted int
+	var last uint64
+	for _, cn := range node.NodesListWithoutState(timestamp, false) {
+		ns := &ConsensusNodeState{
+			CNode:          cn,
+			Genesis:        node.genesisNodesMap[cn.IdForNetwork],
+			Ready:          node.ConsensusReady(cn, timestamp),
+			CountedAt:      node.consensusBaseAt(cn, false),
+			FinalCountedAt: node.consensusBaseAt(cn, true),
+		}
+		ns.Counted = ns.CountedAt < timestamp
+		if cn.State == common.NodeStateAccepted && !ns.Genesis {
+			ns.ReadyAt = cn.Timestamp + uint64(config.KernelNodeAcceptPeriodMinimum)
+		}
+		if cn.State == common.NodeStateAccepted {
+			accepted += 1
+		}
+		last = max(last, cn.Timestamp)
+		state.Nodes = append(state.Nodes, ns)
+	}
+
+	slot := state.Pledging
+	slot.NextPledgeAt = last + uint64(config.KernelNodePledgePeriodMinimum)
+	if pledging := node.PledgingNode(timestamp); pledging != nil {
+		slot.Node = pledging
+		slot.AcceptAfter = pledging.Timestamp + uint64(config.KernelNodeAcceptPeriodMinimum)
+		slot.AcceptBefore = pledging.Timestamp + uint64(config.KernelNodeAcceptPeriodMaximum)
+		slot.NextPledgeAt = math.MaxUint64
+	}
+	slot.Available = slot.NextPledgeAt <= timestamp && accepted < MaxKernelNodesCount
+	return state
+}
@@ -0,0 +1,152 @@
+package kernel
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsensusNodesAt(t *testing.T) {
+	require := require.New(t)
+
+	epoch := uint64(1551312000000000000)
+	node := &Node{Epoch: epoch, genesisNodesMap: make(map[crypto.Hash]bool)}
+	var cnodes []*CNode
+	for i := range 7 {
+		id := crypto.Blake3Hash([]byte(fmt.Sprintf("genesis-%d", i)))
+		node.genesisNodesMap[id] = true
+		cnodes = append(cnodes, &CNode{IdForNetwork: id, Timestamp: epoch, State: common.NodeStateAccepted})
+	}
+	accepted := epoch + uint64(time.Hour*24)
+	cnodes = append(cnodes, &CNode{
+		IdForNetwork: crypto.Blake3Hash([]byte("accepted")),
+		Timestamp:    accepted,
+		State:        common.NodeStateAccepted,
+	})
+	pledged := accepted + uint64(time.Hour*24)
+	cnodes = append(cnodes, &CNode{
+		IdForNetwork: crypto.Blake3Hash([]byte("pledging")),
+		Timestamp:    pledged,
+		State:        common.NodeStatePledging,
+	})
+	node.allNodesSortedWithState = cnodes
+	node.nodeStateSequences = node.buildNodeStateSequences(cnodes, false)
+	node.acceptedNodeStateSequences = node.buildNodeStateSequences(cnodes, true)
+
+	threshold := config.SnapshotReferenceThreshold * config.SnapshotRoundGap
+	now := accepted + 1
+	state := node.ConsensusNodesAt(now)
+	require.Len(state.Nodes, 8)
+	require.Equal(node.ConsensusThreshold(now, false), state.Threshold)
+	require.Equal(7*2/3+1, state.Threshold)
+	an := state.Nodes[7]
+	require.Equal(7, an.ConsensusIndex)
+	require.False(an.Genesis)
+	require.False(an.Ready)
+	require.False(an.Counted)
+	require.Equal(accepted+threshold, an.CountedAt)
+	require.Equal(accepted+uint64(config.KernelNodeAcceptPeriodMinimum), an.ReadyAt)
+	require.Nil(state.Pledging.Node)
+	require.False(state.Pledging.Available)
+	require.Equal(accepted+uint64(config.KernelNodePledgePeriodMinimum), state.Pledging.NextPledgeAt)
+
+	now = pledged + 1
+	state = node.ConsensusNodesAt(now)
+	require.Len(state.Nodes, 9)
+	require.Equal(8*2/3+1, state.Threshold)
+	require.Equal(state.Threshold, state.FinalThreshold)
+	require.True(state.Nodes[7].Ready)
+	require.True(state.Nodes[7].Counted)
+	pn := state.Nodes[8]
+	require.Equal(8, pn.ConsensusIndex)
+	require.False(pn.Ready)
+	require.False(pn.Counted)
+	require.Equal(uint64(math.MaxUint64), pn.FinalCountedAt)
+	require.NotNil(state.Pledging.Node)
+	require.Equal(pn.IdForNetwork, state.Pledging.Node.IdForNetwork)
+	require.Equal(pledged+uint64(config.KernelNodeAcceptPeriodMinimum), state.Pledging.AcceptAfter)
+	require.Equal(pledged+uint64(config.KernelNodeAcceptPeriodMaximum), state.Pledging.AcceptBefore)
+	require.False(state.Pledging.Available)
+
+	now = pn.CountedAt + 1
+	state = node.ConsensusNodesAt(now)
+	require.True(state.Nodes[8].Counted)
+	require.Equal(9*2/3+1, state.Threshold)
+	require.Equal(8*2/3+1, state.FinalThreshold)
+	require.Equal(node.ConsensusThreshold(now, true), state.FinalThreshold)
+}
+
+func TestNodePayeeUpdates(t *testing.T) {
+	require := require.New(t)
+
+	epoch := uint64(1551312000000000000)
+	node := &Node{Epoch: epoch, genesisNodesMap: make(map[crypto.Hash]bool)}
+	var cnodes []*CNode
+	for i := range 7 {
+		signer := testPledgeSigner(fmt.Sprintf("genesis-%d", i))
+		id := signer.Hash().ForNetwork(node.networkId)
+		node.genesisNodesMap[id] = true
+		payee := testPledgeSigner(fmt.Sprintf("payee-%d", i))
+		cnodes = append(cnodes, &CNode{IdForNetwork: id, Signer: signer, Payee: payee, Timestamp: epoch, State: common.NodeStateAccepted})
+	}
+	changed := epoch + uint64(time.Hour*24)
+	payee := testPledgeSigner("payee-changed")
+	node.payeeUpdates = []*common.NodePayeeUpdate{{Signer: cnodes[2].Signer, Payee: payee, Timestamp: changed}}
+	node.allNodesSortedWithState = cnodes
+	node.nodeStateSequences = node.buildNodeStateSequences(cnodes, false)
+	node.acceptedNodeStateSequences = node.buildNodeStateSequences(cnodes, true)
+	require.Len(node.nodeStateSequences, len(cnodes)+1)
+
+	payeeAt := func(ts uint64, acceptedOnly bool) common.Address {
+		for _, cn := range node.NodesListWithoutState(ts, acceptedOnly) {
+			if cn.IdForNetwork == cnodes[2].IdForNetwork {
+				return cn.Payee
+			}
+		}
+		return common.Address{}
+	}
+	require.Equal(cnodes[2].Payee, payeeAt(changed, false))
+	require.Equal(payee, payeeAt(changed+1, false))
+	require.Equal(payee, payeeAt(changed+1, true))
+	require.Equal(cnodes[2].Payee, payeeAt(changed, true))
+	accepted := &CNode{Signer: cnodes[2].Signer, Payee: payee, Timestamp: epoch}
+	require.True(node.payeeChangedAfter(accepted))
+
+	mainnet := &Node{Epoch: epoch, payeeUpdates: node.payeeUpdates}
+	mainnet.networkId, _ = crypto.HashFromString(config.KernelNetworkId)
+	var mainnetNodes []*CNode
+	for _, cn := range cnodes {
+		id := cn.Signer.Hash().ForNetwork(mainnet.networkId)
+		mainnetNodes = append(mainnetNodes, &CNode{IdForNetwork: id, Signer: cn.Signer, Payee: cn.Payee, Timestamp: cn.Timestamp, State: cn.State})
+	}
+	mainnet.allNodesSortedWithState = mainnetNodes
+	mainnet.nodeStateSequences = mainnet.buildNodeStateSequences(mainnetNodes, false)
+	require.False(mainnet.payeeChangedAfter(accepted))
+	for _, cn := range mainnet.NodesListWithoutState(changed+1, false) {
+		require.NotEqual(payee, cn.Payee)
+	}
+	for _, cn := range node.NodesListWithoutState(changed+1, false) {
+		if cn.IdForNetwork != cnodes[2].IdForNetwork {
+			require.NotEqual(payee, cn.Payee)
+		}
+	}
+
+	removed := changed + uint64(time.Hour*24)
+	cnodes = append(cnodes, &CNode{IdForNetwork: cnodes[2].IdForNetwork, Signer: cnodes[2].Signer, Payee: cnodes[2].Payee, Timestamp: removed, State: common.NodeStateRemoved})
+	node.allNodesSortedWithState = cnodes
+	node.nodeStateSequences = node.buildNodeStateSequences(cnodes, false)
+	require.Equal(payee, payeeAt(removed+1, false))
+
+	pledged := removed + uint64(time.Hour*24)
+	repledge := testPledgeSigner("payee-repledge")
+	cnodes = append(cnodes, &CNode{IdForNetwork: cnodes[2].IdForNetwork, Signer: cnodes[2].Signer, Payee: repledge, Timestamp: pledged, State: common.NodeStatePledging})
+	node.allNodesSortedWithState = cnodes
+	node.nodeStateSequences = node.buildNodeStateSequences(cnodes, false)
+	require.Equal(repledge, payeeAt(pledged+1, false))
+}
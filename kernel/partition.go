@@ -0,0 +1,96 @@
+package kernel
+
+import (
+	"slices"
+	"time"
+
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/kernel/internal/clock"
+	"github.com/MixinNetwork/mixin/logger"
+)
+
+// the peers send the graph every half round gap, so a sync point is stale
+// after missing about forty graphs, and the partition is only reported when
+// the stale peers last for another such period, to ignore the short flaps.
+const partitionStaleRounds = 20
+
+type PartitionState struct {
+	Partitioned bool          `json:"partitioned"`
+	Since       uint64        `json:"since"`
+	Accepted    int           `json:"accepted"`
+	Stale       []crypto.Hash `json:"stale"`
+}
+
+// PartitionState reports whether the node lost the sync points from more
+// than 1/3 of the accepted nodes, which halts the finality silently.
+func (node *Node) PartitionState() *PartitionState {
+	state := node.partition.Load()
+	if state == nil {
+		return &PartitionState{}
+	}
+	return state
+}
+
+func (node *Node) PartitionLoop() {
+	defer close(node.plc)
+
+	ticker := time.NewTicker(time.Duration(config.SnapshotRoundGap))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-node.done:
+			return
+		case <-ticker.C:
+			node.checkPartition(clock.Now())
+		}
+	}
+}
+
+func (node *Node) checkPartition(now time.Time) *PartitionState {
+	period := time.Duration(config.SnapshotRoundGap) * partitionStaleRounds
+	nodes := node.NodesListWithoutState(uint64(now.UnixNano()), true)
+	times := node.SyncPoints.Times()
+	old := node.PartitionState()
+
+	state := &PartitionState{Accepted: len(nodes)}
+	for _, cn := range nodes {
+		id := cn.IdForNetwork
+		if id == node.IdForNetwork {
+			continue
+		}
+		last := times[id]
+		if last.Before(node.startAt) {
+			last = node.startAt
+		}
+		if now.Sub(last) <= period {
+			if slices.Contains(old.Stale, id) {
+				logger.Printw("Peer sync point recovered", "alert", "peer", "peer", id.String())
+			}
+			continue
+		}
+		state.Stale = append(state.Stale, id)
+		if !slices.Contains(old.Stale, id) {
+			logger.Printw("Peer sync point stale", "alert", "peer", "peer", id.String(),
+				"last", last.UnixNano())
+		}
+	}
+
+	if len(state.Stale)*3 > len(nodes) {
+		state.Since = old.Since
+		if state.Since == 0 {
+			state.Since = uint64(now.UnixNano())
+		}
+		state.Partitioned = now.Sub(time.Unix(0, int64(state.Since))) >= period
+	}
+	if state.Partitioned && !old.Partitioned {
+		logger.Printw("Partition detected", "alert", "partition", "stale", len(state.Stale),
+			"accepted", state.Accepted, "since", state.Since)
+	} else if !state.Partitioned && old.Partitioned {
+		logger.Printw("Partition recovered", "alert", "partition", "stale", len(state.Stale),
+			"accepted", state.Accepted)
+	}
+	node.partition.Store(state)
+	return state
+}
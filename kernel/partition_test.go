@@ -0,0 +1,69 @@
+package kernel
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/p2p"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPartition(t *testing.T) {
+	require := require.New(t)
+
+	start := time.Unix(0, 1700000000000000000)
+	epoch := uint64(start.UnixNano()) - uint64(time.Hour)
+	node := &Node{SyncPoints: newSyncMap(), startAt: start}
+	var ids []crypto.Hash
+	for i := range 7 {
+		id := crypto.Blake3Hash([]byte(fmt.Sprintf("partition-%d", i)))
+		ids = append(ids, id)
+		node.allNodesSortedWithState = append(node.allNodesSortedWithState,
+			&CNode{IdForNetwork: id, Timestamp: epoch, State: common.NodeStateAccepted})
+	}
+	node.IdForNetwork = ids[0]
+	node.acceptedNodeStateSequences = node.buildNodeStateSequences(node.allNodesSortedWithState, true)
+	update := func(at time.Time, peers ...crypto.Hash) {
+		for _, id := range peers {
+			node.SyncPoints.Set(id, &p2p.SyncPoint{NodeId: node.IdForNetwork})
+			node.SyncPoints.times[id] = at
+		}
+	}
+
+	period := time.Duration(config.SnapshotRoundGap) * partitionStaleRounds
+	state := node.checkPartition(start.Add(period / 2))
+	require.False(state.Partitioned)
+	require.Equal(7, state.Accepted)
+	require.Len(state.Stale, 0)
+
+	now := start.Add(period * 2)
+	update(now, ids[3:]...)
+	state = node.checkPartition(now)
+	require.False(state.Partitioned)
+	require.Len(state.Stale, 2)
+	require.Equal(uint64(0), state.Since)
+
+	update(start, ids[3])
+	state = node.checkPartition(now)
+	require.False(state.Partitioned)
+	require.Len(state.Stale, 3)
+	require.Equal(uint64(now.UnixNano()), state.Since)
+	require.Equal(state, node.PartitionState())
+
+	now = now.Add(period)
+	update(now, ids[4:]...)
+	state = node.checkPartition(now)
+	require.True(state.Partitioned)
+	require.Equal(uint64(now.Add(-period).UnixNano()), state.Since)
+	require.ElementsMatch(ids[1:4], state.Stale)
+
+	update(now, ids[1])
+	state = node.checkPartition(now.Add(time.Second))
+	require.False(state.Partitioned)
+	require.Equal(uint64(0), state.Since)
+	require.ElementsMatch(ids[2:4], state.Stale)
+}
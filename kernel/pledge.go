@@ -0,0 +1,102 @@
+package kernel
+
+import (
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+const (
+	PledgePhaseAvailable = "available"
+	PledgePhaseWaiting   = "waiting"
+	PledgePhasePledging  = "pledging"
+	PledgePhaseAccepting = "accepting"
+	PledgePhaseExpired   = "expired"
+	PledgePhaseAccepted  = "accepted"
+	PledgePhaseReady     = "ready"
+	PledgePhaseCancelled = "cancelled"
+	PledgePhaseRemoved   = "removed"
+)
+
+// PledgeStatus is the progress of a kernel node through the pledge and
+// accept lifecycle. Without any node of the signer, the phase tells whether
+// a new pledge is possible, otherwise it is the state of the latest node of
+// the signer, and the pledging phase is split by the accept window, in which
+// the pledging node sends the accept transaction by itself in the accept
+// hours. A pledge past the window could be neither accepted nor cancelled.
+type PledgeStatus struct {
+	Timestamp uint64
+	Phase     string
+	Node      *CNode
+	Slot      *PledgingSlot
+	// the next timestamp the phase may change by time, 0 if only changed
+	// by transactions
+	NextAt uint64
+}
+
+func (node *Node) PledgeStatusAt(signer crypto.Key, timestamp uint64) *PledgeStatus {
+	state := node.ConsensusNodesAt(timestamp)
+	ps := &PledgeStatus{Timestamp: timestamp, Slot: state.Pledging}
+	var cn *ConsensusNodeState
+	for _, n := range state.Nodes {
+		if n.Signer.PublicSpendKey != signer {
+			continue
+		}
+		if cn == nil || n.Timestamp >= cn.Timestamp {
+			cn = n
+		}
+	}
+
+	slot := state.Pledging
+	if cn == nil {
+		ps.Phase = PledgePhaseWaiting
+		if slot.Available {
+			ps.Phase = PledgePhaseAvailable
+		} else if slot.Node == nil && slot.NextPledgeAt > timestamp {
+			ps.NextAt = slot.NextPledgeAt
+		}
+		return ps
+	}
+
+	ps.Node = cn.CNode
+	switch cn.State {
+	case common.NodeStatePledging:
+		ps.Phase = PledgePhasePledging
+		ps.NextAt = slot.AcceptAfter
+		if timestamp >= slot.AcceptAfter {
+			ps.Phase = PledgePhaseAccepting
+			ps.NextAt = slot.AcceptBefore + 1
+		}
+		if timestamp > slot.AcceptBefore {
+			ps.Phase = PledgePhaseExpired
+			ps.NextAt = 0
+		}
+	case common.NodeStateAccepted:
+		ps.Phase = PledgePhaseReady
+		if !cn.Ready {
+			ps.Phase = PledgePhaseAccepted
+			ps.NextAt = cn.ReadyAt + 1
+		}
+	case common.NodeStateCancelled:
+		ps.Phase = PledgePhaseCancelled
+	case common.NodeStateRemoved:
+		ps.Phase = PledgePhaseRemoved
+	}
+	return ps
+}
+
+// AcceptHourAt returns the start of the next accept hours after the
+// timestamp, or the timestamp itself if already in the accept hours.
+func (node *Node) AcceptHourAt(timestamp uint64) uint64 {
+	if timestamp < node.Epoch || node.checkConsensusAcceptHour(timestamp) {
+		return timestamp
+	}
+	day := (timestamp - node.Epoch) / OneDay
+	begin := node.Epoch + day*OneDay + uint64(config.KernelNodeAcceptTimeBegin)*uint64(time.Hour)
+	if begin < timestamp {
+		begin += OneDay
+	}
+	return begin
+}
@@ -0,0 +1,113 @@
+package kernel
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPledgeStatus(t *testing.T) {
+	require := require.New(t)
+
+	epoch := uint64(1551312000000000000)
+	node := &Node{Epoch: epoch, genesisNodesMap: make(map[crypto.Hash]bool)}
+	var cnodes []*CNode
+	for i := range 7 {
+		id := crypto.Blake3Hash([]byte(fmt.Sprintf("genesis-%d", i)))
+		node.genesisNodesMap[id] = true
+		cnodes = append(cnodes, &CNode{IdForNetwork: id, Timestamp: epoch, State: common.NodeStateAccepted})
+	}
+	signer := testPledgeSigner("pledge")
+	pledged := epoch + uint64(time.Hour*24)
+	pn := &CNode{
+		IdForNetwork: crypto.Blake3Hash([]byte("pledging")),
+		Signer:       signer,
+		Timestamp:    pledged,
+		State:        common.NodeStatePledging,
+	}
+	setNodes := func(cnodes []*CNode) {
+		node.allNodesSortedWithState = cnodes
+		node.nodeStateSequences = node.buildNodeStateSequences(cnodes, false)
+		node.acceptedNodeStateSequences = node.buildNodeStateSequences(cnodes, true)
+	}
+	setNodes(append(cnodes, pn))
+
+	ps := node.PledgeStatusAt(signer.PublicSpendKey, pledged-1)
+	require.Equal(PledgePhaseAvailable, ps.Phase)
+	require.Nil(ps.Node)
+	require.Equal(uint64(0), ps.NextAt)
+
+	other := testPledgeSigner("other")
+	ps = node.PledgeStatusAt(other.PublicSpendKey, pledged+1)
+	require.Equal(PledgePhaseWaiting, ps.Phase)
+	require.Nil(ps.Node)
+	require.Equal(pn.IdForNetwork, ps.Slot.Node.IdForNetwork)
+	require.Equal(uint64(0), ps.NextAt)
+
+	ps = node.PledgeStatusAt(signer.PublicSpendKey, pledged+1)
+	require.Equal(PledgePhasePledging, ps.Phase)
+	require.Equal(pn.IdForNetwork, ps.Node.IdForNetwork)
+	acceptAfter := pledged + uint64(config.KernelNodeAcceptPeriodMinimum)
+	acceptBefore := pledged + uint64(config.KernelNodeAcceptPeriodMaximum)
+	require.Equal(acceptAfter, ps.NextAt)
+
+	ps = node.PledgeStatusAt(signer.PublicSpendKey, acceptAfter)
+	require.Equal(PledgePhaseAccepting, ps.Phase)
+	require.Equal(acceptBefore+1, ps.NextAt)
+	ps = node.PledgeStatusAt(signer.PublicSpendKey, acceptBefore+1)
+	require.Equal(PledgePhaseExpired, ps.Phase)
+	require.Equal(uint64(0), ps.NextAt)
+
+	accepted := acceptAfter + uint64(time.Hour)
+	an := &CNode{
+		IdForNetwork: pn.IdForNetwork,
+		Signer:       signer,
+		Timestamp:    accepted,
+		State:        common.NodeStateAccepted,
+	}
+	setNodes(append(cnodes, an))
+	ps = node.PledgeStatusAt(signer.PublicSpendKey, accepted+1)
+	require.Equal(PledgePhaseAccepted, ps.Phase)
+	readyAt := accepted + uint64(config.KernelNodeAcceptPeriodMinimum)
+	require.Equal(readyAt+1, ps.NextAt)
+	ps = node.PledgeStatusAt(signer.PublicSpendKey, readyAt+1)
+	require.Equal(PledgePhaseReady, ps.Phase)
+	require.Equal(uint64(0), ps.NextAt)
+
+	ps = node.PledgeStatusAt(other.PublicSpendKey, accepted+1)
+	require.Equal(PledgePhaseWaiting, ps.Phase)
+	require.Equal(accepted+uint64(config.KernelNodePledgePeriodMinimum), ps.NextAt)
+
+	cancelled := &CNode{
+		IdForNetwork: pn.IdForNetwork,
+		Signer:       signer,
+		Timestamp:    accepted,
+		State:        common.NodeStateCancelled,
+	}
+	setNodes(append(cnodes, cancelled))
+	ps = node.PledgeStatusAt(signer.PublicSpendKey, accepted+1)
+	require.Equal(PledgePhaseCancelled, ps.Phase)
+
+	hour := uint64(time.Hour)
+	begin := epoch + uint64(config.KernelNodeAcceptTimeBegin)*hour
+	require.Equal(begin, node.AcceptHourAt(epoch))
+	require.Equal(begin+hour, node.AcceptHourAt(begin+hour))
+	end := epoch + uint64(config.KernelNodeAcceptTimeEnd+1)*hour
+	require.Equal(begin+OneDay, node.AcceptHourAt(end))
+}
+
+func testPledgeSigner(seed string) common.Address {
+	h := crypto.Blake3Hash([]byte(seed))
+	key := crypto.NewKeyFromSeed(append(h[:], h[:]...))
+	return common.Address{
+		PrivateSpendKey: key,
+		PublicSpendKey:  key.Public(),
+		PrivateViewKey:  key.Public().DeterministicHashDerive(),
+		PublicViewKey:   key.Public().DeterministicHashDerive().Public(),
+	}
+}
@@ -0,0 +1,182 @@
+package kernel
+
+import (
+	"runtime"
+	"sync"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+)
+
+const (
+	PrevalidationQueueSize = 8192
+	PrevalidationBatchSize = 64
+)
+
+type prevalidationJob struct {
+	PeerId   crypto.Hash
+	Snapshot *common.Snapshot
+
+	valid bool
+}
+
+// the finalization messages are validated in two stages, the stateless
+// checks including format and cosi signature verification are executed
+// by a worker pool as soon as the messages arrive, then the snapshots
+// passed are queued to the chain in the arrival order by this loop only,
+// and the chain does the serialized stateful finalization. the cosi
+// verification results are cached, so the second verification in the
+// finalization stage is cheap. during the catch-up sync, all queued jobs
+// up to a batch have their cosi signatures verified in one batch to warm
+// the cache, which is much faster than one by one.
+func (node *Node) loopPrevalidateSnapshots() {
+	defer close(node.pvc)
+
+	for {
+		select {
+		case <-node.done:
+			return
+		case job := <-node.prevalidations:
+			jobs := node.drainPrevalidationJobs(job)
+			if len(jobs) > 1 {
+				node.batchVerifyFinalizations(jobs)
+			}
+			node.prevalidateFinalizations(jobs)
+			for _, job := range jobs {
+				if job.valid {
+					node.queueFinalization(job.PeerId, job.Snapshot)
+				}
+			}
+		}
+	}
+}
+
+func (node *Node) prevalidateFinalizations(jobs []*prevalidationJob) {
+	queue := make(chan *prevalidationJob)
+	var wg sync.WaitGroup
+	for i := 0; i < min(runtime.NumCPU(), len(jobs)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range queue {
+				job.valid = node.prevalidateFinalization(job.PeerId, job.Snapshot)
+			}
+		}()
+	}
+	for _, job := range jobs {
+		queue <- job
+	}
+	close(queue)
+	wg.Wait()
+}
+
+func (node *Node) drainPrevalidationJobs(job *prevalidationJob) []*prevalidationJob {
+	jobs := []*prevalidationJob{job}
+	for len(jobs) < PrevalidationBatchSize {
+		select {
+		case job := <-node.prevalidations:
+			jobs = append(jobs, job)
+		default:
+			return jobs
+		}
+	}
+	return jobs
+}
+
+// only the cache is updated when the whole batch is valid, otherwise
+// the snapshots are verified one by one as usual. the batch equation is
+// cofactored, so BatchAdd refuses the signatures with torsion, which are
+// then verified by FullVerify, and the batch never caches a signature as
+// valid if FullVerify would refuse it.
+func (node *Node) batchVerifyFinalizations(jobs []*prevalidationJob) {
+	type verification struct {
+		key  []byte
+		sig  *crypto.CosiSignature
+		cids []crypto.Hash
+	}
+	var verifications []*verification
+	verifier := crypto.NewBatchVerifier()
+	for _, job := range jobs {
+		s := job.Snapshot
+		if s.Version != common.SnapshotVersionCommonEncoding || s.Signature == nil {
+			continue
+		}
+		if s.Timestamp < node.Epoch {
+			continue
+		}
+		chain := node.getOrCreateChain(s.NodeId)
+		timestamp, err := chain.finalizationTimestamp(s)
+		if err != nil {
+			continue
+		}
+		cids, publics := chain.ConsensusKeys(s.RoundNumber, timestamp)
+		base := node.ConsensusThreshold(timestamp, true)
+		key := cosiVerificationCacheKey(s.Hash, s.Signature, publics, base)
+		if _, found := node.cacheStore.Get(key); found {
+			continue
+		}
+		if s.Signature.BatchAdd(&verifier, publics, base, s.Hash) != nil {
+			continue
+		}
+		verifications = append(verifications, &verification{key, s.Signature, cids})
+	}
+	if verifier.Len() < 2 || !verifier.Verify() {
+		return
+	}
+	for _, v := range verifications {
+		node.cacheCosiSigners(v.key, v.sig, v.cids)
+	}
+}
+
+// the sender is blocked when the queue is full, so the peer messages are
+// not read until the prevalidation catches up, instead of being dropped.
+func (node *Node) queueSnapshotPrevalidation(peerId crypto.Hash, s *common.Snapshot) {
+	job := &prevalidationJob{PeerId: peerId, Snapshot: s}
+	select {
+	case node.prevalidations <- job:
+	case <-node.done:
+	}
+}
+
+func (node *Node) prevalidateFinalization(peerId crypto.Hash, s *common.Snapshot) bool {
+	if s.Version != common.SnapshotVersionCommonEncoding || s.Signature == nil {
+		logger.Verbosef("prevalidateFinalization(%s, %s) malformed %d\n", peerId, s.Hash, s.Version)
+		return false
+	}
+	if len(s.Transactions) != 1 {
+		logger.Verbosef("prevalidateFinalization(%s, %s) malformed transactions %d\n",
+			peerId, s.Hash, len(s.Transactions))
+		return false
+	}
+
+	tx, finalized, err := node.checkTxInStorage(s.SoleTransaction())
+	if err != nil {
+		logger.Verbosef("prevalidateFinalization(%s, %s) check tx error %s\n", peerId, s.Hash, err)
+	} else if tx == nil {
+		err = node.Peer.SendTransactionRequestMessage(peerId, s.SoleTransaction())
+		logger.Verbosef("prevalidateFinalization(%s, %s) SendTransactionRequestMessage %s %v\n",
+			peerId, s.Hash, s.SoleTransaction(), err)
+	} else if finalized == s.Hash.String() {
+		return false
+	}
+
+	chain := node.getOrCreateChain(s.NodeId)
+	if cs := chain.State; cs != nil && cs.CacheRound.index[s.Hash] {
+		return false
+	}
+	if _, finalized := chain.verifyFinalization(s); !finalized {
+		logger.Verbosef("ERROR prevalidateFinalization %s %v %d %t\n",
+			peerId, s, node.ConsensusThreshold(s.Timestamp, true), chain.IsPledging())
+		return false
+	}
+	return true
+}
+
+func (node *Node) queueFinalization(peerId crypto.Hash, s *common.Snapshot) {
+	chain := node.getOrCreateChain(s.NodeId)
+	err := chain.AppendFinalSnapshot(s.NodeId, s)
+	if err != nil {
+		logger.Verbosef("queueFinalization(%s, %s) chain error %s\n", peerId, s.Hash, err)
+	}
+}
@@ -29,14 +29,34 @@ func (node *Node) QueueTransaction(tx *common.VersionedTransaction) (string, err
 		return old.PayloadHash().String(), node.persistStore.CachePutTransaction(tx)
 	}
 
-	err = tx.Validate(node.persistStore, uint64(clock.Now().UnixNano()), false)
+	parents, err := node.pendingParents(tx)
 	if err != nil {
 		return "", err
 	}
+	store := newPendingStore(node.persistStore, parents)
+	now := uint64(clock.Now().UnixNano())
+	err = tx.Validate(store, now, false, node.consensusForks(now))
+	if err != nil {
+		return "", err
+	}
+	err = node.validateDepositProof(tx)
+	if err != nil {
+		return "", err
+	}
+	if node.custom.Node.ExtraSchemaCheck {
+		err = common.ValidateExtraSchema(tx)
+		if err != nil {
+			return "", err
+		}
+	}
 	err = node.persistStore.CachePutTransaction(tx)
 	if err != nil {
 		return "", err
 	}
+	if len(parents) > 0 {
+		// held in the cache until the parents finalize
+		return tx.PayloadHash().String(), nil
+	}
 	s := &common.Snapshot{
 		Version: common.SnapshotVersionCommonEncoding,
 		NodeId:  node.IdForNetwork,
@@ -46,10 +66,19 @@ func (node *Node) QueueTransaction(tx *common.VersionedTransaction) (string, err
 	return tx.PayloadHash().String(), err
 }
 
+// the cache transactions are counted at most once in the interval, because
+// all the payload keys of the cache store are iterated
+const cacheStatsInterval = time.Minute
+
 func (node *Node) loopCacheQueue() {
 	defer close(node.cqc)
 
+	var statsAt time.Time
 	for !node.waitOrDone(time.Duration(config.SnapshotRoundGap)) {
+		if now := clock.Now(); now.Sub(statsAt) >= cacheStatsInterval {
+			statsAt = now
+			node.observeCacheTransactions()
+		}
 		caches, finals, _ := node.QueueState()
 		if caches > 1000 || finals > 500 {
 			logger.Printf("LoopCacheQueue QueueState too big %d %d\n", caches, finals)
@@ -85,8 +114,13 @@ func (node *Node) loopCacheQueue() {
 				stale = append(stale, hash)
 				continue
 			}
+			parents, err := node.pendingParents(tx)
+			if err != nil || len(parents) > 0 {
+				logger.Debugf("LoopCacheQueue pendingParents %s %d %v\n", hash, len(parents), err)
+				continue
+			}
 			now := clock.Now()
-			err = tx.Validate(node.persistStore, uint64(now.UnixNano()), false)
+			err = tx.Validate(node.persistStore, uint64(now.UnixNano()), false, node.consensusForks(uint64(now.UnixNano())))
 			if err != nil {
 				logger.Debugf("LoopCacheQueue Validate ERROR %s %s\n", hash, err)
 				// FIXME not mark invalid tx as stale is to ensure final graph sync
@@ -94,7 +128,11 @@ func (node *Node) loopCacheQueue() {
 				continue
 			}
 
-			nbor := node.electSnapshotNode(tx.TransactionType(), uint64(now.UnixNano()))
+			nbor, err := node.electSnapshotNode(tx.TransactionType(), uint64(now.UnixNano()))
+			if err != nil {
+				logger.Debugf("LoopCacheQueue electSnapshotNode ERROR %s %s\n", hash, err)
+				continue
+			}
 			if nbor.HasValue() {
 				node.sendTransactionToNode(hash, nbor)
 			} else {
@@ -107,10 +145,40 @@ func (node *Node) loopCacheQueue() {
 		err = node.persistStore.CacheRemoveTransactions(stale)
 		if err != nil {
 			logger.Printf("LoopCacheQueue CacheRemoveTransactions ERROR %s\n", err)
+		} else {
+			cacheTransactionsRemovedCounter.Add(float64(len(stale)), "finalized")
 		}
 	}
 }
 
+func (node *Node) observeCacheTransactions() {
+	count, size, err := node.persistStore.CacheTransactionStats()
+	if err != nil {
+		logger.Printf("LoopCacheQueue CacheTransactionStats ERROR %s\n", err)
+		return
+	}
+	cacheTransactionsGauge.Set("", float64(count))
+	cacheTransactionBytesGauge.Set("", float64(size))
+}
+
+// PurgeCacheTransactions removes the cache transactions first received more
+// than the age ago, if not 0, and with the hash of the hex prefix, if not
+// empty, e.g. the transactions of a spam, before they are expired.
+func (node *Node) PurgeCacheTransactions(age time.Duration, prefix string) ([]crypto.Hash, error) {
+	var before uint64
+	if age > 0 {
+		// the expiry of the cache store is of the wall clock
+		before = uint64(time.Now().Add(-age).UnixNano())
+	}
+	hashes, err := node.persistStore.CachePurgeTransactions(before, prefix)
+	if err != nil {
+		return nil, err
+	}
+	cacheTransactionsRemovedCounter.Add(float64(len(hashes)), "purged")
+	node.observeCacheTransactions()
+	return hashes, nil
+}
+
 func (node *Node) sendTransactionToNode(hash, nbor crypto.Hash) {
 	if nbor != node.IdForNetwork {
 		err := node.SendTransactionToPeer(nbor, hash)
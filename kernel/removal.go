@@ -0,0 +1,93 @@
+package kernel
+
+import (
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+const (
+	RemovalPhaseUnavailable = "unavailable"
+	RemovalPhaseQueued      = "queued"
+	RemovalPhaseNext        = "next"
+	RemovalPhaseRemoved     = "removed"
+	RemovalPhaseRefunded    = "refunded"
+)
+
+// NodeRemoval is the progress of a kernel node towards the removal. A node
+// could not resign at any time by itself, the earliest accepted node is
+// removed by the elected node automatically, when no node is pledging and
+// the pledge period passed since the last node state change, in the accept
+// hours, and the pledge is refunded to the payee by the removal transaction
+// output. So a node leaves gracefully by running until it is removed as the
+// first of the queue, and the payee spends the refund after that.
+type NodeRemoval struct {
+	Timestamp uint64
+	Phase     string
+	Node      *CNode
+	// the count of the accepted nodes to be removed before the node
+	Position int
+	// the earliest timestamp to remove the next node, and the reason why the
+	// removal is not possible at the timestamp
+	RemoveAt uint64
+	Blocked  string
+	// the removal transaction, which is built for preview if not removed yet,
+	// and the refund output after removed
+	Transaction *common.VersionedTransaction
+	Finalized   bool
+	Refund      *common.UTXOWithLock
+}
+
+func (node *Node) NodeRemovalAt(signer crypto.Key, timestamp uint64) (*NodeRemoval, error) {
+	nr := &NodeRemoval{Timestamp: timestamp, Phase: RemovalPhaseUnavailable}
+	var last uint64
+	var accepted int
+	for _, cn := range node.NodesListWithoutState(timestamp, false) {
+		last = max(last, cn.Timestamp)
+		if cn.Signer.PublicSpendKey == signer {
+			nr.Node = cn
+			nr.Position = accepted
+		}
+		if cn.State == common.NodeStateAccepted {
+			accepted += 1
+		}
+	}
+	if nr.Node == nil {
+		return nr, nil
+	}
+
+	switch nr.Node.State {
+	case common.NodeStateAccepted:
+		nr.Phase = RemovalPhaseQueued
+		if nr.Position > 0 {
+			return nr, nil
+		}
+		nr.Phase = RemovalPhaseNext
+		nr.RemoveAt = node.AcceptHourAt(max(timestamp, last+uint64(config.KernelNodePledgePeriodMinimum)))
+		_, err := node.checkRemovePossibility(crypto.Hash{}, timestamp, nil)
+		if err != nil {
+			nr.Blocked = err.Error()
+		}
+		tx, err := node.buildNodeRemoveTransactionFor(nr.Node)
+		if err != nil {
+			return nil, err
+		}
+		nr.Transaction = tx
+	case common.NodeStateRemoved:
+		nr.Phase = RemovalPhaseRemoved
+		tx, snap, err := node.persistStore.ReadTransaction(nr.Node.Transaction)
+		if err != nil {
+			return nil, err
+		}
+		nr.Transaction, nr.Finalized = tx, snap != ""
+		refund, err := node.persistStore.ReadUTXOLock(nr.Node.Transaction, 0)
+		if err != nil {
+			return nil, err
+		}
+		nr.Refund = refund
+		if refund != nil && refund.LockHash.HasValue() {
+			nr.Phase = RemovalPhaseRefunded
+		}
+	}
+	return nr, nil
+}
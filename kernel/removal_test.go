@@ -0,0 +1,66 @@
+package kernel
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNodeRemoval(t *testing.T) {
+	require := require.New(t)
+
+	root, err := os.MkdirTemp("", "mixin-removal-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+
+	now, err := time.Parse(time.RFC3339, "2021-03-10T17:00:00Z")
+	require.Nil(err)
+	ts := uint64(now.UnixNano())
+	var accepted []*CNode
+	for _, cn := range node.NodesListWithoutState(ts, false) {
+		if cn.State == common.NodeStateAccepted {
+			accepted = append(accepted, cn)
+		}
+	}
+	require.Greater(len(accepted), 1)
+
+	nr, err := node.NodeRemovalAt(accepted[0].Signer.PublicSpendKey, ts)
+	require.Nil(err)
+	require.Equal(RemovalPhaseNext, nr.Phase)
+	require.Equal("009234939f0f8f9495f611c713ec61358262ecf6ec742671addfcce5350c1d23", nr.Node.IdForNetwork.String())
+	require.Equal(0, nr.Position)
+	require.Equal("", nr.Blocked)
+	require.Equal(ts, nr.RemoveAt)
+	require.Equal("c183ce86b5de6c35395371eebf9dbe7a27f06fa3bc5f8aae16a8e833bced422b", nr.Transaction.PayloadHash().String())
+	require.False(nr.Finalized)
+	require.Nil(nr.Refund)
+
+	nr, err = node.NodeRemovalAt(accepted[1].Signer.PublicSpendKey, ts)
+	require.Nil(err)
+	require.Equal(RemovalPhaseQueued, nr.Phase)
+	require.Equal(accepted[1].IdForNetwork, nr.Node.IdForNetwork)
+	require.Equal(1, nr.Position)
+	require.Nil(nr.Transaction)
+
+	nr, err = node.NodeRemovalAt(crypto.Key{}, ts)
+	require.Nil(err)
+	require.Equal(RemovalPhaseUnavailable, nr.Phase)
+	require.Nil(nr.Node)
+
+	now, err = time.Parse(time.RFC3339, "2021-03-10T08:00:00Z")
+	require.Nil(err)
+	ts = uint64(now.UnixNano())
+	nr, err = node.NodeRemovalAt(accepted[0].Signer.PublicSpendKey, ts)
+	require.Nil(err)
+	require.Equal(RemovalPhaseNext, nr.Phase)
+	require.Contains(nr.Blocked, "invalid node remove hour")
+	require.Equal(node.AcceptHourAt(ts), nr.RemoveAt)
+	require.Greater(nr.RemoveAt, ts)
+}
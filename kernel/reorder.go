@@ -0,0 +1,230 @@
+package kernel
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/kernel/internal/clock"
+	"github.com/MixinNetwork/mixin/logger"
+)
+
+// the final snapshots too far ahead of the final pool are held in the reorder
+// buffer instead of dropped, and moved to the pool when the cache round moves
+// close enough. the rounds referenced but missing are requested from the peer
+// sent the snapshot, so the chain doesn't wait for the peers to push them
+// again. the buffer is bounded, and the snapshots of the highest round are
+// evicted first, because they are needed last.
+const (
+	ReorderBufferSnapshotsLimit = 4096
+	reorderRequestInterval      = 3 * time.Second
+)
+
+type reorderBuffer struct {
+	sync.Mutex
+	rounds    map[uint64][]*CosiAction
+	size      int
+	requested map[crypto.Hash]time.Time
+}
+
+func newReorderBuffer() *reorderBuffer {
+	return &reorderBuffer{
+		rounds:    make(map[uint64][]*CosiAction),
+		requested: make(map[crypto.Hash]time.Time),
+	}
+}
+
+func (rb *reorderBuffer) Len() int {
+	rb.Lock()
+	defer rb.Unlock()
+	return rb.size
+}
+
+// hold returns false if the buffer is full of the snapshots of lower rounds.
+func (rb *reorderBuffer) hold(m *CosiAction) bool {
+	rb.Lock()
+	defer rb.Unlock()
+
+	number := m.Snapshot.RoundNumber
+	for _, o := range rb.rounds[number] {
+		if o.Snapshot.Hash == m.Snapshot.Hash {
+			return true
+		}
+	}
+	if rb.size >= ReorderBufferSnapshotsLimit {
+		var highest uint64
+		for n := range rb.rounds {
+			highest = max(highest, n)
+		}
+		if highest <= number {
+			return false
+		}
+		evicted := rb.rounds[highest]
+		if len(evicted) == 1 {
+			delete(rb.rounds, highest)
+		} else {
+			rb.rounds[highest] = evicted[:len(evicted)-1]
+		}
+		rb.size -= 1
+	}
+	rb.rounds[number] = append(rb.rounds[number], m)
+	rb.size += 1
+	return true
+}
+
+// release returns the snapshots of the rounds before the limit, and drops the
+// expired ones before the start.
+func (rb *reorderBuffer) release(start, limit uint64) []*CosiAction {
+	rb.Lock()
+	defer rb.Unlock()
+
+	var released []*CosiAction
+	for n, actions := range rb.rounds {
+		if n >= limit {
+			continue
+		}
+		if n >= start {
+			released = append(released, actions...)
+		}
+		rb.size -= len(actions)
+		delete(rb.rounds, n)
+	}
+	return released
+}
+
+// shed drops the snapshots of all rounds except the lowest one, which is still
+// pulled back to the pool window, and the others are sent again by the peers.
+func (rb *reorderBuffer) shed() int {
+	rb.Lock()
+	defer rb.Unlock()
+
+	lowest := uint64(math.MaxUint64)
+	for n := range rb.rounds {
+		lowest = min(lowest, n)
+	}
+	shed := 0
+	for n, actions := range rb.rounds {
+		if n != lowest {
+			shed += len(actions)
+			delete(rb.rounds, n)
+		}
+	}
+	rb.size -= shed
+	clear(rb.requested)
+	return shed
+}
+
+// all returns all the held snapshots without releasing them.
+func (rb *reorderBuffer) all() []*CosiAction {
+	rb.Lock()
+	defer rb.Unlock()
+	var held []*CosiAction
+	for _, ms := range rb.rounds {
+		held = append(held, ms...)
+	}
+	return held
+}
+
+func (rb *reorderBuffer) holding(number uint64) bool {
+	rb.Lock()
+	defer rb.Unlock()
+	return len(rb.rounds[number]) > 0
+}
+
+// lowest returns the snapshots of the lowest round held.
+func (rb *reorderBuffer) lowest() []*CosiAction {
+	rb.Lock()
+	defer rb.Unlock()
+
+	var lowest []*CosiAction
+	for n, actions := range rb.rounds {
+		if len(lowest) == 0 || n < lowest[0].Snapshot.RoundNumber {
+			lowest = actions
+		}
+	}
+	return lowest
+}
+
+func (rb *reorderBuffer) shouldRequest(hash crypto.Hash, now time.Time) bool {
+	rb.Lock()
+	defer rb.Unlock()
+
+	if at, found := rb.requested[hash]; found && now.Sub(at) < reorderRequestInterval {
+		return false
+	}
+	if len(rb.requested) >= ReorderBufferSnapshotsLimit {
+		for h, at := range rb.requested {
+			if now.Sub(at) >= reorderRequestInterval {
+				delete(rb.requested, h)
+			}
+		}
+	}
+	rb.requested[hash] = now
+	return true
+}
+
+func (chain *Chain) holdFinalSnapshot(peerId crypto.Hash, s *common.Snapshot) {
+	if !chain.reorder.hold(&CosiAction{PeerId: peerId, Snapshot: s}) {
+		chain.markRejected(RejectQueueFull)
+		logger.Verbosef("holdFinalSnapshot(%s, %s) reorder buffer full %d\n", peerId, s.Hash, s.RoundNumber)
+	}
+}
+
+// releaseReorderBuffer moves the snapshots in the final pool window to the
+// pool, and requests the round before the lowest one held, if it's neither
+// in the pool nor in the buffer, then the rounds are pulled back one by one
+// until the pool window.
+func (chain *Chain) releaseReorderBuffer() {
+	var start uint64
+	if chain.State != nil {
+		start = chain.State.CacheRound.Number
+	}
+	for _, m := range chain.reorder.release(start, start+FinalPoolSlotsLimit) {
+		retry, err := chain.appendFinalSnapshot(m.PeerId, m.Snapshot)
+		if err != nil {
+			panic(err)
+		} else if retry {
+			chain.reorder.hold(m)
+		}
+	}
+
+	lowest := chain.reorder.lowest()
+	if len(lowest) == 0 {
+		return
+	}
+	s := lowest[0].Snapshot
+	if s.References == nil || chain.reorder.holding(s.RoundNumber-1) || chain.pooling(start, s.RoundNumber-1) {
+		return
+	}
+	chain.requestMissingReferences(lowest[0].PeerId, s.References.Self)
+}
+
+func (chain *Chain) pooling(start, number uint64) bool {
+	if number < start || number-start >= FinalPoolSlotsLimit {
+		return false
+	}
+	offset := (int(number-start) + chain.FinalIndex) % FinalPoolSlotsLimit
+	round := chain.FinalPool[offset]
+	return round != nil && round.Number == number && round.Size > 0
+}
+
+// requestMissingReferences requests the rounds not finalized yet from the
+// peer, and each round is requested once in the interval.
+func (chain *Chain) requestMissingReferences(peerId crypto.Hash, references ...crypto.Hash) {
+	if peerId == chain.node.IdForNetwork {
+		return
+	}
+	for _, h := range references {
+		round, err := chain.persistStore.ReadRound(h)
+		if err != nil || round != nil {
+			continue
+		}
+		if !chain.reorder.shouldRequest(h, clock.Now()) {
+			continue
+		}
+		err = chain.node.Peer.SendRoundRequestMessage(peerId, h)
+		logger.Verbosef("requestMissingReferences(%s, %s) => %v\n", peerId, h, err)
+	}
+}
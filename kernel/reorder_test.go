@@ -0,0 +1,70 @@
+package kernel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReorderBuffer(t *testing.T) {
+	require := require.New(t)
+
+	action := func(number uint64, i int) *CosiAction {
+		s := &common.Snapshot{RoundNumber: number}
+		s.Hash = crypto.Blake3Hash([]byte{byte(number >> 8), byte(number), byte(i >> 8), byte(i)})
+		return &CosiAction{Snapshot: s}
+	}
+
+	rb := newReorderBuffer()
+	require.Len(rb.lowest(), 0)
+	require.True(rb.hold(action(900, 0)))
+	require.True(rb.hold(action(900, 0)))
+	require.True(rb.hold(action(900, 1)))
+	require.True(rb.hold(action(950, 0)))
+	require.Equal(3, rb.Len())
+	require.True(rb.holding(900))
+	require.False(rb.holding(899))
+	require.Len(rb.lowest(), 2)
+	require.Equal(uint64(900), rb.lowest()[0].Snapshot.RoundNumber)
+
+	for i := 3; i < ReorderBufferSnapshotsLimit; i++ {
+		require.True(rb.hold(action(1000, i)))
+	}
+	require.Equal(ReorderBufferSnapshotsLimit, rb.Len())
+	require.False(rb.hold(action(1000, 0)))
+	require.False(rb.hold(action(1001, 0)))
+	require.True(rb.hold(action(901, 0)))
+	require.Equal(ReorderBufferSnapshotsLimit, rb.Len())
+	require.Len(rb.rounds[1000], ReorderBufferSnapshotsLimit-4)
+
+	released := rb.release(900, 951)
+	require.Len(released, 4)
+	require.Equal(ReorderBufferSnapshotsLimit-4, rb.Len())
+	require.False(rb.holding(900))
+	require.Equal(uint64(1000), rb.lowest()[0].Snapshot.RoundNumber)
+	released = rb.release(1001, 1002)
+	require.Len(released, 0)
+	require.Equal(0, rb.Len())
+
+	require.Equal(0, rb.shed())
+	require.True(rb.hold(action(1100, 0)))
+	require.True(rb.hold(action(1100, 1)))
+	require.True(rb.hold(action(1200, 0)))
+	require.True(rb.hold(action(1300, 0)))
+	require.Equal(2, rb.shed())
+	require.Equal(2, rb.Len())
+	require.True(rb.holding(1100))
+	require.False(rb.holding(1200))
+	require.Equal(0, rb.shed())
+	rb.release(0, 1101)
+
+	hash := crypto.Blake3Hash([]byte("round"))
+	now := time.Unix(1700000000, 0)
+	require.True(rb.shouldRequest(hash, now))
+	require.False(rb.shouldRequest(hash, now.Add(reorderRequestInterval-time.Millisecond)))
+	require.True(rb.shouldRequest(hash, now.Add(reorderRequestInterval)))
+	require.True(rb.shouldRequest(crypto.Blake3Hash(hash[:]), now))
+}
@@ -0,0 +1,65 @@
+package kernel
+
+import (
+	"fmt"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/kernel/internal/clock"
+)
+
+// the replica loads the store of the primary without the kernel running, so
+// the chain loops never boot, and the state of the node loaded at the setup
+// is reloaded from the store after each pull instead.
+func (node *Node) replica() bool {
+	return node.custom.Replica.Primary != ""
+}
+
+// ReloadReplica reloads the consensus nodes, the last mint, the topology and
+// the state of all chains from the store written by the replication. The
+// replica is synced to the primary as of the last complete pull.
+func (node *Node) ReloadReplica() error {
+	if !node.replica() {
+		panic(fmt.Errorf("reload the state of a node not replica %s", node.IdForNetwork))
+	}
+	err := node.LoadConsensusNodes()
+	if err != nil {
+		return err
+	}
+	node.LastMint = node.lastMintDistribution().Batch
+
+	node.TopoCounter.Lock()
+	node.TopoCounter.seq = node.persistStore.TopologySequence()
+	node.TopoCounter.Unlock()
+
+	var timestamp uint64
+	now := clock.Now()
+	for _, cn := range node.NodesListWithoutState(uint64(now.UnixNano()), false) {
+		chain := node.getOrCreateChain(cn.IdForNetwork)
+		chain.Lock()
+		state, err := chain.readState()
+		if err == nil && state != nil {
+			chain.State = state
+		}
+		chain.Unlock()
+		if err != nil {
+			return err
+		}
+		if state != nil && state.FinalRound.End > timestamp {
+			timestamp = state.FinalRound.End
+		}
+	}
+	if timestamp > node.GraphTimestamp {
+		node.GraphTimestamp = timestamp
+	}
+
+	graph := node.BuildGraph()
+	finals := make(map[crypto.Hash]uint64, len(graph))
+	for _, p := range graph {
+		finals[p.NodeId] = p.Number
+	}
+	if node.replicaSync == nil {
+		node.replicaSync = &syncTracker{}
+	}
+	node.syncStatus.Store(node.replicaSync.update(now, graph, finals))
+	return nil
+}
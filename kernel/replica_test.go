@@ -0,0 +1,47 @@
+package kernel
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReloadReplica(t *testing.T) {
+	require := require.New(t)
+
+	root, err := os.MkdirTemp("", "mixin-replica-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	node := setupTestNode(require, root)
+	require.Panics(func() { node.ReloadReplica() })
+	node.custom.Replica.Primary = "https://127.0.0.1:8239"
+
+	timestamp, mint := node.GraphTimestamp, node.LastMint
+	graph := node.BuildGraph()
+	nodes := node.NodesListWithoutState(timestamp+1, false)
+	require.Greater(len(nodes), 0)
+	require.Greater(len(graph), 0)
+
+	node.GraphTimestamp, node.LastMint = 0, 0
+	node.nodeStateSequences = nil
+	node.acceptedNodeStateSequences = nil
+	for _, p := range graph {
+		chain := node.getChain(p.NodeId)
+		chain.State = nil
+	}
+	require.Len(node.NodesListWithoutState(timestamp+1, false), 0)
+	require.Len(node.BuildGraph(), 0)
+	require.False(node.SyncStatus().Synced)
+
+	err = node.ReloadReplica()
+	require.Nil(err)
+	require.Equal(timestamp, node.GraphTimestamp)
+	require.Equal(mint, node.LastMint)
+	require.Equal(nodes, node.NodesListWithoutState(timestamp+1, false))
+	require.ElementsMatch(graph, node.BuildGraph())
+	status := node.SyncStatus()
+	require.True(status.Synced)
+	require.Len(status.Chains, len(graph))
+}
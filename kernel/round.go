@@ -42,6 +42,9 @@ func (node *Node) LoadAllChainsAndGraphTimestamp(store storage.Store, networkId
 	}
 	logger.Printf("node.LoadAllChainsAndGraphTimestamp(%s) => %d %d", networkId, len(nodes), node.GraphTimestamp)
 
+	if node.replica() {
+		return nil
+	}
 	node.chains.RLock()
 	for _, chain := range node.chains.m {
 		chain.bootLoops()
@@ -186,6 +189,7 @@ func (c *CacheRound) Gap() (uint64, uint64) {
 }
 
 func (chain *Chain) AddSnapshot(final *FinalRound, cache *CacheRound, s *common.Snapshot, signers []crypto.Hash) error {
+	defer traceSnapshot("kernel.finalize", s.Hash, s, chain.ChainId).End()
 	chain.node.TopoWrite(s, signers)
 	err := cache.validateSnapshot(s, true)
 	if err != nil {
@@ -193,6 +197,7 @@ func (chain *Chain) AddSnapshot(final *FinalRound, cache *CacheRound, s *common.
 	}
 	chain.assignNewGraphRound(final, cache)
 	chain.State.CacheRound.index[s.Hash] = true
+	chain.markConsumed()
 	return nil
 }
 
@@ -206,18 +211,18 @@ func (c *CacheRound) validateSnapshot(s *common.Snapshot, add bool) error {
 	}
 	for _, cs := range c.Snapshots {
 		if cs.Hash == s.Hash || cs.Timestamp == s.Timestamp || cs.SoleTransaction() == s.SoleTransaction() {
-			return fmt.Errorf("ValidateSnapshot error duplication %s %d %s", s.Hash, s.Timestamp, s.SoleTransaction())
+			return common.Errorf(common.ErrorInvalidSnapshot, "ValidateSnapshot error duplication %s %d %s", s.Hash, s.Timestamp, s.SoleTransaction())
 		}
 		if cs.Timestamp/OneDay != s.Timestamp/OneDay {
-			return fmt.Errorf("ValidateSnapshot error round day leap %s %d %s", s.Hash, s.Timestamp, s.SoleTransaction())
+			return common.Errorf(common.ErrorInvalidTimestamp, "ValidateSnapshot error round day leap %s %d %s", s.Hash, s.Timestamp, s.SoleTransaction())
 		}
 	}
 	if start, end := c.Gap(); start <= end {
 		if s.Timestamp < start && s.Timestamp+config.SnapshotRoundGap <= end {
-			return fmt.Errorf("ValidateSnapshot error gap start %s %d %d %d", s.Hash, s.Timestamp, start, end)
+			return common.Errorf(common.ErrorInvalidTimestamp, "ValidateSnapshot error gap start %s %d %d %d", s.Hash, s.Timestamp, start, end)
 		}
 		if s.Timestamp > end && start+config.SnapshotRoundGap <= s.Timestamp {
-			return fmt.Errorf("ValidateSnapshot error gap end %s %d %d %d", s.Hash, s.Timestamp, start, end)
+			return common.Errorf(common.ErrorInvalidTimestamp, "ValidateSnapshot error gap end %s %d %d %d", s.Hash, s.Timestamp, start, end)
 		}
 	}
 	if add {
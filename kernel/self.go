@@ -16,16 +16,17 @@ import (
 func (node *Node) checkTxInStorage(id crypto.Hash) (*common.VersionedTransaction, string, error) {
 	tx, snap, err := node.persistStore.ReadTransaction(id)
 	if err != nil || tx != nil {
-		return tx, snap, err
+		return node.interner.intern(tx), snap, err
 	}
 
 	tx, err = node.persistStore.CacheGetTransaction(id)
-	return tx, "", err
+	return node.interner.intern(tx), "", err
 }
 
 func (node *Node) validateSnapshotTransaction(s *common.Snapshot, finalized bool) (*common.VersionedTransaction, bool, error) {
 	tx, snap, err := node.persistStore.ReadTransaction(s.SoleTransaction())
 	if err == nil && tx != nil {
+		tx = node.interner.intern(tx)
 		err = node.validateKernelSnapshot(s, tx, finalized)
 	}
 	if err != nil || tx != nil {
@@ -36,8 +37,9 @@ func (node *Node) validateSnapshotTransaction(s *common.Snapshot, finalized bool
 	if err != nil || tx == nil {
 		return nil, false, err
 	}
+	tx = node.interner.intern(tx)
 
-	err = tx.Validate(node.persistStore, s.Timestamp, finalized)
+	err = tx.Validate(node.persistStore, s.Timestamp, finalized, node.consensusForks(s.Timestamp))
 	if err != nil {
 		return nil, false, err
 	}
@@ -125,6 +127,16 @@ func (node *Node) validateKernelSnapshot(s *common.Snapshot, tx *common.Versione
 		}
 	case common.TransactionTypeCustodianSlashNodes:
 		return fmt.Errorf("not implemented %v", tx)
+	case common.TransactionTypeDeposit:
+		if finalized {
+			break
+		}
+		err := node.readDepositProof(tx)
+		if err != nil {
+			logger.Printf("readDepositProof ERROR %v %s %s\n",
+				s, hex.EncodeToString(tx.PayloadMarshal()), err.Error())
+			return err
+		}
 	}
 	if s.NodeId != node.IdForNetwork && s.RoundNumber == 0 &&
 		tx.TransactionType() != common.TransactionTypeNodeAccept {
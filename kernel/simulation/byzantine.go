@@ -0,0 +1,115 @@
+package simulation
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/p2p"
+)
+
+// Behavior rewrites the messages sent by a byzantine node to another node,
+// the message is withheld if no data returned. The behaviors apply before the
+// faults, and are not removed by the heal.
+type Behavior func(to int, data []byte) []byte
+
+// SetBehavior makes the node byzantine, or honest again with a nil behavior.
+func (s *Simulation) SetBehavior(i int, b Behavior) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.behaviors == nil {
+		s.behaviors = make(map[int]Behavior)
+	}
+	if b == nil {
+		delete(s.behaviors, i)
+	} else {
+		s.behaviors[i] = b
+	}
+}
+
+// Signer is the private signer key of the node, which the byzantine
+// behaviors use to sign the forged messages as the node.
+func (s *Simulation) Signer(i int) crypto.Key {
+	return s.signers[i]
+}
+
+// WithholdCommitments never commits to the snapshots of the other nodes, nor
+// responds to their challenges, so the node is counted as offline by the
+// leaders, while it still leads its own snapshots.
+func WithholdCommitments() Behavior {
+	return func(to int, data []byte) []byte {
+		switch data[0] {
+		case p2p.PeerMessageTypeSnapshotCommitment,
+			p2p.PeerMessageTypeCommitments,
+			p2p.PeerMessageTypeSnapshotResponse:
+			return nil
+		}
+		return data
+	}
+}
+
+// DoubleSign announces a conflicting snapshot of the same round to the
+// targets, which has a different timestamp and is signed by the key, while
+// the other nodes get the original snapshot.
+func DoubleSign(key crypto.Key, targets ...int) Behavior {
+	return func(to int, data []byte) []byte {
+		if data[0] != p2p.PeerMessageTypeSnapshotAnnouncement || !slices.Contains(targets, to) {
+			return data
+		}
+		// type, signature, commitment R, and the versioned snapshot
+		if len(data) < 1+64+32 {
+			return data
+		}
+		s, err := common.UnmarshalVersionedSnapshot(data[97:])
+		if err != nil {
+			return data
+		}
+		s.Timestamp = s.Timestamp + 1
+		msg := append(data[65:97:97], s.Snapshot.VersionedMarshal()...)
+		sig := key.Sign(crypto.Blake3Hash(msg))
+		forged := append([]byte{p2p.PeerMessageTypeSnapshotAnnouncement}, sig[:]...)
+		return append(forged, msg...)
+	}
+}
+
+// SendStalePledge makes the running node send a pledge of its own signer key
+// to all the other running nodes, the signer is already accepted, so the
+// pledge is stale and never valid. The pledge spends the custodian output,
+// whose amount must be the pledge amount.
+func (s *Simulation) SendStalePledge(i int, input crypto.Hash, index uint) (*common.VersionedTransaction, error) {
+	s.state.RLock()
+	defer s.state.RUnlock()
+	if !s.running[i] {
+		return nil, fmt.Errorf("simulation node %d crashed", i)
+	}
+
+	node := s.nodes[i]
+	tx := common.NewTransactionV5(common.XINAssetId)
+	tx.AddInput(input, index)
+	tx.AddOutputWithType(common.OutputTypeNodePledge, nil, common.Script{}, common.KernelNodePledgeAmount, make([]byte, 64))
+	s.mutex.Lock()
+	payee := s.newAccount()
+	s.mutex.Unlock()
+	tx.Extra = append(node.Signer.PublicSpendKey[:], payee.PublicSpendKey[:]...)
+	signed := tx.AsVersioned()
+	err := signed.SignInput(s.stores[i], 0, []*common.Address{&s.Custodian})
+	if err != nil {
+		return nil, err
+	}
+	ver, err := common.UnmarshalVersionedTransaction(signed.Marshal())
+	if err != nil {
+		return nil, err
+	}
+
+	for j, peer := range s.nodes {
+		if j == i || !s.running[j] {
+			continue
+		}
+		err := node.Peer.SendTransactionMessage(peer.IdForNetwork, ver)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ver, nil
+}
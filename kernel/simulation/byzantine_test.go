@@ -0,0 +1,73 @@
+package simulation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/stretchr/testify/require"
+)
+
+func TestByzantine(t *testing.T) {
+	require := require.New(t)
+	logger.SetLevel(0)
+
+	sim, err := New(t.TempDir(), 7, testSeed(t))
+	require.Nil(err)
+	sim.Start()
+	defer sim.Teardown()
+	require.True(sim.Wait(30*time.Second, sim.Synced))
+	all := []int{0, 1, 2, 3, 4, 5, 6}
+
+	sim.SetBehavior(5, WithholdCommitments())
+	sim.SetBehavior(6, WithholdCommitments())
+	d0 := testDeposit(require, sim, 0, 0)
+	testFinalized(require, sim, d0, all, true)
+	sim.SetBehavior(5, nil)
+
+	sim.SetBehavior(6, DoubleSign(sim.Signer(6), 0, 1))
+	d1 := testDeposit(require, sim, 6, 1)
+	testFinalized(require, sim, d1, all, true)
+	testConsistent(require, sim, d1, all)
+	sim.SetBehavior(6, nil)
+
+	d2, err := sim.Deposit(1, common.KernelNodePledgeAmount, 2)
+	require.Nil(err)
+	testFinalized(require, sim, d2, all, true)
+	pledge, err := sim.SendStalePledge(6, d2, 0)
+	require.Nil(err)
+	d3 := testDeposit(require, sim, 2, 3)
+	testFinalized(require, sim, d3, all, true)
+	testFinalized(require, sim, pledge.PayloadHash(), all, false)
+	cached, err := sim.stores[0].CacheGetTransaction(pledge.PayloadHash())
+	require.Nil(err)
+	require.NotNil(cached)
+	for _, i := range all {
+		require.Len(sim.Node(i).NodesListWithoutState(uint64(time.Now().UnixNano()), false), 7)
+	}
+}
+
+// the transaction may be finalized by more than one snapshot, and the nodes
+// may record different ones as the first, but all the finalized snapshots of
+// any node must be finalized by all the others
+func testConsistent(require *require.Assertions, sim *Simulation, hash crypto.Hash, nodes []int) {
+	consistent := sim.Wait(30*time.Second, func() bool {
+		for _, i := range nodes {
+			snap, err := sim.Finalized(i, hash)
+			require.Nil(err)
+			h, err := crypto.HashFromString(snap)
+			require.Nil(err)
+			for _, j := range nodes {
+				s, err := sim.stores[j].ReadSnapshot(h)
+				require.Nil(err)
+				if s == nil {
+					return false
+				}
+			}
+		}
+		return true
+	})
+	require.True(consistent, hash.String())
+}
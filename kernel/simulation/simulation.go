@@ -0,0 +1,446 @@
+// Package simulation runs the kernel nodes of a private network in the same
+// process, they are connected by the memory network of the p2p package, so
+// the tests could inject faults to the messages and crash the nodes, to
+// verify the consensus of the nodes without sockets.
+//
+// The keys, the faults, the cosi nonces and the ghost keys are all decided by
+// the seed, and the mock clock starts at the same genesis epoch, so a failed
+// simulation is replayed with the same seed, although the goroutines of the
+// nodes are still scheduled by the go runtime. The cosi nonces and the ghost
+// keys follow the seed only with the simulation build tag, which replaces the
+// system random of the crypto package.
+package simulation
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/kernel"
+	"github.com/MixinNetwork/mixin/p2p"
+	"github.com/MixinNetwork/mixin/storage"
+	"github.com/dgraph-io/ristretto/v2"
+)
+
+const (
+	RoundGap = 100 * time.Millisecond
+
+	basePort = 7000
+	anyNode  = -1
+	epoch    = 1551312000
+)
+
+const configTmpl = `[node]
+signer-key = "%s"
+kernel-operation-period = 3
+memory-cache-size = 16
+cache-ttl = 3600
+[p2p]
+port = %d
+seeds = [%s]
+relayer = true
+[rpc]
+port = %d
+`
+
+// Fault applies to all the messages of a link, the message is dropped by the
+// probability, or delivered after the delay and a random jitter, so the
+// messages are reordered by the jitter.
+type Fault struct {
+	Drop   float64
+	Delay  time.Duration
+	Jitter time.Duration
+}
+
+type Simulation struct {
+	Genesis   *common.Genesis
+	Custodian common.Address
+
+	root    string
+	network *p2p.MemoryNetwork
+	signers []crypto.Key
+	nodes   []*kernel.Node
+	stores  []*storage.FaultStore
+	running []bool
+	state   sync.RWMutex
+
+	mutex     sync.Mutex
+	rand      *rand.Rand
+	ports     map[string]int
+	faults    map[[2]int]Fault
+	partition map[int]int
+	behaviors map[int]Behavior
+}
+
+// New writes the genesis and the configs of the nodes in the root directory,
+// and sets up the nodes with the memory stores, all of them are relayers and
+// seeds to each other.
+// The keys of the nodes and the custodian are derived from the seed, and the
+// mock clock and random of the kernel are reset to the epoch and the seed.
+func New(root string, count int, seed int64) (*Simulation, error) {
+	kernel.TestMockReset()
+	kernel.TestMockRand(seed)
+	kernel.TestMockDiff(time.Until(time.Unix(epoch, 0)))
+
+	s := &Simulation{
+		root:    root,
+		rand:    rand.New(rand.NewSource(seed)),
+		ports:   make(map[string]int),
+		faults:  make(map[[2]int]Fault),
+		signers: make([]crypto.Key, count),
+		nodes:   make([]*kernel.Node, count),
+		stores:  make([]*storage.FaultStore, count),
+		running: make([]bool, count),
+	}
+	s.network = p2p.NewMemoryNetwork(s.filter)
+
+	accounts := make([]common.Address, count)
+	inputs := make([]map[string]string, count)
+	for i := range accounts {
+		accounts[i] = s.newAccount()
+		s.signers[i] = accounts[i].PrivateSpendKey
+		inputs[i] = map[string]string{
+			"signer":    accounts[i].String(),
+			"payee":     s.newAccount().String(),
+			"custodian": s.newAccount().String(),
+			"balance":   "13439",
+		}
+	}
+	s.Custodian = s.newAccount()
+	genesisData, err := json.MarshalIndent(map[string]any{
+		"epoch":     epoch,
+		"nodes":     inputs,
+		"custodian": s.Custodian,
+		"params":    map[string]any{"round_gap": RoundGap.String()},
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(genesisData, &s.Genesis)
+	if err != nil {
+		return nil, err
+	}
+	err = kernel.ApplyConsensusParams(s.Genesis)
+	if err != nil {
+		return nil, err
+	}
+
+	peers := make([]string, count)
+	for i, a := range accounts {
+		id := a.Hash().ForNetwork(s.Genesis.NetworkId())
+		addr := fmt.Sprintf("127.0.0.1:%d", basePort+i+1)
+		peers[i] = fmt.Sprintf(`"%s@%s"`, id, addr)
+		s.ports[addr] = i
+	}
+	for i, a := range accounts {
+		dir := s.nodeDir(i)
+		err := os.MkdirAll(dir, 0700)
+		if err != nil {
+			return nil, err
+		}
+		configData := fmt.Sprintf(configTmpl, a.PrivateSpendKey, basePort+i+1,
+			strings.Join(peers, ","), basePort+1000+i+1)
+		err = os.WriteFile(dir+"/config.toml", []byte(configData), 0600)
+		if err != nil {
+			return nil, err
+		}
+		err = s.setupNode(i)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Start runs the loops of all the nodes, the nodes are connected in the
+// following round gaps.
+func (s *Simulation) Start() {
+	for i := range s.nodes {
+		s.startNode(i)
+	}
+}
+
+// Teardown stops all the running nodes and closes their stores.
+func (s *Simulation) Teardown() {
+	var wg sync.WaitGroup
+	for i := range s.nodes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			s.Crash(i)
+		}(i)
+	}
+	wg.Wait()
+	for _, store := range s.stores {
+		store.Close()
+	}
+}
+
+func (s *Simulation) Node(i int) *kernel.Node {
+	return s.nodes[i]
+}
+
+// Store is the store of the node, which could fail the writes or slow the
+// reads of the node, and is kept across the crashes.
+func (s *Simulation) Store(i int) *storage.FaultStore {
+	return s.stores[i]
+}
+
+func (s *Simulation) Count() int {
+	return len(s.nodes)
+}
+
+func (s *Simulation) Running(i int) bool {
+	s.state.RLock()
+	defer s.state.RUnlock()
+	return s.running[i]
+}
+
+// Crash stops the node as a sudden exit, its store is kept in memory as it is
+// on the disk, so the node could be restarted with all its finalized snapshots.
+func (s *Simulation) Crash(i int) {
+	s.state.Lock()
+	running := s.running[i]
+	s.running[i] = false
+	s.state.Unlock()
+	if running {
+		s.nodes[i].Teardown()
+	}
+}
+
+func (s *Simulation) Restart(i int) error {
+	if s.Running(i) {
+		return fmt.Errorf("simulation node %d running", i)
+	}
+	err := s.setupNode(i)
+	if err != nil {
+		return err
+	}
+	s.startNode(i)
+	return nil
+}
+
+// Advance moves the mock clock of all the nodes forward, e.g. to the next
+// election or mint time.
+func (s *Simulation) Advance(d time.Duration) {
+	kernel.TestMockDiff(d)
+}
+
+// SetFault sets the fault of the messages from one node to another, either
+// of them could be -1 for any node, and the exact link has the precedence.
+func (s *Simulation) SetFault(from, to int, f Fault) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.faults[[2]int{from, to}] = f
+}
+
+// Partition drops all the messages between the nodes of different groups,
+// and the nodes not in any group are isolated from all the others.
+func (s *Simulation) Partition(groups ...[]int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.partition = make(map[int]int)
+	for g, nodes := range groups {
+		for _, i := range nodes {
+			s.partition[i] = g
+		}
+	}
+}
+
+// Heal removes the partition and all the faults.
+func (s *Simulation) Heal() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.partition = nil
+	s.faults = make(map[[2]int]Fault)
+}
+
+// QueueTransaction queues the transaction to the running node, as it is sent
+// by the RPC of the node.
+func (s *Simulation) QueueTransaction(i int, ver *common.VersionedTransaction) (string, error) {
+	s.state.RLock()
+	defer s.state.RUnlock()
+	if !s.running[i] {
+		return "", fmt.Errorf("simulation node %d crashed", i)
+	}
+	return s.nodes[i].QueueTransaction(ver)
+}
+
+// Deposit queues a deposit of the amount to the custodian by the running
+// node, the deposit transaction is unique by the sequence.
+func (s *Simulation) Deposit(i int, amount common.Integer, sequence int) (crypto.Hash, error) {
+	hash := crypto.Blake3Hash([]byte(fmt.Sprintf("simulation-deposit-%d", sequence)))
+	tx := common.NewTransactionV5(common.XINAssetId)
+	tx.AddDepositInput(&common.DepositData{
+		Chain:       common.XINAsset.Chain,
+		AssetKey:    common.XINAsset.AssetKey,
+		Transaction: "0x" + hash.String(),
+		Index:       0,
+		Amount:      amount,
+	})
+	seed := append(hash[:], hash[:]...)
+	tx.AddScriptOutput([]*common.Address{&s.Custodian}, common.NewThresholdScript(1), amount, seed)
+	signed := tx.AsVersioned()
+	err := signed.SignRaw(s.Custodian.PrivateSpendKey)
+	if err != nil {
+		return crypto.Hash{}, err
+	}
+	ver, err := common.UnmarshalVersionedTransaction(signed.Marshal())
+	if err != nil {
+		return crypto.Hash{}, err
+	}
+	_, err = s.QueueTransaction(i, ver)
+	return ver.PayloadHash(), err
+}
+
+// Finalized returns the finalized snapshot of the transaction by the running
+// node, or an empty string if not finalized.
+func (s *Simulation) Finalized(i int, hash crypto.Hash) (string, error) {
+	s.state.RLock()
+	defer s.state.RUnlock()
+	if !s.running[i] {
+		return "", fmt.Errorf("simulation node %d crashed", i)
+	}
+	_, snap, err := s.stores[i].ReadTransaction(hash)
+	return snap, err
+}
+
+// Synced returns true if all the running nodes have the sync points of all
+// the other running nodes, and are not behind any of them.
+func (s *Simulation) Synced() bool {
+	s.state.RLock()
+	defer s.state.RUnlock()
+	running := 0
+	for _, r := range s.running {
+		if r {
+			running += 1
+		}
+	}
+	for i, node := range s.nodes {
+		if !s.running[i] {
+			continue
+		}
+		status := node.SyncStatus()
+		if !status.Synced || len(status.Chains) < running-1 {
+			return false
+		}
+	}
+	return true
+}
+
+// Wait polls the condition every round gap until it is true or the timeout.
+func (s *Simulation) Wait(timeout time.Duration, cond func() bool) bool {
+	for deadline := time.Now().Add(timeout); time.Now().Before(deadline); {
+		if cond() {
+			return true
+		}
+		time.Sleep(RoundGap)
+	}
+	return cond()
+}
+
+func (s *Simulation) setupNode(i int) error {
+	dir := s.nodeDir(i)
+	custom, err := config.Initialize(dir + "/config.toml")
+	if err != nil {
+		return err
+	}
+	cost := int64(custom.Node.MemoryCacheSize * 1024 * 1024)
+	cache, err := ristretto.NewCache(&ristretto.Config[[]byte, any]{
+		NumCounters: cost / 1024 * 10,
+		MaxCost:     cost,
+		BufferItems: 64,
+	})
+	if err != nil {
+		return err
+	}
+	if s.stores[i] == nil {
+		store, err := storage.NewMemoryStore(custom)
+		if err != nil {
+			return err
+		}
+		s.stores[i] = storage.NewFaultStore(store)
+	}
+	node, err := kernel.SetupNode(custom, crashStore{s.stores[i]}, cache, s.Genesis)
+	if err != nil {
+		return err
+	}
+	node.SetNetwork(s.network.Endpoint(fmt.Sprintf("127.0.0.1:%d", custom.P2P.Port)))
+	s.nodes[i] = node
+	return nil
+}
+
+// the teardown of a crashed node closes its store, which is only closed by
+// the teardown of the simulation instead
+type crashStore struct {
+	*storage.FaultStore
+}
+
+func (s crashStore) Close() error {
+	return nil
+}
+
+func (s *Simulation) startNode(i int) {
+	s.state.Lock()
+	s.running[i] = true
+	s.state.Unlock()
+	go s.nodes[i].Loop()
+}
+
+func (s *Simulation) filter(from, to string, data []byte) ([]byte, time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	a, b := s.ports[from], s.ports[to]
+	if s.partition != nil {
+		ga, oka := s.partition[a]
+		gb, okb := s.partition[b]
+		if !oka || !okb || ga != gb {
+			return nil, 0
+		}
+	}
+	if behave := s.behaviors[a]; behave != nil {
+		data = behave(b, data)
+		if len(data) == 0 {
+			return nil, 0
+		}
+	}
+	f, found := s.faults[[2]int{a, b}]
+	for _, link := range [][2]int{{a, anyNode}, {anyNode, b}, {anyNode, anyNode}} {
+		if found {
+			break
+		}
+		f, found = s.faults[link]
+	}
+	if !found {
+		return data, 0
+	}
+	if f.Drop > 0 && s.rand.Float64() < f.Drop {
+		return nil, 0
+	}
+	delay := f.Delay
+	if f.Jitter > 0 {
+		delay += time.Duration(s.rand.Int63n(int64(f.Jitter)))
+	}
+	return data, delay
+}
+
+func (s *Simulation) newAccount() common.Address {
+	seed := make([]byte, 64)
+	s.rand.Read(seed)
+	account := common.NewAddressFromSeed(seed)
+	account.PrivateViewKey = account.PublicSpendKey.DeterministicHashDerive()
+	account.PublicViewKey = account.PrivateViewKey.Public()
+	return account
+}
+
+func (s *Simulation) nodeDir(i int) string {
+	return fmt.Sprintf("%s/node-%02d", s.root, i+1)
+}
@@ -0,0 +1,97 @@
+package simulation
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimulation(t *testing.T) {
+	require := require.New(t)
+	logger.SetLevel(0)
+
+	sim, err := New(t.TempDir(), 7, testSeed(t))
+	require.Nil(err)
+	sim.Start()
+	defer sim.Teardown()
+
+	require.True(sim.Wait(30*time.Second, sim.Synced))
+	all := []int{0, 1, 2, 3, 4, 5, 6}
+	d0 := testDeposit(require, sim, 0, 0)
+	testFinalized(require, sim, d0, all, true)
+
+	failure := errors.New("simulation disk failure")
+	sim.Store(1).FailWrites(failure, "CachePutTransaction")
+	_, err = sim.Deposit(1, common.NewInteger(1), 1)
+	require.ErrorIs(err, failure)
+	sim.Store(1).FailWrites(nil)
+
+	sim.SetFault(-1, -1, Fault{Drop: 0.05, Delay: 10 * time.Millisecond, Jitter: 50 * time.Millisecond})
+	sim.SetFault(2, 3, Fault{Drop: 1})
+	d1 := testDeposit(require, sim, 1, 1)
+	testFinalized(require, sim, d1, all, true)
+	sim.Heal()
+
+	sim.Partition([]int{0, 1}, []int{2, 3, 4, 5, 6})
+	d2 := testDeposit(require, sim, 2, 2)
+	testFinalized(require, sim, d2, all[2:], true)
+	testFinalized(require, sim, d2, all[:2], false)
+	sim.Heal()
+	testFinalized(require, sim, d2, all, true)
+
+	sim.Crash(6)
+	require.False(sim.Running(6))
+	_, err = sim.Finalized(6, d0)
+	require.ErrorContains(err, "crashed")
+	d3 := testDeposit(require, sim, 1, 3)
+	testFinalized(require, sim, d3, all[:6], true)
+
+	require.Nil(sim.Restart(6))
+	require.NotNil(sim.Restart(6))
+	require.True(sim.Wait(30*time.Second, sim.Synced))
+	testFinalized(require, sim, d3, all, true)
+	d4 := testDeposit(require, sim, 6, 4)
+	testFinalized(require, sim, d4, all, true)
+}
+
+// the failed simulation is replayed by the seed in the output, e.g.
+// SEED=1551312000 go test -tags simulation -run TestSimulation ./kernel/simulation
+func testSeed(t *testing.T) int64 {
+	seed, err := strconv.ParseInt(os.Getenv("SEED"), 10, 64)
+	if err != nil {
+		seed = time.Now().UnixNano()
+	}
+	t.Logf("SIMULATION SEED %d\n", seed)
+	return seed
+}
+
+func testDeposit(require *require.Assertions, sim *Simulation, node, sequence int) crypto.Hash {
+	hash, err := sim.Deposit(node, common.NewInteger(1), sequence)
+	require.Nil(err)
+	return hash
+}
+
+func testFinalized(require *require.Assertions, sim *Simulation, hash crypto.Hash, nodes []int, expected bool) {
+	timeout := 30 * time.Second
+	if !expected {
+		timeout = 3 * time.Second
+	}
+	finalized := sim.Wait(timeout, func() bool {
+		for _, i := range nodes {
+			snap, err := sim.Finalized(i, hash)
+			require.Nil(err)
+			if snap == "" {
+				return false
+			}
+		}
+		return true
+	})
+	require.Equal(expected, finalized, hash.String())
+}
@@ -0,0 +1,216 @@
+package kernel
+
+import (
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/kernel/internal/clock"
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/mixin/storage"
+)
+
+const (
+	stateCommitmentPayloadSize = 8 + 32 + 32
+	stateCommitmentSize        = stateCommitmentPayloadSize + 64
+	stateCommitmentsLimit      = 24 * 7
+	stateCheckpointsBatch      = 100
+)
+
+// StateCommitment is the signed commitment of a node to its state checkpoint,
+// the checkpoints are cut by the snapshot timestamp instead of the local
+// topology, so the honest nodes always commit to the same hash.
+type StateCommitment struct {
+	Checkpoint uint64
+	Commitment crypto.Hash
+	Reporter   crypto.Hash
+	Signature  crypto.Signature
+}
+
+type stateCommitments struct {
+	sync.RWMutex
+	m map[uint64][]*StateCommitment
+}
+
+func (c *StateCommitment) payload() []byte {
+	data := binary.BigEndian.AppendUint64(nil, c.Checkpoint)
+	data = append(data, c.Commitment[:]...)
+	return append(data, c.Reporter[:]...)
+}
+
+func (c *StateCommitment) Marshal() []byte {
+	return append(c.payload(), c.Signature[:]...)
+}
+
+func UnmarshalStateCommitment(data []byte) (*StateCommitment, error) {
+	if len(data) != stateCommitmentSize {
+		return nil, fmt.Errorf("invalid state commitment size %d", len(data))
+	}
+	c := &StateCommitment{Checkpoint: binary.BigEndian.Uint64(data[:8])}
+	copy(c.Commitment[:], data[8:40])
+	copy(c.Reporter[:], data[40:72])
+	copy(c.Signature[:], data[72:])
+	return c, nil
+}
+
+// StateCommitments returns the commitments received for the checkpoint,
+// including the one signed by this node.
+func (node *Node) StateCommitments(checkpoint uint64) []*StateCommitment {
+	node.commitments.RLock()
+	defer node.commitments.RUnlock()
+	return slices.Clone(node.commitments.m[checkpoint])
+}
+
+func (node *Node) addStateCommitment(c *StateCommitment) bool {
+	node.commitments.Lock()
+	defer node.commitments.Unlock()
+	if node.commitments.m == nil {
+		node.commitments.m = make(map[uint64][]*StateCommitment)
+	}
+	list := node.commitments.m[c.Checkpoint]
+	if slices.ContainsFunc(list, func(o *StateCommitment) bool { return o.Reporter == c.Reporter }) {
+		return false
+	}
+	node.commitments.m[c.Checkpoint] = append(list, c)
+	for len(node.commitments.m) > stateCommitmentsLimit {
+		oldest := c.Checkpoint
+		for n := range node.commitments.m {
+			oldest = min(oldest, n)
+		}
+		delete(node.commitments.m, oldest)
+	}
+	return true
+}
+
+// stateNodesHash commits to the node states at the end of the checkpoint,
+// the list is sorted by the deterministic node state sequences.
+func (node *Node) stateNodesHash(timestamp uint64) crypto.Hash {
+	var data []byte
+	for _, cn := range node.NodesListWithoutState(timestamp, false) {
+		data = append(data, cn.IdForNetwork[:]...)
+		data = append(data, cn.Signer.PublicSpendKey[:]...)
+		data = append(data, cn.Signer.PublicViewKey[:]...)
+		data = append(data, cn.Payee.PublicSpendKey[:]...)
+		data = append(data, cn.Payee.PublicViewKey[:]...)
+		data = append(data, cn.Transaction[:]...)
+		data = binary.BigEndian.AppendUint64(data, cn.Timestamp)
+		data = append(data, cn.State...)
+	}
+	return crypto.Blake3Hash(data)
+}
+
+// readyStateCheckpoint is the last checkpoint ended at least a checkpoint
+// duration ago, no more snapshots before its end could be finalized then,
+// because the snapshot timestamp must follow the local clock of the nodes.
+func (node *Node) readyStateCheckpoint(now uint64) (uint64, bool) {
+	delay := uint64(config.CheckpointDuration)
+	if now < node.Epoch+delay || !node.SyncStatus().Synced {
+		return 0, false
+	}
+	number := storage.StateCheckpointNumber(now - delay)
+	if number == 0 {
+		return 0, false
+	}
+	return number - 1, true
+}
+
+func (node *Node) checkpointState() {
+	now := uint64(clock.Now().UnixNano())
+	ready, ok := node.readyStateCheckpoint(now)
+	if !ok {
+		return
+	}
+	since, found, err := node.persistStore.ReadStateIndexSince()
+	if err != nil || !found || since != 0 {
+		logger.Verbosef("checkpointState ReadStateIndexSince() => %d %t %v\n", since, found, err)
+		return
+	}
+
+	next := storage.StateCheckpointNumber(node.Epoch)
+	last, err := node.persistStore.ListStateCheckpoints(1)
+	if err != nil {
+		logger.Println("checkpointState ListStateCheckpoints", err)
+		return
+	}
+	if len(last) > 0 {
+		next = last[0].Number + 1
+	}
+	for i := 0; next <= ready && i < stateCheckpointsBatch; i++ {
+		end := (next + 1) * uint64(config.StateCheckpointInterval)
+		cp, err := node.persistStore.WriteStateCheckpoint(next, node.stateNodesHash(end))
+		if err != nil {
+			logger.Println("checkpointState WriteStateCheckpoint", next, err)
+			return
+		}
+		if next == ready {
+			node.commitStateCheckpoint(cp, now)
+		}
+		next = next + 1
+	}
+}
+
+func (node *Node) commitStateCheckpoint(cp *storage.StateCheckpoint, now uint64) {
+	c := &StateCommitment{
+		Checkpoint: cp.Number,
+		Commitment: cp.Commitment(),
+		Reporter:   node.IdForNetwork,
+	}
+	sig, err := node.SignData(c.payload())
+	if err != nil {
+		logger.Printf("commitStateCheckpoint(%d) => %v\n", cp.Number, err)
+		return
+	}
+	c.Signature = sig
+	node.addStateCommitment(c)
+	for _, o := range node.StateCommitments(cp.Number) {
+		node.checkStateCommitment(o, c.Commitment)
+	}
+	data := c.Marshal()
+	for _, cn := range node.NodesListWithoutState(now, true) {
+		err := node.Peer.SendStateCommitmentMessage(cn.IdForNetwork, data)
+		if err != nil {
+			logger.Verbosef("SendStateCommitmentMessage(%s) => %v\n", cn.IdForNetwork, err)
+		}
+	}
+}
+
+func (node *Node) checkStateCommitment(c *StateCommitment, local crypto.Hash) {
+	if c.Commitment == local {
+		return
+	}
+	logger.Printw("State commitment mismatch", "alert", "state", "checkpoint", c.Checkpoint,
+		"commitment", c.Commitment.String(), "local", local.String(), "reporter", c.Reporter.String())
+}
+
+func (node *Node) ReceiveStateCommitment(peerId crypto.Hash, data []byte) error {
+	c, err := UnmarshalStateCommitment(data)
+	if err != nil {
+		return err
+	}
+	if c.Reporter != peerId {
+		return fmt.Errorf("invalid state commitment reporter %s %s", c.Reporter, peerId)
+	}
+	current := storage.StateCheckpointNumber(uint64(clock.Now().UnixNano()))
+	if c.Checkpoint >= current || c.Checkpoint+stateCommitmentsLimit < current {
+		return fmt.Errorf("invalid state commitment checkpoint %d %d", c.Checkpoint, current)
+	}
+	reporter := node.GetAcceptedOrPledgingNode(c.Reporter)
+	if reporter == nil {
+		return fmt.Errorf("unknown state commitment reporter %s", c.Reporter)
+	}
+	if !reporter.Signer.PublicSpendKey.Verify(crypto.Blake3Hash(c.payload()), c.Signature) {
+		return fmt.Errorf("invalid state commitment signature %s", c.Reporter)
+	}
+	if !node.addStateCommitment(c) {
+		return nil
+	}
+	cp, err := node.persistStore.ReadStateCheckpoint(c.Checkpoint)
+	if err != nil || cp == nil {
+		return err
+	}
+	node.checkStateCommitment(c, cp.Commitment())
+	return nil
+}
@@ -0,0 +1,92 @@
+package kernel
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/kernel/internal/clock"
+	"github.com/MixinNetwork/mixin/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStateCommitment(t *testing.T) {
+	require := require.New(t)
+
+	custom, err := config.Initialize("../config/config.example.toml")
+	require.Nil(err)
+	root, err := os.MkdirTemp("", "mixin-state-commitment-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+	store, err := storage.NewBadgerStore(custom, root)
+	require.Nil(err)
+	defer store.Close()
+
+	now := uint64(clock.Now().UnixNano())
+	epoch := uint64(clock.Now().Add(-time.Hour * 24 * 30).UnixNano())
+	node := &Node{Epoch: epoch, persistStore: store}
+	var cnodes []*CNode
+	for i := range 7 {
+		signer := testPledgeSigner(fmt.Sprintf("genesis-%d", i))
+		id := signer.Hash().ForNetwork(node.networkId)
+		cnodes = append(cnodes, &CNode{IdForNetwork: id, Signer: signer, Timestamp: epoch + uint64(i), State: common.NodeStateAccepted})
+	}
+	node.allNodesSortedWithState = cnodes
+	node.nodeStateSequences = node.buildNodeStateSequences(cnodes, false)
+	node.acceptedNodeStateSequences = node.buildNodeStateSequences(cnodes, true)
+
+	_, ready := node.readyStateCheckpoint(now)
+	require.False(ready)
+	node.syncStatus.Store(&SyncStatus{Synced: true})
+	number, ready := node.readyStateCheckpoint(now)
+	require.True(ready)
+	require.Equal(storage.StateCheckpointNumber(now-uint64(config.CheckpointDuration))-1, number)
+	_, ready = node.readyStateCheckpoint(epoch)
+	require.False(ready)
+
+	nodes := node.stateNodesHash(now)
+	require.Equal(nodes, node.stateNodesHash(now))
+	require.NotEqual(nodes, node.stateNodesHash(epoch+3))
+
+	reporter := cnodes[1]
+	c := &StateCommitment{
+		Checkpoint: number,
+		Commitment: crypto.Blake3Hash([]byte("commitment")),
+		Reporter:   reporter.IdForNetwork,
+	}
+	c.Signature = reporter.Signer.PrivateSpendKey.Sign(crypto.Blake3Hash(c.payload()))
+	data := c.Marshal()
+	require.Len(data, stateCommitmentSize)
+	dc, err := UnmarshalStateCommitment(data)
+	require.Nil(err)
+	require.Equal(c, dc)
+
+	err = node.ReceiveStateCommitment(cnodes[2].IdForNetwork, data)
+	require.ErrorContains(err, "invalid state commitment reporter")
+	err = node.ReceiveStateCommitment(reporter.IdForNetwork, data)
+	require.Nil(err)
+	err = node.ReceiveStateCommitment(reporter.IdForNetwork, data)
+	require.Nil(err)
+	require.Len(node.StateCommitments(number), 1)
+
+	future := &StateCommitment{Checkpoint: number + 2, Reporter: reporter.IdForNetwork}
+	future.Signature = reporter.Signer.PrivateSpendKey.Sign(crypto.Blake3Hash(future.payload()))
+	err = node.ReceiveStateCommitment(reporter.IdForNetwork, future.Marshal())
+	require.ErrorContains(err, "invalid state commitment checkpoint")
+
+	data[len(data)-1] ^= 0xff
+	err = node.ReceiveStateCommitment(reporter.IdForNetwork, data)
+	require.ErrorContains(err, "invalid state commitment signature")
+	_, err = UnmarshalStateCommitment(data[1:])
+	require.ErrorContains(err, "invalid state commitment size")
+
+	for i := range stateCommitmentsLimit {
+		require.True(node.addStateCommitment(&StateCommitment{Checkpoint: number + 1 + uint64(i)}))
+	}
+	require.Len(node.StateCommitments(number), 0)
+	require.Len(node.StateCommitments(number+1), 1)
+}
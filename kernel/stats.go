@@ -0,0 +1,111 @@
+package kernel
+
+import (
+	"bytes"
+	"slices"
+	"sync/atomic"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/kernel/internal/clock"
+)
+
+// the reason codes of the snapshots rejected by a chain
+const (
+	RejectQueueFull   = "queue_full"
+	RejectSanity      = "sanity"
+	RejectExpired     = "expired"
+	RejectFuture      = "future"
+	RejectNewRound    = "new_round"
+	RejectSignature   = "signature"
+	RejectTransaction = "transaction"
+	RejectReferences  = "references"
+	RejectValidation  = "validation"
+)
+
+var rejectReasons = []string{
+	RejectQueueFull,
+	RejectSanity,
+	RejectExpired,
+	RejectFuture,
+	RejectNewRound,
+	RejectSignature,
+	RejectTransaction,
+	RejectReferences,
+	RejectValidation,
+}
+
+type ChainStats struct {
+	ChainId      crypto.Hash       `json:"chain"`
+	Consumed     uint64            `json:"consumed"`
+	Rejected     map[string]uint64 `json:"rejected"`
+	CachePool    int               `json:"cache_pool"`
+	FinalActions int               `json:"final_actions"`
+	Reorder      int               `json:"reorder"`
+	CacheRound   uint64            `json:"cache_round"`
+	FinalRound   uint64            `json:"final_round"`
+	ActiveAt     uint64            `json:"active_at"`
+}
+
+// the counters are updated by the chain loops and read by the RPC, the
+// rejected map is built once with all the reasons so it's never written.
+type chainCounters struct {
+	consumed atomic.Uint64
+	rejected map[string]*atomic.Uint64
+	activeAt atomic.Uint64
+}
+
+func newChainCounters() *chainCounters {
+	c := &chainCounters{rejected: make(map[string]*atomic.Uint64)}
+	for _, r := range rejectReasons {
+		c.rejected[r] = new(atomic.Uint64)
+	}
+	return c
+}
+
+func (chain *Chain) markActive() {
+	chain.counters.activeAt.Store(uint64(clock.Now().UnixNano()))
+}
+
+func (chain *Chain) markConsumed() {
+	chain.counters.consumed.Add(1)
+}
+
+func (chain *Chain) markRejected(reason string) {
+	chain.counters.rejected[reason].Add(1)
+}
+
+// Stats is best effort like the diagnostics, the round numbers are read
+// without the chain lock.
+func (chain *Chain) Stats() *ChainStats {
+	cs := &ChainStats{
+		ChainId:      chain.ChainId,
+		Consumed:     chain.counters.consumed.Load(),
+		Rejected:     make(map[string]uint64),
+		CachePool:    len(chain.CachePool),
+		FinalActions: len(chain.finalActionsRing),
+		Reorder:      chain.reorder.Len(),
+		ActiveAt:     chain.counters.activeAt.Load(),
+	}
+	for r, c := range chain.counters.rejected {
+		cs.Rejected[r] = c.Load()
+	}
+	if state := chain.State; state != nil {
+		cs.CacheRound = state.CacheRound.Number
+		cs.FinalRound = state.FinalRound.Number
+	}
+	return cs
+}
+
+func (node *Node) ChainsStats() []*ChainStats {
+	node.chains.RLock()
+	var stats []*ChainStats
+	for _, chain := range node.chains.m {
+		stats = append(stats, chain.Stats())
+	}
+	node.chains.RUnlock()
+
+	slices.SortFunc(stats, func(a, b *ChainStats) int {
+		return bytes.Compare(a.ChainId[:], b.ChainId[:])
+	})
+	return stats
+}
@@ -0,0 +1,59 @@
+package kernel
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChainStats(t *testing.T) {
+	require := require.New(t)
+
+	node := &Node{chains: &chainsMap{m: make(map[crypto.Hash]*Chain)}}
+	for _, seed := range []string{"chain-a", "chain-b"} {
+		id := crypto.Blake3Hash([]byte(seed))
+		node.chains.m[id] = &Chain{
+			node:             node,
+			ChainId:          id,
+			CachePool:        make(chan *CosiAction, 4),
+			finalActionsRing: make(chan *CosiAction, 4),
+			reorder:          newReorderBuffer(),
+			counters:         newChainCounters(),
+			State: &ChainState{
+				CacheRound: &CacheRound{Number: 8},
+				FinalRound: &FinalRound{Number: 7},
+			},
+		}
+	}
+
+	stats := node.ChainsStats()
+	require.Len(stats, 2)
+	require.Negative(bytes.Compare(stats[0].ChainId[:], stats[1].ChainId[:]))
+	require.Equal(uint64(0), stats[0].ActiveAt)
+	require.Len(stats[0].Rejected, len(rejectReasons))
+
+	chain := node.chains.m[stats[1].ChainId]
+	chain.markActive()
+	chain.markConsumed()
+	chain.markConsumed()
+	chain.markRejected(RejectSanity)
+	require.Nil(chain.CachePool.Offer(&CosiAction{}))
+	for range 5 {
+		chain.AppendFinalSnapshot(chain.ChainId, &common.Snapshot{NodeId: chain.ChainId, RoundNumber: 8})
+	}
+
+	cs := chain.Stats()
+	require.Equal(uint64(2), cs.Consumed)
+	require.Equal(uint64(1), cs.Rejected[RejectSanity])
+	require.Equal(uint64(1), cs.Rejected[RejectQueueFull])
+	require.Equal(uint64(0), cs.Rejected[RejectExpired])
+	require.Equal(1, cs.CachePool)
+	require.Equal(4, cs.FinalActions)
+	require.Equal(0, cs.Reorder)
+	require.Equal(uint64(8), cs.CacheRound)
+	require.Equal(uint64(7), cs.FinalRound)
+	require.NotZero(cs.ActiveAt)
+}
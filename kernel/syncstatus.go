@@ -0,0 +1,154 @@
+package kernel
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/kernel/internal/clock"
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/mixin/p2p"
+)
+
+// the rate is measured in the recent window to reflect the current network
+// and disk, and the sync is stalled if no round finalized for a long period,
+// which is much longer than the time to finalize a round even for the very
+// busy chains during the catch up.
+const (
+	syncRateWindow  = 10 * time.Minute
+	syncStallPeriod = 10 * time.Minute
+	syncLogInterval = time.Minute
+)
+
+type ChainSyncStatus struct {
+	ChainId   crypto.Hash `json:"chain"`
+	Local     uint64      `json:"local"`
+	Remote    uint64      `json:"remote"`
+	Requested uint64      `json:"requested"`
+	Verified  uint64      `json:"verified"`
+}
+
+// SyncStatus compares the local final round of each chain with the highest
+// one reported by the consensus peers. The local and remote are the sum of
+// the final round numbers of all chains, and the local one of each chain is
+// capped by the remote one, so the progress is never more than 100.
+type SyncStatus struct {
+	Timestamp  uint64             `json:"timestamp"`
+	Synced     bool               `json:"synced"`
+	Stalled    bool               `json:"stalled"`
+	Local      uint64             `json:"local"`
+	Remote     uint64             `json:"remote"`
+	Progress   float64            `json:"progress"`
+	Rate       float64            `json:"rate"`
+	ETA        uint64             `json:"eta"`
+	ProgressAt uint64             `json:"progress_at"`
+	Chains     []*ChainSyncStatus `json:"chains"`
+}
+
+type syncSample struct {
+	at    time.Time
+	local uint64
+}
+
+type syncTracker struct {
+	samples    []syncSample
+	progressAt time.Time
+	loggedAt   time.Time
+}
+
+func (node *Node) SyncStatus() *SyncStatus {
+	status := node.syncStatus.Load()
+	if status == nil {
+		return &SyncStatus{}
+	}
+	return status
+}
+
+func (node *Node) SyncStatusLoop() {
+	defer close(node.ssc)
+
+	ticker := time.NewTicker(time.Duration(config.SnapshotRoundGap))
+	defer ticker.Stop()
+
+	tracker := &syncTracker{}
+	for {
+		select {
+		case <-node.done:
+			return
+		case <-ticker.C:
+			now := clock.Now()
+			status := tracker.update(now, node.BuildGraph(), node.SyncPoints.RemoteFinals())
+			for _, c := range status.Chains {
+				f := node.frontiers.Get(c.ChainId)
+				c.Requested, c.Verified = f.requested, f.verified
+			}
+			tracker.report(now, node.SyncStatus(), status)
+			node.syncStatus.Store(status)
+			observeSyncStatus(status)
+		}
+	}
+}
+
+func (t *syncTracker) update(now time.Time, local []*p2p.SyncPoint, remote map[crypto.Hash]uint64) *SyncStatus {
+	finals := make(map[crypto.Hash]uint64)
+	for _, p := range local {
+		finals[p.NodeId] = p.Number
+	}
+
+	status := &SyncStatus{Timestamp: uint64(now.UnixNano()), Synced: len(remote) > 0}
+	for id, r := range remote {
+		l := finals[id]
+		status.Chains = append(status.Chains, &ChainSyncStatus{ChainId: id, Local: l, Remote: r})
+		status.Local += min(l, r)
+		status.Remote += r
+		if l+1 < r {
+			status.Synced = false
+		}
+	}
+	slices.SortFunc(status.Chains, func(a, b *ChainSyncStatus) int {
+		return bytes.Compare(a.ChainId[:], b.ChainId[:])
+	})
+	if status.Remote > 0 {
+		status.Progress = float64(status.Local) * 100 / float64(status.Remote)
+	}
+
+	if n := len(t.samples); n == 0 || status.Local > t.samples[n-1].local {
+		t.progressAt = now
+	}
+	t.samples = append(t.samples, syncSample{at: now, local: status.Local})
+	for len(t.samples) > 2 && now.Sub(t.samples[1].at) >= syncRateWindow {
+		t.samples = t.samples[1:]
+	}
+	first, last := t.samples[0], t.samples[len(t.samples)-1]
+	if d := last.at.Sub(first.at); d > 0 && last.local > first.local {
+		status.Rate = float64(last.local-first.local) / d.Seconds()
+	}
+	if remaining := status.Remote - status.Local; !status.Synced && status.Rate > 0 {
+		status.ETA = uint64(float64(remaining) / status.Rate * float64(time.Second))
+	}
+	status.ProgressAt = uint64(t.progressAt.UnixNano())
+	status.Stalled = !status.Synced && status.Remote > 0 && now.Sub(t.progressAt) >= syncStallPeriod
+	return status
+}
+
+func (t *syncTracker) report(now time.Time, old, status *SyncStatus) {
+	if status.Stalled && !old.Stalled {
+		logger.Printw("Sync stalled", "alert", "sync", "local", status.Local,
+			"remote", status.Remote, "since", status.ProgressAt)
+	} else if !status.Stalled && old.Stalled {
+		logger.Printw("Sync resumed", "alert", "sync", "local", status.Local, "remote", status.Remote)
+	}
+	if status.Synced && !old.Synced {
+		logger.Printw("Sync completed", "local", status.Local, "remote", status.Remote)
+	}
+	if status.Synced || status.Remote == 0 || now.Sub(t.loggedAt) < syncLogInterval {
+		return
+	}
+	t.loggedAt = now
+	logger.Printw("Sync progress", "progress", fmt.Sprintf("%.2f%%", status.Progress),
+		"local", status.Local, "remote", status.Remote,
+		"rate", fmt.Sprintf("%.2f", status.Rate), "eta", time.Duration(status.ETA).String())
+}
@@ -0,0 +1,67 @@
+package kernel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/p2p"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncStatus(t *testing.T) {
+	require := require.New(t)
+
+	a := crypto.Blake3Hash([]byte("chain-a"))
+	b := crypto.Blake3Hash([]byte("chain-b"))
+	sm := newSyncMap()
+	sm.SetGraph(crypto.Blake3Hash([]byte("peer-1")), []*p2p.SyncPoint{{NodeId: a, Number: 800}, {NodeId: b, Number: 150}})
+	sm.SetGraph(crypto.Blake3Hash([]byte("peer-2")), []*p2p.SyncPoint{{NodeId: a, Number: 700}, {NodeId: b, Number: 200}})
+	remote := sm.RemoteFinals()
+	require.Equal(map[crypto.Hash]uint64{a: 800, b: 200}, remote)
+
+	tracker := &syncTracker{}
+	now := time.Unix(1700000000, 0)
+	status := tracker.update(now, nil, remote)
+	require.False(status.Synced)
+	require.False(status.Stalled)
+	require.Equal(uint64(0), status.Local)
+	require.Equal(uint64(1000), status.Remote)
+	require.Equal(float64(0), status.Progress)
+	require.Equal(uint64(0), status.ETA)
+	require.Len(status.Chains, 2)
+
+	now = now.Add(time.Minute)
+	local := []*p2p.SyncPoint{{NodeId: a, Number: 200}, {NodeId: b, Number: 300}}
+	status = tracker.update(now, local, remote)
+	require.Equal(uint64(400), status.Local)
+	require.Equal(float64(40), status.Progress)
+	require.InDelta(400.0/60, status.Rate, 0.001)
+	require.Equal(uint64(90*time.Second), status.ETA)
+	require.Equal(uint64(now.UnixNano()), status.ProgressAt)
+
+	progressAt := now
+	now = now.Add(syncStallPeriod - time.Second)
+	status = tracker.update(now, local, remote)
+	require.False(status.Stalled)
+	now = now.Add(time.Second)
+	status = tracker.update(now, local, remote)
+	require.True(status.Stalled)
+	require.Equal(uint64(progressAt.UnixNano()), status.ProgressAt)
+	require.Equal(float64(0), status.Rate)
+
+	now = now.Add(time.Minute)
+	local = []*p2p.SyncPoint{{NodeId: a, Number: 799}, {NodeId: b, Number: 200}}
+	status = tracker.update(now, local, remote)
+	require.True(status.Synced)
+	require.False(status.Stalled)
+	require.Equal(uint64(999), status.Local)
+	require.Equal(uint64(0), status.ETA)
+
+	tracker = &syncTracker{}
+	status = tracker.update(now, local, nil)
+	require.False(status.Synced)
+	require.Equal(float64(0), status.Progress)
+	status = tracker.update(now.Add(syncStallPeriod), local, nil)
+	require.False(status.Stalled)
+}
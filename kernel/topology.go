@@ -10,6 +10,7 @@ import (
 	"github.com/MixinNetwork/mixin/kernel/internal/clock"
 	"github.com/MixinNetwork/mixin/logger"
 	"github.com/MixinNetwork/mixin/storage"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type TopologicalSequence struct {
@@ -41,13 +42,16 @@ type SnapshotWitness struct {
 	Timestamp uint64
 }
 
-func (node *Node) WitnessSnapshot(s *common.SnapshotWithTopologicalOrder) *SnapshotWitness {
+func (node *Node) WitnessSnapshot(s *common.SnapshotWithTopologicalOrder) (*SnapshotWitness, error) {
 	msg := crypto.Blake3Hash(s.VersionedMarshal())
-	sig := node.Signer.PrivateSpendKey.Sign(msg)
+	sig, err := node.signer.Sign(msg)
+	if err != nil {
+		return nil, err
+	}
 	return &SnapshotWitness{
 		Signature: &sig,
 		Timestamp: uint64(clock.Now().UnixNano()),
-	}
+	}, nil
 }
 
 func (node *Node) TopoWrite(s *common.Snapshot, signers []crypto.Hash) *common.SnapshotWithTopologicalOrder {
@@ -71,10 +75,13 @@ func (node *Node) TopoWrite(s *common.Snapshot, signers []crypto.Hash) *common.S
 		Snapshot:         s,
 		TopologicalOrder: node.TopoCounter.seq,
 	}
+	span := traceSnapshot("storage.write", s.Hash, s, s.NodeId)
+	span.SetAttributes(attribute.Int64("snapshot.topology", int64(topo.TopologicalOrder)))
 	err := node.persistStore.WriteSnapshot(topo, signers)
 	if err != nil {
 		panic(err)
 	}
+	span.End()
 	return topo
 }
 
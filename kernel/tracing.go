@@ -0,0 +1,55 @@
+package kernel
+
+import (
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var cosiActionStages = map[int]string{
+	CosiActionSelfEmpty:             "cosi.self_empty",
+	CosiActionSelfCommitment:        "cosi.self_commitment",
+	CosiActionSelfResponse:          "cosi.self_response",
+	CosiActionExternalAnnouncement:  "cosi.external_announcement",
+	CosiActionExternalChallenge:     "cosi.external_challenge",
+	CosiActionFinalization:          "cosi.finalization",
+	CosiActionExternalCommitments:   "cosi.external_commitments",
+	CosiActionSelfFullCommitment:    "cosi.self_full_commitment",
+	CosiActionExternalFullChallenge: "cosi.external_full_challenge",
+}
+
+// traceSnapshot starts the span of a lifecycle stage of the snapshot, the
+// snapshot may be nil when only the hash is known, e.g. the commitments.
+func traceSnapshot(stage string, hash crypto.Hash, s *common.Snapshot, peerId crypto.Hash) trace.Span {
+	attrs := []attribute.KeyValue{attribute.String("peer.id", peerId.String())}
+	if s != nil {
+		attrs = append(attrs,
+			attribute.String("snapshot.node", s.NodeId.String()),
+			attribute.Int64("snapshot.round", int64(s.RoundNumber)))
+	}
+	return tracing.StartSnapshot(stage, hash, attrs...)
+}
+
+func traceCosiAction(m *CosiAction) trace.Span {
+	hash := m.SnapshotHash
+	if !hash.HasValue() && m.Snapshot != nil {
+		hash = m.Snapshot.Hash
+	}
+	if !hash.HasValue() {
+		return nil
+	}
+	return traceSnapshot(cosiActionStages[m.Action], hash, m.Snapshot, m.PeerId)
+}
+
+func traceEnd(span trace.Span, err error) {
+	if span == nil {
+		return
+	}
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
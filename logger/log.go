@@ -1,9 +1,16 @@
 package logger
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"log/slog"
 	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 const (
@@ -13,15 +20,109 @@ const (
 	DEBUG   = 7
 )
 
+const (
+	ModuleKernel  = "kernel"
+	ModuleP2P     = "p2p"
+	ModuleStorage = "storage"
+	ModuleRPC     = "rpc"
+
+	FormatText = "text"
+	FormatJSON = "json"
+
+	modulePackagePrefix = "github.com/MixinNetwork/mixin/"
+)
+
 // FIXME GLOBAL VARIABLES
 
 var (
-	level  int
 	filter *regexp.Regexp
+
+	// the level of the packages out of the modules, e.g. the main package
+	level atomic.Int32
+	// the max level of all, to skip the caller lookup of disabled logs fast
+	maxLevel atomic.Int32
+	levels   = map[string]*atomic.Int32{
+		ModuleKernel:  new(atomic.Int32),
+		ModuleP2P:     new(atomic.Int32),
+		ModuleStorage: new(atomic.Int32),
+		ModuleRPC:     new(atomic.Int32),
+	}
+	levelsMutex sync.Mutex
+
+	jsonOutput atomic.Bool
+	jsonLogger = slog.New(slog.NewJSONHandler(logWriter{}, &slog.HandlerOptions{
+		Level:       slog.Level(-DEBUG),
+		ReplaceAttr: replaceJSONLevel,
+	}))
+	callers sync.Map
 )
 
 func SetLevel(l int) {
-	level = l
+	levelsMutex.Lock()
+	defer levelsMutex.Unlock()
+
+	level.Store(int32(l))
+	for _, ml := range levels {
+		ml.Store(int32(l))
+	}
+	maxLevel.Store(int32(l))
+}
+
+// SetModuleLevel changes the level of a single module, independent of the
+// others, and it is safe to change at runtime.
+func SetModuleLevel(module string, l int) error {
+	levelsMutex.Lock()
+	defer levelsMutex.Unlock()
+
+	ml := levels[module]
+	if ml == nil {
+		return fmt.Errorf("invalid log module %s", module)
+	}
+	ml.Store(int32(l))
+	highest := level.Load()
+	for _, ml := range levels {
+		highest = max(highest, ml.Load())
+	}
+	maxLevel.Store(highest)
+	return nil
+}
+
+// Levels returns the levels of all modules, and the default level of the
+// packages out of the modules.
+func Levels() map[string]int {
+	lm := map[string]int{"default": int(level.Load())}
+	for m, ml := range levels {
+		lm[m] = int(ml.Load())
+	}
+	return lm
+}
+
+func Modules() []string {
+	var modules []string
+	for m := range levels {
+		modules = append(modules, m)
+	}
+	sort.Strings(modules)
+	return modules
+}
+
+func SetFormat(format string) error {
+	switch format {
+	case FormatText:
+		jsonOutput.Store(false)
+	case FormatJSON:
+		jsonOutput.Store(true)
+	default:
+		return fmt.Errorf("invalid log format %s", format)
+	}
+	return nil
+}
+
+func Format() string {
+	if jsonOutput.Load() {
+		return FormatJSON
+	}
+	return FormatText
 }
 
 func SetFilter(pattern string) error {
@@ -38,14 +139,14 @@ func SetFilter(pattern string) error {
 }
 
 func Println(v ...any) {
-	if level >= INFO {
-		log.Println(v...)
+	if module, ok := enabled(INFO, 4); ok {
+		output(INFO, module, strings.TrimSuffix(fmt.Sprintln(v...), "\n"), nil)
 	}
 }
 
 func Printf(format string, v ...any) {
-	if level >= INFO {
-		log.Printf(format, v...)
+	if module, ok := enabled(INFO, 4); ok {
+		output(INFO, module, fmt.Sprintf(format, v...), nil)
 	}
 }
 
@@ -57,15 +158,139 @@ func Debugf(format string, v ...any) {
 	printfAtLevel(DEBUG, format, v...)
 }
 
+// Printw logs the message with the key value pairs as structured fields,
+// e.g. Printw("round finalized", "node", id, "round", number).
+func Printw(msg string, kv ...any) {
+	printwAtLevel(INFO, msg, kv...)
+}
+
+func Verbosew(msg string, kv ...any) {
+	printwAtLevel(VERBOSE, msg, kv...)
+}
+
+func Debugw(msg string, kv ...any) {
+	printwAtLevel(DEBUG, msg, kv...)
+}
+
 func printfAtLevel(l int, format string, v ...any) {
-	if level < l {
+	module, ok := enabled(l, 5)
+	if !ok {
 		return
 	}
 	out := filterOutput(format, v...)
 	if out == "" {
 		return
 	}
-	log.Print(out)
+	output(l, module, out, nil)
+}
+
+func printwAtLevel(l int, msg string, kv ...any) {
+	module, ok := enabled(l, 5)
+	if !ok {
+		return
+	}
+	if filterOutput("%s %s", msg, formatFields(kv)) == "" {
+		return
+	}
+	output(l, module, msg, kv)
+}
+
+// the skip of runtime.Callers to the caller of the logger, e.g. 5 for
+// Callers < callerModule < enabled < printfAtLevel < Verbosef < caller
+func enabled(l, skip int) (string, bool) {
+	if int32(l) > maxLevel.Load() {
+		return "", false
+	}
+	module := callerModule(skip)
+	if ml := levels[module]; ml != nil {
+		return module, int32(l) <= ml.Load()
+	}
+	return module, int32(l) <= level.Load()
+}
+
+func callerModule(skip int) string {
+	var pcs [1]uintptr
+	if runtime.Callers(skip, pcs[:]) < 1 {
+		return ""
+	}
+	if m, found := callers.Load(pcs[0]); found {
+		return m.(string)
+	}
+	fn := runtime.FuncForPC(pcs[0])
+	if fn == nil {
+		return ""
+	}
+	module := packageModule(fn.Name())
+	callers.Store(pcs[0], module)
+	return module
+}
+
+// the function name is github.com/MixinNetwork/mixin/kernel.(*Chain).loop
+func packageModule(fn string) string {
+	name, found := strings.CutPrefix(fn, modulePackagePrefix)
+	if !found {
+		return ""
+	}
+	end := strings.IndexAny(name, "/.")
+	if end < 0 {
+		return ""
+	}
+	if levels[name[:end]] == nil {
+		return ""
+	}
+	return name[:end]
+}
+
+func output(l int, module, msg string, kv []any) {
+	msg = strings.TrimSuffix(msg, "\n")
+	if !jsonOutput.Load() {
+		if len(kv) > 0 {
+			msg = msg + " " + formatFields(kv)
+		}
+		log.Print(msg)
+		return
+	}
+	if module == "" {
+		module = "default"
+	}
+	attrs := []any{slog.String("module", module)}
+	jsonLogger.Log(context.Background(), slog.Level(-l), msg, append(attrs, kv...)...)
+}
+
+// logWriter follows the output of the standard logger, which may be
+// redirected to the log shipper as well
+type logWriter struct{}
+
+func (logWriter) Write(p []byte) (int, error) {
+	return log.Writer().Write(p)
+}
+
+func formatFields(kv []any) string {
+	var fields []string
+	for i := 0; i < len(kv); i += 2 {
+		if i+1 < len(kv) {
+			fields = append(fields, fmt.Sprintf("%v=%v", kv[i], kv[i+1]))
+		} else {
+			fields = append(fields, fmt.Sprintf("%v=", kv[i]))
+		}
+	}
+	return strings.Join(fields, " ")
+}
+
+func replaceJSONLevel(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) != 0 || a.Key != slog.LevelKey {
+		return a
+	}
+	switch -a.Value.Any().(slog.Level) {
+	case ERROR:
+		return slog.String(a.Key, "ERROR")
+	case INFO:
+		return slog.String(a.Key, "INFO")
+	case VERBOSE:
+		return slog.String(a.Key, "VERBOSE")
+	default:
+		return slog.String(a.Key, "DEBUG")
+	}
 }
 
 func filterOutput(format string, v ...any) string {
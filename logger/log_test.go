@@ -46,6 +46,53 @@ func TestLogger(t *testing.T) {
 	out = filterOutput("ethereum or bitcoin %d", time.Now().UnixNano())
 	require.NotContains(out, "mixin")
 
-	level = 0
+	SetLevel(0)
 	filter = nil
 }
+
+func TestModuleLevels(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal(ModuleKernel, packageModule("github.com/MixinNetwork/mixin/kernel.(*Chain).loop"))
+	require.Equal(ModuleRPC, packageModule("github.com/MixinNetwork/mixin/rpc/internal/server.(*R).ServeHTTP"))
+	require.Equal(ModuleStorage, packageModule("github.com/MixinNetwork/mixin/storage.readRound"))
+	require.Equal("", packageModule("main.kernelCmd"))
+	require.Equal("", packageModule("github.com/MixinNetwork/mixin/common.ReadGenesis"))
+	require.Equal("", packageModule("github.com/MixinNetwork/mixin/logger.TestModuleLevels"))
+	require.Equal([]string{ModuleKernel, ModuleP2P, ModuleRPC, ModuleStorage}, Modules())
+
+	SetLevel(INFO)
+	_, ok := enabled(VERBOSE, 3)
+	require.False(ok)
+	err := SetModuleLevel(ModuleP2P, DEBUG)
+	require.Nil(err)
+	err = SetModuleLevel("main", DEBUG)
+	require.NotNil(err)
+	require.Equal(map[string]int{
+		"default":     INFO,
+		ModuleKernel:  INFO,
+		ModuleP2P:     DEBUG,
+		ModuleStorage: INFO,
+		ModuleRPC:     INFO,
+	}, Levels())
+	require.Equal(int32(DEBUG), maxLevel.Load())
+	module, ok := enabled(VERBOSE, 3)
+	require.Equal("", module)
+	require.False(ok)
+	module, ok = enabled(INFO, 3)
+	require.Equal("", module)
+	require.True(ok)
+
+	require.Equal(FormatText, Format())
+	err = SetFormat(FormatJSON)
+	require.Nil(err)
+	require.Equal(FormatJSON, Format())
+	err = SetFormat("xml")
+	require.NotNil(err)
+	err = SetFormat(FormatText)
+	require.Nil(err)
+	require.Equal("node=abc round=12 gap=", formatFields([]any{"node", "abc", "round", 12, "gap"}))
+
+	SetLevel(0)
+	require.Equal(int32(0), maxLevel.Load())
+}
@@ -0,0 +1,169 @@
+package logship
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+const (
+	BundleBufferMaxSize = 4 * 1024 * 1024
+	BundleContentType   = "application/vnd.mixin.logship"
+	BundleNodeHeader    = "X-Mixin-Node"
+)
+
+type Bundle struct {
+	NodeId    crypto.Hash `json:"node"`
+	Timestamp uint64      `json:"timestamp"`
+	Dropped   uint64      `json:"dropped"`
+	Logs      string      `json:"logs"`
+	Metrics   any         `json:"metrics"`
+}
+
+// the shipper buffers the log output and sends it to the collector in
+// bundles together with the metrics, each bundle is sealed with the
+// operator key before leaving the node, so the collector and everything
+// between only see ciphertext unless they hold the key.
+type Shipper struct {
+	collector string
+	nodeId    crypto.Hash
+	aead      cipher.AEAD
+	metrics   func() any
+	period    time.Duration
+	client    *http.Client
+
+	mutex   sync.Mutex
+	buffer  []byte
+	dropped uint64
+}
+
+func NewShipper(collector, key string, nodeId crypto.Hash, period time.Duration, metrics func() any) (*Shipper, error) {
+	aead, err := parseKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if period < time.Second {
+		return nil, fmt.Errorf("invalid log ship period %s", period)
+	}
+	return &Shipper{
+		collector: collector,
+		nodeId:    nodeId,
+		aead:      aead,
+		metrics:   metrics,
+		period:    period,
+		client:    &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+func (s *Shipper) Write(p []byte) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.buffer = append(s.buffer, p...)
+	if over := len(s.buffer) - BundleBufferMaxSize; over > 0 {
+		s.buffer = s.buffer[over:]
+		s.dropped += uint64(over)
+	}
+	return len(p), nil
+}
+
+func (s *Shipper) Loop() {
+	for {
+		time.Sleep(s.period)
+		err := s.Ship()
+		if err != nil {
+			logger.Printf("logship.Ship(%s) => %v\n", s.collector, err)
+		}
+	}
+}
+
+func (s *Shipper) Ship() error {
+	s.mutex.Lock()
+	b := &Bundle{
+		NodeId:    s.nodeId,
+		Timestamp: uint64(time.Now().UnixNano()),
+		Dropped:   s.dropped,
+		Logs:      string(s.buffer),
+	}
+	s.buffer, s.dropped = nil, 0
+	s.mutex.Unlock()
+
+	if s.metrics != nil {
+		b.Metrics = s.metrics()
+	}
+	sealed, err := SealBundle(s.aead, b)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", s.collector, bytes.NewReader(sealed))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", BundleContentType)
+	req.Header.Set(BundleNodeHeader, s.nodeId.String())
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("collector response status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func SealBundle(aead cipher.AEAD, b *Bundle) ([]byte, error) {
+	plain, err := json.Marshal(b)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	crypto.ReadRand(nonce)
+	return aead.Seal(nonce, nonce, plain, b.NodeId[:]), nil
+}
+
+func OpenBundle(key string, nodeId crypto.Hash, sealed []byte) (*Bundle, error) {
+	aead, err := parseKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize()+aead.Overhead() {
+		return nil, fmt.Errorf("invalid bundle size %d", len(sealed))
+	}
+	nonce, data := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, data, nodeId[:])
+	if err != nil {
+		return nil, err
+	}
+	var b Bundle
+	err = json.Unmarshal(plain, &b)
+	if err != nil {
+		return nil, err
+	}
+	if b.NodeId != nodeId {
+		return nil, fmt.Errorf("bundle node mismatch %s %s", b.NodeId, nodeId)
+	}
+	return &b, nil
+}
+
+func parseKey(key string) (cipher.AEAD, error) {
+	secret, err := hex.DecodeString(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(secret) != chacha20poly1305.KeySize {
+		return nil, fmt.Errorf("invalid log ship key size %d", len(secret))
+	}
+	return chacha20poly1305.NewX(secret)
+}
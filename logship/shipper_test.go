@@ -0,0 +1,62 @@
+package logship
+
+import (
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShipper(t *testing.T) {
+	require := require.New(t)
+
+	secret := make([]byte, 32)
+	crypto.ReadRand(secret)
+	key := hex.EncodeToString(secret)
+	nodeId := crypto.Blake3Hash([]byte("node"))
+
+	bundles := make(chan *Bundle, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(BundleContentType, r.Header.Get("Content-Type"))
+		require.Equal(nodeId.String(), r.Header.Get(BundleNodeHeader))
+		sealed, err := io.ReadAll(r.Body)
+		require.Nil(err)
+		require.NotContains(string(sealed), "hello mixin")
+		b, err := OpenBundle(key, nodeId, sealed)
+		require.Nil(err)
+		_, err = OpenBundle(key, crypto.Blake3Hash([]byte("other")), sealed)
+		require.NotNil(err)
+		bundles <- b
+	}))
+	defer server.Close()
+
+	_, err := NewShipper(server.URL, key[:32], nodeId, time.Minute, nil)
+	require.NotNil(err)
+	shipper, err := NewShipper(server.URL, key, nodeId, time.Minute, func() any {
+		return map[string]int{"peers": 3}
+	})
+	require.Nil(err)
+
+	n, err := shipper.Write([]byte("hello mixin\n"))
+	require.Nil(err)
+	require.Equal(12, n)
+	err = shipper.Ship()
+	require.Nil(err)
+	b := <-bundles
+	require.Equal(nodeId, b.NodeId)
+	require.Equal("hello mixin\n", b.Logs)
+	require.Equal(uint64(0), b.Dropped)
+	require.Equal(map[string]any{"peers": float64(3)}, b.Metrics)
+
+	shipper.Write(make([]byte, BundleBufferMaxSize+10))
+	err = shipper.Ship()
+	require.Nil(err)
+	b = <-bundles
+	require.Equal(uint64(10), b.Dropped)
+	require.Len(b.Logs, BundleBufferMaxSize)
+}
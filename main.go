@@ -1,18 +1,29 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
-	_ "net/http/pprof"
 	"os"
 	"runtime"
+	"strings"
+	"time"
 
 	"github.com/MixinNetwork/mixin/common"
 	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/diagnostics"
+	"github.com/MixinNetwork/mixin/eventsink"
 	"github.com/MixinNetwork/mixin/kernel"
 	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/mixin/logship"
+	"github.com/MixinNetwork/mixin/replica"
 	"github.com/MixinNetwork/mixin/rpc"
 	"github.com/MixinNetwork/mixin/storage"
+	"github.com/MixinNetwork/mixin/tracing"
+	"github.com/MixinNetwork/mixin/webhook"
 	"github.com/dgraph-io/ristretto/v2"
 	"github.com/urfave/cli/v2"
 )
@@ -74,6 +85,120 @@ func main() {
 					Name:  "filter",
 					Usage: "the RE2 regex pattern to filter log",
 				},
+				&cli.StringFlag{
+					Name:  "log-format",
+					Value: logger.FormatText,
+					Usage: "the log format, text or json",
+				},
+				&cli.StringFlag{
+					Name:  "log-modules",
+					Usage: "the levels of the log modules, e.g. kernel=3,p2p=7",
+				},
+				&cli.BoolFlag{
+					Name:  "recovery-confirm",
+					Usage: "refuse to start instead of repairing the storage inconsistencies",
+				},
+				&cli.StringFlag{
+					Name:    "network",
+					EnvVars: []string{"MIXIN_NETWORK"},
+					Usage:   "the network profile in the networks section of the config.toml",
+				},
+				&cli.StringSliceFlag{
+					Name:  "set",
+					Usage: "override the config.toml value and its MIXIN_ environment variable, e.g. --set p2p.port=5851",
+				},
+			},
+		},
+		{
+			Name:   "doctor",
+			Usage:  "Check the config, signer, store, seeds and clock before starting the kernel",
+			Action: doctorCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:    "dir",
+					Aliases: []string{"d"},
+					Usage:   "the data directory",
+				},
+				&cli.StringFlag{
+					Name:    "network",
+					EnvVars: []string{"MIXIN_NETWORK"},
+					Usage:   "the network profile in the networks section of the config.toml",
+				},
+				&cli.StringSliceFlag{
+					Name:  "set",
+					Usage: "override the config.toml value and its MIXIN_ environment variable, e.g. --set p2p.port=5851",
+				},
+				&cli.StringFlag{
+					Name:  "ntp",
+					Value: "pool.ntp.org:123",
+					Usage: "the NTP server to check the clock accuracy, empty to skip",
+				},
+				&cli.IntFlag{
+					Name:  "timeout",
+					Value: 5,
+					Usage: "the timeout in seconds to connect the seeds and the NTP server",
+				},
+			},
+		},
+		{
+			Name:   "encryptsignerkey",
+			Usage:  "Encrypt the signer key with a passphrase for signer-key-encrypted",
+			Action: encryptSignerKeyCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "key",
+					Usage: "the private signer key to encrypt",
+				},
+			},
+		},
+		{
+			Name:   "splitsignerkey",
+			Usage:  "Split the signer key into shares for the threshold co-signers",
+			Action: splitSignerKeyCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "key",
+					Usage: "the private signer key to split",
+				},
+				&cli.IntFlag{
+					Name:  "threshold",
+					Value: 2,
+					Usage: "the number of co-signers required to sign",
+				},
+				&cli.IntFlag{
+					Name:  "total",
+					Value: 3,
+					Usage: "the total number of co-signers",
+				},
+			},
+		},
+		{
+			Name:   "remotesigner",
+			Usage:  "Start the remote signer service for the kernel signer key",
+			Action: remoteSignerCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:    "dir",
+					Aliases: []string{"d"},
+					Usage:   "the directory of config.toml with the signer key",
+				},
+				&cli.StringFlag{
+					Name:  "listen",
+					Value: ":7860",
+					Usage: "the address to listen",
+				},
+				&cli.StringFlag{
+					Name:  "cert",
+					Usage: "the server certificate file",
+				},
+				&cli.StringFlag{
+					Name:  "key",
+					Usage: "the server private key file",
+				},
+				&cli.StringFlag{
+					Name:  "ca",
+					Usage: "the ca file to verify the client certificates",
+				},
 			},
 		},
 		{
@@ -81,6 +206,84 @@ func main() {
 			Usage:  "Setup the test nodes and genesis",
 			Action: setupTestNetCmd,
 		},
+		{
+			Name:   "devnet",
+			Usage:  "Run a local devnet of in-process kernel nodes on the loopback",
+			Action: devnetCmd,
+			Flags: []cli.Flag{
+				&cli.IntFlag{
+					Name:    "nodes",
+					Aliases: []string{"n"},
+					Value:   config.KernelMinimumNodesCount,
+					Usage:   "the number of the genesis nodes",
+				},
+				&cli.StringFlag{
+					Name:  "dir",
+					Value: "/tmp/mixin-devnet",
+					Usage: "the directory of the devnet genesis and nodes, reused if exists",
+				},
+				&cli.IntFlag{
+					Name:  "port",
+					Value: 17000,
+					Usage: "the base port, the p2p ports after it and the rpc ports after it plus 1000",
+				},
+				&cli.Int64Flag{
+					Name:  "gap",
+					Value: 500,
+					Usage: "the accelerated snapshot round gap in milliseconds of the new devnet genesis",
+				},
+				&cli.BoolFlag{
+					Name:  "dev",
+					Usage: "create a dev genesis allowing a single node to finalize snapshots alone",
+				},
+				&cli.IntFlag{
+					Name:  "log",
+					Value: logger.INFO,
+					Usage: "the log level",
+				},
+			},
+		},
+		{
+			Name:  "bench",
+			Usage: "Benchmark a devnet with the synthetic traffic",
+			Subcommands: []*cli.Command{
+				{
+					Name:   "send",
+					Usage:  "Send the self transfers at the rate and measure the latency from submitted to finalized",
+					Action: benchSendCmd,
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:  "custodian",
+							Usage: "the devnet custodian private view and spend key hex to deposit the bench account",
+						},
+						&cli.IntFlag{
+							Name:  "rate",
+							Value: 20,
+							Usage: "the target transfers per second",
+						},
+						&cli.IntFlag{
+							Name:  "outputs",
+							Value: 100,
+							Usage: "the outputs of the bench account, which limit the transfers in flight",
+						},
+						&cli.DurationFlag{
+							Name:  "duration",
+							Value: time.Minute,
+							Usage: "the duration to send the transfers",
+						},
+						&cli.DurationFlag{
+							Name:  "timeout",
+							Value: 30 * time.Second,
+							Usage: "the timeout to wait for the deposit, the split and the last transfers finalized",
+						},
+						&cli.DurationFlag{
+							Name:  "max-p99",
+							Usage: "fail if the p99 latency exceeds it, for the release checks",
+						},
+					},
+				},
+			},
+		},
 		{
 			Name:   "createaddress",
 			Usage:  "Create a new Mixin address",
@@ -106,6 +309,35 @@ func main() {
 					Name:  "suffix",
 					Usage: "a string suffix the final address should have",
 				},
+				&cli.BoolFlag{
+					Name:  "mnemonic",
+					Usage: "whether derive the keys from a new 24 words BIP39 mnemonic",
+				},
+				&cli.StringFlag{
+					Name:  "path",
+					Value: common.MnemonicPathDefault,
+					Usage: "the hardened derivation path of the mnemonic",
+				},
+			},
+		},
+		{
+			Name:   "recoveraddress",
+			Usage:  "Recover the address keys from the BIP39 mnemonic",
+			Action: recoverAddressCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "mnemonic",
+					Usage: "the 24 words mnemonic, read from the standard input if empty",
+				},
+				&cli.StringFlag{
+					Name:  "path",
+					Value: common.MnemonicPathDefault,
+					Usage: "the hardened derivation path of the mnemonic",
+				},
+				&cli.BoolFlag{
+					Name:  "public",
+					Usage: "whether the address marks all transactions public",
+				},
 			},
 		},
 		{
@@ -120,6 +352,108 @@ func main() {
 				},
 			},
 		},
+		{
+			Name:   "derivesequenceaddress",
+			Usage:  "Derive the deposit addresses of a sequence from the master address",
+			Action: deriveSequenceAddressCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:    "address",
+					Aliases: []string{"a"},
+					Usage:   "the master address",
+				},
+				&cli.StringFlag{
+					Name:  "view",
+					Usage: "the master private view key",
+				},
+				&cli.StringFlag{
+					Name:  "spend",
+					Usage: "the master private spend key, optional to derive the private spend keys",
+				},
+				&cli.Uint64Flag{
+					Name:  "start",
+					Usage: "the first sequence to derive",
+				},
+				&cli.Uint64Flag{
+					Name:  "count",
+					Value: 1,
+					Usage: "the number of addresses to derive",
+				},
+			},
+		},
+		{
+			Name:   "derivesubaddress",
+			Usage:  "Derive the deposit address of a payment id from the master address",
+			Action: deriveSubaddressCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:    "address",
+					Aliases: []string{"a"},
+					Usage:   "the master address",
+				},
+				&cli.StringFlag{
+					Name:  "view",
+					Usage: "the master private view key",
+				},
+				&cli.StringFlag{
+					Name:  "spend",
+					Usage: "the master private spend key, optional to derive the private spend key",
+				},
+				&cli.StringSliceFlag{
+					Name:  "payment",
+					Usage: "the payment id, e.g. the customer id, repeat to derive multiple addresses",
+				},
+			},
+		},
+		{
+			Name:   "signmessage",
+			Usage:  "Sign an arbitrary message with the address keys",
+			Action: signMessageCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "view",
+					Usage: "the private view key of the address",
+				},
+				&cli.StringFlag{
+					Name:  "spend",
+					Usage: "the private spend key of the address",
+				},
+				&cli.StringFlag{
+					Name:    "message",
+					Aliases: []string{"m"},
+					Usage:   "the message to sign",
+				},
+				&cli.BoolFlag{
+					Name:  "hex",
+					Usage: "whether the message is hex encoded",
+				},
+			},
+		},
+		{
+			Name:   "verifymessage",
+			Usage:  "Verify the message signature of an address",
+			Action: verifyMessageCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:    "address",
+					Aliases: []string{"a"},
+					Usage:   "the Mixin Kernel address",
+				},
+				&cli.StringFlag{
+					Name:    "message",
+					Aliases: []string{"m"},
+					Usage:   "the signed message",
+				},
+				&cli.BoolFlag{
+					Name:  "hex",
+					Usage: "whether the message is hex encoded",
+				},
+				&cli.StringFlag{
+					Name:  "signature",
+					Usage: "the signature `HEX` of signmessage",
+				},
+			},
+		},
 		{
 			Name:   "decodesignature",
 			Usage:  "Decode a signature",
@@ -197,6 +531,176 @@ func main() {
 				},
 			},
 		},
+		{
+			Name:   "replaygraphchain",
+			Usage:  "Validate and derive the indexes again for a single node chain",
+			Action: replayGraphChainCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "id",
+					Usage: "the node chain id",
+				},
+				&cli.BoolFlag{
+					Name:  "repair",
+					Usage: "write the derived indexes for the invalid entries",
+				},
+			},
+		},
+		{
+			Name:   "exportconsensusevidence",
+			Usage:  "Export the anonymized consensus datasets of round timing, signer participation and reference delay",
+			Action: exportConsensusEvidenceCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "out",
+					Usage: "the directory to write the dataset files",
+				},
+				&cli.Uint64Flag{
+					Name:  "offset",
+					Usage: "the first snapshot topology to export",
+				},
+				&cli.Uint64Flag{
+					Name:  "count",
+					Value: 100000,
+					Usage: "the maximum number of snapshots to export",
+				},
+				&cli.StringFlag{
+					Name:  "salt",
+					Usage: "the hex salt for the chain pseudonyms, random if empty",
+				},
+			},
+		},
+		{
+			Name:   "export",
+			Usage:  "Export the finalized transactions and outputs as CSV tables for analytics",
+			Action: exportTransactionsCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "out",
+					Usage: "the directory to write the table files",
+				},
+				&cli.Uint64Flag{
+					Name:  "since-topology",
+					Usage: "the first snapshot topology to export",
+				},
+				&cli.Uint64Flag{
+					Name:  "count",
+					Value: 100000,
+					Usage: "the maximum number of snapshots to export",
+				},
+			},
+		},
+		{
+			Name:   "graph",
+			Usage:  "Export the round graph of a node chain with the self and external references",
+			Action: exportRoundGraphCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "node",
+					Usage: "the node id",
+				},
+				&cli.StringFlag{
+					Name:  "rounds",
+					Usage: "the round numbers range as from..to",
+				},
+				&cli.StringFlag{
+					Name:  "format",
+					Value: "dot",
+					Usage: "the output format, only dot for GraphViz now",
+				},
+			},
+		},
+		{
+			Name:      "diffgraph",
+			Usage:     "Compare the finalized snapshots of two stopped data directories and report the first divergence",
+			ArgsUsage: "dirA dirB",
+			Action:    diffGraphCmd,
+			Flags: []cli.Flag{
+				&cli.Uint64Flag{
+					Name:  "offset",
+					Usage: "the topology to begin with",
+				},
+			},
+		},
+		{
+			Name:  "dumpstore",
+			Usage: "Inspect the data directory of a stopped kernel without booting it",
+			Subcommands: []*cli.Command{
+				{
+					Name:   "prefixes",
+					Usage:  "List the key prefixes with the keys count and values size",
+					Action: dumpStorePrefixesCmd,
+				},
+				{
+					Name:   "snapshot",
+					Usage:  "Print the snapshot by hash",
+					Action: dumpStoreSnapshotCmd,
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:  "hash",
+							Usage: "the snapshot hash",
+						},
+					},
+				},
+				{
+					Name:   "transaction",
+					Usage:  "Print the transaction by hash, finalized or in the cache",
+					Action: dumpStoreTransactionCmd,
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:  "hash",
+							Usage: "the transaction hash",
+						},
+					},
+				},
+				{
+					Name:   "heads",
+					Usage:  "Print the head round of all node chains",
+					Action: dumpStoreHeadsCmd,
+				},
+				{
+					Name:   "verify",
+					Usage:  "Verify the final rounds range of a node chain",
+					Action: dumpStoreVerifyCmd,
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:  "node",
+							Usage: "the node id",
+						},
+						&cli.StringFlag{
+							Name:  "rounds",
+							Usage: "the round numbers range as from..to",
+						},
+					},
+				},
+			},
+		},
+		{
+			Name:   "rebuildtimestampindex",
+			Usage:  "Rebuild the timestamp to topology index for the finalized snapshots",
+			Action: rebuildTimestampIndex,
+		},
+		{
+			Name:   "rebuildassetflows",
+			Usage:  "Rebuild the deposits and withdrawals index of all assets from the genesis",
+			Action: rebuildAssetFlows,
+		},
+		{
+			Name:   "rebuildstateindex",
+			Usage:  "Rebuild the state checkpoint deltas of all snapshots from the genesis",
+			Action: rebuildStateIndex,
+		},
+		{
+			Name:   "repairstore",
+			Usage:  "Check and repair the storage inconsistencies left by a power loss",
+			Action: repairStoreCmd,
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:  "dry",
+					Usage: "only report the inconsistencies without repairing",
+				},
+			},
+		},
 		{
 			Name:   "buildrawtransaction",
 			Usage:  "Build a script raw transaction",
@@ -230,48 +734,220 @@ func main() {
 					Name:  "seed",
 					Usage: "the mask seed to hide the recipient public key",
 				},
-			},
-		},
-		{
-			Name:   "signrawtransaction",
-			Usage:  "Sign a JSON encoded transaction",
-			Action: signTransactionCmd,
-			Flags: []cli.Flag{
 				&cli.StringFlag{
-					Name:  "raw",
-					Usage: "the JSON encoded raw transaction",
+					Name:  "signer",
+					Value: "key",
+					Usage: "the spend key signer, only the key signer with --spend for now",
 				},
-				&cli.StringSliceFlag{
-					Name:  "key",
-					Usage: "the private key to sign the raw transaction",
+				&cli.BoolFlag{
+					Name:  "offline",
+					Usage: "build an unsigned JSON raw transaction with only the view key",
 				},
 				&cli.StringFlag{
-					Name:  "seed",
-					Usage: "the mask seed to hide the recipient public key",
+					Name:  "address",
+					Usage: "the watch-only address to spend from, required with --offline",
 				},
 			},
 		},
 		{
-			Name:   "sendrawtransaction",
-			Usage:  "Broadcast a hex encoded signed raw transaction",
-			Action: sendTransactionCmd,
+			Name:   "scanoutputs",
+			Usage:  "Scan unspent outputs of an address with only the private view key",
+			Action: scanOutputsCmd,
 			Flags: []cli.Flag{
 				&cli.StringFlag{
-					Name:  "raw",
-					Usage: "the hex encoded signed raw transaction",
+					Name:  "address",
+					Usage: "the address to scan",
 				},
-			},
-		},
-		{
-			Name:   "decoderawtransaction",
-			Usage:  "Decode a raw transaction as JSON",
-			Action: decodeTransactionCmd,
-			Flags: []cli.Flag{
 				&cli.StringFlag{
-					Name:  "raw",
-					Usage: "the JSON encoded raw transaction",
+					Name:  "view",
+					Usage: "the private view key of the address",
 				},
-			},
+				&cli.Uint64Flag{
+					Name:    "since",
+					Aliases: []string{"s"},
+					Value:   0,
+					Usage:   "the topological order to begin with",
+				},
+				&cli.Uint64Flag{
+					Name:    "count",
+					Aliases: []string{"c"},
+					Value:   100,
+					Usage:   "the up limit of the scanned snapshots",
+				},
+			},
+		},
+		{
+			Name:   "buildsweeptransaction",
+			Usage:  "Build a JSON raw transaction to sweep all unspent outputs of a view-key wallet",
+			Action: buildSweepTransactionCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "address",
+					Usage: "the address to sweep",
+				},
+				&cli.StringFlag{
+					Name:  "view",
+					Usage: "the private view key of the address",
+				},
+				&cli.StringFlag{
+					Name:  "asset",
+					Usage: "the asset id to sweep",
+				},
+				&cli.StringFlag{
+					Name:  "destination",
+					Usage: "the destination address",
+				},
+				&cli.Uint64Flag{
+					Name:    "since",
+					Aliases: []string{"s"},
+					Value:   0,
+					Usage:   "the topological order to begin with",
+				},
+				&cli.Uint64Flag{
+					Name:    "count",
+					Aliases: []string{"c"},
+					Value:   500,
+					Usage:   "the up limit of the scanned snapshots",
+				},
+				&cli.Uint64Flag{
+					Name:  "split-count",
+					Usage: "the maximum number of outputs with the split size",
+				},
+				&cli.StringFlag{
+					Name:  "split-size",
+					Value: "0",
+					Usage: "the amount of each split output",
+				},
+			},
+		},
+		{
+			Name:   "signrawtransaction",
+			Usage:  "Sign a JSON encoded transaction",
+			Action: signTransactionCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "raw",
+					Usage: "the JSON encoded raw transaction",
+				},
+				&cli.StringSliceFlag{
+					Name:  "key",
+					Usage: "the private key to sign the raw transaction",
+				},
+				&cli.StringFlag{
+					Name:  "seed",
+					Usage: "the mask seed to hide the recipient public key",
+				},
+				&cli.BoolFlag{
+					Name:  "offline",
+					Usage: "sign with only the input keys embedded in the raw transaction",
+				},
+			},
+		},
+		{
+			Name:   "buildbatchtransfer",
+			Usage:  "Build the JSON raw transactions paying many recipients for signrawtransaction",
+			Action: buildBatchTransferCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "asset",
+					Usage: "the asset id",
+				},
+				&cli.StringSliceFlag{
+					Name:  "input",
+					Usage: "the unspent output to spend as hash:index:amount",
+				},
+				&cli.StringFlag{
+					Name:  "account",
+					Usage: "spend the unspent outputs of the account indexed by the node wallet scanner",
+				},
+				&cli.StringFlag{
+					Name:  "strategy",
+					Usage: "the coin selection, largest-first, branch-and-bound or consolidate, or the inputs in order if empty",
+				},
+				&cli.StringFlag{
+					Name:  "recipients",
+					Usage: "the file of recipients with one address,amount per line",
+				},
+				&cli.StringFlag{
+					Name:  "change",
+					Usage: "the change address",
+				},
+				&cli.StringFlag{
+					Name:  "extra",
+					Usage: "the hex extra of each transaction",
+				},
+				&cli.StringFlag{
+					Name:  "seed",
+					Usage: "the mask seed to hide the recipient public keys",
+				},
+			},
+		},
+		{
+			Name:   "buildescrowscript",
+			Usage:  "Build a refundable escrow script, use it with the receiver accounts followed by the sender accounts",
+			Action: buildEscrowScriptCmd,
+			Flags: []cli.Flag{
+				&cli.IntFlag{
+					Name:  "receivers",
+					Value: 1,
+					Usage: "the number of the receiver accounts",
+				},
+				&cli.IntFlag{
+					Name:  "receiver-threshold",
+					Value: 1,
+					Usage: "the receiver signatures required with the secret before the deadline",
+				},
+				&cli.IntFlag{
+					Name:  "sender-threshold",
+					Value: 1,
+					Usage: "the sender signatures required to refund at or after the deadline",
+				},
+				&cli.StringFlag{
+					Name:  "deadline",
+					Usage: "the RFC3339 time when the sender is able to refund",
+				},
+				&cli.StringFlag{
+					Name:  "secret",
+					Usage: "the hex secret to unlock the receiver stage, random if empty",
+				},
+			},
+		},
+		{
+			Name:   "sendrawtransaction",
+			Usage:  "Broadcast a hex encoded signed raw transaction",
+			Action: sendTransactionCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "raw",
+					Usage: "the hex encoded signed raw transaction",
+				},
+				&cli.StringFlag{
+					Name:  "key",
+					Usage: "the idempotency key to retry the submission safely",
+				},
+			},
+		},
+		{
+			Name:   "decode",
+			Usage:  "Decode any transaction or snapshot as annotated JSON",
+			Action: decodeCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "payload",
+					Usage: "the hex or base64 encoded transaction or snapshot",
+				},
+			},
+		},
+		{
+			Name:   "decoderawtransaction",
+			Usage:  "Decode a raw transaction as JSON",
+			Action: decodeTransactionCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "raw",
+					Usage: "the JSON encoded raw transaction",
+				},
+			},
 		},
 		{
 			Name:   "signcustodiandeposit",
@@ -327,7 +1003,48 @@ func main() {
 				},
 				&cli.StringFlag{
 					Name:  "signer",
-					Usage: "the signer address",
+					Value: "key",
+					Usage: "the spend key signer, only the key signer with --spend for now",
+				},
+				&cli.StringFlag{
+					Name:  "signer-address",
+					Usage: "the node signer address, the --signer takes the address if not set",
+				},
+				&cli.StringFlag{
+					Name:  "payee",
+					Usage: "the payee address",
+				},
+				&cli.StringFlag{
+					Name:  "input",
+					Usage: "the input transaction hash",
+				},
+				&cli.StringFlag{
+					Name:  "amount",
+					Usage: "the input amount",
+				},
+			},
+		},
+		{
+			Name:   "pledgenode",
+			Usage:  "Check the pledging slot, build, sign and send the transaction to pledge a node",
+			Action: pledgeNodeSendCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "view",
+					Usage: "the private view key to sign the transaction",
+				},
+				&cli.StringFlag{
+					Name:  "spend",
+					Usage: "the private spend key to sign the transaction",
+				},
+				&cli.StringFlag{
+					Name:  "signer",
+					Value: "key",
+					Usage: "the spend key signer, only the key signer with --spend for now",
+				},
+				&cli.StringFlag{
+					Name:  "signer-address",
+					Usage: "the node signer address, the --signer takes the address if not set",
 				},
 				&cli.StringFlag{
 					Name:  "payee",
@@ -341,6 +1058,108 @@ func main() {
 					Name:  "amount",
 					Usage: "the input amount",
 				},
+				&cli.BoolFlag{
+					Name:  "send",
+					Usage: "send the transaction instead of printing it",
+				},
+				&cli.BoolFlag{
+					Name:  "watch",
+					Usage: "watch the pledge status after sent until the node is ready",
+				},
+				&cli.DurationFlag{
+					Name:  "interval",
+					Value: time.Minute,
+					Usage: "the interval to watch the pledge status",
+				},
+			},
+		},
+		{
+			Name:   "pledgestatus",
+			Usage:  "Show the pledge and accept status of a kernel node signer",
+			Action: pledgeStatusCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "signer",
+					Usage: "the signer address",
+				},
+				&cli.BoolFlag{
+					Name:  "watch",
+					Usage: "print the status on each phase change until the node is ready",
+				},
+				&cli.DurationFlag{
+					Name:  "interval",
+					Value: time.Minute,
+					Usage: "the interval to watch the pledge status",
+				},
+			},
+		},
+		{
+			Name:   "noderemoval",
+			Usage:  "Show the removal queue position, the removal transaction and the refund of a kernel node signer",
+			Action: nodeRemovalCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "signer",
+					Usage: "the signer address",
+				},
+				&cli.BoolFlag{
+					Name:  "raw",
+					Usage: "print the raw removal transaction",
+				},
+				&cli.BoolFlag{
+					Name:  "watch",
+					Usage: "print the status on each change until the refund is spendable",
+				},
+				&cli.DurationFlag{
+					Name:  "interval",
+					Value: time.Minute,
+					Usage: "the interval to watch the removal status",
+				},
+			},
+		},
+		{
+			Name:   "listelectionwarnings",
+			Usage:  "List the signed warnings of the pledges not accepted in time and the removals out of order",
+			Action: listElectionWarningsCmd,
+		},
+		{
+			Name:   "getsyncstatus",
+			Usage:  "Get the sync progress of all chains compared to the peers, and the estimated time to finish",
+			Action: getSyncStatusCmd,
+		},
+		{
+			Name:   "buildnodepayeechangetransaction",
+			Usage:  "Build the transaction to change the payee of an accepted node",
+			Action: changeNodePayeeCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "view",
+					Usage: "the private view key which signs the input",
+				},
+				&cli.StringFlag{
+					Name:  "spend",
+					Usage: "the private spend key which signs the input",
+				},
+				&cli.StringFlag{
+					Name:  "signer",
+					Usage: "the signer address of the node",
+				},
+				&cli.StringFlag{
+					Name:  "old",
+					Usage: "the private spend key of the current payee",
+				},
+				&cli.StringFlag{
+					Name:  "new",
+					Usage: "the private spend key of the new payee",
+				},
+				&cli.StringFlag{
+					Name:  "input",
+					Usage: "the input transaction hash",
+				},
+				&cli.StringFlag{
+					Name:  "amount",
+					Usage: "the input amount, which goes to the new payee",
+				},
 			},
 		},
 		{
@@ -398,259 +1217,746 @@ func main() {
 					Name:  "custodian",
 					Usage: "the private spend key of the custodian node",
 				},
-				&cli.StringFlag{
-					Name:  "network",
-					Usage: "the network id",
+				&cli.StringFlag{
+					Name:  "network",
+					Usage: "the network id",
+				},
+			},
+		},
+		{
+			Name:   "gettopologybytimestamp",
+			Usage:  "Get the first topology and round of each node since the timestamp",
+			Action: getTopologyByTimestampCmd,
+			Flags: []cli.Flag{
+				&cli.Uint64Flag{
+					Name:  "timestamp",
+					Usage: "the timestamp in nanoseconds",
+				},
+			},
+		},
+		{
+			Name:   "getroundlink",
+			Usage:  "Get the latest link between two nodes",
+			Action: getRoundLinkCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "from",
+					Usage: "the reference head",
+				},
+				&cli.StringFlag{
+					Name:  "to",
+					Usage: "the reference tail",
+				},
+			},
+		},
+		{
+			Name:   "getroundbynumber",
+			Usage:  "Get a specific round",
+			Action: getRoundByNumberCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "id",
+					Usage: "the round node id",
+				},
+				&cli.Uint64Flag{
+					Name:  "number",
+					Value: 0,
+					Usage: "the round number",
+				},
+			},
+		},
+		{
+			Name:   "getroundbyhash",
+			Usage:  "Get a specific round",
+			Action: getRoundByHashCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:    "hash",
+					Aliases: []string{"x"},
+					Usage:   "the round hash",
+				},
+			},
+		},
+		{
+			Name:   "listsnapshots",
+			Usage:  "List finalized snapshots",
+			Action: listSnapshotsCmd,
+			Flags: []cli.Flag{
+				&cli.Uint64Flag{
+					Name:    "since",
+					Aliases: []string{"s"},
+					Value:   0,
+					Usage:   "the topological order to begin with",
+				},
+				&cli.Uint64Flag{
+					Name:    "count",
+					Aliases: []string{"c"},
+					Value:   10,
+					Usage:   "the up limit of the returned snapshots",
+				},
+				&cli.BoolFlag{
+					Name:  "sig",
+					Usage: "whether including the signatures",
+				},
+				&cli.BoolFlag{
+					Name:  "tx",
+					Usage: "whether including the transactions",
+				},
+			},
+		},
+		{
+			Name:   "getsnapshot",
+			Usage:  "Get the snapshot by hash",
+			Action: getSnapshotCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:    "hash",
+					Aliases: []string{"x"},
+					Usage:   "the snapshot hash",
+				},
+			},
+		},
+		{
+			Name:   "getfinalityproof",
+			Usage:  "Get the finality proof of the snapshot for the EVM contracts",
+			Action: getFinalityProofCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:    "hash",
+					Aliases: []string{"x"},
+					Usage:   "the snapshot hash",
+				},
+			},
+		},
+		{
+			Name:   "gettransaction",
+			Usage:  "Get the finalized transaction by hash",
+			Action: getTransactionCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:    "hash",
+					Aliases: []string{"x"},
+					Usage:   "the transaction hash",
+				},
+			},
+		},
+		{
+			Name:   "gettransactions",
+			Usage:  "Get the finalized transactions by a list of hashes",
+			Action: getTransactionsCmd,
+			Flags: []cli.Flag{
+				&cli.StringSliceFlag{
+					Name:    "hash",
+					Aliases: []string{"x"},
+					Usage:   "the transaction hash",
+				},
+			},
+		},
+		{
+			Name:   "getcachetransaction",
+			Usage:  "Get the transaction in cache by hash",
+			Action: getCacheTransactionCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:    "hash",
+					Aliases: []string{"x"},
+					Usage:   "the transaction hash",
+				},
+			},
+		},
+		{
+			Name:   "purgecachetransactions",
+			Usage:  "Purge the cache transactions by age or hash prefix",
+			Action: purgeCacheTransactionsCmd,
+			Flags: []cli.Flag{
+				&cli.Uint64Flag{
+					Name:  "age",
+					Usage: "the age in seconds since first received, 0 for any age",
+				},
+				&cli.StringFlag{
+					Name:  "prefix",
+					Usage: "the hex prefix of the transaction hashes, empty for any hash",
+				},
+			},
+		},
+		{
+			Name:   "getdeposittransaction",
+			Usage:  "Get the deposit transaction by external chain transaction",
+			Action: getDepositTransactionCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "chain",
+					Usage: "the chain hash",
+				},
+				&cli.StringFlag{
+					Name:  "hash",
+					Usage: "the external chain transaction hash",
+				},
+				&cli.IntFlag{
+					Name:  "index",
+					Usage: "the external chain transaction output index",
+				},
+			},
+		},
+		{
+			Name:   "getwithdrawalclaim",
+			Usage:  "Get the claim transaction for a withdrawal submit",
+			Action: getWithdrawalClaimCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:    "hash",
+					Aliases: []string{"x"},
+					Usage:   "the withdrawal submit transaction hash",
+				},
+			},
+		},
+		{
+			Name:   "listwithdrawalclaims",
+			Usage:  "List the pending withdrawal submissions grouped by chain with the estimated claim fees",
+			Action: listWithdrawalClaimsCmd,
+			Flags: []cli.Flag{
+				&cli.StringSliceFlag{
+					Name:  "fee",
+					Usage: "the claim fee of a chain in chain:fee format, or the minimum fee if not given",
+				},
+			},
+		},
+		{
+			Name:   "buildwithdrawalclaims",
+			Usage:  "Build the claim transactions for the withdrawal submissions",
+			Action: buildWithdrawalClaimsCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "receiver",
+					Usage: "the address to receive the claim fees",
+				},
+				&cli.StringFlag{
+					Name:  "change",
+					Usage: "the address to receive the change",
+				},
+				&cli.StringSliceFlag{
+					Name:  "input",
+					Usage: "the XIN input to pay the fees in hash:index format",
+				},
+				&cli.StringSliceFlag{
+					Name:  "claim",
+					Usage: "the claim in submission:fee:info format, the info is hex encoded",
+				},
+			},
+		},
+		{
+			Name:   "getutxo",
+			Usage:  "Get the UTXO by hash and index",
+			Action: getUTXOCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:    "hash",
+					Aliases: []string{"x"},
+					Usage:   "the transaction hash",
+				},
+				&cli.Uint64Flag{
+					Name:    "index",
+					Aliases: []string{"i"},
+					Value:   0,
+					Usage:   "the output index",
+				},
+			},
+		},
+		{
+			Name:   "proveoutputownership",
+			Usage:  "Sign a statement that the address controls the output, with the view and spend keys",
+			Action: proveOutputOwnershipCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "view",
+					Usage: "the private view key of the address",
+				},
+				&cli.StringFlag{
+					Name:  "spend",
+					Usage: "the private spend key of the address",
+				},
+				&cli.StringFlag{
+					Name:    "hash",
+					Aliases: []string{"x"},
+					Usage:   "the transaction hash",
+				},
+				&cli.Uint64Flag{
+					Name:    "index",
+					Aliases: []string{"i"},
+					Value:   0,
+					Usage:   "the output index",
+				},
+				&cli.StringFlag{
+					Name:  "challenge",
+					Usage: "the hex challenge of the verifier, at most 64 bytes",
+				},
+			},
+		},
+		{
+			Name:   "verifyoutputownership",
+			Usage:  "Verify the output ownership statement against the output on the node",
+			Action: verifyOutputOwnershipCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "proof",
+					Usage: "the hex ownership statement of proveoutputownership",
+				},
+			},
+		},
+		{
+			Name:   "getkey",
+			Usage:  "Get the ghost key",
+			Action: getKeyCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:    "key",
+					Aliases: []string{"k"},
+					Usage:   "the ghost key",
+				},
+			},
+		},
+		{
+			Name:   "getasset",
+			Usage:  "Get the asset and balance",
+			Action: getAssetCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "id",
+					Usage: "the asset id",
+				},
+			},
+		},
+		{
+			Name:   "listcustodianupdates",
+			Usage:  "List all custodian updates",
+			Action: listCustodianUpdatesCmd,
+			Flags:  []cli.Flag{},
+		},
+		{
+			Name:   "getcustodian",
+			Usage:  "Get the current custodian and its nodes",
+			Action: getCustodianCmd,
+			Flags:  []cli.Flag{},
+		},
+		{
+			Name:   "listpendingcustodianupdates",
+			Usage:  "List the custodian update transactions not finalized yet",
+			Action: listPendingCustodianUpdatesCmd,
+			Flags:  []cli.Flag{},
+		},
+		{
+			Name:   "listassetflows",
+			Usage:  "List the deposits and withdrawals of all assets",
+			Action: listAssetFlowsCmd,
+			Flags:  []cli.Flag{},
+		},
+		{
+			Name:   "liststatecheckpoints",
+			Usage:  "List the latest state checkpoints and the commitments of the nodes",
+			Action: listStateCheckpointsCmd,
+			Flags: []cli.Flag{
+				&cli.Uint64Flag{
+					Name:  "limit",
+					Value: 24,
+					Usage: "the checkpoints count limit",
+				},
+			},
+		},
+		{
+			Name:   "listcustodianbalances",
+			Usage:  "List the balances of the assets held by the custodian",
+			Action: listCustodianBalancesCmd,
+			Flags:  []cli.Flag{},
+		},
+		{
+			Name:   "proposecustodianupdate",
+			Usage:  "Add a signed custodian node extra to the local proposal of a new custodian",
+			Action: proposeCustodianUpdateCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "custodian",
+					Usage: "the new custodian address",
+				},
+				&cli.StringFlag{
+					Name:  "extra",
+					Usage: "the hex custodian node extra by encodecustodianextra",
+				},
+			},
+		},
+		{
+			Name:   "listcustodianproposals",
+			Usage:  "List the pending custodian proposals of the node",
+			Action: listCustodianProposalsCmd,
+			Flags:  []cli.Flag{},
+		},
+		{
+			Name:   "signcustodianproposal",
+			Usage:  "Approve the custodian proposal extra with the current custodian key",
+			Action: signCustodianProposalCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "key",
+					Usage: "the private spend key of the current custodian",
+				},
+				&cli.StringFlag{
+					Name:  "extra",
+					Usage: "the hex proposal extra by listcustodianproposals",
+				},
+			},
+		},
+		{
+			Name:   "buildcustodianupdate",
+			Usage:  "Build the JSON raw custodian update transaction for signrawtransaction",
+			Action: buildCustodianUpdateCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "custodian",
+					Usage: "the new custodian address",
+				},
+				&cli.StringFlag{
+					Name:  "approval",
+					Usage: "the approval signature by signcustodianproposal",
+				},
+				&cli.StringFlag{
+					Name:  "receiver",
+					Usage: "the receiver address of the update fee",
+				},
+				&cli.StringFlag{
+					Name:  "input",
+					Usage: "the XIN input hash to pay the update fee",
+				},
+				&cli.UintFlag{
+					Name:  "index",
+					Usage: "the XIN input index",
+				},
+			},
+		},
+		{
+			Name:   "listmintworks",
+			Usage:  "List mint works",
+			Action: listMintWorksCmd,
+			Flags: []cli.Flag{
+				&cli.Uint64Flag{
+					Name:    "since",
+					Aliases: []string{"s"},
+					Value:   0,
+					Usage:   "the mint batch to list works",
+				},
+			},
+		},
+		{
+			Name:   "getmintforecast",
+			Usage:  "Forecast the next mint batch and the node shares by the works so far",
+			Action: getMintForecastCmd,
+		},
+		{
+			Name:   "listmintdistributions",
+			Usage:  "List mint distributions",
+			Action: listMintDistributionsCmd,
+			Flags: []cli.Flag{
+				&cli.Uint64Flag{
+					Name:    "since",
+					Aliases: []string{"s"},
+					Value:   0,
+					Usage:   "the mint batch to begin with",
+				},
+				&cli.Uint64Flag{
+					Name:    "count",
+					Aliases: []string{"c"},
+					Value:   10,
+					Usage:   "the up limit of the returned distributions",
+				},
+				&cli.BoolFlag{
+					Name:  "tx",
+					Usage: "whether including the transactions",
+				},
+			},
+		},
+		{
+			Name:   "listallnodes",
+			Usage:  "List all nodes ever existed",
+			Action: listAllNodesCmd,
+			Flags: []cli.Flag{
+				&cli.Uint64Flag{
+					Name:  "threshold",
+					Value: 0,
+					Usage: "the threshold in Unix nanoseconds to build the nodes list",
+				},
+				&cli.BoolFlag{
+					Name:  "state",
+					Value: false,
+					Usage: "whether keep a full state queue",
 				},
 			},
 		},
 		{
-			Name:   "getroundlink",
-			Usage:  "Get the latest link between two nodes",
-			Action: getRoundLinkCmd,
+			Name:   "exportnodes",
+			Usage:  "Dump the consensus nodes with states, timestamps and transactions as JSON",
+			Action: exportNodesCmd,
 			Flags: []cli.Flag{
-				&cli.StringFlag{
-					Name:  "from",
-					Usage: "the reference head",
+				&cli.Uint64Flag{
+					Name:  "threshold",
+					Usage: "the threshold in Unix nanoseconds to build the nodes list, default now",
 				},
 				&cli.StringFlag{
-					Name:  "to",
-					Usage: "the reference tail",
+					Name:  "out",
+					Usage: "the file to write the dump, default the standard output",
 				},
 			},
 		},
 		{
-			Name:   "getroundbynumber",
-			Usage:  "Get a specific round",
-			Action: getRoundByNumberCmd,
+			Name:   "verifynodes",
+			Usage:  "Verify a consensus nodes dump against the node at the same threshold",
+			Action: verifyNodesCmd,
 			Flags: []cli.Flag{
 				&cli.StringFlag{
-					Name:  "id",
-					Usage: "the round node id",
-				},
-				&cli.Uint64Flag{
-					Name:  "number",
-					Value: 0,
-					Usage: "the round number",
+					Name:  "file",
+					Usage: "the dump file of exportnodes",
 				},
 			},
 		},
 		{
-			Name:   "getroundbyhash",
-			Usage:  "Get a specific round",
-			Action: getRoundByHashCmd,
+			Name:   "nodes",
+			Usage:  "List the consensus nodes and the pledging slot at a time",
+			Action: listConsensusNodesCmd,
 			Flags: []cli.Flag{
 				&cli.StringFlag{
-					Name:    "hash",
-					Aliases: []string{"x"},
-					Usage:   "the round hash",
+					Name:  "at",
+					Usage: "the time in RFC3339 or Unix nanoseconds, default now",
 				},
 			},
 		},
 		{
-			Name:   "listsnapshots",
-			Usage:  "List finalized snapshots",
-			Action: listSnapshotsCmd,
+			Name:   "writesupportbundle",
+			Usage:  "Write a support bundle of the logs, goroutines and states to the node directory",
+			Action: writeSupportBundleCmd,
+		},
+		{
+			Name:   "listchainstats",
+			Usage:  "List the processing statistics of each chain on the node",
+			Action: listChainStatsCmd,
+		},
+		{
+			Name:   "listauditentries",
+			Usage:  "List the operator and access audit entries of the node",
+			Action: listAuditEntriesCmd,
 			Flags: []cli.Flag{
 				&cli.Uint64Flag{
 					Name:    "since",
 					Aliases: []string{"s"},
 					Value:   0,
-					Usage:   "the topological order to begin with",
+					Usage:   "the audit sequence to begin with",
 				},
 				&cli.Uint64Flag{
 					Name:    "count",
 					Aliases: []string{"c"},
-					Value:   10,
-					Usage:   "the up limit of the returned snapshots",
-				},
-				&cli.BoolFlag{
-					Name:  "sig",
-					Usage: "whether including the signatures",
+					Value:   100,
+					Usage:   "the up limit of the returned entries",
 				},
-				&cli.BoolFlag{
-					Name:  "tx",
-					Usage: "whether including the transactions",
+				&cli.StringFlag{
+					Name:  "action",
+					Value: "",
+					Usage: "the action prefix to filter, e.g. rpc. or transaction.submit",
 				},
 			},
 		},
 		{
-			Name:   "getsnapshot",
-			Usage:  "Get the snapshot by hash",
-			Action: getSnapshotCmd,
+			Name:   "registerwalletaccount",
+			Usage:  "Register a watch-only account to the node wallet scanner",
+			Action: registerWalletAccountCmd,
 			Flags: []cli.Flag{
 				&cli.StringFlag{
-					Name:    "hash",
-					Aliases: []string{"x"},
-					Usage:   "the snapshot hash",
+					Name:  "address",
+					Usage: "the account address",
+				},
+				&cli.StringFlag{
+					Name:  "view",
+					Usage: "the account private view key",
 				},
 			},
 		},
 		{
-			Name:   "gettransaction",
-			Usage:  "Get the finalized transaction by hash",
-			Action: getTransactionCmd,
+			Name:   "listwalletoutputs",
+			Usage:  "List the outputs indexed by the node wallet scanner",
+			Action: listWalletOutputsCmd,
 			Flags: []cli.Flag{
 				&cli.StringFlag{
-					Name:    "hash",
-					Aliases: []string{"x"},
-					Usage:   "the transaction hash",
+					Name:  "address",
+					Usage: "the account address",
+				},
+				&cli.StringFlag{
+					Name:  "asset",
+					Usage: "the asset id, or all assets if empty",
+				},
+				&cli.BoolFlag{
+					Name:  "spent",
+					Value: false,
+					Usage: "whether include the spent outputs",
 				},
 			},
 		},
 		{
-			Name:   "getcachetransaction",
-			Usage:  "Get the transaction in cache by hash",
-			Action: getCacheTransactionCmd,
+			Name:   "getwalletbalance",
+			Usage:  "Get the balances of an account indexed by the node wallet scanner",
+			Action: getWalletBalanceCmd,
 			Flags: []cli.Flag{
 				&cli.StringFlag{
-					Name:    "hash",
-					Aliases: []string{"x"},
-					Usage:   "the transaction hash",
+					Name:  "address",
+					Usage: "the account address",
 				},
 			},
 		},
 		{
-			Name:   "getdeposittransaction",
-			Usage:  "Get the deposit transaction by external chain transaction",
-			Action: getDepositTransactionCmd,
+			Name:   "registerwalletsequence",
+			Usage:  "Register a deposit address sequence to the node wallet scanner",
+			Action: registerWalletSequenceCmd,
 			Flags: []cli.Flag{
 				&cli.StringFlag{
-					Name:  "chain",
-					Usage: "the chain hash",
+					Name:  "address",
+					Usage: "the master address",
 				},
 				&cli.StringFlag{
-					Name:  "hash",
-					Usage: "the external chain transaction hash",
+					Name:  "view",
+					Usage: "the master private view key",
 				},
-				&cli.IntFlag{
-					Name:  "index",
-					Usage: "the external chain transaction output index",
+				&cli.Uint64Flag{
+					Name:  "gap",
+					Value: 20,
+					Usage: "the number of unused addresses to watch after the last used one",
 				},
 			},
 		},
 		{
-			Name:   "getwithdrawalclaim",
-			Usage:  "Get the claim transaction for a withdrawal submit",
-			Action: getWithdrawalClaimCmd,
+			Name:   "registerwalletsubaddress",
+			Usage:  "Register the deposit address of a payment id to the node wallet scanner",
+			Action: registerWalletSubaddressCmd,
 			Flags: []cli.Flag{
 				&cli.StringFlag{
-					Name:    "hash",
-					Aliases: []string{"x"},
-					Usage:   "the withdrawal submit transaction hash",
+					Name:  "address",
+					Usage: "the master address",
+				},
+				&cli.StringFlag{
+					Name:  "view",
+					Usage: "the master private view key",
+				},
+				&cli.StringFlag{
+					Name:  "payment",
+					Usage: "the payment id, e.g. the customer id",
 				},
 			},
 		},
 		{
-			Name:   "getutxo",
-			Usage:  "Get the UTXO by hash and index",
-			Action: getUTXOCmd,
+			Name:   "getwalletsequence",
+			Usage:  "Get the deposit address sequence watched by the node wallet scanner",
+			Action: getWalletSequenceCmd,
 			Flags: []cli.Flag{
 				&cli.StringFlag{
-					Name:    "hash",
-					Aliases: []string{"x"},
-					Usage:   "the transaction hash",
-				},
-				&cli.Uint64Flag{
-					Name:    "index",
-					Aliases: []string{"i"},
-					Value:   0,
-					Usage:   "the output index",
+					Name:  "address",
+					Usage: "the master address",
 				},
 			},
 		},
 		{
-			Name:   "getkey",
-			Usage:  "Get the ghost key",
-			Action: getKeyCmd,
+			Name:   "registerwebhook",
+			Usage:  "Register a webhook notified when the matching transactions finalize",
+			Action: registerWebhookCmd,
 			Flags: []cli.Flag{
 				&cli.StringFlag{
-					Name:    "key",
-					Aliases: []string{"k"},
-					Usage:   "the ghost key",
+					Name:  "url",
+					Usage: "the webhook URL to post the notifications",
+				},
+				&cli.StringFlag{
+					Name:  "asset",
+					Usage: "the transaction asset filter",
+				},
+				&cli.StringFlag{
+					Name:  "address",
+					Usage: "the account address filter to view the outputs",
+				},
+				&cli.StringFlag{
+					Name:  "view",
+					Usage: "the account private view key",
+				},
+				&cli.StringFlag{
+					Name:  "extra",
+					Usage: "the hex transaction extra prefix filter",
+				},
+				&cli.Uint64Flag{
+					Name:  "confirmations",
+					Usage: "the snapshots count finalized after the transaction before the notification",
 				},
 			},
 		},
 		{
-			Name:   "getasset",
-			Usage:  "Get the asset and balance",
-			Action: getAssetCmd,
+			Name:   "listwebhooks",
+			Usage:  "List the registered webhooks with the pending deliveries count",
+			Action: listWebhooksCmd,
+		},
+		{
+			Name:   "removewebhook",
+			Usage:  "Remove a webhook and all its pending deliveries",
+			Action: removeWebhookCmd,
 			Flags: []cli.Flag{
 				&cli.StringFlag{
 					Name:  "id",
-					Usage: "the asset id",
+					Usage: "the webhook id",
 				},
 			},
 		},
 		{
-			Name:   "listcustodianupdates",
-			Usage:  "List all custodian updates",
-			Action: listCustodianUpdatesCmd,
-			Flags:  []cli.Flag{},
+			Name:   "getinfo",
+			Usage:  "Get info from the node",
+			Action: getInfoCmd,
 		},
 		{
-			Name:   "listmintworks",
-			Usage:  "List mint works",
-			Action: listMintWorksCmd,
+			Name:   "listpeers",
+			Usage:  "List all the connected peers",
+			Action: listPeersCmd,
+		},
+		{
+			Name:   "setnoderole",
+			Usage:  "Switch the running node between the relayer and consumer roles",
+			Action: setNodeRoleCmd,
 			Flags: []cli.Flag{
-				&cli.Uint64Flag{
-					Name:    "since",
-					Aliases: []string{"s"},
-					Value:   0,
-					Usage:   "the mint batch to list works",
+				&cli.StringFlag{
+					Name:  "role",
+					Usage: "relayer or consumer",
 				},
 			},
 		},
 		{
-			Name:   "listmintdistributions",
-			Usage:  "List mint distributions",
-			Action: listMintDistributionsCmd,
+			Name:   "listloglevels",
+			Usage:  "List the log levels of the running node modules",
+			Action: listLogLevelsCmd,
+		},
+		{
+			Name:   "setloglevel",
+			Usage:  "Change the log level of a running node module",
+			Action: setLogLevelCmd,
 			Flags: []cli.Flag{
-				&cli.Uint64Flag{
-					Name:    "since",
-					Aliases: []string{"s"},
-					Value:   0,
-					Usage:   "the mint batch to begin with",
-				},
-				&cli.Uint64Flag{
-					Name:    "count",
-					Aliases: []string{"c"},
-					Value:   10,
-					Usage:   "the up limit of the returned distributions",
+				&cli.StringFlag{
+					Name:  "module",
+					Value: "all",
+					Usage: "all or one of the modules " + strings.Join(logger.Modules(), ", "),
 				},
-				&cli.BoolFlag{
-					Name:  "tx",
-					Usage: "whether including the transactions",
+				&cli.IntFlag{
+					Name:  "level",
+					Value: logger.INFO,
+					Usage: "the log level",
 				},
 			},
 		},
 		{
-			Name:   "listallnodes",
-			Usage:  "List all nodes ever existed",
-			Action: listAllNodesCmd,
+			Name:   "setdiagnostics",
+			Usage:  "Enable or disable the diagnostics endpoints of a running node",
+			Action: setDiagnosticsCmd,
 			Flags: []cli.Flag{
-				&cli.Uint64Flag{
-					Name:  "threshold",
-					Value: 0,
-					Usage: "the threshold in Unix nanoseconds to build the nodes list",
-				},
 				&cli.BoolFlag{
-					Name:  "state",
-					Value: false,
-					Usage: "whether keep a full state queue",
+					Name:  "enable",
+					Usage: "enable the diagnostics endpoints",
 				},
 			},
 		},
-		{
-			Name:   "getinfo",
-			Usage:  "Get info from the node",
-			Action: getInfoCmd,
-		},
-		{
-			Name:   "listpeers",
-			Usage:  "List all the connected peers",
-			Action: listPeersCmd,
-		},
 		{
 			Name:   "listrelayers",
 			Usage:  "List the remote relayers for peer",
@@ -686,13 +1992,30 @@ func kernelCmd(c *cli.Context) error {
 	if err != nil {
 		return err
 	}
-
-	gns, err := common.ReadGenesis(c.String("dir") + "/genesis.json")
+	err = logger.SetFormat(c.String("log-format"))
+	if err != nil {
+		return err
+	}
+	err = setLogModules(c.String("log-modules"))
 	if err != nil {
 		return err
 	}
+	logs := diagnostics.NewLogRing(diagnostics.LogRingSize)
+	log.SetOutput(io.MultiWriter(os.Stderr, logs))
 
-	custom, err := config.Initialize(c.String("dir") + "/config.toml")
+	custom, err := config.InitializeNetwork(c.String("dir")+"/config.toml", c.String("network"), c.StringSlice("set")...)
+	if err != nil {
+		return err
+	}
+	gns, err := common.ReadGenesis(custom.Network.Genesis)
+	if err != nil {
+		return err
+	}
+	err = kernel.ApplyConsensusParams(gns)
+	if err != nil {
+		return err
+	}
+	err = custom.UnlockSigner(promptSignerPassphrase)
 	if err != nil {
 		return err
 	}
@@ -702,29 +2025,168 @@ func kernelCmd(c *cli.Context) error {
 		return err
 	}
 
-	store, err := storage.NewBadgerStore(custom, c.String("dir"))
+	store, err := storage.NewBadgerStore(custom, custom.Network.Data)
 	if err != nil {
 		return err
 	}
 	defer store.Close()
 
+	if custom.Node.SignerEncrypted != "" {
+		err = store.WriteAuditEntry(&common.AuditEntry{
+			Timestamp: uint64(time.Now().UnixNano()),
+			Actor:     "kernel",
+			Action:    common.AuditActionKeyUnlock,
+			Detail:    fmt.Sprintf("signer %s unlocked", custom.Node.Signer.Public()),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if custom.Storage.RecoveryCheck {
+		err = recoverStore(store, "kernel", c.Bool("recovery-confirm"))
+		if err != nil {
+			return err
+		}
+	}
+
+	// the replica loads the graph of the primary before the node setup, so
+	// the genesis is never written by the replica itself
+	var follower *replica.Follower
+	if p := custom.Replica.Primary; p != "" {
+		interval := time.Duration(custom.Replica.Interval) * time.Second
+		follower, err = replica.NewFollower(store, p, custom.Replica.Token, custom.Replica.CA, interval)
+		if err != nil {
+			return err
+		}
+		err = follower.Pull()
+		if err != nil {
+			return err
+		}
+	}
+
 	node, err := kernel.SetupNode(custom, store, cache, gns)
 	if err != nil {
 		return err
 	}
 
+	err = diagnostics.SetupBundle(custom.Network.Data+"/bundles", logs, map[string]func() (any, error){
+		"config": func() (any, error) { return custom.Redacted(), nil },
+		"state":  func() (any, error) { return node.Diagnostic(), nil },
+		"chains": func() (any, error) { return node.ChainsStats(), nil },
+		"store":  func() (any, error) { return store.ReadDatabaseStats(), nil },
+	})
+	if err != nil {
+		return err
+	}
+	bundle, err := diagnostics.CaptureCrash()
+	if err != nil {
+		return err
+	}
+	if bundle != "" {
+		logger.Printf("support bundle of the last crash %s\n", bundle)
+	}
+
+	if c := custom.LogShip.Collector; c != "" {
+		period := time.Duration(custom.LogShip.Period) * time.Second
+		shipper, err := logship.NewShipper(c, custom.LogShip.Key, node.IdForNetwork, period, func() any {
+			if node.Peer == nil {
+				return nil
+			}
+			return node.Peer.Metric()
+		})
+		if err != nil {
+			return err
+		}
+		log.SetOutput(io.MultiWriter(os.Stderr, logs, shipper))
+		go shipper.Loop()
+	}
+
+	if custom.Webhook.Enabled {
+		dispatcher := webhook.NewDispatcher(store, node.IdForNetwork, node.SignData)
+		go dispatcher.Loop(node.IndexingPaused)
+	}
+
+	if k := custom.EventSink.Kind; k != "" {
+		publisher, err := eventsink.NewPublisher(k, custom.EventSink.Address, custom.EventSink.Topic)
+		if err != nil {
+			return err
+		}
+		defer publisher.Close()
+		go eventsink.NewStreamer(store, publisher).Loop(node.IndexingPaused)
+	}
+
+	if e := custom.Tracing.Endpoint; e != "" {
+		shutdown, err := tracing.Setup(e, custom.Tracing.Ratio, node.IdForNetwork)
+		if err != nil {
+			return err
+		}
+		defer shutdown(context.Background())
+	}
+
 	if p := custom.RPC.Port; p > 0 {
 		server := rpc.NewServer(custom, store, node, p)
-		go server.ListenAndServe()
+		err := listenAndServe(server, custom.RPC.TLSCert, custom.RPC.TLSKey)
+		if err != nil {
+			return err
+		}
+	}
+
+	if custom.Admin.Port > 0 {
+		server := rpc.NewAdminServer(custom, store, node)
+		err := listenAndServe(server, custom.Admin.TLSCert, custom.Admin.TLSKey)
+		if err != nil {
+			return err
+		}
 	}
 
 	if p := custom.Dev.Port; p > 0 {
-		go http.ListenAndServe(fmt.Sprintf(":%d", p), http.DefaultServeMux)
+		server, err := diagnostics.NewServer(custom.Dev.Listen, p, custom.Dev.Token, func() any {
+			return node.Diagnostic()
+		})
+		if err != nil {
+			return err
+		}
+		diagnostics.SetEnabled(true)
+		err = listenAndServe(server, custom.Dev.TLSCert, custom.Dev.TLSKey)
+		if err != nil {
+			return err
+		}
 	}
 
+	defer func() {
+		if r := recover(); r != nil {
+			bundle, err := diagnostics.WriteBundle(fmt.Sprintf("panic: %v", r))
+			logger.Printf("support bundle of the panic %s %v\n", bundle, err)
+			panic(r)
+		}
+	}()
+	if follower != nil {
+		follower.Loop(node.ReloadReplica)
+		return nil
+	}
 	return node.Loop()
 }
 
+// listenAndServe loads the TLS certificate before serving, so an invalid
+// certificate fails the startup instead of the listener goroutine.
+func listenAndServe(server *http.Server, cert, key string) error {
+	if cert == "" {
+		go server.ListenAndServe()
+		return nil
+	}
+	pair, err := tls.LoadX509KeyPair(cert, key)
+	if err != nil {
+		return fmt.Errorf("listenAndServe(%s) => %v", server.Addr, err)
+	}
+	server.TLSConfig = &tls.Config{
+		Certificates: []tls.Certificate{pair},
+		MinVersion:   tls.VersionTLS12,
+	}
+	go server.ListenAndServeTLS("", "")
+	return nil
+}
+
 func newCache(conf *config.Custom) (*ristretto.Cache[[]byte, any], error) {
 	cost := int64(conf.Node.MemoryCacheSize * 1024 * 1024)
 	return ristretto.NewCache(&ristretto.Config[[]byte, any]{
@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Counter accumulates the values which only go up, e.g. the errors, and is
+// partitioned by the values of all its labels, so the rates could be charted
+// by the combinations of them.
+type Counter struct {
+	name   string
+	help   string
+	labels []string
+
+	mutex  sync.Mutex
+	values map[string]float64
+}
+
+// NewCounter registers the counter to be written by WritePrometheus, the
+// counter has no partitions without any label.
+func NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{
+		name:   name,
+		help:   help,
+		labels: labels,
+		values: make(map[string]float64),
+	}
+	register(c)
+	return c
+}
+
+func (c *Counter) metricName() string {
+	return c.name
+}
+
+// Add drops the negative values, and the values of the wrong label count, or
+// of new label values when the counter already has too many series.
+func (c *Counter) Add(v float64, values ...string) {
+	if math.IsNaN(v) || v < 0 || len(values) != len(c.labels) {
+		return
+	}
+	key := strings.Join(values, "\x00")
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	_, found := c.values[key]
+	if !found && len(c.values) >= seriesLimit {
+		return
+	}
+	c.values[key] += v
+}
+
+func (c *Counter) write(w io.Writer) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(c.values))
+	for k := range c.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		var values []string
+		if len(c.labels) > 0 {
+			values = strings.Split(k, "\x00")
+		}
+		_, err = fmt.Fprintf(w, "%s%s %s\n", c.name, formatLabelPairs(c.labels, values, ""),
+			strconv.FormatFloat(c.values[k], 'g', -1, 64))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCounter(t *testing.T) {
+	require := require.New(t)
+
+	c := NewCounter("test_errors_total", "The errors.", "method", "class")
+	require.Panics(func() { NewCounter("test_errors_total", "duplicated") })
+	c.Add(1, "getinfo", "server")
+	c.Add(1, "gettransaction", "invalid")
+	c.Add(2, "gettransaction", "invalid")
+	c.Add(1, "gettransaction")
+	c.Add(-1, "getinfo", "server")
+	NewCounter("test_requests_total", "The requests.").Add(3)
+
+	var buf bytes.Buffer
+	require.Nil(WritePrometheus(&buf))
+	out := buf.String()
+	require.Contains(out, strings.Join([]string{
+		"# HELP test_errors_total The errors.",
+		"# TYPE test_errors_total counter",
+		`test_errors_total{method="getinfo",class="server"} 1`,
+		`test_errors_total{method="gettransaction",class="invalid"} 3`,
+		"# HELP test_requests_total The requests.",
+		"# TYPE test_requests_total counter",
+		"test_requests_total 3",
+	}, "\n"))
+
+	for i := 0; i < seriesLimit+10; i++ {
+		c.Add(1, strings.Repeat("m", i+1), "server")
+	}
+	require.Len(c.values, seriesLimit)
+}
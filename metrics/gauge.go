@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// Gauge holds the latest value of each label value, for the states which go
+// up and down, e.g. the sync progress.
+type Gauge struct {
+	name  string
+	help  string
+	label string
+
+	mutex  sync.Mutex
+	values map[string]float64
+}
+
+// NewGauge registers the gauge to be written by WritePrometheus, the label
+// could be empty for a gauge without partitions.
+func NewGauge(name, help, label string) *Gauge {
+	g := &Gauge{
+		name:   name,
+		help:   help,
+		label:  label,
+		values: make(map[string]float64),
+	}
+	register(g)
+	return g
+}
+
+func (g *Gauge) metricName() string {
+	return g.name
+}
+
+// Set drops the new label values when the gauge already has too many series.
+func (g *Gauge) Set(value string, v float64) {
+	if math.IsNaN(v) {
+		return
+	}
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	_, found := g.values[value]
+	if !found && len(g.values) >= seriesLimit {
+		return
+	}
+	g.values[value] = v
+}
+
+func (g *Gauge) write(w io.Writer) error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", g.name, g.help, g.name)
+	if err != nil {
+		return err
+	}
+	values := make([]string, 0, len(g.values))
+	for v := range g.values {
+		values = append(values, v)
+	}
+	sort.Strings(values)
+	for _, v := range values {
+		_, err = fmt.Fprintf(w, "%s%s %s\n", g.name, formatLabels(g.label, v, ""),
+			strconv.FormatFloat(g.values[v], 'g', -1, 64))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
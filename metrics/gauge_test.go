@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGauge(t *testing.T) {
+	require := require.New(t)
+
+	g := NewGauge("test_chain_lag_rounds", "The chain lag.", "chain")
+	require.Panics(func() { NewGauge("test_chain_lag_rounds", "duplicated", "") })
+	g.Set("b", 3)
+	g.Set("a", 1.5)
+	g.Set("b", 2)
+	g.Set("c", math.NaN())
+	NewGauge("test_progress_ratio", "The progress.", "").Set("", 0.25)
+
+	var buf bytes.Buffer
+	require.Nil(WritePrometheus(&buf))
+	out := buf.String()
+	require.Contains(out, strings.Join([]string{
+		"# HELP test_chain_lag_rounds The chain lag.",
+		"# TYPE test_chain_lag_rounds gauge",
+		`test_chain_lag_rounds{chain="a"} 1.5`,
+		`test_chain_lag_rounds{chain="b"} 2`,
+		"# HELP test_progress_ratio The progress.",
+		"# TYPE test_progress_ratio gauge",
+		"test_progress_ratio 0.25",
+	}, "\n"))
+	require.NotContains(out, `chain="c"`)
+
+	for i := 0; i < seriesLimit+10; i++ {
+		g.Set(strings.Repeat("p", i+1), 1)
+	}
+	require.Len(g.values, seriesLimit)
+}
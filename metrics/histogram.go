@@ -0,0 +1,176 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const seriesLimit = 1024
+
+var registry struct {
+	sync.Mutex
+	collectors []collector
+}
+
+type collector interface {
+	metricName() string
+	write(w io.Writer) error
+}
+
+func register(c collector) {
+	registry.Lock()
+	defer registry.Unlock()
+	for _, o := range registry.collectors {
+		if o.metricName() == c.metricName() {
+			panic(fmt.Errorf("duplicated metric %s", c.metricName()))
+		}
+	}
+	registry.collectors = append(registry.collectors, c)
+}
+
+// Histogram counts the observations in the cumulative buckets of the
+// Prometheus histogram, partitioned by the value of a single label, so
+// Grafana could chart the quantiles with histogram_quantile directly.
+type Histogram struct {
+	name    string
+	help    string
+	label   string
+	buckets []float64
+
+	mutex  sync.Mutex
+	series map[string]*series
+}
+
+type series struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// NewHistogram registers the histogram to be written by WritePrometheus, the
+// label could be empty for a histogram without partitions.
+func NewHistogram(name, help, label string, buckets []float64) *Histogram {
+	if !sort.Float64sAreSorted(buckets) || len(buckets) == 0 {
+		panic(fmt.Errorf("invalid histogram buckets %s %v", name, buckets))
+	}
+	h := &Histogram{
+		name:    name,
+		help:    help,
+		label:   label,
+		buckets: slices.Clone(buckets),
+		series:  make(map[string]*series),
+	}
+	register(h)
+	return h
+}
+
+func (h *Histogram) metricName() string {
+	return h.name
+}
+
+// Observe drops the observations of new label values when the histogram
+// already has too many series, to bound the memory of changing peers.
+func (h *Histogram) Observe(value string, v float64) {
+	if math.IsNaN(v) {
+		return
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	s := h.series[value]
+	if s == nil {
+		if len(h.series) >= seriesLimit {
+			return
+		}
+		s = &series{counts: make([]uint64, len(h.buckets))}
+		h.series[value] = s
+	}
+	for i, b := range h.buckets {
+		if v <= b {
+			s.counts[i] += 1
+		}
+	}
+	s.sum += v
+	s.count += 1
+}
+
+func (h *Histogram) write(w io.Writer) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	if err != nil {
+		return err
+	}
+	values := make([]string, 0, len(h.series))
+	for v := range h.series {
+		values = append(values, v)
+	}
+	sort.Strings(values)
+	for _, v := range values {
+		s := h.series[v]
+		for i, b := range h.buckets {
+			le := strconv.FormatFloat(b, 'g', -1, 64)
+			_, err = fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labels(v, le), s.counts[i])
+			if err != nil {
+				return err
+			}
+		}
+		_, err = fmt.Fprintf(w, "%s_bucket%s %d\n%s_sum%s %s\n%s_count%s %d\n",
+			h.name, h.labels(v, "+Inf"), s.count,
+			h.name, h.labels(v, ""), strconv.FormatFloat(s.sum, 'g', -1, 64),
+			h.name, h.labels(v, ""), s.count)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *Histogram) labels(value, le string) string {
+	return formatLabels(h.label, value, le)
+}
+
+func formatLabels(label, value, le string) string {
+	if label == "" {
+		return formatLabelPairs(nil, nil, le)
+	}
+	return formatLabelPairs([]string{label}, []string{value}, le)
+}
+
+func formatLabelPairs(names, values []string, le string) string {
+	var labels []string
+	for i, name := range names {
+		labels = append(labels, fmt.Sprintf("%s=%s", name, strconv.Quote(values[i])))
+	}
+	if le != "" {
+		labels = append(labels, fmt.Sprintf("le=%q", le))
+	}
+	if len(labels) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(labels, ",") + "}"
+}
+
+// WritePrometheus writes all the registered metrics in the Prometheus text
+// exposition format.
+func WritePrometheus(w io.Writer) error {
+	registry.Lock()
+	collectors := slices.Clone(registry.collectors)
+	registry.Unlock()
+
+	for _, c := range collectors {
+		err := c.write(w)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistogram(t *testing.T) {
+	require := require.New(t)
+
+	require.Panics(func() { NewHistogram("test_invalid", "invalid", "", []float64{2, 1}) })
+
+	h := NewHistogram("test_phase_seconds", "The phase duration.", "phase", []float64{0.1, 1, 10})
+	require.Panics(func() { NewHistogram("test_phase_seconds", "duplicated", "", []float64{1}) })
+	h.Observe("commitment", 0.05)
+	h.Observe("commitment", 0.5)
+	h.Observe("commitment", 20)
+	h.Observe("response", 1)
+	h.Observe("response", 0)
+	NewHistogram("test_lag_rounds", "The lag.", "", []float64{0, 1}).Observe("", -1)
+
+	var buf bytes.Buffer
+	require.Nil(WritePrometheus(&buf))
+	out := buf.String()
+	require.Contains(out, strings.Join([]string{
+		"# HELP test_phase_seconds The phase duration.",
+		"# TYPE test_phase_seconds histogram",
+		`test_phase_seconds_bucket{phase="commitment",le="0.1"} 1`,
+		`test_phase_seconds_bucket{phase="commitment",le="1"} 2`,
+		`test_phase_seconds_bucket{phase="commitment",le="10"} 2`,
+		`test_phase_seconds_bucket{phase="commitment",le="+Inf"} 3`,
+		`test_phase_seconds_sum{phase="commitment"} 20.55`,
+		`test_phase_seconds_count{phase="commitment"} 3`,
+		`test_phase_seconds_bucket{phase="response",le="0.1"} 1`,
+		`test_phase_seconds_bucket{phase="response",le="1"} 2`,
+	}, "\n"))
+	require.Contains(out, strings.Join([]string{
+		"# TYPE test_lag_rounds histogram",
+		`test_lag_rounds_bucket{le="0"} 1`,
+		`test_lag_rounds_bucket{le="1"} 1`,
+		`test_lag_rounds_bucket{le="+Inf"} 1`,
+		`test_lag_rounds_sum -1`,
+		`test_lag_rounds_count 1`,
+	}, "\n"))
+
+	for i := 0; i < seriesLimit+10; i++ {
+		h.Observe(strings.Repeat("p", i+1), 1)
+	}
+	require.Len(h.series, seriesLimit)
+}
@@ -0,0 +1,238 @@
+package p2p
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+)
+
+// a lagging node pulls the finalized rounds of a chain in batches from the
+// neighbors ahead of it, each batch has the snapshots of the complete rounds
+// in the range and their transactions, so the node doesn't need to confirm
+// each snapshot and request each transaction over the high latency links.
+// only one batch of a chain is in flight, besides the hedged one, and the pulled
+// rounds are not ahead of the local final round too much, to avoid flooding
+// the final pool.
+const (
+	snapshotBatchRoundsLimit    = config.SnapshotSyncRoundThreshold
+	snapshotBatchSnapshotsLimit = 512
+	snapshotBatchSizeLimit      = 4 * 1024 * 1024
+	snapshotBatchAheadLimit     = snapshotBatchRoundsLimit * 2
+	snapshotBatchTimeout        = 5 * time.Second
+	snapshotBatchPullingPeriod  = time.Minute
+)
+
+type SnapshotBatch struct {
+	NodeId       crypto.Hash
+	From         uint64
+	To           uint64
+	Snapshots    []*common.Snapshot
+	Transactions []*common.VersionedTransaction
+}
+
+func (me *Peer) pullSnapshotBatches(p *Peer, local []*SyncPoint, remote map[crypto.Hash]*SyncPoint) int {
+	me.batches.updateGraph(p.IdForNetwork, remote)
+
+	var requested int
+	for _, l := range local {
+		from, to, ok := me.batches.next(p.IdForNetwork, l.NodeId, l.Number, time.Now())
+		if !ok {
+			continue
+		}
+		err := me.handle.UpdateSyncFrontier(l.NodeId, to)
+		if err != nil {
+			logger.Verbosef("network.sync pullSnapshotBatches UpdateSyncFrontier %s %d %v\n", l.NodeId, to, err)
+		}
+		err = me.SendSnapshotBatchRequestMessage(p.IdForNetwork, l.NodeId, from, to)
+		logger.Verbosef("network.sync pullSnapshotBatches %s %s %d %d %v\n", p.IdForNetwork, l.NodeId, from, to, err)
+		requested += 1
+	}
+	return requested
+}
+
+// ResumeSnapshotBatches continues pulling the chain after the verified round,
+// whose snapshots are queued again by the node after the restart.
+func (me *Peer) ResumeSnapshotBatches(nodeId crypto.Hash, local, verified uint64) {
+	me.batches.resume(nodeId, local, verified, time.Now())
+}
+
+func (me *Peer) SendSnapshotBatchRequestMessage(idForNetwork, nodeId crypto.Hash, from, to uint64) error {
+	data := buildSnapshotBatchRequestMessage(nodeId, from, to)
+	key := crypto.Blake3Hash(binary.BigEndian.AppendUint64(data, uint64(time.Now().UnixNano())))
+	key = crypto.Blake3Hash(append(key[:], idForNetwork[:]...))
+	return me.sendHighToPeer(idForNetwork, PeerMessageTypeSnapshotBatchRequest, key[:], data)
+}
+
+func (me *Peer) sendSnapshotBatch(peerId, nodeId crypto.Hash, from, to uint64) error {
+	for _, p := range me.GetNeighbors(peerId) {
+		p.pulledAt.Store(time.Now().UnixNano())
+	}
+	batch, err := readSnapshotBatch(me.handle, nodeId, from, to)
+	if err != nil || batch == nil {
+		return err
+	}
+	for _, s := range batch.Snapshots {
+		me.ConfirmSnapshotForPeer(peerId, s.Hash)
+	}
+	data := buildSnapshotBatchMessage(batch)
+	key := crypto.Blake3Hash(data)
+	key = crypto.Blake3Hash(append(key[:], peerId[:]...))
+	return me.sendToPeer(peerId, PeerMessageTypeSnapshotBatch, key[:], data, MsgPriorityNormal)
+}
+
+func (me *Peer) receiveSnapshotBatch(peerId crypto.Hash, b *SnapshotBatch) error {
+	if !me.batches.done(peerId, b, time.Now()) {
+		logger.Verbosef("network.sync receiveSnapshotBatch %s %s %d %d unexpected\n", peerId, b.NodeId, b.From, b.To)
+		return nil
+	}
+	return me.handle.QueueAppendSnapshotBatch(peerId, b)
+}
+
+// readSnapshotBatch reads the complete rounds in the range, and stops before
+// the round exceeding the limits, but the first round is always included.
+func readSnapshotBatch(handle SyncHandle, nodeId crypto.Hash, from, to uint64) (*SnapshotBatch, error) {
+	batch := &SnapshotBatch{NodeId: nodeId, From: from}
+	var size int
+	for n := from; n <= min(to, from+snapshotBatchRoundsLimit-1); n++ {
+		ss, err := handle.ReadSnapshotsForNodeRound(nodeId, n)
+		if err != nil {
+			return nil, err
+		}
+		if len(ss) == 0 {
+			break
+		}
+		var snapshots []*common.Snapshot
+		var txs []*common.VersionedTransaction
+		var rs int
+		for _, s := range ss {
+			tx, err := handle.ReadTransaction(s.SoleTransaction())
+			if err != nil {
+				return nil, err
+			}
+			snapshots = append(snapshots, s.Snapshot)
+			txs = append(txs, tx)
+			rs += len(s.VersionedMarshal())
+			if tx != nil {
+				rs += len(tx.Marshal())
+			}
+		}
+		count := len(batch.Snapshots) + len(snapshots)
+		if n > from && (count > snapshotBatchSnapshotsLimit || size+rs > snapshotBatchSizeLimit) {
+			break
+		}
+		batch.Snapshots = append(batch.Snapshots, snapshots...)
+		batch.Transactions = append(batch.Transactions, txs...)
+		batch.To, size = n, size+rs
+	}
+	if len(batch.Snapshots) == 0 {
+		return nil, nil
+	}
+	return batch, nil
+}
+
+func buildSnapshotBatchRequestMessage(nodeId crypto.Hash, from, to uint64) []byte {
+	data := []byte{PeerMessageTypeSnapshotBatchRequest}
+	data = append(data, nodeId[:]...)
+	data = binary.BigEndian.AppendUint64(data, from)
+	return binary.BigEndian.AppendUint64(data, to)
+}
+
+func buildSnapshotBatchMessage(b *SnapshotBatch) []byte {
+	data := []byte{PeerMessageTypeSnapshotBatch}
+	data = append(data, b.NodeId[:]...)
+	data = binary.BigEndian.AppendUint64(data, b.From)
+	data = binary.BigEndian.AppendUint64(data, b.To)
+	data = binary.BigEndian.AppendUint16(data, uint16(len(b.Snapshots)))
+	for i, s := range b.Snapshots {
+		sd := s.VersionedMarshal()
+		data = binary.BigEndian.AppendUint32(data, uint32(len(sd)))
+		data = append(data, sd...)
+		var td []byte
+		if tx := b.Transactions[i]; tx != nil {
+			td = tx.Marshal()
+		}
+		data = binary.BigEndian.AppendUint32(data, uint32(len(td)))
+		data = append(data, td...)
+	}
+	return data
+}
+
+func parseSnapshotBatchRequestMessage(data []byte) (*SnapshotBatch, error) {
+	if len(data) != 49 {
+		return nil, fmt.Errorf("invalid snapshot batch request message size %d", len(data))
+	}
+	b := &SnapshotBatch{}
+	copy(b.NodeId[:], data[1:33])
+	b.From = binary.BigEndian.Uint64(data[33:41])
+	b.To = binary.BigEndian.Uint64(data[41:49])
+	if b.To < b.From {
+		return nil, fmt.Errorf("invalid snapshot batch request range %d %d", b.From, b.To)
+	}
+	return b, nil
+}
+
+func parseSnapshotBatchMessage(data []byte) (*SnapshotBatch, error) {
+	if len(data) < 51 {
+		return nil, fmt.Errorf("invalid snapshot batch message size %d", len(data))
+	}
+	b := &SnapshotBatch{}
+	copy(b.NodeId[:], data[1:33])
+	b.From = binary.BigEndian.Uint64(data[33:41])
+	b.To = binary.BigEndian.Uint64(data[41:49])
+	count := int(binary.BigEndian.Uint16(data[49:51]))
+	if count == 0 || count > snapshotBatchSnapshotsLimit {
+		return nil, fmt.Errorf("invalid snapshot batch count %d", count)
+	}
+	if b.To < b.From || b.To-b.From >= snapshotBatchRoundsLimit {
+		return nil, fmt.Errorf("invalid snapshot batch range %d %d", b.From, b.To)
+	}
+
+	offset := 51
+	next := func() ([]byte, error) {
+		if len(data[offset:]) < 4 {
+			return nil, fmt.Errorf("malformed snapshot batch message %d", offset)
+		}
+		size := int(binary.BigEndian.Uint32(data[offset : offset+4]))
+		offset = offset + 4
+		if len(data[offset:]) < size {
+			return nil, fmt.Errorf("malformed snapshot batch message %d %d", offset, size)
+		}
+		offset = offset + size
+		return data[offset-size : offset], nil
+	}
+	for range count {
+		sd, err := next()
+		if err != nil {
+			return nil, err
+		}
+		s, err := common.UnmarshalVersionedSnapshot(sd)
+		if err != nil {
+			return nil, err
+		}
+		if s.NodeId != b.NodeId || s.RoundNumber < b.From || s.RoundNumber > b.To {
+			return nil, fmt.Errorf("invalid snapshot batch snapshot %s %d", s.NodeId, s.RoundNumber)
+		}
+		td, err := next()
+		if err != nil {
+			return nil, err
+		}
+		var tx *common.VersionedTransaction
+		if len(td) > 0 {
+			tx, err = common.UnmarshalVersionedTransaction(td)
+			if err != nil {
+				return nil, err
+			}
+		}
+		b.Snapshots = append(b.Snapshots, s.Snapshot)
+		b.Transactions = append(b.Transactions, tx)
+	}
+	if offset != len(data) {
+		return nil, fmt.Errorf("malformed snapshot batch message %d %d", offset, len(data))
+	}
+	return b, nil
+}
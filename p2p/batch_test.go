@@ -0,0 +1,89 @@
+package p2p
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotBatch(t *testing.T) {
+	require := require.New(t)
+
+	h := &catchupTestHandle{
+		snapshots: make(map[string][]*common.SnapshotWithTopologicalOrder),
+		txs:       make(map[crypto.Hash]*common.VersionedTransaction),
+	}
+	a := crypto.Blake3Hash([]byte("chain-a"))
+	for n := range uint64(6) {
+		count := 2
+		if n >= 3 {
+			count = 300
+		}
+		for i := range count {
+			tx := common.NewTransactionV5(common.XINAssetId)
+			tx.Extra = []byte(fmt.Sprintf("%d:%d", n, i))
+			ver := tx.AsVersioned()
+			s := &common.Snapshot{
+				Version:      common.SnapshotVersionCommonEncoding,
+				NodeId:       a,
+				RoundNumber:  n,
+				References:   &common.RoundLink{},
+				Transactions: []crypto.Hash{ver.PayloadHash()},
+				Timestamp:    n*1000 + uint64(i),
+			}
+			s.Hash = s.PayloadHash()
+			key := fmt.Sprintf("%s:%d", a, n)
+			h.snapshots[key] = append(h.snapshots[key], &common.SnapshotWithTopologicalOrder{Snapshot: s})
+			if i > 0 {
+				h.txs[ver.PayloadHash()] = ver
+			}
+		}
+	}
+
+	batch, err := readSnapshotBatch(h, a, 1, 10)
+	require.Nil(err)
+	require.Equal(uint64(1), batch.From)
+	require.Equal(uint64(3), batch.To)
+	require.Len(batch.Snapshots, 2+2+300)
+	require.Nil(batch.Transactions[0])
+	require.NotNil(batch.Transactions[1])
+
+	data := buildSnapshotBatchMessage(batch)
+	msg, err := parseNetworkMessage(buildTransportFlags(data), data)
+	require.Nil(err)
+	require.Equal(uint8(PeerMessageTypeSnapshotBatch), msg.Type)
+	parsed := msg.SnapshotBatch
+	require.Equal(a, parsed.NodeId)
+	require.Equal(uint64(1), parsed.From)
+	require.Equal(uint64(3), parsed.To)
+	require.Len(parsed.Snapshots, len(batch.Snapshots))
+	for i, s := range parsed.Snapshots {
+		require.Equal(batch.Snapshots[i].Hash, s.PayloadHash())
+		if tx := batch.Transactions[i]; tx != nil {
+			require.Equal(tx.PayloadHash(), parsed.Transactions[i].PayloadHash())
+		} else {
+			require.Nil(parsed.Transactions[i])
+		}
+	}
+	_, err = parseSnapshotBatchMessage(data[:len(data)-1])
+	require.NotNil(err)
+
+	batch, err = readSnapshotBatch(h, a, 4, 10)
+	require.Nil(err)
+	require.Equal(uint64(4), batch.To)
+	require.Len(batch.Snapshots, 300)
+	batch, err = readSnapshotBatch(h, a, 6, 10)
+	require.Nil(err)
+	require.Nil(batch)
+
+	data = buildSnapshotBatchRequestMessage(a, 1, 100)
+	msg, err = parseNetworkMessage(buildTransportFlags(data), data)
+	require.Nil(err)
+	require.Equal(uint8(PeerMessageTypeSnapshotBatchRequest), msg.Type)
+	require.Equal(&SnapshotBatch{NodeId: a, From: 1, To: 100}, msg.SnapshotBatch)
+	_, err = parseSnapshotBatchRequestMessage(buildSnapshotBatchRequestMessage(a, 2, 1))
+	require.NotNil(err)
+}
@@ -0,0 +1,173 @@
+package p2p
+
+import (
+	"bytes"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+const (
+	catchupChainsParallel = 8
+	catchupRoundsWindow   = config.SnapshotSyncRoundThreshold
+	catchupPullingWindow  = config.SnapshotReferenceThreshold
+)
+
+// catchupScheduler pushes the rounds of all chains to a lagging peer. Each
+// chain has its own cursor and the chains advance concurrently, instead of
+// the single topological order of the sender, so a slow chain doesn't block
+// the others. A round is sent only after the external rounds referenced by
+// its snapshots, which are either finalized by the peer already or sent in
+// this pass, so the peer is able to finalize the rounds of all chains in
+// parallel. The chains are ordered differently by each sender, so the peers
+// serving the same lagging node start from different chains.
+type catchupScheduler struct {
+	handle  SyncHandle
+	remote  map[crypto.Hash]*SyncPoint
+	cursors map[crypto.Hash]*catchupCursor
+	order   []*catchupCursor
+	send    func(s *common.Snapshot) error
+
+	rounds sync.Map
+}
+
+type catchupCursor struct {
+	nodeId crypto.Hash
+	next   atomic.Uint64
+	last   uint64
+	sorter crypto.Hash
+}
+
+func newCatchupScheduler(handle SyncHandle, self crypto.Hash, local []*SyncPoint, remote map[crypto.Hash]*SyncPoint, window uint64, send func(s *common.Snapshot) error) *catchupScheduler {
+	cs := &catchupScheduler{
+		handle:  handle,
+		remote:  remote,
+		cursors: make(map[crypto.Hash]*catchupCursor),
+		send:    send,
+	}
+	for _, l := range local {
+		var next uint64
+		if r := remote[l.NodeId]; r != nil {
+			if r.Number > l.Number {
+				continue
+			}
+			next = r.Number
+		}
+		c := &catchupCursor{
+			nodeId: l.NodeId,
+			last:   min(l.Number+1, next+window),
+			sorter: crypto.Blake3Hash(append(self[:], l.NodeId[:]...)),
+		}
+		c.next.Store(next)
+		cs.cursors[l.NodeId] = c
+		cs.order = append(cs.order, c)
+	}
+	slices.SortFunc(cs.order, func(a, b *catchupCursor) int {
+		return bytes.Compare(a.sorter[:], b.sorter[:])
+	})
+	return cs
+}
+
+// run advances all chains in passes until no chain could advance, and
+// returns the count of rounds sent.
+func (cs *catchupScheduler) run(closing func() bool) (int, error) {
+	var total int
+	for !closing() {
+		var wg sync.WaitGroup
+		var progress atomic.Int64
+		var failure atomic.Pointer[error]
+		sem := make(chan struct{}, catchupChainsParallel)
+		for _, c := range cs.order {
+			if c.next.Load() > c.last {
+				continue
+			}
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(c *catchupCursor) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				n, err := cs.advance(c, closing)
+				progress.Add(int64(n))
+				if err != nil {
+					failure.CompareAndSwap(nil, &err)
+				}
+			}(c)
+		}
+		wg.Wait()
+		total += int(progress.Load())
+		if err := failure.Load(); err != nil {
+			return total, *err
+		}
+		if progress.Load() == 0 {
+			break
+		}
+	}
+	return total, nil
+}
+
+func (cs *catchupScheduler) advance(c *catchupCursor, closing func() bool) (int, error) {
+	var sent int
+	for n := c.next.Load(); n <= c.last && !closing(); n++ {
+		ss, err := cs.handle.ReadSnapshotsForNodeRound(c.nodeId, n)
+		if err != nil || len(ss) == 0 {
+			return sent, err
+		}
+		for _, s := range ss {
+			if s.References == nil {
+				continue
+			}
+			ready, err := cs.referenceReady(s.References.External)
+			if err != nil || !ready {
+				return sent, err
+			}
+		}
+		for _, s := range ss {
+			err := cs.send(s.Snapshot)
+			if err != nil {
+				return sent, err
+			}
+		}
+		c.next.Store(n + 1)
+		sent += 1
+	}
+	return sent, nil
+}
+
+// the external round is ready if finalized by the peer or sent already, and
+// the round of a chain not scheduled is not blocked on, because it could never
+// be sent by this scheduler.
+func (cs *catchupScheduler) referenceReady(external crypto.Hash) (bool, error) {
+	round, err := cs.readRound(external)
+	if err != nil {
+		return false, err
+	}
+	if round == nil {
+		return true, nil
+	}
+	if r := cs.remote[round.NodeId]; r != nil && round.Number <= r.Number {
+		return true, nil
+	}
+	c := cs.cursors[round.NodeId]
+	if c == nil {
+		return true, nil
+	}
+	return round.Number < c.next.Load(), nil
+}
+
+func (cs *catchupScheduler) readRound(hash crypto.Hash) (*common.Round, error) {
+	if r, found := cs.rounds.Load(hash); found {
+		return r.(*common.Round), nil
+	}
+	round, err := cs.handle.ReadRound(hash)
+	if err != nil || round == nil {
+		return nil, err
+	}
+	cs.rounds.Store(hash, round)
+	return round, nil
+}
@@ -0,0 +1,110 @@
+package p2p
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+type catchupTestHandle struct {
+	SyncHandle
+	rounds    map[crypto.Hash]*common.Round
+	snapshots map[string][]*common.SnapshotWithTopologicalOrder
+	txs       map[crypto.Hash]*common.VersionedTransaction
+}
+
+func (h *catchupTestHandle) ReadTransaction(hash crypto.Hash) (*common.VersionedTransaction, error) {
+	return h.txs[hash], nil
+}
+
+func (h *catchupTestHandle) ReadRound(hash crypto.Hash) (*common.Round, error) {
+	return h.rounds[hash], nil
+}
+
+func (h *catchupTestHandle) ReadSnapshotsForNodeRound(nodeId crypto.Hash, number uint64) ([]*common.SnapshotWithTopologicalOrder, error) {
+	return h.snapshots[fmt.Sprintf("%s:%d", nodeId, number)], nil
+}
+
+func (h *catchupTestHandle) addRound(nodeId crypto.Hash, number uint64, external *common.Round) *common.Round {
+	round := &common.Round{
+		Hash:   crypto.Blake3Hash([]byte(fmt.Sprintf("%s:%d", nodeId, number))),
+		NodeId: nodeId,
+		Number: number,
+	}
+	h.rounds[round.Hash] = round
+	s := &common.Snapshot{NodeId: nodeId, RoundNumber: number}
+	s.Hash = crypto.Blake3Hash(round.Hash[:])
+	if external != nil {
+		s.References = &common.RoundLink{External: external.Hash}
+	}
+	key := fmt.Sprintf("%s:%d", nodeId, number)
+	h.snapshots[key] = append(h.snapshots[key], &common.SnapshotWithTopologicalOrder{Snapshot: s})
+	return round
+}
+
+func TestCatchupScheduler(t *testing.T) {
+	require := require.New(t)
+
+	h := &catchupTestHandle{
+		rounds:    make(map[crypto.Hash]*common.Round),
+		snapshots: make(map[string][]*common.SnapshotWithTopologicalOrder),
+	}
+	a := crypto.Blake3Hash([]byte("chain-a"))
+	b := crypto.Blake3Hash([]byte("chain-b"))
+	c := crypto.Blake3Hash([]byte("chain-c"))
+	var ra, rb []*common.Round
+	for i := range 11 {
+		var ea, eb *common.Round
+		if i > 0 {
+			ea, eb = rb[i-1], ra[i-1]
+		}
+		ra = append(ra, h.addRound(a, uint64(i), ea))
+		rb = append(rb, h.addRound(b, uint64(i), eb))
+	}
+	for i := range 6 {
+		h.addRound(c, uint64(i), nil)
+	}
+
+	local := []*SyncPoint{{NodeId: a, Number: 10}, {NodeId: b, Number: 10}, {NodeId: c, Number: 5}}
+	remote := map[crypto.Hash]*SyncPoint{a: {NodeId: a, Number: 2}, c: {NodeId: c, Number: 8}}
+
+	var mutex sync.Mutex
+	var sent []*common.Snapshot
+	cs := newCatchupScheduler(h, crypto.Blake3Hash([]byte("self")), local, remote, catchupRoundsWindow, func(s *common.Snapshot) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		sent = append(sent, s)
+		return nil
+	})
+	require.Len(cs.order, 2)
+	require.Equal(uint64(2), cs.cursors[a].next.Load())
+	require.Equal(uint64(0), cs.cursors[b].next.Load())
+	require.Equal(uint64(11), cs.cursors[a].last)
+
+	rounds, err := cs.run(func() bool { return false })
+	require.Nil(err)
+	require.Equal(9+11, rounds)
+	require.Len(sent, 9+11)
+
+	finalized := map[crypto.Hash]uint64{a: 2}
+	done := make(map[crypto.Hash]bool)
+	for _, s := range sent {
+		require.NotEqual(c, s.NodeId)
+		if s.References != nil {
+			ext := h.rounds[s.References.External]
+			require.True(done[ext.Hash] || ext.Number <= finalized[ext.NodeId] && remote[ext.NodeId] != nil,
+				"%s:%d sent before %s:%d", s.NodeId, s.RoundNumber, ext.NodeId, ext.Number)
+		}
+		done[crypto.Blake3Hash([]byte(fmt.Sprintf("%s:%d", s.NodeId, s.RoundNumber)))] = true
+	}
+	require.Equal(uint64(11), cs.cursors[a].next.Load())
+	require.Equal(uint64(11), cs.cursors[b].next.Load())
+
+	rounds, err = cs.run(func() bool { return false })
+	require.Nil(err)
+	require.Equal(0, rounds)
+}
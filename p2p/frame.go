@@ -0,0 +1,82 @@
+package p2p
+
+import (
+	"fmt"
+)
+
+const (
+	TransportMessageFlagOptional     = 0x01
+	TransportMessageFlagVersionShift = 4
+)
+
+// every transport message header carries the flags byte after the transport
+// version, the high four bits are the version of the message type in the
+// payload, and the optional bit tells the receiver what to do when it doesn't
+// understand the message. an optional message with unknown type or newer
+// version is skipped silently, while a mandatory one is a protocol violation.
+// so new message types should be introduced as optional first, and become
+// mandatory only after all nodes are able to handle them.
+type peerMessageSpec struct {
+	version  uint8
+	optional bool
+}
+
+var peerMessageSpecs = map[uint8]peerMessageSpec{
+	PeerMessageTypePing:                 {},
+	PeerMessageTypeAuthentication:       {},
+	PeerMessageTypeGraph:                {},
+	PeerMessageTypeSnapshotConfirm:      {},
+	PeerMessageTypeTransactionRequest:   {},
+	PeerMessageTypeTransaction:          {},
+	PeerMessageTypeSnapshotAnnouncement: {},
+	PeerMessageTypeSnapshotCommitment:   {},
+	PeerMessageTypeTransactionChallenge: {},
+	PeerMessageTypeSnapshotResponse:     {},
+	PeerMessageTypeSnapshotFinalization: {},
+	PeerMessageTypeCommitments:          {},
+	PeerMessageTypeFullChallenge:        {},
+	PeerMessageTypeRelay:                {},
+	PeerMessageTypeConsumers:            {},
+	PeerMessageTypeElectionWarning:      {optional: true},
+	PeerMessageTypeSnapshotBatchRequest: {optional: true},
+	PeerMessageTypeSnapshotBatch:        {optional: true},
+	PeerMessageTypeRoundRequest:         {optional: true},
+	PeerMessageTypeStateCommitment:      {optional: true},
+}
+
+func buildTransportFlags(data []byte) uint8 {
+	if len(data) < 1 {
+		return 0
+	}
+	typ := data[0]
+	if typ == PeerMessageTypeRelay && len(data) > 65 {
+		typ = data[65]
+	}
+	spec, found := peerMessageSpecs[typ]
+	if !found {
+		return 0
+	}
+	flags := spec.version << TransportMessageFlagVersionShift
+	if spec.optional {
+		flags |= TransportMessageFlagOptional
+	}
+	return flags
+}
+
+// returns true if the message should be skipped, or an error if the message
+// is mandatory but not understood by this node
+func checkTransportFlags(flags uint8, typ uint8) (bool, error) {
+	version := flags >> TransportMessageFlagVersionShift
+	optional := flags&TransportMessageFlagOptional == TransportMessageFlagOptional
+	spec, found := peerMessageSpecs[typ]
+	switch {
+	case found && version <= spec.version:
+		return false, nil
+	case optional:
+		return true, nil
+	case !found:
+		return false, fmt.Errorf("unknown mandatory message type %d", typ)
+	default:
+		return false, fmt.Errorf("unsupported mandatory message type %d version %d", typ, version)
+	}
+}
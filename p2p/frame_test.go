@@ -0,0 +1,59 @@
+package p2p
+
+import (
+	"testing"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransportFlags(t *testing.T) {
+	require := require.New(t)
+
+	snap := crypto.Blake3Hash([]byte("snapshot"))
+	data := buildSnapshotConfirmMessage(snap)
+	flags := buildTransportFlags(data)
+	require.Equal(uint8(0), flags)
+	msg, err := parseNetworkMessage(flags, data)
+	require.Nil(err)
+	require.False(msg.skipped)
+	require.Equal(snap, msg.SnapshotHash)
+
+	unknown := []byte{99, 1, 2, 3}
+	require.Equal(uint8(0), buildTransportFlags(unknown))
+	msg, err = parseNetworkMessage(0, unknown)
+	require.NotNil(err)
+	require.Nil(msg)
+	msg, err = parseNetworkMessage(TransportMessageFlagOptional, unknown)
+	require.Nil(err)
+	require.True(msg.skipped)
+	require.Equal(uint8(99), msg.Type)
+
+	newer := uint8(1) << TransportMessageFlagVersionShift
+	msg, err = parseNetworkMessage(newer, data)
+	require.NotNil(err)
+	require.Nil(msg)
+	msg, err = parseNetworkMessage(newer|TransportMessageFlagOptional, data)
+	require.Nil(err)
+	require.True(msg.skipped)
+
+	peerMessageSpecs[99] = peerMessageSpec{version: 2, optional: true}
+	defer delete(peerMessageSpecs, 99)
+	require.Equal(uint8(0x21), buildTransportFlags(unknown))
+	relay := append([]byte{PeerMessageTypeRelay}, make([]byte, 64)...)
+	relay = append(relay, unknown...)
+	require.Equal(uint8(0x21), buildTransportFlags(relay))
+	msg, err = parseNetworkMessage(0x21, unknown)
+	require.Nil(err)
+	require.False(msg.skipped)
+
+	round := crypto.Blake3Hash([]byte("round"))
+	data = buildRoundRequestMessage(round)
+	require.Equal(uint8(TransportMessageFlagOptional), buildTransportFlags(data))
+	msg, err = parseNetworkMessage(buildTransportFlags(data), data)
+	require.Nil(err)
+	require.Equal(uint8(PeerMessageTypeRoundRequest), msg.Type)
+	require.Equal(round, msg.SnapshotHash)
+	_, err = parseNetworkMessage(TransportMessageFlagOptional, data[:32])
+	require.NotNil(err)
+}
@@ -0,0 +1,89 @@
+//go:build fuzz
+
+package p2p
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+// the seeds are built from the transactions and snapshots of the mainnet
+// genesis, run with go test -tags fuzz -fuzz FuzzParseNetworkMessage ./p2p
+func FuzzParseNetworkMessage(f *testing.F) {
+	gns, err := common.ReadGenesis("../config/genesis.json")
+	if err != nil {
+		f.Fatal(err)
+	}
+	_, snapshots, transactions, err := gns.BuildSnapshots()
+	if err != nil {
+		f.Fatal(err)
+	}
+	s, tx := snapshots[0].Snapshot, transactions[0]
+	hash := crypto.Blake3Hash([]byte("fuzz"))
+	key := crypto.NewKeyFromSeed(append(hash[:], hash[:]...))
+	cosi := &crypto.CosiSignature{Mask: 1}
+	signed := *s
+	signed.Signature = cosi
+	var sig crypto.Signature
+
+	points := marshalSyncPoints([]*SyncPoint{{NodeId: s.NodeId, Number: 1, Hash: hash}})
+	commitments := binary.BigEndian.AppendUint16(nil, 1)
+	commitments = append(commitments, key[:]...)
+	announcement := append(sig[:], key[:]...)
+	announcement = append(announcement, s.VersionedMarshal()...)
+	commitment := append(sig[:], hash[:]...)
+	commitment = append(commitment, key[:]...)
+	for _, data := range [][]byte{
+		{PeerMessageTypePing},
+		buildAuthenticationMessage(hash[:]),
+		append([]byte{PeerMessageTypeGraph}, append(sig[:], points...)...),
+		append([]byte{PeerMessageTypeCommitments}, append(sig[:], commitments...)...),
+		append([]byte{PeerMessageTypeSnapshotAnnouncement}, announcement...),
+		append([]byte{PeerMessageTypeSnapshotCommitment}, append(commitment, 1)...),
+		buildSnapshotConfirmMessage(hash),
+		buildTransactionRequestMessage(hash),
+		buildTransactionMessage(tx),
+		buildTransactionChallengeMessage(hash, cosi, tx),
+		buildFullChanllengeMessage(&signed, &key, &key, tx),
+		buildFullChanllengeMessage(s, &key, &key, tx),
+		buildSnapshotResponseMessage(hash, (*[32]byte)(&hash)),
+		buildSnapshotFinalizationMessage(s),
+		buildRoundRequestMessage(hash),
+		buildSnapshotBatchRequestMessage(s.NodeId, 0, 1),
+		buildSnapshotBatchMessage(&SnapshotBatch{
+			NodeId:       s.NodeId,
+			To:           1,
+			Snapshots:    []*common.Snapshot{s},
+			Transactions: []*common.VersionedTransaction{tx},
+		}),
+		append([]byte{PeerMessageTypeElectionWarning}, make([]byte, 169)...),
+		append([]byte{PeerMessageTypeStateCommitment}, make([]byte, 136)...),
+	} {
+		f.Add(uint8(0), data)
+		f.Add(uint8(TransportMessageFlagOptional), data)
+	}
+
+	f.Fuzz(func(t *testing.T, flags uint8, data []byte) {
+		msg, err := parseNetworkMessage(flags, data)
+		if err != nil {
+			return
+		}
+		if msg.Snapshot != nil {
+			msg.Snapshot.PayloadHash()
+		}
+		if msg.Transaction != nil {
+			msg.Transaction.PayloadHash()
+		}
+		if b := msg.SnapshotBatch; b != nil {
+			for i, s := range b.Snapshots {
+				s.PayloadHash()
+				if tx := b.Transactions[i]; tx != nil {
+					tx.PayloadHash()
+				}
+			}
+		}
+	})
+}
@@ -28,6 +28,11 @@ const (
 	PeerMessageTypeSnapshotFinalization = 14 // leader generate A, verify si B = ri B + H(R || A || M)ai B = Ri + H(R || A || M)Ai, then finalize based on threshold
 	PeerMessageTypeCommitments          = 15
 	PeerMessageTypeFullChallenge        = 16
+	PeerMessageTypeElectionWarning      = 17
+	PeerMessageTypeSnapshotBatchRequest = 18
+	PeerMessageTypeSnapshotBatch        = 19
+	PeerMessageTypeRoundRequest         = 20
+	PeerMessageTypeStateCommitment      = 21
 
 	PeerMessageTypeRelay     = 200
 	PeerMessageTypeConsumers = 201
@@ -49,11 +54,13 @@ type PeerMessage struct {
 	WantTx          bool
 	Commitments     []*crypto.Key
 	Graph           []*SyncPoint
+	SnapshotBatch   *SnapshotBatch
 	Data            []byte
 
 	unsigned  []byte
 	signature *crypto.Signature
-	version   byte
+	flags     byte
+	skipped   bool
 }
 
 type AuthToken struct {
@@ -61,18 +68,25 @@ type AuthToken struct {
 	Timestamp uint64
 	IsRelayer bool
 	Data      []byte
+
+	// the timestamp is by the clock of the handle, which may be mocked in
+	// the tests, and it was within the handshake timeout when accepted, so
+	// the token lifetime is since the local acceptance
+	acceptedAt time.Time
 }
 
 type SyncHandle interface {
 	GetCacheStore() *ristretto.Cache[[]byte, any]
-	SignData(data []byte) crypto.Signature
-	BuildAuthenticationMessage(relayerId crypto.Hash) []byte
+	SignData(data []byte) (crypto.Signature, error)
+	BuildAuthenticationMessage(relayerId crypto.Hash) ([]byte, error)
 	AuthenticateAs(recipientId crypto.Hash, msg []byte, timeoutSec int64) (*AuthToken, error)
+	RecordAuthenticationFailure(address string, err error)
 	BuildGraph() []*SyncPoint
 	UpdateSyncPoint(peerId crypto.Hash, points []*SyncPoint, data []byte, sig *crypto.Signature) error
 	ReadAllNodesWithoutState() []crypto.Hash
-	ReadSnapshotsSinceTopology(offset, count uint64) ([]*common.SnapshotWithTopologicalOrder, error)
+	ReadRound(hash crypto.Hash) (*common.Round, error)
 	ReadSnapshotsForNodeRound(nodeIdWithNetwork crypto.Hash, round uint64) ([]*common.SnapshotWithTopologicalOrder, error)
+	ReadTransaction(hash crypto.Hash) (*common.VersionedTransaction, error)
 	SendTransactionToPeer(peerId, tx crypto.Hash) error
 	CachePutTransaction(peerId crypto.Hash, ver *common.VersionedTransaction) error
 	CosiQueueExternalAnnouncement(peerId crypto.Hash, s *common.Snapshot, R *crypto.Key, sig *crypto.Signature) error
@@ -81,29 +95,61 @@ type SyncHandle interface {
 	CosiQueueExternalFullChallenge(peerId crypto.Hash, s *common.Snapshot, commitment, challenge *crypto.Key, cosi *crypto.CosiSignature, ver *common.VersionedTransaction) error
 	CosiAggregateSelfResponses(peerId crypto.Hash, snap crypto.Hash, response *[32]byte) error
 	VerifyAndQueueAppendSnapshotFinalization(peerId crypto.Hash, s *common.Snapshot) error
+	QueueAppendSnapshotBatch(peerId crypto.Hash, b *SnapshotBatch) error
+	UpdateSyncFrontier(nodeId crypto.Hash, requested uint64) error
 	CosiQueueExternalCommitments(peerId crypto.Hash, commitments []*crypto.Key, data []byte, sig *crypto.Signature) error
+	ReceiveElectionWarning(peerId crypto.Hash, data []byte) error
+	ReceiveStateCommitment(peerId crypto.Hash, data []byte) error
 }
 
 func (me *Peer) SendGraphMessage(idForNetwork crypto.Hash) error {
-	msg := buildGraphMessage(me.handle)
+	msg, err := buildGraphMessage(me.handle)
+	if err != nil {
+		return err
+	}
 	return me.sendHighToPeer(idForNetwork, PeerMessageTypeGraph, nil, msg)
 }
 
 func (me *Peer) SendCommitmentsMessage(idForNetwork crypto.Hash, commitments []*crypto.Key) error {
-	data := buildCommitmentsMessage(me.handle, commitments)
+	data, err := buildCommitmentsMessage(me.handle, commitments)
+	if err != nil {
+		return err
+	}
 	hash := crypto.Blake3Hash(data)
 	key := append(idForNetwork[:], 'C', 'R')
 	key = append(key, hash[:]...)
 	return me.sendHighToPeer(idForNetwork, PeerMessageTypeCommitments, key, data)
 }
 
-func (me *Peer) SendSnapshotAnnouncementMessage(idForNetwork crypto.Hash, s *common.Snapshot, R crypto.Key, spend crypto.Key) error {
-	data := buildSnapshotAnnouncementMessage(s, R, spend)
+func (me *Peer) SendElectionWarningMessage(idForNetwork crypto.Hash, warning []byte) error {
+	hash := crypto.Blake3Hash(warning)
+	key := append(idForNetwork[:], 'E', 'W')
+	key = append(key, hash[:]...)
+	data := append([]byte{PeerMessageTypeElectionWarning}, warning...)
+	return me.sendToPeer(idForNetwork, PeerMessageTypeElectionWarning, key, data, MsgPriorityNormal)
+}
+
+func (me *Peer) SendStateCommitmentMessage(idForNetwork crypto.Hash, commitment []byte) error {
+	hash := crypto.Blake3Hash(commitment)
+	key := append(idForNetwork[:], 'S', 'C')
+	key = append(key, hash[:]...)
+	data := append([]byte{PeerMessageTypeStateCommitment}, commitment...)
+	return me.sendToPeer(idForNetwork, PeerMessageTypeStateCommitment, key, data, MsgPriorityNormal)
+}
+
+func (me *Peer) SendSnapshotAnnouncementMessage(idForNetwork crypto.Hash, s *common.Snapshot, R crypto.Key) error {
+	data, err := buildSnapshotAnnouncementMessage(me.handle, s, R)
+	if err != nil {
+		return err
+	}
 	return me.sendSnapshotMessageToPeer(idForNetwork, s.PayloadHash(), PeerMessageTypeSnapshotAnnouncement, data)
 }
 
 func (me *Peer) SendSnapshotCommitmentMessage(idForNetwork crypto.Hash, snap crypto.Hash, R crypto.Key, wantTx bool) error {
-	data := buildSnapshotCommitmentMessage(me.handle, snap, R, wantTx)
+	data, err := buildSnapshotCommitmentMessage(me.handle, snap, R, wantTx)
+	if err != nil {
+		return err
+	}
 	return me.sendSnapshotMessageToPeer(idForNetwork, snap, PeerMessageTypeSnapshotCommitment, data)
 }
 
@@ -149,6 +195,35 @@ func (me *Peer) SendTransactionRequestMessage(idForNetwork crypto.Hash, tx crypt
 	return me.sendHighToPeer(idForNetwork, PeerMessageTypeTransactionRequest, key, buildTransactionRequestMessage(tx))
 }
 
+func (me *Peer) SendRoundRequestMessage(idForNetwork crypto.Hash, round crypto.Hash) error {
+	key := append(idForNetwork[:], round[:]...)
+	key = binary.BigEndian.AppendUint64(key, uint64(time.Now().UnixNano()))
+	key = append(key, 'R', 'O', 'U', 'N', 'D', PeerMessageTypeRoundRequest)
+	return me.sendHighToPeer(idForNetwork, PeerMessageTypeRoundRequest, key, buildRoundRequestMessage(round))
+}
+
+// sendRound sends the finalization messages of the round requested by hash,
+// regardless of the peer confirmed them before, because the peer asks for
+// the dependency missing.
+func (me *Peer) sendRound(peerId, hash crypto.Hash) error {
+	round, err := me.handle.ReadRound(hash)
+	if err != nil || round == nil {
+		return err
+	}
+	ss, err := me.handle.ReadSnapshotsForNodeRound(round.NodeId, round.Number)
+	if err != nil {
+		return err
+	}
+	for _, s := range ss {
+		data := buildSnapshotFinalizationMessage(s.Snapshot)
+		err := me.sendSnapshotMessageToPeer(peerId, s.Hash, PeerMessageTypeSnapshotFinalization, data)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (me *Peer) SendTransactionMessage(idForNetwork crypto.Hash, ver *common.VersionedTransaction) error {
 	tx := ver.PayloadHash()
 	key := append(idForNetwork[:], tx[:]...)
@@ -167,24 +242,30 @@ func buildAuthenticationMessage(data []byte) []byte {
 	return append(header, data...)
 }
 
-func buildSnapshotAnnouncementMessage(s *common.Snapshot, R, spend crypto.Key) []byte {
+func buildSnapshotAnnouncementMessage(handle SyncHandle, s *common.Snapshot, R crypto.Key) ([]byte, error) {
 	data := s.VersionedMarshal()
 	data = append(R[:], data...)
-	sig := spend.Sign(crypto.Blake3Hash(data))
+	sig, err := handle.SignData(data)
+	if err != nil {
+		return nil, err
+	}
 	data = append(sig[:], data...)
-	return append([]byte{PeerMessageTypeSnapshotAnnouncement}, data...)
+	return append([]byte{PeerMessageTypeSnapshotAnnouncement}, data...), nil
 }
 
-func buildSnapshotCommitmentMessage(handle SyncHandle, snap crypto.Hash, R crypto.Key, wantTx bool) []byte {
+func buildSnapshotCommitmentMessage(handle SyncHandle, snap crypto.Hash, R crypto.Key, wantTx bool) ([]byte, error) {
 	data := append(snap[:], R[:]...)
 	if wantTx {
 		data = append(data, byte(1))
 	} else {
 		data = append(data, byte(0))
 	}
-	sig := handle.SignData(data)
+	sig, err := handle.SignData(data)
+	if err != nil {
+		return nil, err
+	}
 	data = append(sig[:], data...)
-	return append([]byte{PeerMessageTypeSnapshotCommitment}, data...)
+	return append([]byte{PeerMessageTypeSnapshotCommitment}, data...), nil
 }
 
 func buildTransactionChallengeMessage(snap crypto.Hash, cosi *crypto.CosiSignature, tx *common.VersionedTransaction) []byte {
@@ -234,19 +315,26 @@ func buildTransactionMessage(ver *common.VersionedTransaction) []byte {
 	return append([]byte{PeerMessageTypeTransaction}, data...)
 }
 
+func buildRoundRequestMessage(round crypto.Hash) []byte {
+	return append([]byte{PeerMessageTypeRoundRequest}, round[:]...)
+}
+
 func buildTransactionRequestMessage(tx crypto.Hash) []byte {
 	return append([]byte{PeerMessageTypeTransactionRequest}, tx[:]...)
 }
 
-func buildGraphMessage(handle SyncHandle) []byte {
+func buildGraphMessage(handle SyncHandle) ([]byte, error) {
 	points := handle.BuildGraph()
 	data := marshalSyncPoints(points)
-	sig := handle.SignData(data)
+	sig, err := handle.SignData(data)
+	if err != nil {
+		return nil, err
+	}
 	data = append(sig[:], data...)
-	return append([]byte{PeerMessageTypeGraph}, data...)
+	return append([]byte{PeerMessageTypeGraph}, data...), nil
 }
 
-func buildCommitmentsMessage(handle SyncHandle, commitments []*crypto.Key) []byte {
+func buildCommitmentsMessage(handle SyncHandle, commitments []*crypto.Key) ([]byte, error) {
 	if len(commitments) > 1024 {
 		panic(len(commitments))
 	}
@@ -254,9 +342,12 @@ func buildCommitmentsMessage(handle SyncHandle, commitments []*crypto.Key) []byt
 	for _, k := range commitments {
 		data = append(data, k[:]...)
 	}
-	sig := handle.SignData(data)
+	sig, err := handle.SignData(data)
+	if err != nil {
+		return nil, err
+	}
 	data = append(sig[:], data...)
-	return append([]byte{PeerMessageTypeCommitments}, data...)
+	return append([]byte{PeerMessageTypeCommitments}, data...), nil
 }
 
 func (me *Peer) buildConsumersMessage() []byte {
@@ -264,7 +355,7 @@ func (me *Peer) buildConsumersMessage() []byte {
 	peers := me.consumers.Slice()
 	for _, p := range peers {
 		data = append(data, p.IdForNetwork[:]...)
-		data = append(data, p.consumerAuth.Data...)
+		data = append(data, p.consumerAuth.Load().Data...)
 	}
 	return data
 }
@@ -280,11 +371,18 @@ func (me *Peer) buildRelayMessage(peerId crypto.Hash, msg []byte) []byte {
 	return data
 }
 
-func parseNetworkMessage(version uint8, data []byte) (*PeerMessage, error) {
+func parseNetworkMessage(flags uint8, data []byte) (*PeerMessage, error) {
 	if len(data) < 1 {
 		return nil, errors.New("invalid message data")
 	}
-	msg := &PeerMessage{Type: data[0], version: version}
+	msg := &PeerMessage{Type: data[0], flags: flags}
+	skip, err := checkTransportFlags(flags, msg.Type)
+	if err != nil {
+		return nil, err
+	} else if skip {
+		msg.skipped = true
+		return msg, nil
+	}
 	switch msg.Type {
 	case PeerMessageTypeCommitments:
 		if len(data) < 80 {
@@ -307,6 +405,9 @@ func parseNetworkMessage(version uint8, data []byte) (*PeerMessage, error) {
 		msg.signature = &sig
 		msg.unsigned = data[65:]
 	case PeerMessageTypeGraph:
+		if len(data) < 65 {
+			return nil, fmt.Errorf("invalid graph message size %d", len(data))
+		}
 		var sig crypto.Signature
 		copy(sig[:], data[1:])
 		points, err := unmarshalSyncPoints(data[65:])
@@ -369,6 +470,9 @@ func parseNetworkMessage(version uint8, data []byte) (*PeerMessage, error) {
 		if err != nil {
 			return nil, fmt.Errorf("invalid full challenge snapshot %v", err)
 		}
+		if s.Snapshot.Signature == nil {
+			return nil, fmt.Errorf("invalid full challenge snapshot signature %s", s.PayloadHash())
+		}
 		msg.Snapshot = s.Snapshot
 		offset = offset + size
 		if len(data[offset:]) < 256 {
@@ -383,10 +487,10 @@ func parseNetworkMessage(version uint8, data []byte) (*PeerMessage, error) {
 		offset = offset + 32
 
 		size = int(binary.BigEndian.Uint32(data[offset : offset+4]))
+		offset = offset + 4
 		if len(data[offset:]) < size {
 			return nil, fmt.Errorf("invalid full challenge transaction size %d %d", len(data[offset:]), size)
 		}
-		offset = offset + 4
 		ver, err := common.UnmarshalVersionedTransaction(data[offset : offset+size])
 		if err != nil {
 			return nil, fmt.Errorf("invalid full challenge transaction %v", err)
@@ -425,6 +529,27 @@ func parseNetworkMessage(version uint8, data []byte) (*PeerMessage, error) {
 		msg.Data = data
 	case PeerMessageTypeConsumers:
 		msg.Data = data[1:]
+	case PeerMessageTypeElectionWarning:
+		msg.Data = data[1:]
+	case PeerMessageTypeStateCommitment:
+		msg.Data = data[1:]
+	case PeerMessageTypeRoundRequest:
+		if len(data[1:]) != 32 {
+			return nil, fmt.Errorf("invalid round request message size %d", len(data[1:]))
+		}
+		copy(msg.SnapshotHash[:], data[1:])
+	case PeerMessageTypeSnapshotBatchRequest:
+		b, err := parseSnapshotBatchRequestMessage(data)
+		if err != nil {
+			return nil, err
+		}
+		msg.SnapshotBatch = b
+	case PeerMessageTypeSnapshotBatch:
+		b, err := parseSnapshotBatchMessage(data)
+		if err != nil {
+			return nil, err
+		}
+		msg.SnapshotBatch = b
 	}
 	return msg, nil
 }
@@ -438,7 +563,7 @@ func (me *Peer) relayOrHandlePeerMessage(relayerId crypto.Hash, msg *PeerMessage
 	copy(from[:], msg.Data[1:33])
 	copy(to[:], msg.Data[33:65])
 	if to == me.IdForNetwork {
-		rm, err := parseNetworkMessage(msg.version, msg.Data[65:])
+		rm, err := parseNetworkMessage(msg.flags, msg.Data[65:])
 		logger.Verbosef("me.relayOrHandlePeerMessage.ME(%s, %s) => %s %v %v", me.Address, me.IdForNetwork, from, rm, err)
 		if err != nil {
 			return err
@@ -494,11 +619,21 @@ func (me *Peer) updateRemoteRelayerConsumers(relayerId crypto.Hash, data []byte)
 }
 
 func (me *Peer) handlePeerMessage(peerId crypto.Hash, msg *PeerMessage) error {
+	if msg.skipped {
+		logger.Verbosef("network.handle handlePeerMessage skip %s %d %d\n", peerId, msg.Type, msg.flags)
+		return nil
+	}
 	switch msg.Type {
 	case PeerMessageTypeRelay:
 		return me.relayOrHandlePeerMessage(peerId, msg)
 	case PeerMessageTypeConsumers:
 		return me.updateRemoteRelayerConsumers(peerId, msg.Data)
+	case PeerMessageTypeElectionWarning:
+		logger.Verbosef("network.handle handlePeerMessage PeerMessageTypeElectionWarning %s\n", peerId)
+		return me.handle.ReceiveElectionWarning(peerId, msg.Data)
+	case PeerMessageTypeStateCommitment:
+		logger.Verbosef("network.handle handlePeerMessage PeerMessageTypeStateCommitment %s\n", peerId)
+		return me.handle.ReceiveStateCommitment(peerId, msg.Data)
 	case PeerMessageTypePing:
 	case PeerMessageTypeCommitments:
 		logger.Verbosef("network.handle handlePeerMessage PeerMessageTypeCommitments %s %d\n", peerId, len(msg.Commitments))
@@ -545,6 +680,17 @@ func (me *Peer) handlePeerMessage(peerId crypto.Hash, msg *PeerMessage) error {
 	case PeerMessageTypeSnapshotFinalization:
 		logger.Verbosef("network.handle handlePeerMessage PeerMessageTypeSnapshotFinalization %s %s\n", peerId, msg.Snapshot.SoleTransaction())
 		return me.handle.VerifyAndQueueAppendSnapshotFinalization(peerId, msg.Snapshot)
+	case PeerMessageTypeRoundRequest:
+		logger.Verbosef("network.handle handlePeerMessage PeerMessageTypeRoundRequest %s %s\n", peerId, msg.SnapshotHash)
+		return me.sendRound(peerId, msg.SnapshotHash)
+	case PeerMessageTypeSnapshotBatchRequest:
+		b := msg.SnapshotBatch
+		logger.Verbosef("network.handle handlePeerMessage PeerMessageTypeSnapshotBatchRequest %s %s %d %d\n", peerId, b.NodeId, b.From, b.To)
+		return me.sendSnapshotBatch(peerId, b.NodeId, b.From, b.To)
+	case PeerMessageTypeSnapshotBatch:
+		b := msg.SnapshotBatch
+		logger.Verbosef("network.handle handlePeerMessage PeerMessageTypeSnapshotBatch %s %s %d %d %d\n", peerId, b.NodeId, b.From, b.To, len(b.Snapshots))
+		return me.receiveSnapshotBatch(peerId, b)
 	}
 	return nil
 }
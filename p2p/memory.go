@@ -0,0 +1,232 @@
+package p2p
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// MemoryFilter decides the delivery of each message sent from one address to
+// another, the message is dropped if no data returned, or the returned data,
+// which could be rewritten, is delivered after the delay, so the messages
+// with different delays are reordered.
+type MemoryFilter func(from, to string, data []byte) ([]byte, time.Duration)
+
+// MemoryNetwork connects the peers in the same process with channels, so the
+// simulations of the kernel nodes need no sockets, and could inject faults to
+// all the messages by the filter.
+type MemoryNetwork struct {
+	mutex     sync.Mutex
+	listeners map[string]*memoryListener
+	filter    MemoryFilter
+}
+
+type memoryEndpoint struct {
+	network *MemoryNetwork
+	addr    string
+}
+
+type memoryListener struct {
+	network *MemoryNetwork
+	addr    string
+	clients chan *memoryClient
+	closed  chan struct{}
+	once    sync.Once
+}
+
+type memoryClient struct {
+	network *MemoryNetwork
+	local   string
+	remote  string
+	inbox   chan *TransportMessage
+	peer    *memoryClient
+	closed  chan struct{}
+	once    *sync.Once
+}
+
+type memoryAddr string
+
+func NewMemoryNetwork(filter MemoryFilter) *MemoryNetwork {
+	return &MemoryNetwork{
+		listeners: make(map[string]*memoryListener),
+		filter:    filter,
+	}
+}
+
+// Endpoint is the network of the peer at the address, which is the source
+// address of all the messages sent by the peer.
+func (n *MemoryNetwork) Endpoint(addr string) Network {
+	return &memoryEndpoint{network: n, addr: memoryAddress(addr)}
+}
+
+func (e *memoryEndpoint) Listen(addr string) (Listener, error) {
+	addr = memoryAddress(addr)
+	if addr != e.addr {
+		return nil, fmt.Errorf("memory listen %s on endpoint %s", addr, e.addr)
+	}
+	e.network.mutex.Lock()
+	defer e.network.mutex.Unlock()
+	if e.network.listeners[addr] != nil {
+		return nil, fmt.Errorf("memory listen %s already in use", addr)
+	}
+	l := &memoryListener{
+		network: e.network,
+		addr:    addr,
+		clients: make(chan *memoryClient),
+		closed:  make(chan struct{}),
+	}
+	e.network.listeners[addr] = l
+	return l, nil
+}
+
+func (e *memoryEndpoint) Dial(ctx context.Context, addr string) (Client, error) {
+	addr = memoryAddress(addr)
+	e.network.mutex.Lock()
+	l := e.network.listeners[addr]
+	e.network.mutex.Unlock()
+	if l == nil {
+		return nil, fmt.Errorf("memory dial %s refused", addr)
+	}
+
+	closed, once := make(chan struct{}), new(sync.Once)
+	client := &memoryClient{
+		network: e.network,
+		local:   e.addr,
+		remote:  addr,
+		inbox:   make(chan *TransportMessage, 1024),
+		closed:  closed,
+		once:    once,
+	}
+	server := &memoryClient{
+		network: e.network,
+		local:   addr,
+		remote:  e.addr,
+		inbox:   make(chan *TransportMessage, 1024),
+		closed:  closed,
+		once:    once,
+	}
+	client.peer, server.peer = server, client
+
+	select {
+	case l.clients <- server:
+		return client, nil
+	case <-l.closed:
+		return nil, fmt.Errorf("memory dial %s refused", addr)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (l *memoryListener) Accept(ctx context.Context) (Client, error) {
+	select {
+	case c := <-l.clients:
+		return c, nil
+	case <-l.closed:
+		return nil, fmt.Errorf("memory listener %s closed", l.addr)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (l *memoryListener) Close() error {
+	l.once.Do(func() {
+		close(l.closed)
+		l.network.mutex.Lock()
+		defer l.network.mutex.Unlock()
+		if l.network.listeners[l.addr] == l {
+			delete(l.network.listeners, l.addr)
+		}
+	})
+	return nil
+}
+
+func (c *memoryClient) RemoteAddr() net.Addr {
+	return memoryAddr(c.remote)
+}
+
+// Receive times out as the quic client, so the receiving loop of a closing
+// peer always returns.
+func (c *memoryClient) Receive() (*TransportMessage, error) {
+	select {
+	case m := <-c.inbox:
+		return m, nil
+	case <-c.closed:
+		return nil, io.EOF
+	case <-time.After(ReadDeadline):
+		return nil, fmt.Errorf("memory receive timeout %s", c.remote)
+	}
+}
+
+func (c *memoryClient) Send(data []byte) error {
+	if l := len(data); l < 1 || l > TransportMessageMaxSize {
+		return fmt.Errorf("memory send invalid message size %d", l)
+	}
+	select {
+	case <-c.closed:
+		return io.ErrClosedPipe
+	default:
+	}
+
+	m := &TransportMessage{
+		Version: TransportMessageVersion,
+		Flags:   buildTransportFlags(data),
+		Size:    uint32(len(data)),
+		Data:    append([]byte{}, data...),
+	}
+	var delay time.Duration
+	if filter := c.network.filter; filter != nil {
+		m.Data, delay = filter(c.local, c.remote, m.Data)
+		if len(m.Data) == 0 {
+			return nil
+		}
+		m.Flags, m.Size = buildTransportFlags(m.Data), uint32(len(m.Data))
+	}
+	if delay <= 0 {
+		return c.peer.deliver(m)
+	}
+	go func() {
+		time.Sleep(delay)
+		_ = c.peer.deliver(m)
+	}()
+	return nil
+}
+
+func (c *memoryClient) deliver(m *TransportMessage) error {
+	select {
+	case c.inbox <- m:
+		return nil
+	case <-c.closed:
+		return io.ErrClosedPipe
+	case <-time.After(WriteDeadline):
+		return fmt.Errorf("memory send timeout %s", c.local)
+	}
+}
+
+func (c *memoryClient) Close(code string) error {
+	c.once.Do(func() { close(c.closed) })
+	return nil
+}
+
+func (a memoryAddr) Network() string {
+	return "memory"
+}
+
+func (a memoryAddr) String() string {
+	return string(a)
+}
+
+// the peers listen on the port of all interfaces, and are dialed on the
+// loopback address, both are the same address in the memory network
+func memoryAddress(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	return net.JoinHostPort(host, port)
+}
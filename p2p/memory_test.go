@@ -0,0 +1,85 @@
+package p2p
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryNetwork(t *testing.T) {
+	require := require.New(t)
+
+	var dropped, delayed int
+	network := NewMemoryNetwork(func(from, to string, data []byte) ([]byte, time.Duration) {
+		require.Equal("127.0.0.1:7100", from)
+		require.Equal("127.0.0.1:7101", to)
+		switch string(data) {
+		case "drop":
+			dropped += 1
+			return nil, 0
+		case "delay":
+			delayed += 1
+			return data, 100 * time.Millisecond
+		case "rewrite":
+			return []byte("rewritten"), 0
+		}
+		return data, 0
+	})
+
+	_, err := network.Endpoint("127.0.0.1:7100").Dial(context.Background(), "127.0.0.1:7101")
+	require.ErrorContains(err, "refused")
+	_, err = network.Endpoint("127.0.0.1:7101").Listen(":7102")
+	require.ErrorContains(err, "endpoint")
+
+	listener, err := network.Endpoint("127.0.0.1:7101").Listen(":7101")
+	require.Nil(err)
+	_, err = network.Endpoint("127.0.0.1:7101").Listen("127.0.0.1:7101")
+	require.ErrorContains(err, "in use")
+
+	accepted := make(chan Client)
+	go func() {
+		server, err := listener.Accept(context.Background())
+		require.Nil(err)
+		accepted <- server
+	}()
+	client, err := network.Endpoint("127.0.0.1:7100").Dial(context.Background(), "127.0.0.1:7101")
+	require.Nil(err)
+	server := <-accepted
+	require.Equal("127.0.0.1:7100", server.RemoteAddr().String())
+	require.Equal("127.0.0.1:7101", client.RemoteAddr().String())
+
+	require.NotNil(client.Send(nil))
+	for _, m := range []string{"delay", "drop", "hello mixin", "rewrite"} {
+		require.Nil(client.Send([]byte(m)))
+	}
+	msg, err := server.Receive()
+	require.Nil(err)
+	require.Equal("hello mixin", string(msg.Data))
+	require.Equal(uint8(TransportMessageVersion), msg.Version)
+	require.Equal(uint32(11), msg.Size)
+	msg, err = server.Receive()
+	require.Nil(err)
+	require.Equal("rewritten", string(msg.Data))
+	require.Equal(uint32(9), msg.Size)
+	msg, err = server.Receive()
+	require.Nil(err)
+	require.Equal("delay", string(msg.Data))
+	require.Equal(1, dropped)
+	require.Equal(1, delayed)
+
+	require.Nil(server.Close("test"))
+	_, err = client.Receive()
+	require.NotNil(err)
+	require.NotNil(client.Send([]byte("hello mixin")))
+
+	require.Nil(listener.Close())
+	_, err = listener.Accept(context.Background())
+	require.ErrorContains(err, "closed")
+	_, err = network.Endpoint("127.0.0.1:7100").Dial(context.Background(), "127.0.0.1:7101")
+	require.ErrorContains(err, "refused")
+	listener, err = network.Endpoint("127.0.0.1:7101").Listen(":7101")
+	require.Nil(err)
+	require.Nil(listener.Close())
+}
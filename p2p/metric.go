@@ -22,6 +22,11 @@ type MetricPool struct {
 	PeerMessageTypeSnapshotFinalization uint32 `json:"snapshot-finalization"`
 	PeerMessageTypeCommitments          uint32 `json:"commitments"`
 	PeerMessageTypeFullChallenge        uint32 `json:"full-challenge"`
+	PeerMessageTypeElectionWarning      uint32 `json:"election-warning"`
+	PeerMessageTypeSnapshotBatchRequest uint32 `json:"snapshot-batch-request"`
+	PeerMessageTypeSnapshotBatch        uint32 `json:"snapshot-batch"`
+	PeerMessageTypeRoundRequest         uint32 `json:"round-request"`
+	PeerMessageTypeStateCommitment      uint32 `json:"state-commitment"`
 
 	PeerMessageTypeRelay uint32 `json:"relay"`
 }
@@ -58,6 +63,16 @@ func (mp *MetricPool) handle(msg uint8) {
 		atomic.AddUint32(&mp.PeerMessageTypeCommitments, 1)
 	case PeerMessageTypeFullChallenge:
 		atomic.AddUint32(&mp.PeerMessageTypeFullChallenge, 1)
+	case PeerMessageTypeElectionWarning:
+		atomic.AddUint32(&mp.PeerMessageTypeElectionWarning, 1)
+	case PeerMessageTypeSnapshotBatchRequest:
+		atomic.AddUint32(&mp.PeerMessageTypeSnapshotBatchRequest, 1)
+	case PeerMessageTypeSnapshotBatch:
+		atomic.AddUint32(&mp.PeerMessageTypeSnapshotBatch, 1)
+	case PeerMessageTypeRoundRequest:
+		atomic.AddUint32(&mp.PeerMessageTypeRoundRequest, 1)
+	case PeerMessageTypeStateCommitment:
+		atomic.AddUint32(&mp.PeerMessageTypeStateCommitment, 1)
 	case PeerMessageTypeRelay:
 		atomic.AddUint32(&mp.PeerMessageTypeRelay, 1)
 	}
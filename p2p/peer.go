@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/MixinNetwork/mixin/config"
@@ -12,6 +13,11 @@ import (
 	"github.com/MixinNetwork/mixin/logger"
 )
 
+const (
+	AuthRenewalPeriod = 5 * time.Minute
+	AuthTokenLifetime = 3 * AuthRenewalPeriod
+)
+
 type Peer struct {
 	IdForNetwork crypto.Hash
 	Address      string
@@ -30,10 +36,15 @@ type Peer struct {
 	closing         bool
 	ops             chan struct{}
 	stn             chan struct{}
-
-	relayer        *QuicRelayer
-	consumerAuth   *AuthToken
-	isRelayer      bool
+	batches         *snapshotBatchTracker
+	pulledAt        atomic.Int64
+
+	network        Network
+	relayer        Listener
+	roleMutex      sync.Mutex
+	roleUpdatedAt  atomic.Int64
+	consumerAuth   atomic.Pointer[AuthToken]
+	isRelayer      atomic.Bool
 	remoteRelayers *relayersMap
 }
 
@@ -50,7 +61,7 @@ type ChanMsg struct {
 }
 
 func (me *Peer) IsRelayer() bool {
-	return me.isRelayer
+	return me.isRelayer.Load()
 }
 
 func (me *Peer) ConnectRelayer(idForNetwork crypto.Hash, addr string) {
@@ -59,7 +70,7 @@ func (me *Peer) ConnectRelayer(idForNetwork crypto.Hash, addr string) {
 	} else if a.Port < 80 || a.IP == nil {
 		panic(fmt.Errorf("invalid address %s %d %s", addr, a.Port, a.IP))
 	}
-	if me.isRelayer {
+	if me.IsRelayer() {
 		me.remoteRelayers = &relayersMap{m: make(map[crypto.Hash][]*remoteRelayer)}
 	}
 
@@ -77,15 +88,18 @@ func (me *Peer) ConnectRelayer(idForNetwork crypto.Hash, addr string) {
 
 func (me *Peer) connectRelayer(relayer *Peer) error {
 	logger.Printf("me.connectRelayer(%s, %s) => %v", me.Address, me.IdForNetwork, relayer)
-	client, err := NewQuicConsumer(me.ctx, relayer.Address)
-	logger.Printf("NewQuicConsumer(%s) => %v %v", relayer.Address, client, err)
+	client, err := me.network.Dial(me.ctx, relayer.Address)
+	logger.Printf("me.network.Dial(%s) => %v %v", relayer.Address, client, err)
 	if err != nil {
 		return err
 	}
 	defer client.Close("connectRelayer")
 	defer relayer.disconnect()
 
-	auth := me.handle.BuildAuthenticationMessage(relayer.IdForNetwork)
+	auth, err := me.handle.BuildAuthenticationMessage(relayer.IdForNetwork)
+	if err != nil {
+		return err
+	}
 	err = client.Send(buildAuthenticationMessage(auth))
 	logger.Printf("client.SendAuthenticationMessage(%x) => %v", auth, err)
 	if err != nil {
@@ -98,6 +112,7 @@ func (me *Peer) connectRelayer(relayer *Peer) error {
 	defer me.relayers.Delete(relayer.IdForNetwork)
 
 	go me.syncToNeighborLoop(relayer)
+	go me.renewAuthenticationLoop(relayer)
 	go me.loopReceiveMessage(relayer, client)
 	_, err = me.loopSendingStream(relayer, client)
 	logger.Printf("me.loopSendingStream(%s, %s) => %v", me.Address, client.RemoteAddr().String(), err)
@@ -148,8 +163,10 @@ func NewPeer(handle SyncHandle, idForNetwork crypto.Hash, addr string, isRelayer
 		receivedMetric: &MetricPool{enabled: false},
 		ops:            make(chan struct{}),
 		stn:            make(chan struct{}),
-		isRelayer:      isRelayer,
+		batches:        newSnapshotBatchTracker(),
+		network:        quicNetwork{},
 	}
+	peer.isRelayer.Store(isRelayer)
 	peer.ctx = context.Background() // FIXME use real context
 	if handle != nil {
 		peer.snapshotsCaches = &confirmMap{cache: handle.GetCacheStore()}
@@ -157,11 +174,19 @@ func NewPeer(handle SyncHandle, idForNetwork crypto.Hash, addr string, isRelayer
 	return peer
 }
 
+// SetNetwork replaces the quic network of the peer, it must be called before
+// the peer connects to any relayer or listens to the consumers.
+func (me *Peer) SetNetwork(network Network) {
+	me.network = network
+}
+
 func (me *Peer) Teardown() {
+	me.roleMutex.Lock()
 	me.closing = true
 	if me.relayer != nil {
 		me.relayer.Close()
 	}
+	me.roleMutex.Unlock()
 	close(me.highRing)
 	close(me.normalRing)
 	close(me.syncRing)
@@ -180,19 +205,81 @@ func (me *Peer) Teardown() {
 
 func (me *Peer) ListenConsumers() error {
 	logger.Printf("me.ListenConsumers(%s, %s)", me.Address, me.IdForNetwork)
-	relayer, err := NewQuicRelayer(me.Address)
+	me.roleMutex.Lock()
+	if me.relayer != nil {
+		me.roleMutex.Unlock()
+		return nil
+	}
+	relayer, err := me.startConsumersListener()
+	me.roleMutex.Unlock()
 	if err != nil {
 		return err
 	}
+	me.serveConsumers(relayer)
+	return nil
+}
+
+// SetRelayer switches the peer between the relayer and consumer roles at
+// runtime. The consumers listener is started, or closed with all consumers
+// disconnected, and the relayers are advertised the new role immediately by
+// the authentication renewal, instead of waiting for the renewal period.
+func (me *Peer) SetRelayer(relayer bool) error {
+	me.roleMutex.Lock()
+	defer me.roleMutex.Unlock()
+
+	if me.closing {
+		return fmt.Errorf("peer closing")
+	}
+	if me.IsRelayer() == relayer && (me.relayer != nil) == relayer {
+		return nil
+	}
+	if relayer {
+		l, err := me.startConsumersListener()
+		if err != nil {
+			return err
+		}
+		me.isRelayer.Store(true)
+		go me.serveConsumers(l)
+	} else {
+		me.isRelayer.Store(false)
+		if me.relayer != nil {
+			me.relayer.Close()
+			me.relayer = nil
+		}
+		for _, p := range me.consumers.Slice() {
+			go p.disconnect()
+		}
+	}
+	me.roleUpdatedAt.Store(time.Now().UnixNano())
+	logger.Printf("me.SetRelayer(%s, %t)\n", me.IdForNetwork, relayer)
+	return nil
+}
+
+func (me *Peer) startConsumersListener() (Listener, error) {
+	relayer, err := me.network.Listen(me.Address)
+	if err != nil {
+		return nil, err
+	}
 	me.relayer = relayer
-	me.remoteRelayers = &relayersMap{m: make(map[crypto.Hash][]*remoteRelayer)}
+	if me.remoteRelayers == nil {
+		me.remoteRelayers = &relayersMap{m: make(map[crypto.Hash][]*remoteRelayer)}
+	}
+	return relayer, nil
+}
+
+func (me *Peer) listeningConsumers(relayer Listener) bool {
+	me.roleMutex.Lock()
+	defer me.roleMutex.Unlock()
+	return !me.closing && me.relayer == relayer
+}
 
+func (me *Peer) serveConsumers(relayer Listener) {
 	go func() {
-		for !me.closing {
+		for me.listeningConsumers(relayer) {
 			neighbors := me.Neighbors()
 			msg := me.buildConsumersMessage()
 			for _, p := range neighbors {
-				if !p.isRelayer {
+				if !p.IsRelayer() {
 					continue
 				}
 				me.offerToPeerWithCacheCheck(p, MsgPriorityNormal, &ChanMsg{nil, msg})
@@ -202,8 +289,8 @@ func (me *Peer) ListenConsumers() error {
 		}
 	}()
 
-	for !me.closing {
-		c, err := me.relayer.Accept(me.ctx)
+	for me.listeningConsumers(relayer) {
+		c, err := relayer.Accept(me.ctx)
 		logger.Printf("me.relayer.Accept(%s) => %v %v", me.Address, c, err)
 		if err != nil {
 			continue
@@ -214,6 +301,7 @@ func (me *Peer) ListenConsumers() error {
 			peer, err := me.authenticateNeighbor(c)
 			logger.Printf("me.authenticateNeighbor(%s, %s) => %v %v", me.Address, c.RemoteAddr().String(), peer, err)
 			if err != nil {
+				me.handle.RecordAuthenticationFailure(c.RemoteAddr().String(), err)
 				return
 			}
 			defer peer.disconnect()
@@ -236,7 +324,6 @@ func (me *Peer) ListenConsumers() error {
 	}
 
 	logger.Printf("ListenConsumers(%s, %s) DONE\n", me.IdForNetwork, me.Address)
-	return nil
 }
 
 func (me *Peer) loopSendingStream(p *Peer, consumer Client) (*ChanMsg, error) {
@@ -293,7 +380,15 @@ func (me *Peer) loopReceiveMessage(peer *Peer, client Client) {
 		defer client.Close("handlePeerMessage")
 
 		for msg := range receive {
-			err := me.handlePeerMessage(peer.IdForNetwork, msg)
+			var err error
+			if msg.Type == PeerMessageTypeAuthentication {
+				err = me.renewAuthentication(peer, msg.Data)
+				if err != nil {
+					me.handle.RecordAuthenticationFailure(client.RemoteAddr().String(), err)
+				}
+			} else {
+				err = me.handlePeerMessage(peer.IdForNetwork, msg)
+			}
 			if err == nil {
 				continue
 			}
@@ -308,11 +403,21 @@ func (me *Peer) loopReceiveMessage(peer *Peer, client Client) {
 			logger.Printf("client.Receive %s %v", peer.Address, err)
 			return
 		}
-		msg, err := parseNetworkMessage(tm.Version, tm.Data)
+		if auth := peer.consumerAuth.Load(); auth != nil {
+			if time.Since(auth.acceptedAt) > AuthTokenLifetime {
+				logger.Printf("peer authentication expired %s %s", peer.Address, auth.acceptedAt)
+				return
+			}
+		}
+		msg, err := parseNetworkMessage(tm.Flags, tm.Data)
 		if err != nil {
 			logger.Debugf("parseNetworkMessage %s %v", peer.Address, err)
 			return
 		}
+		if msg.skipped {
+			logger.Debugf("parseNetworkMessage %s skip %d %d", peer.Address, msg.Type, tm.Flags)
+			continue
+		}
 		me.receivedMetric.handle(msg.Type)
 
 		select {
@@ -333,7 +438,7 @@ func (me *Peer) authenticateNeighbor(client Client) (*Peer, error) {
 			auth <- err
 			return
 		}
-		msg, err := parseNetworkMessage(tm.Version, tm.Data)
+		msg, err := parseNetworkMessage(tm.Flags, tm.Data)
 		if err != nil {
 			auth <- err
 			return
@@ -352,7 +457,8 @@ func (me *Peer) authenticateNeighbor(client Client) (*Peer, error) {
 
 		addr := client.RemoteAddr().String()
 		peer = NewPeer(nil, token.PeerId, addr, token.IsRelayer)
-		peer.consumerAuth = token
+		token.acceptedAt = time.Now()
+		peer.consumerAuth.Store(token)
 		auth <- nil
 	}()
 
@@ -367,6 +473,51 @@ func (me *Peer) authenticateNeighbor(client Client) (*Peer, error) {
 	return peer, nil
 }
 
+// the consumer renews the authentication over the established connection
+// periodically, so the relayer drops the connection when the token expires,
+// and the relayer role change of the consumer propagates without reconnect.
+func (me *Peer) renewAuthenticationLoop(relayer *Peer) {
+	renewed := time.Now()
+	for !me.closing && !relayer.closing {
+		time.Sleep(time.Second)
+		if time.Since(renewed) < AuthRenewalPeriod && me.roleUpdatedAt.Load() < renewed.UnixNano() {
+			continue
+		}
+		auth, err := me.handle.BuildAuthenticationMessage(relayer.IdForNetwork)
+		if err != nil {
+			logger.Verbosef("renewAuthenticationLoop(%s) => %v\n", relayer.IdForNetwork, err)
+			continue
+		}
+		if !relayer.offer(MsgPriorityHigh, &ChanMsg{nil, buildAuthenticationMessage(auth)}) {
+			logger.Verbosef("renewAuthenticationLoop(%s) send timeout\n", relayer.IdForNetwork)
+			continue
+		}
+		me.sentMetric.handle(PeerMessageTypeAuthentication)
+		renewed = time.Now()
+	}
+}
+
+func (me *Peer) renewAuthentication(peer *Peer, data []byte) error {
+	old := peer.consumerAuth.Load()
+	if old == nil {
+		return fmt.Errorf("peer authentication renewal from relayer %s", peer.IdForNetwork)
+	}
+	token, err := me.handle.AuthenticateAs(me.IdForNetwork, data, int64(HandshakeTimeout/time.Second))
+	if err != nil {
+		return err
+	}
+	if token.PeerId != peer.IdForNetwork {
+		return fmt.Errorf("peer authentication renewal for %s from %s", token.PeerId, peer.IdForNetwork)
+	}
+	if token.Timestamp <= old.Timestamp {
+		return fmt.Errorf("peer authentication renewal stale %d %d", token.Timestamp, old.Timestamp)
+	}
+	token.acceptedAt = time.Now()
+	peer.consumerAuth.Store(token)
+	peer.isRelayer.Store(token.IsRelayer)
+	return nil
+}
+
 func (me *Peer) sendHighToPeer(to crypto.Hash, typ byte, key, data []byte) error {
 	return me.sendToPeer(to, typ, key, data, MsgPriorityHigh)
 }
@@ -0,0 +1,93 @@
+package p2p
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+type authTestHandle struct {
+	SyncHandle
+}
+
+func (h *authTestHandle) AuthenticateAs(recipientId crypto.Hash, msg []byte, timeoutSec int64) (*AuthToken, error) {
+	token := &AuthToken{
+		Timestamp: binary.BigEndian.Uint64(msg[:8]),
+		IsRelayer: msg[40] == 1,
+		Data:      msg,
+	}
+	copy(token.PeerId[:], msg[8:40])
+	return token, nil
+}
+
+func TestRenewAuthentication(t *testing.T) {
+	require := require.New(t)
+
+	me := NewPeer(nil, crypto.Blake3Hash([]byte("relayer")), "127.0.0.1:7001", true)
+	me.handle = &authTestHandle{}
+	id := crypto.Blake3Hash([]byte("consumer"))
+	build := func(id crypto.Hash, ts uint64, relayer bool) []byte {
+		data := binary.BigEndian.AppendUint64(nil, ts)
+		data = append(data, id[:]...)
+		if relayer {
+			return append(data, 1)
+		}
+		return append(data, 0)
+	}
+
+	relayer := NewPeer(nil, id, "127.0.0.1:7002", true)
+	err := me.renewAuthentication(relayer, build(id, 2, false))
+	require.NotNil(err)
+
+	consumer := NewPeer(nil, id, "127.0.0.1:7002", false)
+	consumer.consumerAuth.Store(&AuthToken{PeerId: id, Timestamp: 1})
+	require.False(consumer.IsRelayer())
+
+	err = me.renewAuthentication(consumer, build(crypto.Blake3Hash([]byte("other")), 2, false))
+	require.NotNil(err)
+	err = me.renewAuthentication(consumer, build(id, 1, true))
+	require.NotNil(err)
+	require.False(consumer.IsRelayer())
+
+	err = me.renewAuthentication(consumer, build(id, 2, true))
+	require.Nil(err)
+	require.True(consumer.IsRelayer())
+	require.Equal(uint64(2), consumer.consumerAuth.Load().Timestamp)
+	err = me.renewAuthentication(consumer, build(id, 2, false))
+	require.NotNil(err)
+	require.True(consumer.IsRelayer())
+}
+
+func TestSetRelayer(t *testing.T) {
+	require := require.New(t)
+
+	me := NewPeer(nil, crypto.Blake3Hash([]byte("consumer")), "127.0.0.1:7011", false)
+	defer me.Teardown()
+	require.False(me.IsRelayer())
+	require.Nil(me.SetRelayer(false))
+	require.Equal(int64(0), me.roleUpdatedAt.Load())
+
+	require.Nil(me.SetRelayer(true))
+	require.True(me.IsRelayer())
+	relayer := me.relayer
+	require.NotNil(relayer)
+	require.True(me.listeningConsumers(relayer))
+	updated := me.roleUpdatedAt.Load()
+	require.True(updated > 0)
+	require.Nil(me.SetRelayer(true))
+	require.True(relayer == me.relayer)
+	require.Equal(updated, me.roleUpdatedAt.Load())
+	require.Nil(me.ListenConsumers())
+
+	require.Nil(me.SetRelayer(false))
+	require.False(me.IsRelayer())
+	require.Nil(me.relayer)
+	require.False(me.listeningConsumers(relayer))
+
+	require.Nil(me.SetRelayer(true))
+	require.True(me.IsRelayer())
+	require.NotNil(me.relayer)
+	require.False(relayer == me.relayer)
+}
@@ -121,6 +121,7 @@ func (c *QuicClient) Receive() (*TransportMessage, error) {
 	if m.Version != TransportMessageVersion {
 		return nil, fmt.Errorf("quic receive invalid message version %d", m.Version)
 	}
+	m.Flags = header[1]
 	m.Size = binary.BigEndian.Uint32(header[2:])
 	if m.Size > TransportMessageMaxSize {
 		return nil, fmt.Errorf("quic receive invalid message size %d", m.Size)
@@ -140,7 +141,7 @@ func (c *QuicClient) Send(data []byte) error {
 	if err != nil {
 		return err
 	}
-	header := []byte{TransportMessageVersion, 0, 0, 0, 0, 0}
+	header := []byte{TransportMessageVersion, buildTransportFlags(data), 0, 0, 0, 0}
 	binary.BigEndian.PutUint32(header[2:], uint32(len(data)))
 	_, err = c.stream.Write(header)
 	if err != nil {
@@ -1,7 +1,6 @@
 package p2p
 
 import (
-	"fmt"
 	"time"
 
 	"github.com/MixinNetwork/mixin/common"
@@ -10,137 +9,48 @@ import (
 	"github.com/MixinNetwork/mixin/logger"
 )
 
-func (me *Peer) cacheReadSnapshotsForNodeRound(nodeId crypto.Hash, number uint64) ([]*common.SnapshotWithTopologicalOrder, error) {
-	return me.handle.ReadSnapshotsForNodeRound(nodeId, number)
-}
-
-func (me *Peer) cacheReadSnapshotsSinceTopology(offset, limit uint64) ([]*common.SnapshotWithTopologicalOrder, error) {
-	return me.handle.ReadSnapshotsSinceTopology(offset, limit)
-}
-
-// FIXME this could result in a very small topology due to already removed node
-// and sync to neighbor since this offset will take substantial time
-func (me *Peer) compareRoundGraphAndGetTopologicalOffset(p *Peer, local, remote []*SyncPoint) (uint64, error) {
-	remoteFilter := make(map[crypto.Hash]*SyncPoint)
-	for _, p := range remote {
-		remoteFilter[p.NodeId] = p
-	}
-
-	var offset uint64
-
-	for _, l := range local {
-		r := remoteFilter[l.NodeId]
-		if r == nil || r.Number > l.Number {
-			continue
-		}
-		number := r.Number + 2 // because the node may be stale or removed, and with cache
-		logger.Verbosef("network.sync compareRoundGraphAndGetTopologicalOffset %s try %s:%d\n", p.IdForNetwork, l.NodeId, number)
-
-		ss, err := me.cacheReadSnapshotsForNodeRound(l.NodeId, number)
-		if err != nil {
-			return offset, err
-		}
-		if len(ss) == 0 {
-			logger.Verbosef("network.sync compareRoundGraphAndGetTopologicalOffset %s local round empty %s:%d:%d\n", p.IdForNetwork, l.NodeId, number, l.Number)
-			continue
-		}
-		topo := ss[0].TopologicalOrder
-		if offset == 0 || topo < offset {
-			offset = topo
-		}
-	}
-	return offset, nil
-}
-
-func (me *Peer) syncToNeighborSince(graph map[crypto.Hash]*SyncPoint, p *Peer, offset uint64) (uint64, error) {
-	logger.Verbosef("network.sync syncToNeighborSince %s %d\n", p.IdForNetwork, offset)
-	limit := 200
-	snapshots, err := me.cacheReadSnapshotsSinceTopology(offset, uint64(limit))
-	if err != nil {
-		return offset, err
-	}
-	for _, s := range snapshots {
-		var remoteRound uint64
-		if r := graph[s.NodeId]; r != nil {
-			remoteRound = r.Number
-		}
-		if s.RoundNumber < remoteRound {
-			offset = s.TopologicalOrder
-			continue
-		}
-		if s.RoundNumber >= remoteRound+config.SnapshotReferenceThreshold*2 {
-			return offset, fmt.Errorf("FUTURE %s %d %d", s.NodeId, s.RoundNumber, remoteRound)
-		}
-		err := me.SendSnapshotFinalizationMessage(p.IdForNetwork, s.Snapshot)
-		if err != nil {
-			return offset, err
-		}
-		offset = s.TopologicalOrder
-	}
-	time.Sleep(100 * time.Millisecond)
-	if len(snapshots) < limit {
-		return offset, fmt.Errorf("EOF")
-	}
-	return offset, nil
-}
-
-func (me *Peer) syncHeadRoundToRemote(local, remote map[crypto.Hash]*SyncPoint, p *Peer, nodeId crypto.Hash) {
-	var localFinal, remoteFinal uint64
-	if r := remote[nodeId]; r != nil {
-		remoteFinal = r.Number
-	}
-	if l := local[nodeId]; l != nil {
-		localFinal = l.Number
-	}
-	if remoteFinal > localFinal {
-		return
-	}
-	logger.Verbosef("network.sync syncHeadRoundToRemote %s %s:%d\n", p.IdForNetwork, nodeId, remoteFinal)
-	for i := remoteFinal; i <= remoteFinal+config.SnapshotReferenceThreshold+2; i++ {
-		ss, _ := me.cacheReadSnapshotsForNodeRound(nodeId, i)
-		for _, s := range ss {
-			err := me.SendSnapshotFinalizationMessage(p.IdForNetwork, s.Snapshot)
-			if err != nil {
-				logger.Verbosef("network.sync SendSnapshotFinalizationMessage %s %v\n", p.IdForNetwork, err)
-			}
-		}
+// sendCatchupSnapshot waits for the neighbor ring to drain a bit before
+// sending, because the ring drops the messages when full, and the dropped
+// snapshots are not sent again until the cache expires.
+func (me *Peer) sendCatchupSnapshot(p *Peer, s *common.Snapshot) error {
+	for !me.closing && !p.closing && len(p.normalRing) > cap(p.normalRing)/2 {
+		time.Sleep(10 * time.Millisecond)
 	}
+	return me.SendSnapshotFinalizationMessage(p.IdForNetwork, s)
 }
 
 func (me *Peer) syncToNeighborLoop(p *Peer) {
 	defer close(p.stn)
+	defer me.batches.removePeer(p.IdForNetwork)
 
 	for !me.closing && !p.closing {
-		graph, offset := me.getSyncPointOffset(p)
-		logger.Verbosef("network.sync syncToNeighborLoop getSyncPointOffset %s %d %v\n", p.IdForNetwork, offset, graph != nil)
+		graph := me.getSyncPointGraph(p)
+		logger.Verbosef("network.sync syncToNeighborLoop getSyncPointGraph %s %v\n", p.IdForNetwork, graph != nil)
 		if graph == nil {
 			time.Sleep(time.Duration(config.SnapshotRoundGap))
 			continue
 		}
 
-		points := me.handle.BuildGraph()
-		nodes := me.handle.ReadAllNodesWithoutState()
-		local := make(map[crypto.Hash]*SyncPoint)
-		for _, n := range points {
-			local[n.NodeId] = n
-		}
-		for _, n := range nodes {
-			me.syncHeadRoundToRemote(local, graph, p, n)
-		}
+		local := me.handle.BuildGraph()
+		pulls := me.pullSnapshotBatches(p, local, graph)
 
-		for !me.closing && !p.closing && offset > 0 {
-			off, err := me.syncToNeighborSince(graph, p, offset)
-			if err != nil {
-				logger.Verbosef("network.sync syncToNeighborLoop syncToNeighborSince %s %d DONE with %s", p.IdForNetwork, offset, err)
-				break
-			}
-			offset = off
+		// the peer pulling batches only needs the rounds near its head pushed
+		window := uint64(catchupRoundsWindow)
+		if time.Since(time.Unix(0, p.pulledAt.Load())) < snapshotBatchPullingPeriod {
+			window = catchupPullingWindow
 		}
+		cs := newCatchupScheduler(me.handle, me.IdForNetwork, local, graph, window, func(s *common.Snapshot) error {
+			return me.sendCatchupSnapshot(p, s)
+		})
+		rounds, err := cs.run(func() bool { return me.closing || p.closing })
+		logger.Verbosef("network.sync syncToNeighborLoop catchup %s %d rounds %d chains %d pulls DONE with %v\n",
+			p.IdForNetwork, rounds, len(cs.order), pulls, err)
 	}
 }
 
-func (me *Peer) getSyncPointOffset(p *Peer) (map[crypto.Hash]*SyncPoint, uint64) {
-	var offset uint64
+// getSyncPointGraph returns the latest graph received from the peer in about
+// one second, or nil if the peer closed.
+func (me *Peer) getSyncPointGraph(p *Peer) map[crypto.Hash]*SyncPoint {
 	var graph map[crypto.Hash]*SyncPoint
 
 	startAt := time.Now()
@@ -157,17 +67,10 @@ func (me *Peer) getSyncPointOffset(p *Peer) (map[crypto.Hash]*SyncPoint, uint64)
 		for _, r := range g {
 			graph[r.NodeId] = r
 		}
-		off, err := me.compareRoundGraphAndGetTopologicalOffset(p, me.handle.BuildGraph(), g)
-		if err != nil {
-			logger.Verbosef("network.sync compareRoundGraphAndGetTopologicalOffset %s error %v\n", p.IdForNetwork, err)
-		}
-		if off > 0 {
-			offset = off
-		}
 		if startAt.Add(time.Second).Before(time.Now()) {
-			return graph, offset
+			return graph
 		}
 	}
 
-	return nil, 0
+	return nil
 }
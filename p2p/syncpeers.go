@@ -0,0 +1,268 @@
+package p2p
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+// the batches are routed to the peers by the measured throughput and the
+// failure rate, instead of whichever peer loop comes first. a peer never
+// measured is assumed as fast as the fastest one, so it's tried soon, and a
+// peer failing too much is used only when no healthy peer has the rounds.
+// a request slower than expected is hedged to the next best peer, and the
+// first response wins.
+const (
+	syncPeerEWMAWeight       = 0.3
+	syncPeerFailureThreshold = 0.5
+	syncPeerHedgeMinDelay    = time.Second
+	syncPeerHedgeMaxDelay    = snapshotBatchTimeout / 2
+)
+
+type syncPeerStats struct {
+	rate     float64
+	latency  time.Duration
+	failure  float64
+	samples  int
+	inflight int
+	graph    map[crypto.Hash]*SyncPoint
+}
+
+type snapshotBatchRequest struct {
+	peerId crypto.Hash
+	at     time.Time
+}
+
+type snapshotBatchCursor struct {
+	from       uint64
+	to         uint64
+	at         time.Time
+	local      uint64
+	progressAt time.Time
+	requests   []*snapshotBatchRequest
+}
+
+type snapshotBatchTracker struct {
+	sync.Mutex
+	chains map[crypto.Hash]*snapshotBatchCursor
+	peers  map[crypto.Hash]*syncPeerStats
+}
+
+func newSnapshotBatchTracker() *snapshotBatchTracker {
+	return &snapshotBatchTracker{
+		chains: make(map[crypto.Hash]*snapshotBatchCursor),
+		peers:  make(map[crypto.Hash]*syncPeerStats),
+	}
+}
+
+func (t *snapshotBatchTracker) updateGraph(peerId crypto.Hash, graph map[crypto.Hash]*SyncPoint) {
+	t.Lock()
+	defer t.Unlock()
+
+	t.stats(peerId).graph = graph
+}
+
+func (t *snapshotBatchTracker) removePeer(peerId crypto.Hash) {
+	t.Lock()
+	defer t.Unlock()
+
+	delete(t.peers, peerId)
+}
+
+// resume continues the chain from the rounds pulled before the restart, which
+// are queued again from the cache.
+func (t *snapshotBatchTracker) resume(chain crypto.Hash, local, verified uint64, now time.Time) {
+	t.Lock()
+	defer t.Unlock()
+
+	if verified <= local || t.chains[chain] != nil {
+		return
+	}
+	t.chains[chain] = &snapshotBatchCursor{from: local + 1, to: verified, at: now, local: local, progressAt: now}
+}
+
+// next returns the round range of the chain to request from the peer, only
+// if the peer is the best one to serve it. the range continues from the last
+// batch received as long as the local final round keeps moving, or restarts
+// from the local final round if it stalls, because the pulled snapshots could
+// be dropped by the prevalidation.
+func (t *snapshotBatchTracker) next(peerId, chain crypto.Hash, local uint64, now time.Time) (uint64, uint64, bool) {
+	t.Lock()
+	defer t.Unlock()
+
+	c := t.chains[chain]
+	if c != nil && local > c.local {
+		c.local, c.progressAt = local, now
+	}
+	if c != nil && len(c.requests) > 0 {
+		if now.Sub(c.at) >= snapshotBatchTimeout {
+			for _, r := range c.requests {
+				t.record(r.peerId, 0, 0, false)
+			}
+			c = nil
+		} else {
+			return t.hedge(peerId, chain, c, now)
+		}
+	}
+
+	from := local + 1
+	if c != nil && (now.Sub(c.at) < snapshotBatchTimeout || now.Sub(c.progressAt) < snapshotBatchTimeout) {
+		from = max(from, c.to+1)
+	}
+	best := t.best(chain, from, nil)
+	if best != peerId {
+		return 0, 0, false
+	}
+	remote := t.peers[peerId].graph[chain].Number
+	to := min(remote, from+snapshotBatchRoundsLimit-1, local+snapshotBatchAheadLimit)
+	if remote <= local+1 || from > to {
+		return 0, 0, false
+	}
+	nc := &snapshotBatchCursor{from: from, to: to, at: now, local: local, progressAt: now}
+	if c != nil {
+		nc.progressAt = c.progressAt
+	}
+	t.chains[chain] = nc
+	t.request(nc, peerId, now)
+	return from, to, true
+}
+
+// the pending request is hedged once, to the best peer other than the one
+// requested, and the hedged request has the same range, so the response of
+// either one is accepted.
+func (t *snapshotBatchTracker) hedge(peerId, chain crypto.Hash, c *snapshotBatchCursor, now time.Time) (uint64, uint64, bool) {
+	if len(c.requests) != 1 {
+		return 0, 0, false
+	}
+	first := c.requests[0]
+	if s := t.peers[first.peerId]; s != nil && now.Sub(first.at) < s.hedgeDelay() {
+		return 0, 0, false
+	}
+	best := t.best(chain, c.to, map[crypto.Hash]bool{first.peerId: true})
+	if best != peerId {
+		return 0, 0, false
+	}
+	t.request(c, peerId, now)
+	return c.from, c.to, true
+}
+
+func (t *snapshotBatchTracker) request(c *snapshotBatchCursor, peerId crypto.Hash, now time.Time) {
+	c.requests = append(c.requests, &snapshotBatchRequest{peerId: peerId, at: now})
+	t.stats(peerId).inflight += 1
+}
+
+// done accepts the batch only if it's the response of the pending request,
+// and the late response of the hedged request is ignored.
+func (t *snapshotBatchTracker) done(peerId crypto.Hash, b *SnapshotBatch, now time.Time) bool {
+	t.Lock()
+	defer t.Unlock()
+
+	c := t.chains[b.NodeId]
+	if c == nil || c.from != b.From || b.To < b.From || b.To > c.to {
+		return false
+	}
+	var found *snapshotBatchRequest
+	for _, r := range c.requests {
+		if r.peerId == peerId {
+			found = r
+		}
+	}
+	if found == nil {
+		return false
+	}
+	for _, r := range c.requests {
+		if r != found {
+			t.release(r.peerId)
+		}
+	}
+	t.record(peerId, b.To-b.From+1, now.Sub(found.at), true)
+	c.to, c.at, c.requests = b.To, now, nil
+	return true
+}
+
+func (t *snapshotBatchTracker) record(peerId crypto.Hash, rounds uint64, latency time.Duration, success bool) {
+	s := t.release(peerId)
+	if s == nil {
+		return
+	}
+	if !success {
+		s.failure = s.failure*(1-syncPeerEWMAWeight) + syncPeerEWMAWeight
+		return
+	}
+	s.failure = s.failure * (1 - syncPeerEWMAWeight)
+	rate := float64(rounds) / max(latency, time.Millisecond).Seconds()
+	if s.samples == 0 {
+		s.rate, s.latency = rate, latency
+	} else {
+		s.rate = s.rate*(1-syncPeerEWMAWeight) + rate*syncPeerEWMAWeight
+		s.latency = time.Duration(float64(s.latency)*(1-syncPeerEWMAWeight) + float64(latency)*syncPeerEWMAWeight)
+	}
+	s.samples += 1
+}
+
+func (t *snapshotBatchTracker) release(peerId crypto.Hash) *syncPeerStats {
+	s := t.peers[peerId]
+	if s != nil {
+		s.inflight = max(s.inflight-1, 0)
+	}
+	return s
+}
+
+// best returns the peer having the round of the chain with the highest score,
+// the healthy peers are always preferred, and the ties are broken by id.
+func (t *snapshotBatchTracker) best(chain crypto.Hash, round uint64, excluded map[crypto.Hash]bool) crypto.Hash {
+	var fastest float64
+	for _, s := range t.peers {
+		fastest = max(fastest, s.rate)
+	}
+	var best crypto.Hash
+	var bestScore float64
+	var bestHealthy, found bool
+	for id, s := range t.peers {
+		p := s.graph[chain]
+		if excluded[id] || p == nil || p.Number < round {
+			continue
+		}
+		healthy := s.failure < syncPeerFailureThreshold
+		score := s.score(fastest)
+		switch {
+		case !found:
+		case healthy != bestHealthy:
+			if !healthy {
+				continue
+			}
+		case score < bestScore:
+			continue
+		case score == bestScore && bytes.Compare(id[:], best[:]) > 0:
+			continue
+		}
+		best, bestScore, bestHealthy, found = id, score, healthy, true
+	}
+	return best
+}
+
+func (t *snapshotBatchTracker) stats(peerId crypto.Hash) *syncPeerStats {
+	s := t.peers[peerId]
+	if s == nil {
+		s = &syncPeerStats{}
+		t.peers[peerId] = s
+	}
+	return s
+}
+
+func (s *syncPeerStats) score(fastest float64) float64 {
+	rate := s.rate
+	if s.samples == 0 {
+		rate = max(fastest, 1)
+	}
+	return rate * (1 - s.failure) / float64(1+s.inflight)
+}
+
+func (s *syncPeerStats) hedgeDelay() time.Duration {
+	if s.samples == 0 {
+		return syncPeerHedgeMaxDelay
+	}
+	return min(max(s.latency*2, syncPeerHedgeMinDelay), syncPeerHedgeMaxDelay)
+}
@@ -0,0 +1,120 @@
+package p2p
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotBatchTracker(t *testing.T) {
+	require := require.New(t)
+
+	a := crypto.Blake3Hash([]byte("chain-a"))
+	b := crypto.Blake3Hash([]byte("chain-b"))
+	p1 := crypto.Blake3Hash([]byte("peer-1"))
+	p2 := crypto.Blake3Hash([]byte("peer-2"))
+	if bytes.Compare(p1[:], p2[:]) > 0 {
+		p1, p2 = p2, p1
+	}
+	tracker := newSnapshotBatchTracker()
+	now := time.Unix(1700000000, 0)
+
+	tracker.updateGraph(p1, map[crypto.Hash]*SyncPoint{a: {NodeId: a, Number: 1000}})
+	tracker.updateGraph(p2, map[crypto.Hash]*SyncPoint{a: {NodeId: a, Number: 1000}, b: {NodeId: b, Number: 50}})
+
+	_, _, ok := tracker.next(p1, a, 999, now)
+	require.False(ok)
+	_, _, ok = tracker.next(p2, a, 10, now)
+	require.False(ok)
+	from, to, ok := tracker.next(p1, a, 10, now)
+	require.True(ok)
+	require.Equal(uint64(11), from)
+	require.Equal(uint64(110), to)
+	_, _, ok = tracker.next(p1, a, 10, now)
+	require.False(ok)
+	_, _, ok = tracker.next(p1, b, 10, now)
+	require.False(ok)
+	from, to, ok = tracker.next(p2, b, 10, now)
+	require.True(ok)
+	require.Equal(uint64(11), from)
+	require.Equal(uint64(50), to)
+	require.True(tracker.done(p2, &SnapshotBatch{NodeId: b, From: 11, To: 50}, now.Add(time.Second)))
+
+	_, _, ok = tracker.next(p2, a, 10, now.Add(syncPeerHedgeMaxDelay-time.Millisecond))
+	require.False(ok)
+	from, to, ok = tracker.next(p2, a, 10, now.Add(syncPeerHedgeMaxDelay))
+	require.True(ok)
+	require.Equal(uint64(11), from)
+	require.Equal(uint64(110), to)
+	_, _, ok = tracker.next(p1, a, 10, now.Add(syncPeerHedgeMaxDelay))
+	require.False(ok)
+
+	require.False(tracker.done(p2, &SnapshotBatch{NodeId: a, From: 12, To: 50}, now))
+	require.False(tracker.done(p2, &SnapshotBatch{NodeId: a, From: 11, To: 111}, now))
+	now = now.Add(syncPeerHedgeMaxDelay + time.Second)
+	require.True(tracker.done(p2, &SnapshotBatch{NodeId: a, From: 11, To: 110}, now))
+	require.False(tracker.done(p1, &SnapshotBatch{NodeId: a, From: 11, To: 110}, now))
+	require.Equal(0, tracker.peers[p1].inflight)
+	require.Equal(0, tracker.peers[p1].samples)
+	require.Equal(0, tracker.peers[p2].inflight)
+	require.Equal(2, tracker.peers[p2].samples)
+
+	// the unmeasured peer is as fast as the fastest one, so the tie is broken by id
+	from, to, ok = tracker.next(p1, a, 10, now)
+	require.True(ok)
+	require.Equal(uint64(111), from)
+	require.Equal(uint64(210), to)
+	now = now.Add(snapshotBatchTimeout)
+	_, _, ok = tracker.next(p1, a, 10, now)
+	require.False(ok)
+	require.InDelta(syncPeerEWMAWeight, tracker.peers[p1].failure, 0.001)
+	from, to, ok = tracker.next(p2, a, 10, now)
+	require.True(ok)
+	require.Equal(uint64(11), from)
+	require.Equal(uint64(110), to)
+
+	tracker.peers[p1].failure = syncPeerFailureThreshold
+	tracker.peers[p2].rate = 0.01
+	require.Equal(p2, tracker.best(a, 11, nil))
+	require.Equal(p1, tracker.best(a, 11, map[crypto.Hash]bool{p2: true}))
+	require.Equal(crypto.Hash{}, tracker.best(b, 11, map[crypto.Hash]bool{p2: true}))
+
+	tracker.removePeer(p2)
+	require.Equal(p1, tracker.best(a, 11, nil))
+	require.True(tracker.done(p2, &SnapshotBatch{NodeId: a, From: 11, To: 110}, now))
+	require.Nil(tracker.peers[p2])
+
+	now = now.Add(time.Minute)
+	tracker.updateGraph(p2, map[crypto.Hash]*SyncPoint{a: {NodeId: a, Number: 1000}})
+	tracker.chains = make(map[crypto.Hash]*snapshotBatchCursor)
+	tracker.resume(a, 10, 5, now)
+	require.Nil(tracker.chains[a])
+	tracker.resume(a, 10, 150, now)
+	tracker.resume(a, 10, 200, now)
+	require.Equal(uint64(150), tracker.chains[a].to)
+	from, to, ok = tracker.next(p2, a, 10, now.Add(snapshotBatchTimeout-time.Second))
+	require.True(ok)
+	require.Equal(uint64(151), from)
+	require.Equal(uint64(210), to)
+	require.True(tracker.done(p2, &SnapshotBatch{NodeId: a, From: 151, To: 210}, now))
+
+	// the pulled rounds are not requested again while the local final round keeps moving
+	now = now.Add(snapshotBatchTimeout - time.Second)
+	_, _, ok = tracker.next(p2, a, 10, now)
+	require.False(ok)
+	now = now.Add(snapshotBatchTimeout - time.Second)
+	from, to, ok = tracker.next(p2, a, 30, now)
+	require.True(ok)
+	require.Equal(uint64(211), from)
+	require.Equal(uint64(230), to)
+	require.True(tracker.done(p2, &SnapshotBatch{NodeId: a, From: 211, To: 230}, now))
+
+	now = now.Add(snapshotBatchTimeout)
+	from, to, ok = tracker.next(p2, a, 30, now)
+	require.True(ok)
+	require.Equal(uint64(31), from)
+	require.Equal(uint64(130), to)
+}
@@ -13,6 +13,7 @@ const (
 
 type TransportMessage struct {
 	Version uint8
+	Flags   uint8
 	Size    uint32
 	Data    []byte
 }
@@ -30,3 +31,25 @@ type Transport interface {
 	Accept(ctx context.Context) (Client, error)
 	Close() error
 }
+
+type Listener interface {
+	Accept(ctx context.Context) (Client, error)
+	Close() error
+}
+
+// Network creates the consumers listener and the relayer clients of a peer,
+// it is quic for the kernel nodes, and in memory for the simulations.
+type Network interface {
+	Listen(addr string) (Listener, error)
+	Dial(ctx context.Context, addr string) (Client, error)
+}
+
+type quicNetwork struct{}
+
+func (quicNetwork) Listen(addr string) (Listener, error) {
+	return NewQuicRelayer(addr)
+}
+
+func (quicNetwork) Dial(ctx context.Context, addr string) (Client, error) {
+	return NewQuicConsumer(ctx, addr)
+}
@@ -0,0 +1,142 @@
+package remotesigner
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+// Client implements the crypto.Signer by a remote signer service over mTLS,
+// so the node signer private key never lives on the internet-facing host.
+type Client struct {
+	endpoint string
+	client   *http.Client
+	public   crypto.Key
+}
+
+func NewClient(endpoint, certFile, keyFile, caFile string) (*Client, error) {
+	conf, err := loadTLSConfig(certFile, keyFile, caFile)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{
+		endpoint: endpoint,
+		client: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: conf},
+		},
+	}
+	var res struct {
+		Key crypto.Key `json:"key"`
+	}
+	err = c.call("/public", nil, &res)
+	if err != nil {
+		return nil, err
+	}
+	if !res.Key.CheckKey() {
+		return nil, fmt.Errorf("invalid remote signer public key %s", res.Key)
+	}
+	c.public = res.Key
+	return c, nil
+}
+
+func (c *Client) PublicKey() crypto.Key {
+	return c.public
+}
+
+func (c *Client) Sign(message crypto.Hash) (crypto.Signature, error) {
+	var res struct {
+		Signature crypto.Signature `json:"signature"`
+	}
+	err := c.call("/sign", map[string]any{"message": message}, &res)
+	if err != nil {
+		return crypto.Signature{}, err
+	}
+	if !c.public.Verify(message, res.Signature) {
+		return crypto.Signature{}, fmt.Errorf("invalid remote signer signature %s for %s", res.Signature, message)
+	}
+	return res.Signature, nil
+}
+
+func (c *Client) CosiCommit(count int) ([]*crypto.CosiNonce, error) {
+	var res struct {
+		Commitments []crypto.Key `json:"commitments"`
+	}
+	err := c.call("/commit", map[string]any{"count": count}, &res)
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Commitments) != count {
+		return nil, fmt.Errorf("invalid remote signer commitments count %d/%d", len(res.Commitments), count)
+	}
+	nonces := make([]*crypto.CosiNonce, count)
+	for i, k := range res.Commitments {
+		nonces[i] = crypto.NewRemoteCosiNonce(k)
+	}
+	return nonces, nil
+}
+
+func (c *Client) CosiRespond(nonce *crypto.CosiNonce, challenge [32]byte) (*[32]byte, error) {
+	var res struct {
+		Response crypto.Key `json:"response"`
+	}
+	err := c.call("/respond", map[string]any{
+		"commitment": nonce.Commitment,
+		"challenge":  crypto.Key(challenge),
+	}, &res)
+	if err != nil {
+		return nil, err
+	}
+	response := [32]byte(res.Response)
+	return &response, nil
+}
+
+func (c *Client) call(path string, params any, out any) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Post(c.endpoint+path, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("remote signer error %d %s", resp.StatusCode, string(data))
+	}
+	return json.Unmarshal(data, out)
+}
+
+func loadTLSConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	ca, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("invalid ca file %s", caFile)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      pool,
+		ClientCAs:    pool,
+		MinVersion:   tls.VersionTLS13,
+	}, nil
+}
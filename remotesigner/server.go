@@ -0,0 +1,135 @@
+package remotesigner
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+const (
+	MaximumCommitmentsCount = 1024
+	MaximumNoncesCached     = 1024 * 256
+)
+
+type Server struct {
+	signer *crypto.KeySigner
+	mutex  sync.Mutex
+	nonces map[crypto.Key]*crypto.CosiNonce
+	queue  []crypto.Key
+}
+
+func NewServer(signer *crypto.KeySigner) *Server {
+	return &Server{
+		signer: signer,
+		nonces: make(map[crypto.Key]*crypto.CosiNonce),
+	}
+}
+
+// ListenAndServe only accepts the clients with certificates signed by the ca
+func (s *Server) ListenAndServe(addr, certFile, keyFile, caFile string) error {
+	conf, err := loadTLSConfig(certFile, keyFile, caFile)
+	if err != nil {
+		return err
+	}
+	conf.ClientAuth = tls.RequireAndVerifyClientCert
+	server := &http.Server{
+		Addr:         addr,
+		Handler:      s,
+		TLSConfig:    conf,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+	return server.ListenAndServeTLS("", "")
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	var params struct {
+		Message    crypto.Hash `json:"message"`
+		Count      int         `json:"count"`
+		Commitment crypto.Key  `json:"commitment"`
+		Challenge  crypto.Key  `json:"challenge"`
+	}
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1024)).Decode(&params)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var res any
+	switch r.URL.Path {
+	case "/public":
+		res = map[string]any{"key": s.signer.PublicKey()}
+	case "/sign":
+		res, err = s.sign(params.Message)
+	case "/commit":
+		res, err = s.commit(params.Count)
+	case "/respond":
+		res, err = s.respond(params.Commitment, params.Challenge)
+	default:
+		err = fmt.Errorf("invalid path %s", r.URL.Path)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+func (s *Server) sign(message crypto.Hash) (map[string]any, error) {
+	sig, err := s.signer.Sign(message)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"signature": sig}, nil
+}
+
+func (s *Server) commit(count int) (map[string]any, error) {
+	if count <= 0 || count > MaximumCommitmentsCount {
+		return nil, fmt.Errorf("invalid commitments count %d", count)
+	}
+	nonces, err := s.signer.CosiCommit(count)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	commitments := make([]crypto.Key, count)
+	for i, n := range nonces {
+		commitments[i] = n.Commitment
+		s.nonces[n.Commitment] = n
+		s.queue = append(s.queue, n.Commitment)
+	}
+	for len(s.queue) > MaximumNoncesCached {
+		delete(s.nonces, s.queue[0])
+		s.queue = s.queue[1:]
+	}
+	return map[string]any{"commitments": commitments}, nil
+}
+
+func (s *Server) respond(commitment, challenge crypto.Key) (map[string]any, error) {
+	s.mutex.Lock()
+	nonce := s.nonces[commitment]
+	delete(s.nonces, commitment)
+	s.mutex.Unlock()
+
+	if nonce == nil {
+		return nil, fmt.Errorf("commitment %s not found", commitment)
+	}
+	response, err := s.signer.CosiRespond(nonce, challenge)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"response": crypto.Key(*response)}, nil
+}
@@ -0,0 +1,120 @@
+package remotesigner
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemoteSigner(t *testing.T) {
+	require := require.New(t)
+
+	root, err := os.MkdirTemp("", "mixin-remote-signer-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+	testGenerateCertificates(require, root)
+
+	seed := make([]byte, 64)
+	crypto.ReadRand(seed)
+	spend := crypto.NewKeyFromSeed(seed)
+	ks := crypto.NewKeySigner(spend)
+
+	conf, err := loadTLSConfig(filepath.Join(root, "server.crt"), filepath.Join(root, "server.key"), filepath.Join(root, "ca.crt"))
+	require.Nil(err)
+	conf.ClientAuth = tls.RequireAndVerifyClientCert
+	hs := httptest.NewUnstartedServer(NewServer(ks))
+	hs.TLS = conf
+	hs.StartTLS()
+	defer hs.Close()
+
+	_, err = NewClient(hs.URL, filepath.Join(root, "server.crt"), filepath.Join(root, "server.key"), filepath.Join(root, "ca.crt"))
+	require.NotNil(err)
+
+	client, err := NewClient(hs.URL, filepath.Join(root, "client.crt"), filepath.Join(root, "client.key"), filepath.Join(root, "ca.crt"))
+	require.Nil(err)
+	pub := client.PublicKey()
+	require.Equal(spend.Public(), pub)
+
+	msg := crypto.Blake3Hash([]byte("remote signer"))
+	sig, err := client.Sign(msg)
+	require.Nil(err)
+	require.True(pub.Verify(msg, sig))
+
+	nonces, err := client.CosiCommit(3)
+	require.Nil(err)
+	require.Len(nonces, 3)
+	cosi, err := crypto.CosiAggregateCommitment(map[int]*crypto.Key{0: &nonces[1].Commitment})
+	require.Nil(err)
+	publics := []*crypto.Key{&pub}
+	response, err := cosi.SignerResponse(client, nonces[1], publics, msg)
+	require.Nil(err)
+	require.Nil(cosi.VerifyResponse(publics, 0, response, msg))
+
+	_, err = client.CosiRespond(crypto.NewRemoteCosiNonce(pub), [32]byte{})
+	require.NotNil(err)
+	require.Contains(err.Error(), "not found")
+
+	hs.Close()
+	_, err = client.Sign(msg)
+	require.NotNil(err)
+}
+
+func testGenerateCertificates(require *require.Assertions, dir string) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(err)
+	ca := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "mixin test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, ca, ca, &caKey.PublicKey, caKey)
+	require.Nil(err)
+	testWritePEM(require, filepath.Join(dir, "ca.crt"), "CERTIFICATE", der)
+
+	for i, name := range []string{"server", "client"} {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.Nil(err)
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(int64(i + 2)),
+			Subject:      pkix.Name{CommonName: name},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		}
+		if name == "server" {
+			tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+		} else {
+			tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+		require.Nil(err)
+		testWritePEM(require, filepath.Join(dir, name+".crt"), "CERTIFICATE", der)
+		kb, err := x509.MarshalECPrivateKey(key)
+		require.Nil(err)
+		testWritePEM(require, filepath.Join(dir, name+".key"), "EC PRIVATE KEY", kb)
+	}
+}
+
+func testWritePEM(require *require.Assertions, path, typ string, der []byte) {
+	data := pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der})
+	require.Nil(os.WriteFile(path, data, 0600))
+}
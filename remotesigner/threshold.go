@@ -0,0 +1,36 @@
+package remotesigner
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+)
+
+// NewThresholdSigner connects to the co-signers, each of them is a remote
+// signer service with a key share as its signer key, listed as index@endpoint.
+func NewThresholdSigner(threshold int, cosigners []string, certFile, keyFile, caFile string) (*crypto.ThresholdSigner, error) {
+	signers := make(map[uint16]crypto.Signer)
+	for _, cs := range cosigners {
+		parts := strings.SplitN(cs, "@", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid co-signer %s", cs)
+		}
+		index, err := strconv.ParseUint(parts[0], 10, 16)
+		if err != nil || index == 0 {
+			return nil, fmt.Errorf("invalid co-signer index %s", cs)
+		}
+		if signers[uint16(index)] != nil {
+			return nil, fmt.Errorf("duplicated co-signer index %s", cs)
+		}
+		client, err := NewClient(parts[1], certFile, keyFile, caFile)
+		if err != nil {
+			logger.Printf("remotesigner.NewThresholdSigner(%s) => %v\n", cs, err)
+			continue
+		}
+		signers[uint16(index)] = client
+	}
+	return crypto.NewThresholdSigner(threshold, signers)
+}
@@ -0,0 +1,121 @@
+package replica
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/mixin/storage"
+)
+
+// the trailer of the replication stream, sent by the primary only after all
+// the entries are written
+const DoneTrailer = "X-Replication-Done"
+
+// the follower pulls the writes of the primary store from the replication
+// endpoint of the primary admin listener, authorized by the admin token, and
+// loads them to the local store. the kernel of the follower never runs, so
+// it serves the read methods of the RPC with the store of the primary,
+// without the p2p sync of its own, and the node state is reloaded from the
+// store after each pull.
+type Follower struct {
+	store    storage.Store
+	endpoint string
+	token    string
+	interval time.Duration
+	client   *http.Client
+}
+
+func NewFollower(store storage.Store, primary, token, ca string, interval time.Duration) (*Follower, error) {
+	endpoint, err := url.JoinPath(primary, "replication")
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if ca != "" {
+		pem, err := os.ReadFile(ca)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("invalid ca file %s", ca)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+	return &Follower{
+		store:    store,
+		endpoint: endpoint,
+		token:    token,
+		interval: interval,
+		client:   &http.Client{Transport: transport},
+	}, nil
+}
+
+// Loop pulls the primary periodically, and reloads the state of the node
+// loaded from the store whenever a pull moves the store forward, so the
+// read methods never serve the state of the replica boot.
+func (f *Follower) Loop(reload func() error) {
+	var loaded uint64
+	for {
+		err := f.Pull()
+		if err != nil {
+			logger.Printf("replica.Pull(%s) => %v\n", f.endpoint, err)
+		}
+		version, err := f.store.ReadReplicaVersion()
+		if err == nil && version != loaded {
+			err = reload()
+		}
+		if err != nil {
+			logger.Printf("replica.Reload(%s, %d) => %v\n", f.endpoint, version, err)
+		} else {
+			loaded = version
+		}
+		time.Sleep(f.interval)
+	}
+}
+
+// Pull loads the writes of the primary since the last complete pull, the
+// first pull of an empty store loads the whole store of the primary.
+func (f *Follower) Pull() error {
+	since, err := f.store.ReadReplicaVersion()
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("GET", f.endpoint+"?since="+strconv.FormatUint(since, 10), nil)
+	if err != nil {
+		return err
+	}
+	if f.token != "" {
+		req.Header.Set("Authorization", "Bearer "+f.token)
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/octet-stream" {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("replication response %d %s", resp.StatusCode, body)
+	}
+	next, err := f.store.LoadReplication(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.Trailer.Get(DoneTrailer) != "true" {
+		return fmt.Errorf("replication since %d interrupted", since)
+	}
+	if next == since {
+		return nil
+	}
+	logger.Verbosef("replica.Pull(%s) %d => %d\n", f.endpoint, since, next)
+	return f.store.WriteReplicaVersion(next)
+}
@@ -0,0 +1,108 @@
+package replica
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFollower(t *testing.T) {
+	require := require.New(t)
+
+	custom, err := config.Initialize("../config/config.example.toml")
+	require.Nil(err)
+	custom.Storage.AuditRetention = 3
+	root, err := os.MkdirTemp("", "mixin-replica-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	primary, err := storage.NewBadgerStore(custom, root+"/primary")
+	require.Nil(err)
+	defer primary.Close()
+	replica, err := storage.NewBadgerStore(custom, root+"/replica")
+	require.Nil(err)
+	defer replica.Close()
+
+	audit := func(n int) {
+		for i := range n {
+			err := primary.WriteAuditEntry(&common.AuditEntry{
+				Timestamp: uint64(i + 1),
+				Actor:     "kernel",
+				Action:    common.AuditActionKeyUnlock,
+				Detail:    strconv.Itoa(i),
+			})
+			require.Nil(err)
+		}
+	}
+	audit(2)
+
+	var broken bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		since, err := strconv.ParseUint(r.URL.Query().Get("since"), 10, 64)
+		require.Nil(err)
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Trailer", DoneTrailer)
+		err = primary.WriteReplication(w, since)
+		require.Nil(err)
+		if !broken {
+			w.Header().Set(DoneTrailer, "true")
+		}
+	}))
+	defer server.Close()
+
+	follower, err := NewFollower(replica, server.URL, "invalid", "", time.Second)
+	require.Nil(err)
+	err = follower.Pull()
+	require.NotNil(err)
+	require.Contains(err.Error(), "replication response 401")
+
+	follower, err = NewFollower(replica, server.URL, "secret", "", time.Second)
+	require.Nil(err)
+	err = follower.Pull()
+	require.Nil(err)
+	entries, err := replica.ListAuditEntries(0, 10, "")
+	require.Nil(err)
+	require.Len(entries, 2)
+	version, err := replica.ReadReplicaVersion()
+	require.Nil(err)
+	require.Greater(version, uint64(0))
+
+	audit(2)
+	broken = true
+	err = follower.Pull()
+	require.NotNil(err)
+	require.Contains(err.Error(), "interrupted")
+	old, err := replica.ReadReplicaVersion()
+	require.Nil(err)
+	require.Equal(version, old)
+
+	broken = false
+	err = follower.Pull()
+	require.Nil(err)
+	entries, err = replica.ListAuditEntries(0, 10, "")
+	require.Nil(err)
+	require.Len(entries, 3)
+	require.Equal(uint64(2), entries[0].Sequence)
+	require.Equal(uint64(4), entries[2].Sequence)
+	version, err = replica.ReadReplicaVersion()
+	require.Nil(err)
+	require.Greater(version, old)
+
+	err = follower.Pull()
+	require.Nil(err)
+	old, err = replica.ReadReplicaVersion()
+	require.Nil(err)
+	require.Equal(version, old)
+}
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"net/http"
 	"time"
+
+	"github.com/MixinNetwork/mixin/common"
 )
 
 func CallMixinRPC(node, method string, params []any) ([]byte, error) {
@@ -35,8 +37,9 @@ func CallMixinRPC(node, method string, params []any) ([]byte, error) {
 	}
 
 	var result struct {
-		Data  any `json:"data"`
-		Error any `json:"error"`
+		Data  any              `json:"data"`
+		Error any              `json:"error"`
+		Code  common.ErrorCode `json:"code"`
 	}
 	dec := json.NewDecoder(resp.Body)
 	dec.UseNumber()
@@ -44,7 +47,9 @@ func CallMixinRPC(node, method string, params []any) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	if result.Error != nil {
+	if result.Error != nil && result.Code != "" {
+		return nil, common.Errorf(result.Code, "CallMixinRPC(%s, %s, %s) => %v", node, method, params, result.Error)
+	} else if result.Error != nil {
 		return nil, fmt.Errorf("CallMixinRPC(%s, %s, %s) => %v", node, method, params, result.Error)
 	}
 	if result.Data == nil {
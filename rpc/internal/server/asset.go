@@ -28,3 +28,48 @@ func readAsset(store storage.Store, params []any) (map[string]any, error) {
 		"balance":   balance,
 	}, nil
 }
+
+// the deposits and withdrawals are complete only if indexed from the genesis,
+// otherwise the since is the first topology indexed.
+func listAssetFlows(store storage.Store, params []any) (map[string]any, error) {
+	if len(params) != 0 {
+		return nil, errors.New("invalid params count")
+	}
+	since, found, err := store.ReadAssetFlowSince()
+	if err != nil {
+		return nil, err
+	}
+	flows, err := store.ListAssetFlows()
+	if err != nil {
+		return nil, err
+	}
+	assets := make([]map[string]any, len(flows))
+	for i, f := range flows {
+		asset, balance, err := store.ReadAssetWithBalance(f.Asset)
+		if err != nil {
+			return nil, err
+		}
+		item := map[string]any{
+			"id":                f.Asset,
+			"deposits":          f.Deposits,
+			"deposits_count":    f.DepositsCount,
+			"withdrawals":       f.Withdrawals,
+			"withdrawals_count": f.WithdrawalsCount,
+			"topology":          f.Topology,
+			"balance":           balance,
+		}
+		if asset != nil {
+			item["chain"] = asset.Chain
+			item["asset_key"] = asset.AssetKey
+		}
+		assets[i] = item
+	}
+	data := map[string]any{
+		"complete": found && since == 0,
+		"assets":   assets,
+	}
+	if found {
+		data["since"] = since
+	}
+	return data, nil
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/mixin/storage"
+)
+
+func listAuditEntries(store storage.Store, params []any) ([]map[string]any, error) {
+	if len(params) != 2 && len(params) != 3 {
+		return nil, errors.New("invalid params count")
+	}
+	offset, err := strconv.ParseUint(fmt.Sprint(params[0]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.ParseUint(fmt.Sprint(params[1]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	var action string
+	if len(params) == 3 {
+		action = fmt.Sprint(params[2])
+	}
+	entries, err := store.ListAuditEntries(offset, count, action)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]map[string]any, len(entries))
+	for i, e := range entries {
+		result[i] = map[string]any{
+			"sequence":  e.Sequence,
+			"timestamp": e.Timestamp,
+			"actor":     e.Actor,
+			"action":    e.Action,
+			"source":    e.Source,
+			"detail":    e.Detail,
+		}
+	}
+	return result, nil
+}
+
+const (
+	auditQueueSize = 4096
+	auditBatchSize = 100
+)
+
+// the methods only available to localhost, or to the admin listener if any,
+// the invocations are audited as admin actions, and the other attempts as
+// authentication failures
+var adminMethods = map[string]bool{
+	"setnoderole":              true,
+	"listloglevels":            true,
+	"setloglevel":              true,
+	"setdiagnostics":           true,
+	"writesupportbundle":       true,
+	"listwithdrawalclaims":     true,
+	"buildwithdrawalclaims":    true,
+	"proposecustodianupdate":   true,
+	"listcustodianproposals":   true,
+	"buildcustodianupdate":     true,
+	"buildsweeptransaction":    true,
+	"listauditentries":         true,
+	"registerwalletaccount":    true,
+	"listwalletoutputs":        true,
+	"getwalletbalance":         true,
+	"registerwalletsequence":   true,
+	"registerwalletsubaddress": true,
+	"getwalletsequence":        true,
+	"registerwebhook":          true,
+	"listwebhooks":             true,
+	"removewebhook":            true,
+}
+
+// the auditor writes the entries in batches from a queue, so the RPC calls
+// never wait for the store, and the entries are dropped when the queue is
+// full. The params are never recorded because they may contain private keys.
+type auditor struct {
+	store    storage.Store
+	entries  chan *common.AuditEntry
+	failures *common.AuditThrottle
+}
+
+func newAuditor(store storage.Store) *auditor {
+	return &auditor{
+		store:    store,
+		entries:  make(chan *common.AuditEntry, auditQueueSize),
+		failures: common.NewAuditThrottle(time.Minute),
+	}
+}
+
+func (a *auditor) loop() {
+	for e := range a.entries {
+		batch := []*common.AuditEntry{e}
+		for len(batch) < auditBatchSize && len(a.entries) > 0 {
+			batch = append(batch, <-a.entries)
+		}
+		err := a.store.WriteAuditEntries(batch)
+		if err != nil {
+			logger.Printf("auditor.WriteAuditEntries(%d) => %v\n", len(batch), err)
+		}
+	}
+}
+
+func (a *auditor) call(remoteAddr, method string, admin bool) {
+	if !adminMethods[method] {
+		return
+	}
+	source := remoteHost(remoteAddr)
+	if admin {
+		a.record(common.AuditActionRPCAdmin, source, "method="+method)
+		return
+	}
+	if a.failures.Allow(source, uint64(time.Now().UnixNano())) {
+		a.record(common.AuditActionRPCAuthFailure, source, "method="+method)
+	}
+}
+
+func (a *auditor) submit(remoteAddr, hash string, err error) {
+	detail := "hash=" + hash
+	if err != nil {
+		detail = "error=" + err.Error()
+	}
+	if len(detail) > 1024 {
+		detail = detail[:1024]
+	}
+	a.record(common.AuditActionTransactionSubmit, remoteHost(remoteAddr), detail)
+}
+
+func (a *auditor) record(action, source, detail string) {
+	e := &common.AuditEntry{
+		Timestamp: uint64(time.Now().UnixNano()),
+		Actor:     "rpc",
+		Action:    action,
+		Source:    source,
+		Detail:    detail,
+	}
+	select {
+	case a.entries <- e:
+	default:
+		logger.Printf("auditor.record(%s, %s) dropped\n", action, source)
+	}
+}
+
+func remoteHost(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/storage"
+	"github.com/stretchr/testify/require"
+)
+
+type auditTestStore struct {
+	storage.Store
+	entries []*common.AuditEntry
+}
+
+func (s *auditTestStore) WriteAuditEntries(entries []*common.AuditEntry) error {
+	s.entries = append(s.entries, entries...)
+	return nil
+}
+
+func TestAuditor(t *testing.T) {
+	require := require.New(t)
+
+	store := &auditTestStore{}
+	a := newAuditor(store)
+	a.call("127.0.0.1:51000", "getinfo", true)
+	a.call("127.0.0.1:51000", "setloglevel", true)
+	a.call("10.0.0.1:51000", "registerwalletaccount", false)
+	a.call("10.0.0.1:51001", "listwebhooks", false)
+	a.call("10.0.0.2:51000", "listwebhooks", false)
+	a.submit("[::1]:51000", "", errors.New("invalid transaction"))
+	a.submit("10.0.0.3:51000", "f00d", nil)
+	require.Len(a.entries, 5)
+
+	close(a.entries)
+	a.loop()
+	require.Len(store.entries, 5)
+	e := store.entries[0]
+	require.Equal(common.AuditActionRPCAdmin, e.Action)
+	require.Equal("127.0.0.1", e.Source)
+	require.Equal("method=setloglevel", e.Detail)
+	e = store.entries[1]
+	require.Equal(common.AuditActionRPCAuthFailure, e.Action)
+	require.Equal("10.0.0.1", e.Source)
+	require.Equal("method=registerwalletaccount", e.Detail)
+	require.Equal("10.0.0.2", store.entries[2].Source)
+	e = store.entries[3]
+	require.Equal(common.AuditActionTransactionSubmit, e.Action)
+	require.Equal("::1", e.Source)
+	require.Equal("error=invalid transaction", e.Detail)
+	require.Equal("hash=f00d", store.entries[4].Detail)
+	for _, e := range store.entries {
+		require.Nil(e.Verify())
+	}
+}
+
+func TestAdminListener(t *testing.T) {
+	require := require.New(t)
+
+	custom := &config.Custom{}
+	public := &RPC{custom: custom}
+	admin := &RPC{custom: custom, admin: true, token: strings.Repeat("a", 32)}
+
+	r := httptest.NewRequest("POST", "/", nil)
+	r.RemoteAddr = "127.0.0.1:51000"
+	require.True(public.authorized(r))
+	require.True(public.isAdmin(r))
+	require.False(admin.authorized(r))
+	r.Header.Set("Authorization", "Bearer "+strings.Repeat("b", 32))
+	require.False(admin.authorized(r))
+	r.Header.Set("Authorization", "Bearer "+strings.Repeat("a", 32))
+	require.True(admin.authorized(r))
+	require.True(admin.isAdmin(r))
+
+	custom.Admin.Port = 6861
+	require.False(public.isAdmin(r))
+	r.RemoteAddr = "10.0.0.1:51000"
+	require.True(admin.isAdmin(r))
+	custom.Admin.Port = 0
+	require.False(public.isAdmin(r))
+	r.RemoteAddr = "[::1]:51000"
+	require.True(public.isAdmin(r))
+	r.RemoteAddr = "127.0.0.1.example.com:51000"
+	require.False(public.isAdmin(r))
+}
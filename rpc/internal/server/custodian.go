@@ -1,6 +1,17 @@
 package server
 
-import "github.com/MixinNetwork/mixin/storage"
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/storage"
+)
+
+const custodianPendingUpdatesLimit = 100
 
 func getCustodianHistory(store storage.Store, params []any) ([]map[string]any, error) {
 	curs, err := store.ListCustodianUpdates()
@@ -18,3 +29,271 @@ func getCustodianHistory(store storage.Store, params []any) ([]map[string]any, e
 	}
 	return result, nil
 }
+
+func getCustodian(store storage.Store, params []any) (map[string]any, error) {
+	if len(params) != 0 {
+		return nil, errors.New("invalid params count")
+	}
+	cur, err := store.ReadCustodian(^uint64(0))
+	if err != nil || cur == nil {
+		return nil, err
+	}
+	nodes := make([]map[string]any, len(cur.Nodes))
+	for i, n := range cur.Nodes {
+		var id crypto.Hash
+		copy(id[:], n.Extra[129:161])
+		nodes[i] = map[string]any{
+			"custodian": n.Custodian.String(),
+			"payee":     n.Payee.String(),
+			"node":      id,
+		}
+	}
+	data := map[string]any{
+		"custodian":   cur.Custodian.String(),
+		"nodes":       nodes,
+		"transaction": cur.Transaction,
+		"timestamp":   cur.Timestamp,
+	}
+	if cur.Signature != nil {
+		data["approval"] = cur.Signature
+	}
+	return data, nil
+}
+
+// the custodian update transactions sent but not finalized yet, the price is
+// by the current custodian nodes, and the error is the reason if the extra is
+// invalid.
+func listPendingCustodianUpdates(store storage.Store, params []any) ([]map[string]any, error) {
+	if len(params) != 0 {
+		return nil, errors.New("invalid params count")
+	}
+	txs, err := store.CacheListTransactions(common.TransactionTypeCustodianUpdateNodes, custodianPendingUpdatesLimit)
+	if err != nil {
+		return nil, err
+	}
+	prev, err := store.ReadCustodian(^uint64(0))
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]map[string]any, 0)
+	for _, tx := range txs {
+		hash := tx.PayloadHash()
+		_, snap, err := store.ReadTransaction(hash)
+		if err != nil {
+			return nil, err
+		}
+		if len(snap) > 0 {
+			continue
+		}
+		item := map[string]any{
+			"transaction": hash,
+			"amount":      tx.Outputs[0].Amount,
+		}
+		cur, err := common.ParseCustodianUpdateNodesExtra(tx.Extra, false)
+		if err != nil {
+			item["error"] = err.Error()
+			result = append(result, item)
+			continue
+		}
+		item["custodian"] = cur.Custodian.String()
+		item["nodes"] = len(cur.Nodes)
+		if prev != nil {
+			item["price"] = common.CustodianUpdateNodesPrice(prev, cur.Nodes)
+		}
+		result = append(result, item)
+	}
+	return result, nil
+}
+
+// the custodian holds all the deposited assets, so the balance of an asset
+// is its deposits minus its withdrawals, but the XIN is excluded because its
+// balance has the mint and the genesis.
+func listCustodianBalances(store storage.Store, params []any) ([]map[string]any, error) {
+	if len(params) != 0 {
+		return nil, errors.New("invalid params count")
+	}
+	assets, err := store.ListAssetsWithBalance()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]map[string]any, 0)
+	for _, a := range assets {
+		if a.Id == common.XINAssetId {
+			continue
+		}
+		result = append(result, map[string]any{
+			"id":        a.Id,
+			"chain":     a.Asset.Chain,
+			"asset_key": a.Asset.AssetKey,
+			"balance":   a.Balance,
+			"capacity":  common.GetAssetCapacity(a.Id),
+		})
+	}
+	return result, nil
+}
+
+func proposeCustodianUpdate(store storage.Store, params []any) (map[string]any, error) {
+	if len(params) != 2 {
+		return nil, errors.New("invalid params count")
+	}
+	custodian, err := common.NewAddressFromString(fmt.Sprint(params[0]))
+	if err != nil {
+		return nil, err
+	}
+	node, err := hex.DecodeString(fmt.Sprint(params[1]))
+	if err != nil {
+		return nil, err
+	}
+	p, err := store.WriteCustodianProposal(&custodian, node)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{
+		"custodian": p.Custodian.String(),
+		"nodes":     len(p.Nodes),
+		"timestamp": p.Timestamp,
+	}, nil
+}
+
+// the proposals of custodians already updated are not listed, and the
+// approval is the hash of the extra to be signed by the current custodian
+func listCustodianProposals(store storage.Store, params []any) ([]map[string]any, error) {
+	if len(params) != 0 {
+		return nil, errors.New("invalid params count")
+	}
+	proposals, err := store.ListCustodianProposals()
+	if err != nil {
+		return nil, err
+	}
+	curs, err := store.ListCustodianUpdates()
+	if err != nil {
+		return nil, err
+	}
+	filter := make(map[string]bool)
+	for _, cur := range curs {
+		filter[cur.Custodian.String()] = true
+	}
+	prev, err := store.ReadCustodian(^uint64(0))
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]map[string]any, 0)
+	for _, p := range proposals {
+		if filter[p.Custodian.String()] {
+			continue
+		}
+		item := map[string]any{
+			"custodian": p.Custodian.String(),
+			"nodes":     len(p.Nodes),
+			"timestamp": p.Timestamp,
+		}
+		extra, err := common.EncodeCustodianUpdateNodesExtra(&p.Custodian, p.Nodes)
+		if err != nil {
+			item["error"] = err.Error()
+			result = append(result, item)
+			continue
+		}
+		cur, err := common.ParseCustodianUpdateNodesExtra(append(extra, make([]byte, 64)...), false)
+		if err != nil {
+			return nil, err
+		}
+		item["extra"] = hex.EncodeToString(extra)
+		item["approval"] = crypto.Blake3Hash(extra)
+		if prev != nil {
+			item["price"] = common.CustodianUpdateNodesPrice(prev, cur.Nodes)
+		}
+		result = append(result, item)
+	}
+	return result, nil
+}
+
+func buildCustodianUpdate(store storage.Store, params []any) (map[string]any, error) {
+	if len(params) != 5 {
+		return nil, errors.New("invalid params count")
+	}
+	custodian, err := common.NewAddressFromString(fmt.Sprint(params[0]))
+	if err != nil {
+		return nil, err
+	}
+	sig, err := hex.DecodeString(fmt.Sprint(params[1]))
+	if err != nil {
+		return nil, err
+	}
+	var approval crypto.Signature
+	if len(sig) != len(approval) {
+		return nil, fmt.Errorf("invalid custodian update approval signature %x", sig)
+	}
+	copy(approval[:], sig)
+	receiver, err := common.NewAddressFromString(fmt.Sprint(params[2]))
+	if err != nil {
+		return nil, err
+	}
+	hash, err := crypto.HashFromString(fmt.Sprint(params[3]))
+	if err != nil {
+		return nil, err
+	}
+	index, err := strconv.ParseUint(fmt.Sprint(params[4]), 10, 16)
+	if err != nil {
+		return nil, err
+	}
+
+	proposals, err := store.ListCustodianProposals()
+	if err != nil {
+		return nil, err
+	}
+	var proposal *storage.CustodianProposal
+	for _, p := range proposals {
+		if p.Custodian.String() == custodian.String() {
+			proposal = p
+		}
+	}
+	if proposal == nil {
+		return nil, fmt.Errorf("custodian proposal %s not found", custodian)
+	}
+	extra, err := common.EncodeCustodianUpdateNodesExtra(&custodian, proposal.Nodes)
+	if err != nil {
+		return nil, err
+	}
+	prev, err := store.ReadCustodian(^uint64(0))
+	if err != nil {
+		return nil, err
+	}
+	if prev == nil || !prev.Custodian.PublicSpendKey.Verify(crypto.Blake3Hash(extra), approval) {
+		return nil, fmt.Errorf("invalid custodian update approval signature %s", approval)
+	}
+	extra = append(extra, approval[:]...)
+	cur, err := common.ParseCustodianUpdateNodesExtra(extra, false)
+	if err != nil {
+		return nil, err
+	}
+
+	utxo, err := store.ReadUTXOLock(hash, uint(index))
+	if err != nil {
+		return nil, err
+	}
+	if utxo == nil || utxo.LockHash.HasValue() || utxo.Asset != common.XINAssetId {
+		return nil, fmt.Errorf("invalid custodian update input %s:%d", hash, index)
+	}
+	price := common.CustodianUpdateNodesPrice(prev, cur.Nodes)
+	if utxo.Amount.Cmp(price) < 0 {
+		return nil, fmt.Errorf("insufficient custodian update input %s %s", utxo.Amount, price)
+	}
+
+	return map[string]any{
+		"version": common.TxVersionHashSignature,
+		"asset":   common.XINAssetId,
+		"inputs": []map[string]any{{
+			"hash":  hash,
+			"index": index,
+		}},
+		"outputs": []map[string]any{{
+			"type":     common.OutputTypeCustodianUpdateNodes,
+			"amount":   utxo.Amount,
+			"script":   common.NewThresholdScript(common.Operator64),
+			"accounts": []*common.Address{&receiver},
+		}},
+		"extra": hex.EncodeToString(extra),
+	}, nil
+}
@@ -0,0 +1,34 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/MixinNetwork/mixin/diagnostics"
+)
+
+// setDiagnostics toggles the diagnostics endpoints of the [dev] port, the
+// listener must be configured at startup to be enabled at runtime.
+func setDiagnostics(params []any) (map[string]any, error) {
+	if len(params) != 1 {
+		return nil, errors.New("invalid params count")
+	}
+	on, err := strconv.ParseBool(fmt.Sprint(params[0]))
+	if err != nil {
+		return nil, err
+	}
+	diagnostics.SetEnabled(on)
+	return map[string]any{"enabled": diagnostics.Enabled()}, nil
+}
+
+func writeSupportBundle(params []any) (map[string]any, error) {
+	if len(params) != 0 {
+		return nil, errors.New("invalid params count")
+	}
+	path, err := diagnostics.WriteBundle("rpc")
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"path": path}, nil
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	defaultCBORType    = "application/cbor"
+	defaultMsgpackType = "application/msgpack"
+)
+
+// negotiateContentType picks the response type with the highest quality in the
+// Accept header, the first one wins on ties, and JSON is the default for all
+// the clients not asking for CBOR or MessagePack.
+func negotiateContentType(accept string) string {
+	best, quality := defaultJSONType, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, found := params["q"]; found {
+			q, err = strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+		}
+		var typ string
+		switch mt {
+		case "application/cbor":
+			typ = defaultCBORType
+		case "application/msgpack", "application/x-msgpack", "application/vnd.msgpack":
+			typ = defaultMsgpackType
+		case "application/json", "application/*", "*/*":
+			typ = defaultJSONType
+		default:
+			continue
+		}
+		if q > quality {
+			best, quality = typ, q
+		}
+	}
+	return best
+}
+
+// marshalBody encodes the response body in the content type, the values are
+// encoded directly with the field names of the JSON tags, and the types with
+// the JSON marshaler, e.g. the hashes and amounts, are the same strings as in
+// JSON, so the CBOR and MessagePack documents have the same fields and values.
+func marshalBody(body any, typ string) ([]byte, error) {
+	switch typ {
+	case defaultJSONType:
+		return json.Marshal(body)
+	case defaultCBORType:
+		return cborEncMode.Marshal(body)
+	case defaultMsgpackType:
+		var buf bytes.Buffer
+		enc := msgpack.NewEncoder(&buf)
+		enc.SetCustomStructTag("json")
+		enc.SetSortMapKeys(true)
+		enc.UseCompactInts(true)
+		err := enc.Encode(body)
+		return buf.Bytes(), err
+	}
+	return nil, fmt.Errorf("invalid content type %s", typ)
+}
+
+var cborEncMode = func() cbor.EncMode {
+	em, err := cbor.EncOptions{
+		Sort:                    cbor.SortBytewiseLexical,
+		Time:                    cbor.TimeRFC3339Nano,
+		JSONMarshalerTranscoder: jsonStringTranscoder{},
+	}.EncMode()
+	if err != nil {
+		panic(err)
+	}
+	return em
+}()
+
+// the JSON marshalers in the responses are all encoded as strings
+var msgpackStringTypes = []json.Marshaler{
+	crypto.Hash{},
+	crypto.Key{},
+	crypto.Signature{},
+	crypto.CosiSignature{},
+	common.Integer{},
+	common.Address{},
+	common.Script{},
+}
+
+func init() {
+	for _, v := range msgpackStringTypes {
+		msgpack.Register(v, encodeMsgpackJSONString, nil)
+	}
+}
+
+func encodeMsgpackJSONString(enc *msgpack.Encoder, v reflect.Value) error {
+	s, err := marshalJSONString(v.Interface().(json.Marshaler))
+	if err != nil {
+		return err
+	}
+	return enc.EncodeString(s)
+}
+
+type jsonStringTranscoder struct{}
+
+func (jsonStringTranscoder) Transcode(w io.Writer, r io.Reader) error {
+	var s string
+	err := json.NewDecoder(r).Decode(&s)
+	if err != nil {
+		return err
+	}
+	b, err := cbor.Marshal(s)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+func marshalJSONString(m json.Marshaler) (string, error) {
+	b, err := m.MarshalJSON()
+	if err != nil {
+		return "", err
+	}
+	var s string
+	err = json.Unmarshal(b, &s)
+	return s, err
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"encoding/hex"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContentNegotiation(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal(defaultJSONType, negotiateContentType(""))
+	require.Equal(defaultJSONType, negotiateContentType("text/html, */*"))
+	require.Equal(defaultCBORType, negotiateContentType("application/cbor"))
+	require.Equal(defaultMsgpackType, negotiateContentType("application/x-msgpack, application/json;q=0.9"))
+	require.Equal(defaultJSONType, negotiateContentType("application/json, application/cbor"))
+	require.Equal(defaultCBORType, negotiateContentType("application/json;q=0.5, application/cbor;q=0.8"))
+	require.Equal(defaultJSONType, negotiateContentType("application/cbor;q=0"))
+}
+
+func TestBodyEncoding(t *testing.T) {
+	require := require.New(t)
+
+	for _, c := range []struct {
+		value   any
+		cbor    string
+		msgpack string
+	}{
+		{0, "00", "00"},
+		{24, "1818", "18"},
+		{256, "190100", "cd0100"},
+		{-1, "20", "ff"},
+		{-33, "3820", "d0df"},
+		{-1000, "3903e7", "d1fc18"},
+		{uint64(18446744073709551615), "1bffffffffffffffff", "cfffffffffffffffff"},
+		{1.5, "fb3ff8000000000000", "cb3ff8000000000000"},
+		{nil, "f6", "c0"},
+		{true, "f5", "c3"},
+		{"a", "6161", "a161"},
+		{[]any{1, 2, 3}, "83010203", "93010203"},
+		{map[string]any{"b": []int{2, 3}, "a": 1}, "a26161016162820203", "82a16101a162920203"},
+		{struct {
+			Count int    `json:"count"`
+			Name  string `json:"name,omitempty"`
+		}{Count: 1}, "a165636f756e7401", "81a5636f756e7401"},
+		{common.NewInteger(1), "6a312e3030303030303030", "aa312e3030303030303030"},
+		{&crypto.Hash{}, "7840" + strings.Repeat("30", 64), "d940" + strings.Repeat("30", 64)},
+	} {
+		for _, typ := range []string{defaultCBORType, defaultMsgpackType} {
+			b, err := marshalBody(c.value, typ)
+			require.Nil(err)
+			if typ == defaultCBORType {
+				require.Equal(c.cbor, hex.EncodeToString(b), c.value)
+			} else {
+				require.Equal(c.msgpack, hex.EncodeToString(b), c.value)
+			}
+		}
+	}
+
+	s := strings.Repeat("x", 40)
+	b, err := marshalBody(s, defaultMsgpackType)
+	require.Nil(err)
+	require.Equal("d928", hex.EncodeToString(b[:2]))
+	b, err = marshalBody(s, defaultCBORType)
+	require.Nil(err)
+	require.Equal("7828", hex.EncodeToString(b[:2]))
+}
+
+func TestRenderContentType(t *testing.T) {
+	require := require.New(t)
+
+	custom := &config.Custom{}
+	store := &auditTestStore{}
+	impl := &RPC{Store: store, custom: custom, audit: newAuditor(store)}
+
+	r := httptest.NewRequest("GET", "/mixin", nil)
+	r.Header.Set("Accept", "application/cbor")
+	w := httptest.NewRecorder()
+	impl.ServeHTTP(w, r)
+	require.Equal(defaultCBORType, w.Header().Get("Content-Type"))
+	require.Equal("Accept", w.Header().Get("Vary"))
+	require.Equal("a1656572726f7276626164207265717565737420474554202f6d6978696e", hex.EncodeToString(w.Body.Bytes()))
+
+	r = httptest.NewRequest("GET", "/mixin", nil)
+	w = httptest.NewRecorder()
+	impl.ServeHTTP(w, r)
+	require.Equal(defaultJSONType, w.Header().Get("Content-Type"))
+	require.Equal(`{"error":"bad request GET /mixin"}`, w.Body.String())
+}
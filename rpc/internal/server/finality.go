@@ -0,0 +1,43 @@
+package server
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/finality"
+	"github.com/MixinNetwork/mixin/kernel"
+	"github.com/MixinNetwork/mixin/storage"
+)
+
+// the proof is in the abi encoding for the EVM contracts, and the commitment
+// and threshold are what the contract should have for the consensus keys.
+func getFinalityProof(node *kernel.Node, store storage.Store, params []any) (map[string]any, error) {
+	if len(params) != 1 {
+		return nil, errors.New("invalid params count")
+	}
+	hash, err := crypto.HashFromString(fmt.Sprint(params[0]))
+	if err != nil {
+		return nil, err
+	}
+	snap, err := store.ReadSnapshot(hash)
+	if err != nil || snap == nil {
+		return nil, err
+	}
+	keys, threshold, err := node.FinalityKeys(snap.Snapshot)
+	if err != nil {
+		return nil, err
+	}
+	proof, err := finality.NewProof(snap.Snapshot, keys)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{
+		"snapshot":   snap.Hash,
+		"proof":      hex.EncodeToString(proof.Encode()),
+		"commitment": finality.KeysCommitment(keys),
+		"threshold":  threshold,
+		"keys":       len(keys),
+	}, nil
+}
@@ -1,13 +1,16 @@
 package server
 
 import (
+	"crypto/subtle"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"strings"
 	"time"
 
+	"github.com/MixinNetwork/mixin/common"
 	"github.com/MixinNetwork/mixin/config"
 	"github.com/MixinNetwork/mixin/crypto"
 	"github.com/MixinNetwork/mixin/kernel"
@@ -18,6 +21,10 @@ type RPC struct {
 	Store  storage.Store
 	Node   *kernel.Node
 	custom *config.Custom
+	audit  *auditor
+	subs   *submissions
+	admin  bool
+	token  string
 }
 
 type Call struct {
@@ -39,6 +46,9 @@ type Render struct {
 	w     http.ResponseWriter
 	start time.Time
 	id    string
+	typ   string
+	err   error
+	size  int
 }
 
 func (r *Render) RenderData(data any) {
@@ -47,7 +57,11 @@ func (r *Render) RenderData(data any) {
 }
 
 func (r *Render) RenderError(err error) {
+	r.err = err
 	body := map[string]any{"error": err.Error()}
+	if code := common.ErrorCodeOf(err); code != "" {
+		body["code"] = code
+	}
 	r.render(body)
 }
 
@@ -58,12 +72,17 @@ func (r *Render) render(body map[string]any) {
 	if !r.start.IsZero() {
 		body["runtime"] = fmt.Sprint(time.Since(r.start).Seconds())
 	}
-	b, err := json.Marshal(body)
+	if r.typ == "" {
+		r.typ = defaultJSONType
+	}
+	b, err := marshalBody(body, r.typ)
 	if err != nil {
 		panic(err)
 	}
-	r.w.Header().Set("Content-Type", defaultJSONType)
+	r.w.Header().Set("Content-Type", r.typ)
+	r.w.Header().Add("Vary", "Accept")
 	r.w.WriteHeader(http.StatusOK)
+	r.size = len(b)
 	_, err = r.w.Write(b)
 	if err != nil {
 		panic(err)
@@ -82,11 +101,27 @@ func (impl *RPC) renderInfo(rdr *Render) {
 func (impl *RPC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	defer handlePanic(w, r)
 
-	rdr := &Render{w: w}
+	rdr := &Render{w: w, typ: negotiateContentType(r.Header.Get("Accept"))}
+	if !impl.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
 	if r.URL.Path == "/" && r.Method == "GET" {
 		impl.renderInfo(rdr)
 		return
 	}
+	if r.URL.Path == "/readyz" && r.Method == "GET" {
+		impl.handleReady(w)
+		return
+	}
+	if r.URL.Path == "/metrics" && r.Method == "GET" && impl.custom.RPC.Metrics {
+		impl.handleMetrics(w)
+		return
+	}
+	if r.URL.Path == "/replication" && r.Method == "GET" {
+		impl.handleReplication(w, r, rdr)
+		return
+	}
 	if strings.HasPrefix(r.URL.Path, "/objects/") && impl.custom.RPC.ObjectServer {
 		impl.handleObject(w, r, rdr)
 		return
@@ -97,32 +132,96 @@ func (impl *RPC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var call Call
-	dec := json.NewDecoder(r.Body)
+	body := &countingReader{r: r.Body}
+	dec := json.NewDecoder(body)
 	dec.UseNumber()
 	if err := dec.Decode(&call); err != nil {
 		rdr.RenderError(fmt.Errorf("bad request %s", err.Error()))
 		return
 	}
 	rdr.id = call.Id
+	method := call.Method
+	defer func(start time.Time) {
+		if rcv := recover(); rcv != nil {
+			rdr.RenderError(fmt.Errorf("server error"))
+		}
+		observeCall(method, start, body.n, rdr)
+	}(time.Now())
 	if impl.custom.RPC.Runtime {
 		rdr.start = time.Now()
 	}
+	admin := impl.isAdmin(r)
+	impl.audit.call(r.RemoteAddr, call.Method, admin)
+	if impl.replica() && !replicaReadMethods[call.Method] {
+		rdr.RenderError(fmt.Errorf("%s is only available on the primary", call.Method))
+		return
+	}
 	switch call.Method {
 	case "getinfo":
 		impl.renderInfo(rdr)
 	case "listpeers":
 		peers := make([]map[string]any, 0)
-		if strings.HasPrefix(r.RemoteAddr, "127.0.0.1:") {
+		if admin {
 			peers = peerNeighbors(impl.Node.Peer.Neighbors())
 		}
 		rdr.RenderData(peers)
+	case "setnoderole":
+		if !admin {
+			rdr.RenderError(errors.New("node role is only available to localhost"))
+			return
+		}
+		role, err := setNodeRole(impl.Node, call.Params)
+		if err != nil {
+			rdr.RenderError(err)
+		} else {
+			rdr.RenderData(role)
+		}
+	case "listloglevels":
+		if !admin {
+			rdr.RenderError(errors.New("log levels are only available to localhost"))
+			return
+		}
+		rdr.RenderData(listLogLevels())
+	case "setloglevel":
+		if !admin {
+			rdr.RenderError(errors.New("log levels are only available to localhost"))
+			return
+		}
+		levels, err := setLogLevel(call.Params)
+		if err != nil {
+			rdr.RenderError(err)
+		} else {
+			rdr.RenderData(levels)
+		}
+	case "setdiagnostics":
+		if !admin {
+			rdr.RenderError(errors.New("diagnostics are only available to localhost"))
+			return
+		}
+		state, err := setDiagnostics(call.Params)
+		if err != nil {
+			rdr.RenderError(err)
+		} else {
+			rdr.RenderData(state)
+		}
+	case "writesupportbundle":
+		if !admin {
+			rdr.RenderError(errors.New("support bundle is only available to localhost"))
+			return
+		}
+		bundle, err := writeSupportBundle(call.Params)
+		if err != nil {
+			rdr.RenderError(err)
+		} else {
+			rdr.RenderData(bundle)
+		}
 	case "listrelayers":
 		if len(call.Params) != 1 {
 			rdr.RenderError(errors.New("invalid params count"))
 			return
 		}
 		peers := make([]map[string]any, 0)
-		if strings.HasPrefix(r.RemoteAddr, "127.0.0.1:") {
+		if admin {
 			id, _ := crypto.HashFromString(fmt.Sprint(call.Params[0]))
 			peers = peerNeighbors(impl.Node.Peer.GetRemoteRelayers(id))
 		}
@@ -135,7 +234,8 @@ func (impl *RPC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			rdr.RenderData(data)
 		}
 	case "sendrawtransaction":
-		id, err := queueTransaction(impl.Node, call.Params)
+		id, err := queueTransaction(impl.Node, impl.subs, call.Params)
+		impl.audit.submit(r.RemoteAddr, id, err)
 		if err != nil {
 			rdr.RenderError(err)
 		} else {
@@ -148,6 +248,13 @@ func (impl *RPC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		} else {
 			rdr.RenderData(tx)
 		}
+	case "gettransactions":
+		txs, err := getTransactions(impl.Store, call.Params)
+		if err != nil {
+			rdr.RenderError(err)
+		} else {
+			rdr.RenderData(txs)
+		}
 	case "getcachetransaction":
 		tx, err := getCacheTransaction(impl.Store, call.Params)
 		if err != nil {
@@ -155,6 +262,24 @@ func (impl *RPC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		} else {
 			rdr.RenderData(tx)
 		}
+	case "getcachedependencies":
+		deps, err := getCacheDependencies(impl.Node, impl.Store, call.Params)
+		if err != nil {
+			rdr.RenderError(err)
+		} else {
+			rdr.RenderData(deps)
+		}
+	case "purgecachetransactions":
+		if !admin {
+			rdr.RenderError(errors.New("cache purge is only available to localhost"))
+			return
+		}
+		purged, err := purgeCacheTransactions(impl.Node, call.Params)
+		if err != nil {
+			rdr.RenderError(err)
+		} else {
+			rdr.RenderData(purged)
+		}
 	case "getdeposittransaction":
 		tx, err := readDeposit(impl.Store, call.Params)
 		if err != nil {
@@ -169,6 +294,28 @@ func (impl *RPC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		} else {
 			rdr.RenderData(tx)
 		}
+	case "listwithdrawalclaims":
+		if !admin {
+			rdr.RenderError(errors.New("withdrawal claims are only available to localhost"))
+			return
+		}
+		batches, err := listWithdrawalClaims(impl.Store, call.Params)
+		if err != nil {
+			rdr.RenderError(err)
+		} else {
+			rdr.RenderData(batches)
+		}
+	case "buildwithdrawalclaims":
+		if !admin {
+			rdr.RenderError(errors.New("withdrawal claims are only available to localhost"))
+			return
+		}
+		claims, err := buildWithdrawalClaims(impl.Store, call.Params)
+		if err != nil {
+			rdr.RenderError(err)
+		} else {
+			rdr.RenderData(claims)
+		}
 	case "getutxo":
 		utxo, err := getUTXO(impl.Store, call.Params)
 		if err != nil {
@@ -176,6 +323,13 @@ func (impl *RPC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		} else {
 			rdr.RenderData(utxo)
 		}
+	case "verifyoutputownership":
+		result, err := verifyOutputOwnership(impl.Store, call.Params)
+		if err != nil {
+			rdr.RenderError(err)
+		} else {
+			rdr.RenderData(result)
+		}
 	case "getkey":
 		utxo, err := getGhostKey(impl.Store, call.Params)
 		if err != nil {
@@ -197,6 +351,13 @@ func (impl *RPC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		} else {
 			rdr.RenderData(snap)
 		}
+	case "getfinalityproof":
+		proof, err := getFinalityProof(impl.Node, impl.Store, call.Params)
+		if err != nil {
+			rdr.RenderError(err)
+		} else {
+			rdr.RenderData(proof)
+		}
 	case "listsnapshots":
 		snapshots, err := listSnapshots(impl.Node, impl.Store, call.Params)
 		if err != nil {
@@ -204,6 +365,17 @@ func (impl *RPC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		} else {
 			rdr.RenderData(snapshots)
 		}
+	case "listviewsnapshots":
+		if !admin {
+			rdr.RenderError(errors.New("view snapshots are only available to localhost"))
+			return
+		}
+		snapshots, err := listViewSnapshots(impl.Node, impl.Store, call.Params)
+		if err != nil {
+			rdr.RenderError(err)
+		} else {
+			rdr.RenderData(snapshots)
+		}
 	case "listcustodianupdates":
 		curs, err := getCustodianHistory(impl.Store, call.Params)
 		if err != nil {
@@ -211,6 +383,74 @@ func (impl *RPC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		} else {
 			rdr.RenderData(curs)
 		}
+	case "getcustodian":
+		cur, err := getCustodian(impl.Store, call.Params)
+		if err != nil {
+			rdr.RenderError(err)
+		} else {
+			rdr.RenderData(cur)
+		}
+	case "listpendingcustodianupdates":
+		updates, err := listPendingCustodianUpdates(impl.Store, call.Params)
+		if err != nil {
+			rdr.RenderError(err)
+		} else {
+			rdr.RenderData(updates)
+		}
+	case "listassetflows":
+		flows, err := listAssetFlows(impl.Store, call.Params)
+		if err != nil {
+			rdr.RenderError(err)
+		} else {
+			rdr.RenderData(flows)
+		}
+	case "liststatecheckpoints":
+		checkpoints, err := listStateCheckpoints(impl.Node, impl.Store, call.Params)
+		if err != nil {
+			rdr.RenderError(err)
+		} else {
+			rdr.RenderData(checkpoints)
+		}
+	case "listcustodianbalances":
+		balances, err := listCustodianBalances(impl.Store, call.Params)
+		if err != nil {
+			rdr.RenderError(err)
+		} else {
+			rdr.RenderData(balances)
+		}
+	case "proposecustodianupdate":
+		if !admin {
+			rdr.RenderError(errors.New("custodian proposals are only available to localhost"))
+			return
+		}
+		proposal, err := proposeCustodianUpdate(impl.Store, call.Params)
+		if err != nil {
+			rdr.RenderError(err)
+		} else {
+			rdr.RenderData(proposal)
+		}
+	case "listcustodianproposals":
+		if !admin {
+			rdr.RenderError(errors.New("custodian proposals are only available to localhost"))
+			return
+		}
+		proposals, err := listCustodianProposals(impl.Store, call.Params)
+		if err != nil {
+			rdr.RenderError(err)
+		} else {
+			rdr.RenderData(proposals)
+		}
+	case "buildcustodianupdate":
+		if !admin {
+			rdr.RenderError(errors.New("custodian proposals are only available to localhost"))
+			return
+		}
+		raw, err := buildCustodianUpdate(impl.Store, call.Params)
+		if err != nil {
+			rdr.RenderError(err)
+		} else {
+			rdr.RenderData(raw)
+		}
 	case "listmintworks":
 		works, err := listMintWorks(impl.Node, call.Params)
 		if err != nil {
@@ -218,6 +458,13 @@ func (impl *RPC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		} else {
 			rdr.RenderData(works)
 		}
+	case "getmintforecast":
+		forecast, err := getMintForecast(impl.Node, call.Params)
+		if err != nil {
+			rdr.RenderError(err)
+		} else {
+			rdr.RenderData(forecast)
+		}
 	case "listmintdistributions":
 		distributions, err := listMintDistributions(impl.Store, call.Params)
 		if err != nil {
@@ -232,6 +479,40 @@ func (impl *RPC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		} else {
 			rdr.RenderData(nodes)
 		}
+	case "listchainstats":
+		rdr.RenderData(impl.Node.ChainsStats())
+	case "listconsensusnodes":
+		state, err := listConsensusNodes(impl.Node, call.Params)
+		if err != nil {
+			rdr.RenderError(err)
+		} else {
+			rdr.RenderData(state)
+		}
+	case "getpledgestatus":
+		status, err := getPledgeStatus(impl.Node, call.Params)
+		if err != nil {
+			rdr.RenderError(err)
+		} else {
+			rdr.RenderData(status)
+		}
+	case "getnoderemoval":
+		removal, err := getNodeRemoval(impl.Node, call.Params)
+		if err != nil {
+			rdr.RenderError(err)
+		} else {
+			rdr.RenderData(removal)
+		}
+	case "listelectionwarnings":
+		rdr.RenderData(listElectionWarnings(impl.Node))
+	case "getsyncstatus":
+		rdr.RenderData(getSyncStatus(impl.Node))
+	case "getattestation":
+		attestation, err := getAttestation(impl.Node, call.Params)
+		if err != nil {
+			rdr.RenderError(err)
+		} else {
+			rdr.RenderData(attestation)
+		}
 	case "getroundbynumber":
 		round, err := getRoundByNumber(impl.Node, impl.Store, call.Params)
 		if err != nil {
@@ -246,6 +527,13 @@ func (impl *RPC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		} else {
 			rdr.RenderData(round)
 		}
+	case "gettopologybytimestamp":
+		data, err := getTopologyByTimestamp(impl.Store, call.Params)
+		if err != nil {
+			rdr.RenderError(err)
+		} else {
+			rdr.RenderData(data)
+		}
 	case "getroundlink":
 		link, err := getRoundLink(impl.Store, call.Params)
 		if err != nil {
@@ -253,7 +541,129 @@ func (impl *RPC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		} else {
 			rdr.RenderData(map[string]any{"link": link})
 		}
+	case "buildsweeptransaction":
+		if !admin {
+			rdr.RenderError(errors.New("sweep is only available to localhost"))
+			return
+		}
+		sweep, err := buildSweepTransaction(impl.Store, call.Params)
+		if err != nil {
+			rdr.RenderError(err)
+		} else {
+			rdr.RenderData(sweep)
+		}
+	case "listauditentries":
+		if !admin {
+			rdr.RenderError(errors.New("audit entries are only available to localhost"))
+			return
+		}
+		entries, err := listAuditEntries(impl.Store, call.Params)
+		if err != nil {
+			rdr.RenderError(err)
+		} else {
+			rdr.RenderData(entries)
+		}
+	case "registerwalletaccount":
+		if !admin {
+			rdr.RenderError(errors.New("wallet scanner is only available to localhost"))
+			return
+		}
+		account, err := registerWalletAccount(impl.Store, call.Params)
+		if err != nil {
+			rdr.RenderError(err)
+		} else {
+			rdr.RenderData(account)
+		}
+	case "listwalletoutputs":
+		if !admin {
+			rdr.RenderError(errors.New("wallet scanner is only available to localhost"))
+			return
+		}
+		outputs, err := listWalletOutputs(impl.Store, call.Params)
+		if err != nil {
+			rdr.RenderError(err)
+		} else {
+			rdr.RenderData(outputs)
+		}
+	case "getwalletbalance":
+		if !admin {
+			rdr.RenderError(errors.New("wallet scanner is only available to localhost"))
+			return
+		}
+		balance, err := getWalletBalance(impl.Store, call.Params)
+		if err != nil {
+			rdr.RenderError(err)
+		} else {
+			rdr.RenderData(balance)
+		}
+	case "registerwalletsequence":
+		if !admin {
+			rdr.RenderError(errors.New("wallet scanner is only available to localhost"))
+			return
+		}
+		seq, err := registerWalletSequence(impl.Store, call.Params)
+		if err != nil {
+			rdr.RenderError(err)
+		} else {
+			rdr.RenderData(seq)
+		}
+	case "registerwalletsubaddress":
+		if !admin {
+			rdr.RenderError(errors.New("wallet scanner is only available to localhost"))
+			return
+		}
+		sub, err := registerWalletSubaddress(impl.Store, call.Params)
+		if err != nil {
+			rdr.RenderError(err)
+		} else {
+			rdr.RenderData(sub)
+		}
+	case "getwalletsequence":
+		if !admin {
+			rdr.RenderError(errors.New("wallet scanner is only available to localhost"))
+			return
+		}
+		seq, err := getWalletSequence(impl.Store, call.Params)
+		if err != nil {
+			rdr.RenderError(err)
+		} else {
+			rdr.RenderData(seq)
+		}
+	case "registerwebhook":
+		if !admin {
+			rdr.RenderError(errors.New("webhooks are only available to localhost"))
+			return
+		}
+		hook, err := registerWebhook(impl.Store, call.Params)
+		if err != nil {
+			rdr.RenderError(err)
+		} else {
+			rdr.RenderData(hook)
+		}
+	case "listwebhooks":
+		if !admin {
+			rdr.RenderError(errors.New("webhooks are only available to localhost"))
+			return
+		}
+		hooks, err := listWebhooks(impl.Store)
+		if err != nil {
+			rdr.RenderError(err)
+		} else {
+			rdr.RenderData(hooks)
+		}
+	case "removewebhook":
+		if !admin {
+			rdr.RenderError(errors.New("webhooks are only available to localhost"))
+			return
+		}
+		hook, err := removeWebhook(impl.Store, call.Params)
+		if err != nil {
+			rdr.RenderError(err)
+		} else {
+			rdr.RenderData(hook)
+		}
 	default:
+		method = "unknown"
 		rdr.RenderError(fmt.Errorf("invalid method %s", call.Method))
 	}
 }
@@ -279,12 +689,28 @@ func handleCORS(handler http.Handler) http.Handler {
 	})
 }
 
+// NewServer serves the public RPC, the admin methods are only available to
+// localhost unless the admin listener is configured, then they are only
+// available on the admin listener.
 func NewServer(custom *config.Custom, store storage.Store, node *kernel.Node, port int) *http.Server {
-	rpc := &RPC{Store: store, Node: node, custom: custom}
+	rpc := &RPC{Store: store, Node: node, custom: custom, audit: newAuditor(store), subs: newSubmissions(), token: custom.RPC.Token}
+	go rpc.audit.loop()
+	return newHTTPServer(rpc, config.ListenAddress(custom.RPC.Listen, port))
+}
+
+// NewAdminServer serves all the methods to the clients of the admin listener,
+// which are authorized by the admin token if any.
+func NewAdminServer(custom *config.Custom, store storage.Store, node *kernel.Node) *http.Server {
+	rpc := &RPC{Store: store, Node: node, custom: custom, audit: newAuditor(store), subs: newSubmissions(), admin: true, token: custom.Admin.Token}
+	go rpc.audit.loop()
+	return newHTTPServer(rpc, config.ListenAddress(custom.Admin.Listen, custom.Admin.Port))
+}
+
+func newHTTPServer(rpc *RPC, addr string) *http.Server {
 	handler := handleCORS(rpc)
 
 	server := &http.Server{
-		Addr:         fmt.Sprintf(":%d", port),
+		Addr:         addr,
 		Handler:      handler,
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 10 * time.Second,
@@ -292,3 +718,29 @@ func NewServer(custom *config.Custom, store storage.Store, node *kernel.Node, po
 	}
 	return server
 }
+
+func (impl *RPC) isAdmin(r *http.Request) bool {
+	if impl.admin {
+		return true
+	}
+	if impl.custom.Admin.Port > 0 {
+		return false
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (impl *RPC) authorized(r *http.Request) bool {
+	if impl.token == "" {
+		return true
+	}
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(impl.token)) == 1
+}
@@ -18,6 +18,7 @@ func getInfo(store storage.Store, node *kernel.Node) (map[string]any, error) {
 		"node":      node.IdForNetwork,
 		"version":   config.BuildVersion,
 		"uptime":    node.Uptime().String(),
+		"relayer":   node.Peer.IsRelayer(),
 		"epoch":     time.Unix(0, int64(node.Epoch)),
 		"timestamp": time.Unix(0, int64(node.GraphTimestamp)),
 	}
@@ -79,10 +80,16 @@ func getInfo(store storage.Store, node *kernel.Node) (map[string]any, error) {
 		"tps":       node.TPS(),
 	}
 	caches, finals, state := node.QueueState()
+	interned, bytes, hits := node.InternState()
 	info["queue"] = map[string]any{
 		"finals": finals,
 		"caches": caches,
 		"state":  state,
+		"interned": map[string]any{
+			"transactions": interned,
+			"bytes":        bytes,
+			"hits":         hits,
+		},
 	}
 	info["metric"] = map[string]any{
 		"transport": node.Peer.Metric(),
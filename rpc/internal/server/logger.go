@@ -0,0 +1,41 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/MixinNetwork/mixin/logger"
+)
+
+func listLogLevels() map[string]any {
+	return map[string]any{
+		"format": logger.Format(),
+		"levels": logger.Levels(),
+	}
+}
+
+// setLogLevel changes the level of a module at runtime, or all of them with
+// the module all, e.g. ["p2p", 7] to debug the p2p module only.
+func setLogLevel(params []any) (map[string]any, error) {
+	if len(params) != 2 {
+		return nil, errors.New("invalid params count")
+	}
+	level, err := strconv.ParseUint(fmt.Sprint(params[1]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	if level > logger.DEBUG {
+		return nil, fmt.Errorf("invalid log level %d", level)
+	}
+	switch module := fmt.Sprint(params[0]); module {
+	case "all":
+		logger.SetLevel(int(level))
+	default:
+		err = logger.SetModuleLevel(module, int(level))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return listLogLevels(), nil
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/mixin/metrics"
+)
+
+const prometheusTextType = "text/plain; version=0.0.4; charset=utf-8"
+
+const (
+	callErrorForbidden = "forbidden"
+	callErrorInvalid   = "invalid"
+	callErrorNotFound  = "notfound"
+	callErrorServer    = "server"
+	callErrorOther     = "other"
+)
+
+var (
+	callDurationHistogram = metrics.NewHistogram("mixin_rpc_call_duration_seconds",
+		"The duration of each RPC call from the request decoded to the response written.",
+		"method", []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10})
+
+	callRequestHistogram = metrics.NewHistogram("mixin_rpc_call_request_bytes",
+		"The request body size of each RPC call.",
+		"method", []float64{64, 256, 1024, 4096, 16384, 65536, 262144, 1048576})
+
+	callResponseHistogram = metrics.NewHistogram("mixin_rpc_call_response_bytes",
+		"The response body size of each RPC call.",
+		"method", []float64{64, 256, 1024, 4096, 16384, 65536, 262144, 1048576, 4194304})
+
+	callErrorCounter = metrics.NewCounter("mixin_rpc_call_errors_total",
+		"The RPC calls responded with an error, by the method and the error class.",
+		"method", "class")
+)
+
+func (impl *RPC) handleMetrics(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", prometheusTextType)
+	w.WriteHeader(http.StatusOK)
+	err := metrics.WritePrometheus(w)
+	if err != nil {
+		logger.Verbosef("metrics.WritePrometheus() => %v\n", err)
+	}
+}
+
+// the methods not served are all observed as the unknown method, so the
+// series of the metrics are bounded by the methods of the server
+func observeCall(method string, start time.Time, request int, rdr *Render) {
+	callDurationHistogram.Observe(method, time.Since(start).Seconds())
+	callRequestHistogram.Observe(method, float64(request))
+	callResponseHistogram.Observe(method, float64(rdr.size))
+	if rdr.err != nil {
+		callErrorCounter.Add(1, method, classifyCallError(rdr.err))
+	}
+}
+
+// the validation errors have the codes, and the other errors are classified
+// by the messages, then the errors of the kernel or the store are all the
+// other class
+func classifyCallError(err error) string {
+	msg := err.Error()
+	switch {
+	case common.ErrorCodeOf(err) != "":
+		return callErrorInvalid
+	case msg == "server error":
+		return callErrorServer
+	case strings.HasSuffix(msg, "only available to localhost"):
+		return callErrorForbidden
+	case strings.Contains(msg, "not found"):
+		return callErrorNotFound
+	case strings.HasPrefix(msg, "invalid"), strings.HasPrefix(msg, "bad request"):
+		return callErrorInvalid
+	}
+	return callErrorOther
+}
+
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCallMetrics(t *testing.T) {
+	require := require.New(t)
+
+	custom := &config.Custom{}
+	custom.RPC.Metrics = true
+	store := &auditTestStore{}
+	impl := &RPC{Store: store, custom: custom, audit: newAuditor(store)}
+
+	call := func(remote, body string) string {
+		r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		r.RemoteAddr = remote
+		w := httptest.NewRecorder()
+		impl.ServeHTTP(w, r)
+		require.Equal(http.StatusOK, w.Code)
+		return w.Body.String()
+	}
+	require.Contains(call("10.0.0.1:51000", `{"method":"gettransaction","params":[]}`), "invalid params count")
+	require.Contains(call("10.0.0.1:51000", `{"method":"gettransaction","params":["f00d"]}`), "error")
+	require.Contains(call("10.0.0.1:51000", `{"method":"listwebhooks","params":[]}`), "localhost")
+	require.Contains(call("10.0.0.1:51000", `{"method":"dumpgraphhead","params":[]}`), "server error")
+	require.Contains(call("10.0.0.1:51000", `{"method":"mixin","params":[]}`), "invalid method")
+	require.Contains(call("10.0.0.1:51000", `{"method":"mixin"`), "bad request")
+	require.Contains(call("10.0.0.1:51000", `{"method":"sendrawtransaction","params":["777700"]}`), `"code":"invalid_format"`)
+
+	r := httptest.NewRequest("GET", "/metrics", nil)
+	w := httptest.NewRecorder()
+	impl.ServeHTTP(w, r)
+	out := w.Body.String()
+	require.Contains(out, `mixin_rpc_call_duration_seconds_count{method="gettransaction"} 2`)
+	require.Contains(out, `mixin_rpc_call_request_bytes_sum{method="gettransaction"} 84`)
+	require.Contains(out, `mixin_rpc_call_response_bytes_count{method="unknown"} 1`)
+	require.Contains(out, `mixin_rpc_call_errors_total{method="dumpgraphhead",class="server"} 1`)
+	require.Contains(out, `mixin_rpc_call_errors_total{method="gettransaction",class="invalid"} 2`)
+	require.Contains(out, `mixin_rpc_call_errors_total{method="listwebhooks",class="forbidden"} 1`)
+	require.Contains(out, `mixin_rpc_call_errors_total{method="unknown",class="invalid"} 1`)
+	require.Contains(out, `mixin_rpc_call_errors_total{method="sendrawtransaction",class="invalid"} 1`)
+	require.NotContains(out, `method="mixin"`)
+
+	require.Equal("notfound", classifyCallError(errorString("round not found")))
+	require.Equal("other", classifyCallError(errorString("DB Closed")))
+}
+
+type errorString string
+
+func (e errorString) Error() string {
+	return string(e)
+}
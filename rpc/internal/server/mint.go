@@ -30,6 +30,36 @@ func listMintWorks(node *kernel.Node, params []any) (map[string]any, error) {
 	return wm, nil
 }
 
+func getMintForecast(node *kernel.Node, params []any) (map[string]any, error) {
+	if len(params) != 0 {
+		return nil, errors.New("invalid params count")
+	}
+	f, err := node.MintForecast(node.GraphTimestamp)
+	if err != nil {
+		return nil, err
+	}
+	nodes := make([]map[string]any, len(f.Nodes))
+	for i, n := range f.Nodes {
+		nodes[i] = map[string]any{
+			"node":  n.Id,
+			"payee": n.Payee.String(),
+			"works": n.Works,
+			"share": n.Share.String(),
+		}
+	}
+	return map[string]any{
+		"batch":     f.Batch,
+		"timestamp": f.Timestamp,
+		"pool":      f.Pool.String(),
+		"amount":    f.Amount.String(),
+		"kernel":    f.Kernel.String(),
+		"custodian": f.Custodian.String(),
+		"light":     f.Light.String(),
+		"nodes":     nodes,
+		"error":     f.Error,
+	}, nil
+}
+
 func listMintDistributions(store storage.Store, params []any) ([]map[string]any, error) {
 	if len(params) != 3 {
 		return nil, errors.New("invalid params count")
@@ -1,12 +1,15 @@
 package server
 
 import (
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"math"
 	"sort"
 	"strconv"
 	"time"
 
+	"github.com/MixinNetwork/mixin/common"
 	"github.com/MixinNetwork/mixin/kernel"
 	"github.com/MixinNetwork/mixin/p2p"
 	"github.com/MixinNetwork/mixin/storage"
@@ -43,6 +46,284 @@ func listAllNodes(store storage.Store, node *kernel.Node, params []any) ([]map[s
 	return result, nil
 }
 
+func listConsensusNodes(node *kernel.Node, params []any) (map[string]any, error) {
+	if len(params) != 1 {
+		return nil, errors.New("invalid params count")
+	}
+	timestamp, err := strconv.ParseUint(fmt.Sprint(params[0]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	if timestamp == 0 {
+		timestamp = uint64(time.Now().UnixNano())
+	}
+	state := node.ConsensusNodesAt(timestamp)
+	nodes := make([]map[string]any, len(state.Nodes))
+	for i, n := range state.Nodes {
+		item := map[string]any{
+			"id":              n.IdForNetwork,
+			"signer":          n.Signer,
+			"payee":           n.Payee,
+			"transaction":     n.Transaction,
+			"timestamp":       n.Timestamp,
+			"state":           n.State,
+			"consensus_index": n.ConsensusIndex,
+			"genesis":         n.Genesis,
+			"ready":           n.Ready,
+			"counted":         n.Counted,
+		}
+		if !n.Ready && n.ReadyAt > 0 {
+			item["ready_in"] = n.ReadyAt - timestamp + 1
+		}
+		if !n.Counted && n.CountedAt != math.MaxUint64 {
+			item["counted_in"] = n.CountedAt - timestamp + 1
+		}
+		if n.FinalCountedAt >= timestamp && n.FinalCountedAt != math.MaxUint64 {
+			item["final_counted_in"] = n.FinalCountedAt - timestamp + 1
+		}
+		nodes[i] = item
+	}
+
+	slot := state.Pledging
+	pledging := map[string]any{
+		"available":   slot.Available,
+		"accept_hour": slot.AcceptHour,
+	}
+	if slot.Node != nil {
+		pledging["node"] = slot.Node.IdForNetwork
+		pledging["signer"] = slot.Node.Signer
+		pledging["timestamp"] = slot.Node.Timestamp
+		pledging["accept_after"] = slot.AcceptAfter
+		pledging["accept_before"] = slot.AcceptBefore
+		if slot.AcceptAfter > timestamp {
+			pledging["accept_in"] = slot.AcceptAfter - timestamp
+		}
+	} else {
+		pledging["next_pledge_at"] = slot.NextPledgeAt
+		if slot.NextPledgeAt > timestamp {
+			pledging["next_pledge_in"] = slot.NextPledgeAt - timestamp
+		}
+	}
+	return map[string]any{
+		"timestamp":       timestamp,
+		"threshold":       state.Threshold,
+		"final_threshold": state.FinalThreshold,
+		"nodes":           nodes,
+		"pledging":        pledging,
+	}, nil
+}
+
+func getPledgeStatus(node *kernel.Node, params []any) (map[string]any, error) {
+	if len(params) != 2 {
+		return nil, errors.New("invalid params count")
+	}
+	signer, err := common.NewAddressFromString(fmt.Sprint(params[0]))
+	if err != nil {
+		return nil, err
+	}
+	timestamp, err := strconv.ParseUint(fmt.Sprint(params[1]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	if timestamp == 0 {
+		timestamp = uint64(time.Now().UnixNano())
+	}
+	ps := node.PledgeStatusAt(signer.PublicSpendKey, timestamp)
+	status := map[string]any{
+		"timestamp": timestamp,
+		"signer":    signer.String(),
+		"phase":     ps.Phase,
+	}
+	if ps.NextAt > 0 {
+		status["next_at"] = ps.NextAt
+		status["next_in"] = ps.NextAt - timestamp
+	}
+	if n := ps.Node; n != nil {
+		status["node"] = map[string]any{
+			"id":          n.IdForNetwork,
+			"payee":       n.Payee,
+			"transaction": n.Transaction,
+			"timestamp":   n.Timestamp,
+			"state":       n.State,
+		}
+	}
+	slot := ps.Slot
+	switch ps.Phase {
+	case kernel.PledgePhaseWaiting:
+		if slot.Node != nil {
+			status["pledging"] = slot.Node.Signer.String()
+		}
+	case kernel.PledgePhasePledging, kernel.PledgePhaseAccepting:
+		status["accept_after"] = slot.AcceptAfter
+		status["accept_before"] = slot.AcceptBefore
+		status["accept_hour"] = slot.AcceptHour
+		status["accept_hour_at"] = node.AcceptHourAt(max(timestamp, slot.AcceptAfter))
+	}
+	return status, nil
+}
+
+func getNodeRemoval(node *kernel.Node, params []any) (map[string]any, error) {
+	if len(params) != 2 {
+		return nil, errors.New("invalid params count")
+	}
+	signer, err := common.NewAddressFromString(fmt.Sprint(params[0]))
+	if err != nil {
+		return nil, err
+	}
+	timestamp, err := strconv.ParseUint(fmt.Sprint(params[1]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	if timestamp == 0 {
+		timestamp = uint64(time.Now().UnixNano())
+	}
+	nr, err := node.NodeRemovalAt(signer.PublicSpendKey, timestamp)
+	if err != nil {
+		return nil, err
+	}
+	removal := map[string]any{
+		"timestamp": timestamp,
+		"signer":    signer.String(),
+		"phase":     nr.Phase,
+	}
+	if n := nr.Node; n != nil {
+		removal["node"] = map[string]any{
+			"id":          n.IdForNetwork,
+			"payee":       n.Payee,
+			"transaction": n.Transaction,
+			"timestamp":   n.Timestamp,
+			"state":       n.State,
+		}
+	}
+	switch nr.Phase {
+	case kernel.RemovalPhaseQueued:
+		removal["position"] = nr.Position
+	case kernel.RemovalPhaseNext:
+		removal["position"] = nr.Position
+		removal["remove_at"] = nr.RemoveAt
+		removal["remove_in"] = nr.RemoveAt - timestamp
+		if nr.Blocked != "" {
+			removal["blocked"] = nr.Blocked
+		}
+	}
+	if tx := nr.Transaction; tx != nil {
+		removal["transaction"] = map[string]any{
+			"hash":      tx.PayloadHash(),
+			"raw":       hex.EncodeToString(tx.Marshal()),
+			"finalized": nr.Finalized,
+		}
+	}
+	if r := nr.Refund; r != nil {
+		refund := map[string]any{
+			"hash":      r.Hash,
+			"index":     r.Index,
+			"amount":    r.Amount,
+			"spendable": !r.LockHash.HasValue(),
+		}
+		if r.LockHash.HasValue() {
+			refund["lock"] = r.LockHash
+		}
+		removal["refund"] = refund
+	}
+	return removal, nil
+}
+
+func listElectionWarnings(node *kernel.Node) []map[string]any {
+	warnings := node.ElectionWarnings()
+	result := make([]map[string]any, len(warnings))
+	for i, w := range warnings {
+		item := map[string]any{
+			"kind":      w.KindName(),
+			"node":      w.Node,
+			"timestamp": w.Timestamp,
+			"reporter":  w.Reporter,
+			"signature": w.Signature,
+			"raw":       hex.EncodeToString(w.Marshal()),
+		}
+		if w.Kind == kernel.ElectionAnomalyRemoveOrder {
+			item["expected"] = w.Expected
+		}
+		result[i] = item
+	}
+	return result
+}
+
+func getSyncStatus(node *kernel.Node) map[string]any {
+	status := node.SyncStatus()
+	chains := make([]map[string]any, len(status.Chains))
+	for i, c := range status.Chains {
+		chains[i] = map[string]any{
+			"chain":  c.ChainId,
+			"local":  c.Local,
+			"remote": c.Remote,
+			"lag":    int64(c.Remote) - int64(c.Local),
+		}
+	}
+	return map[string]any{
+		"timestamp":   status.Timestamp,
+		"synced":      status.Synced,
+		"stalled":     status.Stalled,
+		"progress":    status.Progress,
+		"local":       status.Local,
+		"remote":      status.Remote,
+		"rate":        status.Rate,
+		"eta":         time.Duration(status.ETA).String(),
+		"progress_at": status.ProgressAt,
+		"chains":      chains,
+	}
+}
+
+// getAttestation signs a fresh statement with the hex nonce of the caller, the
+// nonce is required so the statement can't be replayed by a stale replica.
+func getAttestation(node *kernel.Node, params []any) (map[string]any, error) {
+	if len(params) != 1 {
+		return nil, errors.New("invalid params count")
+	}
+	nonce, err := hex.DecodeString(fmt.Sprint(params[0]))
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) == 0 {
+		return nil, errors.New("empty attestation nonce")
+	}
+	a, err := node.Attest(nonce)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{
+		"network":   a.NetworkId,
+		"node":      a.NodeId,
+		"signer":    a.Signer,
+		"round":     a.Round,
+		"hash":      a.RoundHash,
+		"timestamp": a.Timestamp,
+		"nonce":     hex.EncodeToString(a.Nonce),
+		"signature": a.Signature,
+	}, nil
+}
+
+func setNodeRole(node *kernel.Node, params []any) (map[string]any, error) {
+	if len(params) != 1 {
+		return nil, errors.New("invalid params count")
+	}
+	var relayer bool
+	switch role := fmt.Sprint(params[0]); role {
+	case "relayer":
+		relayer = true
+	case "consumer":
+	default:
+		return nil, fmt.Errorf("invalid node role %s", role)
+	}
+	err := node.SetRelayer(relayer)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{
+		"node":    node.IdForNetwork,
+		"relayer": node.Peer.IsRelayer(),
+	}, nil
+}
+
 func peerNeighbors(peers []*p2p.Peer) []map[string]any {
 	sort.Slice(peers, func(i, j int) bool { return peers[i].IdForNetwork.String() < peers[j].IdForNetwork.String() })
 	data := make([]map[string]any, 0)
@@ -0,0 +1,27 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// the readiness is for the load balancers and the orchestrators, so the
+// status code is 503 when the node is not ready, unlike the RPC calls.
+func (impl *RPC) handleReady(w http.ResponseWriter) {
+	partition := impl.Node.PartitionState()
+	body := map[string]any{
+		"ready":     !partition.Partitioned,
+		"partition": partition,
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		panic(err)
+	}
+	w.Header().Set("Content-Type", defaultJSONType)
+	if body["ready"] == true {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	w.Write(b)
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/mixin/replica"
+)
+
+// the replicas load the store of the primary without the kernel running, and
+// the node state is only reloaded from the store after each pull, so only the
+// methods reading the store or the reloaded state are available on a replica,
+// all the others, writing to the store or depending on the kernel or the peers,
+// are only available on the primary.
+var replicaReadMethods = map[string]bool{
+	"getinfo":                     true,
+	"listloglevels":               true,
+	"dumpgraphhead":               true,
+	"gettransaction":              true,
+	"gettransactions":             true,
+	"getcachetransaction":         true,
+	"getdeposittransaction":       true,
+	"getwithdrawalclaim":          true,
+	"listwithdrawalclaims":        true,
+	"getutxo":                     true,
+	"verifyoutputownership":       true,
+	"getkey":                      true,
+	"getasset":                    true,
+	"getsnapshot":                 true,
+	"getfinalityproof":            true,
+	"listsnapshots":               true,
+	"listviewsnapshots":           true,
+	"listcustodianupdates":        true,
+	"getcustodian":                true,
+	"listpendingcustodianupdates": true,
+	"listassetflows":              true,
+	"listcustodianbalances":       true,
+	"listcustodianproposals":      true,
+	"listmintworks":               true,
+	"getmintforecast":             true,
+	"listmintdistributions":       true,
+	"listallnodes":                true,
+	"listconsensusnodes":          true,
+	"getpledgestatus":             true,
+	"getnoderemoval":              true,
+	"getsyncstatus":               true,
+	"getroundbynumber":            true,
+	"getroundbyhash":              true,
+	"gettopologybytimestamp":      true,
+	"getroundlink":                true,
+	"listauditentries":            true,
+	"listwalletoutputs":           true,
+	"getwalletbalance":            true,
+	"getwalletsequence":           true,
+	"listwebhooks":                true,
+}
+
+func (impl *RPC) replica() bool {
+	return impl.custom.Replica.Primary != ""
+}
+
+// handleReplication streams the writes to the store since the version in the
+// query to a replica, which is only available to the admin clients, because
+// all the store is readable by the replica.
+func (impl *RPC) handleReplication(w http.ResponseWriter, r *http.Request, rdr *Render) {
+	if !impl.isAdmin(r) {
+		rdr.RenderError(errors.New("replication is only available to localhost"))
+		return
+	}
+	since, err := strconv.ParseUint(r.URL.Query().Get("since"), 10, 64)
+	if err != nil {
+		rdr.RenderError(fmt.Errorf("bad request %s", err.Error()))
+		return
+	}
+	impl.audit.call(r.RemoteAddr, "replication", true)
+
+	// the first pull of a replica is the whole store
+	err = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	if err != nil {
+		rdr.RenderError(err)
+		return
+	}
+	// the trailer is only sent after all the entries are written, so the
+	// replica never takes a broken stream as the end
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Trailer", replica.DoneTrailer)
+	w.WriteHeader(http.StatusOK)
+	err = impl.Store.WriteReplication(w, since)
+	if err != nil {
+		logger.Printf("handleReplication(%s, %d) => %v\n", r.RemoteAddr, since, err)
+		return
+	}
+	w.Header().Set(replica.DoneTrailer, "true")
+}
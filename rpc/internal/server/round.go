@@ -72,6 +72,10 @@ func getRoundByNumber(kn *kernel.Node, store storage.Store, params []any) (map[s
 	} else {
 		return nil, fmt.Errorf("round not found")
 	}
+	items, err := snapshotsToMap(kn, snapshots, nil, false)
+	if err != nil {
+		return nil, err
+	}
 	return map[string]any{
 		"node":       node,
 		"hash":       hash,
@@ -79,7 +83,7 @@ func getRoundByNumber(kn *kernel.Node, store storage.Store, params []any) (map[s
 		"end":        end,
 		"number":     number,
 		"references": roundLinkToMap(references),
-		"snapshots":  snapshotsToMap(kn, snapshots, nil, false),
+		"snapshots":  items,
 	}, nil
 }
 
@@ -119,6 +123,10 @@ func getRoundByHash(kn *kernel.Node, store storage.Store, params []any) (map[str
 	} else {
 		return nil, fmt.Errorf("round malformed %s:%d", round.NodeId, round.Number)
 	}
+	items, err := snapshotsToMap(kn, snapshots, nil, false)
+	if err != nil {
+		return nil, err
+	}
 	return map[string]any{
 		"node":       round.NodeId,
 		"hash":       hash,
@@ -126,7 +134,7 @@ func getRoundByHash(kn *kernel.Node, store storage.Store, params []any) (map[str
 		"end":        end,
 		"number":     round.Number,
 		"references": roundLinkToMap(round.References),
-		"snapshots":  snapshotsToMap(kn, snapshots, nil, false),
+		"snapshots":  items,
 	}, nil
 }
 
@@ -139,3 +147,29 @@ func roundLinkToMap(r *common.RoundLink) map[string]any {
 		"external": r.External.String(),
 	}
 }
+
+func getTopologyByTimestamp(store storage.Store, params []any) (map[string]any, error) {
+	if len(params) != 1 {
+		return nil, errors.New("invalid params count")
+	}
+	ts, err := strconv.ParseUint(fmt.Sprint(params[0]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	topology, rounds, err := store.ReadTopologyByTimestamp(ts)
+	if err != nil {
+		return nil, err
+	}
+	chains := make([]map[string]any, 0)
+	for id, number := range rounds {
+		chains = append(chains, map[string]any{
+			"node":  id,
+			"round": number,
+		})
+	}
+	return map[string]any{
+		"timestamp": ts,
+		"topology":  topology,
+		"chains":    chains,
+	}, nil
+}
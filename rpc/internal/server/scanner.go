@@ -0,0 +1,199 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/storage"
+)
+
+func registerWalletAccount(store storage.Store, params []any) (map[string]any, error) {
+	if len(params) != 2 {
+		return nil, errors.New("invalid params count")
+	}
+	account, err := common.NewAddressFromString(fmt.Sprint(params[0]))
+	if err != nil {
+		return nil, err
+	}
+	view, err := crypto.KeyFromString(fmt.Sprint(params[1]))
+	if err != nil {
+		return nil, err
+	}
+	if view.Public() != account.PublicViewKey {
+		return nil, fmt.Errorf("invalid view key for address %s", account)
+	}
+	account.PrivateViewKey = view
+	err = store.RegisterWalletAccount(&account)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"address": account.String()}, nil
+}
+
+func listWalletOutputs(store storage.Store, params []any) ([]map[string]any, error) {
+	if len(params) != 3 {
+		return nil, errors.New("invalid params count")
+	}
+	account, err := common.NewAddressFromString(fmt.Sprint(params[0]))
+	if err != nil {
+		return nil, err
+	}
+	var asset crypto.Hash
+	if a := fmt.Sprint(params[1]); a != "" {
+		asset, err = crypto.HashFromString(a)
+		if err != nil {
+			return nil, err
+		}
+	}
+	spent, err := strconv.ParseBool(fmt.Sprint(params[2]))
+	if err != nil {
+		return nil, err
+	}
+	outputs, err := store.ListWalletOutputs(account.PublicSpendKey, asset, spent)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]map[string]any, len(outputs))
+	for i, o := range outputs {
+		result[i] = walletOutputToMap(o)
+	}
+	return result, nil
+}
+
+func getWalletBalance(store storage.Store, params []any) (map[string]any, error) {
+	if len(params) != 1 {
+		return nil, errors.New("invalid params count")
+	}
+	account, err := common.NewAddressFromString(fmt.Sprint(params[0]))
+	if err != nil {
+		return nil, err
+	}
+	outputs, err := store.ListWalletOutputs(account.PublicSpendKey, crypto.Hash{}, false)
+	if err != nil {
+		return nil, err
+	}
+	balances := make(map[string]common.Integer)
+	for _, o := range outputs {
+		a := o.Asset.String()
+		balances[a] = balances[a].Add(o.Amount)
+	}
+	result := make(map[string]any)
+	for a, b := range balances {
+		result[a] = b.String()
+	}
+	return map[string]any{
+		"address":  account.String(),
+		"balances": result,
+		"outputs":  len(outputs),
+	}, nil
+}
+
+func registerWalletSequence(store storage.Store, params []any) (map[string]any, error) {
+	if len(params) != 3 {
+		return nil, errors.New("invalid params count")
+	}
+	master, err := common.NewAddressFromString(fmt.Sprint(params[0]))
+	if err != nil {
+		return nil, err
+	}
+	view, err := crypto.KeyFromString(fmt.Sprint(params[1]))
+	if err != nil {
+		return nil, err
+	}
+	if view.Public() != master.PublicViewKey {
+		return nil, fmt.Errorf("invalid view key for address %s", master)
+	}
+	gap, err := strconv.ParseUint(fmt.Sprint(params[2]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	master.PrivateViewKey = view
+	seq, err := store.RegisterWalletSequence(&master, gap)
+	if err != nil {
+		return nil, err
+	}
+	return walletSequenceToMap(seq), nil
+}
+
+func getWalletSequence(store storage.Store, params []any) (map[string]any, error) {
+	if len(params) != 1 {
+		return nil, errors.New("invalid params count")
+	}
+	master, err := common.NewAddressFromString(fmt.Sprint(params[0]))
+	if err != nil {
+		return nil, err
+	}
+	seq, err := store.ReadWalletSequence(master.PublicSpendKey)
+	if err != nil || seq == nil {
+		return nil, err
+	}
+	return walletSequenceToMap(seq), nil
+}
+
+func registerWalletSubaddress(store storage.Store, params []any) (map[string]any, error) {
+	if len(params) != 3 {
+		return nil, errors.New("invalid params count")
+	}
+	master, err := common.NewAddressFromString(fmt.Sprint(params[0]))
+	if err != nil {
+		return nil, err
+	}
+	view, err := crypto.KeyFromString(fmt.Sprint(params[1]))
+	if err != nil {
+		return nil, err
+	}
+	if view.Public() != master.PublicViewKey {
+		return nil, fmt.Errorf("invalid view key for address %s", master)
+	}
+	paymentId := fmt.Sprint(params[2])
+	master.PrivateViewKey = view
+	sub, err := store.RegisterWalletSubaddress(&master, paymentId)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{
+		"master":     master.String(),
+		"payment_id": paymentId,
+		"address":    sub.String(),
+	}, nil
+}
+
+// the addresses include all the used ones and the unused ones within the gap
+func walletSequenceToMap(seq *storage.WalletSequence) map[string]any {
+	addresses := make([]map[string]any, seq.Next+seq.Gap)
+	for i := range addresses {
+		addr := seq.Master.DeriveSequenceAddress(uint64(i))
+		addresses[i] = map[string]any{
+			"sequence": i,
+			"address":  addr.String(),
+		}
+	}
+	return map[string]any{
+		"master":    seq.Master.String(),
+		"gap":       seq.Gap,
+		"next":      seq.Next,
+		"addresses": addresses,
+	}
+}
+
+func walletOutputToMap(o *storage.WalletOutput) map[string]any {
+	output := map[string]any{
+		"hash":      o.Hash,
+		"index":     o.Index,
+		"key_index": o.KeyIndex,
+		"asset":     o.Asset,
+		"amount":    o.Amount,
+		"snapshot":  o.Snapshot,
+		"timestamp": o.Timestamp,
+	}
+	if o.SpentBy.HasValue() {
+		output["spent_by"] = o.SpentBy
+	}
+	if o.PaymentId != "" {
+		output["payment_id"] = o.PaymentId
+	}
+	return output
+}
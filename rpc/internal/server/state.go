@@ -0,0 +1,66 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/MixinNetwork/mixin/kernel"
+	"github.com/MixinNetwork/mixin/storage"
+)
+
+const stateCheckpointsLimit = 100
+
+func listStateCheckpoints(node *kernel.Node, store storage.Store, params []any) (map[string]any, error) {
+	if len(params) != 1 {
+		return nil, errors.New("invalid params count")
+	}
+	limit, err := strconv.ParseUint(fmt.Sprint(params[0]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	if limit == 0 || limit > stateCheckpointsLimit {
+		limit = stateCheckpointsLimit
+	}
+	since, found, err := store.ReadStateIndexSince()
+	if err != nil {
+		return nil, err
+	}
+	checkpoints, err := store.ListStateCheckpoints(int(limit))
+	if err != nil {
+		return nil, err
+	}
+	result := make([]map[string]any, len(checkpoints))
+	for i, cp := range checkpoints {
+		commitment := cp.Commitment()
+		var attestations []map[string]any
+		for _, c := range node.StateCommitments(cp.Number) {
+			attestations = append(attestations, map[string]any{
+				"node":       c.Reporter,
+				"commitment": c.Commitment,
+				"matched":    c.Commitment == commitment,
+			})
+		}
+		result[i] = map[string]any{
+			"number":       cp.Number,
+			"timestamp":    cp.Timestamp(),
+			"commitment":   commitment,
+			"outputs":      cp.Outputs.Checksum(),
+			"transactions": cp.Transactions,
+			"mint": map[string]any{
+				"batch":       cp.MintBatch,
+				"transaction": cp.MintTransaction,
+			},
+			"nodes":        cp.Nodes,
+			"attestations": attestations,
+		}
+	}
+	data := map[string]any{
+		"complete":    found && since == 0,
+		"checkpoints": result,
+	}
+	if found {
+		data["since"] = since
+	}
+	return data, nil
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+// the recent submissions are kept for the retry loops of the clients, the
+// same transaction or idempotency key submitted again in this period has the
+// original result, without being validated and queued again
+const (
+	submissionTTL     = 10 * time.Minute
+	submissionLimit   = 100000
+	idempotencyKeyMax = 64
+)
+
+// the errors may be resolved by a later submission, e.g. the input output
+// finalized, so they are never kept
+var transientSubmissionErrors = map[common.ErrorCode]bool{
+	common.ErrorInputNotFound:       true,
+	common.ErrorReferenceNotFound:   true,
+	common.ErrorInvalidDepositProof: true,
+	common.ErrorQueueFull:           true,
+}
+
+type submission struct {
+	hash      crypto.Hash
+	err       error
+	timestamp time.Time
+}
+
+type submissions struct {
+	sync.Mutex
+	txs  map[crypto.Hash]*submission
+	keys map[string]*submission
+}
+
+func newSubmissions() *submissions {
+	return &submissions{
+		txs:  make(map[crypto.Hash]*submission),
+		keys: make(map[string]*submission),
+	}
+}
+
+func (s *submission) result() (string, error) {
+	if s.err != nil {
+		return "", s.err
+	}
+	return s.hash.String(), nil
+}
+
+// get returns the recent submission of the transaction, or of the key, which
+// must be the submission of the same transaction.
+func (subs *submissions) get(hash crypto.Hash, key string, now time.Time) (*submission, error) {
+	subs.Lock()
+	defer subs.Unlock()
+
+	if s := subs.keys[key]; key != "" && s != nil && now.Sub(s.timestamp) < submissionTTL {
+		if s.hash != hash {
+			return nil, fmt.Errorf("idempotency key %s used by transaction %s", key, s.hash)
+		}
+		return s, nil
+	}
+	s := subs.txs[hash]
+	if s == nil || now.Sub(s.timestamp) >= submissionTTL {
+		return nil, nil
+	}
+	if key != "" {
+		subs.keys[key] = s
+	}
+	return s, nil
+}
+
+// put keeps the result unless it is a transient error, or there are too many
+// recent submissions.
+func (subs *submissions) put(hash crypto.Hash, key string, err error, now time.Time) {
+	if err != nil && (common.ErrorCodeOf(err) == "" || transientSubmissionErrors[common.ErrorCodeOf(err)]) {
+		return
+	}
+
+	subs.Lock()
+	defer subs.Unlock()
+
+	if len(subs.txs)+len(subs.keys) >= submissionLimit {
+		subs.evict(now)
+	}
+	if len(subs.txs)+len(subs.keys) >= submissionLimit {
+		return
+	}
+	s := &submission{hash: hash, err: err, timestamp: now}
+	subs.txs[hash] = s
+	if key != "" {
+		subs.keys[key] = s
+	}
+}
+
+func (subs *submissions) evict(now time.Time) {
+	for h, s := range subs.txs {
+		if now.Sub(s.timestamp) >= submissionTTL {
+			delete(subs.txs, h)
+		}
+	}
+	for k, s := range subs.keys {
+		if now.Sub(s.timestamp) >= submissionTTL {
+			delete(subs.keys, k)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubmissions(t *testing.T) {
+	require := require.New(t)
+
+	subs := newSubmissions()
+	now := time.Now()
+	tx := crypto.Blake3Hash([]byte("tx"))
+	other := crypto.Blake3Hash([]byte("other"))
+
+	s, err := subs.get(tx, "order-1", now)
+	require.Nil(err)
+	require.Nil(s)
+	subs.put(tx, "order-1", nil, now)
+
+	s, err = subs.get(tx, "", now.Add(time.Minute))
+	require.Nil(err)
+	id, err := s.result()
+	require.Nil(err)
+	require.Equal(tx.String(), id)
+	s, err = subs.get(tx, "order-2", now.Add(time.Minute))
+	require.Nil(err)
+	require.NotNil(s)
+	_, err = subs.get(other, "order-2", now.Add(time.Minute))
+	require.ErrorContains(err, "idempotency key order-2 used by transaction "+tx.String())
+	_, err = subs.get(other, "order-1", now.Add(time.Minute))
+	require.ErrorContains(err, "idempotency key order-1 used by transaction")
+	s, err = subs.get(other, "order-1", now.Add(submissionTTL))
+	require.Nil(err)
+	require.Nil(s)
+
+	subs.put(other, "", common.Errorf(common.ErrorInputNotFound, "input not found"), now)
+	subs.put(other, "", errors.New("leveldb closed"), now)
+	s, err = subs.get(other, "", now)
+	require.Nil(err)
+	require.Nil(s)
+	subs.put(other, "", common.Errorf(common.ErrorInputLocked, "input locked"), now)
+	s, err = subs.get(other, "", now)
+	require.Nil(err)
+	_, err = s.result()
+	require.Equal(common.ErrorInputLocked, common.ErrorCodeOf(err))
+
+	subs.evict(now.Add(submissionTTL))
+	require.Len(subs.txs, 0)
+	require.Len(subs.keys, 0)
+}
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/MixinNetwork/mixin/common"
 	"github.com/MixinNetwork/mixin/crypto"
@@ -29,19 +31,95 @@ func getCacheTransaction(store storage.Store, params []any) (map[string]any, err
 	return data, nil
 }
 
-func queueTransaction(node *kernel.Node, params []any) (string, error) {
+// getCacheDependencies lists the pending ancestors of the cache transaction,
+// which is held in the cache and not queued to any chain until the parents
+// in the first entry are finalized.
+func getCacheDependencies(node *kernel.Node, store storage.Store, params []any) (map[string]any, error) {
 	if len(params) != 1 {
+		return nil, errors.New("invalid params count")
+	}
+	hash, err := crypto.HashFromString(fmt.Sprint(params[0]))
+	if err != nil {
+		return nil, err
+	}
+	tx, err := store.CacheGetTransaction(hash)
+	if err != nil || tx == nil {
+		return nil, err
+	}
+	deps, err := node.CacheDependencies(tx)
+	if err != nil {
+		return nil, err
+	}
+	chain := make([]map[string]any, len(deps))
+	for i, d := range deps {
+		parents := append([]crypto.Hash{}, d.Parents...)
+		chain[i] = map[string]any{"hash": d.Hash, "parents": parents}
+	}
+	return map[string]any{
+		"hash":         hash,
+		"held":         len(deps[0].Parents) > 0,
+		"dependencies": chain,
+	}, nil
+}
+
+// purgeCacheTransactions has the age in seconds and the hex prefix of the
+// hashes, at least one of them is required, so the whole cache is never
+// purged by mistake.
+func purgeCacheTransactions(node *kernel.Node, params []any) (map[string]any, error) {
+	if len(params) != 2 {
+		return nil, errors.New("invalid params count")
+	}
+	age, err := strconv.ParseUint(fmt.Sprint(params[0]), 10, 32)
+	if err != nil {
+		return nil, err
+	}
+	prefix := strings.ToLower(fmt.Sprint(params[1]))
+	if len(prefix) > len(crypto.Hash{})*2 || strings.Trim(prefix, "0123456789abcdef") != "" {
+		return nil, fmt.Errorf("invalid hash prefix %s", prefix)
+	}
+	if age == 0 && prefix == "" {
+		return nil, errors.New("age or prefix required")
+	}
+	hashes, err := node.PurgeCacheTransactions(time.Duration(age)*time.Second, prefix)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"purged": len(hashes)}, nil
+}
+
+// queueTransaction has the optional idempotency key of the client, the
+// re-submissions of the same transaction or key have the original result.
+func queueTransaction(node *kernel.Node, subs *submissions, params []any) (string, error) {
+	if len(params) != 1 && len(params) != 2 {
 		return "", errors.New("invalid params count")
 	}
 	raw, err := hex.DecodeString(fmt.Sprint(params[0]))
 	if err != nil {
 		return "", err
 	}
+	var key string
+	if len(params) == 2 {
+		key = fmt.Sprint(params[1])
+	}
+	if len(key) > idempotencyKeyMax {
+		return "", fmt.Errorf("invalid idempotency key size %d", len(key))
+	}
 	ver, err := common.UnmarshalVersionedTransaction(raw)
 	if err != nil {
 		return "", err
 	}
-	return node.QueueTransaction(ver)
+
+	hash, now := ver.PayloadHash(), time.Now()
+	old, err := subs.get(hash, key, now)
+	if err != nil {
+		return "", err
+	}
+	if old != nil {
+		return old.result()
+	}
+	id, err := node.QueueTransaction(ver)
+	subs.put(hash, key, err, now)
+	return id, err
 }
 
 func getTransaction(store storage.Store, params []any) (map[string]any, error) {
@@ -64,6 +142,39 @@ func getTransaction(store storage.Store, params []any) (map[string]any, error) {
 	return data, nil
 }
 
+func getTransactions(store storage.Store, params []any) ([]map[string]any, error) {
+	if len(params) == 0 || len(params) > storage.TransactionsReadBatchLimit {
+		return nil, errors.New("invalid params count")
+	}
+	hashes := make([]crypto.Hash, len(params))
+	for i, p := range params {
+		hash, err := crypto.HashFromString(fmt.Sprint(p))
+		if err != nil {
+			return nil, err
+		}
+		hashes[i] = hash
+	}
+	txs, snaps, err := store.ReadTransactions(hashes)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]map[string]any, len(hashes))
+	for i, tx := range txs {
+		if tx == nil {
+			result[i] = map[string]any{"hash": hashes[i], "found": false}
+			continue
+		}
+		data := transactionToMap(tx)
+		data["hex"] = hex.EncodeToString(tx.Marshal())
+		data["found"] = true
+		if len(snaps[i]) > 0 {
+			data["snapshot"] = snaps[i]
+		}
+		result[i] = data
+	}
+	return result, nil
+}
+
 func getUTXO(store storage.Store, params []any) (map[string]any, error) {
 	if len(params) != 2 {
 		return nil, errors.New("invalid params count")
@@ -85,6 +196,7 @@ func getUTXO(store storage.Store, params []any) (map[string]any, error) {
 		"type":   utxo.Type,
 		"hash":   hash,
 		"index":  index,
+		"asset":  utxo.Asset,
 		"amount": utxo.Amount,
 	}
 	if len(utxo.Keys) > 0 {
@@ -102,6 +214,48 @@ func getUTXO(store storage.Store, params []any) (map[string]any, error) {
 	return output, nil
 }
 
+// verifyOutputOwnership verifies the hex encoded ownership statement against
+// the output in the store, the output may be spent already, so the caller
+// proving the funds should also check the lock.
+func verifyOutputOwnership(store storage.Store, params []any) (map[string]any, error) {
+	if len(params) != 1 {
+		return nil, errors.New("invalid params count")
+	}
+	b, err := hex.DecodeString(fmt.Sprint(params[0]))
+	if err != nil {
+		return nil, err
+	}
+	o, err := common.UnmarshalOutputOwnership(b)
+	if err != nil {
+		return nil, err
+	}
+	utxo, err := store.ReadUTXOLock(o.Transaction, o.Index)
+	if err != nil {
+		return nil, err
+	}
+	if utxo == nil {
+		return nil, fmt.Errorf("output %s:%d not found", o.Transaction, o.Index)
+	}
+	err = o.Verify(&utxo.Output)
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]any{
+		"address":   o.Address.String(),
+		"hash":      o.Transaction,
+		"index":     o.Index,
+		"key":       o.Key,
+		"asset":     utxo.Asset,
+		"amount":    utxo.Amount,
+		"challenge": hex.EncodeToString(o.Challenge),
+	}
+	if utxo.LockHash.HasValue() {
+		result["lock"] = utxo.LockHash
+	}
+	return result, nil
+}
+
 func getGhostKey(store storage.Store, params []any) (map[string]any, error) {
 	if len(params) != 1 {
 		return nil, errors.New("invalid params count")
@@ -137,7 +291,7 @@ func getSnapshot(node *kernel.Node, store storage.Store, params []any) (map[stri
 	if err != nil {
 		return nil, err
 	}
-	return snapshotToMap(node, snap, tx, true), nil
+	return snapshotToMap(node, snap, tx, true)
 }
 
 func listSnapshots(node *kernel.Node, store storage.Store, params []any) ([]map[string]any, error) {
@@ -163,27 +317,40 @@ func listSnapshots(node *kernel.Node, store storage.Store, params []any) ([]map[
 
 	if tx {
 		snapshots, transactions, err := store.ReadSnapshotWithTransactionsSinceTopology(offset, count)
-		return snapshotsToMap(node, snapshots, transactions, sig), err
+		if err != nil {
+			return nil, err
+		}
+		return snapshotsToMap(node, snapshots, transactions, sig)
 	}
 	snapshots, err := store.ReadSnapshotsSinceTopology(offset, count)
-	return snapshotsToMap(node, snapshots, nil, sig), err
+	if err != nil {
+		return nil, err
+	}
+	return snapshotsToMap(node, snapshots, nil, sig)
 }
 
-func snapshotsToMap(node *kernel.Node, snapshots []*common.SnapshotWithTopologicalOrder, transactions []*common.VersionedTransaction, sig bool) []map[string]any {
+func snapshotsToMap(node *kernel.Node, snapshots []*common.SnapshotWithTopologicalOrder, transactions []*common.VersionedTransaction, sig bool) ([]map[string]any, error) {
 	tx := len(transactions) == len(snapshots)
 	result := make([]map[string]any, len(snapshots))
 	for i, s := range snapshots {
+		var ver *common.VersionedTransaction
 		if tx {
-			result[i] = snapshotToMap(node, s, transactions[i], sig)
-		} else {
-			result[i] = snapshotToMap(node, s, nil, sig)
+			ver = transactions[i]
+		}
+		item, err := snapshotToMap(node, s, ver, sig)
+		if err != nil {
+			return nil, err
 		}
+		result[i] = item
 	}
-	return result
+	return result, nil
 }
 
-func snapshotToMap(node *kernel.Node, s *common.SnapshotWithTopologicalOrder, tx *common.VersionedTransaction, sig bool) map[string]any {
-	wn := node.WitnessSnapshot(s)
+func snapshotToMap(node *kernel.Node, s *common.SnapshotWithTopologicalOrder, tx *common.VersionedTransaction, sig bool) (map[string]any, error) {
+	wn, err := node.WitnessSnapshot(s)
+	if err != nil {
+		return nil, err
+	}
 	item := map[string]any{
 		"version":    s.Version,
 		"node":       s.NodeId,
@@ -206,7 +373,7 @@ func snapshotToMap(node *kernel.Node, s *common.SnapshotWithTopologicalOrder, tx
 	if sig {
 		item["signature"] = s.Signature
 	}
-	return item
+	return item, nil
 }
 
 func transactionToMap(tx *common.VersionedTransaction) map[string]any {
@@ -275,5 +442,10 @@ func transactionToMap(tx *common.VersionedTransaction) map[string]any {
 		"hash":       tx.PayloadHash(),
 		"references": tx.References,
 	}
+	name, data, err := common.DecodeExtraSchema(tx)
+	if name != "" && err == nil {
+		tm["extra_schema"] = name
+		tm["extra_decoded"] = data
+	}
 	return tm
 }
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/kernel"
+	"github.com/MixinNetwork/mixin/storage"
+)
+
+// each output of the scanned snapshots costs a scalar multiplication for the
+// view key, so the count of a call is limited, and the method is only for the
+// admin clients, which never send the view key to a public node.
+const viewSnapshotsLimit = 500
+
+// listViewSnapshots scans the snapshots since the topology with the view key,
+// and lists only the snapshots having the outputs to the address, of the asset
+// if not empty, with the indexes of these outputs. The next is the topology to
+// scan from in the next call, because the scanned snapshots may have none.
+func listViewSnapshots(node *kernel.Node, store storage.Store, params []any) (map[string]any, error) {
+	if len(params) != 6 {
+		return nil, errors.New("invalid params count")
+	}
+	account, err := common.NewAddressFromString(fmt.Sprint(params[0]))
+	if err != nil {
+		return nil, err
+	}
+	view, err := crypto.KeyFromString(fmt.Sprint(params[1]))
+	if err != nil {
+		return nil, err
+	}
+	if view.Public() != account.PublicViewKey {
+		return nil, fmt.Errorf("invalid view key for address %s", account)
+	}
+	account.PrivateViewKey = view
+	var asset crypto.Hash
+	if a := fmt.Sprint(params[2]); a != "" {
+		asset, err = crypto.HashFromString(a)
+		if err != nil {
+			return nil, err
+		}
+	}
+	offset, err := strconv.ParseUint(fmt.Sprint(params[3]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.ParseUint(fmt.Sprint(params[4]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 || count > viewSnapshotsLimit {
+		count = viewSnapshotsLimit
+	}
+	sig, err := strconv.ParseBool(fmt.Sprint(params[5]))
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots, transactions, err := store.ReadSnapshotWithTransactionsSinceTopology(offset, count)
+	if err != nil {
+		return nil, err
+	}
+	next := offset
+	result := make([]map[string]any, 0)
+	for i, s := range snapshots {
+		next = s.TopologicalOrder + 1
+		tx := transactions[i]
+		if asset.HasValue() && tx.Asset != asset {
+			continue
+		}
+		outputs := viewTransactionOutputs(tx, &account)
+		if len(outputs) == 0 {
+			continue
+		}
+		item, err := snapshotToMap(node, s, tx, sig)
+		if err != nil {
+			return nil, err
+		}
+		item["outputs"] = outputs
+		result = append(result, item)
+	}
+	return map[string]any{"snapshots": result, "next": next}, nil
+}
+
+func viewTransactionOutputs(tx *common.VersionedTransaction, account *common.Address) []map[string]any {
+	var outputs []map[string]any
+	for j, out := range tx.Outputs {
+		ki, found := out.ViewKeyIndex(account, uint(j))
+		if !found {
+			continue
+		}
+		outputs = append(outputs, map[string]any{
+			"index":     j,
+			"key_index": ki,
+			"amount":    out.Amount,
+		})
+	}
+	return outputs
+}
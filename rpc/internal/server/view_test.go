@@ -0,0 +1,37 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestViewTransactionOutputs(t *testing.T) {
+	require := require.New(t)
+
+	a := common.NewAddressFromSeed(bytes.Repeat([]byte{1}, 64))
+	b := common.NewAddressFromSeed(bytes.Repeat([]byte{2}, 64))
+	tx := common.NewTransactionV5(common.XINAssetId)
+	tx.AddScriptOutput([]*common.Address{&b}, common.NewThresholdScript(1), common.NewInteger(1), bytes.Repeat([]byte{3}, 64))
+	tx.AddScriptOutput([]*common.Address{&b, &a}, common.NewThresholdScript(1), common.NewInteger(2), bytes.Repeat([]byte{4}, 64))
+	tx.AddScriptOutput([]*common.Address{&a}, common.NewThresholdScript(1), common.NewInteger(3), bytes.Repeat([]byte{5}, 64))
+	ver := tx.AsVersioned()
+
+	outputs := viewTransactionOutputs(ver, &a)
+	require.Len(outputs, 2)
+	require.Equal(1, outputs[0]["index"])
+	require.Equal(1, outputs[0]["key_index"])
+	require.Equal(common.NewInteger(2), outputs[0]["amount"])
+	require.Equal(2, outputs[1]["index"])
+	require.Equal(0, outputs[1]["key_index"])
+
+	outputs = viewTransactionOutputs(ver, &b)
+	require.Len(outputs, 2)
+	require.Equal(0, outputs[0]["index"])
+	require.Equal(1, outputs[1]["index"])
+
+	c := common.NewAddressFromSeed(bytes.Repeat([]byte{6}, 64))
+	require.Len(viewTransactionOutputs(ver, &c), 0)
+}
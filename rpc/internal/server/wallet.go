@@ -0,0 +1,152 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/storage"
+)
+
+// buildSweepTransaction scans the snapshots since the topology for all the
+// unspent outputs of the view-key wallet, and builds the JSON raw transaction
+// to spend them all to the destination, which is then signed offline by the
+// signrawtransaction command. The amount is split into at most split count
+// outputs of the split size, and the remaining goes to the last output.
+func buildSweepTransaction(store storage.Store, params []any) (map[string]any, error) {
+	if len(params) != 8 {
+		return nil, errors.New("invalid params count")
+	}
+	account, err := common.NewAddressFromString(fmt.Sprint(params[0]))
+	if err != nil {
+		return nil, err
+	}
+	view, err := crypto.KeyFromString(fmt.Sprint(params[1]))
+	if err != nil {
+		return nil, err
+	}
+	if view.Public() != account.PublicViewKey {
+		return nil, fmt.Errorf("invalid view key for address %s", account)
+	}
+	account.PrivateViewKey = view
+	asset, err := crypto.HashFromString(fmt.Sprint(params[2]))
+	if err != nil {
+		return nil, err
+	}
+	destination, err := common.NewAddressFromString(fmt.Sprint(params[3]))
+	if err != nil {
+		return nil, err
+	}
+	offset, err := strconv.ParseUint(fmt.Sprint(params[4]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.ParseUint(fmt.Sprint(params[5]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	splitCount, err := strconv.ParseUint(fmt.Sprint(params[6]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	splitSize := common.NewIntegerFromString(fmt.Sprint(params[7]))
+
+	snapshots, transactions, err := store.ReadSnapshotWithTransactionsSinceTopology(offset, count)
+	if err != nil {
+		return nil, err
+	}
+
+	next := offset
+	total := common.Zero
+	inputs := make([]map[string]any, 0)
+	threshold := common.NewThresholdScript(1).String()
+	for i, s := range snapshots {
+		if len(inputs) >= common.SliceCountLimit {
+			break
+		}
+		next = s.TopologicalOrder + 1
+		tx := transactions[i]
+		if tx.Asset != asset {
+			continue
+		}
+		hash := tx.PayloadHash()
+		for j, out := range tx.Outputs {
+			if out.Type != common.OutputTypeScript || out.Script.String() != threshold {
+				continue
+			}
+			if _, found := out.ViewKeyIndex(&account, uint(j)); !found {
+				continue
+			}
+			utxo, err := store.ReadUTXOLock(hash, uint(j))
+			if err != nil {
+				return nil, err
+			}
+			if utxo == nil || utxo.LockHash.HasValue() {
+				continue
+			}
+			total = total.Add(out.Amount)
+			inputs = append(inputs, map[string]any{
+				"hash":  hash,
+				"index": j,
+				"keys":  out.Keys,
+				"mask":  out.Mask,
+			})
+		}
+	}
+	if len(inputs) > common.SliceCountLimit {
+		return nil, fmt.Errorf("too many inputs %d in snapshot %d", len(inputs), next-1)
+	}
+
+	result := map[string]any{"next": next, "amount": total}
+	if len(inputs) == 0 {
+		return result, nil
+	}
+	amounts, err := splitSweepAmount(total, splitCount, splitSize)
+	if err != nil {
+		return nil, err
+	}
+	outputs := make([]map[string]any, len(amounts))
+	for i, amount := range amounts {
+		outputs[i] = map[string]any{
+			"type":     common.OutputTypeScript,
+			"amount":   amount,
+			"script":   common.NewThresholdScript(1),
+			"accounts": []*common.Address{&destination},
+		}
+	}
+	result["raw"] = map[string]any{
+		"version": common.TxVersionHashSignature,
+		"asset":   asset,
+		"inputs":  inputs,
+		"outputs": outputs,
+		"extra":   "",
+	}
+	return result, nil
+}
+
+func splitSweepAmount(total common.Integer, count uint64, size common.Integer) ([]common.Integer, error) {
+	if total.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid sweep amount %s", total)
+	}
+	if count == 0 || size.Sign() <= 0 {
+		return []common.Integer{total}, nil
+	}
+	if count >= common.SliceCountLimit {
+		return nil, fmt.Errorf("invalid split count %d", count)
+	}
+	if total.Cmp(size) < 0 {
+		return []common.Integer{total}, nil
+	}
+	count = min(count, total.Count(size))
+	amounts := make([]common.Integer, 0, count+1)
+	for range count {
+		amounts = append(amounts, size)
+		total = total.Sub(size)
+	}
+	if total.Sign() > 0 {
+		amounts = append(amounts, total)
+	}
+	return amounts, nil
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitSweepAmount(t *testing.T) {
+	require := require.New(t)
+
+	_, err := splitSweepAmount(common.Zero, 3, common.NewInteger(10))
+	require.NotNil(err)
+	_, err = splitSweepAmount(common.NewInteger(100), 256, common.NewInteger(10))
+	require.NotNil(err)
+
+	amounts, err := splitSweepAmount(common.NewInteger(100), 0, common.NewInteger(10))
+	require.Nil(err)
+	require.Equal([]common.Integer{common.NewInteger(100)}, amounts)
+
+	amounts, err = splitSweepAmount(common.NewInteger(5), 3, common.NewInteger(10))
+	require.Nil(err)
+	require.Equal([]common.Integer{common.NewInteger(5)}, amounts)
+
+	amounts, err = splitSweepAmount(common.NewInteger(35), 3, common.NewInteger(10))
+	require.Nil(err)
+	require.Len(amounts, 4)
+	require.Equal("10.00000000", amounts[0].String())
+	require.Equal("10.00000000", amounts[2].String())
+	require.Equal("5.00000000", amounts[3].String())
+
+	amounts, err = splitSweepAmount(common.NewInteger(30), 5, common.NewInteger(10))
+	require.Nil(err)
+	require.Len(amounts, 3)
+	require.Equal("10.00000000", amounts[2].String())
+}
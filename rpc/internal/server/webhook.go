@@ -0,0 +1,115 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/storage"
+)
+
+// registerWebhook params are the url, the asset, the account address with its
+// private view key, the hex extra prefix, and the optional confirmations, all
+// filters could be empty.
+func registerWebhook(store storage.Store, params []any) (map[string]any, error) {
+	if len(params) != 5 && len(params) != 6 {
+		return nil, errors.New("invalid params count")
+	}
+	hook := &storage.Webhook{
+		URL:         fmt.Sprint(params[0]),
+		ExtraPrefix: fmt.Sprint(params[4]),
+	}
+	if len(params) == 6 {
+		c, err := strconv.ParseUint(fmt.Sprint(params[5]), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		hook.Confirmations = c
+	}
+	if a := fmt.Sprint(params[1]); a != "" {
+		asset, err := crypto.HashFromString(a)
+		if err != nil {
+			return nil, err
+		}
+		hook.Asset = asset
+	}
+	if a := fmt.Sprint(params[2]); a != "" {
+		account, err := common.NewAddressFromString(a)
+		if err != nil {
+			return nil, err
+		}
+		view, err := crypto.KeyFromString(fmt.Sprint(params[3]))
+		if err != nil {
+			return nil, err
+		}
+		if view.Public() != account.PublicViewKey {
+			return nil, fmt.Errorf("invalid view key for address %s", account)
+		}
+		hook.View = view
+		hook.Spend = account.PublicSpendKey
+	}
+	hook, err := store.RegisterWebhook(hook, uint64(time.Now().UnixNano()))
+	if err != nil {
+		return nil, err
+	}
+	return webhookToMap(store, hook)
+}
+
+func listWebhooks(store storage.Store) ([]map[string]any, error) {
+	hooks, err := store.ListWebhooks()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]map[string]any, len(hooks))
+	for i, h := range hooks {
+		result[i], err = webhookToMap(store, h)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func removeWebhook(store storage.Store, params []any) (map[string]any, error) {
+	if len(params) != 1 {
+		return nil, errors.New("invalid params count")
+	}
+	id, err := crypto.HashFromString(fmt.Sprint(params[0]))
+	if err != nil {
+		return nil, err
+	}
+	err = store.RemoveWebhook(id)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"id": id}, nil
+}
+
+func webhookToMap(store storage.Store, hook *storage.Webhook) (map[string]any, error) {
+	pending, err := store.CountWebhookDeliveries(hook.Id)
+	if err != nil {
+		return nil, err
+	}
+	m := map[string]any{
+		"id":            hook.Id,
+		"url":           hook.URL,
+		"extra_prefix":  hook.ExtraPrefix,
+		"confirmations": hook.Confirmations,
+		"timestamp":     hook.Timestamp,
+		"pending":       pending,
+	}
+	if hook.Asset.HasValue() {
+		m["asset"] = hook.Asset
+	}
+	if hook.Spend.HasValue() {
+		account := common.Address{
+			PublicViewKey:  hook.View.Public(),
+			PublicSpendKey: hook.Spend,
+		}
+		m["account"] = account.String()
+	}
+	return m, nil
+}
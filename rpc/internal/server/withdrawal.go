@@ -4,7 +4,10 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 
+	"github.com/MixinNetwork/mixin/common"
 	"github.com/MixinNetwork/mixin/crypto"
 	"github.com/MixinNetwork/mixin/storage"
 )
@@ -30,3 +33,190 @@ func readWithdrawal(store storage.Store, params []any) (map[string]any, error) {
 	}
 	return data, nil
 }
+
+// the claim fees are estimated by the minimum fee, unless the fee of the
+// chain is given in the optional chain to fee object param
+func listWithdrawalClaims(store storage.Store, params []any) ([]map[string]any, error) {
+	if len(params) > 1 {
+		return nil, errors.New("invalid params count")
+	}
+	fees := make(map[crypto.Hash]common.Integer)
+	if len(params) == 1 {
+		m, ok := params[0].(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("invalid withdrawal claim fees %v", params[0])
+		}
+		for c, f := range m {
+			chain, err := crypto.HashFromString(c)
+			if err != nil {
+				return nil, err
+			}
+			fees[chain] = common.NewIntegerFromString(fmt.Sprint(f))
+		}
+	}
+
+	pending, err := store.ListPendingWithdrawalClaims(storage.WithdrawalPendingClaimsListLimit)
+	if err != nil {
+		return nil, err
+	}
+	submissions := make([]*common.WithdrawalSubmission, len(pending))
+	filter := make(map[crypto.Hash]*common.VersionedTransaction)
+	for i, ver := range pending {
+		asset, _, err := store.ReadAssetWithBalance(ver.Asset)
+		if err != nil {
+			return nil, err
+		}
+		if asset == nil {
+			return nil, fmt.Errorf("invalid withdrawal submission asset %s", ver.Asset)
+		}
+		submissions[i] = &common.WithdrawalSubmission{Transaction: ver, Chain: asset.Chain}
+		filter[ver.PayloadHash()] = ver
+	}
+	batches, err := common.GroupWithdrawalClaims(submissions, func(chain crypto.Hash, ver *common.VersionedTransaction) (common.Integer, error) {
+		if fee, found := fees[chain]; found {
+			return fee, nil
+		}
+		return common.EstimateMinimumWithdrawalClaimFee(chain, ver)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]map[string]any, len(batches))
+	for i, b := range batches {
+		claims := make([]map[string]any, len(b.Claims))
+		for j, c := range b.Claims {
+			ver := filter[c.Submission]
+			claims[j] = map[string]any{
+				"submission": c.Submission,
+				"fee":        c.Fee,
+				"asset":      ver.Asset,
+				"amount":     ver.Outputs[0].Amount,
+				"address":    ver.Outputs[0].Withdrawal.Address,
+				"tag":        ver.Outputs[0].Withdrawal.Tag,
+			}
+		}
+		result[i] = map[string]any{
+			"chain":  b.Chain,
+			"claims": claims,
+			"total":  b.Total,
+		}
+	}
+	return result, nil
+}
+
+// the claims param is the list of objects with submission, fee and info in
+// hex, and the inputs param is the list of XIN inputs in hash:index format
+func buildWithdrawalClaims(store storage.Store, params []any) ([]map[string]any, error) {
+	if len(params) != 4 {
+		return nil, errors.New("invalid params count")
+	}
+	receiver, err := common.NewAddressFromString(fmt.Sprint(params[0]))
+	if err != nil {
+		return nil, err
+	}
+	change, err := common.NewAddressFromString(fmt.Sprint(params[1]))
+	if err != nil {
+		return nil, err
+	}
+	list, ok := params[2].([]any)
+	if !ok {
+		return nil, fmt.Errorf("invalid withdrawal claim inputs %v", params[2])
+	}
+	inputs := make([]*common.TransferInput, len(list))
+	for i, in := range list {
+		parts := strings.Split(fmt.Sprint(in), ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid withdrawal claim input %v", in)
+		}
+		hash, err := crypto.HashFromString(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		index, err := strconv.ParseUint(parts[1], 10, 16)
+		if err != nil {
+			return nil, err
+		}
+		utxo, err := store.ReadUTXOLock(hash, uint(index))
+		if err != nil {
+			return nil, err
+		}
+		if utxo == nil || utxo.LockHash.HasValue() || utxo.Asset != common.XINAssetId {
+			return nil, fmt.Errorf("invalid withdrawal claim input %s:%d", hash, index)
+		}
+		inputs[i] = &common.TransferInput{Hash: hash, Index: uint(index), Amount: utxo.Amount}
+	}
+	list, ok = params[3].([]any)
+	if !ok {
+		return nil, fmt.Errorf("invalid withdrawal claims %v", params[3])
+	}
+	claims := make([]*common.WithdrawalClaim, len(list))
+	for i, c := range list {
+		m, ok := c.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("invalid withdrawal claim %v", c)
+		}
+		hash, err := crypto.HashFromString(fmt.Sprint(m["submission"]))
+		if err != nil {
+			return nil, err
+		}
+		fee := common.NewIntegerFromString(fmt.Sprint(m["fee"]))
+		info, err := hex.DecodeString(fmt.Sprint(m["info"]))
+		if err != nil {
+			return nil, err
+		}
+		claim, _, err := store.ReadWithdrawalClaim(hash)
+		if err != nil {
+			return nil, err
+		}
+		if claim != nil {
+			return nil, fmt.Errorf("withdrawal submission %s already claimed", hash)
+		}
+		claims[i] = &common.WithdrawalClaim{Submission: hash, Fee: fee, Info: info}
+	}
+
+	seed := make([]byte, 64)
+	crypto.ReadRand(seed)
+	txs, _, err := common.NewWithdrawalClaims(claims, inputs, &receiver, &change, seed)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]map[string]any, len(txs))
+	for i, tx := range txs {
+		result[i] = map[string]any{
+			"submission":  claims[i].Submission,
+			"approval":    crypto.Blake3Hash(claims[i].Info),
+			"transaction": rawTransactionToMap(tx),
+		}
+	}
+	return result, nil
+}
+
+// the raw transaction map is in the signrawtransaction format
+func rawTransactionToMap(tx *common.Transaction) map[string]any {
+	inputs := make([]map[string]any, len(tx.Inputs))
+	for i, in := range tx.Inputs {
+		inputs[i] = map[string]any{
+			"hash":  in.Hash,
+			"index": in.Index,
+		}
+	}
+	outputs := make([]map[string]any, len(tx.Outputs))
+	for i, o := range tx.Outputs {
+		outputs[i] = map[string]any{
+			"type":   o.Type,
+			"amount": o.Amount,
+			"keys":   o.Keys,
+			"mask":   o.Mask,
+			"script": o.Script,
+		}
+	}
+	return map[string]any{
+		"version":    tx.Version,
+		"asset":      tx.Asset,
+		"inputs":     inputs,
+		"outputs":    outputs,
+		"references": tx.References,
+		"extra":      hex.EncodeToString(tx.Extra),
+	}
+}
@@ -12,3 +12,7 @@ import (
 func NewServer(custom *config.Custom, store storage.Store, node *kernel.Node, port int) *http.Server {
 	return server.NewServer(custom, store, node, port)
 }
+
+func NewAdminServer(custom *config.Custom, store storage.Store, node *kernel.Node) *http.Server {
+	return server.NewAdminServer(custom, store, node)
+}
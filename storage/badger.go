@@ -4,7 +4,9 @@ import (
 	"sync"
 	"time"
 
+	"github.com/MixinNetwork/mixin/common"
 	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
 	"github.com/MixinNetwork/mixin/logger"
 	"github.com/dgraph-io/badger/v4"
 	"github.com/dgraph-io/badger/v4/options"
@@ -15,7 +17,17 @@ type BadgerStore struct {
 	snapshotsDB *badger.DB
 	cacheDB     *badger.DB
 	mutex       *sync.RWMutex
+	auditMutex  sync.Mutex
 	closing     bool
+	devGenesis  bool
+
+	walletAccounts        map[crypto.Key]*common.Address
+	walletSequences       map[crypto.Key]*WalletSequence
+	walletDerivations     map[crypto.Key]*walletDerivation
+	walletSubaddresses    map[crypto.Key]*walletSubaddress
+	walletSubaddressViews map[crypto.Key]bool
+
+	webhooks map[crypto.Hash]*Webhook
 }
 
 func NewBadgerStore(custom *config.Custom, dir string) (*BadgerStore, error) {
@@ -27,13 +39,29 @@ func NewBadgerStore(custom *config.Custom, dir string) (*BadgerStore, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &BadgerStore{
+	return newBadgerStore(custom, snapshotsDB, cacheDB)
+}
+
+func newBadgerStore(custom *config.Custom, snapshotsDB, cacheDB *badger.DB) (*BadgerStore, error) {
+	store := &BadgerStore{
 		custom:      custom,
 		snapshotsDB: snapshotsDB,
 		cacheDB:     cacheDB,
 		mutex:       new(sync.RWMutex),
 		closing:     false,
-	}, nil
+	}
+	err := store.loadWalletAccounts()
+	if err != nil {
+		return nil, err
+	}
+	return store, store.loadWebhooks()
+}
+
+// EnableDevGenesis allows the single node of a dev genesis to reference its
+// own genesis round, which is a bug for all the other networks. It must be
+// called before any round is started.
+func (store *BadgerStore) EnableDevGenesis() {
+	store.devGenesis = true
 }
 
 func (store *BadgerStore) Close() error {
@@ -47,6 +75,7 @@ func (store *BadgerStore) Close() error {
 
 func openDB(dir string, sync bool, custom *config.Custom) (*badger.DB, error) {
 	opts := badger.DefaultOptions(dir)
+	opts = opts.WithInMemory(dir == "")
 	opts = opts.WithSyncWrites(sync)
 	opts = opts.WithCompression(options.None)
 	opts = opts.WithBlockCacheSize(0)
@@ -71,7 +100,7 @@ func openDB(dir string, sync bool, custom *config.Custom) (*badger.DB, error) {
 		return nil, err
 	}
 
-	if custom != nil && custom.Storage.ValueLogGC {
+	if custom != nil && custom.Storage.ValueLogGC && !opts.InMemory {
 		go func() {
 			for {
 				lsm, vlog := db.Size()
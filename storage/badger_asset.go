@@ -24,6 +24,38 @@ func (s *BadgerStore) ReadAssetWithBalance(id crypto.Hash) (*common.Asset, commo
 	return asset, balance, nil
 }
 
+type AssetWithBalance struct {
+	Id      crypto.Hash
+	Asset   *common.Asset
+	Balance common.Integer
+}
+
+func (s *BadgerStore) ListAssetsWithBalance() ([]*AssetWithBalance, error) {
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = []byte(graphPrefixAssetInfo)
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	var assets []*AssetWithBalance
+	for it.Seek(opts.Prefix); it.Valid(); it.Next() {
+		var id crypto.Hash
+		copy(id[:], it.Item().Key()[len(graphPrefixAssetInfo):])
+		asset, err := readAssetInfo(txn, id)
+		if err != nil {
+			return nil, err
+		}
+		balance, err := readTotalInAsset(txn, id)
+		if err != nil {
+			return nil, err
+		}
+		assets = append(assets, &AssetWithBalance{Id: id, Asset: asset, Balance: balance})
+	}
+	return assets, nil
+}
+
 func readTotalInAsset(txn *badger.Txn, hash crypto.Hash) (common.Integer, error) {
 	key := graphAssetTotalKey(hash)
 	item, err := txn.Get(key)
@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"strings"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/dgraph-io/badger/v4"
+)
+
+const (
+	graphPrefixAuditEntry = "AUDITENTRY"
+
+	auditRotateBatchSize = 1024
+)
+
+func (s *BadgerStore) WriteAuditEntry(entry *common.AuditEntry) error {
+	return s.WriteAuditEntries([]*common.AuditEntry{entry})
+}
+
+// WriteAuditEntries writes the entries in a single transaction, and rotates
+// the audit log to keep only the latest entries of the retention count.
+func (s *BadgerStore) WriteAuditEntries(entries []*common.AuditEntry) error {
+	for _, e := range entries {
+		err := e.Verify()
+		if err != nil {
+			return err
+		}
+	}
+
+	s.auditMutex.Lock()
+	defer s.auditMutex.Unlock()
+
+	txn := s.snapshotsDB.NewTransaction(true)
+	defer txn.Discard()
+
+	last, err := readLastAuditSequence(txn)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		last = last + 1
+		e.Sequence = last
+		val, err := json.Marshal(e)
+		if err != nil {
+			panic(err)
+		}
+		err = txn.Set(graphAuditEntryKey(e.Sequence), val)
+		if err != nil {
+			return err
+		}
+	}
+	var retention uint64
+	if s.custom != nil {
+		retention = s.custom.Storage.AuditRetention
+	}
+	if retention > 0 && last > retention {
+		err = rotateAuditEntries(txn, last-retention)
+		if err != nil {
+			return err
+		}
+	}
+	return txn.Commit()
+}
+
+// ListAuditEntries lists the entries since the offset sequence, and filters
+// them by the action prefix if not empty, e.g. rpc. for all the RPC entries.
+func (s *BadgerStore) ListAuditEntries(offset, count uint64, action string) ([]*common.AuditEntry, error) {
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = true
+	opts.Prefix = []byte(graphPrefixAuditEntry)
+
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	var entries []*common.AuditEntry
+	it.Seek(graphAuditEntryKey(offset))
+	for ; it.ValidForPrefix([]byte(graphPrefixAuditEntry)) && uint64(len(entries)) < count; it.Next() {
+		val, err := it.Item().ValueCopy(nil)
+		if err != nil {
+			return nil, err
+		}
+		var e common.AuditEntry
+		err = json.Unmarshal(val, &e)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(e.Action, action) {
+			continue
+		}
+		entries = append(entries, &e)
+	}
+	return entries, nil
+}
+
+func readLastAuditSequence(txn *badger.Txn) (uint64, error) {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	opts.Prefix = []byte(graphPrefixAuditEntry)
+	opts.Reverse = true
+
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	it.Seek(graphAuditEntryKey(^uint64(0)))
+	if !it.ValidForPrefix([]byte(graphPrefixAuditEntry)) {
+		return 0, nil
+	}
+	key := it.Item().KeyCopy(nil)
+	return binary.BigEndian.Uint64(key[len(graphPrefixAuditEntry):]), nil
+}
+
+// the rotation deletes a limited number of entries in each write, so a
+// lowered retention is caught up gradually without a too big transaction
+func rotateAuditEntries(txn *badger.Txn, sequence uint64) error {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	opts.Prefix = []byte(graphPrefixAuditEntry)
+
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	var keys [][]byte
+	for it.Seek(opts.Prefix); it.Valid() && len(keys) < auditRotateBatchSize; it.Next() {
+		key := it.Item().KeyCopy(nil)
+		if binary.BigEndian.Uint64(key[len(graphPrefixAuditEntry):]) > sequence {
+			break
+		}
+		keys = append(keys, key)
+	}
+	it.Close()
+
+	for _, key := range keys {
+		err := txn.Delete(key)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func graphAuditEntryKey(sequence uint64) []byte {
+	key := []byte(graphPrefixAuditEntry)
+	return binary.BigEndian.AppendUint64(key, sequence)
+}
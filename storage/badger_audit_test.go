@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditEntries(t *testing.T) {
+	require := require.New(t)
+	custom, err := config.Initialize("../config/config.example.toml")
+	require.Nil(err)
+
+	root, err := os.MkdirTemp("", "mixin-audit-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	store, err := NewBadgerStore(custom, root)
+	require.Nil(err)
+	defer store.Close()
+
+	entries, err := store.ListAuditEntries(0, 10, "")
+	require.Nil(err)
+	require.Len(entries, 0)
+
+	err = store.WriteAuditEntry(&common.AuditEntry{Actor: "root", Action: common.AuditActionMaintenance})
+	require.NotNil(err)
+
+	for i := 0; i < 5; i++ {
+		entry := &common.AuditEntry{
+			Timestamp: uint64(i + 1),
+			Actor:     "root",
+			Action:    common.AuditActionMaintenance,
+			Detail:    fmt.Sprintf("task %d", i),
+		}
+		err = store.WriteAuditEntry(entry)
+		require.Nil(err)
+		require.Equal(uint64(i+1), entry.Sequence)
+	}
+
+	entries, err = store.ListAuditEntries(0, 10, "")
+	require.Nil(err)
+	require.Len(entries, 5)
+	require.Equal(uint64(1), entries[0].Sequence)
+	require.Equal("task 0", entries[0].Detail)
+
+	entries, err = store.ListAuditEntries(4, 1, "")
+	require.Nil(err)
+	require.Len(entries, 1)
+	require.Equal(uint64(4), entries[0].Sequence)
+	require.Equal("task 3", entries[0].Detail)
+	require.Equal("root", entries[0].Actor)
+}
+
+func TestAuditEntriesRotation(t *testing.T) {
+	require := require.New(t)
+	custom, err := config.Initialize("../config/config.example.toml")
+	require.Nil(err)
+	custom.Storage.AuditRetention = 3
+
+	root, err := os.MkdirTemp("", "mixin-audit-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	store, err := NewBadgerStore(custom, root)
+	require.Nil(err)
+	defer store.Close()
+
+	err = store.WriteAuditEntries([]*common.AuditEntry{
+		{Timestamp: 1, Actor: "rpc", Action: common.AuditActionRPCAdmin, Source: "127.0.0.1", Detail: "method=setloglevel"},
+		{Timestamp: 2, Actor: "rpc", Action: common.AuditActionTransactionSubmit, Source: "10.0.0.1", Detail: "hash=1"},
+		{Timestamp: 3, Actor: "p2p", Action: common.AuditActionPeerAuthFailure, Source: "10.0.0.2"},
+		{Timestamp: 4, Actor: "rpc", Action: common.AuditActionRPCAuthFailure, Source: "10.0.0.3", Detail: "method=setloglevel"},
+	})
+	require.Nil(err)
+
+	entries, err := store.ListAuditEntries(0, 10, "")
+	require.Nil(err)
+	require.Len(entries, 3)
+	require.Equal(uint64(2), entries[0].Sequence)
+	require.Equal("10.0.0.1", entries[0].Source)
+
+	entries, err = store.ListAuditEntries(0, 10, "rpc.")
+	require.Nil(err)
+	require.Len(entries, 1)
+	require.Equal(uint64(4), entries[0].Sequence)
+	require.Equal(common.AuditActionRPCAuthFailure, entries[0].Action)
+
+	entry := &common.AuditEntry{Timestamp: 5, Actor: "rpc", Action: common.AuditActionRPCAdmin, Source: "127.0.0.1"}
+	err = store.WriteAuditEntry(entry)
+	require.Nil(err)
+	require.Equal(uint64(5), entry.Sequence)
+	entries, err = store.ListAuditEntries(0, 10, "")
+	require.Nil(err)
+	require.Len(entries, 3)
+	require.Equal(uint64(3), entries[0].Sequence)
+	entries, err = store.ListAuditEntries(0, 1, "rpc.")
+	require.Nil(err)
+	require.Len(entries, 1)
+	require.Equal(uint64(4), entries[0].Sequence)
+}
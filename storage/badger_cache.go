@@ -2,6 +2,7 @@ package storage
 
 import (
 	"encoding/binary"
+	"strings"
 	"time"
 
 	"github.com/MixinNetwork/mixin/common"
@@ -13,6 +14,10 @@ const (
 	cachePrefixTransactionQueue = "CACHETRANSACTIONQUEUE"
 	cachePrefixTransactionOrder = "CACHETRANSACTIONORDER"
 	cachePrefixTransactionCache = "CACHETRANSACTIONPAYLOAD"
+
+	// the payload is kept a bit longer than the queue and order keys, so
+	// the transaction of a queue key read just before the expiry is found
+	cacheTransactionPayloadGrace = 60
 )
 
 func (s *BadgerStore) CacheRetrieveTransactions(limit int) ([]*common.VersionedTransaction, error) {
@@ -87,6 +92,9 @@ func (s *BadgerStore) CacheRemoveTransactions(hashes []crypto.Hash) error {
 	}
 }
 
+// CachePutTransaction queues the transaction again if already cached, but
+// the expiry of the first put is kept, so a transaction never finalized is
+// expired after the cache transaction ttl, however often it is received.
 func (s *BadgerStore) CachePutTransaction(tx *common.VersionedTransaction) error {
 	txn := s.cacheDB.NewTransaction(true)
 	defer txn.Discard()
@@ -97,22 +105,34 @@ func (s *BadgerStore) CachePutTransaction(tx *common.VersionedTransaction) error
 	if err == nil {
 		return nil
 	}
-	etr := badger.NewEntry(key, []byte{}).WithTTL(time.Duration(s.custom.Node.CacheTTL) * time.Second)
-	err = txn.SetEntry(etr)
-	if err != nil {
+
+	ttl := time.Duration(s.custom.Node.CacheTransactionTTL) * time.Second
+	expires := uint64(time.Now().Add(ttl).Unix())
+	item, err := txn.Get(cacheTransactionCacheKey(hash))
+	if err == nil {
+		expires = item.ExpiresAt()
+	} else if err != badger.ErrKeyNotFound {
 		return err
+	} else {
+		key := cacheTransactionCacheKey(hash)
+		etr := badger.NewEntry(key, tx.Marshal())
+		etr.ExpiresAt = expires + cacheTransactionPayloadGrace
+		err = txn.SetEntry(etr)
+		if err != nil {
+			return err
+		}
 	}
 
-	key = cacheTransactionCacheKey(hash)
-	val := tx.Marshal()
-	etr = badger.NewEntry(key, val).WithTTL(time.Duration(s.custom.Node.CacheTTL+60) * time.Second)
+	etr := badger.NewEntry(key, []byte{})
+	etr.ExpiresAt = expires
 	err = txn.SetEntry(etr)
 	if err != nil {
 		return err
 	}
 
 	key = cacheTransactionQueueKey(uint64(time.Now().UnixNano()), hash)
-	etr = badger.NewEntry(key, []byte{}).WithTTL(time.Duration(s.custom.Node.CacheTTL) * time.Second)
+	etr = badger.NewEntry(key, []byte{})
+	etr.ExpiresAt = expires
 	err = txn.SetEntry(etr)
 	if err != nil {
 		return err
@@ -121,6 +141,67 @@ func (s *BadgerStore) CachePutTransaction(tx *common.VersionedTransaction) error
 	return txn.Commit()
 }
 
+// CacheTransactionStats counts the cache transactions not expired yet, and
+// the size of their payloads.
+func (s *BadgerStore) CacheTransactionStats() (int, int64, error) {
+	txn := s.cacheDB.NewTransaction(false)
+	defer txn.Discard()
+
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	opts.Prefix = []byte(cachePrefixTransactionCache)
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	var count int
+	var size int64
+	for it.Seek(opts.Prefix); it.Valid(); it.Next() {
+		count += 1
+		size += it.Item().ValueSize()
+	}
+	return count, size, nil
+}
+
+// CachePurgeTransactions removes the cache transactions first put before the
+// timestamp in nanoseconds, if not 0, and with the hash of the hex prefix,
+// if not empty. The put time is derived from the expiry of the payload, so
+// it's not accurate if the ttl changed since the put.
+func (s *BadgerStore) CachePurgeTransactions(before uint64, prefix string) ([]crypto.Hash, error) {
+	hashes, err := s.cacheListPurgeTransactions(before, prefix)
+	if err != nil || len(hashes) == 0 {
+		return nil, err
+	}
+	return hashes, s.CacheRemoveTransactions(hashes)
+}
+
+func (s *BadgerStore) cacheListPurgeTransactions(before uint64, prefix string) ([]crypto.Hash, error) {
+	ttl := uint64(s.custom.Node.CacheTransactionTTL) + cacheTransactionPayloadGrace
+	txn := s.cacheDB.NewTransaction(false)
+	defer txn.Discard()
+
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	opts.Prefix = []byte(cachePrefixTransactionCache)
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	var hashes []crypto.Hash
+	for it.Seek(opts.Prefix); it.Valid(); it.Next() {
+		item := it.Item()
+		var hash crypto.Hash
+		copy(hash[:], item.Key()[len(cachePrefixTransactionCache):])
+		if !strings.HasPrefix(hash.String(), prefix) {
+			continue
+		}
+		put := (item.ExpiresAt() - min(item.ExpiresAt(), ttl)) * uint64(time.Second)
+		if before > 0 && put >= before {
+			continue
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, nil
+}
+
 func (s *BadgerStore) CacheGetTransaction(hash crypto.Hash) (*common.VersionedTransaction, error) {
 	txn := s.cacheDB.NewTransaction(false)
 	defer txn.Discard()
@@ -128,6 +209,34 @@ func (s *BadgerStore) CacheGetTransaction(hash crypto.Hash) (*common.VersionedTr
 	return s.cacheReadTransaction(txn, hash)
 }
 
+// CacheListTransactions lists the cached transactions of the type without
+// removing them from the queue, and the finalized ones may be included.
+func (s *BadgerStore) CacheListTransactions(typ uint8, limit int) ([]*common.VersionedTransaction, error) {
+	txn := s.cacheDB.NewTransaction(false)
+	defer txn.Discard()
+
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = []byte(cachePrefixTransactionCache)
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	var txs []*common.VersionedTransaction
+	for it.Seek(opts.Prefix); len(txs) < limit && it.Valid(); it.Next() {
+		val, err := it.Item().ValueCopy(nil)
+		if err != nil {
+			return nil, err
+		}
+		ver, err := common.UnmarshalVersionedTransaction(val)
+		if err != nil {
+			return nil, err
+		}
+		if ver.TransactionType() == typ {
+			txs = append(txs, ver)
+		}
+	}
+	return txs, nil
+}
+
 func (s *BadgerStore) cacheReadTransaction(txn *badger.Txn, tx crypto.Hash) (*common.VersionedTransaction, error) {
 	key := cacheTransactionCacheKey(tx)
 	item, err := txn.Get(key)
@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/dgraph-io/badger/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheTransactionRetention(t *testing.T) {
+	require := require.New(t)
+
+	custom, err := config.Initialize("../config/config.example.toml")
+	require.Nil(err)
+	require.Equal(custom.Node.CacheTTL, custom.Node.CacheTransactionTTL)
+	custom.Node.CacheTransactionTTL = 600
+
+	root, err := os.MkdirTemp("", "mixin-cache-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+	store, err := NewBadgerStore(custom, root)
+	require.Nil(err)
+	defer store.Close()
+
+	var txs []*common.VersionedTransaction
+	for i := range 3 {
+		tx := common.NewTransactionV5(common.XINAssetId)
+		tx.AddInput(crypto.Blake3Hash([]byte("cache")), uint(i))
+		ver := tx.AsVersioned()
+		err = store.CachePutTransaction(ver)
+		require.Nil(err)
+		txs = append(txs, ver)
+	}
+	expires := func(hash crypto.Hash) uint64 {
+		txn := store.cacheDB.NewTransaction(false)
+		defer txn.Discard()
+		item, err := txn.Get(cacheTransactionCacheKey(hash))
+		require.Nil(err)
+		return item.ExpiresAt()
+	}
+	hash := txs[0].PayloadHash()
+	first := expires(hash)
+	require.InDelta(time.Now().Unix()+600+cacheTransactionPayloadGrace, int64(first), 2)
+
+	// received again after retrieved from the queue
+	err = store.cacheDB.Update(func(txn *badger.Txn) error {
+		etr := badger.NewEntry(cacheTransactionCacheKey(hash), txs[0].Marshal())
+		etr.ExpiresAt = first - 300
+		return txn.SetEntry(etr)
+	})
+	require.Nil(err)
+	retrieved, err := store.CacheRetrieveTransactions(10)
+	require.Nil(err)
+	require.Len(retrieved, 3)
+	err = store.CachePutTransaction(txs[0])
+	require.Nil(err)
+	require.Equal(first-300, expires(hash))
+	retrieved, err = store.CacheRetrieveTransactions(10)
+	require.Nil(err)
+	require.Len(retrieved, 1)
+
+	count, size, err := store.CacheTransactionStats()
+	require.Nil(err)
+	require.Equal(3, count)
+	require.Equal(int64(len(txs[0].Marshal())*3), size)
+
+	purged, err := store.CachePurgeTransactions(uint64(time.Now().Add(-time.Minute).UnixNano()), "")
+	require.Nil(err)
+	require.Equal([]crypto.Hash{hash}, purged)
+	other := txs[1].PayloadHash()
+	purged, err = store.CachePurgeTransactions(0, other.String()[:8])
+	require.Nil(err)
+	require.Equal([]crypto.Hash{other}, purged)
+	purged, err = store.CachePurgeTransactions(uint64(time.Now().Add(-time.Minute).UnixNano()), "")
+	require.Nil(err)
+	require.Len(purged, 0)
+
+	count, _, err = store.CacheTransactionStats()
+	require.Nil(err)
+	require.Equal(1, count)
+	tx, err := store.CacheGetTransaction(hash)
+	require.Nil(err)
+	require.Nil(tx)
+	tx, err = store.CacheGetTransaction(txs[2].PayloadHash())
+	require.Nil(err)
+	require.NotNil(tx)
+}
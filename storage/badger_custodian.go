@@ -1,14 +1,107 @@
 package storage
 
 import (
+	"bytes"
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/MixinNetwork/mixin/common"
 	"github.com/MixinNetwork/mixin/crypto"
 	"github.com/dgraph-io/badger/v4"
 )
 
+const graphPrefixCustodianProposal = "CUSTODIANPROPOSAL"
+
+// CustodianProposal collects the signed node extras of a new custodian
+// locally, until all nodes signed and the current custodian approves it.
+type CustodianProposal struct {
+	Custodian common.Address `json:"custodian"`
+	Nodes     [][]byte       `json:"nodes"`
+	Timestamp uint64         `json:"timestamp"`
+}
+
+func (s *BadgerStore) WriteCustodianProposal(custodian *common.Address, node []byte) (*CustodianProposal, error) {
+	cn, err := common.DecodeCustodianNode(node)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	txn := s.snapshotsDB.NewTransaction(true)
+	defer txn.Discard()
+
+	key := graphCustodianProposalKey(custodian)
+	p, err := readCustodianProposal(txn, key)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		p = &CustodianProposal{Custodian: *custodian}
+	}
+	nodes := [][]byte{node}
+	for _, n := range p.Nodes {
+		if !bytes.Equal(n[1:33], cn.Custodian.PublicSpendKey[:]) {
+			nodes = append(nodes, n)
+		}
+	}
+	p.Nodes = nodes
+	p.Timestamp = uint64(time.Now().UnixNano())
+	val, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	err = txn.Set(key, val)
+	if err != nil {
+		return nil, err
+	}
+	return p, txn.Commit()
+}
+
+func (s *BadgerStore) ListCustodianProposals() ([]*CustodianProposal, error) {
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = []byte(graphPrefixCustodianProposal)
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	var proposals []*CustodianProposal
+	for it.Seek(opts.Prefix); it.Valid(); it.Next() {
+		val, err := it.Item().ValueCopy(nil)
+		if err != nil {
+			return nil, err
+		}
+		var p CustodianProposal
+		err = json.Unmarshal(val, &p)
+		if err != nil {
+			return nil, err
+		}
+		proposals = append(proposals, &p)
+	}
+	return proposals, nil
+}
+
+func readCustodianProposal(txn *badger.Txn, key []byte) (*CustodianProposal, error) {
+	item, err := txn.Get(key)
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+	var p CustodianProposal
+	err = json.Unmarshal(val, &p)
+	return &p, err
+}
+
 func (s *BadgerStore) ListCustodianUpdates() ([]*common.CustodianUpdateRequest, error) {
 	txn := s.snapshotsDB.NewTransaction(false)
 	defer txn.Discard()
@@ -131,3 +224,7 @@ func graphCustodianAccountTimestamp(key []byte) uint64 {
 	ts := key[len(graphPrefixCustodianUpdate):]
 	return binary.BigEndian.Uint64(ts)
 }
+
+func graphCustodianProposalKey(custodian *common.Address) []byte {
+	return append([]byte(graphPrefixCustodianProposal), custodian.PublicSpendKey[:]...)
+}
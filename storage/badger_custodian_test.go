@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"os"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCustodianProposals(t *testing.T) {
+	require := require.New(t)
+	custom, err := config.Initialize("../config/config.example.toml")
+	require.Nil(err)
+
+	root, err := os.MkdirTemp("", "mixin-custodian-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	store, err := NewBadgerStore(custom, root)
+	require.Nil(err)
+	defer store.Close()
+
+	proposals, err := store.ListCustodianProposals()
+	require.Nil(err)
+	require.Len(proposals, 0)
+
+	random := func() common.Address {
+		seed := make([]byte, 64)
+		crypto.ReadRand(seed)
+		return common.NewAddressFromSeed(seed)
+	}
+	network := crypto.Blake3Hash([]byte("network"))
+	custodian := random()
+	signer, payee, node := random(), random(), random()
+	extra := common.EncodeCustodianNode(&node, &payee, &signer.PrivateSpendKey, &payee.PrivateSpendKey, &node.PrivateSpendKey, network)
+
+	invalid := append([]byte{}, extra...)
+	invalid[300] ^= 1
+	_, err = store.WriteCustodianProposal(&custodian, invalid)
+	require.NotNil(err)
+
+	p, err := store.WriteCustodianProposal(&custodian, extra)
+	require.Nil(err)
+	require.Len(p.Nodes, 1)
+	payee = random()
+	extra = common.EncodeCustodianNode(&node, &payee, &signer.PrivateSpendKey, &payee.PrivateSpendKey, &node.PrivateSpendKey, network)
+	p, err = store.WriteCustodianProposal(&custodian, extra)
+	require.Nil(err)
+	require.Len(p.Nodes, 1)
+	require.Equal(extra, p.Nodes[0])
+
+	signer, payee, node = random(), random(), random()
+	extra = common.EncodeCustodianNode(&node, &payee, &signer.PrivateSpendKey, &payee.PrivateSpendKey, &node.PrivateSpendKey, network)
+	_, err = store.WriteCustodianProposal(&custodian, extra)
+	require.Nil(err)
+	other := random()
+	_, err = store.WriteCustodianProposal(&other, extra)
+	require.Nil(err)
+
+	proposals, err = store.ListCustodianProposals()
+	require.Nil(err)
+	require.Len(proposals, 2)
+	for _, p := range proposals {
+		if p.Custodian.String() == custodian.String() {
+			require.Len(p.Nodes, 2)
+			require.Equal(extra, p.Nodes[0])
+		} else {
+			require.Equal(other.String(), p.Custodian.String())
+			require.Len(p.Nodes, 1)
+		}
+	}
+}
+
+func TestCustodianState(t *testing.T) {
+	require := require.New(t)
+	custom, err := config.Initialize("../config/config.example.toml")
+	require.Nil(err)
+
+	root, err := os.MkdirTemp("", "mixin-custodian-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	store, err := NewBadgerStore(custom, root)
+	require.Nil(err)
+	defer store.Close()
+
+	assets, err := store.ListAssetsWithBalance()
+	require.Nil(err)
+	require.Len(assets, 0)
+
+	deposit := common.NewTransactionV5(common.BitcoinAssetId)
+	deposit.AddDepositInput(&common.DepositData{
+		Chain:       common.BitcoinAssetId,
+		AssetKey:    "c6d0c728-2624-429b-8e0d-d9d19b6592fa",
+		Transaction: "e5d4f5b0ce0d2b0ab8e6f1c1bc1d7c2ba8e51d4a95e9c1bbd1d2a6c7b8e9f0a1",
+		Amount:      common.NewIntegerFromString("1.5"),
+	})
+	ver := deposit.AsVersioned()
+	txn := store.snapshotsDB.NewTransaction(true)
+	defer txn.Discard()
+	require.Nil(writeAssetInfo(txn, common.BitcoinAssetId, ver.DepositData().Asset()))
+	require.Nil(writeTotalInAsset(txn, ver))
+	require.Nil(txn.Commit())
+
+	assets, err = store.ListAssetsWithBalance()
+	require.Nil(err)
+	require.Len(assets, 1)
+	require.Equal(common.BitcoinAssetId, assets[0].Id)
+	require.Equal(common.BitcoinAssetId, assets[0].Asset.Chain)
+	require.Equal("1.50000000", assets[0].Balance.String())
+
+	update := common.NewTransactionV5(common.XINAssetId)
+	update.AddInput(crypto.Blake3Hash([]byte("input")), 0)
+	update.AddOutputWithType(common.OutputTypeCustodianUpdateNodes, nil, common.NewThresholdScript(common.Operator64), common.NewInteger(100), make([]byte, 64))
+	require.Nil(store.CachePutTransaction(ver))
+	require.Nil(store.CachePutTransaction(update.AsVersioned()))
+
+	txs, err := store.CacheListTransactions(common.TransactionTypeCustodianUpdateNodes, 10)
+	require.Nil(err)
+	require.Len(txs, 1)
+	require.Equal(update.AsVersioned().PayloadHash(), txs[0].PayloadHash())
+	txs, err = store.CacheListTransactions(common.TransactionTypeDeposit, 10)
+	require.Nil(err)
+	require.Len(txs, 1)
+	txs, err = store.CacheListTransactions(common.TransactionTypeDeposit, 0)
+	require.Nil(err)
+	require.Len(txs, 0)
+	txs, err = store.CacheRetrieveTransactions(10)
+	require.Nil(err)
+	require.Len(txs, 2)
+	txs, err = store.CacheListTransactions(common.TransactionTypeCustodianUpdateNodes, 10)
+	require.Nil(err)
+	require.Len(txs, 1)
+}
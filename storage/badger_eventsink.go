@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"encoding/binary"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+const (
+	graphPrefixEventSinkOffset = "EVENTSINKOFFSET"
+)
+
+// ReadEventSinkOffset returns the next topology to publish by the event sink.
+func (s *BadgerStore) ReadEventSinkOffset() (uint64, error) {
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	item, err := txn.Get([]byte(graphPrefixEventSinkOffset))
+	if err == badger.ErrKeyNotFound {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(val), nil
+}
+
+func (s *BadgerStore) WriteEventSinkOffset(offset uint64) error {
+	val := binary.BigEndian.AppendUint64(nil, offset)
+	return s.snapshotsDB.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte(graphPrefixEventSinkOffset), val)
+	})
+}
@@ -0,0 +1,197 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/dgraph-io/badger/v4"
+)
+
+// the deposits and withdrawals of each asset are accumulated when finalized,
+// so the supply of a bridged asset on the graph could be compared with the
+// reserve attestations of the custodian. the index is complete since the
+// topology in the since key, the graph before it is not indexed, unless the
+// index is rebuilt from the genesis.
+const (
+	graphPrefixAssetFlow      = "ASSETFLOW"
+	graphPrefixAssetFlowSince = "FLOWINDEXSINCE"
+)
+
+type AssetFlow struct {
+	Asset            crypto.Hash    `json:"asset"`
+	Deposits         common.Integer `json:"deposits"`
+	DepositsCount    uint64         `json:"deposits_count"`
+	Withdrawals      common.Integer `json:"withdrawals"`
+	WithdrawalsCount uint64         `json:"withdrawals_count"`
+	Topology         uint64         `json:"topology"`
+}
+
+func (s *BadgerStore) ListAssetFlows() ([]*AssetFlow, error) {
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = []byte(graphPrefixAssetFlow)
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	var flows []*AssetFlow
+	for it.Seek(opts.Prefix); it.Valid(); it.Next() {
+		val, err := it.Item().ValueCopy(nil)
+		if err != nil {
+			return nil, err
+		}
+		var f AssetFlow
+		err = json.Unmarshal(val, &f)
+		if err != nil {
+			return nil, err
+		}
+		flows = append(flows, &f)
+	}
+	return flows, nil
+}
+
+// ReadAssetFlowSince returns the topology since which the index is complete,
+// and false if nothing indexed yet.
+func (s *BadgerStore) ReadAssetFlowSince() (uint64, bool, error) {
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	return readAssetFlowSince(txn)
+}
+
+// RebuildAssetFlows indexes the snapshots since the offset, and the index is
+// reset at the offset 0, returns the next topology. The kernel must not run
+// during the rebuild.
+func (s *BadgerStore) RebuildAssetFlows(offset, count uint64) (uint64, error) {
+	if offset == 0 {
+		_, err := s.RemoveGraphEntries(graphPrefixAssetFlow)
+		if err != nil {
+			return offset, err
+		}
+		_, err = s.RemoveGraphEntries(graphPrefixAssetFlowSince)
+		if err != nil {
+			return offset, err
+		}
+	}
+	snapshots, txs, err := s.ReadSnapshotWithTransactionsSinceTopology(offset, count)
+	if err != nil {
+		return offset, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	txn := s.snapshotsDB.NewTransaction(true)
+	defer txn.Discard()
+
+	for i, snap := range snapshots {
+		item, err := txn.Get(graphFinalizationKey(txs[i].PayloadHash()))
+		if err != nil {
+			return offset, err
+		}
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return offset, err
+		}
+		if hash := snap.PayloadHash(); !bytes.Equal(val, hash[:]) {
+			continue
+		}
+		err = writeAssetFlow(txn, txs[i], snap.TopologicalOrder)
+		if err != nil {
+			return offset, err
+		}
+	}
+	if uint64(len(snapshots)) < count {
+		err = writeAssetFlowSince(txn, 0)
+		if err != nil {
+			return offset, err
+		}
+	}
+	err = txn.Commit()
+	if err != nil || len(snapshots) == 0 {
+		return offset, err
+	}
+	return snapshots[len(snapshots)-1].TopologicalOrder + 1, nil
+}
+
+func writeAssetFlow(txn *badger.Txn, ver *common.VersionedTransaction, topology uint64) error {
+	var deposit, withdrawal common.Integer
+	switch ver.TransactionType() {
+	case common.TransactionTypeDeposit:
+		deposit = ver.DepositData().Amount
+	case common.TransactionTypeWithdrawalSubmit:
+		for _, o := range ver.Outputs {
+			if o.Type == common.OutputTypeWithdrawalSubmit {
+				withdrawal = withdrawal.Add(o.Amount)
+			}
+		}
+	default:
+		return nil
+	}
+
+	key := graphAssetFlowKey(ver.Asset)
+	f := &AssetFlow{Asset: ver.Asset}
+	item, err := txn.Get(key)
+	if err == nil {
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		err = json.Unmarshal(val, f)
+		if err != nil {
+			return err
+		}
+	} else if err != badger.ErrKeyNotFound {
+		return err
+	}
+	if deposit.Sign() > 0 {
+		f.Deposits = f.Deposits.Add(deposit)
+		f.DepositsCount += 1
+	}
+	if withdrawal.Sign() > 0 {
+		f.Withdrawals = f.Withdrawals.Add(withdrawal)
+		f.WithdrawalsCount += 1
+	}
+	f.Topology = topology
+	val, err := json.Marshal(f)
+	if err != nil {
+		panic(err)
+	}
+	err = txn.Set(key, val)
+	if err != nil {
+		return err
+	}
+
+	_, found, err := readAssetFlowSince(txn)
+	if err != nil || found {
+		return err
+	}
+	return writeAssetFlowSince(txn, topology)
+}
+
+func readAssetFlowSince(txn *badger.Txn) (uint64, bool, error) {
+	item, err := txn.Get([]byte(graphPrefixAssetFlowSince))
+	if err == badger.ErrKeyNotFound {
+		return 0, false, nil
+	} else if err != nil {
+		return 0, false, err
+	}
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return 0, false, err
+	}
+	return binary.BigEndian.Uint64(val), true, nil
+}
+
+func writeAssetFlowSince(txn *badger.Txn, topology uint64) error {
+	val := binary.BigEndian.AppendUint64(nil, topology)
+	return txn.Set([]byte(graphPrefixAssetFlowSince), val)
+}
+
+func graphAssetFlowKey(id crypto.Hash) []byte {
+	return append([]byte(graphPrefixAssetFlow), id[:]...)
+}
@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"os"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssetFlow(t *testing.T) {
+	require := require.New(t)
+	custom, err := config.Initialize("../config/config.example.toml")
+	require.Nil(err)
+
+	root, err := os.MkdirTemp("", "mixin-flow-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	store, err := NewBadgerStore(custom, root)
+	require.Nil(err)
+	defer store.Close()
+
+	flows, err := store.ListAssetFlows()
+	require.Nil(err)
+	require.Len(flows, 0)
+	_, found, err := store.ReadAssetFlowSince()
+	require.Nil(err)
+	require.False(found)
+
+	deposit := common.NewTransactionV5(common.BitcoinAssetId)
+	deposit.AddDepositInput(&common.DepositData{
+		Chain:       common.BitcoinAssetId,
+		AssetKey:    "c6d0c728-2624-429b-8e0d-d9d19b6592fa",
+		Transaction: "e5d4f5b0ce0d2b0ab8e6f1c1bc1d7c2ba8e51d4a95e9c1bbd1d2a6c7b8e9f0a1",
+		Amount:      common.NewIntegerFromString("1.5"),
+	})
+	withdrawal := common.NewTransactionV5(common.BitcoinAssetId)
+	withdrawal.AddInput(crypto.Blake3Hash([]byte("input")), 0)
+	withdrawal.AddOutputWithType(common.OutputTypeWithdrawalSubmit, nil, common.NewThresholdScript(common.Operator64), common.NewIntegerFromString("0.4"), make([]byte, 64))
+	transfer := common.NewTransactionV5(common.BitcoinAssetId)
+	transfer.AddInput(crypto.Blake3Hash([]byte("input")), 1)
+	transfer.AddOutputWithType(common.OutputTypeScript, nil, common.NewThresholdScript(common.Operator64), common.NewIntegerFromString("0.1"), make([]byte, 64))
+
+	txn := store.snapshotsDB.NewTransaction(true)
+	defer txn.Discard()
+	require.Nil(writeAssetFlow(txn, deposit.AsVersioned(), 7))
+	require.Nil(writeAssetFlow(txn, withdrawal.AsVersioned(), 8))
+	require.Nil(writeAssetFlow(txn, transfer.AsVersioned(), 9))
+	require.Nil(writeAssetFlow(txn, deposit.AsVersioned(), 10))
+	require.Nil(txn.Commit())
+
+	flows, err = store.ListAssetFlows()
+	require.Nil(err)
+	require.Len(flows, 1)
+	require.Equal(common.BitcoinAssetId, flows[0].Asset)
+	require.Equal("3.00000000", flows[0].Deposits.String())
+	require.Equal(uint64(2), flows[0].DepositsCount)
+	require.Equal("0.40000000", flows[0].Withdrawals.String())
+	require.Equal(uint64(1), flows[0].WithdrawalsCount)
+	require.Equal(uint64(10), flows[0].Topology)
+	since, found, err := store.ReadAssetFlowSince()
+	require.Nil(err)
+	require.True(found)
+	require.Equal(uint64(7), since)
+
+	next, err := store.RebuildAssetFlows(0, 100)
+	require.Nil(err)
+	require.Equal(uint64(0), next)
+	flows, err = store.ListAssetFlows()
+	require.Nil(err)
+	require.Len(flows, 0)
+	since, found, err = store.ReadAssetFlowSince()
+	require.Nil(err)
+	require.True(found)
+	require.Equal(uint64(0), since)
+}
@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/dgraph-io/badger/v4"
+)
+
+// the sync frontier and the snapshots pulled but not finalized yet are kept
+// in the cache, with the same ttl as the cached transactions, so the node
+// restarted during the initial sync doesn't download them again, and they
+// expire after the node is down for too long.
+const (
+	cachePrefixSyncFrontier = "CACHESYNCFRONTIER"
+	cachePrefixSyncSnapshot = "CACHESYNCSNAPSHOT"
+)
+
+func (s *BadgerStore) CacheWriteSyncFrontier(nodeId crypto.Hash, requested, verified uint64, snapshots []*common.Snapshot) error {
+	ttl := time.Duration(s.custom.Node.CacheTTL) * time.Second
+	return s.cacheDB.Update(func(txn *badger.Txn) error {
+		for _, snap := range snapshots {
+			if snap.NodeId != nodeId || snap.RoundNumber > verified {
+				panic(fmt.Errorf("invalid sync snapshot %s %d %s %d", snap.NodeId, snap.RoundNumber, nodeId, verified))
+			}
+			key := cacheSyncSnapshotKey(nodeId, snap.RoundNumber, snap.Hash)
+			etr := badger.NewEntry(key, snap.VersionedMarshal()).WithTTL(ttl)
+			err := txn.SetEntry(etr)
+			if err != nil {
+				return err
+			}
+		}
+		val := binary.BigEndian.AppendUint64(nil, requested)
+		val = binary.BigEndian.AppendUint64(val, verified)
+		etr := badger.NewEntry(cacheSyncFrontierKey(nodeId), val).WithTTL(ttl)
+		return txn.SetEntry(etr)
+	})
+}
+
+func (s *BadgerStore) CacheReadSyncFrontier(nodeId crypto.Hash) (uint64, uint64, error) {
+	txn := s.cacheDB.NewTransaction(false)
+	defer txn.Discard()
+
+	item, err := txn.Get(cacheSyncFrontierKey(nodeId))
+	if err == badger.ErrKeyNotFound {
+		return 0, 0, nil
+	} else if err != nil {
+		return 0, 0, err
+	}
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	return binary.BigEndian.Uint64(val[:8]), binary.BigEndian.Uint64(val[8:]), nil
+}
+
+func (s *BadgerStore) CacheReadSyncSnapshots(nodeId crypto.Hash, round uint64) ([]*common.Snapshot, error) {
+	txn := s.cacheDB.NewTransaction(false)
+	defer txn.Discard()
+
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = cacheSyncRoundPrefix(nodeId, round)
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	var snapshots []*common.Snapshot
+	for it.Seek(opts.Prefix); it.Valid(); it.Next() {
+		val, err := it.Item().ValueCopy(nil)
+		if err != nil {
+			return nil, err
+		}
+		snap, err := common.UnmarshalVersionedSnapshot(val)
+		if err != nil {
+			return nil, err
+		}
+		snap.Hash = snap.PayloadHash()
+		snapshots = append(snapshots, snap.Snapshot)
+	}
+	return snapshots, nil
+}
+
+func cacheSyncFrontierKey(nodeId crypto.Hash) []byte {
+	return append([]byte(cachePrefixSyncFrontier), nodeId[:]...)
+}
+
+func cacheSyncRoundPrefix(nodeId crypto.Hash, round uint64) []byte {
+	key := append([]byte(cachePrefixSyncSnapshot), nodeId[:]...)
+	return binary.BigEndian.AppendUint64(key, round)
+}
+
+func cacheSyncSnapshotKey(nodeId crypto.Hash, round uint64, hash crypto.Hash) []byte {
+	return append(cacheSyncRoundPrefix(nodeId, round), hash[:]...)
+}
@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"os"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncFrontier(t *testing.T) {
+	require := require.New(t)
+	custom, err := config.Initialize("../config/config.example.toml")
+	require.Nil(err)
+
+	root, err := os.MkdirTemp("", "mixin-frontier-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	store, err := NewBadgerStore(custom, root)
+	require.Nil(err)
+	defer store.Close()
+
+	a := crypto.Blake3Hash([]byte("chain-a"))
+	b := crypto.Blake3Hash([]byte("chain-b"))
+	requested, verified, err := store.CacheReadSyncFrontier(a)
+	require.Nil(err)
+	require.Equal(uint64(0), requested)
+	require.Equal(uint64(0), verified)
+
+	var snapshots []*common.Snapshot
+	for n := range uint64(3) {
+		for i := range 2 {
+			s := &common.Snapshot{
+				Version:      common.SnapshotVersionCommonEncoding,
+				NodeId:       a,
+				RoundNumber:  11 + n,
+				Transactions: []crypto.Hash{crypto.Blake3Hash([]byte{byte(n), byte(i)})},
+				Timestamp:    n*10 + uint64(i),
+			}
+			s.Hash = s.PayloadHash()
+			snapshots = append(snapshots, s)
+		}
+	}
+	err = store.CacheWriteSyncFrontier(a, 110, 0, nil)
+	require.Nil(err)
+	err = store.CacheWriteSyncFrontier(a, 110, 13, snapshots)
+	require.Nil(err)
+	err = store.CacheWriteSyncFrontier(b, 50, 0, nil)
+	require.Nil(err)
+
+	requested, verified, err = store.CacheReadSyncFrontier(a)
+	require.Nil(err)
+	require.Equal(uint64(110), requested)
+	require.Equal(uint64(13), verified)
+	requested, verified, err = store.CacheReadSyncFrontier(b)
+	require.Nil(err)
+	require.Equal(uint64(50), requested)
+	require.Equal(uint64(0), verified)
+
+	ss, err := store.CacheReadSyncSnapshots(a, 12)
+	require.Nil(err)
+	require.Len(ss, 2)
+	hashes := []crypto.Hash{snapshots[2].Hash, snapshots[3].Hash}
+	require.ElementsMatch(hashes, []crypto.Hash{ss[0].Hash, ss[1].Hash})
+	ss, err = store.CacheReadSyncSnapshots(a, 14)
+	require.Nil(err)
+	require.Len(ss, 0)
+	ss, err = store.CacheReadSyncSnapshots(b, 12)
+	require.Nil(err)
+	require.Len(ss, 0)
+
+	require.Panics(func() { store.CacheWriteSyncFrontier(b, 50, 12, snapshots[:1]) })
+	require.Panics(func() { store.CacheWriteSyncFrontier(a, 50, 10, snapshots[:1]) })
+}
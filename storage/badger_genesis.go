@@ -41,6 +41,14 @@ func (s *BadgerStore) LoadGenesis(rounds []*common.Round, snapshots []*common.Sn
 			return err
 		}
 	}
+	err = writeAssetFlowSince(txn, 0)
+	if err != nil {
+		return err
+	}
+	err = writeStateIndexSince(txn, 0)
+	if err != nil {
+		return err
+	}
 
 	return txn.Commit()
 }
@@ -17,6 +17,7 @@ const (
 	graphPrefixUTXO            = "UTXO"  // unspent outputs, including first consumed transaction hash
 	graphPrefixDeposit         = "DEPOSIT"
 	graphPrefixWithdrawal      = "WITHDRAWAL"
+	graphPrefixPendingClaim    = "PENDINGCLAIM" // finalized withdrawal submissions not claimed yet
 	graphPrefixMint            = "MINTUNIVERSAL"
 	graphPrefixTransaction     = "TRANSACTION"  // raw transaction, may not be finalized yet, if finalized with first finalized snapshot hash
 	graphPrefixFinalization    = "FINALIZATION" // transaction finalization hack
@@ -35,6 +36,8 @@ const (
 	graphPrefixAssetInfo       = "ASSETINFO"
 	graphPrefixAssetTotal      = "ASSETTOTAL"
 	graphPrefixCustodianUpdate = "CUSTODIANUPDATE"
+	graphPrefixTimeTopology    = "TIMETOPOLOGY"
+	graphPrefixTimeRound       = "TIMEROUND"
 )
 
 func (s *BadgerStore) RemoveGraphEntries(prefix string) (int, error) {
@@ -150,6 +153,14 @@ func (s *BadgerStore) WriteSnapshot(snap *common.SnapshotWithTopologicalOrder, s
 	if err != nil {
 		return err
 	}
+	err = s.writeWalletOutputs(txn, snap, ver)
+	if err != nil {
+		return err
+	}
+	err = s.writeWebhookDeliveries(txn, snap, ver)
+	if err != nil {
+		return err
+	}
 	err = writeSnapshotWork(txn, snap, signers)
 	if err != nil {
 		return err
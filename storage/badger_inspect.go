@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"bytes"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+type DatabaseStat struct {
+	DB     string
+	LSM    int64
+	VLog   int64
+	Tables int
+	Levels []int64
+}
+
+type KeyPrefixStat struct {
+	DB     string
+	Prefix string
+	Keys   int
+	Size   int64
+}
+
+var (
+	graphKeyPrefixes = []string{
+		graphPrefixGhost, graphPrefixUTXO, graphPrefixDeposit, graphPrefixWithdrawal,
+		graphPrefixPendingClaim, graphPrefixMint, graphPrefixTransaction, graphPrefixFinalization,
+		graphPrefixUnique, graphPrefixRound, graphPrefixSnapshot, graphPrefixLink,
+		graphPrefixTopology, graphPrefixSnapTopology, graphPrefixWorkLead, graphPrefixWorkSign,
+		graphPrefixWorkOffset, graphPrefixWorkSnapshot, graphPrefixSpaceCheckpoint, graphPrefixSpaceQueue,
+		graphPrefixAssetInfo, graphPrefixAssetTotal, graphPrefixCustodianUpdate, graphPrefixTimeTopology,
+		graphPrefixTimeRound, graphPrefixNodeStateQueue, graphPrefixNodeOperation, graphPrefixCustodianProposal,
+		graphPrefixAuditEntry, graphPrefixWalletAccount, graphPrefixWalletUTXO, graphPrefixWalletOwner,
+		graphPrefixWalletSequence, graphPrefixWalletSubaddress, graphPrefixWebhook, graphPrefixWebhookDelivery,
+		graphPrefixEventSinkOffset, graphPrefixAssetFlow, graphPrefixAssetFlowSince, graphPrefixStateDelta,
+		graphPrefixStateMoved, graphPrefixStateCheckpoint, graphPrefixStateSince,
+	}
+	cacheKeyPrefixes = []string{
+		cachePrefixTransactionQueue, cachePrefixTransactionOrder, cachePrefixTransactionCache,
+	}
+)
+
+// ReadDatabaseStats reads the sizes from the badger manifest without any
+// iteration, so it's cheap even for a node with a huge graph.
+func (s *BadgerStore) ReadDatabaseStats() []*DatabaseStat {
+	return []*DatabaseStat{
+		readDatabaseStat(s.snapshotsDB, "snapshots"),
+		readDatabaseStat(s.cacheDB, "cache"),
+	}
+}
+
+func readDatabaseStat(db *badger.DB, name string) *DatabaseStat {
+	lsm, vlog := db.Size()
+	stat := &DatabaseStat{DB: name, LSM: lsm, VLog: vlog, Tables: len(db.Tables())}
+	for _, l := range db.Levels() {
+		stat.Levels = append(stat.Levels, l.Size)
+	}
+	return stat
+}
+
+// ListKeyPrefixes counts the keys and the value sizes of each known key prefix
+// in the snapshots and cache databases, the keys without a known prefix are
+// counted with an empty prefix.
+func (s *BadgerStore) ListKeyPrefixes() ([]*KeyPrefixStat, error) {
+	graph, err := listKeyPrefixes(s.snapshotsDB, "snapshots", graphKeyPrefixes)
+	if err != nil {
+		return nil, err
+	}
+	cache, err := listKeyPrefixes(s.cacheDB, "cache", cacheKeyPrefixes)
+	if err != nil {
+		return nil, err
+	}
+	return append(graph, cache...), nil
+}
+
+func listKeyPrefixes(db *badger.DB, name string, prefixes []string) ([]*KeyPrefixStat, error) {
+	stats := make(map[string]*KeyPrefixStat)
+	err := db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+			prefix := matchKeyPrefix(item.Key(), prefixes)
+			stat := stats[prefix]
+			if stat == nil {
+				stat = &KeyPrefixStat{DB: name, Prefix: prefix}
+				stats[prefix] = stat
+			}
+			stat.Keys += 1
+			stat.Size += item.ValueSize()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var list []*KeyPrefixStat
+	for _, p := range append(prefixes, "") {
+		if stat := stats[p]; stat != nil {
+			list = append(list, stat)
+		}
+	}
+	return list, nil
+}
+
+// a new prefix may begin with an old one, so the longest match wins
+func matchKeyPrefix(key []byte, prefixes []string) string {
+	var match string
+	for _, p := range prefixes {
+		if len(p) > len(match) && bytes.HasPrefix(key, []byte(p)) {
+			match = p
+		}
+	}
+	return match
+}
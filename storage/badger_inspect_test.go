@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"os"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInspectStore(t *testing.T) {
+	require := require.New(t)
+
+	custom, err := config.Initialize("../config/config.example.toml")
+	require.Nil(err)
+
+	root, err := os.MkdirTemp("", "mixin-badger-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	store, err := NewBadgerStore(custom, root)
+	require.Nil(err)
+	defer store.Close()
+
+	gns, err := common.ReadGenesis("../config/genesis.json")
+	require.Nil(err)
+	rounds, snapshots, transactions, err := gns.BuildSnapshots()
+	require.Nil(err)
+	err = store.LoadGenesis(rounds, snapshots, transactions)
+	require.Nil(err)
+
+	stats, err := store.ListKeyPrefixes()
+	require.Nil(err)
+	counts := make(map[string]int)
+	for _, s := range stats {
+		require.Equal("snapshots", s.DB)
+		counts[s.Prefix] = s.Keys
+	}
+	require.Equal(len(snapshots), counts[graphPrefixSnapshot])
+	require.Equal(len(snapshots), counts[graphPrefixTopology])
+	require.Equal(len(transactions), counts[graphPrefixTransaction])
+	require.Equal(0, counts[""])
+	require.Equal(graphPrefixUTXO, matchKeyPrefix([]byte("UTXO0000"), graphKeyPrefixes))
+	require.Equal(graphPrefixWalletUTXO, matchKeyPrefix([]byte("WALLETUTXO0000"), graphKeyPrefixes))
+	require.Equal("", matchKeyPrefix([]byte("UTX"), graphKeyPrefixes))
+
+	err = store.CachePutTransaction(transactions[0])
+	require.Nil(err)
+	stats, err = store.ListKeyPrefixes()
+	require.Nil(err)
+	last := stats[len(stats)-1]
+	require.Equal("cache", last.DB)
+	require.Greater(last.Keys, 0)
+
+	dbs := store.ReadDatabaseStats()
+	require.Len(dbs, 2)
+	require.Equal("snapshots", dbs[0].DB)
+	require.Equal("cache", dbs[1].DB)
+	require.Equal(custom.Storage.MaxCompactionLevels, len(dbs[0].Levels))
+
+	snap := snapshots[0]
+	replay, err := store.VerifyGraphChainRange(snap.NodeId, 0, 0)
+	require.Nil(err)
+	require.Equal(uint64(1), replay.Rounds)
+	require.Equal(0, replay.Invalid)
+	_, err = store.VerifyGraphChainRange(snap.NodeId, 0, 1)
+	require.NotNil(err)
+	_, err = store.VerifyGraphChainRange(snap.NodeId, 1, 0)
+	require.NotNil(err)
+
+	txn := store.snapshotsDB.NewTransaction(true)
+	require.Nil(txn.Delete(graphRoundKey(rounds[0].Hash)))
+	require.Nil(txn.Commit())
+	replay, err = store.VerifyGraphChainRange(rounds[0].NodeId, 0, 0)
+	require.Nil(err)
+	require.Equal(1, replay.Invalid)
+}
@@ -0,0 +1,19 @@
+package storage
+
+import (
+	"github.com/MixinNetwork/mixin/config"
+)
+
+// NewMemoryStore is the badger store without any directory, all the data are
+// kept in memory and dropped on close, so the tests need no temp directories.
+func NewMemoryStore(custom *config.Custom) (*BadgerStore, error) {
+	snapshotsDB, err := openDB("", true, custom)
+	if err != nil {
+		return nil, err
+	}
+	cacheDB, err := openDB("", false, custom)
+	if err != nil {
+		return nil, err
+	}
+	return newBadgerStore(custom, snapshotsDB, cacheDB)
+}
@@ -15,6 +15,7 @@ import (
 const (
 	graphPrefixNodeStateQueue = "NODESTATEQUEUE"
 	graphPrefixNodeOperation  = "NODEOPERATION"
+	graphPrefixNodePayee      = "NODEPAYEE"
 )
 
 func readAllNodes(txn *badger.Txn, threshold uint64, withState bool) []*common.Node {
@@ -50,8 +51,12 @@ func readAllNodes(txn *badger.Txn, threshold uint64, withState bool) []*common.N
 	}
 
 	filter := make(map[crypto.Hash]*common.Node)
+	pledges := make(map[crypto.Hash]uint64)
 	for i, n := range nodes {
 		filter[n.Signer.Hash()] = n
+		if n.State == common.NodeStatePledging {
+			pledges[n.Signer.Hash()] = n.Timestamp
+		}
 		if i == 0 {
 			continue
 		}
@@ -63,6 +68,11 @@ func readAllNodes(txn *badger.Txn, threshold uint64, withState bool) []*common.N
 	if withState {
 		return nodes
 	}
+	for _, u := range readNodePayeeUpdates(txn, threshold) {
+		if n := filter[u.Signer.Hash()]; n != nil && u.Timestamp > pledges[n.Signer.Hash()] {
+			n.Payee = u.Payee
+		}
+	}
 	nodes = make([]*common.Node, 0)
 	for _, n := range filter {
 		nodes = append(nodes, n)
@@ -80,6 +90,64 @@ func (s *BadgerStore) ReadAllNodes(threshold uint64, withState bool) []*common.N
 	return readAllNodes(txn, threshold, withState)
 }
 
+// the node states with history keep the payee of the state transactions, and
+// the payee updates apply only to the latest states without history.
+func readNodePayeeUpdates(txn *badger.Txn, threshold uint64) []*common.NodePayeeUpdate {
+	prefix := []byte(graphPrefixNodePayee)
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = prefix
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	var updates []*common.NodePayeeUpdate
+	for it.Seek(prefix); it.Valid(); it.Next() {
+		item := it.Item()
+		key := item.KeyCopy(nil)[len(prefix):]
+		ts := binary.BigEndian.Uint64(key[:8])
+		if ts > threshold {
+			break
+		}
+		ival, err := item.ValueCopy(nil)
+		if err != nil {
+			panic(err)
+		}
+		signer, _ := nodeSignerFromStateKey(append([]byte(graphPrefixNodeStateQueue), key...))
+		updates = append(updates, &common.NodePayeeUpdate{
+			Signer:      signer,
+			Payee:       nodePayee(ival),
+			Transaction: nodeTransaction(ival),
+			Timestamp:   ts,
+		})
+	}
+	return updates
+}
+
+func (s *BadgerStore) ReadNodePayeeUpdates(threshold uint64) []*common.NodePayeeUpdate {
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	return readNodePayeeUpdates(txn, threshold)
+}
+
+func writeNodePayeeChange(txn *badger.Txn, change *common.NodePayeeChange, tx crypto.Hash, timestamp uint64) error {
+	var node *common.Node
+	for _, n := range readAllNodes(txn, timestamp, false) {
+		if n.Signer.PublicSpendKey == change.Signer {
+			node = n
+		}
+	}
+	if node == nil || node.State != common.NodeStateAccepted {
+		return fmt.Errorf("node not available to change payee %s", change.Signer)
+	}
+	if node.Payee.PublicSpendKey != change.OldPayee {
+		return fmt.Errorf("node %s payee %s not match %s", change.Signer, node.Payee.PublicSpendKey, change.OldPayee)
+	}
+
+	key := nodePayeeKey(change.Signer, timestamp)
+	val := append(change.NewPayee[:], tx[:]...)
+	return txn.Set(key, val)
+}
+
 func (s *BadgerStore) AddNodeOperation(tx *common.VersionedTransaction, timestamp, threshold uint64) error {
 	txn := s.snapshotsDB.NewTransaction(true)
 	defer txn.Discard()
@@ -244,6 +312,12 @@ func nodeStateQueueKey(signer crypto.Key, timestamp uint64) []byte {
 	return append(key, signer[:]...)
 }
 
+func nodePayeeKey(signer crypto.Key, timestamp uint64) []byte {
+	key := []byte(graphPrefixNodePayee)
+	key = binary.BigEndian.AppendUint64(key, timestamp)
+	return append(key, signer[:]...)
+}
+
 func nodeEntryValue(payee crypto.Key, tx crypto.Hash, state string) []byte {
 	val := append(payee[:], tx[:]...)
 	return append(val, []byte(state)...)
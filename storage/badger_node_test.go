@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"os"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/dgraph-io/badger/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNodePayeeChange(t *testing.T) {
+	require := require.New(t)
+	custom, err := config.Initialize("../config/config.example.toml")
+	require.Nil(err)
+
+	root, err := os.MkdirTemp("", "mixin-badger-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	store, err := NewBadgerStore(custom, root)
+	require.Nil(err)
+	require.NotNil(store)
+	defer store.Close()
+
+	signer := testNodeKey("signer")
+	oldPayee, newPayee := testNodeKey("old-payee"), testNodeKey("new-payee")
+	accepted := uint64(1000)
+	err = store.snapshotsDB.Update(func(txn *badger.Txn) error {
+		key := nodeStateQueueKey(signer, accepted)
+		val := nodeEntryValue(oldPayee, crypto.Blake3Hash([]byte("accept")), common.NodeStateAccepted)
+		return txn.Set(key, val)
+	})
+	require.Nil(err)
+
+	change := &common.NodePayeeChange{Signer: signer, OldPayee: newPayee, NewPayee: oldPayee}
+	tx := crypto.Blake3Hash([]byte("change"))
+	err = store.snapshotsDB.Update(func(txn *badger.Txn) error {
+		return writeNodePayeeChange(txn, change, tx, accepted+10)
+	})
+	require.ErrorContains(err, "not match")
+
+	change = &common.NodePayeeChange{Signer: signer, OldPayee: oldPayee, NewPayee: newPayee}
+	err = store.snapshotsDB.Update(func(txn *badger.Txn) error {
+		return writeNodePayeeChange(txn, change, tx, accepted+10)
+	})
+	require.Nil(err)
+
+	updates := store.ReadNodePayeeUpdates(accepted + 9)
+	require.Len(updates, 0)
+	updates = store.ReadNodePayeeUpdates(accepted + 10)
+	require.Len(updates, 1)
+	require.Equal(signer, updates[0].Signer.PublicSpendKey)
+	require.Equal(newPayee, updates[0].Payee.PublicSpendKey)
+	require.Equal(tx, updates[0].Transaction)
+	require.Equal(accepted+10, updates[0].Timestamp)
+
+	nodes := store.ReadAllNodes(accepted+9, false)
+	require.Len(nodes, 1)
+	require.Equal(oldPayee, nodes[0].Payee.PublicSpendKey)
+	nodes = store.ReadAllNodes(accepted+10, false)
+	require.Len(nodes, 1)
+	require.Equal(newPayee, nodes[0].Payee.PublicSpendKey)
+	nodes = store.ReadAllNodes(accepted+10, true)
+	require.Len(nodes, 1)
+	require.Equal(oldPayee, nodes[0].Payee.PublicSpendKey)
+
+	err = store.snapshotsDB.Update(func(txn *badger.Txn) error {
+		return writeNodePayeeChange(txn, change, tx, accepted+20)
+	})
+	require.ErrorContains(err, "not match")
+}
+
+func testNodeKey(seed string) crypto.Key {
+	h := crypto.Blake3Hash([]byte(seed))
+	return crypto.NewKeyFromSeed(append(h[:], h[:]...)).Public()
+}
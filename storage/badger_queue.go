@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/dgraph-io/badger/v4"
+)
+
+// the final snapshots queued by a chain but not finalized yet are kept in the
+// cache when the node stops, with the same ttl as the cached transactions,
+// so the node restarted queues them again instead of waiting for the peers
+// to gossip them again. they are not trusted and verified again when final.
+const cachePrefixChainQueue = "CACHECHAINQUEUE"
+
+type QueuedSnapshot struct {
+	PeerId   crypto.Hash
+	Snapshot *common.Snapshot
+}
+
+// CacheWriteChainQueue replaces all the queued snapshots of the chain, and
+// an empty queue removes them.
+func (s *BadgerStore) CacheWriteChainQueue(chainId crypto.Hash, snapshots []*QueuedSnapshot) error {
+	ttl := time.Duration(s.custom.Node.CacheTTL) * time.Second
+	return s.cacheDB.Update(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = cacheChainQueuePrefix(chainId)
+		it := txn.NewIterator(opts)
+		var keys [][]byte
+		for it.Seek(opts.Prefix); it.Valid(); it.Next() {
+			keys = append(keys, it.Item().KeyCopy(nil))
+		}
+		it.Close()
+		for _, k := range keys {
+			err := txn.Delete(k)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, qs := range snapshots {
+			snap := qs.Snapshot
+			if snap.NodeId != chainId {
+				panic(fmt.Errorf("invalid queued snapshot %s %s", snap.NodeId, chainId))
+			}
+			key := cacheChainQueueKey(chainId, snap.RoundNumber, snap.Hash)
+			val := append(qs.PeerId[:], snap.VersionedMarshal()...)
+			err := txn.SetEntry(badger.NewEntry(key, val).WithTTL(ttl))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// CacheReadChainQueue returns the queued snapshots of the chain ordered by
+// the round number.
+func (s *BadgerStore) CacheReadChainQueue(chainId crypto.Hash) ([]*QueuedSnapshot, error) {
+	txn := s.cacheDB.NewTransaction(false)
+	defer txn.Discard()
+
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = cacheChainQueuePrefix(chainId)
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	var snapshots []*QueuedSnapshot
+	for it.Seek(opts.Prefix); it.Valid(); it.Next() {
+		val, err := it.Item().ValueCopy(nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(val) < 32 {
+			return nil, fmt.Errorf("invalid queued snapshot %x", it.Item().Key())
+		}
+		snap, err := common.UnmarshalVersionedSnapshot(val[32:])
+		if err != nil {
+			return nil, err
+		}
+		snap.Hash = snap.PayloadHash()
+		qs := &QueuedSnapshot{Snapshot: snap.Snapshot}
+		copy(qs.PeerId[:], val[:32])
+		snapshots = append(snapshots, qs)
+	}
+	return snapshots, nil
+}
+
+func cacheChainQueuePrefix(chainId crypto.Hash) []byte {
+	return append([]byte(cachePrefixChainQueue), chainId[:]...)
+}
+
+func cacheChainQueueKey(chainId crypto.Hash, round uint64, hash crypto.Hash) []byte {
+	key := binary.BigEndian.AppendUint64(cacheChainQueuePrefix(chainId), round)
+	return append(key, hash[:]...)
+}
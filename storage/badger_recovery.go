@@ -0,0 +1,239 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/dgraph-io/badger/v4"
+)
+
+const (
+	RecoveryTopologyDepth = 16384
+
+	RecoveryIssueOrphanTopology  = "orphan-topology"
+	RecoveryIssueOrphanUTXOLock  = "orphan-utxo-lock"
+	RecoveryIssueOrphanGhostLock = "orphan-ghost-lock"
+)
+
+type RecoveryIssue struct {
+	Kind   string
+	Key    []byte
+	Detail string
+}
+
+// the kernel writes a snapshot in several badger transactions, the inputs
+// are locked before the transaction body is persisted, so a power loss may
+// leave the locks without the transaction, or the topology entries without
+// the snapshot or transaction bodies at the tail of the graph.
+func (s *BadgerStore) CheckRecovery() ([]*RecoveryIssue, error) {
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	issues, err := checkOrphanTopologies(txn, RecoveryTopologyDepth)
+	if err != nil {
+		return nil, err
+	}
+	locks, err := checkOrphanUTXOLocks(txn)
+	if err != nil {
+		return nil, err
+	}
+	ghosts, err := checkOrphanGhostLocks(txn)
+	if err != nil {
+		return nil, err
+	}
+	issues = append(issues, locks...)
+	return append(issues, ghosts...), nil
+}
+
+func (s *BadgerStore) RepairRecovery(issues []*RecoveryIssue) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	txn := s.snapshotsDB.NewTransaction(true)
+	defer txn.Discard()
+
+	for _, i := range issues {
+		logger.Printf("BadgerStore.RepairRecovery(%s) %s\n", i.Kind, i.Detail)
+		var err error
+		switch i.Kind {
+		case RecoveryIssueOrphanTopology:
+			err = repairOrphanTopology(txn, i.Key)
+		case RecoveryIssueOrphanUTXOLock:
+			err = repairOrphanUTXOLock(txn, i.Key)
+		case RecoveryIssueOrphanGhostLock:
+			err = txn.Delete(i.Key)
+		default:
+			err = fmt.Errorf("invalid recovery issue %s", i.Kind)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return txn.Commit()
+}
+
+func checkOrphanTopologies(txn *badger.Txn, depth int) ([]*RecoveryIssue, error) {
+	var issues []*RecoveryIssue
+
+	opts := badger.DefaultIteratorOptions
+	opts.Reverse = true
+	opts.Prefix = []byte(graphPrefixTopology)
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	it.Seek(graphTopologyKey(^uint64(0)))
+	for ; it.Valid() && depth > 0; it.Next() {
+		depth--
+		key := it.Item().KeyCopy(nil)
+		val, err := it.Item().ValueCopy(nil)
+		if err != nil {
+			return nil, err
+		}
+		order := graphTopologyOrder(key)
+		if len(val) != len(graphSnapshotKey(crypto.Hash{}, 0, crypto.Hash{})) {
+			issues = append(issues, &RecoveryIssue{
+				Kind:   RecoveryIssueOrphanTopology,
+				Key:    key,
+				Detail: fmt.Sprintf("topology %d malformed snapshot key", order),
+			})
+			continue
+		}
+		_, err = txn.Get(val)
+		if err == badger.ErrKeyNotFound {
+			issues = append(issues, &RecoveryIssue{
+				Kind:   RecoveryIssueOrphanTopology,
+				Key:    key,
+				Detail: fmt.Sprintf("topology %d without snapshot", order),
+			})
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		var tx crypto.Hash
+		copy(tx[:], val[len(val)-len(tx):])
+		_, err = txn.Get(graphTransactionKey(tx))
+		if err == badger.ErrKeyNotFound {
+			issues = append(issues, &RecoveryIssue{
+				Kind:   RecoveryIssueOrphanTopology,
+				Key:    key,
+				Detail: fmt.Sprintf("topology %d without transaction %s", order, tx),
+			})
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return issues, nil
+}
+
+func checkOrphanUTXOLocks(txn *badger.Txn) ([]*RecoveryIssue, error) {
+	var issues []*RecoveryIssue
+
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = []byte(graphPrefixUTXO)
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	for it.Seek(opts.Prefix); it.Valid(); it.Next() {
+		val, err := it.Item().ValueCopy(nil)
+		if err != nil {
+			return nil, err
+		}
+		utxo, err := common.UnmarshalUTXO(val)
+		if err != nil {
+			return nil, err
+		}
+		if !utxo.LockHash.HasValue() {
+			continue
+		}
+		_, err = txn.Get(graphTransactionKey(utxo.LockHash))
+		if err == badger.ErrKeyNotFound {
+			issues = append(issues, &RecoveryIssue{
+				Kind:   RecoveryIssueOrphanUTXOLock,
+				Key:    it.Item().KeyCopy(nil),
+				Detail: fmt.Sprintf("utxo %s:%d locked by missing transaction %s", utxo.Hash, utxo.Index, utxo.LockHash),
+			})
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return issues, nil
+}
+
+func checkOrphanGhostLocks(txn *badger.Txn) ([]*RecoveryIssue, error) {
+	var issues []*RecoveryIssue
+
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = []byte(graphPrefixGhost)
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	for it.Seek(opts.Prefix); it.Valid(); it.Next() {
+		var tx crypto.Hash
+		_, err := it.Item().ValueCopy(tx[:])
+		if err != nil {
+			return nil, err
+		}
+		_, err = txn.Get(graphTransactionKey(tx))
+		if err == badger.ErrKeyNotFound {
+			key := it.Item().KeyCopy(nil)
+			issues = append(issues, &RecoveryIssue{
+				Kind:   RecoveryIssueOrphanGhostLock,
+				Key:    key,
+				Detail: fmt.Sprintf("ghost key %x locked by missing transaction %s", key[len(graphPrefixGhost):], tx),
+			})
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return issues, nil
+}
+
+func repairOrphanTopology(txn *badger.Txn, key []byte) error {
+	item, err := txn.Get(key)
+	if err == badger.ErrKeyNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return err
+	}
+	item, err = txn.Get(val)
+	if err == nil {
+		v, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		snap, err := common.UnmarshalVersionedSnapshot(v)
+		if err != nil {
+			return err
+		}
+		err = txn.Delete(graphSnapTopologyKey(snap.PayloadHash()))
+		if err != nil {
+			return err
+		}
+	} else if err != badger.ErrKeyNotFound {
+		return err
+	}
+	return txn.Delete(key)
+}
+
+func repairOrphanUTXOLock(txn *badger.Txn, key []byte) error {
+	item, err := txn.Get(key)
+	if err != nil {
+		return err
+	}
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return err
+	}
+	utxo, err := common.UnmarshalUTXO(val)
+	if err != nil {
+		return err
+	}
+	utxo.LockHash = crypto.Hash{}
+	return txn.Set(key, utxo.Marshal())
+}
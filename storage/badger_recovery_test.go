@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"os"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/dgraph-io/badger/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecovery(t *testing.T) {
+	require := require.New(t)
+	custom, err := config.Initialize("../config/config.example.toml")
+	require.Nil(err)
+
+	root, err := os.MkdirTemp("", "mixin-recovery-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	store, err := NewBadgerStore(custom, root)
+	require.Nil(err)
+	defer store.Close()
+
+	issues, err := store.CheckRecovery()
+	require.Nil(err)
+	require.Len(issues, 0)
+
+	tx := crypto.Blake3Hash([]byte("missing-transaction"))
+	ghost := crypto.NewKeyFromSeed(make([]byte, 64)).Public()
+	utxo := &common.UTXOWithLock{
+		UTXO: common.UTXO{
+			Input: common.Input{Hash: crypto.Blake3Hash([]byte("utxo")), Index: 1},
+			Output: common.Output{
+				Type:   common.OutputTypeScript,
+				Amount: common.NewIntegerFromString("1"),
+				Keys:   []*crypto.Key{&ghost},
+				Script: common.NewThresholdScript(1),
+			},
+			Asset: common.XINAssetId,
+		},
+		LockHash: tx,
+	}
+	err = store.snapshotsDB.Update(func(txn *badger.Txn) error {
+		err := txn.Set(graphUtxoKey(utxo.Hash, utxo.Index), utxo.Marshal())
+		if err != nil {
+			return err
+		}
+		err = txn.Set(graphGhostKey(ghost), tx[:])
+		if err != nil {
+			return err
+		}
+		val := graphSnapshotKey(crypto.Blake3Hash([]byte("node")), 7, tx)
+		return txn.Set(graphTopologyKey(3), val)
+	})
+	require.Nil(err)
+
+	issues, err = store.CheckRecovery()
+	require.Nil(err)
+	require.Len(issues, 3)
+	require.Equal(RecoveryIssueOrphanTopology, issues[0].Kind)
+	require.Equal(RecoveryIssueOrphanUTXOLock, issues[1].Kind)
+	require.Equal(RecoveryIssueOrphanGhostLock, issues[2].Kind)
+	require.Equal(uint64(3), store.TopologySequence())
+
+	err = store.RepairRecovery(issues)
+	require.Nil(err)
+	issues, err = store.CheckRecovery()
+	require.Nil(err)
+	require.Len(issues, 0)
+	require.Equal(uint64(0), store.TopologySequence())
+	lock, err := store.ReadUTXOLock(utxo.Hash, utxo.Index)
+	require.Nil(err)
+	require.False(lock.LockHash.HasValue())
+	require.Equal("1.00000000", lock.Amount.String())
+	locked, err := store.ReadGhostKeyLock(ghost)
+	require.Nil(err)
+	require.Nil(locked)
+}
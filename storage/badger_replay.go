@@ -0,0 +1,271 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/dgraph-io/badger/v4"
+)
+
+type ChainReplay struct {
+	NodeId    crypto.Hash
+	Rounds    uint64
+	Snapshots int
+	Invalid   int
+	Repaired  int
+}
+
+// ReplayGraphChain re-validates all the final rounds of a single node chain
+// against the stored snapshots and transactions, much faster than the full
+// graph validation when only one chain is suspected to be corrupted. With
+// repair, the snapshot topology, timestamp, uniqueness and final round indexes
+// are derived again from the stored snapshots. The transaction finalization
+// and the round references are only validated, because rewriting them may
+// revive spent outputs or break the consensus.
+func (s *BadgerStore) ReplayGraphChain(nodeId crypto.Hash, repair bool) (*ChainReplay, error) {
+	logger.Printf("BadgerStore.ReplayGraphChain(%s, %t) BEGIN\n", nodeId, repair)
+	if repair {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+	}
+
+	txn := s.snapshotsDB.NewTransaction(false)
+	head, err := readRound(txn, nodeId)
+	txn.Discard()
+	if err != nil {
+		return nil, err
+	}
+	if head == nil {
+		return nil, fmt.Errorf("node chain %s not found", nodeId)
+	}
+
+	replay := &ChainReplay{NodeId: nodeId, Rounds: head.Number}
+	var prev crypto.Hash
+	for i := uint64(0); i < head.Number; i++ {
+		hash, err := s.replayChainRound(replay, i, prev, repair)
+		if err != nil {
+			return replay, err
+		}
+		prev = hash
+	}
+	if head.Number > 0 && head.References.Self != prev {
+		logger.Printf("MALFORMED HEAD REFERENCE %s %d %s %s\n", nodeId, head.Number, head.References.Self, prev)
+		replay.Invalid += 1
+	}
+	logger.Printf("BadgerStore.ReplayGraphChain(%s, %t) DONE %d %d %d\n", nodeId, repair, replay.Snapshots, replay.Invalid, replay.Repaired)
+	return replay, nil
+}
+
+// VerifyGraphChainRange validates the final rounds from to to of a single
+// node chain the same way as ReplayGraphChain without repair, and the first
+// round of the range is checked against the round before it.
+func (s *BadgerStore) VerifyGraphChainRange(nodeId crypto.Hash, from, to uint64) (*ChainReplay, error) {
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	head, err := readRound(txn, nodeId)
+	if err != nil {
+		return nil, err
+	}
+	if head == nil {
+		return nil, fmt.Errorf("node chain %s not found", nodeId)
+	}
+	if to < from || to >= head.Number {
+		return nil, fmt.Errorf("invalid final rounds range %d..%d of %d", from, to, head.Number)
+	}
+
+	var prev crypto.Hash
+	if from > 0 {
+		snapshots, err := readSnapshotsForNodeRound(txn, nodeId, from-1)
+		if err != nil {
+			return nil, err
+		}
+		if len(snapshots) == 0 {
+			return nil, fmt.Errorf("empty final round %s %d", nodeId, from-1)
+		}
+		_, _, prev = computeRoundHash(nodeId, from-1, snapshots)
+	}
+
+	replay := &ChainReplay{NodeId: nodeId}
+	for i := from; i <= to; i++ {
+		hash, err := s.replayChainRound(replay, i, prev, false)
+		if err != nil {
+			return replay, err
+		}
+		replay.Rounds += 1
+		prev = hash
+	}
+	if to+1 == head.Number && head.References.Self != prev {
+		logger.Printf("MALFORMED HEAD REFERENCE %s %d %s %s\n", nodeId, head.Number, head.References.Self, prev)
+		replay.Invalid += 1
+	}
+	return replay, nil
+}
+
+func (s *BadgerStore) replayChainRound(replay *ChainReplay, number uint64, prev crypto.Hash, repair bool) (crypto.Hash, error) {
+	txn := s.snapshotsDB.NewTransaction(repair)
+	defer txn.Discard()
+
+	nodeId := replay.NodeId
+	snapshots, err := readSnapshotsForNodeRound(txn, nodeId, number)
+	if err != nil {
+		return crypto.Hash{}, err
+	}
+	if len(snapshots) == 0 {
+		return crypto.Hash{}, fmt.Errorf("empty final round %s %d", nodeId, number)
+	}
+
+	for _, snap := range snapshots {
+		replay.Snapshots += 1
+		issues, err := replayChainSnapshot(txn, nodeId, number, snap)
+		if err != nil {
+			return crypto.Hash{}, err
+		}
+		first := snapshots[0].References
+		if (first == nil) != (snap.References == nil) || (first != nil && !first.Equal(snap.References)) {
+			logger.Printf("MALFORMED SNAPSHOT REFERENCES %s %d %s\n", nodeId, number, snap.Hash)
+			replay.Invalid += 1
+		}
+		replay.Invalid += len(issues)
+		if !repair || len(issues) == 0 {
+			continue
+		}
+		err = writeChainSnapshotIndexes(txn, snap)
+		if err != nil {
+			return crypto.Hash{}, err
+		}
+		replay.Repaired += len(issues) - countUnrepairableIssues(snap, issues)
+	}
+
+	start, _, hash := computeRoundHash(nodeId, number, snapshots)
+	references := snapshots[0].References
+	if number > 0 && (references == nil || references.Self != prev) {
+		logger.Printf("MALFORMED ROUND REFERENCE %s %d %s\n", nodeId, number, prev)
+		replay.Invalid += 1
+	}
+	round, err := readRound(txn, hash)
+	if err != nil {
+		return hash, err
+	}
+	if round == nil || round.NodeId != nodeId || round.Number != number || round.Timestamp != start {
+		logger.Printf("MALFORMED ROUND %s %d %s %v\n", nodeId, number, hash, round)
+		replay.Invalid += 1
+		if repair {
+			err = writeRound(txn, hash, &common.Round{
+				Hash:       hash,
+				NodeId:     nodeId,
+				Number:     number,
+				Timestamp:  start,
+				References: references,
+			})
+			if err != nil {
+				return hash, err
+			}
+			replay.Repaired += 1
+		}
+	}
+
+	if !repair {
+		return hash, nil
+	}
+	return hash, txn.Commit()
+}
+
+const (
+	replayIssueTransaction  = "transaction"
+	replayIssueFinalization = "finalization"
+	replayIssueUnique       = "unique"
+	replayIssueTopology     = "topology"
+	replayIssueTimestamp    = "timestamp"
+)
+
+func replayChainSnapshot(txn *badger.Txn, nodeId crypto.Hash, number uint64, snap *common.SnapshotWithTopologicalOrder) ([]string, error) {
+	var issues []string
+	if snap.NodeId != nodeId || snap.RoundNumber != number {
+		return nil, fmt.Errorf("malformed snapshot %s %s %d", snap.Hash, snap.NodeId, snap.RoundNumber)
+	}
+
+	tx := snap.SoleTransaction()
+	ver, err := readTransaction(txn, tx)
+	if err != nil {
+		return nil, err
+	}
+	if ver == nil || ver.PayloadHash() != tx {
+		issues = append(issues, replayIssueTransaction)
+	}
+	found, err := hasGraphEntry(txn, graphFinalizationKey(tx))
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		issues = append(issues, replayIssueFinalization)
+	}
+	found, err = hasGraphEntry(txn, graphUniqueKey(snap.NodeId, tx))
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		issues = append(issues, replayIssueUnique)
+	}
+
+	topo, err := readSnapshotWithTopo(txn, snap.Hash)
+	if err != nil && err != badger.ErrKeyNotFound {
+		return nil, err
+	}
+	if topo == nil || topo.TopologicalOrder != snap.TopologicalOrder || topo.PayloadHash() != snap.Hash {
+		issues = append(issues, replayIssueTopology)
+	}
+	found, err = hasGraphEntry(txn, graphTimeTopologyKey(snap.Timestamp, snap.TopologicalOrder))
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		issues = append(issues, replayIssueTimestamp)
+	}
+
+	for _, i := range issues {
+		logger.Printf("MALFORMED SNAPSHOT %s %s %d %s\n", i, snap.NodeId, number, snap.Hash)
+	}
+	return issues, nil
+}
+
+// the finalization writes the UTXOs, so it can't be derived again
+func countUnrepairableIssues(snap *common.SnapshotWithTopologicalOrder, issues []string) int {
+	var unrepaired int
+	for _, i := range issues {
+		switch i {
+		case replayIssueTransaction, replayIssueFinalization:
+			logger.Printf("UNREPAIRABLE SNAPSHOT %s %s\n", i, snap.Hash)
+			unrepaired += 1
+		}
+	}
+	return unrepaired
+}
+
+func writeChainSnapshotIndexes(txn *badger.Txn, snap *common.SnapshotWithTopologicalOrder) error {
+	key := graphSnapshotKey(snap.NodeId, snap.RoundNumber, snap.SoleTransaction())
+	topo := graphTopologyKey(snap.TopologicalOrder)
+	err := txn.Set(topo, key)
+	if err != nil {
+		return err
+	}
+	err = txn.Set(graphSnapTopologyKey(snap.Hash), topo)
+	if err != nil {
+		return err
+	}
+	err = txn.Set(graphUniqueKey(snap.NodeId, snap.SoleTransaction()), []byte{})
+	if err != nil {
+		return err
+	}
+	return writeTimestampIndex(txn, snap)
+}
+
+func hasGraphEntry(txn *badger.Txn, key []byte) (bool, error) {
+	_, err := txn.Get(key)
+	if err == badger.ErrKeyNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
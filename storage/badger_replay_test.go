@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"os"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplayGraphChain(t *testing.T) {
+	require := require.New(t)
+
+	custom, err := config.Initialize("../config/config.example.toml")
+	require.Nil(err)
+
+	root, err := os.MkdirTemp("", "mixin-badger-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	store, err := NewBadgerStore(custom, root)
+	require.Nil(err)
+	defer store.Close()
+
+	gns, err := common.ReadGenesis("../config/genesis.json")
+	require.Nil(err)
+	rounds, snapshots, transactions, err := gns.BuildSnapshots()
+	require.Nil(err)
+	err = store.LoadGenesis(rounds, snapshots, transactions)
+	require.Nil(err)
+
+	_, err = store.ReplayGraphChain(crypto.Blake3Hash([]byte("missing")), false)
+	require.NotNil(err)
+
+	snap := snapshots[0]
+	replay, err := store.ReplayGraphChain(snap.NodeId, false)
+	require.Nil(err)
+	require.Equal(uint64(1), replay.Rounds)
+	require.Equal(0, replay.Invalid)
+	require.Equal(0, replay.Repaired)
+	total := replay.Snapshots
+	require.Greater(total, 0)
+
+	txn := store.snapshotsDB.NewTransaction(true)
+	require.Nil(txn.Delete(graphUniqueKey(snap.NodeId, snap.SoleTransaction())))
+	require.Nil(txn.Delete(graphSnapTopologyKey(snap.PayloadHash())))
+	require.Nil(txn.Delete(graphRoundKey(rounds[0].Hash)))
+	require.Nil(txn.Commit())
+
+	replay, err = store.ReplayGraphChain(snap.NodeId, false)
+	require.Nil(err)
+	require.Equal(total, replay.Snapshots)
+	require.Equal(3, replay.Invalid)
+	require.Equal(0, replay.Repaired)
+	s, err := store.ReadSnapshot(snap.PayloadHash())
+	require.Nil(err)
+	require.Nil(s)
+
+	replay, err = store.ReplayGraphChain(snap.NodeId, true)
+	require.Nil(err)
+	require.Equal(3, replay.Invalid)
+	require.Equal(3, replay.Repaired)
+	s, err = store.ReadSnapshot(snap.PayloadHash())
+	require.Nil(err)
+	require.Equal(snap.TopologicalOrder, s.TopologicalOrder)
+	round, err := store.ReadRound(rounds[0].Hash)
+	require.Nil(err)
+	require.Equal(rounds[0].Timestamp, round.Timestamp)
+
+	replay, err = store.ReplayGraphChain(snap.NodeId, false)
+	require.Nil(err)
+	require.Equal(0, replay.Invalid)
+
+	txn = store.snapshotsDB.NewTransaction(true)
+	require.Nil(txn.Delete(graphFinalizationKey(snap.SoleTransaction())))
+	require.Nil(txn.Commit())
+	replay, err = store.ReplayGraphChain(snap.NodeId, true)
+	require.Nil(err)
+	require.Equal(1, replay.Invalid)
+	require.Equal(0, replay.Repaired)
+}
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// the replicas of a primary store load all the writes to the snapshots db
+// since the replica version, in the incremental backup format of badger, so
+// the entries keep the versions of the primary, and the last version of the
+// primary is kept in the cache db of the replica, which is never replicated.
+const (
+	cacheReplicaVersionKey = "CACHEREPLICAVERSION"
+
+	replicaPendingWrites = 16
+)
+
+// WriteReplication writes the next version to pull since, and then all the
+// entries of the snapshots db after the version. The entries written after
+// the next version is read may be written again in the next pull, which is
+// harmless because they have the same versions.
+func (s *BadgerStore) WriteReplication(w io.Writer, since uint64) error {
+	next := max(s.snapshotsDB.MaxVersion(), since)
+	err := binary.Write(w, binary.BigEndian, next)
+	if err != nil {
+		return err
+	}
+	_, err = s.snapshotsDB.Backup(w, since)
+	return err
+}
+
+// LoadReplication loads the entries written by WriteReplication of the
+// primary, and returns the next version, which should be written only after
+// the stream is known to be complete, so a broken pull is pulled again from
+// the same version.
+func (s *BadgerStore) LoadReplication(r io.Reader) (uint64, error) {
+	var next uint64
+	err := binary.Read(r, binary.BigEndian, &next)
+	if err != nil {
+		return 0, fmt.Errorf("replication version %v", err)
+	}
+	old, err := s.ReadReplicaVersion()
+	if err != nil {
+		return 0, err
+	}
+	if next < old {
+		return 0, fmt.Errorf("replication version %d before %d", next, old)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return next, s.snapshotsDB.Load(r, replicaPendingWrites)
+}
+
+func (s *BadgerStore) WriteReplicaVersion(next uint64) error {
+	return s.cacheDB.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte(cacheReplicaVersionKey), binary.BigEndian.AppendUint64(nil, next))
+	})
+}
+
+// ReadReplicaVersion is the last version of the primary loaded, 0 for the
+// store never replicated.
+func (s *BadgerStore) ReadReplicaVersion() (uint64, error) {
+	txn := s.cacheDB.NewTransaction(false)
+	defer txn.Discard()
+
+	item, err := txn.Get([]byte(cacheReplicaVersionKey))
+	if err == badger.ErrKeyNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(val), nil
+}
@@ -95,7 +95,8 @@ func (s *BadgerStore) StartNewRound(node crypto.Hash, number uint64, references
 		if external == nil {
 			panic("external final not exist")
 		}
-		if external.NodeId == self.NodeId {
+		// the single node of a dev genesis references its own genesis round
+		if external.NodeId == self.NodeId && (!s.devGenesis || external.Number != 0) {
 			panic("self references loop")
 		}
 		old, err := readRound(txn, references.Self)
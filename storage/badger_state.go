@@ -0,0 +1,451 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/dgraph-io/badger/v4"
+)
+
+// the outputs of the finalized transactions are added to the lattice hash of
+// the checkpoint by the snapshot timestamp, and the spent inputs are removed,
+// so the nodes with the same finalized snapshots before the end of a
+// checkpoint have the same state, despite their local topology orders. the
+// checkpoints are cumulative from the genesis, so they are written only if
+// the deltas are complete since the genesis.
+const (
+	graphPrefixStateDelta      = "STATEDELTA"
+	graphPrefixStateMoved      = "STATEMOVED"
+	graphPrefixStateCheckpoint = "STATECHECKPOINT"
+	graphPrefixStateSince      = "STATEINDEXSINCE"
+
+	stateDeltaSize      = 8 + 8 + 32 + crypto.LatticeHashSize
+	stateCheckpointSize = 8 + 8 + 8 + 32 + 32 + crypto.LatticeHashSize
+)
+
+type stateDelta struct {
+	transactions uint64
+	mintBatch    uint64
+	mint         crypto.Hash
+	outputs      crypto.LatticeHash
+}
+
+type StateCheckpoint struct {
+	Number          uint64
+	Transactions    uint64
+	MintBatch       uint64
+	MintTransaction crypto.Hash
+	Nodes           crypto.Hash
+	Outputs         crypto.LatticeHash
+}
+
+func StateCheckpointNumber(timestamp uint64) uint64 {
+	return timestamp / uint64(config.StateCheckpointInterval)
+}
+
+// Timestamp is the end of the checkpoint, all the snapshots before it are
+// included in the checkpoint.
+func (c *StateCheckpoint) Timestamp() uint64 {
+	return (c.Number + 1) * uint64(config.StateCheckpointInterval)
+}
+
+func (c *StateCheckpoint) Commitment() crypto.Hash {
+	outputs := c.Outputs.Checksum()
+	data := binary.BigEndian.AppendUint64(nil, c.Number)
+	data = append(data, outputs[:]...)
+	data = binary.BigEndian.AppendUint64(data, c.Transactions)
+	data = binary.BigEndian.AppendUint64(data, c.MintBatch)
+	data = append(data, c.MintTransaction[:]...)
+	data = append(data, c.Nodes[:]...)
+	return crypto.Blake3Hash(data)
+}
+
+// WriteStateCheckpoint accumulates the deltas since the last checkpoint, the
+// nodes is the hash of the node states at the end of the checkpoint, which
+// the kernel is responsible for.
+func (s *BadgerStore) WriteStateCheckpoint(number uint64, nodes crypto.Hash) (*StateCheckpoint, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	txn := s.snapshotsDB.NewTransaction(true)
+	defer txn.Discard()
+
+	since, found, err := readStateIndexSince(txn)
+	if err != nil {
+		return nil, err
+	}
+	if !found || since != 0 {
+		return nil, fmt.Errorf("state index incomplete since %d", since)
+	}
+	last, err := readLastStateCheckpoint(txn, number)
+	if err != nil {
+		return nil, err
+	}
+	if last != nil && last.Number == number {
+		return last, nil
+	}
+
+	cp := &StateCheckpoint{Number: number, Nodes: nodes}
+	from := uint64(0)
+	if last != nil {
+		cp.Transactions = last.Transactions
+		cp.MintBatch, cp.MintTransaction = last.MintBatch, last.MintTransaction
+		cp.Outputs = last.Outputs
+		from = last.Number + 1
+	}
+
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = []byte(graphPrefixStateDelta)
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	for it.Seek(graphStateDeltaKey(from)); it.Valid(); it.Next() {
+		key := it.Item().Key()
+		if binary.BigEndian.Uint64(key[len(graphPrefixStateDelta):]) > number {
+			break
+		}
+		val, err := it.Item().ValueCopy(nil)
+		if err != nil {
+			return nil, err
+		}
+		d, err := parseStateDelta(val)
+		if err != nil {
+			return nil, err
+		}
+		cp.Transactions += d.transactions
+		if d.mintBatch > cp.MintBatch {
+			cp.MintBatch, cp.MintTransaction = d.mintBatch, d.mint
+		}
+		cp.Outputs.Merge(&d.outputs)
+	}
+	it.Close()
+
+	err = txn.Set(graphStateCheckpointKey(number), cp.marshal())
+	if err != nil {
+		return nil, err
+	}
+	return cp, txn.Commit()
+}
+
+func (s *BadgerStore) ReadStateCheckpoint(number uint64) (*StateCheckpoint, error) {
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	item, err := txn.Get(graphStateCheckpointKey(number))
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+	return parseStateCheckpoint(val)
+}
+
+// ListStateCheckpoints returns the latest checkpoints first.
+func (s *BadgerStore) ListStateCheckpoints(limit int) ([]*StateCheckpoint, error) {
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = []byte(graphPrefixStateCheckpoint)
+	opts.Reverse = true
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	var checkpoints []*StateCheckpoint
+	for it.Seek(graphStateCheckpointKey(^uint64(0))); it.Valid() && len(checkpoints) < limit; it.Next() {
+		val, err := it.Item().ValueCopy(nil)
+		if err != nil {
+			return nil, err
+		}
+		cp, err := parseStateCheckpoint(val)
+		if err != nil {
+			return nil, err
+		}
+		checkpoints = append(checkpoints, cp)
+	}
+	return checkpoints, nil
+}
+
+// ReadStateIndexSince returns 0 if the state deltas are complete since the
+// genesis, otherwise the first checkpoint indexed.
+func (s *BadgerStore) ReadStateIndexSince() (uint64, bool, error) {
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	return readStateIndexSince(txn)
+}
+
+// RebuildStateIndex writes the state deltas of the snapshots since the
+// offset, and the index is reset at the offset 0, returns the next topology.
+// The kernel must not run during the rebuild.
+func (s *BadgerStore) RebuildStateIndex(offset, count uint64) (uint64, error) {
+	if offset == 0 {
+		for _, prefix := range []string{graphPrefixStateDelta, graphPrefixStateMoved,
+			graphPrefixStateCheckpoint, graphPrefixStateSince} {
+			_, err := s.RemoveGraphEntries(prefix)
+			if err != nil {
+				return offset, err
+			}
+		}
+	}
+	snapshots, txs, err := s.ReadSnapshotWithTransactionsSinceTopology(offset, count)
+	if err != nil {
+		return offset, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	txn := s.snapshotsDB.NewTransaction(true)
+	defer txn.Discard()
+
+	for i, snap := range snapshots {
+		item, err := txn.Get(graphFinalizationKey(txs[i].PayloadHash()))
+		if err != nil {
+			return offset, err
+		}
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return offset, err
+		}
+		if hash := snap.PayloadHash(); bytes.Equal(val, hash[:]) {
+			err = writeStateDelta(txn, txs[i], snap)
+		} else {
+			var first crypto.Hash
+			copy(first[:], val)
+			err = moveStateDelta(txn, txs[i], first, snap)
+		}
+		if err != nil {
+			return offset, err
+		}
+	}
+	if uint64(len(snapshots)) < count {
+		err = writeStateIndexSince(txn, 0)
+		if err != nil {
+			return offset, err
+		}
+	}
+	err = txn.Commit()
+	if err != nil || len(snapshots) == 0 {
+		return offset, err
+	}
+	return snapshots[len(snapshots)-1].TopologicalOrder + 1, nil
+}
+
+func writeStateDelta(txn *badger.Txn, ver *common.VersionedTransaction, snap *common.SnapshotWithTopologicalOrder) error {
+	number := StateCheckpointNumber(snap.Timestamp)
+	d, err := readStateDelta(txn, number)
+	if err != nil {
+		return err
+	}
+	d.apply(ver, false)
+	err = txn.Set(graphStateDeltaKey(number), d.marshal())
+	if err != nil {
+		return err
+	}
+
+	_, found, err := readStateIndexSince(txn)
+	if err != nil || found {
+		return err
+	}
+	return writeStateIndexSince(txn, number)
+}
+
+// moveStateDelta moves the transaction finalized again by an earlier snapshot
+// to the earlier checkpoint, so the checkpoint of a transaction is always by
+// its earliest snapshot, despite the snapshot finalized first by the node.
+func moveStateDelta(txn *badger.Txn, ver *common.VersionedTransaction, first crypto.Hash, snap *common.SnapshotWithTopologicalOrder) error {
+	key := graphStateMovedKey(ver.PayloadHash())
+	var current uint64
+	item, err := txn.Get(key)
+	if err == nil {
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		current = binary.BigEndian.Uint64(val)
+	} else if err != badger.ErrKeyNotFound {
+		return err
+	} else {
+		prev, err := readSnapshotWithTopo(txn, first)
+		if err != nil || prev == nil {
+			return fmt.Errorf("state delta snapshot %s not found %v", first, err)
+		}
+		current = StateCheckpointNumber(prev.Timestamp)
+	}
+
+	number := StateCheckpointNumber(snap.Timestamp)
+	if number >= current {
+		return nil
+	}
+	for _, n := range []uint64{current, number} {
+		d, err := readStateDelta(txn, n)
+		if err != nil {
+			return err
+		}
+		d.apply(ver, n == current)
+		err = txn.Set(graphStateDeltaKey(n), d.marshal())
+		if err != nil {
+			return err
+		}
+	}
+	return txn.Set(key, binary.BigEndian.AppendUint64(nil, number))
+}
+
+func (d *stateDelta) apply(ver *common.VersionedTransaction, remove bool) {
+	hash := ver.PayloadHash()
+	update := d.outputs.Add
+	if remove {
+		update = d.outputs.Remove
+		d.transactions -= 1
+	} else {
+		d.transactions += 1
+	}
+	for i := range ver.Outputs {
+		update(stateOutputElement(hash, uint(i)))
+	}
+	for _, in := range ver.Inputs {
+		if !in.Hash.HasValue() {
+			continue
+		}
+		if remove {
+			d.outputs.Add(stateOutputElement(in.Hash, uint(in.Index)))
+		} else {
+			d.outputs.Remove(stateOutputElement(in.Hash, uint(in.Index)))
+		}
+	}
+	if mint := ver.Inputs[0].Mint; mint != nil {
+		if remove && d.mint == hash {
+			d.mintBatch, d.mint = 0, crypto.Hash{}
+		} else if !remove && mint.Batch > d.mintBatch {
+			d.mintBatch, d.mint = mint.Batch, hash
+		}
+	}
+}
+
+func stateOutputElement(hash crypto.Hash, index uint) []byte {
+	return binary.BigEndian.AppendUint16(hash[:], uint16(index))
+}
+
+func readStateDelta(txn *badger.Txn, number uint64) (*stateDelta, error) {
+	item, err := txn.Get(graphStateDeltaKey(number))
+	if err == badger.ErrKeyNotFound {
+		return &stateDelta{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+	return parseStateDelta(val)
+}
+
+func (d *stateDelta) marshal() []byte {
+	data := binary.BigEndian.AppendUint64(nil, d.transactions)
+	data = binary.BigEndian.AppendUint64(data, d.mintBatch)
+	data = append(data, d.mint[:]...)
+	return append(data, d.outputs.Bytes()...)
+}
+
+func parseStateDelta(val []byte) (*stateDelta, error) {
+	if len(val) != stateDeltaSize {
+		return nil, fmt.Errorf("invalid state delta size %d", len(val))
+	}
+	d := &stateDelta{
+		transactions: binary.BigEndian.Uint64(val[:8]),
+		mintBatch:    binary.BigEndian.Uint64(val[8:16]),
+	}
+	copy(d.mint[:], val[16:48])
+	outputs, err := crypto.NewLatticeHash(val[48:])
+	if err != nil {
+		return nil, err
+	}
+	d.outputs = *outputs
+	return d, nil
+}
+
+func readLastStateCheckpoint(txn *badger.Txn, number uint64) (*StateCheckpoint, error) {
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = []byte(graphPrefixStateCheckpoint)
+	opts.Reverse = true
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	it.Seek(graphStateCheckpointKey(number))
+	if !it.Valid() {
+		return nil, nil
+	}
+	val, err := it.Item().ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+	return parseStateCheckpoint(val)
+}
+
+func (c *StateCheckpoint) marshal() []byte {
+	data := binary.BigEndian.AppendUint64(nil, c.Number)
+	data = binary.BigEndian.AppendUint64(data, c.Transactions)
+	data = binary.BigEndian.AppendUint64(data, c.MintBatch)
+	data = append(data, c.MintTransaction[:]...)
+	data = append(data, c.Nodes[:]...)
+	return append(data, c.Outputs.Bytes()...)
+}
+
+func parseStateCheckpoint(val []byte) (*StateCheckpoint, error) {
+	if len(val) != stateCheckpointSize {
+		return nil, fmt.Errorf("invalid state checkpoint size %d", len(val))
+	}
+	cp := &StateCheckpoint{
+		Number:       binary.BigEndian.Uint64(val[:8]),
+		Transactions: binary.BigEndian.Uint64(val[8:16]),
+		MintBatch:    binary.BigEndian.Uint64(val[16:24]),
+	}
+	copy(cp.MintTransaction[:], val[24:56])
+	copy(cp.Nodes[:], val[56:88])
+	outputs, err := crypto.NewLatticeHash(val[88:])
+	if err != nil {
+		return nil, err
+	}
+	cp.Outputs = *outputs
+	return cp, nil
+}
+
+func readStateIndexSince(txn *badger.Txn) (uint64, bool, error) {
+	item, err := txn.Get([]byte(graphPrefixStateSince))
+	if err == badger.ErrKeyNotFound {
+		return 0, false, nil
+	} else if err != nil {
+		return 0, false, err
+	}
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return 0, false, err
+	}
+	return binary.BigEndian.Uint64(val), true, nil
+}
+
+func writeStateIndexSince(txn *badger.Txn, number uint64) error {
+	val := binary.BigEndian.AppendUint64(nil, number)
+	return txn.Set([]byte(graphPrefixStateSince), val)
+}
+
+func graphStateDeltaKey(number uint64) []byte {
+	return binary.BigEndian.AppendUint64([]byte(graphPrefixStateDelta), number)
+}
+
+func graphStateMovedKey(hash crypto.Hash) []byte {
+	return append([]byte(graphPrefixStateMoved), hash[:]...)
+}
+
+func graphStateCheckpointKey(number uint64) []byte {
+	return binary.BigEndian.AppendUint64([]byte(graphPrefixStateCheckpoint), number)
+}
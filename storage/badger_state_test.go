@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"os"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStateCheckpoint(t *testing.T) {
+	require := require.New(t)
+	custom, err := config.Initialize("../config/config.example.toml")
+	require.Nil(err)
+
+	root, err := os.MkdirTemp("", "mixin-state-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	store, err := NewBadgerStore(custom, root)
+	require.Nil(err)
+	defer store.Close()
+
+	input := crypto.Blake3Hash([]byte("input"))
+	a := common.NewTransactionV5(common.XINAssetId)
+	a.AddInput(input, 0)
+	a.AddOutputWithType(common.OutputTypeScript, nil, common.NewThresholdScript(1), common.NewInteger(1), make([]byte, 64))
+	a.AddOutputWithType(common.OutputTypeScript, nil, common.NewThresholdScript(1), common.NewInteger(2), make([]byte, 64))
+	va := a.AsVersioned()
+	b := common.NewTransactionV5(common.XINAssetId)
+	b.AddInput(va.PayloadHash(), 0)
+	b.AddOutputWithType(common.OutputTypeScript, nil, common.NewThresholdScript(1), common.NewInteger(1), make([]byte, 64))
+	vb := b.AsVersioned()
+
+	interval := uint64(config.StateCheckpointInterval)
+	number := uint64(480000)
+	snapshot := func(node string, tx crypto.Hash, timestamp, topology uint64) *common.SnapshotWithTopologicalOrder {
+		s := &common.Snapshot{
+			Version:   common.SnapshotVersionCommonEncoding,
+			NodeId:    crypto.Blake3Hash([]byte(node)),
+			Timestamp: timestamp,
+		}
+		s.AddSoleTransaction(tx)
+		return &common.SnapshotWithTopologicalOrder{Snapshot: s, TopologicalOrder: topology}
+	}
+	txn := store.snapshotsDB.NewTransaction(true)
+	defer txn.Discard()
+	require.Nil(writeAssetInfo(txn, common.XINAssetId, common.XINAsset))
+	require.Nil(writeTransaction(txn, va))
+	require.Nil(writeTransaction(txn, vb))
+	require.Nil(writeSnapshot(txn, snapshot("one", va.PayloadHash(), number*interval+1, 0), va))
+	require.Nil(writeSnapshot(txn, snapshot("one", vb.PayloadHash(), (number+2)*interval+1, 1), vb))
+	require.Nil(writeSnapshot(txn, snapshot("two", vb.PayloadHash(), (number+1)*interval+1, 2), vb))
+	require.Nil(txn.Commit())
+
+	since, found, err := store.ReadStateIndexSince()
+	require.Nil(err)
+	require.True(found)
+	require.Equal(number, since)
+	_, err = store.WriteStateCheckpoint(number, crypto.Hash{})
+	require.ErrorContains(err, "state index incomplete since 480000")
+
+	txn = store.snapshotsDB.NewTransaction(false)
+	deltas := make([]*stateDelta, 3)
+	for i := range deltas {
+		deltas[i], err = readStateDelta(txn, number+uint64(i))
+		require.Nil(err)
+	}
+	txn.Discard()
+	require.Equal(uint64(1), deltas[0].transactions)
+	require.Equal(uint64(1), deltas[1].transactions)
+	require.Equal(uint64(0), deltas[2].transactions)
+	require.Equal(new(crypto.LatticeHash).Checksum(), deltas[2].outputs.Checksum())
+
+	next, err := store.RebuildStateIndex(0, 100)
+	require.Nil(err)
+	require.Equal(uint64(3), next)
+	txn = store.snapshotsDB.NewTransaction(false)
+	for i := range deltas {
+		d, err := readStateDelta(txn, number+uint64(i))
+		require.Nil(err)
+		require.Equal(deltas[i], d)
+	}
+	txn.Discard()
+
+	nodes := crypto.Blake3Hash([]byte("nodes"))
+	first, err := store.WriteStateCheckpoint(number, nodes)
+	require.Nil(err)
+	var outputs crypto.LatticeHash
+	outputs.Add(stateOutputElement(va.PayloadHash(), 0))
+	outputs.Add(stateOutputElement(va.PayloadHash(), 1))
+	outputs.Remove(stateOutputElement(input, 0))
+	require.Equal(uint64(1), first.Transactions)
+	require.Equal(outputs.Checksum(), first.Outputs.Checksum())
+	require.Equal((number+1)*interval, first.Timestamp())
+
+	last, err := store.WriteStateCheckpoint(number+2, nodes)
+	require.Nil(err)
+	outputs.Remove(stateOutputElement(va.PayloadHash(), 0))
+	outputs.Add(stateOutputElement(vb.PayloadHash(), 0))
+	require.Equal(uint64(2), last.Transactions)
+	require.Equal(outputs.Checksum(), last.Outputs.Checksum())
+	require.Equal(nodes, last.Nodes)
+
+	second, err := store.WriteStateCheckpoint(number+1, nodes)
+	require.Nil(err)
+	require.Equal(last.Outputs.Checksum(), second.Outputs.Checksum())
+	require.NotEqual(last.Commitment(), second.Commitment())
+	again, err := store.WriteStateCheckpoint(number, crypto.Hash{})
+	require.Nil(err)
+	require.Equal(first.Commitment(), again.Commitment())
+
+	checkpoints, err := store.ListStateCheckpoints(2)
+	require.Nil(err)
+	require.Len(checkpoints, 2)
+	require.Equal(number+2, checkpoints[0].Number)
+	require.Equal(number+1, checkpoints[1].Number)
+	cp, err := store.ReadStateCheckpoint(number)
+	require.Nil(err)
+	require.Equal(first.Commitment(), cp.Commitment())
+	cp, err = store.ReadStateCheckpoint(number + 3)
+	require.Nil(err)
+	require.Nil(cp)
+}
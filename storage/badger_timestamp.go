@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"encoding/binary"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/dgraph-io/badger/v4"
+)
+
+// ReadTopologyByTimestamp returns the smallest topology of all the snapshots
+// with timestamp not before ts, or the next topology if not found, and the
+// first round number of each node chain not before ts. The topology is the
+// local finalization order, a snapshot synced late has a larger topology than
+// the later snapshots, so all the index entries since ts are scanned.
+func (s *BadgerStore) ReadTopologyByTimestamp(ts uint64) (uint64, map[crypto.Hash]uint64, error) {
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	topology := s.TopologySequence() + 1
+	for it.Seek(graphTimeTopologyKey(ts, 0)); it.ValidForPrefix([]byte(graphPrefixTimeTopology)); it.Next() {
+		key := it.Item().Key()
+		topology = min(topology, binary.BigEndian.Uint64(key[len(key)-8:]))
+	}
+
+	rounds := make(map[crypto.Hash]uint64)
+	it.Seek([]byte(graphPrefixTimeRound))
+	for it.ValidForPrefix([]byte(graphPrefixTimeRound)) {
+		key := it.Item().KeyCopy(nil)
+		var nodeId crypto.Hash
+		copy(nodeId[:], key[len(graphPrefixTimeRound):])
+		prefix := key[:len(graphPrefixTimeRound)+len(nodeId)]
+		it.Seek(graphTimeRoundKey(nodeId, ts))
+		if it.ValidForPrefix(prefix) {
+			val, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return 0, nil, err
+			}
+			rounds[nodeId] = binary.BigEndian.Uint64(val)
+		}
+		it.Seek(graphTimeRoundKey(nodeId, ^uint64(0)))
+		if it.ValidForPrefix(prefix) {
+			it.Next()
+		}
+	}
+	return topology, rounds, nil
+}
+
+// RebuildTimestampIndex writes the timestamp index for the snapshots
+// finalized before the index was introduced, returns the next topology.
+func (s *BadgerStore) RebuildTimestampIndex(offset, count uint64) (uint64, error) {
+	snapshots, err := s.ReadSnapshotsSinceTopology(offset, count)
+	if err != nil || len(snapshots) == 0 {
+		return offset, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	txn := s.snapshotsDB.NewTransaction(true)
+	defer txn.Discard()
+
+	for _, snap := range snapshots {
+		err = writeTimestampIndex(txn, snap)
+		if err != nil {
+			return offset, err
+		}
+	}
+	err = txn.Commit()
+	if err != nil {
+		return offset, err
+	}
+	return snapshots[len(snapshots)-1].TopologicalOrder + 1, nil
+}
+
+func writeTimestampIndex(txn *badger.Txn, snap *common.SnapshotWithTopologicalOrder) error {
+	key := graphTimeTopologyKey(snap.Timestamp, snap.TopologicalOrder)
+	err := txn.Set(key, []byte{})
+	if err != nil {
+		return err
+	}
+
+	key = graphTimeRoundKey(snap.NodeId, snap.Timestamp)
+	item, err := txn.Get(key)
+	if err == nil {
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		if binary.BigEndian.Uint64(val) <= snap.RoundNumber {
+			return nil
+		}
+	} else if err != badger.ErrKeyNotFound {
+		return err
+	}
+	val := binary.BigEndian.AppendUint64(nil, snap.RoundNumber)
+	return txn.Set(key, val)
+}
+
+func graphTimeTopologyKey(ts, topology uint64) []byte {
+	key := []byte(graphPrefixTimeTopology)
+	key = binary.BigEndian.AppendUint64(key, ts)
+	return binary.BigEndian.AppendUint64(key, topology)
+}
+
+func graphTimeRoundKey(nodeId crypto.Hash, ts uint64) []byte {
+	key := append([]byte(graphPrefixTimeRound), nodeId[:]...)
+	return binary.BigEndian.AppendUint64(key, ts)
+}
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"os"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimestampIndex(t *testing.T) {
+	require := require.New(t)
+
+	custom, err := config.Initialize("../config/config.example.toml")
+	require.Nil(err)
+
+	root, err := os.MkdirTemp("", "mixin-badger-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	store, err := NewBadgerStore(custom, root)
+	require.Nil(err)
+	defer store.Close()
+
+	gns, err := common.ReadGenesis("../config/genesis.json")
+	require.Nil(err)
+	rounds, snapshots, transactions, err := gns.BuildSnapshots()
+	require.Nil(err)
+	err = store.LoadGenesis(rounds, snapshots, transactions)
+	require.Nil(err)
+
+	ts := snapshots[0].Timestamp
+	last := ts
+	chains := make(map[crypto.Hash]bool)
+	for _, s := range snapshots {
+		chains[s.NodeId] = true
+		last = max(last, s.Timestamp)
+	}
+	topology, nodes, err := store.ReadTopologyByTimestamp(ts - 1)
+	require.Nil(err)
+	require.Equal(uint64(0), topology)
+	require.Len(nodes, len(chains))
+	for _, s := range snapshots {
+		require.Equal(uint64(0), nodes[s.NodeId])
+	}
+
+	topology, nodes, err = store.ReadTopologyByTimestamp(last + 1)
+	require.Nil(err)
+	require.Equal(store.TopologySequence()+1, topology)
+	require.Len(nodes, 0)
+
+	next, err := store.RebuildTimestampIndex(0, 100)
+	require.Nil(err)
+	require.Equal(uint64(len(snapshots)), next)
+	topology, nodes, err = store.ReadTopologyByTimestamp(ts)
+	require.Nil(err)
+	require.Equal(uint64(0), topology)
+	require.Len(nodes, len(chains))
+
+	txn := store.snapshotsDB.NewTransaction(true)
+	defer txn.Discard()
+	late := &common.SnapshotWithTopologicalOrder{
+		Snapshot:         &common.Snapshot{NodeId: snapshots[0].NodeId, Timestamp: last + 10, RoundNumber: 1},
+		TopologicalOrder: 3,
+	}
+	require.Nil(writeTimestampIndex(txn, late))
+	synced := &common.SnapshotWithTopologicalOrder{
+		Snapshot:         &common.Snapshot{NodeId: snapshots[0].NodeId, Timestamp: last + 5, RoundNumber: 1},
+		TopologicalOrder: 7,
+	}
+	require.Nil(writeTimestampIndex(txn, synced))
+	require.Nil(txn.Commit())
+	topology, _, err = store.ReadTopologyByTimestamp(last + 1)
+	require.Nil(err)
+	require.Equal(uint64(3), topology)
+	topology, _, err = store.ReadTopologyByTimestamp(last + 6)
+	require.Nil(err)
+	require.Equal(uint64(3), topology)
+}
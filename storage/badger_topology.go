@@ -146,6 +146,10 @@ func writeTopology(txn *badger.Txn, snap *common.SnapshotWithTopologicalOrder) e
 	if err != nil {
 		return err
 	}
+	err = writeTimestampIndex(txn, snap)
+	if err != nil {
+		return err
+	}
 
 	return txn.Set(graphSnapTopologyKey(snap.PayloadHash()), key)
 }
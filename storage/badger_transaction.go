@@ -12,6 +12,8 @@ import (
 	"github.com/dgraph-io/badger/v4"
 )
 
+const TransactionsReadBatchLimit = 1000
+
 func (s *BadgerStore) ReadTransaction(hash crypto.Hash) (*common.VersionedTransaction, string, error) {
 	txn := s.snapshotsDB.NewTransaction(false)
 	defer txn.Discard()
@@ -19,6 +21,27 @@ func (s *BadgerStore) ReadTransaction(hash crypto.Hash) (*common.VersionedTransa
 	return readTransactionAndFinalization(txn, hash)
 }
 
+// ReadTransactions reads the transactions in one badger transaction, the
+// results are in the same order of the hashes, and nil for the missing ones.
+func (s *BadgerStore) ReadTransactions(hashes []crypto.Hash) ([]*common.VersionedTransaction, []string, error) {
+	if len(hashes) > TransactionsReadBatchLimit {
+		return nil, nil, fmt.Errorf("too many transactions to read %d", len(hashes))
+	}
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	txs := make([]*common.VersionedTransaction, len(hashes))
+	snaps := make([]string, len(hashes))
+	for i, h := range hashes {
+		tx, snap, err := readTransactionAndFinalization(txn, h)
+		if err != nil {
+			return nil, nil, err
+		}
+		txs[i], snaps[i] = tx, snap
+	}
+	return txs, snaps, nil
+}
+
 func readTransactionAndFinalization(txn *badger.Txn, hash crypto.Hash) (*common.VersionedTransaction, string, error) {
 	tx, err := readTransaction(txn, hash)
 	if err != nil || tx == nil {
@@ -152,9 +175,13 @@ func writeTransaction(txn *badger.Txn, ver *common.VersionedTransaction) error {
 
 func finalizeTransaction(txn *badger.Txn, ver *common.VersionedTransaction, snap *common.SnapshotWithTopologicalOrder) error {
 	key := graphFinalizationKey(ver.PayloadHash())
-	_, err := txn.Get(key)
+	item, err := txn.Get(key)
 	if err == nil {
-		return nil
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		return moveStateDelta(txn, ver, crypto.Hash(val), snap)
 	} else if err != badger.ErrKeyNotFound {
 		return err
 	}
@@ -171,6 +198,13 @@ func finalizeTransaction(txn *badger.Txn, ver *common.VersionedTransaction, snap
 		}
 	}
 
+	if ver.TransactionType() == common.TransactionTypeWithdrawalSubmit {
+		err := txn.Set(graphPendingClaimKey(ver.PayloadHash()), []byte{})
+		if err != nil {
+			return err
+		}
+	}
+
 	genesis := len(ver.Inputs[0].Genesis) > 0
 	for _, utxo := range ver.UnspentOutputs() {
 		err := writeUTXO(txn, utxo, ver, snap.Timestamp, genesis)
@@ -179,6 +213,14 @@ func finalizeTransaction(txn *badger.Txn, ver *common.VersionedTransaction, snap
 		}
 	}
 
+	err = writeAssetFlow(txn, ver, snap.TopologicalOrder)
+	if err != nil {
+		return err
+	}
+	err = writeStateDelta(txn, ver, snap)
+	if err != nil {
+		return err
+	}
 	return writeTotalInAsset(txn, ver)
 }
 
@@ -210,6 +252,12 @@ func writeUTXO(txn *badger.Txn, utxo *common.UTXOWithLock, ver *common.Versioned
 		return writeNodeAccept(txn, signer, payee, utxo.Hash, timestamp, genesis)
 	case common.OutputTypeNodeRemove:
 		return writeNodeRemove(txn, signer, payee, utxo.Hash, timestamp)
+	case common.OutputTypeNodePayeeChange:
+		change, err := common.ParseNodePayeeChange(ver.Extra)
+		if err != nil {
+			return err
+		}
+		return writeNodePayeeChange(txn, change, utxo.Hash, timestamp)
 	case common.OutputTypeCustodianUpdateNodes:
 		return writeCustodianNodes(txn, timestamp, utxo, ver.Extra, genesis)
 	case common.OutputTypeWithdrawalClaim:
@@ -0,0 +1,482 @@
+package storage
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/dgraph-io/badger/v4"
+)
+
+const (
+	graphPrefixWalletAccount    = "WALLETACCOUNT"
+	graphPrefixWalletUTXO       = "WALLETUTXO"
+	graphPrefixWalletOwner      = "WALLETOWNER"
+	graphPrefixWalletSequence   = "WALLETSEQUENCE"
+	graphPrefixWalletSubaddress = "WALLETSUBADDRESS"
+
+	WalletAccountsLimit     = 1024
+	WalletSequenceGapLimit  = 256
+	WalletSubaddressesLimit = 65536
+)
+
+// the wallet sequence derives the deposit addresses from the master address,
+// the scanner watches the addresses up to the gap limit after the last used
+// one, and extends the watched addresses when an output is found.
+type WalletSequence struct {
+	Master *common.Address
+	Gap    uint64
+	Next   uint64
+
+	derived uint64
+}
+
+type walletDerivation struct {
+	master   crypto.Key
+	sequence uint64
+}
+
+// the subaddresses are derived from the master address by the payment ids,
+// they share the master view key, so the outputs are viewed once for each
+// view key, and the payment id is found by the derived spend key.
+type walletSubaddress struct {
+	address   *common.Address
+	master    crypto.Key
+	paymentId string
+}
+
+type WalletOutput struct {
+	Account   crypto.Key     `json:"account"`
+	Hash      crypto.Hash    `json:"hash"`
+	Index     uint           `json:"index"`
+	KeyIndex  int            `json:"key_index"`
+	Asset     crypto.Hash    `json:"asset"`
+	Amount    common.Integer `json:"amount"`
+	Snapshot  crypto.Hash    `json:"snapshot"`
+	Timestamp uint64         `json:"timestamp"`
+	SpentBy   crypto.Hash    `json:"spent_by"`
+	PaymentId string         `json:"payment_id,omitempty"`
+}
+
+// the wallet scanner indexes the outputs of the registered watch-only
+// accounts as the snapshots finalize, an account is the private view key
+// with the public spend key, and only the outputs finalized after the
+// registration are indexed.
+func (s *BadgerStore) RegisterWalletAccount(addr *common.Address) error {
+	if !s.custom.Wallet.Scanner {
+		return fmt.Errorf("wallet scanner disabled")
+	}
+	if addr.PrivateViewKey.Public() != addr.PublicViewKey {
+		return fmt.Errorf("invalid wallet account view key %s", addr.PublicViewKey)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.walletAccounts[addr.PublicSpendKey] != nil {
+		return nil
+	}
+	if len(s.walletAccounts) >= WalletAccountsLimit {
+		return fmt.Errorf("too many wallet accounts %d", len(s.walletAccounts))
+	}
+	val := append(addr.PrivateViewKey[:], addr.PublicSpendKey[:]...)
+	err := s.snapshotsDB.Update(func(txn *badger.Txn) error {
+		return txn.Set(graphWalletAccountKey(addr.PublicSpendKey), val)
+	})
+	if err != nil {
+		return err
+	}
+	s.walletAccounts[addr.PublicSpendKey] = addr
+	return nil
+}
+
+func (s *BadgerStore) RegisterWalletSequence(master *common.Address, gap uint64) (*WalletSequence, error) {
+	if !s.custom.Wallet.Scanner {
+		return nil, fmt.Errorf("wallet scanner disabled")
+	}
+	if master.PrivateViewKey.Public() != master.PublicViewKey {
+		return nil, fmt.Errorf("invalid wallet sequence view key %s", master.PublicViewKey)
+	}
+	if gap == 0 || gap > WalletSequenceGapLimit {
+		return nil, fmt.Errorf("invalid wallet sequence gap %d", gap)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if seq := s.walletSequences[master.PublicSpendKey]; seq != nil {
+		return seq.copy(), nil
+	}
+	if len(s.walletAccounts)+int(gap) > WalletAccountsLimit {
+		return nil, fmt.Errorf("too many wallet accounts %d", len(s.walletAccounts))
+	}
+	seq := &WalletSequence{
+		Master: &common.Address{
+			PrivateViewKey: master.PrivateViewKey,
+			PublicViewKey:  master.PublicViewKey,
+			PublicSpendKey: master.PublicSpendKey,
+		},
+		Gap: gap,
+	}
+	err := s.snapshotsDB.Update(func(txn *badger.Txn) error {
+		return txn.Set(graphWalletSequenceKey(master.PublicSpendKey), seq.marshal())
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.walletSequences[master.PublicSpendKey] = seq
+	s.extendWalletSequence(seq)
+	return seq.copy(), nil
+}
+
+func (s *BadgerStore) RegisterWalletSubaddress(master *common.Address, paymentId string) (*common.Address, error) {
+	if !s.custom.Wallet.Scanner {
+		return nil, fmt.Errorf("wallet scanner disabled")
+	}
+	if master.PrivateViewKey.Public() != master.PublicViewKey {
+		return nil, fmt.Errorf("invalid wallet subaddress view key %s", master.PublicViewKey)
+	}
+	watch := common.Address{
+		PrivateViewKey: master.PrivateViewKey,
+		PublicViewKey:  master.PublicViewKey,
+		PublicSpendKey: master.PublicSpendKey,
+	}
+	addr, err := watch.DeriveSubaddress([]byte(paymentId))
+	if err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.walletSubaddresses[addr.PublicSpendKey] != nil {
+		return &addr, nil
+	}
+	if len(s.walletSubaddresses) >= WalletSubaddressesLimit {
+		return nil, fmt.Errorf("too many wallet subaddresses %d", len(s.walletSubaddresses))
+	}
+	val := append(master.PrivateViewKey[:], master.PublicSpendKey[:]...)
+	val = append(val, paymentId...)
+	err = s.snapshotsDB.Update(func(txn *badger.Txn) error {
+		return txn.Set(graphWalletSubaddressKey(addr.PublicSpendKey), val)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.walletSubaddresses[addr.PublicSpendKey] = &walletSubaddress{
+		address:   &addr,
+		master:    master.PublicSpendKey,
+		paymentId: paymentId,
+	}
+	s.walletSubaddressViews[master.PrivateViewKey] = true
+	return &addr, nil
+}
+
+func (s *BadgerStore) ReadWalletSequence(master crypto.Key) (*WalletSequence, error) {
+	if !s.custom.Wallet.Scanner {
+		return nil, fmt.Errorf("wallet scanner disabled")
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	seq := s.walletSequences[master]
+	if seq == nil {
+		return nil, nil
+	}
+	return seq.copy(), nil
+}
+
+func (s *BadgerStore) ListWalletOutputs(account crypto.Key, asset crypto.Hash, spent bool) ([]*WalletOutput, error) {
+	if !s.custom.Wallet.Scanner {
+		return nil, fmt.Errorf("wallet scanner disabled")
+	}
+
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	opts.Prefix = append([]byte(graphPrefixWalletOwner), account[:]...)
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	var outputs []*WalletOutput
+	for it.Seek(opts.Prefix); it.Valid(); it.Next() {
+		key := it.Item().KeyCopy(nil)
+		item, err := txn.Get(append([]byte(graphPrefixWalletUTXO), key[len(opts.Prefix):]...))
+		if err != nil {
+			return nil, err
+		}
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return nil, err
+		}
+		var out WalletOutput
+		err = json.Unmarshal(val, &out)
+		if err != nil {
+			return nil, err
+		}
+		if asset.HasValue() && out.Asset != asset {
+			continue
+		}
+		if out.SpentBy.HasValue() && !spent {
+			continue
+		}
+		outputs = append(outputs, &out)
+	}
+	return outputs, nil
+}
+
+func (s *BadgerStore) loadWalletAccounts() error {
+	s.walletAccounts = make(map[crypto.Key]*common.Address)
+	s.walletSequences = make(map[crypto.Key]*WalletSequence)
+	s.walletDerivations = make(map[crypto.Key]*walletDerivation)
+	s.walletSubaddresses = make(map[crypto.Key]*walletSubaddress)
+	s.walletSubaddressViews = make(map[crypto.Key]bool)
+	if !s.custom.Wallet.Scanner {
+		return nil
+	}
+
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = []byte(graphPrefixWalletAccount)
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	for it.Seek(opts.Prefix); it.Valid(); it.Next() {
+		val, err := it.Item().ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		var addr common.Address
+		copy(addr.PrivateViewKey[:], val[:32])
+		copy(addr.PublicSpendKey[:], val[32:])
+		addr.PublicViewKey = addr.PrivateViewKey.Public()
+		s.walletAccounts[addr.PublicSpendKey] = &addr
+	}
+	it.Close()
+
+	opts.Prefix = []byte(graphPrefixWalletSequence)
+	it = txn.NewIterator(opts)
+	defer it.Close()
+
+	for it.Seek(opts.Prefix); it.Valid(); it.Next() {
+		val, err := it.Item().ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		seq := unmarshalWalletSequence(val)
+		s.walletSequences[seq.Master.PublicSpendKey] = seq
+		s.extendWalletSequence(seq)
+	}
+	it.Close()
+
+	opts.Prefix = []byte(graphPrefixWalletSubaddress)
+	it = txn.NewIterator(opts)
+	defer it.Close()
+
+	for it.Seek(opts.Prefix); it.Valid(); it.Next() {
+		val, err := it.Item().ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		var master common.Address
+		copy(master.PrivateViewKey[:], val[:32])
+		copy(master.PublicSpendKey[:], val[32:64])
+		master.PublicViewKey = master.PrivateViewKey.Public()
+		addr, err := master.DeriveSubaddress(val[64:])
+		if err != nil {
+			return err
+		}
+		s.walletSubaddresses[addr.PublicSpendKey] = &walletSubaddress{
+			address:   &addr,
+			master:    master.PublicSpendKey,
+			paymentId: string(val[64:]),
+		}
+		s.walletSubaddressViews[master.PrivateViewKey] = true
+	}
+	return nil
+}
+
+func (s *BadgerStore) extendWalletSequence(seq *WalletSequence) {
+	for ; seq.derived < seq.Next+seq.Gap; seq.derived++ {
+		addr := seq.Master.DeriveSequenceAddress(seq.derived)
+		s.walletAccounts[addr.PublicSpendKey] = &addr
+		s.walletDerivations[addr.PublicSpendKey] = &walletDerivation{
+			master:   seq.Master.PublicSpendKey,
+			sequence: seq.derived,
+		}
+	}
+}
+
+func (s *BadgerStore) useWalletSequence(txn *badger.Txn, account crypto.Key) error {
+	d := s.walletDerivations[account]
+	if d == nil {
+		return nil
+	}
+	seq := s.walletSequences[d.master]
+	if d.sequence < seq.Next {
+		return nil
+	}
+	seq.Next = d.sequence + 1
+	s.extendWalletSequence(seq)
+	return txn.Set(graphWalletSequenceKey(d.master), seq.marshal())
+}
+
+func (s *BadgerStore) writeWalletOutputs(txn *badger.Txn, snap *common.SnapshotWithTopologicalOrder, ver *common.VersionedTransaction) error {
+	if len(s.walletAccounts) == 0 && len(s.walletSubaddresses) == 0 {
+		return nil
+	}
+
+	hash := ver.PayloadHash()
+	for _, in := range ver.Inputs {
+		if in.Mint != nil || in.Deposit != nil || len(in.Genesis) > 0 {
+			continue
+		}
+		key := graphWalletUTXOKey(in.Hash, in.Index)
+		item, err := txn.Get(key)
+		if err == badger.ErrKeyNotFound {
+			continue
+		} else if err != nil {
+			return err
+		}
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		var out WalletOutput
+		err = json.Unmarshal(val, &out)
+		if err != nil {
+			return err
+		}
+		out.SpentBy = hash
+		val, err = json.Marshal(out)
+		if err != nil {
+			return err
+		}
+		err = txn.Set(key, val)
+		if err != nil {
+			return err
+		}
+	}
+
+	// the sequence addresses and subaddresses share the same view key, so
+	// the ghost keys are viewed once for each view key and matched by the
+	// spend key
+	views := make(map[crypto.Key]bool)
+	for _, addr := range s.walletAccounts {
+		views[addr.PrivateViewKey] = true
+	}
+	for view := range s.walletSubaddressViews {
+		views[view] = true
+	}
+	for i, o := range ver.Outputs {
+		if o.Type != common.OutputTypeScript || !o.Mask.HasValue() {
+			continue
+		}
+		for view := range views {
+			addr, paymentId, ki := s.viewWalletOutput(o, view, uint(i))
+			if addr == nil {
+				continue
+			}
+			out := &WalletOutput{
+				Account:   addr.PublicSpendKey,
+				Hash:      hash,
+				Index:     uint(i),
+				KeyIndex:  ki,
+				Asset:     ver.Asset,
+				Amount:    o.Amount,
+				Snapshot:  snap.PayloadHash(),
+				Timestamp: snap.Timestamp,
+				PaymentId: paymentId,
+			}
+			val, err := json.Marshal(out)
+			if err != nil {
+				return err
+			}
+			err = txn.Set(graphWalletUTXOKey(hash, uint(i)), val)
+			if err != nil {
+				return err
+			}
+			err = txn.Set(graphWalletOwnerKey(addr.PublicSpendKey, hash, uint(i)), []byte{})
+			if err != nil {
+				return err
+			}
+			err = s.useWalletSequence(txn, addr.PublicSpendKey)
+			if err != nil {
+				return err
+			}
+			break
+		}
+	}
+	return nil
+}
+
+func (s *BadgerStore) viewWalletOutput(o *common.Output, view crypto.Key, index uint) (*common.Address, string, int) {
+	for i, k := range o.Keys {
+		spend := crypto.ViewGhostOutputKey(k, &view, &o.Mask, uint64(index))
+		addr := s.walletAccounts[*spend]
+		if addr != nil && addr.PrivateViewKey == view {
+			return addr, "", i
+		}
+		sub := s.walletSubaddresses[*spend]
+		if sub != nil && sub.address.PrivateViewKey == view {
+			return sub.address, sub.paymentId, i
+		}
+	}
+	return nil, "", -1
+}
+
+func (seq *WalletSequence) copy() *WalletSequence {
+	return &WalletSequence{
+		Master: seq.Master,
+		Gap:    seq.Gap,
+		Next:   seq.Next,
+	}
+}
+
+func (seq *WalletSequence) marshal() []byte {
+	val := append(seq.Master.PrivateViewKey[:], seq.Master.PublicSpendKey[:]...)
+	val = binary.BigEndian.AppendUint64(val, seq.Gap)
+	return binary.BigEndian.AppendUint64(val, seq.Next)
+}
+
+func unmarshalWalletSequence(val []byte) *WalletSequence {
+	var addr common.Address
+	copy(addr.PrivateViewKey[:], val[:32])
+	copy(addr.PublicSpendKey[:], val[32:64])
+	addr.PublicViewKey = addr.PrivateViewKey.Public()
+	return &WalletSequence{
+		Master: &addr,
+		Gap:    binary.BigEndian.Uint64(val[64:72]),
+		Next:   binary.BigEndian.Uint64(val[72:80]),
+	}
+}
+
+func graphWalletAccountKey(spend crypto.Key) []byte {
+	return append([]byte(graphPrefixWalletAccount), spend[:]...)
+}
+
+func graphWalletSequenceKey(master crypto.Key) []byte {
+	return append([]byte(graphPrefixWalletSequence), master[:]...)
+}
+
+func graphWalletSubaddressKey(spend crypto.Key) []byte {
+	return append([]byte(graphPrefixWalletSubaddress), spend[:]...)
+}
+
+func graphWalletUTXOKey(hash crypto.Hash, index uint) []byte {
+	key := append([]byte(graphPrefixWalletUTXO), hash[:]...)
+	return binary.BigEndian.AppendUint64(key, uint64(index))
+}
+
+func graphWalletOwnerKey(spend crypto.Key, hash crypto.Hash, index uint) []byte {
+	key := append([]byte(graphPrefixWalletOwner), spend[:]...)
+	key = append(key, hash[:]...)
+	return binary.BigEndian.AppendUint64(key, uint64(index))
+}
@@ -0,0 +1,262 @@
+package storage
+
+import (
+	"os"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/dgraph-io/badger/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWalletScanner(t *testing.T) {
+	require := require.New(t)
+	custom, err := config.Initialize("../config/config.example.toml")
+	require.Nil(err)
+
+	root, err := os.MkdirTemp("", "mixin-wallet-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	store, err := NewBadgerStore(custom, root)
+	require.Nil(err)
+
+	seed := make([]byte, 64)
+	seed[0] = 1
+	account := common.NewAddressFromSeed(seed)
+	watch := &common.Address{
+		PrivateViewKey: account.PrivateViewKey,
+		PublicViewKey:  account.PublicViewKey,
+		PublicSpendKey: account.PublicSpendKey,
+	}
+	err = store.RegisterWalletAccount(watch)
+	require.NotNil(err)
+	store.Close()
+
+	custom.Wallet.Scanner = true
+	store, err = NewBadgerStore(custom, root)
+	require.Nil(err)
+	err = store.RegisterWalletAccount(&common.Address{PublicSpendKey: account.PublicSpendKey})
+	require.NotNil(err)
+	err = store.RegisterWalletAccount(watch)
+	require.Nil(err)
+	store.Close()
+
+	store, err = NewBadgerStore(custom, root)
+	require.Nil(err)
+	defer store.Close()
+	require.Len(store.walletAccounts, 1)
+
+	other := common.NewAddressFromSeed(make([]byte, 64))
+	tx := common.NewTransactionV5(common.XINAssetId).AsVersioned()
+	tx.AddInput(crypto.Blake3Hash([]byte("genesis")), 0)
+	tx.AddRandomScriptOutput([]*common.Address{&other}, common.NewThresholdScript(1), common.NewInteger(100))
+	tx.AddRandomScriptOutput([]*common.Address{&other, &account}, common.NewThresholdScript(1), common.NewInteger(200))
+	snap := &common.SnapshotWithTopologicalOrder{
+		Snapshot: &common.Snapshot{
+			Version:      common.SnapshotVersionCommonEncoding,
+			Timestamp:    1,
+			Transactions: []crypto.Hash{tx.PayloadHash()},
+		},
+		TopologicalOrder: 1,
+	}
+	err = store.snapshotsDB.Update(func(txn *badger.Txn) error {
+		return store.writeWalletOutputs(txn, snap, tx)
+	})
+	require.Nil(err)
+
+	outputs, err := store.ListWalletOutputs(account.PublicSpendKey, crypto.Hash{}, false)
+	require.Nil(err)
+	require.Len(outputs, 1)
+	require.Equal(tx.PayloadHash(), outputs[0].Hash)
+	require.Equal(uint(1), outputs[0].Index)
+	require.Equal(1, outputs[0].KeyIndex)
+	require.Equal("200.00000000", outputs[0].Amount.String())
+	outputs, err = store.ListWalletOutputs(account.PublicSpendKey, crypto.Blake3Hash([]byte("asset")), false)
+	require.Nil(err)
+	require.Len(outputs, 0)
+	outputs, err = store.ListWalletOutputs(other.PublicSpendKey, crypto.Hash{}, true)
+	require.Nil(err)
+	require.Len(outputs, 0)
+
+	spend := common.NewTransactionV5(common.XINAssetId).AsVersioned()
+	spend.AddInput(tx.PayloadHash(), 1)
+	spend.AddRandomScriptOutput([]*common.Address{&other}, common.NewThresholdScript(1), common.NewInteger(200))
+	err = store.snapshotsDB.Update(func(txn *badger.Txn) error {
+		return store.writeWalletOutputs(txn, snap, spend)
+	})
+	require.Nil(err)
+	outputs, err = store.ListWalletOutputs(account.PublicSpendKey, crypto.Hash{}, false)
+	require.Nil(err)
+	require.Len(outputs, 0)
+	outputs, err = store.ListWalletOutputs(account.PublicSpendKey, common.XINAssetId, true)
+	require.Nil(err)
+	require.Len(outputs, 1)
+	require.Equal(spend.PayloadHash(), outputs[0].SpentBy)
+}
+
+func TestWalletSequence(t *testing.T) {
+	require := require.New(t)
+	custom, err := config.Initialize("../config/config.example.toml")
+	require.Nil(err)
+	custom.Wallet.Scanner = true
+
+	root, err := os.MkdirTemp("", "mixin-wallet-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	store, err := NewBadgerStore(custom, root)
+	require.Nil(err)
+
+	seed := make([]byte, 64)
+	seed[0] = 2
+	master := common.NewAddressFromSeed(seed)
+	watch := &common.Address{
+		PrivateViewKey: master.PrivateViewKey,
+		PublicViewKey:  master.PublicViewKey,
+		PublicSpendKey: master.PublicSpendKey,
+	}
+	_, err = store.RegisterWalletSequence(watch, 0)
+	require.NotNil(err)
+	_, err = store.RegisterWalletSequence(watch, WalletSequenceGapLimit+1)
+	require.NotNil(err)
+	seq, err := store.RegisterWalletSequence(watch, 2)
+	require.Nil(err)
+	require.Equal(uint64(2), seq.Gap)
+	require.Equal(uint64(0), seq.Next)
+	require.Len(store.walletAccounts, 2)
+
+	write := func(sequence uint64) *common.VersionedTransaction {
+		addr := master.DeriveSequenceAddress(sequence)
+		tx := common.NewTransactionV5(common.XINAssetId).AsVersioned()
+		tx.AddInput(crypto.Blake3Hash([]byte{byte(sequence)}), 0)
+		tx.AddRandomScriptOutput([]*common.Address{&addr}, common.NewThresholdScript(1), common.NewInteger(100))
+		snap := &common.SnapshotWithTopologicalOrder{
+			Snapshot: &common.Snapshot{
+				Version:      common.SnapshotVersionCommonEncoding,
+				Timestamp:    1,
+				Transactions: []crypto.Hash{tx.PayloadHash()},
+			},
+		}
+		err := store.snapshotsDB.Update(func(txn *badger.Txn) error {
+			return store.writeWalletOutputs(txn, snap, tx)
+		})
+		require.Nil(err)
+		return tx
+	}
+	list := func(sequence uint64) []*WalletOutput {
+		addr := master.DeriveSequenceAddress(sequence)
+		outputs, err := store.ListWalletOutputs(addr.PublicSpendKey, crypto.Hash{}, false)
+		require.Nil(err)
+		return outputs
+	}
+
+	write(2)
+	require.Len(list(2), 0)
+	tx := write(1)
+	outputs := list(1)
+	require.Len(outputs, 1)
+	require.Equal(tx.PayloadHash(), outputs[0].Hash)
+	seq, err = store.ReadWalletSequence(master.PublicSpendKey)
+	require.Nil(err)
+	require.Equal(uint64(2), seq.Next)
+	require.Len(store.walletAccounts, 4)
+	write(3)
+	require.Len(list(3), 1)
+	store.Close()
+
+	store, err = NewBadgerStore(custom, root)
+	require.Nil(err)
+	defer store.Close()
+	seq, err = store.ReadWalletSequence(master.PublicSpendKey)
+	require.Nil(err)
+	require.Equal(uint64(4), seq.Next)
+	require.Len(store.walletAccounts, 6)
+	write(5)
+	require.Len(list(5), 1)
+	seq, err = store.RegisterWalletSequence(watch, 8)
+	require.Nil(err)
+	require.Equal(uint64(2), seq.Gap)
+	require.Equal(uint64(6), seq.Next)
+}
+
+func TestWalletSubaddress(t *testing.T) {
+	require := require.New(t)
+	custom, err := config.Initialize("../config/config.example.toml")
+	require.Nil(err)
+	custom.Wallet.Scanner = true
+
+	root, err := os.MkdirTemp("", "mixin-wallet-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	store, err := NewBadgerStore(custom, root)
+	require.Nil(err)
+
+	seed := make([]byte, 64)
+	seed[0] = 3
+	master := common.NewAddressFromSeed(seed)
+	watch := &common.Address{
+		PrivateViewKey: master.PrivateViewKey,
+		PublicViewKey:  master.PublicViewKey,
+		PublicSpendKey: master.PublicSpendKey,
+	}
+	_, err = store.RegisterWalletSubaddress(watch, "")
+	require.NotNil(err)
+	sub, err := store.RegisterWalletSubaddress(watch, "customer-1")
+	require.Nil(err)
+	expected, err := master.DeriveSubaddress([]byte("customer-1"))
+	require.Nil(err)
+	require.Equal(expected.String(), sub.String())
+	require.Len(store.walletAccounts, 0)
+	require.Len(store.walletSubaddresses, 1)
+
+	write := func(paymentId string) {
+		addr, err := master.DeriveSubaddress([]byte(paymentId))
+		require.Nil(err)
+		tx := common.NewTransactionV5(common.XINAssetId).AsVersioned()
+		tx.AddInput(crypto.Blake3Hash([]byte(paymentId)), 0)
+		tx.AddRandomScriptOutput([]*common.Address{&addr}, common.NewThresholdScript(1), common.NewInteger(100))
+		snap := &common.SnapshotWithTopologicalOrder{
+			Snapshot: &common.Snapshot{
+				Version:      common.SnapshotVersionCommonEncoding,
+				Timestamp:    1,
+				Transactions: []crypto.Hash{tx.PayloadHash()},
+			},
+		}
+		err = store.snapshotsDB.Update(func(txn *badger.Txn) error {
+			return store.writeWalletOutputs(txn, snap, tx)
+		})
+		require.Nil(err)
+	}
+	list := func(paymentId string) []*WalletOutput {
+		addr, err := master.DeriveSubaddress([]byte(paymentId))
+		require.Nil(err)
+		outputs, err := store.ListWalletOutputs(addr.PublicSpendKey, crypto.Hash{}, false)
+		require.Nil(err)
+		return outputs
+	}
+
+	write("customer-2")
+	require.Len(list("customer-2"), 0)
+	write("customer-1")
+	outputs := list("customer-1")
+	require.Len(outputs, 1)
+	require.Equal("customer-1", outputs[0].PaymentId)
+	require.Equal(sub.PublicSpendKey, outputs[0].Account)
+	store.Close()
+
+	store, err = NewBadgerStore(custom, root)
+	require.Nil(err)
+	defer store.Close()
+	require.Len(store.walletSubaddresses, 1)
+	_, err = store.RegisterWalletSubaddress(watch, "customer-2")
+	require.Nil(err)
+	write("customer-2")
+	outputs = list("customer-2")
+	require.Len(outputs, 1)
+	require.Equal("customer-2", outputs[0].PaymentId)
+	require.Len(list("customer-1"), 1)
+}
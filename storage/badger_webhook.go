@@ -0,0 +1,378 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/dgraph-io/badger/v4"
+)
+
+const (
+	graphPrefixWebhook         = "WEBHOOKHOOK"
+	graphPrefixWebhookDelivery = "WEBHOOKDELIVERY"
+
+	WebhooksLimit             = 64
+	WebhookExtraPrefixMaxSize = 256
+	WebhookConfirmationsMax   = 1000000
+)
+
+// the webhook filters are all optional, and a transaction must match all
+// the present ones, the account is the private view key with the public
+// spend key to find the outputs, like the wallet scanner accounts. The
+// notification of a transaction is only delivered after the confirmations
+// count of snapshots are finalized after it in the topology.
+type Webhook struct {
+	Id            crypto.Hash `json:"id"`
+	URL           string      `json:"url"`
+	Asset         crypto.Hash `json:"asset"`
+	View          crypto.Key  `json:"view"`
+	Spend         crypto.Key  `json:"spend"`
+	ExtraPrefix   string      `json:"extra_prefix"`
+	Confirmations uint64      `json:"confirmations"`
+	Timestamp     uint64      `json:"timestamp"`
+
+	account *common.Address
+	extra   []byte
+}
+
+// the confirmations is the count of the snapshots finalized after the
+// sequence, it is updated whenever the notification is listed for delivery.
+type WebhookNotification struct {
+	Webhook       crypto.Hash `json:"webhook"`
+	Sequence      uint64      `json:"sequence"`
+	Snapshot      crypto.Hash `json:"snapshot"`
+	Transaction   crypto.Hash `json:"transaction"`
+	Asset         crypto.Hash `json:"asset"`
+	Timestamp     uint64      `json:"timestamp"`
+	Outputs       []uint      `json:"outputs"`
+	Extra         string      `json:"extra"`
+	Confirmations uint64      `json:"confirmations"`
+}
+
+// the deliveries are written in the same transaction with the snapshot, so
+// a finalized transaction is never missed, and a delivery is only removed
+// after the endpoint accepts it, thus delivered at least once.
+type WebhookDelivery struct {
+	Notification *WebhookNotification `json:"notification"`
+	Attempts     uint64               `json:"attempts"`
+	NextAttempt  uint64               `json:"next_attempt"`
+	LastError    string               `json:"last_error"`
+}
+
+func (s *BadgerStore) RegisterWebhook(hook *Webhook, timestamp uint64) (*Webhook, error) {
+	if !s.custom.Webhook.Enabled {
+		return nil, fmt.Errorf("webhook disabled")
+	}
+	err := hook.prepare()
+	if err != nil {
+		return nil, err
+	}
+	hook.Id = hook.hash()
+	hook.Timestamp = timestamp
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if old := s.webhooks[hook.Id]; old != nil {
+		return old, nil
+	}
+	if len(s.webhooks) >= WebhooksLimit {
+		return nil, fmt.Errorf("too many webhooks %d", len(s.webhooks))
+	}
+	val, err := json.Marshal(hook)
+	if err != nil {
+		panic(err)
+	}
+	err = s.snapshotsDB.Update(func(txn *badger.Txn) error {
+		return txn.Set(graphWebhookKey(hook.Id), val)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.webhooks[hook.Id] = hook
+	return hook, nil
+}
+
+// RemoveWebhook removes the webhook and drops all its pending deliveries.
+func (s *BadgerStore) RemoveWebhook(id crypto.Hash) error {
+	if !s.custom.Webhook.Enabled {
+		return fmt.Errorf("webhook disabled")
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.webhooks[id] == nil {
+		return fmt.Errorf("webhook %s not found", id)
+	}
+	err := s.snapshotsDB.DropPrefix(graphWebhookDeliveryPrefix(id))
+	if err != nil {
+		return err
+	}
+	err = s.snapshotsDB.Update(func(txn *badger.Txn) error {
+		return txn.Delete(graphWebhookKey(id))
+	})
+	if err != nil {
+		return err
+	}
+	delete(s.webhooks, id)
+	return nil
+}
+
+func (s *BadgerStore) ListWebhooks() ([]*Webhook, error) {
+	if !s.custom.Webhook.Enabled {
+		return nil, fmt.Errorf("webhook disabled")
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	hooks := make([]*Webhook, 0)
+	for _, h := range s.webhooks {
+		hooks = append(hooks, h)
+	}
+	return hooks, nil
+}
+
+// ListWebhookDeliveries returns the due deliveries in order, a webhook is
+// skipped entirely when its earliest delivery is not due, or not confirmed
+// by enough snapshots after it, so the deliveries of a webhook are always in
+// the topological order.
+func (s *BadgerStore) ListWebhookDeliveries(now uint64, limit int) ([]*WebhookDelivery, error) {
+	if !s.custom.Webhook.Enabled {
+		return nil, fmt.Errorf("webhook disabled")
+	}
+
+	topology := s.TopologySequence()
+	confirmations := make(map[crypto.Hash]uint64)
+	s.mutex.RLock()
+	for id, h := range s.webhooks {
+		confirmations[id] = h.Confirmations
+	}
+	s.mutex.RUnlock()
+
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = []byte(graphPrefixWebhookDelivery)
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	var deliveries []*WebhookDelivery
+	for it.Seek(opts.Prefix); it.Valid() && len(deliveries) < limit; {
+		val, err := it.Item().ValueCopy(nil)
+		if err != nil {
+			return nil, err
+		}
+		var d WebhookDelivery
+		err = json.Unmarshal(val, &d)
+		if err != nil {
+			return nil, err
+		}
+		n := d.Notification
+		if n.Sequence <= topology {
+			n.Confirmations = topology - n.Sequence
+		}
+		c := confirmations[n.Webhook]
+		if d.NextAttempt > now || (c > 0 && n.Sequence+c > topology) {
+			next := graphWebhookDeliveryPrefix(n.Webhook)
+			it.Seek(append(next, bytes.Repeat([]byte{0xff}, 9)...))
+			continue
+		}
+		deliveries = append(deliveries, &d)
+		it.Next()
+	}
+	return deliveries, nil
+}
+
+// CountWebhookDeliveries returns the number of pending deliveries of the webhook.
+func (s *BadgerStore) CountWebhookDeliveries(id crypto.Hash) (uint64, error) {
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	opts.Prefix = graphWebhookDeliveryPrefix(id)
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	var count uint64
+	for it.Seek(opts.Prefix); it.Valid(); it.Next() {
+		count++
+	}
+	return count, nil
+}
+
+func (s *BadgerStore) AckWebhookDelivery(d *WebhookDelivery) error {
+	n := d.Notification
+	return s.snapshotsDB.Update(func(txn *badger.Txn) error {
+		return txn.Delete(graphWebhookDeliveryKey(n.Webhook, n.Sequence))
+	})
+}
+
+func (s *BadgerStore) RetryWebhookDelivery(d *WebhookDelivery) error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	n := d.Notification
+	if s.webhooks[n.Webhook] == nil {
+		return nil
+	}
+	val, err := json.Marshal(d)
+	if err != nil {
+		panic(err)
+	}
+	return s.snapshotsDB.Update(func(txn *badger.Txn) error {
+		return txn.Set(graphWebhookDeliveryKey(n.Webhook, n.Sequence), val)
+	})
+}
+
+func (s *BadgerStore) loadWebhooks() error {
+	s.webhooks = make(map[crypto.Hash]*Webhook)
+	if !s.custom.Webhook.Enabled {
+		return nil
+	}
+
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = []byte(graphPrefixWebhook)
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	for it.Seek(opts.Prefix); it.Valid(); it.Next() {
+		val, err := it.Item().ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		var hook Webhook
+		err = json.Unmarshal(val, &hook)
+		if err != nil {
+			return err
+		}
+		err = hook.prepare()
+		if err != nil {
+			return err
+		}
+		s.webhooks[hook.Id] = &hook
+	}
+	return nil
+}
+
+func (s *BadgerStore) writeWebhookDeliveries(txn *badger.Txn, snap *common.SnapshotWithTopologicalOrder, ver *common.VersionedTransaction) error {
+	if len(s.webhooks) == 0 {
+		return nil
+	}
+
+	for _, hook := range s.webhooks {
+		outputs, matched := hook.match(ver)
+		if !matched {
+			continue
+		}
+		d := &WebhookDelivery{
+			Notification: &WebhookNotification{
+				Webhook:     hook.Id,
+				Sequence:    snap.TopologicalOrder,
+				Snapshot:    snap.PayloadHash(),
+				Transaction: ver.PayloadHash(),
+				Asset:       ver.Asset,
+				Timestamp:   snap.Timestamp,
+				Outputs:     outputs,
+				Extra:       hex.EncodeToString(ver.Extra),
+			},
+		}
+		val, err := json.Marshal(d)
+		if err != nil {
+			panic(err)
+		}
+		err = txn.Set(graphWebhookDeliveryKey(hook.Id, snap.TopologicalOrder), val)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (hook *Webhook) prepare() error {
+	u, err := url.Parse(hook.URL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("invalid webhook url %s", hook.URL)
+	}
+	hook.extra, err = hex.DecodeString(hook.ExtraPrefix)
+	if err != nil {
+		return err
+	}
+	if len(hook.extra) > WebhookExtraPrefixMaxSize {
+		return fmt.Errorf("invalid webhook extra prefix size %d", len(hook.extra))
+	}
+	if hook.Confirmations > WebhookConfirmationsMax {
+		return fmt.Errorf("invalid webhook confirmations %d", hook.Confirmations)
+	}
+	hook.account = nil
+	if hook.View.HasValue() != hook.Spend.HasValue() {
+		return fmt.Errorf("invalid webhook account %s %s", hook.View.Public(), hook.Spend)
+	}
+	if hook.View.HasValue() {
+		hook.account = &common.Address{
+			PrivateViewKey: hook.View,
+			PublicViewKey:  hook.View.Public(),
+			PublicSpendKey: hook.Spend,
+		}
+	}
+	return nil
+}
+
+func (hook *Webhook) hash() crypto.Hash {
+	b := []byte(hook.URL)
+	b = append(b, hook.Asset[:]...)
+	b = append(b, hook.View[:]...)
+	b = append(b, hook.Spend[:]...)
+	b = append(b, hook.extra...)
+	// the hooks registered before the confirmations keep their ids
+	if hook.Confirmations > 0 {
+		b = binary.BigEndian.AppendUint64(b, hook.Confirmations)
+	}
+	return crypto.Blake3Hash(b)
+}
+
+func (hook *Webhook) match(ver *common.VersionedTransaction) ([]uint, bool) {
+	if hook.Asset.HasValue() && hook.Asset != ver.Asset {
+		return nil, false
+	}
+	if !bytes.HasPrefix(ver.Extra, hook.extra) {
+		return nil, false
+	}
+	outputs := make([]uint, 0)
+	if hook.account == nil {
+		return outputs, true
+	}
+	for i, o := range ver.Outputs {
+		if _, found := o.ViewKeyIndex(hook.account, uint(i)); found {
+			outputs = append(outputs, uint(i))
+		}
+	}
+	return outputs, len(outputs) > 0
+}
+
+func graphWebhookKey(id crypto.Hash) []byte {
+	return append([]byte(graphPrefixWebhook), id[:]...)
+}
+
+func graphWebhookDeliveryPrefix(id crypto.Hash) []byte {
+	return append([]byte(graphPrefixWebhookDelivery), id[:]...)
+}
+
+func graphWebhookDeliveryKey(id crypto.Hash, sequence uint64) []byte {
+	return binary.BigEndian.AppendUint64(graphWebhookDeliveryPrefix(id), sequence)
+}
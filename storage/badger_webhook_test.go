@@ -0,0 +1,169 @@
+package storage
+
+import (
+	"os"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/dgraph-io/badger/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookDeliveries(t *testing.T) {
+	require := require.New(t)
+	custom, err := config.Initialize("../config/config.example.toml")
+	require.Nil(err)
+
+	root, err := os.MkdirTemp("", "mixin-webhook-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	store, err := NewBadgerStore(custom, root)
+	require.Nil(err)
+	_, err = store.RegisterWebhook(&Webhook{URL: "http://127.0.0.1:8000"}, 1)
+	require.NotNil(err)
+	store.Close()
+
+	custom.Webhook.Enabled = true
+	store, err = NewBadgerStore(custom, root)
+	require.Nil(err)
+
+	seed := make([]byte, 64)
+	seed[0] = 1
+	account := common.NewAddressFromSeed(seed)
+	other := common.NewAddressFromSeed(make([]byte, 64))
+
+	_, err = store.RegisterWebhook(&Webhook{URL: "ftp://127.0.0.1:8000"}, 1)
+	require.NotNil(err)
+	_, err = store.RegisterWebhook(&Webhook{URL: "http://127.0.0.1:8000", Spend: account.PublicSpendKey}, 1)
+	require.NotNil(err)
+	all, err := store.RegisterWebhook(&Webhook{URL: "http://127.0.0.1:8000"}, 1)
+	require.Nil(err)
+	viewed, err := store.RegisterWebhook(&Webhook{
+		URL:         "http://127.0.0.1:8001",
+		Asset:       common.XINAssetId,
+		View:        account.PrivateViewKey,
+		Spend:       account.PublicSpendKey,
+		ExtraPrefix: "6d6978",
+	}, 1)
+	require.Nil(err)
+	dup, err := store.RegisterWebhook(&Webhook{URL: "http://127.0.0.1:8000"}, 2)
+	require.Nil(err)
+	require.Equal(all.Id, dup.Id)
+	require.Equal(uint64(1), dup.Timestamp)
+	store.Close()
+
+	store, err = NewBadgerStore(custom, root)
+	require.Nil(err)
+	defer store.Close()
+	hooks, err := store.ListWebhooks()
+	require.Nil(err)
+	require.Len(hooks, 2)
+
+	write := func(topology uint64, extra string, receivers ...*common.Address) *common.VersionedTransaction {
+		tx := common.NewTransactionV5(common.XINAssetId).AsVersioned()
+		tx.AddInput(crypto.Blake3Hash([]byte("genesis")), uint(topology))
+		tx.AddRandomScriptOutput([]*common.Address{&other}, common.NewThresholdScript(1), common.NewInteger(100))
+		tx.AddRandomScriptOutput(receivers, common.NewThresholdScript(1), common.NewInteger(200))
+		tx.Extra = []byte(extra)
+		snap := &common.SnapshotWithTopologicalOrder{
+			Snapshot: &common.Snapshot{
+				Version:      common.SnapshotVersionCommonEncoding,
+				Timestamp:    topology,
+				Transactions: []crypto.Hash{tx.PayloadHash()},
+			},
+			TopologicalOrder: topology,
+		}
+		err := store.snapshotsDB.Update(func(txn *badger.Txn) error {
+			return store.writeWebhookDeliveries(txn, snap, tx)
+		})
+		require.Nil(err)
+		return tx
+	}
+	write(1, "mixin", &other)
+	tx := write(2, "mixin", &account)
+	write(3, "other", &account)
+
+	deliveries, err := store.ListWebhookDeliveries(0, 10)
+	require.Nil(err)
+	require.Len(deliveries, 4)
+	count, err := store.CountWebhookDeliveries(all.Id)
+	require.Nil(err)
+	require.Equal(uint64(3), count)
+	count, err = store.CountWebhookDeliveries(viewed.Id)
+	require.Nil(err)
+	require.Equal(uint64(1), count)
+	for _, d := range deliveries {
+		n := d.Notification
+		if n.Webhook != viewed.Id {
+			continue
+		}
+		require.Equal(uint64(2), n.Sequence)
+		require.Equal(tx.PayloadHash(), n.Transaction)
+		require.Equal([]uint{1}, n.Outputs)
+	}
+
+	d := deliveries[0]
+	if d.Notification.Webhook != all.Id {
+		d = deliveries[1]
+	}
+	require.Equal(uint64(1), d.Notification.Sequence)
+	d.Attempts = 1
+	d.NextAttempt = 10
+	d.LastError = "timeout"
+	err = store.RetryWebhookDelivery(d)
+	require.Nil(err)
+	deliveries, err = store.ListWebhookDeliveries(5, 10)
+	require.Nil(err)
+	require.Len(deliveries, 1)
+	require.Equal(viewed.Id, deliveries[0].Notification.Webhook)
+	deliveries, err = store.ListWebhookDeliveries(10, 10)
+	require.Nil(err)
+	require.Len(deliveries, 4)
+
+	err = store.AckWebhookDelivery(d)
+	require.Nil(err)
+	count, err = store.CountWebhookDeliveries(all.Id)
+	require.Nil(err)
+	require.Equal(uint64(2), count)
+
+	err = store.RemoveWebhook(all.Id)
+	require.Nil(err)
+	err = store.RemoveWebhook(all.Id)
+	require.NotNil(err)
+	deliveries, err = store.ListWebhookDeliveries(0, 10)
+	require.Nil(err)
+	require.Len(deliveries, 1)
+	require.Equal(viewed.Id, deliveries[0].Notification.Webhook)
+
+	_, err = store.RegisterWebhook(&Webhook{URL: "http://127.0.0.1:8002", Confirmations: WebhookConfirmationsMax + 1}, 3)
+	require.NotNil(err)
+	confirmed, err := store.RegisterWebhook(&Webhook{URL: "http://127.0.0.1:8002", Confirmations: 2}, 3)
+	require.Nil(err)
+	require.NotEqual(all.Id, confirmed.Id)
+	write(4, "other", &other)
+	topology := func(order uint64) {
+		err := store.snapshotsDB.Update(func(txn *badger.Txn) error {
+			return txn.Set(graphTopologyKey(order), []byte{})
+		})
+		require.Nil(err)
+	}
+	topology(5)
+	deliveries, err = store.ListWebhookDeliveries(0, 10)
+	require.Nil(err)
+	require.Len(deliveries, 1)
+	require.Equal(viewed.Id, deliveries[0].Notification.Webhook)
+	require.Equal(uint64(3), deliveries[0].Notification.Confirmations)
+	topology(6)
+	deliveries, err = store.ListWebhookDeliveries(0, 10)
+	require.Nil(err)
+	require.Len(deliveries, 2)
+	for _, d := range deliveries {
+		if d.Notification.Webhook == confirmed.Id {
+			require.Equal(uint64(4), d.Notification.Sequence)
+			require.Equal(uint64(2), d.Notification.Confirmations)
+		}
+	}
+}
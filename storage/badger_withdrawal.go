@@ -2,6 +2,7 @@ package storage
 
 import (
 	"encoding/hex"
+	"fmt"
 
 	"github.com/MixinNetwork/mixin/common"
 	"github.com/MixinNetwork/mixin/crypto"
@@ -32,6 +33,41 @@ func (s *BadgerStore) ReadWithdrawalClaim(hash crypto.Hash) (*common.VersionedTr
 	return readTransactionAndFinalization(txn, claim)
 }
 
+const WithdrawalPendingClaimsListLimit = 500
+
+// only the withdrawal submissions finalized after the pending claim index
+// was introduced are listed, and the claimed ones are removed from the index.
+func (s *BadgerStore) ListPendingWithdrawalClaims(limit int) ([]*common.VersionedTransaction, error) {
+	if limit <= 0 || limit > WithdrawalPendingClaimsListLimit {
+		return nil, fmt.Errorf("invalid pending withdrawal claims limit %d", limit)
+	}
+
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	opts.Prefix = []byte(graphPrefixPendingClaim)
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	var submissions []*common.VersionedTransaction
+	for it.Seek(opts.Prefix); it.Valid() && len(submissions) < limit; it.Next() {
+		key := it.Item().KeyCopy(nil)
+		var hash crypto.Hash
+		copy(hash[:], key[len(graphPrefixPendingClaim):])
+		ver, err := readTransaction(txn, hash)
+		if err != nil {
+			return nil, err
+		}
+		if ver == nil {
+			panic(hash.String())
+		}
+		submissions = append(submissions, ver)
+	}
+	return submissions, nil
+}
+
 func writeWithdrawalClaim(txn *badger.Txn, hash, claim crypto.Hash) error {
 	tx, snap, err := readTransactionAndFinalization(txn, hash)
 	if err != nil {
@@ -41,9 +77,17 @@ func writeWithdrawalClaim(txn *badger.Txn, hash, claim crypto.Hash) error {
 		panic(claim.String())
 	}
 	key := graphWithdrawalClaimKey(hash)
-	return txn.Set(key, claim[:])
+	err = txn.Set(key, claim[:])
+	if err != nil {
+		return err
+	}
+	return txn.Delete(graphPendingClaimKey(hash))
 }
 
 func graphWithdrawalClaimKey(tx crypto.Hash) []byte {
 	return append([]byte(graphPrefixWithdrawal), tx[:]...)
 }
+
+func graphPendingClaimKey(tx crypto.Hash) []byte {
+	return append([]byte(graphPrefixPendingClaim), tx[:]...)
+}
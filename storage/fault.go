@@ -0,0 +1,225 @@
+package storage
+
+import (
+	"sync"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+// FaultStore wraps a store to inject the write errors and the slow reads, so
+// the tests could verify the error paths of the kernel. All the other methods
+// go to the wrapped store directly.
+type FaultStore struct {
+	Store
+	mutex  sync.RWMutex
+	errors map[string]error
+	delay  time.Duration
+}
+
+func NewFaultStore(store Store) *FaultStore {
+	return &FaultStore{
+		Store:  store,
+		errors: make(map[string]error),
+	}
+}
+
+// FailWrites makes the writes of the method names return the error, or all
+// the writes if no method names, and a nil error stops the failures.
+func (s *FaultStore) FailWrites(err error, methods ...string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(methods) == 0 && err == nil {
+		s.errors = make(map[string]error)
+		return
+	}
+	if len(methods) == 0 {
+		methods = []string{""}
+	}
+	for _, m := range methods {
+		if err == nil {
+			delete(s.errors, m)
+		} else {
+			s.errors[m] = err
+		}
+	}
+}
+
+// SlowReads delays all the reads of transactions, snapshots, rounds and
+// outputs by the duration, and a zero duration stops the delay.
+func (s *FaultStore) SlowReads(delay time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.delay = delay
+}
+
+func (s *FaultStore) write(method string) error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if err := s.errors[method]; err != nil {
+		return err
+	}
+	return s.errors[""]
+}
+
+func (s *FaultStore) read() {
+	s.mutex.RLock()
+	delay := s.delay
+	s.mutex.RUnlock()
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+}
+
+func (s *FaultStore) WriteTransaction(tx *common.VersionedTransaction) error {
+	if err := s.write("WriteTransaction"); err != nil {
+		return err
+	}
+	return s.Store.WriteTransaction(tx)
+}
+
+func (s *FaultStore) WriteSnapshot(snap *common.SnapshotWithTopologicalOrder, signers []crypto.Hash) error {
+	if err := s.write("WriteSnapshot"); err != nil {
+		return err
+	}
+	return s.Store.WriteSnapshot(snap, signers)
+}
+
+func (s *FaultStore) StartNewRound(node crypto.Hash, number uint64, references *common.RoundLink, finalStart uint64) error {
+	if err := s.write("StartNewRound"); err != nil {
+		return err
+	}
+	return s.Store.StartNewRound(node, number, references, finalStart)
+}
+
+func (s *FaultStore) UpdateEmptyHeadRound(node crypto.Hash, number uint64, references *common.RoundLink) error {
+	if err := s.write("UpdateEmptyHeadRound"); err != nil {
+		return err
+	}
+	return s.Store.UpdateEmptyHeadRound(node, number, references)
+}
+
+func (s *FaultStore) AddNodeOperation(tx *common.VersionedTransaction, timestamp, threshold uint64) error {
+	if err := s.write("AddNodeOperation"); err != nil {
+		return err
+	}
+	return s.Store.AddNodeOperation(tx, timestamp, threshold)
+}
+
+func (s *FaultStore) LockUTXOs(inputs []*common.Input, tx crypto.Hash, fork bool) error {
+	if err := s.write("LockUTXOs"); err != nil {
+		return err
+	}
+	return s.Store.LockUTXOs(inputs, tx, fork)
+}
+
+func (s *FaultStore) LockDepositInput(deposit *common.DepositData, tx crypto.Hash, fork bool) error {
+	if err := s.write("LockDepositInput"); err != nil {
+		return err
+	}
+	return s.Store.LockDepositInput(deposit, tx, fork)
+}
+
+func (s *FaultStore) LockGhostKeys(keys []*crypto.Key, tx crypto.Hash, fork bool) error {
+	if err := s.write("LockGhostKeys"); err != nil {
+		return err
+	}
+	return s.Store.LockGhostKeys(keys, tx, fork)
+}
+
+func (s *FaultStore) LockMintInput(mint *common.MintData, tx crypto.Hash, fork bool) error {
+	if err := s.write("LockMintInput"); err != nil {
+		return err
+	}
+	return s.Store.LockMintInput(mint, tx, fork)
+}
+
+func (s *FaultStore) CachePutTransaction(tx *common.VersionedTransaction) error {
+	if err := s.write("CachePutTransaction"); err != nil {
+		return err
+	}
+	return s.Store.CachePutTransaction(tx)
+}
+
+func (s *FaultStore) CacheRemoveTransactions(hashes []crypto.Hash) error {
+	if err := s.write("CacheRemoveTransactions"); err != nil {
+		return err
+	}
+	return s.Store.CacheRemoveTransactions(hashes)
+}
+
+func (s *FaultStore) CacheWriteSyncFrontier(nodeId crypto.Hash, requested, verified uint64, snapshots []*common.Snapshot) error {
+	if err := s.write("CacheWriteSyncFrontier"); err != nil {
+		return err
+	}
+	return s.Store.CacheWriteSyncFrontier(nodeId, requested, verified, snapshots)
+}
+
+func (s *FaultStore) CacheWriteChainQueue(chainId crypto.Hash, snapshots []*QueuedSnapshot) error {
+	if err := s.write("CacheWriteChainQueue"); err != nil {
+		return err
+	}
+	return s.Store.CacheWriteChainQueue(chainId, snapshots)
+}
+
+func (s *FaultStore) WriteRoundWork(nodeId crypto.Hash, round uint64, snapshots []*common.SnapshotWork, credit bool) error {
+	if err := s.write("WriteRoundWork"); err != nil {
+		return err
+	}
+	return s.Store.WriteRoundWork(nodeId, round, snapshots, credit)
+}
+
+func (s *FaultStore) WriteRoundSpaceAndState(space *common.RoundSpace) error {
+	if err := s.write("WriteRoundSpaceAndState"); err != nil {
+		return err
+	}
+	return s.Store.WriteRoundSpaceAndState(space)
+}
+
+func (s *FaultStore) WriteStateCheckpoint(number uint64, nodes crypto.Hash) (*StateCheckpoint, error) {
+	if err := s.write("WriteStateCheckpoint"); err != nil {
+		return nil, err
+	}
+	return s.Store.WriteStateCheckpoint(number, nodes)
+}
+
+func (s *FaultStore) ReadTransaction(hash crypto.Hash) (*common.VersionedTransaction, string, error) {
+	s.read()
+	return s.Store.ReadTransaction(hash)
+}
+
+func (s *FaultStore) ReadSnapshot(hash crypto.Hash) (*common.SnapshotWithTopologicalOrder, error) {
+	s.read()
+	return s.Store.ReadSnapshot(hash)
+}
+
+func (s *FaultStore) ReadSnapshotsSinceTopology(offset, count uint64) ([]*common.SnapshotWithTopologicalOrder, error) {
+	s.read()
+	return s.Store.ReadSnapshotsSinceTopology(offset, count)
+}
+
+func (s *FaultStore) ReadSnapshotsForNodeRound(nodeIdWithNetwork crypto.Hash, round uint64) ([]*common.SnapshotWithTopologicalOrder, error) {
+	s.read()
+	return s.Store.ReadSnapshotsForNodeRound(nodeIdWithNetwork, round)
+}
+
+func (s *FaultStore) ReadRound(hash crypto.Hash) (*common.Round, error) {
+	s.read()
+	return s.Store.ReadRound(hash)
+}
+
+func (s *FaultStore) ReadUTXOKeys(hash crypto.Hash, index uint) (*common.UTXOKeys, error) {
+	s.read()
+	return s.Store.ReadUTXOKeys(hash, index)
+}
+
+func (s *FaultStore) ReadUTXOLock(hash crypto.Hash, index uint) (*common.UTXOWithLock, error) {
+	s.read()
+	return s.Store.ReadUTXOLock(hash, index)
+}
+
+func (s *FaultStore) CacheGetTransaction(hash crypto.Hash) (*common.VersionedTransaction, error) {
+	s.read()
+	return s.Store.CacheGetTransaction(hash)
+}
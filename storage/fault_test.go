@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFaultStore(t *testing.T) {
+	require := require.New(t)
+	custom, err := config.Initialize("../config/config.example.toml")
+	require.Nil(err)
+
+	memory, err := NewMemoryStore(custom)
+	require.Nil(err)
+	store := NewFaultStore(memory)
+
+	gns, err := common.ReadGenesis("../config/genesis.json")
+	require.Nil(err)
+	rounds, snapshots, transactions, err := gns.BuildSnapshots()
+	require.Nil(err)
+	err = store.LoadGenesis(rounds, snapshots, transactions)
+	require.Nil(err)
+	loaded, err := store.CheckGenesisLoad(snapshots)
+	require.Nil(err)
+	require.True(loaded)
+	require.Equal(uint64(len(snapshots)-1), store.TopologySequence())
+
+	tx := common.NewTransactionV5(common.XINAssetId)
+	tx.AddDepositInput(&common.DepositData{
+		Chain:       common.XINAsset.Chain,
+		AssetKey:    common.XINAsset.AssetKey,
+		Transaction: "0xMIXINTODAMOONTRANSACTION0",
+		Amount:      common.NewInteger(10),
+	})
+	ver := tx.AsVersioned()
+
+	failure := errors.New("disk failure")
+	store.FailWrites(failure, "CachePutTransaction", "LockDepositInput")
+	require.ErrorIs(store.CachePutTransaction(ver), failure)
+	require.ErrorIs(store.LockDepositInput(ver.Inputs[0].Deposit, ver.PayloadHash(), false), failure)
+	store.FailWrites(nil, "LockDepositInput")
+	require.Nil(store.LockDepositInput(ver.Inputs[0].Deposit, ver.PayloadHash(), false))
+	require.Nil(store.WriteTransaction(ver))
+	store.FailWrites(nil, "CachePutTransaction")
+	require.Nil(store.CachePutTransaction(ver))
+	cached, err := store.CacheGetTransaction(ver.PayloadHash())
+	require.Nil(err)
+	require.Equal(ver.PayloadHash(), cached.PayloadHash())
+
+	store.FailWrites(failure)
+	require.ErrorIs(store.WriteTransaction(ver), failure)
+	require.ErrorIs(store.LockGhostKeys(nil, ver.PayloadHash(), false), failure)
+	_, err = store.WriteStateCheckpoint(1, crypto.Hash{})
+	require.ErrorIs(err, failure)
+	store.FailWrites(nil)
+	require.Nil(store.WriteTransaction(ver))
+
+	store.SlowReads(100 * time.Millisecond)
+	start := time.Now()
+	read, snap, err := store.ReadTransaction(ver.PayloadHash())
+	require.Nil(err)
+	require.Equal("", snap)
+	require.Equal(ver.PayloadHash(), read.PayloadHash())
+	require.GreaterOrEqual(time.Since(start), 100*time.Millisecond)
+	store.SlowReads(0)
+	start = time.Now()
+	_, err = store.ReadSnapshot(snapshots[0].PayloadHash())
+	require.Nil(err)
+	require.Less(time.Since(start), 100*time.Millisecond)
+
+	require.Nil(store.Close())
+	memory, err = NewMemoryStore(custom)
+	require.Nil(err)
+	defer memory.Close()
+	require.Equal(uint64(0), memory.TopologySequence())
+}
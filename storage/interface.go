@@ -1,6 +1,8 @@
 package storage
 
 import (
+	"io"
+
 	"github.com/MixinNetwork/mixin/common"
 	"github.com/MixinNetwork/mixin/crypto"
 )
@@ -10,14 +12,25 @@ type Store interface {
 
 	CheckGenesisLoad(snapshots []*common.SnapshotWithTopologicalOrder) (bool, error)
 	LoadGenesis(rounds []*common.Round, snapshots []*common.SnapshotWithTopologicalOrder, transactions []*common.VersionedTransaction) error
+	EnableDevGenesis()
 	ReadAssetWithBalance(id crypto.Hash) (*common.Asset, common.Integer, error)
+	ListAssetsWithBalance() ([]*AssetWithBalance, error)
+	ListAssetFlows() ([]*AssetFlow, error)
+	ReadAssetFlowSince() (uint64, bool, error)
+	WriteStateCheckpoint(number uint64, nodes crypto.Hash) (*StateCheckpoint, error)
+	ReadStateCheckpoint(number uint64) (*StateCheckpoint, error)
+	ListStateCheckpoints(limit int) ([]*StateCheckpoint, error)
+	ReadStateIndexSince() (uint64, bool, error)
 	ReadAllNodes(threshold uint64, withState bool) []*common.Node
+	ReadNodePayeeUpdates(threshold uint64) []*common.NodePayeeUpdate
 	AddNodeOperation(tx *common.VersionedTransaction, timestamp, threshold uint64) error
 	ReadTransaction(hash crypto.Hash) (*common.VersionedTransaction, string, error)
+	ReadTransactions(hashes []crypto.Hash) ([]*common.VersionedTransaction, []string, error)
 	WriteTransaction(tx *common.VersionedTransaction) error
 	StartNewRound(node crypto.Hash, number uint64, references *common.RoundLink, finalStart uint64) error
 	UpdateEmptyHeadRound(node crypto.Hash, number uint64, references *common.RoundLink) error
 	TopologySequence() uint64
+	ReadTopologyByTimestamp(ts uint64) (uint64, map[crypto.Hash]uint64, error)
 
 	ReadUTXOKeys(hash crypto.Hash, index uint) (*common.UTXOKeys, error)
 	ReadUTXOLock(hash crypto.Hash, index uint) (*common.UTXOWithLock, error)
@@ -25,6 +38,7 @@ type Store interface {
 	ReadDepositLock(deposit *common.DepositData) (crypto.Hash, error)
 	LockDepositInput(deposit *common.DepositData, tx crypto.Hash, fork bool) error
 	ReadWithdrawalClaim(hash crypto.Hash) (*common.VersionedTransaction, string, error)
+	ListPendingWithdrawalClaims(limit int) ([]*common.VersionedTransaction, error)
 	ReadGhostKeyLock(key crypto.Key) (*crypto.Hash, error)
 	LockGhostKeys(keys []*crypto.Key, tx crypto.Hash, fork bool) error
 	ReadSnapshot(hash crypto.Hash) (*common.SnapshotWithTopologicalOrder, error)
@@ -36,11 +50,21 @@ type Store interface {
 	WriteSnapshot(*common.SnapshotWithTopologicalOrder, []crypto.Hash) error
 	ReadCustodian(ts uint64) (*common.CustodianUpdateRequest, error)
 	ListCustodianUpdates() ([]*common.CustodianUpdateRequest, error)
+	WriteCustodianProposal(custodian *common.Address, node []byte) (*CustodianProposal, error)
+	ListCustodianProposals() ([]*CustodianProposal, error)
 
 	CachePutTransaction(tx *common.VersionedTransaction) error
 	CacheGetTransaction(hash crypto.Hash) (*common.VersionedTransaction, error)
 	CacheRetrieveTransactions(limit int) ([]*common.VersionedTransaction, error)
+	CacheListTransactions(typ uint8, limit int) ([]*common.VersionedTransaction, error)
 	CacheRemoveTransactions([]crypto.Hash) error
+	CacheTransactionStats() (int, int64, error)
+	CachePurgeTransactions(before uint64, prefix string) ([]crypto.Hash, error)
+	CacheWriteSyncFrontier(nodeId crypto.Hash, requested, verified uint64, snapshots []*common.Snapshot) error
+	CacheReadSyncFrontier(nodeId crypto.Hash) (uint64, uint64, error)
+	CacheReadSyncSnapshots(nodeId crypto.Hash, round uint64) ([]*common.Snapshot, error)
+	CacheWriteChainQueue(chainId crypto.Hash, snapshots []*QueuedSnapshot) error
+	CacheReadChainQueue(chainId crypto.Hash) ([]*QueuedSnapshot, error)
 
 	ReadLastMintDistribution(batch uint64) (*common.MintDistribution, error)
 	LockMintInput(mint *common.MintData, tx crypto.Hash, fork bool) error
@@ -58,4 +82,35 @@ type Store interface {
 
 	RemoveGraphEntries(prefix string) (int, error)
 	ValidateGraphEntries(networkId crypto.Hash, depth uint64) (int, int, error)
+	ReplayGraphChain(nodeId crypto.Hash, repair bool) (*ChainReplay, error)
+	VerifyGraphChainRange(nodeId crypto.Hash, from, to uint64) (*ChainReplay, error)
+	ListKeyPrefixes() ([]*KeyPrefixStat, error)
+	ReadDatabaseStats() []*DatabaseStat
+	CheckRecovery() ([]*RecoveryIssue, error)
+	RepairRecovery(issues []*RecoveryIssue) error
+	WriteReplication(w io.Writer, since uint64) error
+	LoadReplication(r io.Reader) (uint64, error)
+	ReadReplicaVersion() (uint64, error)
+	WriteReplicaVersion(next uint64) error
+
+	RegisterWalletAccount(addr *common.Address) error
+	ListWalletOutputs(account crypto.Key, asset crypto.Hash, spent bool) ([]*WalletOutput, error)
+	RegisterWalletSequence(master *common.Address, gap uint64) (*WalletSequence, error)
+	ReadWalletSequence(master crypto.Key) (*WalletSequence, error)
+	RegisterWalletSubaddress(master *common.Address, paymentId string) (*common.Address, error)
+
+	RegisterWebhook(hook *Webhook, timestamp uint64) (*Webhook, error)
+	RemoveWebhook(id crypto.Hash) error
+	ListWebhooks() ([]*Webhook, error)
+	ListWebhookDeliveries(now uint64, limit int) ([]*WebhookDelivery, error)
+	CountWebhookDeliveries(id crypto.Hash) (uint64, error)
+	AckWebhookDelivery(d *WebhookDelivery) error
+	RetryWebhookDelivery(d *WebhookDelivery) error
+
+	ReadEventSinkOffset() (uint64, error)
+	WriteEventSinkOffset(offset uint64) error
+
+	WriteAuditEntry(entry *common.AuditEntry) error
+	WriteAuditEntries(entries []*common.AuditEntry) error
+	ListAuditEntries(offset, count uint64, action string) ([]*common.AuditEntry, error)
 }
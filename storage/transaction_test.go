@@ -142,10 +142,29 @@ func TestTransaction(t *testing.T) {
 	require.Nil(err)
 	require.Equal("365562.00000000", balance.String())
 
+	missing := crypto.Blake3Hash([]byte("missing"))
+	txs, snaps, err := store.ReadTransactions([]crypto.Hash{deposit.AsVersioned().PayloadHash(), missing, submit.AsVersioned().PayloadHash()})
+	require.Nil(err)
+	require.Len(txs, 3)
+	require.Equal(deposit.AsVersioned().PayloadHash(), txs[0].PayloadHash())
+	require.NotEqual("", snaps[0])
+	require.Nil(txs[1])
+	require.Equal("", snaps[1])
+	require.Equal(submit.AsVersioned().PayloadHash(), txs[2].PayloadHash())
+	require.Equal(snap.PayloadHash().String(), snaps[2])
+	_, _, err = store.ReadTransactions(make([]crypto.Hash, TransactionsReadBatchLimit+1))
+	require.NotNil(err)
+
 	ver, ss, err := store.ReadWithdrawalClaim(submit.AsVersioned().PayloadHash())
 	require.Nil(err)
 	require.Equal("", ss)
 	require.Nil(ver)
+	_, err = store.ListPendingWithdrawalClaims(0)
+	require.NotNil(err)
+	pending, err := store.ListPendingWithdrawalClaims(10)
+	require.Nil(err)
+	require.Len(pending, 1)
+	require.Equal(submit.AsVersioned().PayloadHash(), pending[0].PayloadHash())
 
 	claim := common.NewTransactionV5(common.XINAssetId)
 	claim.AddInput(deposit.AsVersioned().PayloadHash(), 1)
@@ -178,6 +197,9 @@ func TestTransaction(t *testing.T) {
 	require.Nil(err)
 	require.Equal(topo.PayloadHash().String(), ss)
 	require.Equal(claim.AsVersioned().PayloadHash(), ver.PayloadHash())
+	pending, err = store.ListPendingWithdrawalClaims(10)
+	require.Nil(err)
+	require.Len(pending, 0)
 
 	_, balance, err = store.ReadAssetWithBalance(common.XINAssetId)
 	require.Nil(err)
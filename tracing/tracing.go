@@ -0,0 +1,73 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	ScopeName   = "github.com/MixinNetwork/mixin"
+	ServiceName = "mixin-kernel"
+)
+
+// Setup exports the spans to the OTLP/HTTP endpoint, e.g.
+// http://127.0.0.1:4318/v1/traces. The sampling is decided by the trace id,
+// i.e. the snapshot hash, so all nodes trace the same snapshots.
+func Setup(endpoint string, ratio float64, nodeId crypto.Hash) (func(context.Context) error, error) {
+	if ratio <= 0 || ratio > 1 {
+		return nil, fmt.Errorf("invalid tracing ratio %f", ratio)
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid tracing endpoint %s", endpoint)
+	}
+	exporter, err := otlptracehttp.New(context.Background(), otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, err
+	}
+	res := resource.NewSchemaless(
+		attribute.String("service.name", ServiceName),
+		attribute.String("service.instance.id", nodeId.String()),
+	)
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.TraceIDRatioBased(ratio)),
+	)
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+// StartSnapshot starts a span in the trace of the snapshot, the trace id
+// is derived from the snapshot hash, so the spans of all the lifecycle
+// stages of the snapshot, even from different nodes, are in the same
+// trace without any context propagation through the queues or the p2p
+// messages. The parent span is virtual and never exported.
+func StartSnapshot(name string, hash crypto.Hash, attrs ...attribute.KeyValue) trace.Span {
+	var tid trace.TraceID
+	var sid trace.SpanID
+	copy(tid[:], hash[:16])
+	copy(sid[:], hash[16:24])
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    tid,
+		SpanID:     sid,
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	})
+	ctx := trace.ContextWithRemoteSpanContext(context.Background(), sc)
+	attrs = append(attrs, attribute.String("snapshot.hash", hash.String()))
+	_, span := otel.Tracer(ScopeName).Start(ctx, name, trace.WithAttributes(attrs...))
+	return span
+}
@@ -0,0 +1,43 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestSnapshotTracing(t *testing.T) {
+	require := require.New(t)
+
+	requests := make(chan *http.Request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- r
+	}))
+	defer server.Close()
+
+	_, err := Setup("tcp://127.0.0.1:4318", 1, crypto.Hash{})
+	require.NotNil(err)
+	_, err = Setup(server.URL, 0, crypto.Hash{})
+	require.NotNil(err)
+
+	shutdown, err := Setup(server.URL+"/v1/traces", 1, crypto.Blake3Hash([]byte("node")))
+	require.Nil(err)
+
+	hash := crypto.Blake3Hash([]byte("snapshot"))
+	span := StartSnapshot("cosi.announcement", hash, attribute.Int64("snapshot.round", 7))
+	sc := span.SpanContext()
+	require.Equal(trace.TraceID(hash[:16]), sc.TraceID())
+	require.True(sc.IsSampled())
+	span.End()
+	require.Nil(shutdown(context.Background()))
+
+	r := <-requests
+	require.Equal("/v1/traces", r.URL.Path)
+	require.Equal("application/x-protobuf", r.Header.Get("Content-Type"))
+}
@@ -0,0 +1,147 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/mixin/storage"
+)
+
+const (
+	NodeHeader      = "X-Mixin-Node"
+	SignatureHeader = "X-Mixin-Signature"
+
+	deliveryBatchSize  = 64
+	retryBackoffMax    = time.Hour
+	retryBackoffMin    = time.Second
+	dispatchInterval   = time.Second
+	deliveryTimeout    = 10 * time.Second
+	lastErrorMaxLength = 256
+)
+
+// the dispatcher posts the pending deliveries written by the store when the
+// matching transactions finalize, the body is signed by the node signer key,
+// so the receiver verifies the signature of the blake3 hash of the body with
+// the signer public key of the node in the X-Mixin-Node header.
+type Dispatcher struct {
+	store  storage.Store
+	nodeId crypto.Hash
+	sign   func([]byte) (crypto.Signature, error)
+	client *http.Client
+}
+
+func NewDispatcher(store storage.Store, nodeId crypto.Hash, sign func([]byte) (crypto.Signature, error)) *Dispatcher {
+	return &Dispatcher{
+		store:  store,
+		nodeId: nodeId,
+		sign:   sign,
+		client: &http.Client{Timeout: deliveryTimeout},
+	}
+}
+
+// Loop waits while paused, e.g. under the memory pressure of the node, and the
+// due deliveries are kept in the store until resumed.
+func (d *Dispatcher) Loop(paused func() bool) {
+	for {
+		if paused() {
+			time.Sleep(dispatchInterval)
+			continue
+		}
+		n, err := d.Dispatch(time.Now())
+		if err != nil {
+			logger.Printf("webhook.Dispatch() => %v\n", err)
+		}
+		if n < deliveryBatchSize {
+			time.Sleep(dispatchInterval)
+		}
+	}
+}
+
+// Dispatch posts a batch of the due deliveries, and returns the number of
+// deliveries attempted. The failed ones are retried with exponential backoff.
+func (d *Dispatcher) Dispatch(now time.Time) (int, error) {
+	deliveries, err := d.store.ListWebhookDeliveries(uint64(now.UnixNano()), deliveryBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	hooks, err := d.store.ListWebhooks()
+	if err != nil {
+		return 0, err
+	}
+	urls := make(map[crypto.Hash]string)
+	for _, h := range hooks {
+		urls[h.Id] = h.URL
+	}
+
+	failed := make(map[crypto.Hash]bool)
+	for _, wd := range deliveries {
+		n := wd.Notification
+		url := urls[n.Webhook]
+		// keep the order of the deliveries of a webhook
+		if url == "" || failed[n.Webhook] {
+			continue
+		}
+		err = d.post(url, n)
+		if err == nil {
+			err = d.store.AckWebhookDelivery(wd)
+			if err != nil {
+				return 0, err
+			}
+			continue
+		}
+		logger.Verbosef("webhook.post(%s, %d) => %v\n", url, n.Sequence, err)
+		failed[n.Webhook] = true
+		wd.Attempts = wd.Attempts + 1
+		wd.NextAttempt = uint64(now.Add(retryBackoff(wd.Attempts)).UnixNano())
+		wd.LastError = err.Error()
+		if len(wd.LastError) > lastErrorMaxLength {
+			wd.LastError = wd.LastError[:lastErrorMaxLength]
+		}
+		err = d.store.RetryWebhookDelivery(wd)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(deliveries), nil
+}
+
+func (d *Dispatcher) post(url string, n *storage.WebhookNotification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		panic(err)
+	}
+	sig, err := d.sign(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(NodeHeader, d.nodeId.String())
+	req.Header.Set(SignatureHeader, sig.String())
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook response status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func retryBackoff(attempts uint64) time.Duration {
+	if attempts > 12 {
+		return retryBackoffMax
+	}
+	return min(retryBackoffMin<<attempts, retryBackoffMax)
+}
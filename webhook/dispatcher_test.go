@@ -0,0 +1,100 @@
+package webhook
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/storage"
+	"github.com/stretchr/testify/require"
+)
+
+type testStore struct {
+	storage.Store
+	hooks      []*storage.Webhook
+	deliveries []*storage.WebhookDelivery
+	acked      []uint64
+	retried    []*storage.WebhookDelivery
+}
+
+func (s *testStore) ListWebhooks() ([]*storage.Webhook, error) {
+	return s.hooks, nil
+}
+
+func (s *testStore) ListWebhookDeliveries(now uint64, limit int) ([]*storage.WebhookDelivery, error) {
+	return s.deliveries, nil
+}
+
+func (s *testStore) AckWebhookDelivery(d *storage.WebhookDelivery) error {
+	s.acked = append(s.acked, d.Notification.Sequence)
+	return nil
+}
+
+func (s *testStore) RetryWebhookDelivery(d *storage.WebhookDelivery) error {
+	s.retried = append(s.retried, d)
+	return nil
+}
+
+func TestDispatcher(t *testing.T) {
+	require := require.New(t)
+
+	signer := crypto.NewKeyFromSeed(make([]byte, 64))
+	nodeId := crypto.Blake3Hash([]byte("node"))
+	var received []uint64
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var sig crypto.Signature
+		b, err := hex.DecodeString(r.Header.Get(SignatureHeader))
+		require.Nil(err)
+		copy(sig[:], b)
+		pub := signer.Public()
+		require.True(pub.Verify(crypto.Blake3Hash(body), sig))
+		require.Equal(nodeId.String(), r.Header.Get(NodeHeader))
+		var n storage.WebhookNotification
+		require.Nil(json.Unmarshal(body, &n))
+		received = append(received, n.Sequence)
+	}))
+	defer good.Close()
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer bad.Close()
+
+	goodId, badId := crypto.Blake3Hash([]byte("good")), crypto.Blake3Hash([]byte("bad"))
+	store := &testStore{
+		hooks: []*storage.Webhook{{Id: goodId, URL: good.URL}, {Id: badId, URL: bad.URL}},
+	}
+	for i := uint64(1); i <= 3; i++ {
+		for _, id := range []crypto.Hash{goodId, badId} {
+			store.deliveries = append(store.deliveries, &storage.WebhookDelivery{
+				Notification: &storage.WebhookNotification{Webhook: id, Sequence: i},
+			})
+		}
+	}
+
+	dispatcher := NewDispatcher(store, nodeId, func(b []byte) (crypto.Signature, error) {
+		return signer.Sign(crypto.Blake3Hash(b)), nil
+	})
+	now := time.Now()
+	n, err := dispatcher.Dispatch(now)
+	require.Nil(err)
+	require.Equal(6, n)
+	require.Equal([]uint64{1, 2, 3}, received)
+	require.Equal([]uint64{1, 2, 3}, store.acked)
+	require.Len(store.retried, 1)
+	d := store.retried[0]
+	require.Equal(badId, d.Notification.Webhook)
+	require.Equal(uint64(1), d.Notification.Sequence)
+	require.Equal(uint64(1), d.Attempts)
+	require.Equal(uint64(now.Add(2*time.Second).UnixNano()), d.NextAttempt)
+	require.Equal("webhook response status 500", d.LastError)
+
+	require.Equal(2*time.Second, retryBackoff(1))
+	require.Equal(time.Hour, retryBackoff(12))
+	require.Equal(time.Hour, retryBackoff(100))
+}